@@ -0,0 +1,176 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package access decides whether cards tapped at access control readers
+// are granted access. A Policy enforces per-UID rate limits and
+// anti-passback windows, together with a callback for application rules,
+// and reports every decision as an access granted or denied event.
+package access
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit"
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// ErrDenied is wrapped by the errors of handlers guarded by a Policy for
+// denied cards.
+var ErrDenied = errors.New("access: denied")
+
+// Reasons of denied decisions.
+const (
+	ReasonNoUID     = "no-uid"
+	ReasonRateLimit = "rate-limit"
+	ReasonPassback  = "anti-passback"
+)
+
+// Decision is the outcome of a tap.
+type Decision struct {
+	Allowed bool
+	// Reason is why the tap was denied: one of the Reason constants or the
+	// error of Policy.Check.
+	Reason string
+	Event  cardreader.Event
+}
+
+// AccessEvent returns the access granted or denied event of the decision.
+func (d Decision) AccessEvent() cardreader.Event {
+	ev := d.Event
+	ev.Type, ev.Reason = cardreader.EventAccessGranted, ""
+	if !d.Allowed {
+		ev.Type, ev.Reason = cardreader.EventAccessDenied, d.Reason
+	}
+	return ev
+}
+
+// Policy decides taps. Its zero value allows every card with a UID.
+type Policy struct {
+	// RateLimit, when greater than zero, is the number of taps of a UID
+	// granted within RateWindow.
+	RateLimit  int
+	RateWindow time.Duration
+	// Passback, when set, denies a UID granted in a zone access to the
+	// same zone again within the window, unless it was granted in another
+	// zone meanwhile, e.g. left through the exit. Readers without a zone
+	// are zones of their own.
+	Passback time.Duration
+	// Check, when set, is called for taps passing the limits; a non-nil
+	// error denies the tap with the error as reason.
+	Check func(ev cardreader.Event) error
+	// Emit, when set, receives the access event of every decision, e.g.
+	// the Emit method of an SDK passing it to the event sinks.
+	Emit func(ev cardreader.Event)
+
+	mu    sync.Mutex
+	uids  map[string]*history
+	swept time.Time
+}
+
+// history is the record of the grants of a UID.
+type history struct {
+	grants []time.Time // Within RateWindow.
+	zone   string      // Zone of the last grant.
+	last   time.Time   // Time of the last grant.
+}
+
+// Decide decides the tap ev, at ev.Time or now when unset, and records it
+// when allowed.
+func (p *Policy) Decide(ev cardreader.Event) Decision {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	d := p.decide(ev)
+	if p.Emit != nil {
+		p.Emit(d.AccessEvent())
+	}
+	return d
+}
+
+func (p *Policy) decide(ev cardreader.Event) Decision {
+	d := Decision{Event: ev}
+	if len(ev.UID) == 0 {
+		d.Reason = ReasonNoUID
+		return d
+	}
+	zone := ev.Zone
+	if zone == "" {
+		zone = ev.Reader
+	}
+	now := ev.Time
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sweep(now)
+	h := p.uids[string(ev.UID)]
+	if h == nil {
+		h = &history{}
+	}
+	if p.RateLimit > 0 {
+		kept := h.grants[:0]
+		for _, t := range h.grants {
+			if now.Sub(t) < p.RateWindow {
+				kept = append(kept, t)
+			}
+		}
+		h.grants = kept
+		if len(h.grants) >= p.RateLimit {
+			d.Reason = ReasonRateLimit
+			return d
+		}
+	}
+	if p.Passback > 0 && h.zone == zone && now.Sub(h.last) < p.Passback {
+		d.Reason = ReasonPassback
+		return d
+	}
+	if p.Check != nil {
+		// Check runs with the lock held, so decisions of a UID are
+		// serialized.
+		if err := p.Check(ev); err != nil {
+			d.Reason = err.Error()
+			return d
+		}
+	}
+	if p.RateLimit > 0 {
+		h.grants = append(h.grants, now)
+	}
+	h.zone, h.last = zone, now
+	if p.uids == nil {
+		p.uids = make(map[string]*history)
+	}
+	p.uids[string(ev.UID)] = h
+	d.Allowed = true
+	return d
+}
+
+// sweep forgets UIDs whose grants no longer affect decisions, at most once
+// a minute.
+func (p *Policy) sweep(now time.Time) {
+	if now.Sub(p.swept) < time.Minute {
+		return
+	}
+	p.swept = now
+	keep := max(p.RateWindow, p.Passback)
+	for uid, h := range p.uids {
+		if now.Sub(h.last) >= keep {
+			delete(p.uids, uid)
+		}
+	}
+}
+
+// Guard returns a handler calling next for granted cards only. Denied
+// cards fail with an error wrapping ErrDenied, logged by the SDK.
+func (p *Policy) Guard(next scardkit.CardHandler) scardkit.CardHandler {
+	return func(ctx context.Context, ev cardreader.Event, card transport.Card) error {
+		if d := p.Decide(ev); !d.Allowed {
+			return fmt.Errorf("%w: %s", ErrDenied, d.Reason)
+		}
+		return next(ctx, ev, card)
+	}
+}
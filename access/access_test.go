@@ -0,0 +1,75 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package access
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+func TestPolicy(t *testing.T) {
+	var events []cardreader.Event
+	p := &Policy{
+		RateLimit:  3,
+		RateWindow: time.Hour,
+		Passback:   10 * time.Minute,
+		Check: func(ev cardreader.Event) error {
+			if ev.UID[0] == 0xBA {
+				return errors.New("revoked")
+			}
+			return nil
+		},
+		Emit: func(ev cardreader.Event) { events = append(events, ev) },
+	}
+	start := time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC)
+	alice, bob := []byte{0x04, 0xA1}, []byte{0xBA, 0xB0}
+	tests := []struct {
+		uid    []byte
+		zone   string
+		after  time.Duration
+		reason string // Empty when allowed.
+	}{
+		{alice, "lobby", 0, ""},
+		{alice, "lobby", time.Minute, ReasonPassback},
+		{alice, "exit", 2 * time.Minute, ""},
+		{alice, "lobby", 3 * time.Minute, ""},
+		{alice, "exit", 4 * time.Minute, ReasonRateLimit},
+		{alice, "exit", 61 * time.Minute, ""},
+		{bob, "lobby", 0, "revoked"},
+		{nil, "lobby", 0, ReasonNoUID},
+	}
+	for i, tt := range tests {
+		ev := cardreader.Event{Type: cardreader.EventCardInserted, Reader: "r", Zone: tt.zone, UID: tt.uid, Time: start.Add(tt.after)}
+		d := p.Decide(ev)
+		if d.Allowed != (tt.reason == "") || d.Reason != tt.reason {
+			t.Errorf("%d: Decide() = %v %q, want %q", i, d.Allowed, d.Reason, tt.reason)
+		}
+	}
+	if len(events) != len(tests) || events[0].Type != cardreader.EventAccessGranted ||
+		events[1].Type != cardreader.EventAccessDenied || events[1].Reason != ReasonPassback {
+		t.Errorf("emitted %+v", events)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	p := &Policy{Passback: time.Minute}
+	calls := 0
+	h := p.Guard(func(context.Context, cardreader.Event, transport.Card) error {
+		calls++
+		return nil
+	})
+	ev := cardreader.Event{Reader: "turnstile", UID: []byte{1}}
+	if err := h(context.Background(), ev, nil); err != nil {
+		t.Fatalf("first tap error = %v", err)
+	}
+	if err := h(context.Background(), ev, nil); !errors.Is(err, ErrDenied) || calls != 1 {
+		t.Errorf("second tap error = %v, %d calls", err, calls)
+	}
+}
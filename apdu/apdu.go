@@ -21,7 +21,22 @@ type Response struct {
 }
 
 // CheckStatus checks the status words of a response APDU and returns an error if they indicate an error.
-func CheckStatus(sw1, sw2 byte) error { return nil }
+func CheckStatus(sw1, sw2 byte) error {
+	if sw1 == 0x90 && sw2 == 0x00 {
+		return nil
+	}
+	return &StatusError{SW1: sw1, SW2: sw2}
+}
+
+// StatusError is returned for response APDUs whose status words indicate
+// an error or a warning.
+type StatusError struct {
+	SW1, SW2 byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("card returned status %02X%02X", e.SW1, e.SW2)
+}
 
 // CheckStatusFromData interprets the status words from the last two bytes of a data slice.
 func CheckStatusFromData(data []byte) error {
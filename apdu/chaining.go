@@ -0,0 +1,81 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package apdu
+
+// Chaining splits commands carrying more than size bytes of data, short or
+// extended length, into a chain of short commands of at most size bytes
+// each, flagged with the chaining bit (CLA b5) but the last one, as defined
+// by ISO 7816-4. It suits cards and readers without extended length
+// support, such as most Type 4 tags. The chain stops at the first
+// intermediate response other than 90 00, which is returned. The response
+// to the last command is returned as is; wrap the transceiver with
+// GetResponse as well to concatenate responses announced by 61xx. A size
+// outside 1 to 255 chains commands with more than 255 bytes of data.
+func Chaining(size int) Middleware {
+	if size < 1 || size > 255 {
+		size = 255
+	}
+	return func(next Transceiver) Transceiver {
+		return TransceiverFunc(func(cmd []byte) ([]byte, error) {
+			header, data, le, ok := splitCommand(cmd)
+			if !ok || len(data) <= size {
+				return next.Transmit(cmd)
+			}
+			for {
+				n := min(len(data), size)
+				part := make([]byte, 0, 6+n)
+				part = append(append(part, header...), byte(n))
+				part = append(part, data[:n]...)
+				data = data[n:]
+				if len(data) == 0 {
+					return next.Transmit(append(part, le...))
+				}
+				part[0] |= 0x10
+				resp, err := next.Transmit(part)
+				if err != nil || len(resp) != 2 || resp[0] != 0x90 || resp[1] != 0x00 {
+					return resp, err
+				}
+			}
+		})
+	}
+}
+
+// splitCommand splits the command APDU cmd into its header, data and Le,
+// converted to a short Le. It reports false for commands without data or
+// malformed ones.
+func splitCommand(cmd []byte) (header, data, le []byte, ok bool) {
+	if len(cmd) <= 5 {
+		return nil, nil, nil, false
+	}
+	header = cmd[:4]
+	if cmd[4] != 0 {
+		lc := int(cmd[4])
+		switch len(cmd) {
+		case 5 + lc:
+			return header, cmd[5:], nil, true
+		case 6 + lc:
+			return header, cmd[5 : 5+lc], cmd[5+lc:], true
+		}
+		return nil, nil, nil, false
+	}
+	if len(cmd) < 7 {
+		return nil, nil, nil, false
+	}
+	lc := int(cmd[5])<<8 | int(cmd[6])
+	if lc == 0 {
+		return nil, nil, nil, false
+	}
+	switch len(cmd) {
+	case 7 + lc:
+		return header, cmd[7:], nil, true
+	case 9 + lc:
+		ne := int(cmd[7+lc])<<8 | int(cmd[8+lc])
+		if ne == 0 || ne > 256 {
+			ne = 256
+		}
+		return header, cmd[7 : 7+lc], []byte{byte(ne)}, true
+	}
+	return nil, nil, nil, false
+}
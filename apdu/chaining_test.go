@@ -0,0 +1,67 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package apdu
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestChaining(t *testing.T) {
+	data := make([]byte, 300)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	short := append([]byte{0x00, 0xD6, 0x00, 0x00, 200}, data[:200]...)
+	extended := append(append([]byte{0x00, 0xDB, 0x3F, 0xFF, 0x00, 0x01, 0x2C}, data...), 0x00, 0x00)
+	tests := []struct {
+		name string
+		size int
+		cmd  []byte
+		want []string // Commands sent, as header, data length and Le.
+	}{
+		{"fits", 255, short, []string{"00D60000 200 -"}},
+		{"short", 128, short, []string{"10D60000 128 -", "00D60000 72 -"}},
+		{"extended", 0, extended, []string{"10DB3FFF 255 -", "00DB3FFF 45 00"}},
+		{"no data", 10, []byte{0x00, 0xB0, 0x00, 0x00, 0x00}, []string{"00B0000000"}},
+	}
+	for _, tt := range tests {
+		var sent []string
+		var got []byte
+		card := TransceiverFunc(func(cmd []byte) ([]byte, error) {
+			if len(cmd) > 5 {
+				h, d, le, _ := splitCommand(cmd)
+				sent = append(sent, fmt.Sprintf("%X %d %s", h, len(d), leString(le)))
+				got = append(got, d...)
+			} else {
+				sent = append(sent, fmt.Sprintf("%X", cmd))
+			}
+			return []byte{0x90, 0x00}, nil
+		})
+		resp, err := Wrap(card, Chaining(tt.size)).Transmit(tt.cmd)
+		if err != nil || !bytes.Equal(resp, []byte{0x90, 0x00}) {
+			t.Errorf("%s: Transmit() = %X, %v", tt.name, resp, err)
+		}
+		if fmt.Sprint(sent) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: sent %q, want %q", tt.name, sent, tt.want)
+		}
+		if _, d, _, ok := splitCommand(tt.cmd); ok && !bytes.Equal(got, d) {
+			t.Errorf("%s: chained data differs", tt.name)
+		}
+	}
+
+	card := TransceiverFunc(func(cmd []byte) ([]byte, error) { return []byte{0x6A, 0x84}, nil })
+	if resp, _ := Wrap(card, Chaining(100)).Transmit(short); !bytes.Equal(resp, []byte{0x6A, 0x84}) {
+		t.Errorf("Transmit() = %X, want the failing intermediate response", resp)
+	}
+}
+
+func leString(le []byte) string {
+	if le == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%X", le)
+}
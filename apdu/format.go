@@ -0,0 +1,284 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package apdu
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// LevelTrace is the level below debug Dump is meant for, so exchange dumps
+// are enabled separately from other debug records.
+const LevelTrace = slog.LevelDebug - 4
+
+// InsName returns the name of the instruction ins of class cla, or "" when
+// unknown.
+func InsName(cla, ins byte) string {
+	switch cla {
+	case 0xFF:
+		switch ins {
+		case 0xCA:
+			return "GET DATA (PC/SC)"
+		case 0xB0:
+			return "READ BINARY (PC/SC)"
+		case 0xD6:
+			return "UPDATE BINARY (PC/SC)"
+		case 0x82:
+			return "LOAD KEYS (PC/SC)"
+		case 0x86:
+			return "GENERAL AUTHENTICATE (PC/SC)"
+		case 0x00:
+			return "DIRECT TRANSMIT (PC/SC)"
+		}
+		return ""
+	case 0x90:
+		return fmt.Sprintf("DESFIRE %02X", ins)
+	}
+	if cla&0x80 != 0 {
+		// GlobalPlatform commands of proprietary classes.
+		switch ins {
+		case 0x50:
+			return "INITIALIZE UPDATE"
+		case 0x82:
+			return "EXTERNAL AUTHENTICATE"
+		case 0xD8:
+			return "PUT KEY"
+		case 0xE4:
+			return "DELETE"
+		case 0xE6:
+			return "INSTALL"
+		case 0xE8:
+			return "LOAD"
+		case 0xF0:
+			return "SET STATUS"
+		case 0xF2:
+			return "GET STATUS"
+		case 0xCA, 0xCB:
+			return "GET DATA"
+		case 0xA4:
+			return "SELECT"
+		}
+		return ""
+	}
+	switch ins {
+	case 0x0E:
+		return "ERASE BINARY"
+	case 0x20:
+		return "VERIFY"
+	case 0x24:
+		return "CHANGE REFERENCE DATA"
+	case 0x2C:
+		return "RESET RETRY COUNTER"
+	case 0x70:
+		return "MANAGE CHANNEL"
+	case 0x82:
+		return "EXTERNAL AUTHENTICATE"
+	case 0x84:
+		return "GET CHALLENGE"
+	case 0x86, 0x87:
+		return "GENERAL AUTHENTICATE"
+	case 0x88:
+		return "INTERNAL AUTHENTICATE"
+	case 0xA4:
+		return "SELECT"
+	case 0xB0, 0xB1:
+		return "READ BINARY"
+	case 0xB2, 0xB3:
+		return "READ RECORD"
+	case 0xC0:
+		return "GET RESPONSE"
+	case 0xCA, 0xCB:
+		return "GET DATA"
+	case 0xD6, 0xD7:
+		return "UPDATE BINARY"
+	case 0xDA, 0xDB:
+		return "PUT DATA"
+	case 0xDC, 0xDD:
+		return "UPDATE RECORD"
+	case 0xE2:
+		return "APPEND RECORD"
+	}
+	return ""
+}
+
+// StatusText returns the ISO 7816-4 meaning of the status words, or ""
+// when unknown.
+func StatusText(sw1, sw2 byte) string {
+	switch sw1 {
+	case 0x61:
+		return fmt.Sprintf("%d bytes available", sw2)
+	case 0x6C:
+		return fmt.Sprintf("wrong Le, %d bytes available", sw2)
+	case 0x63:
+		if sw2&0xF0 == 0xC0 {
+			return fmt.Sprintf("verification failed, %d retries left", sw2&0x0F)
+		}
+	}
+	switch uint16(sw1)<<8 | uint16(sw2) {
+	case 0x9000:
+		return "success"
+	case 0x6200:
+		return "warning, memory unchanged"
+	case 0x6281:
+		return "part of returned data may be corrupted"
+	case 0x6282:
+		return "end of file reached before reading Le bytes"
+	case 0x6283:
+		return "selected file invalidated"
+	case 0x6300:
+		return "verification failed"
+	case 0x6581:
+		return "memory failure"
+	case 0x6700:
+		return "wrong length"
+	case 0x6800:
+		return "functions in CLA not supported"
+	case 0x6881:
+		return "logical channel not supported"
+	case 0x6882:
+		return "secure messaging not supported"
+	case 0x6900:
+		return "command not allowed"
+	case 0x6981:
+		return "command incompatible with file structure"
+	case 0x6982:
+		return "security status not satisfied"
+	case 0x6983:
+		return "authentication method blocked"
+	case 0x6984:
+		return "reference data not usable"
+	case 0x6985:
+		return "conditions of use not satisfied"
+	case 0x6986:
+		return "command not allowed, no current EF"
+	case 0x6987:
+		return "expected secure messaging data objects missing"
+	case 0x6988:
+		return "incorrect secure messaging data objects"
+	case 0x6A80:
+		return "incorrect parameters in the data field"
+	case 0x6A81:
+		return "function not supported"
+	case 0x6A82:
+		return "file or application not found"
+	case 0x6A83:
+		return "record not found"
+	case 0x6A84:
+		return "not enough memory space in the file"
+	case 0x6A86:
+		return "incorrect parameters P1-P2"
+	case 0x6A88:
+		return "referenced data not found"
+	case 0x6B00:
+		return "wrong parameters P1-P2"
+	case 0x6D00:
+		return "instruction not supported"
+	case 0x6E00:
+		return "class not supported"
+	case 0x6F00:
+		return "no precise diagnosis"
+	}
+	return ""
+}
+
+// HexDump renders data as lines of 16 bytes: the offset, the bytes in hex
+// and their printable ASCII characters.
+func HexDump(data []byte) string {
+	var b strings.Builder
+	for off := 0; off < len(data); off += 16 {
+		line := data[off:min(off+16, len(data))]
+		fmt.Fprintf(&b, "%04X  ", off)
+		for i := 0; i < 16; i++ {
+			if i < len(line) {
+				fmt.Fprintf(&b, "%02X ", line[i])
+			} else {
+				b.WriteString("   ")
+			}
+		}
+		b.WriteString(" |")
+		for _, c := range line {
+			if c < 0x20 || c > 0x7E {
+				c = '.'
+			}
+			b.WriteByte(c)
+		}
+		b.WriteString("|\n")
+	}
+	return b.String()
+}
+
+// FormatExchange renders an exchange for debugging: the command header with
+// the instruction name, the response status words with their meaning and
+// the duration d, each followed by a hex dump of its data.
+func FormatExchange(cmd, resp []byte, d time.Duration, err error) string {
+	var b strings.Builder
+	b.WriteString("> ")
+	if len(cmd) >= 4 {
+		fmt.Fprintf(&b, "%02X %02X %02X %02X", cmd[0], cmd[1], cmd[2], cmd[3])
+		if name := InsName(cmd[0], cmd[1]); name != "" {
+			b.WriteString("  " + name)
+		}
+	} else {
+		fmt.Fprintf(&b, "% X", cmd)
+	}
+	b.WriteByte('\n')
+	if _, data, _, ok := splitCommand(cmd); ok && len(data) > 0 {
+		b.WriteString(indent(HexDump(data)))
+	}
+	switch {
+	case err != nil:
+		fmt.Fprintf(&b, "< error: %v (%s)\n", err, d)
+	case len(resp) < 2:
+		fmt.Fprintf(&b, "< % X (%s)\n", resp, d)
+	default:
+		sw1, sw2 := resp[len(resp)-2], resp[len(resp)-1]
+		fmt.Fprintf(&b, "< %02X%02X", sw1, sw2)
+		if text := StatusText(sw1, sw2); text != "" {
+			b.WriteString(" " + text)
+		}
+		fmt.Fprintf(&b, " (%s)\n", d)
+		b.WriteString(indent(HexDump(resp[:len(resp)-2])))
+	}
+	return b.String()
+}
+
+func indent(s string) string {
+	if s == "" {
+		return s
+	}
+	return "  " + strings.ReplaceAll(strings.TrimSuffix(s, "\n"), "\n", "\n  ") + "\n"
+}
+
+// Dump logs each exchange formatted by FormatExchange on logger at level,
+// typically LevelTrace. The data of exchanges for which Sensitive reports
+// true is left out.
+func Dump(logger *slog.Logger, level slog.Level) Middleware {
+	return func(next Transceiver) Transceiver {
+		return TransceiverFunc(func(cmd []byte) ([]byte, error) {
+			if !logger.Enabled(context.Background(), level) {
+				return next.Transmit(cmd)
+			}
+			start := time.Now()
+			resp, err := next.Transmit(cmd)
+			d := time.Since(start)
+			dcmd, dresp := cmd, resp
+			if Sensitive(cmd) {
+				dcmd = cmd[:min(len(cmd), 4)]
+				if len(resp) >= 2 {
+					dresp = resp[len(resp)-2:]
+				}
+			}
+			attrs := []slog.Attr{slog.String("dump", FormatExchange(dcmd, dresp, d, err)), slog.Duration("duration", d)}
+			if len(resp) >= 2 && err == nil {
+				attrs = append(attrs, slog.String("sw", fmt.Sprintf("%02X%02X", resp[len(resp)-2], resp[len(resp)-1])))
+			}
+			logger.LogAttrs(context.Background(), level, "apdu", attrs...)
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package apdu
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusText(t *testing.T) {
+	tests := []struct {
+		sw1, sw2 byte
+		want     string
+	}{
+		{0x90, 0x00, "success"},
+		{0x6A, 0x82, "file or application not found"},
+		{0x61, 0x10, "16 bytes available"},
+		{0x6C, 0x08, "wrong Le, 8 bytes available"},
+		{0x63, 0xC2, "verification failed, 2 retries left"},
+		{0x91, 0xAE, ""},
+	}
+	for _, tt := range tests {
+		if got := StatusText(tt.sw1, tt.sw2); got != tt.want {
+			t.Errorf("StatusText(%02X%02X) = %q, want %q", tt.sw1, tt.sw2, got, tt.want)
+		}
+	}
+}
+
+func TestInsName(t *testing.T) {
+	tests := []struct {
+		cla, ins byte
+		want     string
+	}{
+		{0x00, 0xA4, "SELECT"},
+		{0x0C, 0xB0, "READ BINARY"},
+		{0x80, 0x50, "INITIALIZE UPDATE"},
+		{0xFF, 0xCA, "GET DATA (PC/SC)"},
+		{0x90, 0x60, "DESFIRE 60"},
+		{0x00, 0x01, ""},
+	}
+	for _, tt := range tests {
+		if got := InsName(tt.cla, tt.ins); got != tt.want {
+			t.Errorf("InsName(%02X, %02X) = %q, want %q", tt.cla, tt.ins, got, tt.want)
+		}
+	}
+}
+
+func TestHexDump(t *testing.T) {
+	data := []byte("0123456789abcdef\x00\xFFz")
+	want := "0000  30 31 32 33 34 35 36 37 38 39 61 62 63 64 65 66  |0123456789abcdef|\n" +
+		"0010  00 FF 7A                                         |..z|\n"
+	if got := HexDump(data); got != want {
+		t.Errorf("HexDump() =\n%s\nwant\n%s", got, want)
+	}
+	if got := HexDump(nil); got != "" {
+		t.Errorf("HexDump(nil) = %q", got)
+	}
+}
+
+func TestFormatExchange(t *testing.T) {
+	cmd, _ := hex.DecodeString("00A4040007D276000085010100")
+	got := FormatExchange(cmd, []byte{0x6A, 0x82}, 3*time.Millisecond, nil)
+	want := "> 00 A4 04 00  SELECT\n" +
+		"  0000  D2 76 00 00 85 01 01                             |.v.....|\n" +
+		"< 6A82 file or application not found (3ms)\n"
+	if got != want {
+		t.Errorf("FormatExchange() =\n%s\nwant\n%s", got, want)
+	}
+	got = FormatExchange([]byte{0x00, 0x84, 0x00, 0x00, 0x08}, nil, time.Millisecond, errors.New("card removed"))
+	if !strings.HasSuffix(got, "< error: card removed (1ms)\n") {
+		t.Errorf("FormatExchange() with error =\n%s", got)
+	}
+}
+
+func TestDump(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: LevelTrace}))
+	card := TransceiverFunc(func([]byte) ([]byte, error) { return []byte{0xDE, 0xAD, 0xBE, 0xEF, 0x90, 0x00}, nil })
+	tr := Wrap(card, Dump(logger, LevelTrace))
+	if _, err := tr.Transmit([]byte{0x00, 0xB0, 0x00, 0x00, 0x04}); err != nil {
+		t.Fatal(err)
+	}
+	if s := logs.String(); !strings.Contains(s, "READ BINARY") || !strings.Contains(s, "DE AD BE EF") || !strings.Contains(s, "sw=9000") {
+		t.Errorf("log = %s", s)
+	}
+
+	logs.Reset()
+	cmd, _ := hex.DecodeString("0020008008313233343536FFFF")
+	if _, err := tr.Transmit(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if s := logs.String(); !strings.Contains(s, "VERIFY") || strings.Contains(s, "31 32") || strings.Contains(s, "DE AD") {
+		t.Errorf("sensitive exchange log = %s", s)
+	}
+
+	logs.Reset()
+	tr = Wrap(card, Dump(logger, slog.LevelDebug-8))
+	if _, err := tr.Transmit([]byte{0x00, 0xB0, 0x00, 0x00, 0x04}); err != nil || logs.Len() != 0 {
+		t.Errorf("disabled Dump logged %q, error %v", logs.String(), err)
+	}
+}
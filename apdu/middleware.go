@@ -0,0 +1,99 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package apdu
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"time"
+)
+
+// Middleware wraps a Transceiver to add behaviour around each exchange, such
+// as logging or retries, without touching the code issuing the commands.
+type Middleware func(next Transceiver) Transceiver
+
+// Wrap returns tr wrapped in mws. The first middleware is the outermost, it
+// sees the command first and the response last.
+func Wrap(tr Transceiver, mws ...Middleware) Transceiver {
+	for i := len(mws) - 1; i >= 0; i-- {
+		tr = mws[i](tr)
+	}
+	return tr
+}
+
+// Logging logs each exchange as hex dump on logger at level.
+func Logging(logger *slog.Logger, level slog.Level) Middleware {
+	return func(next Transceiver) Transceiver {
+		return TransceiverFunc(func(cmd []byte) ([]byte, error) {
+			resp, err := next.Transmit(cmd)
+			logExchange(logger, level, cmd, resp, err)
+			return resp, err
+		})
+	}
+}
+
+// logExchange logs an exchange as hex dump.
+func logExchange(logger *slog.Logger, level slog.Level, cmd, resp []byte, err error) {
+	attrs := []slog.Attr{slog.String("cmd", hex.EncodeToString(cmd))}
+	if err != nil {
+		attrs = append(attrs, slog.Any("err", err))
+	} else {
+		attrs = append(attrs, slog.String("resp", hex.EncodeToString(resp)))
+	}
+	logger.LogAttrs(context.Background(), level, "apdu", attrs...)
+}
+
+// Timing calls observe with the command and the duration of each exchange.
+func Timing(observe func(cmd []byte, d time.Duration, err error)) Middleware {
+	return func(next Transceiver) Transceiver {
+		return TransceiverFunc(func(cmd []byte) ([]byte, error) {
+			start := time.Now()
+			resp, err := next.Transmit(cmd)
+			observe(cmd, time.Since(start), err)
+			return resp, err
+		})
+	}
+}
+
+// Retry retransmits a command up to attempts times in total while the
+// exchange fails with an error for which retryable reports true, waiting
+// interval between attempts. A nil retryable retries on any error. Only use
+// Retry for commands which are safe to repeat.
+func Retry(attempts int, interval time.Duration, retryable func(error) bool) Middleware {
+	return func(next Transceiver) Transceiver {
+		return TransceiverFunc(func(cmd []byte) ([]byte, error) {
+			var (
+				resp []byte
+				err  error
+			)
+			for i := 0; i < max(attempts, 1); i++ {
+				if i > 0 && interval > 0 {
+					time.Sleep(interval)
+				}
+				resp, err = next.Transmit(cmd)
+				if err == nil || (retryable != nil && !retryable(err)) {
+					break
+				}
+			}
+			return resp, err
+		})
+	}
+}
+
+// StatusErrors translates response status words other than 90 00 into a
+// *StatusError. The response is still returned, so callers can inspect
+// the data of warnings.
+func StatusErrors() Middleware {
+	return func(next Transceiver) Transceiver {
+		return TransceiverFunc(func(cmd []byte) ([]byte, error) {
+			resp, err := next.Transmit(cmd)
+			if err != nil {
+				return resp, err
+			}
+			return resp, CheckStatusFromData(resp)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package apdu
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrapOrder(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next Transceiver) Transceiver {
+			return TransceiverFunc(func(cmd []byte) ([]byte, error) {
+				order = append(order, name)
+				return next.Transmit(cmd)
+			})
+		}
+	}
+	tr := Wrap(TransceiverFunc(func([]byte) ([]byte, error) {
+		order = append(order, "card")
+		return []byte{0x90, 0x00}, nil
+	}), mw("a"), mw("b"))
+	if _, err := tr.Transmit(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(order, ","); got != "a,b,card" {
+		t.Errorf("order = %s, want a,b,card", got)
+	}
+}
+
+func TestMiddlewares(t *testing.T) {
+	transient := errors.New("transient")
+	calls := 0
+	card := TransceiverFunc(func(cmd []byte) ([]byte, error) {
+		calls++
+		if calls < 3 {
+			return nil, transient
+		}
+		return []byte{0x6A, 0x82}, nil
+	})
+
+	var logs bytes.Buffer
+	var timed int
+	tr := Wrap(card,
+		Logging(slog.New(slog.NewTextHandler(&logs, nil)), slog.LevelInfo),
+		Timing(func([]byte, time.Duration, error) { timed++ }),
+		StatusErrors(),
+		Retry(3, 0, func(err error) bool { return errors.Is(err, transient) }),
+	)
+	resp, err := tr.Transmit([]byte{0x00, 0xA4})
+	var se *StatusError
+	if !errors.As(err, &se) || se.SW1 != 0x6A || se.SW2 != 0x82 {
+		t.Errorf("Transmit() error = %v, want status 6A82", err)
+	}
+	if !bytes.Equal(resp, []byte{0x6A, 0x82}) {
+		t.Errorf("Transmit() = % X", resp)
+	}
+	if calls != 3 || timed != 1 {
+		t.Errorf("card calls = %d, timed = %d; want 3, 1", calls, timed)
+	}
+	if !strings.Contains(logs.String(), "cmd=00a4") {
+		t.Errorf("log = %q, want the command hex dump", logs.String())
+	}
+}
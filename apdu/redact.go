@@ -0,0 +1,69 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package apdu
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+)
+
+// Sensitive reports whether cmd or its response may carry secrets, such as
+// PINs, keys or authentication cryptograms: ISO 7816-4 VERIFY, CHANGE
+// REFERENCE DATA, RESET RETRY COUNTER and the authentication commands,
+// GlobalPlatform INITIALIZE UPDATE and PUT KEY, wrapped DESFire and NTAG 424
+// authentication and key changes, and the reader commands loading MIFARE
+// keys or sending NTAG PWD_AUTH.
+func Sensitive(cmd []byte) bool {
+	if len(cmd) < 2 {
+		return false
+	}
+	cla, ins := cmd[0], cmd[1]
+	switch cla {
+	case 0xFF:
+		// LOAD KEYS, or PWD_AUTH through the pass-through command.
+		return ins == 0x82 || (ins == 0x00 && len(cmd) > 5 && cmd[5] == 0x1B)
+	case 0x90:
+		switch ins {
+		case 0x0A, 0x1A, 0xAA, 0x71, 0x77, 0xAF, 0xC4, 0x54, 0x5C:
+			return true
+		}
+		return false
+	}
+	switch ins {
+	case 0x20, 0x24, 0x2C, 0x82, 0x86, 0x87, 0x88, 0x50, 0xD8:
+		return true
+	}
+	return false
+}
+
+// RedactedLogging is Logging which logs only the header and the status
+// words of exchanges for which sensitive reports true, never their data, so
+// secrets stay out of logs at any level. A nil sensitive uses Sensitive.
+func RedactedLogging(logger *slog.Logger, level slog.Level, sensitive func(cmd []byte) bool) Middleware {
+	if sensitive == nil {
+		sensitive = Sensitive
+	}
+	return func(next Transceiver) Transceiver {
+		return TransceiverFunc(func(cmd []byte) ([]byte, error) {
+			resp, err := next.Transmit(cmd)
+			if !sensitive(cmd) {
+				logExchange(logger, level, cmd, resp, err)
+				return resp, err
+			}
+			attrs := []slog.Attr{
+				slog.String("cmd", hex.EncodeToString(cmd[:min(len(cmd), 4)])),
+				slog.Bool("redacted", true),
+			}
+			if err != nil {
+				attrs = append(attrs, slog.Any("err", err))
+			} else if len(resp) >= 2 {
+				attrs = append(attrs, slog.String("sw", hex.EncodeToString(resp[len(resp)-2:])))
+			}
+			logger.LogAttrs(context.Background(), level, "apdu", attrs...)
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package apdu
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedactedLogging(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		redacted bool
+	}{
+		{"0020008008313233343536FFFF", true},
+		{"8050000008DEADBEEFDEADBEEF00", true},
+		{"90AF000010DEADBEEFDEADBEEFDEADBEEFDEADBEEF00", true},
+		{"FF82000006DEADBEEFFFFF", true},
+		{"FF000000051BDEADBEEF", true},
+		{"00A4040007D276000085010100", false},
+		{"FF00000002300400", false},
+		{"90600000", false},
+	}
+	for _, tt := range tests {
+		var logs bytes.Buffer
+		card := TransceiverFunc(func([]byte) ([]byte, error) { return []byte{0xDE, 0xAD, 0xBE, 0xEF, 0x90, 0x00}, nil })
+		tr := Wrap(card, RedactedLogging(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})), slog.LevelDebug, nil))
+		cmd, _ := hex.DecodeString(tt.cmd)
+		if _, err := tr.Transmit(cmd); err != nil {
+			t.Fatal(err)
+		}
+		if Sensitive(cmd) != tt.redacted {
+			t.Errorf("Sensitive(%s) = %v", tt.cmd, !tt.redacted)
+		}
+		leaked := strings.Contains(logs.String(), "deadbeef")
+		if leaked == tt.redacted || !strings.Contains(logs.String(), "cmd="+strings.ToLower(tt.cmd[:8])) {
+			t.Errorf("%s: log = %s", tt.cmd, logs.String())
+		}
+	}
+}
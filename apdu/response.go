@@ -0,0 +1,99 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package apdu
+
+import "fmt"
+
+// maxGetResponse bounds the GET RESPONSE commands sent for one command, so
+// a card answering 61xx forever cannot stall the exchange.
+const maxGetResponse = 256
+
+// GetResponse handles the T=0 status words announcing response data: on
+// 61 XX it collects the XX bytes available with GET RESPONSE until the card
+// answers other status words, and on 6C XX it retransmits the command once
+// with Le set to XX. GSM SIMs answer the commands of class A0 with 9F XX
+// instead of 61 XX, handled alike with GET RESPONSE in class A0. The caller
+// sees a single response with the collected data and the final status
+// words.
+func GetResponse() Middleware {
+	return func(next Transceiver) Transceiver {
+		return TransceiverFunc(func(cmd []byte) ([]byte, error) {
+			resp, err := next.Transmit(cmd)
+			if err != nil || len(resp) < 2 {
+				return resp, err
+			}
+			if len(resp) == 2 && resp[0] == 0x6C {
+				if retry := withLe(cmd, resp[1]); retry != nil {
+					if resp, err = next.Transmit(retry); err != nil {
+						return resp, err
+					}
+				}
+			}
+			var data []byte
+			for i := 0; moreData(cmd, resp); i++ {
+				if i == maxGetResponse {
+					return nil, fmt.Errorf("no end of response after %d GET RESPONSE commands", i)
+				}
+				data = append(data, resp[:len(resp)-2]...)
+				if resp, err = next.Transmit([]byte{getResponseCla(cmd), 0xC0, 0x00, 0x00, resp[len(resp)-1]}); err != nil {
+					return nil, err
+				}
+			}
+			if data == nil {
+				return resp, nil
+			}
+			return append(data, resp...), nil
+		})
+	}
+}
+
+// moreData reports whether resp, the response to cmd, announces data to
+// fetch with GET RESPONSE: 61 XX, or 9F XX for GSM class commands.
+func moreData(cmd, resp []byte) bool {
+	if len(resp) < 2 {
+		return false
+	}
+	sw1 := resp[len(resp)-2]
+	return sw1 == 0x61 || sw1 == 0x9F && len(cmd) > 0 && cmd[0] == 0xA0
+}
+
+// getResponseCla returns the class byte of GET RESPONSE for cmd, keeping its
+// logical channel, or the GSM class A0.
+func getResponseCla(cmd []byte) byte {
+	switch {
+	case len(cmd) == 0:
+		return 0x00
+	case cmd[0] == 0xA0:
+		return 0xA0
+	case cmd[0]&0xC0 == 0x00:
+		return cmd[0] & 0x03
+	case cmd[0]&0xC0 == 0x40:
+		return cmd[0] & 0x4F
+	default:
+		return 0x00
+	}
+}
+
+// withLe returns the short command APDU cmd with Le set to le, or nil when
+// cmd is not a short command APDU.
+func withLe(cmd []byte, le byte) []byte {
+	if len(cmd) < 4 {
+		return nil
+	}
+	out := make([]byte, 0, len(cmd)+1)
+	switch n := len(cmd); {
+	case n == 4:
+		return append(append(out, cmd...), le)
+	case cmd[4] == 0 && n > 5:
+		return nil // Extended length.
+	case n == 5, n == 6+int(cmd[4]):
+		out = append(out, cmd[:n-1]...)
+		return append(out, le)
+	case n == 5+int(cmd[4]):
+		return append(append(out, cmd...), le)
+	default:
+		return nil
+	}
+}
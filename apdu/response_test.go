@@ -0,0 +1,82 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package apdu
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func TestGetResponse(t *testing.T) {
+	tests := []struct {
+		name  string
+		cmd   string
+		cards map[string]string // Command to response.
+		want  string
+		sent  int
+	}{
+		{"plain", "00B0000000", map[string]string{"00B0000000": "01029000"}, "01029000", 1},
+		{"61xx", "00A4040000", map[string]string{
+			"00A4040000": "6104",
+			"00C0000004": "AABBCCDD6102",
+			"00C0000002": "EEFF9000",
+		}, "AABBCCDDEEFF9000", 3},
+		{"61xx on channel 1", "01CA004F00", map[string]string{
+			"01CA004F00": "6101",
+			"01C0000001": "4F9000",
+		}, "4F9000", 2},
+		{"6Cxx", "00B0000000", map[string]string{
+			"00B0000000": "6C03",
+			"00B0000003": "0102036282",
+		}, "0102036282", 2},
+		{"6Cxx case 1", "00CA0101", map[string]string{
+			"00CA0101":   "6C01",
+			"00CA010101": "FF9000",
+		}, "FF9000", 2},
+		{"6Cxx then 61xx", "00B00000020102", map[string]string{
+			"00B00000020102":   "6C10",
+			"00B0000002010210": "6101",
+			"00C0000001":       "109000",
+		}, "109000", 3},
+		{"61xx with data", "00CB3FFF00", map[string]string{
+			"00CB3FFF00": "01026101",
+			"00C0000001": "039000",
+		}, "0102039000", 2},
+		{"9Fxx of a SIM", "A0A40000023F00", map[string]string{
+			"A0A40000023F00": "9F16",
+			"A0C0000016":     "00000000000000000000000000000000000000000000" + "9000",
+		}, "00000000000000000000000000000000000000000000" + "9000", 2},
+		{"6Cxx of a SIM", "A0B0000000", map[string]string{
+			"A0B0000000": "6C02",
+			"A0B0000002": "12349000",
+		}, "12349000", 2},
+		{"9Fxx in ISO class", "00A4040000", map[string]string{"00A4040000": "9F10"}, "9F10", 1},
+		{"error", "00A4040000", map[string]string{"00A4040000": "6A82"}, "6A82", 1},
+	}
+	for _, tt := range tests {
+		sent := 0
+		card := TransceiverFunc(func(cmd []byte) ([]byte, error) {
+			sent++
+			resp, ok := tt.cards[fmt.Sprintf("%X", cmd)]
+			if !ok {
+				return []byte{0x6D, 0x00}, nil
+			}
+			return hex.DecodeString(resp)
+		})
+		cmd, _ := hex.DecodeString(tt.cmd)
+		got, err := Wrap(card, GetResponse()).Transmit(cmd)
+		want, _ := hex.DecodeString(tt.want)
+		if err != nil || !bytes.Equal(got, want) || sent != tt.sent {
+			t.Errorf("%s: Transmit() = %X, %v after %d commands, want %X after %d", tt.name, got, err, sent, want, tt.sent)
+		}
+	}
+
+	endless := TransceiverFunc(func([]byte) ([]byte, error) { return []byte{0x61, 0x01}, nil })
+	if _, err := Wrap(endless, GetResponse()).Transmit([]byte{0x00, 0xB0, 0x00, 0x00, 0x01}); err == nil {
+		t.Error("Transmit() succeeded for a card answering 61xx forever")
+	}
+}
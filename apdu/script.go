@@ -0,0 +1,149 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package apdu
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Step is a command of a Script.
+type Step struct {
+	// Name identifies the step in results and errors.
+	Name string
+	// Command is the command APDU sent.
+	Command []byte
+	// Build, when set, builds the command APDU from the values extracted by
+	// the previous steps instead of Command, e.g. to answer a challenge.
+	Build func(values map[string][]byte) ([]byte, error)
+	// Expect lists the accepted status words as four hex digits, where X
+	// matches any digit, e.g. "9000" or "61XX". Empty accepts 9000 only.
+	Expect []string
+	// Extract lists the values taken from the response data.
+	Extract []Extraction
+}
+
+// Extraction takes Length bytes at Offset of the response data as the value
+// Name. A Length of 0 takes the data up to its end.
+type Extraction struct {
+	Name           string
+	Offset, Length int
+}
+
+// Script is an ordered list of commands run as a single exchange, such as a
+// personalization flow. Run it within a transaction, e.g. with
+// pcsc.Card.RunScript, so other applications cannot interleave commands.
+type Script struct {
+	Steps []Step
+}
+
+// Add appends a step sending cmd and accepting the status words expect.
+func (s *Script) Add(name string, cmd []byte, expect ...string) *Script {
+	s.Steps = append(s.Steps, Step{Name: name, Command: cmd, Expect: expect})
+	return s
+}
+
+// StepResult is the outcome of a Step.
+type StepResult struct {
+	Name     string
+	Command  []byte
+	Data     []byte // Response data, without the status words.
+	SW1, SW2 byte
+}
+
+// ScriptResult is the outcome of a Script.
+type ScriptResult struct {
+	Steps  []StepResult
+	Values map[string][]byte
+}
+
+// ScriptError reports the step at which a script stopped.
+type ScriptError struct {
+	Step int
+	Name string
+	Err  error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("script step %d (%s): %v", e.Step, e.Name, e.Err)
+}
+
+func (e *ScriptError) Unwrap() error { return e.Err }
+
+// Run sends the steps of s to tr in order and stops at the first step
+// failing or answering status words it does not expect, with a *ScriptError
+// wrapping a *StatusError for unexpected status words. The result holds the
+// steps run so far.
+func (s *Script) Run(tr Transceiver) (*ScriptResult, error) {
+	res := &ScriptResult{Values: make(map[string][]byte)}
+	for i, st := range s.Steps {
+		fail := func(err error) (*ScriptResult, error) {
+			return res, &ScriptError{Step: i, Name: st.Name, Err: err}
+		}
+		cmd := st.Command
+		if st.Build != nil {
+			var err error
+			if cmd, err = st.Build(res.Values); err != nil {
+				return fail(err)
+			}
+		}
+		resp, err := tr.Transmit(cmd)
+		if err != nil {
+			return fail(err)
+		}
+		if len(resp) < 2 {
+			return fail(errors.New("response too short to contain status words"))
+		}
+		n := len(resp) - 2
+		r := StepResult{Name: st.Name, Command: cmd, Data: resp[:n:n], SW1: resp[n], SW2: resp[n+1]}
+		res.Steps = append(res.Steps, r)
+		ok, err := expected(st.Expect, r.SW1, r.SW2)
+		if err != nil {
+			return fail(err)
+		}
+		if !ok {
+			return fail(&StatusError{SW1: r.SW1, SW2: r.SW2})
+		}
+		for _, x := range st.Extract {
+			end := len(r.Data)
+			if x.Length > 0 {
+				end = x.Offset + x.Length
+			}
+			if x.Offset < 0 || end > len(r.Data) || x.Offset > end {
+				return fail(fmt.Errorf("extract %s: %d bytes of response data", x.Name, len(r.Data)))
+			}
+			res.Values[x.Name] = r.Data[x.Offset:end:end]
+		}
+	}
+	return res, nil
+}
+
+// expected reports whether the status words match one of the patterns.
+func expected(patterns []string, sw1, sw2 byte) (bool, error) {
+	if len(patterns) == 0 {
+		return sw1 == 0x90 && sw2 == 0x00, nil
+	}
+	sw := fmt.Sprintf("%02X%02X", sw1, sw2)
+	for _, p := range patterns {
+		if len(p) != 4 {
+			return false, fmt.Errorf("invalid status word pattern %q", p)
+		}
+		p = strings.ToUpper(p)
+		match := true
+		for i := 0; i < 4; i++ {
+			if c := p[i]; c != 'X' && c != sw[i] {
+				if !strings.ContainsRune("0123456789ABCDEF", rune(c)) {
+					return false, fmt.Errorf("invalid status word pattern %q", p)
+				}
+				match = false
+			}
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
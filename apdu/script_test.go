@@ -0,0 +1,79 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package apdu
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestScript(t *testing.T) {
+	var sent [][]byte
+	card := TransceiverFunc(func(cmd []byte) ([]byte, error) {
+		sent = append(sent, cmd)
+		switch cmd[1] {
+		case 0xA4:
+			return []byte{0x90, 0x00}, nil
+		case 0x84:
+			return []byte{0x11, 0x22, 0x33, 0x44, 0x90, 0x00}, nil
+		case 0x82:
+			return []byte{0x63, 0xC2}, nil
+		}
+		return []byte{0x6D, 0x00}, nil
+	})
+
+	s := new(Script).Add("select", []byte{0x00, 0xA4, 0x04, 0x00, 0x00})
+	s.Steps = append(s.Steps,
+		Step{
+			Name:    "challenge",
+			Command: []byte{0x00, 0x84, 0x00, 0x00, 0x04},
+			Extract: []Extraction{{Name: "rnd", Offset: 0, Length: 4}, {Name: "tail", Offset: 2}},
+		},
+		Step{
+			Name: "authenticate",
+			Build: func(v map[string][]byte) ([]byte, error) {
+				return append([]byte{0x00, 0x82, 0x00, 0x00, 0x04}, v["rnd"]...), nil
+			},
+			Expect: []string{"9000", "63cx"},
+		},
+	)
+	res, err := s.Run(card)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(res.Steps) != 3 || res.Steps[2].SW1 != 0x63 || res.Steps[2].SW2 != 0xC2 {
+		t.Errorf("steps = %+v", res.Steps)
+	}
+	if !bytes.Equal(res.Values["rnd"], []byte{0x11, 0x22, 0x33, 0x44}) || !bytes.Equal(res.Values["tail"], []byte{0x33, 0x44}) {
+		t.Errorf("values = %X", res.Values)
+	}
+	if !bytes.Equal(sent[2], []byte{0x00, 0x82, 0x00, 0x00, 0x04, 0x11, 0x22, 0x33, 0x44}) {
+		t.Errorf("built command = %X", sent[2])
+	}
+
+	tests := []struct {
+		name string
+		step Step
+		sw   *StatusError
+	}{
+		{"unexpected sw", Step{Name: "read", Command: []byte{0x00, 0xB0, 0x00, 0x00, 0x00}}, &StatusError{SW1: 0x6D}},
+		{"bad pattern", Step{Name: "select", Command: []byte{0x00, 0xA4}, Expect: []string{"90"}}, nil},
+		{"short data", Step{Name: "select", Command: []byte{0x00, 0xA4}, Extract: []Extraction{{Name: "x", Length: 1}}}, nil},
+	}
+	for _, tt := range tests {
+		s := &Script{Steps: []Step{{Name: "select", Command: []byte{0x00, 0xA4}}, tt.step}}
+		res, err := s.Run(card)
+		var se *ScriptError
+		if !errors.As(err, &se) || se.Step != 1 || len(res.Steps) < 1 {
+			t.Errorf("%s: Run() error = %v", tt.name, err)
+			continue
+		}
+		var sw *StatusError
+		if tt.sw != nil && (!errors.As(err, &sw) || *sw != *tt.sw) {
+			t.Errorf("%s: Run() error = %v, want status %v", tt.name, err, tt.sw)
+		}
+	}
+}
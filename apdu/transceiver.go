@@ -0,0 +1,17 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package apdu
+
+// Transceiver sends a command APDU to a card and returns the response APDU,
+// including the status words. pcsc.Card and cardreader.Reader implement it.
+type Transceiver interface {
+	Transmit(cmd []byte) ([]byte, error)
+}
+
+// TransceiverFunc adapts a function to the Transceiver interface.
+type TransceiverFunc func(cmd []byte) ([]byte, error)
+
+// Transmit calls f(cmd).
+func (f TransceiverFunc) Transmit(cmd []byte) ([]byte, error) { return f(cmd) }
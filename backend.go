@@ -0,0 +1,234 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// WithBackend makes the SDK reach readers and cards through b instead of
+// the PC/SC resource manager.
+func WithBackend(b transport.Backend) Option {
+	return func(sdk *SDK) {
+		if b != nil {
+			sdk.backend = b
+		}
+	}
+}
+
+// WithConnectRetry makes the PC/SC backend retry connecting to a card in a
+// reader held by another application following r. When the reader stays
+// busy, or without retry, the tap is reported with a *ReaderBusyError.
+func WithConnectRetry(r pcsc.BusyRetry) Option {
+	return func(sdk *SDK) {
+		sdk.connectRetry = r
+	}
+}
+
+// ReaderBusyError reports a card which could not be connected because
+// another application holds its reader in exclusive mode. It wraps
+// pcsc.ErrSharingViolation.
+type ReaderBusyError struct {
+	Reader string
+	Err    error
+}
+
+func (e *ReaderBusyError) Error() string {
+	return fmt.Sprintf("reader %s is held by another application: %v", e.Reader, e.Err)
+}
+
+func (e *ReaderBusyError) Unwrap() error { return e.Err }
+
+// readerStater is implemented by backends reporting the live state of
+// their readers.
+type readerStater interface {
+	readerStates(readers []cardreader.Reader) ([]ReaderInfo, error)
+}
+
+// errWaitElapsed ends a single WaitCard of the PC/SC backend.
+var errWaitElapsed = errors.New("wait elapsed")
+
+// statesPool holds the reader state buffers of WaitCard, so status polls do
+// not allocate them.
+var statesPool = sync.Pool{New: func() any { return new([]pcsc.ReaderState) }}
+
+// pcscBackend is the default backend using the PC/SC resource manager. Its
+// context is established on first use and released by Close. It remembers
+// the reader states it has seen, so WaitCard reports every card once.
+type pcscBackend struct {
+	mu    sync.Mutex
+	hctx  *pcsc.Context
+	known map[string]pcsc.State
+
+	// onChange, when set, is called for every reader state change seen.
+	onChange func(reader string, from, to pcsc.State)
+	// retry is how connections to busy readers are retried.
+	retry pcsc.BusyRetry
+}
+
+func (b *pcscBackend) context() (*pcsc.Context, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.hctx == nil {
+		hctx, err := pcsc.EstablishContext()
+		if err != nil {
+			return nil, fmt.Errorf("establish context: %w", err)
+		}
+		b.hctx = hctx
+	}
+	return b.hctx, nil
+}
+
+// Close releases the context, failing pending and later calls on it and on
+// the cards connected with it. A later call establishes a new context.
+func (b *pcscBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.hctx == nil {
+		return nil
+	}
+	err := b.hctx.Release()
+	b.hctx = nil
+	return err
+}
+
+func (*pcscBackend) ListReaders() ([]cardreader.Reader, error) { return cardreader.ListReaders() }
+
+func (b *pcscBackend) WaitCard(ctx context.Context, readers []cardreader.Reader, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	hctx, err := b.context()
+	if err != nil {
+		return nil, cardreader.Reader{}, err
+	}
+
+	buf := statesPool.Get().(*[]pcsc.ReaderState)
+	defer func() {
+		clear(*buf)
+		statesPool.Put(buf)
+	}()
+	b.mu.Lock()
+	states := (*buf)[:0]
+	for _, r := range readers {
+		states = append(states, pcsc.ReaderState{Reader: r.Name, CurrentState: b.known[r.Name]})
+	}
+	*buf = states
+	b.mu.Unlock()
+	// Connections are retried past the wait timeout.
+	parent := ctx
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, errWaitElapsed)
+	defer cancel()
+	for {
+		if err := hctx.WaitStatusChange(ctx, states, timeout); err != nil {
+			b.remember(states, -1)
+			if context.Cause(ctx) == errWaitElapsed {
+				return nil, cardreader.Reader{}, nil
+			}
+			return nil, cardreader.Reader{}, err
+		}
+		if b.onChange != nil {
+			for _, st := range states {
+				if from, to := st.CurrentState.Flags(), st.EventState.Flags()&^pcsc.StateChanged; from != to {
+					b.onChange(st.Reader, from, to)
+				}
+			}
+		}
+		for i, st := range states {
+			if !cardInserted(st.CurrentState, st.EventState) {
+				continue
+			}
+			b.remember(states, i)
+			card, err := pcsc.ConnectToCardRetry(parent, st.Reader, b.retry)
+			if errors.Is(err, pcsc.ErrSharingViolation) {
+				return nil, cardreader.Reader{}, &ReaderBusyError{Reader: st.Reader, Err: err}
+			}
+			if err != nil {
+				return nil, cardreader.Reader{}, &ReaderError{Reader: st.Reader, Err: fmt.Errorf("connect: %w", err)}
+			}
+			return card, readers[i], nil
+		}
+		for i := range states {
+			if states[i].EventState&(pcsc.StateUnknown|pcsc.StateUnavailable) != 0 {
+				// A reader went away, refresh the reader list.
+				b.remember(states, -1)
+				return nil, cardreader.Reader{}, nil
+			}
+			states[i].CurrentState = states[i].EventState &^ pcsc.StateChanged
+		}
+	}
+}
+
+// cardInserted reports whether the reader state cur shows a responsive card
+// which the state prev, last seen by the backend, did not: either no card
+// was present or cards were inserted and removed meanwhile.
+func cardInserted(prev, cur pcsc.State) bool {
+	if cur&pcsc.StatePresent == 0 || cur&pcsc.StateMute != 0 {
+		return false
+	}
+	return prev&pcsc.StatePresent == 0 || prev.EventCount() != cur.EventCount()
+}
+
+// remember stores the reader states seen by WaitCard, so that a card left
+// in a reader is not reported again. Readers showing a newly inserted card
+// other than the reported one keep their previous state, so that card is
+// reported by the next wait.
+func (b *pcscBackend) remember(states []pcsc.ReaderState, reported int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.known == nil {
+		b.known = make(map[string]pcsc.State)
+	}
+	for i, st := range states {
+		seen := st.CurrentState
+		if i == reported || (st.EventState != 0 && !cardInserted(st.CurrentState, st.EventState)) {
+			seen = st.EventState &^ pcsc.StateChanged
+		}
+		b.known[st.Reader] = seen
+	}
+}
+
+// rearm forgets the states seen of the readers named, all when none is
+// named, so a card left in them is reported again.
+func (b *pcscBackend) rearm(readers ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(readers) == 0 {
+		clear(b.known)
+	}
+	for _, name := range readers {
+		delete(b.known, name)
+	}
+}
+
+func (b *pcscBackend) readerStates(readers []cardreader.Reader) ([]ReaderInfo, error) {
+	hctx, err := b.context()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]pcsc.ReaderState, len(readers))
+	for i, r := range readers {
+		states[i] = pcsc.ReaderState{Reader: r.Name, CurrentState: pcsc.StateUnaware}
+	}
+	if err := hctx.GetStatusChange(0, states); err != nil && !errors.Is(err, pcsc.ErrTimeout) {
+		return nil, fmt.Errorf("get status change: %w", err)
+	}
+	infos := make([]ReaderInfo, len(states))
+	for i, st := range states {
+		infos[i] = ReaderInfo{
+			Name:       st.Reader,
+			State:      st.EventState.Flags() &^ pcsc.StateChanged,
+			ATR:        st.ATR,
+			EventCount: st.EventState.EventCount(),
+		}
+	}
+	return infos, nil
+}
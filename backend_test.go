@@ -0,0 +1,162 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// memCard is a card of a fakeBackend answering GET DATA with its UID.
+type memCard struct {
+	atr           []byte
+	disconnected  bool
+	disconnectErr error
+}
+
+func (c *memCard) ATR() []byte { return c.atr }
+
+func (c *memCard) Transmit(cmd []byte) ([]byte, error) {
+	if bytes.Equal(cmd, []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}) {
+		return []byte{0x04, 0xA1, 0xB2, 0xC3, 0x90, 0x00}, nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+func (c *memCard) Disconnect() error {
+	c.disconnected = true
+	return c.disconnectErr
+}
+
+// fakeBackend presents card on its reader after the given number of empty
+// waits.
+type fakeBackend struct {
+	reader cardreader.Reader
+	card   *memCard
+	empty  int
+	closed atomic.Bool
+}
+
+func (b *fakeBackend) Close() error {
+	b.closed.Store(true)
+	return nil
+}
+
+func (b *fakeBackend) ListReaders() ([]cardreader.Reader, error) {
+	return []cardreader.Reader{b.reader}, nil
+}
+
+func (b *fakeBackend) WaitCard(ctx context.Context, readers []cardreader.Reader, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, cardreader.Reader{}, err
+	}
+	if b.empty > 0 {
+		b.empty--
+		return nil, cardreader.Reader{}, nil
+	}
+	return b.card, readers[0], nil
+}
+
+func TestLastCardOfListedReader(t *testing.T) {
+	b := &fakeBackend{reader: cardreader.Reader{Name: "virtual"}, card: &memCard{}}
+	sdk := New(WithBackend(b))
+	ev, err := sdk.WaitForCard(context.Background(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := sdk.LookupReader("virtual")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last, ok := r.LastCard(); !ok || !bytes.Equal(last.UID, ev.UID) {
+		t.Errorf("LastCard() = %+v, %v", last, ok)
+	}
+	if err := r.Deselect(); err != nil {
+		t.Errorf("Deselect() error = %v", err)
+	}
+}
+
+func TestWithBackend(t *testing.T) {
+	b := &fakeBackend{
+		reader: *cardreader.NewReader("virtual"),
+		card:   &memCard{atr: []byte{0x3B, 0x81, 0x80, 0x01, 0x80, 0x80}},
+		empty:  2,
+	}
+	var handled transport.Card
+	sdk := New(WithBackend(b), WithCardHandler(func(_ context.Context, _ cardreader.Event, card transport.Card) error {
+		handled = card
+		return nil
+	}))
+
+	ev, err := sdk.WaitForCard(context.Background(), time.Second)
+	if err != nil {
+		t.Fatalf("WaitForCard() error = %v", err)
+	}
+	if ev.Reader != "virtual" || !bytes.Equal(ev.UID, []byte{0x04, 0xA1, 0xB2, 0xC3}) {
+		t.Errorf("event = %+v", ev)
+	}
+	if handled != b.card || !b.card.disconnected {
+		t.Errorf("handled %v, disconnected %v", handled, b.card.disconnected)
+	}
+	if last, ok := b.reader.LastCard(); !ok || !bytes.Equal(last.UID, ev.UID) {
+		t.Errorf("LastCard() = %+v, %v", last, ok)
+	}
+
+	infos, err := sdk.Readers()
+	if err != nil || len(infos) != 1 || infos[0].Name != "virtual" {
+		t.Errorf("Readers() = %+v, %v", infos, err)
+	}
+}
+
+// busyBackend reports its reader as held by another application busy times
+// before presenting its card.
+type busyBackend struct {
+	fakeBackend
+	busy int
+}
+
+func (b *busyBackend) WaitCard(ctx context.Context, readers []cardreader.Reader, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	if b.busy > 0 {
+		b.busy--
+		return nil, cardreader.Reader{}, &ReaderBusyError{Reader: b.reader.Name, Err: pcsc.NewError("SCardConnect", pcsc.SCardESharingViolation)}
+	}
+	return b.fakeBackend.WaitCard(ctx, readers, timeout)
+}
+
+func TestReaderBusy(t *testing.T) {
+	retry := pcsc.BusyRetry{MaxWait: time.Second, Backoff: 2}
+	if b := New(WithConnectRetry(retry)).backend.(*pcscBackend); b.retry != retry {
+		t.Errorf("pcsc backend retry = %+v, want %+v", b.retry, retry)
+	}
+
+	b := &busyBackend{fakeBackend: fakeBackend{reader: cardreader.Reader{Name: "virtual"}, card: &memCard{}}, busy: 2}
+	_, err := New(WithBackend(b)).WaitForCard(context.Background(), time.Second)
+	var busy *ReaderBusyError
+	if !errors.As(err, &busy) || busy.Reader != "virtual" || !errors.Is(err, pcsc.ErrSharingViolation) {
+		t.Fatalf("WaitForCard() error = %v, want *ReaderBusyError", err)
+	}
+
+	var calls atomic.Int32
+	sdk := New(WithBackend(b), WithCardHandler(func(context.Context, cardreader.Event, transport.Card) error {
+		calls.Add(1)
+		return nil
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := sdk.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() error = %v", err)
+	}
+	if calls.Load() == 0 {
+		t.Error("Run() stopped handling cards after a busy reader")
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package calypso reads Calypso public transport cards: it selects the
+// transit application, reads the records of its environment, contracts,
+// event log and counters, and decodes them along the EN 1545 structures of
+// the Intercode profile, as needed by balance-check kiosks. Reading is done
+// without a secure access module, so no session is opened and nothing is
+// written to the card.
+package calypso
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// AIDs are the names of the transit applications tried by Open.
+var AIDs = [][]byte{
+	[]byte("1TIC.ICA"),
+	{0xA0, 0x00, 0x00, 0x04, 0x04, 0x01, 0x25, 0x09, 0x01, 0x01},
+}
+
+// ErrNotCalypso is returned when a card has none of the transit
+// applications looked for.
+var ErrNotCalypso = errors.New("calypso: no transit application")
+
+// Short identifiers of the elementary files of a transit application.
+const (
+	SFIEnvironment   = 0x07
+	SFIEventLog      = 0x08
+	SFIContracts     = 0x09
+	SFICounters      = 0x19
+	SFISpecialEvents = 0x1D
+	SFIContractList  = 0x1E
+)
+
+// Classes of Calypso commands: revision 1 cards only accept the
+// proprietary class.
+const (
+	claISO  = 0x00
+	claRev1 = 0x94
+)
+
+// recordSize is the size of the records of a transit application.
+const recordSize = 29
+
+// StartupInfo is the startup information of a Calypso application,
+// returned when it is selected.
+type StartupInfo struct {
+	BufferSizeIndicator byte
+	Platform            byte
+	ApplicationType     byte
+	ApplicationSubtype  byte
+	SoftwareIssuer      byte
+	SoftwareVersion     byte
+	SoftwareRevision    byte
+}
+
+// Card is a selected Calypso transit application.
+type Card struct {
+	tr  apdu.Transceiver
+	cla byte
+	// AID is the name of the selected application.
+	AID []byte
+	// Serial is the application serial number, nil when the card did not
+	// return it.
+	Serial []byte
+	// Startup is the startup information, zero when the card did not
+	// return it.
+	Startup StartupInfo
+}
+
+// Open selects the first of aids, AIDs when none are given, present on the
+// card behind tr. Revision 1 cards rejecting the ISO class are addressed
+// with the proprietary one.
+func Open(tr apdu.Transceiver, aids ...[]byte) (*Card, error) {
+	if len(aids) == 0 {
+		aids = AIDs
+	}
+	c := &Card{tr: apdu.Wrap(tr, apdu.GetResponse()), cla: claISO}
+	var errs []error
+	for _, aid := range aids {
+		fci, err := c.selectName(aid)
+		var se *apdu.StatusError
+		if errors.As(err, &se) && se.SW1 == 0x6E && c.cla == claISO {
+			c.cla = claRev1
+			fci, err = c.selectName(aid)
+		}
+		if errors.As(err, &se) && se.SW1 == 0x6A {
+			continue // File or application not found.
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("calypso: select %X: %w", aid, err))
+			continue
+		}
+		c.AID = aid
+		if name, ok := iso7816.FindTLV(fci, 0x84); ok {
+			c.AID = name
+		}
+		c.Serial, _ = iso7816.FindTLV(fci, 0xC7)
+		if info, ok := iso7816.FindTLV(fci, 0x53); ok && len(info) >= 7 {
+			c.Startup = StartupInfo{info[0], info[1], info[2], info[3], info[4], info[5], info[6]}
+		}
+		return c, nil
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return nil, ErrNotCalypso
+}
+
+func (c *Card) selectName(name []byte) ([]byte, error) {
+	return c.transmit(&iso7816.CommandAPDU{Cla: c.cla, Ins: iso7816.INSSelect, P1: iso7816.SelectByName, Data: name, Ne: 256})
+}
+
+// Transceiver returns the transceiver of the card.
+func (c *Card) Transceiver() apdu.Transceiver { return c.tr }
+
+// ReadRecord reads record rec of the file with short identifier sfi.
+func (c *Card) ReadRecord(sfi byte, rec int) ([]byte, error) {
+	if sfi == 0 || sfi > 30 {
+		return nil, fmt.Errorf("calypso: short file identifier %d out of range", sfi)
+	}
+	if rec < 1 || rec > 254 {
+		return nil, fmt.Errorf("calypso: record number %d out of range", rec)
+	}
+	data, err := c.transmit(&iso7816.CommandAPDU{Cla: c.cla, Ins: iso7816.INSReadRecord, P1: byte(rec), P2: sfi<<3 | 0x04, Ne: recordSize})
+	if err != nil {
+		return nil, fmt.Errorf("calypso: read record %d of sfi %02X: %w", rec, sfi, err)
+	}
+	return data, nil
+}
+
+// ReadRecords reads the records of the file with short identifier sfi
+// until the card reports no further record. Cards answer for a missing
+// file the same way, so that it reads as empty.
+func (c *Card) ReadRecords(sfi byte) ([][]byte, error) {
+	var recs [][]byte
+	for rec := 1; rec <= 254; rec++ {
+		data, err := c.ReadRecord(sfi, rec)
+		var se *apdu.StatusError
+		if errors.As(err, &se) && se.SW1 == 0x6A && (se.SW2 == 0x83 || se.SW2 == 0x82) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, data)
+	}
+	return recs, nil
+}
+
+// Environment reads and decodes the environment record.
+func (c *Card) Environment() (Values, error) {
+	data, err := c.ReadRecord(SFIEnvironment, 1)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data, IntercodeEnvironment...)
+}
+
+// Contracts reads and decodes the contract records, skipping empty ones.
+func (c *Card) Contracts() ([]Values, error) {
+	return c.decodeRecords(SFIContracts, IntercodeContract)
+}
+
+// Events reads and decodes the event log, most recent event first,
+// skipping empty records.
+func (c *Card) Events() ([]Values, error) {
+	return c.decodeRecords(SFIEventLog, IntercodeEvent)
+}
+
+func (c *Card) decodeRecords(sfi byte, elems []Element) ([]Values, error) {
+	recs, err := c.ReadRecords(sfi)
+	if err != nil {
+		return nil, err
+	}
+	var out []Values
+	for i, rec := range recs {
+		if isEmpty(rec) {
+			continue
+		}
+		v, err := decode(rec, elems)
+		if err != nil {
+			return nil, fmt.Errorf("calypso: record %d of sfi %02X: %w", i+1, sfi, err)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// Counters reads the counters of the counter file, such as remaining trips
+// or a stored value, in the order of the contracts they belong to.
+func (c *Card) Counters() ([]int, error) {
+	data, err := c.ReadRecord(SFICounters, 1)
+	if err != nil {
+		return nil, err
+	}
+	counters := make([]int, 0, len(data)/3)
+	for i := 0; i+3 <= len(data); i += 3 {
+		counters = append(counters, int(data[i])<<16|int(data[i+1])<<8|int(data[i+2]))
+	}
+	return counters, nil
+}
+
+// isEmpty reports whether a record was never written.
+func isEmpty(rec []byte) bool {
+	return len(bytes.Trim(rec, "\x00")) == 0
+}
+
+// transmit sends cmd and returns the response data.
+func (c *Card) transmit(cmd *iso7816.CommandAPDU) ([]byte, error) {
+	raw, err := cmd.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.tr.Transmit(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		return nil, err
+	}
+	return resp[:len(resp)-2], nil
+}
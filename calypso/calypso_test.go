@@ -0,0 +1,147 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package calypso
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// bits encodes fields of the given widths, most significant bit first, into
+// a record of recordSize bytes.
+func bits(fields ...[2]uint64) []byte {
+	rec := make([]byte, recordSize)
+	pos := 0
+	for _, f := range fields {
+		for i := int(f[1]) - 1; i >= 0; i-- {
+			if f[0]>>i&1 == 1 {
+				rec[pos/8] |= 0x80 >> (pos % 8)
+			}
+			pos++
+		}
+	}
+	return rec
+}
+
+var (
+	// Environment of version 1 on network 250901 with an end date.
+	environment = bits([2]uint64{1, 6}, [2]uint64{0b0000101, 7}, [2]uint64{0x250901, 24}, [2]uint64{10956, 14})
+	// Contract of tariff 0x1234, valid from day 9862 to 9892.
+	contract = bits([2]uint64{1<<2 | 1<<13, 20}, [2]uint64{0x1234, 16}, [2]uint64{0b101, 9}, [2]uint64{9862, 14}, [2]uint64{9892, 14})
+	// Entry at station 0x0421 on day 9870 at 08:30.
+	event  = bits([2]uint64{9870, 14}, [2]uint64{510, 11}, [2]uint64{1<<2 | 1<<8, 28}, [2]uint64{0x11, 8}, [2]uint64{0x0421, 16})
+	serial = []byte{0x00, 0x00, 0x00, 0x00, 0x12, 0x34, 0x56, 0x78}
+)
+
+// fakeCard emulates a Calypso card with a transit application holding an
+// environment, two contracts of which one empty, an event and counters.
+type fakeCard struct {
+	rev1 bool // reject the ISO class
+}
+
+func (f *fakeCard) Transmit(cmd []byte) ([]byte, error) {
+	ok := []byte{0x90, 0x00}
+	if f.rev1 && cmd[0] != claRev1 {
+		return []byte{0x6E, 0x00}, nil
+	}
+	switch cmd[1] {
+	case 0xA4:
+		if !bytes.Equal(cmd[5:5+int(cmd[4])], []byte("1TIC.ICA")) {
+			return []byte{0x6A, 0x82}, nil
+		}
+		prop := iso7816.AppendTLV(iso7816.AppendTLV(nil, 0xC7, serial), 0x53, []byte{0x0A, 0x3C, 0x20, 0x05, 0x14, 0x01, 0x02})
+		fci := iso7816.AppendTLV(iso7816.AppendTLV(nil, 0x84, []byte("1TIC.ICA")), 0xA5, iso7816.AppendTLV(nil, 0xBF0C, prop))
+		return append(iso7816.AppendTLV(nil, 0x6F, fci), ok...), nil
+	case 0xB2:
+		if cmd[4] != recordSize {
+			return []byte{0x6C, recordSize}, nil
+		}
+		var recs [][]byte
+		switch cmd[3] >> 3 {
+		case SFIEnvironment:
+			recs = [][]byte{environment}
+		case SFIContracts:
+			recs = [][]byte{contract, make([]byte, recordSize)}
+		case SFIEventLog:
+			recs = [][]byte{event}
+		case SFICounters:
+			recs = [][]byte{append([]byte{0x00, 0x00, 0x0A, 0x00, 0x01, 0xF4}, make([]byte, recordSize-6)...)}
+		default:
+			return []byte{0x6A, 0x82}, nil
+		}
+		if int(cmd[2]) > len(recs) {
+			return []byte{0x6A, 0x83}, nil
+		}
+		return append(append([]byte(nil), recs[cmd[2]-1]...), ok...), nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+func TestCard(t *testing.T) {
+	for _, rev1 := range []bool{false, true} {
+		card, err := Open(&fakeCard{rev1: rev1})
+		if err != nil {
+			t.Fatalf("rev1 %v: Open() error = %v", rev1, err)
+		}
+		want := byte(claISO)
+		if rev1 {
+			want = claRev1
+		}
+		if card.cla != want {
+			t.Errorf("rev1 %v: class %02X", rev1, card.cla)
+		}
+		if !bytes.Equal(card.AID, []byte("1TIC.ICA")) || !bytes.Equal(card.Serial, serial) || card.Startup.ApplicationType != 0x20 || card.Startup.SoftwareRevision != 0x02 {
+			t.Errorf("rev1 %v: Open() = %X %X %+v", rev1, card.AID, card.Serial, card.Startup)
+		}
+
+		env, err := card.Environment()
+		if err != nil {
+			t.Fatalf("rev1 %v: Environment() error = %v", rev1, err)
+		}
+		end, _ := env.Date("EnvApplicationValidityEndDate")
+		if env["EnvApplicationVersionNumber"] != 1 || env["EnvNetworkId"] != 0x250901 || !end.Equal(time.Date(2026, time.December, 31, 0, 0, 0, 0, time.UTC)) || env.Has("EnvPayMethod") {
+			t.Errorf("rev1 %v: Environment() = %v", rev1, env)
+		}
+
+		contracts, err := card.Contracts()
+		if err != nil {
+			t.Fatalf("rev1 %v: Contracts() error = %v", rev1, err)
+		}
+		if len(contracts) != 1 || contracts[0]["ContractTariff"] != 0x1234 || contracts[0]["ContractValidityStartDate"] != 9862 || contracts[0]["ContractValidityEndDate"] != 9892 {
+			t.Errorf("rev1 %v: Contracts() = %v", rev1, contracts)
+		}
+
+		events, err := card.Events()
+		if err != nil {
+			t.Fatalf("rev1 %v: Events() error = %v", rev1, err)
+		}
+		if len(events) != 1 || events[0]["EventCode"] != 0x11 || events[0]["EventLocationId"] != 0x0421 {
+			t.Fatalf("rev1 %v: Events() = %v", rev1, events)
+		}
+		if at := DateTime(events[0]["EventDateStamp"], events[0]["EventTimeStamp"]); !at.Equal(time.Date(2024, time.January, 10, 8, 30, 0, 0, time.UTC)) {
+			t.Errorf("rev1 %v: event at %v", rev1, at)
+		}
+
+		counters, err := card.Counters()
+		if err != nil {
+			t.Fatalf("rev1 %v: Counters() error = %v", rev1, err)
+		}
+		if len(counters) != 9 || counters[0] != 10 || counters[1] != 500 {
+			t.Errorf("rev1 %v: Counters() = %v", rev1, counters)
+		}
+
+		if recs, err := card.ReadRecords(SFISpecialEvents); err != nil || len(recs) != 0 {
+			t.Errorf("rev1 %v: ReadRecords(missing) = %d records, %v", rev1, len(recs), err)
+		}
+	}
+
+	if _, err := Open(&fakeCard{}, []byte("2TIC.ICA")); !errors.Is(err, ErrNotCalypso) {
+		t.Errorf("Open(other aid) error = %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package calypso
+
+import (
+	"fmt"
+	"time"
+)
+
+// Element describes an element of an EN 1545 structure: a field of Bits
+// bits or, when Bitmap is set, a presence bitmap of len(Bitmap) bits
+// followed by the elements whose bit is set, the first element on the least
+// significant bit.
+type Element struct {
+	Name   string
+	Bits   int
+	Bitmap []Element
+}
+
+// Field returns a field element of bits bits.
+func Field(name string, bits int) Element { return Element{Name: name, Bits: bits} }
+
+// Bitmap returns a bitmap element over elems.
+func Bitmap(name string, elems ...Element) Element { return Element{Name: name, Bitmap: elems} }
+
+// Values holds the fields of a decoded structure by name. Fields wider than
+// 64 bits are skipped and absent.
+type Values map[string]uint64
+
+// Has reports whether the field name was present.
+func (v Values) Has(name string) bool {
+	_, ok := v[name]
+	return ok
+}
+
+// Date returns the EN 1545 date of field name, a number of days since
+// 1 January 1997, and whether it was present.
+func (v Values) Date(name string) (time.Time, bool) {
+	d, ok := v[name]
+	return Date(d), ok
+}
+
+// Decode decodes data, whose bits are read most significant first, along
+// the structure elems.
+func Decode(data []byte, elems ...Element) (Values, error) {
+	v, err := decode(data, elems)
+	if err != nil {
+		return nil, fmt.Errorf("calypso: %w", err)
+	}
+	return v, nil
+}
+
+func decode(data []byte, elems []Element) (Values, error) {
+	d := decoder{data: data, v: Values{}}
+	if err := d.elements(elems); err != nil {
+		return nil, err
+	}
+	return d.v, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+	v    Values
+}
+
+func (d *decoder) elements(elems []Element) error {
+	for _, e := range elems {
+		if e.Bitmap == nil {
+			if err := d.field(e); err != nil {
+				return err
+			}
+			continue
+		}
+		bitmap, err := d.read(len(e.Bitmap))
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Name, err)
+		}
+		for i, sub := range e.Bitmap {
+			if bitmap&(1<<i) == 0 {
+				continue
+			}
+			if err := d.elements([]Element{sub}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *decoder) field(e Element) error {
+	if e.Bits > 64 {
+		if d.pos+e.Bits > 8*len(d.data) {
+			return fmt.Errorf("%s: structure truncated", e.Name)
+		}
+		d.pos += e.Bits
+		return nil
+	}
+	x, err := d.read(e.Bits)
+	if err != nil {
+		return fmt.Errorf("%s: %w", e.Name, err)
+	}
+	d.v[e.Name] = x
+	return nil
+}
+
+func (d *decoder) read(n int) (uint64, error) {
+	if d.pos+n > 8*len(d.data) {
+		return 0, fmt.Errorf("structure truncated")
+	}
+	var x uint64
+	for i := 0; i < n; i++ {
+		bit := d.data[(d.pos+i)/8] >> (7 - (d.pos+i)%8) & 1
+		x = x<<1 | uint64(bit)
+	}
+	d.pos += n
+	return x, nil
+}
+
+// epoch is the origin of EN 1545 dates.
+var epoch = time.Date(1997, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Date returns the EN 1545 date of days since 1 January 1997.
+func Date(days uint64) time.Time { return epoch.AddDate(0, 0, int(days)) }
+
+// DateTime returns the EN 1545 date of days since 1 January 1997 at the
+// time of minutes since midnight. Networks record local time, returned as
+// UTC.
+func DateTime(days, minutes uint64) time.Time {
+	return Date(days).Add(time.Duration(minutes) * time.Minute)
+}
@@ -0,0 +1,43 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package calypso
+
+import "testing"
+
+func TestDecode(t *testing.T) {
+	elems := []Element{
+		Field("A", 4),
+		Bitmap("B", Field("B0", 3), Field("Wide", 70), Bitmap("B2", Field("C", 5), Field("D", 2))),
+		Field("E", 1),
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want Values
+	}{
+		{"all", bits([2]uint64{9, 4}, [2]uint64{0b111, 3}, [2]uint64{5, 3}, [2]uint64{0, 70}, [2]uint64{0b10, 2}, [2]uint64{3, 2}, [2]uint64{1, 1}),
+			Values{"A": 9, "B0": 5, "D": 3, "E": 1}},
+		{"none", bits([2]uint64{2, 4}, [2]uint64{0, 3}, [2]uint64{0, 1}), Values{"A": 2, "E": 0}},
+	}
+	for _, tt := range tests {
+		got, err := Decode(tt.data, elems...)
+		if err != nil {
+			t.Errorf("%s: Decode() error = %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: Decode() = %v, want %v", tt.name, got, tt.want)
+		}
+		for k, v := range tt.want {
+			if got[k] != v || !got.Has(k) {
+				t.Errorf("%s: Decode()[%s] = %d, want %d", tt.name, k, got[k], v)
+			}
+		}
+	}
+
+	if _, err := Decode([]byte{0xFF}, elems...); err == nil {
+		t.Error("Decode(truncated) succeeded")
+	}
+}
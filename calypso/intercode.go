@@ -0,0 +1,134 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package calypso
+
+// Structures of the Intercode II profile of EN 1545, used by most Calypso
+// networks. Networks may define their own variants, described with
+// Element and decoded with Decode.
+var (
+	// IntercodeEnvironment is the environment record, the first record
+	// of EF Environment.
+	IntercodeEnvironment = []Element{
+		Field("EnvApplicationVersionNumber", 6),
+		Bitmap("EnvBitmap",
+			Field("EnvNetworkId", 24),
+			Field("EnvApplicationIssuerId", 8),
+			Field("EnvApplicationValidityEndDate", 14),
+			Field("EnvPayMethod", 11),
+			Field("EnvAuthenticator", 16),
+			Field("EnvSelectList", 32),
+			Bitmap("EnvData",
+				Field("EnvCardStatus", 1),
+				Field("EnvExtra", 0),
+			),
+		),
+	}
+
+	// IntercodeContract is a record of EF Contracts.
+	IntercodeContract = []Element{
+		Bitmap("ContractBitmap",
+			Field("ContractNetworkId", 24),
+			Field("ContractProvider", 8),
+			Field("ContractTariff", 16),
+			Field("ContractSerialNumber", 32),
+			Bitmap("ContractCustomerInfo",
+				Field("ContractCustomerProfile", 6),
+				Field("ContractCustomerNumber", 32),
+			),
+			Bitmap("ContractPassengerInfo",
+				Field("ContractPassengerClass", 8),
+				Field("ContractPassengerTotal", 8),
+			),
+			Field("ContractVehicleClassAllowed", 6),
+			Field("ContractPaymentPointer", 32),
+			Field("ContractPayMethod", 11),
+			Field("ContractServices", 16),
+			Field("ContractPriceAmount", 16),
+			Field("ContractPriceUnit", 16),
+			Bitmap("ContractRestrictions",
+				Field("ContractRestrictStart", 11),
+				Field("ContractRestrictEnd", 11),
+				Field("ContractRestrictDay", 8),
+				Field("ContractRestrictTimeCode", 8),
+				Field("ContractRestrictCode", 8),
+				Field("ContractRestrictProduct", 16),
+				Field("ContractRestrictLocation", 16),
+			),
+			Bitmap("ContractValidityInfo",
+				Field("ContractValidityStartDate", 14),
+				Field("ContractValidityStartTime", 11),
+				Field("ContractValidityEndDate", 14),
+				Field("ContractValidityEndTime", 11),
+				Field("ContractValidityDuration", 8),
+				Field("ContractValidityLimitDate", 14),
+				Field("ContractValidityZones", 8),
+				Field("ContractValidityJourneys", 16),
+				Field("ContractPeriodJourneys", 16),
+			),
+			Bitmap("ContractJourneyData",
+				Field("ContractJourneyOrigin", 16),
+				Field("ContractJourneyDestination", 16),
+				Field("ContractJourneyRouteNumbers", 16),
+				Field("ContractJourneyRouteVariants", 8),
+				Field("ContractJourneyRun", 16),
+				Field("ContractJourneyViaLoc", 16),
+				Field("ContractJourneyDistance", 16),
+				Field("ContractJourneyInterchanges", 8),
+			),
+			Bitmap("ContractSaleData",
+				Field("ContractSaleDate", 14),
+				Field("ContractSaleTime", 11),
+				Field("ContractSaleAgent", 8),
+				Field("ContractSaleDevice", 16),
+			),
+			Field("ContractStatus", 8),
+			Field("ContractLoyaltyPoints", 16),
+			Field("ContractAuthenticator", 16),
+			Field("ContractData", 0),
+		),
+	}
+
+	// IntercodeEvent is a record of EF Event Log.
+	IntercodeEvent = []Element{
+		Field("EventDateStamp", 14),
+		Field("EventTimeStamp", 11),
+		Bitmap("EventBitmap",
+			Field("EventDisplayData", 8),
+			Field("EventNetworkId", 24),
+			Field("EventCode", 8),
+			Field("EventResult", 8),
+			Field("EventServiceProvider", 8),
+			Field("EventNotOkCounter", 8),
+			Field("EventSerialNumber", 24),
+			Field("EventDestination", 16),
+			Field("EventLocationId", 16),
+			Field("EventLocationGate", 8),
+			Field("EventDevice", 16),
+			Field("EventRouteNumber", 16),
+			Field("EventRouteVariant", 8),
+			Field("EventJourneyRun", 16),
+			Field("EventVehicleId", 16),
+			Field("EventVehicleClass", 8),
+			Field("EventLocationType", 5),
+			Field("EventEmployee", 240),
+			Field("EventLocationReference", 16),
+			Field("EventJourneyInterchanges", 8),
+			Field("EventPeriodJourneys", 16),
+			Field("EventTotalJourneys", 16),
+			Field("EventJourneyDistance", 16),
+			Field("EventPriceAmount", 16),
+			Field("EventPriceUnit", 16),
+			Field("EventContractPointer", 5),
+			Field("EventAuthenticator", 16),
+			Bitmap("EventData",
+				Field("EventDataDateFirstStamp", 14),
+				Field("EventDataTimeFirstStamp", 11),
+				Field("EventDataSimulation", 1),
+				Field("EventDataTrip", 2),
+				Field("EventDataRouteDirection", 2),
+			),
+		),
+	}
+)
@@ -0,0 +1,124 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// CardContext is a card session as seen by a handler written with CardFunc.
+// It gathers what the SDK knows about the card and the services it offers
+// for it, so handlers gain features without changing their signature.
+type CardContext struct {
+	ctx context.Context
+
+	// Event is the card inserted event of the session, with its UID, ATR,
+	// session ID and, when resolved or read, identity and NDEF message.
+	Event cardreader.Event
+	// Reader is the reader the card was presented to.
+	Reader cardreader.Reader
+	// Type is the tag type of the card. It is probed by the detector of the
+	// SDK when dispatch depended on it, see Handle, and detected from the
+	// ATR otherwise.
+	Type tag.Type
+	// Card is the connected card. Prefer Transmit, which reports the
+	// exchanges to the SDK.
+	Card transport.Card
+	// Logger logs with the session group of the card lifecycle records.
+	Logger *slog.Logger
+
+	tr apdu.Transceiver
+}
+
+// Context returns the context of the session, carrying its deadline, see
+// WithSessionTimeout, and cancellation.
+func (c *CardContext) Context() context.Context { return c.ctx }
+
+// Deadline returns the time the session has to end by, if any.
+func (c *CardContext) Deadline() (time.Time, bool) { return c.ctx.Deadline() }
+
+// UID returns the UID of the card, nil when unknown.
+func (c *CardContext) UID() []byte { return c.Event.UID }
+
+// ATR returns the ATR of the card.
+func (c *CardContext) ATR() []byte { return c.Event.ATR }
+
+// Protocol returns the protocol negotiated with the card, ProtocolUndefined
+// when its backend does not report it.
+func (c *CardContext) Protocol() pcsc.Protocol {
+	if p, ok := c.Card.(interface{ Protocol() pcsc.Protocol }); ok {
+		return p.Protocol()
+	}
+	return pcsc.ProtocolUndefined
+}
+
+// Transmit sends cmd to the card honouring the session context. The
+// exchange is reported to the metrics and tracer of the SDK and logged to
+// its LogPCSC logger, and recovers from card resets following the policy
+// set with WithReconnectPolicy. CardContext is thereby an apdu.Transceiver, so
+// protocol packages and middlewares take it in place of the card.
+func (c *CardContext) Transmit(cmd []byte) ([]byte, error) { return c.tr.Transmit(cmd) }
+
+// CardFunc adapts a handler taking a CardContext to a CardHandler, which may
+// be registered with WithCardHandler, Handle, HandleATR and HandleFilter.
+// Called outside the SDK, the handler gets a context built from the
+// arguments alone, logging nothing.
+func CardFunc(fn func(c *CardContext) error) CardHandler {
+	return func(ctx context.Context, ev cardreader.Event, card transport.Card) error {
+		c, ok := ctx.Value(cardContextKey{}).(*CardContext)
+		if !ok || c.Card != card {
+			c = &CardContext{
+				ctx:    ctx,
+				Event:  ev,
+				Reader: cardreader.Reader{Name: ev.Reader},
+				Type:   tag.Detect(tag.Signature{ATR: ev.ATR}),
+				Card:   card,
+				Logger: slog.New(discardHandler{}),
+				tr: apdu.TransceiverFunc(func(cmd []byte) ([]byte, error) {
+					return transport.TransmitContext(ctx, card, cmd)
+				}),
+			}
+		}
+		return fn(c)
+	}
+}
+
+// WithSessionTimeout bounds every card session: the context passed to card
+// handlers is cancelled d after the card was connected. Values not greater
+// than zero are ignored.
+func WithSessionTimeout(d time.Duration) Option {
+	return func(sdk *SDK) {
+		if d > 0 {
+			sdk.sessionTimeout = d
+		}
+	}
+}
+
+// cardContextKey is the context key of the CardContext of a session.
+type cardContextKey struct{}
+
+// withCardContext returns ctx carrying the CardContext of the session of ev,
+// used by handlers adapted with CardFunc.
+func (sdk *SDK) withCardContext(ctx context.Context, ev cardreader.Event, reader cardreader.Reader, typ tag.Type, card transport.Card) context.Context {
+	c := &CardContext{
+		Event:  ev,
+		Reader: reader,
+		Type:   typ,
+		Card:   card,
+		Logger: sdk.logger.With(sessionGroup(ev, card)),
+	}
+	ctx = context.WithValue(ctx, cardContextKey{}, c)
+	c.ctx = ctx
+	c.tr = instrumentedCard{Card: sdk.reconnecting(card, reader.Name), ctx: ctx, reader: reader.Name, sdk: sdk}
+	return ctx
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+)
+
+func TestCardFunc(t *testing.T) {
+	var logs bytes.Buffer
+	var got *CardContext
+	var uid []byte
+	sdk := New(
+		WithBackend(&fakeBackend{reader: *cardreader.NewReader("virtual"), card: &memCard{atr: []byte{0x3B, 0x81, 0x80, 0x01, 0x80, 0x80}}}),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		WithSessionTimeout(time.Minute),
+		WithCardHandler(CardFunc(func(c *CardContext) error {
+			got = c
+			c.Logger.Info("handled")
+			resp, err := c.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+			uid = resp
+			return err
+		})),
+	)
+	ev, err := sdk.WaitForCard(context.Background(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("handler not called")
+	}
+	if got.Reader.Name != "virtual" || !bytes.Equal(got.UID(), ev.UID) || got.Event.SessionID != ev.SessionID {
+		t.Errorf("CardContext = %+v, event %+v", got, ev)
+	}
+	if got.Type != tag.TypeDESFire || got.Protocol() != pcsc.ProtocolUndefined {
+		t.Errorf("Type = %s, Protocol() = %s", got.Type, got.Protocol())
+	}
+	// WaitForCard bounds the session to its own timeout.
+	if d, ok := got.Deadline(); !ok || d.Sub(ev.Time) > time.Second {
+		t.Errorf("Deadline() = %v, %v", d, ok)
+	}
+	if !bytes.Equal(uid, []byte{0x04, 0xA1, 0xB2, 0xC3, 0x90, 0x00}) {
+		t.Errorf("Transmit() = %X", uid)
+	}
+	if !strings.Contains(logs.String(), "msg=handled") || !strings.Contains(logs.String(), "session.session_id="+ev.SessionID) {
+		t.Errorf("session logger records:\n%s", logs.String())
+	}
+
+	sdk = New(WithBackend(&fakeBackend{reader: *cardreader.NewReader("virtual"), card: &memCard{}}), WithSessionTimeout(time.Minute),
+		WithCardHandler(CardFunc(func(c *CardContext) error {
+			got = c
+			return nil
+		})))
+	ev, err = sdk.WaitForCard(context.Background(), 0)
+	if d, ok := got.Deadline(); err != nil || !ok || d.Sub(ev.Time) != time.Minute {
+		t.Errorf("Deadline() with session timeout = %v, %v", d, ok)
+	}
+
+	// Outside the SDK the context is built from the handler arguments.
+	card := &memCard{}
+	err = CardFunc(func(c *CardContext) error {
+		if c.Card != card || c.Reader.Name != "r0" || c.Context() == nil {
+			t.Errorf("CardContext = %+v", c)
+		}
+		_, err := c.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+		return err
+	})(context.Background(), cardreader.Event{Reader: "r0"}, card)
+	if err != nil {
+		t.Errorf("CardFunc() error = %v", err)
+	}
+}
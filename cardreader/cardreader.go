@@ -10,6 +10,11 @@
 
 package cardreader
 
+import (
+	"encoding/hex"
+	"log/slog"
+)
+
 const (
 	// Constants related to reader status, types, etc.
 	StatusConnected    = "connected"
@@ -31,6 +36,38 @@ func Connect(readerName string) (*Reader, error) { return nil, nil }
 // Reader represents a smart card reader device.
 type Reader struct {
 	// ... Fields representing reader properties ...
+	Name string // PC/SC name of the reader.
+
+	last *lastCard
+	sel  *selection
+}
+
+// NewReader returns a reader representation for the named reader.
+func NewReader(name string) *Reader {
+	return &Reader{Name: name, last: &lastCard{}, sel: &selection{}}
+}
+
+// Track returns a copy of r sharing its last card and selection state. It
+// creates them for readers not created with NewReader, such as readers
+// listed by name only, so RecordCard and Select take effect on the copy.
+func (r Reader) Track() *Reader {
+	if r.last == nil {
+		r.last = &lastCard{}
+	}
+	if r.sel == nil {
+		r.sel = &selection{}
+	}
+	return &r
+}
+
+// LogValue implements slog.LogValuer, logging the reader as a group of its
+// name and the UID of the last card it has seen, if any.
+func (r Reader) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("name", r.Name)}
+	if last, ok := r.LastCard(); ok && len(last.UID) > 0 {
+		attrs = append(attrs, slog.String("last_uid", hex.EncodeToString(last.UID)))
+	}
+	return slog.GroupValue(attrs...)
 }
 
 // IsConnected checks if the reader is connected based on the status.
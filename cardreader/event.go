@@ -0,0 +1,75 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cardreader
+
+import (
+	"time"
+
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+)
+
+// EventType identifies the kind of change observed on a reader.
+type EventType uint8
+
+const (
+	EventCardInserted  EventType = iota + 1 // A card was placed on the reader.
+	EventCardRemoved                        // A card was removed from the reader.
+	EventReaderAdded                        // A reader was attached to the system.
+	EventReaderRemoved                      // A reader was detached from the system.
+	EventAccessGranted                      // An access policy allowed a card.
+	EventAccessDenied                       // An access policy denied a card.
+	EventReaderHealth                       // The health of a reader changed.
+	EventStateChanged                       // The lifecycle state of the SDK changed.
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventCardInserted:
+		return "card-inserted"
+	case EventCardRemoved:
+		return "card-removed"
+	case EventReaderAdded:
+		return "reader-added"
+	case EventReaderRemoved:
+		return "reader-removed"
+	case EventAccessGranted:
+		return "access-granted"
+	case EventAccessDenied:
+		return "access-denied"
+	case EventReaderHealth:
+		return "reader-health"
+	case EventStateChanged:
+		return "state-changed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a change observed on a smart card reader.
+type Event struct {
+	Type   EventType
+	Reader string    // Name of the reader the event originates from.
+	Zone   string    // Zone the reader belongs to, empty when unassigned.
+	ATR    []byte    // ATR of the card for card events.
+	UID    []byte    // UID of the card for card events, when known.
+	Time   time.Time // Time the event was observed.
+
+	// SessionID identifies the card session of card inserted events
+	// dispatched by the SDK, matching the session_id of its log records.
+	SessionID string
+
+	// Identity is the person or asset the card UID resolves to, nil when
+	// no resolver is used or the UID is unknown.
+	Identity *Identity
+
+	// NDEF is the NDEF message of the card, read by the SDK before
+	// dispatch when a filter inspects it, nil otherwise.
+	NDEF *ndef.Message
+
+	// Reason explains access denied events and holds the new health of
+	// reader health events and the new state of state changed events.
+	Reason string
+}
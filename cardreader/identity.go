@@ -0,0 +1,167 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cardreader
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Identity is a person or asset record a card UID resolves to.
+type Identity struct {
+	ID         string
+	Name       string
+	Attributes map[string]string
+}
+
+// IdentityResolver resolves card UIDs to identities. Resolve receives UIDs
+// in the form returned by UIDKey and returns identities keyed the same way;
+// unknown UIDs are omitted from the result.
+type IdentityResolver interface {
+	Resolve(ctx context.Context, uids []string) (map[string]*Identity, error)
+}
+
+// IdentityResolverFunc adapts a function to the IdentityResolver interface.
+type IdentityResolverFunc func(ctx context.Context, uids []string) (map[string]*Identity, error)
+
+// Resolve calls f(ctx, uids).
+func (f IdentityResolverFunc) Resolve(ctx context.Context, uids []string) (map[string]*Identity, error) {
+	return f(ctx, uids)
+}
+
+// UIDKey returns the key used for uid by identity resolvers, the upper case
+// hex encoding of the UID.
+func UIDKey(uid []byte) string {
+	return strings.ToUpper(hex.EncodeToString(uid))
+}
+
+// ResolveIdentities resolves the UIDs of events with a single batch lookup
+// and sets their Identity. It is meant to be called before events are handed
+// to consumers, so they receive resolved identities rather than raw UIDs.
+func ResolveIdentities(ctx context.Context, r IdentityResolver, events []Event) error {
+	var uids []string
+	seen := make(map[string]bool)
+	for _, ev := range events {
+		if len(ev.UID) == 0 {
+			continue
+		}
+		key := UIDKey(ev.UID)
+		if !seen[key] {
+			seen[key] = true
+			uids = append(uids, key)
+		}
+	}
+	if len(uids) == 0 {
+		return nil
+	}
+
+	ids, err := r.Resolve(ctx, uids)
+	if err != nil {
+		return err
+	}
+	for i := range events {
+		if len(events[i].UID) > 0 {
+			events[i].Identity = ids[UIDKey(events[i].UID)]
+		}
+	}
+	return nil
+}
+
+// CachedResolver caches the results of another IdentityResolver, including
+// unknown UIDs, for a fixed time. Expired entries are dropped as the cache
+// grows, so it holds at most about twice the UIDs resolved within the last
+// ttl. It is safe for concurrent use.
+type CachedResolver struct {
+	next IdentityResolver
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedIdentity
+	sweepAt int // Size of entries triggering the next sweep.
+}
+
+// minCacheSweep is the smallest cache size at which CachedResolver drops
+// expired entries.
+const minCacheSweep = 64
+
+type cachedIdentity struct {
+	id      *Identity
+	expires time.Time
+}
+
+// NewCachedResolver returns a resolver caching the results of next for ttl.
+func NewCachedResolver(next IdentityResolver, ttl time.Duration) *CachedResolver {
+	return &CachedResolver{
+		next:    next,
+		ttl:     ttl,
+		entries: make(map[string]cachedIdentity),
+		sweepAt: minCacheSweep,
+	}
+}
+
+// Resolve returns cached identities and looks up the remaining UIDs with a
+// single call to the wrapped resolver.
+func (c *CachedResolver) Resolve(ctx context.Context, uids []string) (map[string]*Identity, error) {
+	now := time.Now()
+	result := make(map[string]*Identity, len(uids))
+	var missing []string
+
+	c.mu.Lock()
+	for _, uid := range uids {
+		e, ok := c.entries[uid]
+		if !ok || now.After(e.expires) {
+			missing = append(missing, uid)
+			continue
+		}
+		if e.id != nil {
+			result[uid] = e.id
+		}
+	}
+	c.mu.Unlock()
+
+	if len(missing) == 0 {
+		return result, nil
+	}
+	ids, err := c.next.Resolve(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, uid := range missing {
+		id := ids[uid]
+		c.entries[uid] = cachedIdentity{id: id, expires: now.Add(c.ttl)}
+		if id != nil {
+			result[uid] = id
+		}
+	}
+	if len(c.entries) >= c.sweepAt {
+		c.sweep(now)
+	}
+	return result, nil
+}
+
+// sweep drops the entries expired at now and doubles the remaining size as
+// the next sweep threshold, keeping the amortized cost constant.
+func (c *CachedResolver) sweep(now time.Time) {
+	for uid, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, uid)
+		}
+	}
+	c.sweepAt = max(2*len(c.entries), minCacheSweep)
+}
+
+// Purge drops all cached entries.
+func (c *CachedResolver) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cachedIdentity)
+	c.sweepAt = minCacheSweep
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cardreader
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCachedResolver(t *testing.T) {
+	var lookups [][]string
+	backend := IdentityResolverFunc(func(ctx context.Context, uids []string) (map[string]*Identity, error) {
+		lookups = append(lookups, uids)
+		ids := make(map[string]*Identity)
+		for _, uid := range uids {
+			if uid == "04A1B2C3" {
+				ids[uid] = &Identity{ID: "emp-42", Name: "Badge 42"}
+			}
+		}
+		return ids, nil
+	})
+	resolver := NewCachedResolver(backend, time.Minute)
+
+	events := []Event{
+		{Type: EventCardInserted, UID: []byte{0x04, 0xA1, 0xB2, 0xC3}},
+		{Type: EventCardInserted, UID: []byte{0x04, 0xA1, 0xB2, 0xC3}},
+		{Type: EventCardInserted, UID: []byte{0xDE, 0xAD}},
+		{Type: EventReaderAdded},
+	}
+	for i := 0; i < 2; i++ {
+		if err := ResolveIdentities(context.Background(), resolver, events); err != nil {
+			t.Fatalf("ResolveIdentities() error = %v", err)
+		}
+	}
+
+	if len(lookups) != 1 || len(lookups[0]) != 2 {
+		t.Fatalf("backend lookups = %v, want a single batch of 2 UIDs", lookups)
+	}
+	if events[0].Identity == nil || events[0].Identity.ID != "emp-42" || events[1].Identity != events[0].Identity {
+		t.Errorf("known UID resolved to %+v, want emp-42", events[0].Identity)
+	}
+	if events[2].Identity != nil {
+		t.Errorf("unknown UID resolved to %+v, want nil", events[2].Identity)
+	}
+}
+
+func TestCachedResolverEvicts(t *testing.T) {
+	backend := IdentityResolverFunc(func(ctx context.Context, uids []string) (map[string]*Identity, error) {
+		return nil, nil
+	})
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		max  int // Bound of the cached entries, reached while they live.
+	}{
+		{"expired", -time.Second, minCacheSweep},
+		{"live", time.Hour, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewCachedResolver(backend, tt.ttl)
+			for i := 0; i < 1000; i++ {
+				if _, err := resolver.Resolve(context.Background(), []string{fmt.Sprintf("%08X", i)}); err != nil {
+					t.Fatalf("Resolve() error = %v", err)
+				}
+			}
+			if n := len(resolver.entries); n > tt.max || tt.ttl > 0 && n != tt.max {
+				t.Errorf("cached entries = %d, want at most %d", n, tt.max)
+			}
+		})
+	}
+}
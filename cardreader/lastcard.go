@@ -0,0 +1,54 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cardreader
+
+import (
+	"sync"
+	"time"
+)
+
+// CardInfo summarizes a card seen by a reader.
+type CardInfo struct {
+	UID  []byte
+	ATR  []byte
+	Time time.Time // Time the card was seen.
+	NDEF string    // Short summary of the NDEF message, empty when none was read.
+}
+
+// lastCard holds the last card seen by a reader. It is shared by all copies
+// of a Reader created with NewReader or Track.
+type lastCard struct {
+	mu   sync.RWMutex
+	info CardInfo
+	seen bool
+}
+
+// LastCard returns the last card seen by the reader. The second return value
+// is false when no card has been recorded yet. LastCard is safe to call at
+// any time, e.g. from a UI showing the last badge seen.
+func (r *Reader) LastCard() (CardInfo, bool) {
+	if r.last == nil {
+		return CardInfo{}, false
+	}
+	r.last.mu.RLock()
+	defer r.last.mu.RUnlock()
+	return r.last.info, r.last.seen
+}
+
+// RecordCard stores info as the last card seen by the reader. Only readers
+// created with NewReader or Track keep this cache, on others RecordCard
+// does nothing.
+func (r *Reader) RecordCard(info CardInfo) {
+	if r.last == nil {
+		return
+	}
+	if info.Time.IsZero() {
+		info.Time = time.Now()
+	}
+	r.last.mu.Lock()
+	defer r.last.mu.Unlock()
+	r.last.info = info
+	r.last.seen = true
+}
@@ -0,0 +1,38 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cardreader
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRecordCard(t *testing.T) {
+	tests := []struct {
+		name   string
+		reader *Reader
+		want   bool
+	}{
+		{"new reader", NewReader("ACS ACR122U 00"), true},
+		{"tracked listed reader", Reader{Name: "ACS ACR122U 00"}.Track(), true},
+		{"listed reader", &Reader{Name: "ACS ACR122U 00"}, false},
+	}
+	for _, tt := range tests {
+		copied := *tt.reader
+		copied.RecordCard(CardInfo{UID: []byte{0x04, 0xA1}})
+		last, ok := tt.reader.LastCard()
+		if ok != tt.want {
+			t.Errorf("%s: LastCard() seen = %v, want %v", tt.name, ok, tt.want)
+		}
+		if ok && (!bytes.Equal(last.UID, []byte{0x04, 0xA1}) || last.Time.IsZero()) {
+			t.Errorf("%s: LastCard() = %+v", tt.name, last)
+		}
+		if tracked := tt.reader.Track(); tt.want {
+			if _, ok := tracked.LastCard(); !ok {
+				t.Errorf("%s: Track() does not share the last card", tt.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cardreader
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// readerName matches PC/SC reader names. pcsc-lite names readers
+// "<friendly name> [<interface>] (<serial>) <index> <slot>" where the
+// interface and serial are optional; Windows appends a single index.
+var readerName = regexp.MustCompile(`^(.*?)(?: \[([^\]]*)\])?(?: \(([^)]*)\))?(?: ([0-9A-Fa-f]{1,2}))?(?: ([0-9A-Fa-f]{1,2}))?$`)
+
+// vendors lists vendor names spanning several words, other vendors are
+// taken to be the first word of the reader name.
+var vendors = []string{
+	"SCM Microsystems Inc.",
+	"Alcor Micro",
+	"Broadcom Corp",
+	"HID Global",
+	"Generic EMV",
+}
+
+// interfaceSuffixes are the designations of the interface of multi
+// interface readers found at the end of their friendly names.
+var interfaceSuffixes = []string{
+	" PICC Interface",
+	" SAM Interface",
+	" Interface",
+	" PICC",
+	" SAM",
+}
+
+// nameParts is a reader name split into its parts.
+type nameParts struct {
+	friendly string
+	slot     int
+}
+
+func parseName(name string) nameParts {
+	m := readerName.FindStringSubmatch(strings.TrimSpace(name))
+	if m == nil {
+		return nameParts{friendly: name}
+	}
+	p := nameParts{friendly: m[1]}
+	// The last number is the slot, a single one included.
+	if last := m[5]; last != "" {
+		p.slot = parseHex(last)
+	} else if m[4] != "" {
+		p.slot = parseHex(m[4])
+	}
+	return p
+}
+
+func parseHex(s string) int {
+	n, _ := strconv.ParseUint(s, 16, 8)
+	return int(n)
+}
+
+// Vendor returns the vendor of the reader parsed from its name, e.g. "ACS"
+// for "ACS ACR122U PICC Interface 00 00".
+func (r Reader) Vendor() string {
+	friendly := parseName(r.Name).friendly
+	for _, v := range vendors {
+		if strings.HasPrefix(friendly, v+" ") || friendly == v {
+			return v
+		}
+	}
+	vendor, _, _ := strings.Cut(friendly, " ")
+	return vendor
+}
+
+// Model returns the model of the reader parsed from its name, without the
+// vendor and interface designation, e.g. "ACR122U" for
+// "ACS ACR122U PICC Interface 00 00".
+func (r Reader) Model() string {
+	model := strings.TrimSpace(strings.TrimPrefix(parseName(r.Name).friendly, r.Vendor()))
+	for _, s := range interfaceSuffixes {
+		if m, ok := strings.CutSuffix(model, s); ok && m != "" {
+			return m
+		}
+	}
+	return model
+}
+
+// Slot returns the slot number of the reader parsed from its name, which
+// tells apart the interfaces of a device such as the contactless and SAM
+// slots of an ACR1252. It is zero when the name carries no number.
+func (r Reader) Slot() int { return parseName(r.Name).slot }
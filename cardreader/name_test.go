@@ -0,0 +1,29 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cardreader
+
+import "testing"
+
+func TestReaderName(t *testing.T) {
+	tests := []struct {
+		name          string
+		vendor, model string
+		slot          int
+	}{
+		{"ACS ACR122U PICC Interface 00", "ACS", "ACR122U", 0},
+		{"ACS ACR122U PICC Interface 00 00", "ACS", "ACR122U", 0},
+		{"ACS ACR1252 1S CL Reader [ACR1252 1S CL Reader SAM] 00 01", "ACS", "ACR1252 1S CL Reader", 1},
+		{"Identiv uTrust 3700 F CL Reader [uTrust 3700 F CL Reader] (55041738400036) 01 0A", "Identiv", "uTrust 3700 F CL Reader", 10},
+		{"SCM Microsystems Inc. SCR 3310 [CCID Interface] 00 00", "SCM Microsystems Inc.", "SCR 3310", 0},
+		{"ACS ACR122 0", "ACS", "ACR122", 0},
+		{"virtual", "virtual", "", 0},
+	}
+	for _, tt := range tests {
+		r := Reader{Name: tt.name}
+		if v, m, s := r.Vendor(), r.Model(), r.Slot(); v != tt.vendor || m != tt.model || s != tt.slot {
+			t.Errorf("%q: Vendor, Model, Slot = %q, %q, %d, want %q, %q, %d", tt.name, v, m, s, tt.vendor, tt.model, tt.slot)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cardreader
+
+import "regexp"
+
+// ReaderSelectFunc selects the readers to use from the readers available on
+// the system. It must not modify the provided slice.
+type ReaderSelectFunc func(readers []Reader) []Reader
+
+// SelectAllReaders selects every available reader.
+func SelectAllReaders() ReaderSelectFunc {
+	return func(readers []Reader) []Reader {
+		return append([]Reader(nil), readers...)
+	}
+}
+
+// SelectReaderByName selects the readers whose name equals one of names.
+func SelectReaderByName(names ...string) ReaderSelectFunc {
+	return func(readers []Reader) []Reader {
+		var selected []Reader
+		for _, r := range readers {
+			for _, name := range names {
+				if r.Name == name {
+					selected = append(selected, r)
+					break
+				}
+			}
+		}
+		return selected
+	}
+}
+
+// SelectReaderByIndex selects readers by their position in the list of
+// available readers. Indexes out of range are ignored.
+func SelectReaderByIndex(indexes ...int) ReaderSelectFunc {
+	return func(readers []Reader) []Reader {
+		var selected []Reader
+		for i, r := range readers {
+			for _, idx := range indexes {
+				if i == idx {
+					selected = append(selected, r)
+					break
+				}
+			}
+		}
+		return selected
+	}
+}
+
+// SelectReadersMatching selects the readers whose name matches re.
+func SelectReadersMatching(re *regexp.Regexp) ReaderSelectFunc {
+	return func(readers []Reader) []Reader {
+		var selected []Reader
+		for _, r := range readers {
+			if re.MatchString(r.Name) {
+				selected = append(selected, r)
+			}
+		}
+		return selected
+	}
+}
+
+// SelectAnyOf selects the readers picked by at least one of selectors,
+// keeping the order of the available readers.
+func SelectAnyOf(selectors ...ReaderSelectFunc) ReaderSelectFunc {
+	return func(readers []Reader) []Reader {
+		picked := make(map[string]bool)
+		for _, sel := range selectors {
+			for _, r := range sel(readers) {
+				picked[r.Name] = true
+			}
+		}
+		var selected []Reader
+		for _, r := range readers {
+			if picked[r.Name] {
+				selected = append(selected, r)
+			}
+		}
+		return selected
+	}
+}
+
+// SelectAllOf applies selectors in order, each one choosing from the readers
+// selected by the previous one.
+func SelectAllOf(selectors ...ReaderSelectFunc) ReaderSelectFunc {
+	return func(readers []Reader) []Reader {
+		selected := readers
+		for _, sel := range selectors {
+			selected = sel(selected)
+		}
+		return append([]Reader(nil), selected...)
+	}
+}
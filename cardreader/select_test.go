@@ -0,0 +1,52 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cardreader
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestReaderSelectors(t *testing.T) {
+	readers := []Reader{
+		{Name: "ACS ACR122U PICC Interface 00"},
+		{Name: "Identiv uTrust 3700 F CL Reader 01"},
+		{Name: "ACS ACR1252 1S CL Reader PICC 02"},
+	}
+
+	tests := []struct {
+		name string
+		sel  ReaderSelectFunc
+		want []string
+	}{
+		{"all", SelectAllReaders(), []string{readers[0].Name, readers[1].Name, readers[2].Name}},
+		{"by name", SelectReaderByName("ACS ACR122U PICC Interface 00"), []string{readers[0].Name}},
+		{"by index", SelectReaderByIndex(1, 7), []string{readers[1].Name}},
+		{"matching", SelectReadersMatching(regexp.MustCompile(`^ACS `)), []string{readers[0].Name, readers[2].Name}},
+		{
+			"any of",
+			SelectAnyOf(SelectReaderByIndex(2), SelectReaderByName("ACS ACR122U PICC Interface 00")),
+			[]string{readers[0].Name, readers[2].Name},
+		},
+		{
+			"all of",
+			SelectAllOf(SelectReadersMatching(regexp.MustCompile(`^ACS `)), SelectReaderByIndex(1)),
+			[]string{readers[2].Name},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range tt.sel(readers) {
+				got = append(got, r.Name)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("selected %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cardreader
+
+import (
+	"errors"
+	"sync"
+)
+
+// Errors of invalid selection transitions.
+var (
+	ErrAlreadySelected  = errors.New("reader already selected")
+	ErrNotSelected      = errors.New("reader not selected")
+	ErrNoSelectionState = errors.New("reader has no selection state")
+)
+
+// SelectionFunc observes a selection change of a reader before it takes
+// effect; returning an error vetoes the change.
+type SelectionFunc func(r Reader, selected bool) error
+
+// selection holds the selection state of a reader. Like lastCard it is
+// shared by all copies of a Reader created with NewReader. Readers start
+// selected.
+type selection struct {
+	mu         sync.Mutex
+	deselected bool
+	hooks      []SelectionFunc
+}
+
+// Selected reports whether the reader is selected for use. Readers not
+// created with NewReader are always selected.
+func (r Reader) Selected() bool {
+	if r.sel == nil {
+		return true
+	}
+	r.sel.mu.Lock()
+	defer r.sel.mu.Unlock()
+	return !r.sel.deselected
+}
+
+// Select puts a deselected reader back into use. It returns
+// ErrAlreadySelected for a selected reader.
+func (r *Reader) Select() error { return r.setSelected(true) }
+
+// Deselect takes the reader out of use, so the SDK ignores it until it is
+// selected again. It returns ErrNotSelected for a deselected reader.
+func (r *Reader) Deselect() error { return r.setSelected(false) }
+
+// OnSelectionChange registers fn to be called on every selection change of
+// the reader, before it takes effect. fn must not change the selection of
+// the reader itself.
+func (r *Reader) OnSelectionChange(fn SelectionFunc) {
+	if r.sel == nil || fn == nil {
+		return
+	}
+	r.sel.mu.Lock()
+	defer r.sel.mu.Unlock()
+	r.sel.hooks = append(r.sel.hooks, fn)
+}
+
+func (r *Reader) setSelected(selected bool) error {
+	if r.sel == nil {
+		return ErrNoSelectionState
+	}
+	r.sel.mu.Lock()
+	defer r.sel.mu.Unlock()
+	switch {
+	case selected && !r.sel.deselected:
+		return ErrAlreadySelected
+	case !selected && r.sel.deselected:
+		return ErrNotSelected
+	}
+	for _, fn := range r.sel.hooks {
+		if err := fn(*r, selected); err != nil {
+			return err
+		}
+	}
+	r.sel.deselected = !selected
+	return nil
+}
+
+// SelectSelected selects the readers which are not deselected.
+func SelectSelected() ReaderSelectFunc {
+	return func(readers []Reader) []Reader {
+		var selected []Reader
+		for _, r := range readers {
+			if r.Selected() {
+				selected = append(selected, r)
+			}
+		}
+		return selected
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cardreader
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReaderSelection(t *testing.T) {
+	r := NewReader("ACS ACR122U PICC Interface 00")
+	var changes []bool
+	veto := errors.New("veto")
+	vetoing := false
+	r.OnSelectionChange(func(got Reader, selected bool) error {
+		if got.Name != r.Name {
+			t.Errorf("hook got reader %q", got.Name)
+		}
+		if vetoing {
+			return veto
+		}
+		changes = append(changes, selected)
+		return nil
+	})
+
+	copied := *r
+	steps := []struct {
+		op       func() error
+		err      error
+		selected bool
+	}{
+		{r.Select, ErrAlreadySelected, true},
+		{r.Deselect, nil, false},
+		{copied.Deselect, ErrNotSelected, false},
+		{copied.Select, nil, true},
+	}
+	if !r.Selected() {
+		t.Fatal("new reader is not selected")
+	}
+	for i, s := range steps {
+		if err := s.op(); !errors.Is(err, s.err) {
+			t.Errorf("step %d: error = %v, want %v", i, err, s.err)
+		}
+		if r.Selected() != s.selected || copied.Selected() != s.selected {
+			t.Errorf("step %d: Selected() = %v, copy %v; want %v", i, r.Selected(), copied.Selected(), s.selected)
+		}
+	}
+	if len(changes) != 2 || changes[0] || !changes[1] {
+		t.Errorf("hook saw changes %v, want [false true]", changes)
+	}
+
+	vetoing = true
+	if err := r.Deselect(); !errors.Is(err, veto) || !r.Selected() {
+		t.Errorf("vetoed Deselect() error = %v, Selected() = %v", err, r.Selected())
+	}
+
+	plain := Reader{Name: "plain"}
+	if err := plain.Deselect(); !errors.Is(err, ErrNoSelectionState) || !plain.Selected() {
+		t.Errorf("Deselect() of a reader without state error = %v", err)
+	}
+}
+
+func TestSelectSelected(t *testing.T) {
+	a, b := NewReader("a"), NewReader("b")
+	if err := a.Deselect(); err != nil {
+		t.Fatal(err)
+	}
+	got := SelectSelected()([]Reader{*a, *b, {Name: "c"}})
+	if len(got) != 2 || got[0].Name != "b" || got[1].Name != "c" {
+		t.Errorf("SelectSelected() = %v, want [b c]", got)
+	}
+}
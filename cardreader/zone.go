@@ -0,0 +1,108 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cardreader
+
+import (
+	"errors"
+	"sync"
+)
+
+// ZoneHandler handles events originating from the readers of a zone.
+type ZoneHandler func(ev Event) error
+
+// Zones groups readers into named scan zones such as "entrance", "exit" or
+// "desk-3". Every dispatched event is tagged with the zone of its reader and
+// handed to the handlers registered for that zone. The zero value is an
+// empty registry ready to use. Zones is safe for concurrent use.
+type Zones struct {
+	mu       sync.RWMutex
+	zones    map[string]string // reader name to zone name
+	handlers map[string][]ZoneHandler
+}
+
+// NewZones returns an empty zone registry.
+func NewZones() *Zones {
+	return &Zones{
+		zones:    make(map[string]string),
+		handlers: make(map[string][]ZoneHandler),
+	}
+}
+
+// Assign adds readers to the named zone. A reader belongs to at most one
+// zone, assigning it again moves it to the new zone.
+func (z *Zones) Assign(zone string, readers ...string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.zones == nil {
+		z.zones = make(map[string]string)
+	}
+	for _, r := range readers {
+		z.zones[r] = zone
+	}
+}
+
+// Unassign removes readers from whichever zone they belong to.
+func (z *Zones) Unassign(readers ...string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	for _, r := range readers {
+		delete(z.zones, r)
+	}
+}
+
+// Zone returns the zone the reader is assigned to.
+func (z *Zones) Zone(reader string) (string, bool) {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	zone, ok := z.zones[reader]
+	return zone, ok
+}
+
+// Readers returns the names of the readers assigned to the zone.
+func (z *Zones) Readers(zone string) []string {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	var readers []string
+	for r, zn := range z.zones {
+		if zn == zone {
+			readers = append(readers, r)
+		}
+	}
+	return readers
+}
+
+// Handle registers a handler for events of the named zone. Handlers are
+// called in registration order.
+func (z *Zones) Handle(zone string, h ZoneHandler) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.handlers == nil {
+		z.handlers = make(map[string][]ZoneHandler)
+	}
+	z.handlers[zone] = append(z.handlers[zone], h)
+}
+
+// Dispatch sets the zone of the event from its reader and calls the handlers
+// registered for that zone. All handlers are called, errors they return are
+// joined. Events from unassigned readers are returned unchanged without
+// calling any handler.
+func (z *Zones) Dispatch(ev Event) (Event, error) {
+	z.mu.RLock()
+	zone, ok := z.zones[ev.Reader]
+	handlers := z.handlers[zone]
+	z.mu.RUnlock()
+	if !ok {
+		return ev, nil
+	}
+
+	ev.Zone = zone
+	var errs []error
+	for _, h := range handlers {
+		if err := h(ev); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return ev, errors.Join(errs...)
+}
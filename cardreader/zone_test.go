@@ -0,0 +1,88 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cardreader
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestZonesDispatch(t *testing.T) {
+	zones := NewZones()
+	zones.Assign("entrance", "ACS ACR122U PICC Interface 00")
+	zones.Assign("exit", "ACS ACR122U PICC Interface 01")
+
+	var got []string
+	zones.Handle("entrance", func(ev Event) error {
+		got = append(got, "entrance:"+ev.Zone)
+		return nil
+	})
+	errExit := errors.New("exit handler failed")
+	zones.Handle("exit", func(ev Event) error {
+		got = append(got, "exit:"+ev.Zone)
+		return errExit
+	})
+
+	ev, err := zones.Dispatch(Event{Type: EventCardInserted, Reader: "ACS ACR122U PICC Interface 00"})
+	if err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+	if ev.Zone != "entrance" {
+		t.Errorf("Dispatch() zone = %q, want %q", ev.Zone, "entrance")
+	}
+
+	if _, err := zones.Dispatch(Event{Reader: "ACS ACR122U PICC Interface 01"}); !errors.Is(err, errExit) {
+		t.Errorf("Dispatch() error = %v, want %v", err, errExit)
+	}
+
+	ev, err = zones.Dispatch(Event{Reader: "unknown"})
+	if err != nil || ev.Zone != "" {
+		t.Errorf("Dispatch() unassigned = %q, %v; want empty zone and no error", ev.Zone, err)
+	}
+
+	want := []string{"entrance:entrance", "exit:exit"}
+	if len(got) != len(want) {
+		t.Fatalf("handlers called %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("handler call %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestZonesAssignMovesReader(t *testing.T) {
+	zones := NewZones()
+	zones.Assign("desk-1", "reader")
+	zones.Assign("desk-3", "reader")
+
+	if zone, ok := zones.Zone("reader"); !ok || zone != "desk-3" {
+		t.Errorf("Zone() = %q, %v; want %q, true", zone, ok, "desk-3")
+	}
+	if readers := zones.Readers("desk-1"); len(readers) != 0 {
+		t.Errorf("Readers(desk-1) = %v, want none", readers)
+	}
+
+	zones.Unassign("reader")
+	if _, ok := zones.Zone("reader"); ok {
+		t.Error("Zone() after Unassign reported an assignment")
+	}
+}
+
+func TestZonesZeroValue(t *testing.T) {
+	var zones Zones
+	if _, err := zones.Dispatch(Event{Reader: "reader"}); err != nil {
+		t.Fatalf("Dispatch() on empty registry error = %v", err)
+	}
+	called := false
+	zones.Handle("desk-3", func(ev Event) error {
+		called = ev.Zone == "desk-3"
+		return nil
+	})
+	zones.Assign("desk-3", "reader")
+	if _, err := zones.Dispatch(Event{Reader: "reader"}); err != nil || !called {
+		t.Errorf("Dispatch() = %v, handler called %v; want nil, true", err, called)
+	}
+}
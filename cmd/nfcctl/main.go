@@ -0,0 +1,293 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Command nfcctl validates reader setups and serves as a reference use of
+// the SDK.
+//
+// Usage:
+//
+//	nfcctl [-reader regexp] [-timeout 30s] [-v] <command> [arguments]
+//
+// The commands are:
+//
+//	readers            list the readers and their state
+//	wait               wait for a tag and print its reader, UID, ATR and type
+//	ndef               print the NDEF message of a tag
+//	write-url URL      write a URI record to a tag
+//	write-text TEXT    write a text record to a tag, -lang sets the language
+//	pages [-n count]   dump the pages of an Ultralight or NTAG tag
+//	apdu HEX...        send command APDUs to a card and print the responses
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/happy-sdk/scardkit"
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "nfcctl:", err)
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("usage: nfcctl [-reader regexp] [-timeout 30s] [-v] readers|wait|ndef|write-url|write-text|pages|apdu [arguments]")
+
+// run runs the command line args. opts are added to the options of the SDK,
+// e.g. to use another backend.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer, opts ...scardkit.Option) error {
+	fs := flag.NewFlagSet("nfcctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	readerRE := fs.String("reader", "", "use the readers whose name matches `regexp`")
+	timeout := fs.Duration("timeout", 30*time.Second, "time to wait for a tag, zero waits forever")
+	verbose := fs.Bool("v", false, "log card sessions and APDUs to stderr")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errUsage
+	}
+
+	c := &cli{stdout: stdout, timeout: *timeout}
+	if *verbose {
+		c.logger = slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		opts = append([]scardkit.Option{scardkit.WithLogger(c.logger)}, opts...)
+	}
+	c.opts = opts
+	if *readerRE != "" {
+		re, err := regexp.Compile(*readerRE)
+		if err != nil {
+			return fmt.Errorf("reader: %w", err)
+		}
+		c.selectReaders = cardreader.SelectReadersMatching(re)
+	}
+
+	cmd, cargs := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "readers":
+		return c.readers()
+	case "wait":
+		return c.withCard(ctx, func(ev cardreader.Event, card transport.Card) error {
+			fmt.Fprintf(stdout, "reader %s\nuid    %X\natr    %X\ntype   %s\n", ev.Reader, ev.UID, ev.ATR, tag.Detect(tag.Signature{ATR: ev.ATR}))
+			return nil
+		})
+	case "ndef":
+		return c.ndef(ctx)
+	case "write-url":
+		if len(cargs) != 1 {
+			return fmt.Errorf("usage: nfcctl write-url URL")
+		}
+		r, err := ndef.NewURL(cargs[0])
+		if err != nil {
+			return err
+		}
+		return c.write(ctx, r)
+	case "write-text":
+		wfs := flag.NewFlagSet("write-text", flag.ContinueOnError)
+		wfs.SetOutput(stderr)
+		lang := wfs.String("lang", "en", "language `code` of the text")
+		if err := wfs.Parse(cargs); err != nil {
+			return err
+		}
+		r, err := ndef.NewTextRecord(*lang, strings.Join(wfs.Args(), " "))
+		if err != nil {
+			return err
+		}
+		return c.write(ctx, r)
+	case "pages":
+		pfs := flag.NewFlagSet("pages", flag.ContinueOnError)
+		pfs.SetOutput(stderr)
+		n := pfs.Int("n", 0, "number of pages to dump, zero for the size in the capability container")
+		if err := pfs.Parse(cargs); err != nil {
+			return err
+		}
+		return c.withCard(ctx, func(_ cardreader.Event, card transport.Card) error {
+			return dumpPages(stdout, card, *n)
+		})
+	case "apdu":
+		if len(cargs) == 0 {
+			return fmt.Errorf("usage: nfcctl apdu HEX...")
+		}
+		cmds := make([][]byte, len(cargs))
+		for i, a := range cargs {
+			b, err := hex.DecodeString(strings.NewReplacer(" ", "", ":", "").Replace(a))
+			if err != nil {
+				return fmt.Errorf("apdu %q: %w", a, err)
+			}
+			cmds[i] = b
+		}
+		return c.withCard(ctx, func(_ cardreader.Event, card transport.Card) error {
+			var tr apdu.Transceiver = card
+			if c.logger != nil {
+				tr = apdu.Wrap(card, apdu.RedactedLogging(c.logger, slog.LevelDebug, nil))
+			}
+			for _, cmd := range cmds {
+				resp, err := tr.Transmit(cmd)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(stdout, "> %X\n< %X\n", cmd, resp)
+			}
+			return nil
+		})
+	default:
+		return fmt.Errorf("unknown command %q\n%w", cmd, errUsage)
+	}
+}
+
+// cli holds the settings shared by the commands.
+type cli struct {
+	stdout        io.Writer
+	timeout       time.Duration
+	logger        *slog.Logger
+	opts          []scardkit.Option
+	selectReaders cardreader.ReaderSelectFunc
+}
+
+func (c *cli) sdk(opts ...scardkit.Option) *scardkit.SDK {
+	sdk := scardkit.New(append(append([]scardkit.Option(nil), c.opts...), opts...)...)
+	if c.selectReaders != nil {
+		sdk.SetReaderSelect(c.selectReaders)
+	}
+	return sdk
+}
+
+func (c *cli) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(ctx, c.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (c *cli) readers() error {
+	infos, err := c.sdk().Readers()
+	if err != nil {
+		return err
+	}
+	for i, r := range infos {
+		fmt.Fprintf(c.stdout, "%d: %s", i, r.Name)
+		if r.State != 0 {
+			fmt.Fprintf(c.stdout, " [%s]", r.State)
+		}
+		if len(r.ATR) > 0 {
+			fmt.Fprintf(c.stdout, " atr %X", r.ATR)
+		}
+		fmt.Fprintln(c.stdout)
+	}
+	if len(infos) == 0 {
+		fmt.Fprintln(c.stdout, "no readers")
+	}
+	return nil
+}
+
+// withCard waits for a card and runs fn on it.
+func (c *cli) withCard(ctx context.Context, fn func(ev cardreader.Event, card transport.Card) error) error {
+	sdk := c.sdk(scardkit.WithCardHandler(func(_ context.Context, ev cardreader.Event, card transport.Card) error {
+		return fn(ev, card)
+	}))
+	_, err := sdk.WaitForCard(ctx, c.timeout)
+	return err
+}
+
+func (c *cli) ndef(ctx context.Context) error {
+	ctx, cancel := c.context(ctx)
+	defer cancel()
+	msg, err := c.sdk().ReadNDEF(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if len(msg.Records) == 0 {
+		fmt.Fprintln(c.stdout, "empty ndef message")
+	}
+	for i, r := range msg.Records {
+		fmt.Fprintf(c.stdout, "%d: %s\n", i, describe(r))
+	}
+	return nil
+}
+
+func (c *cli) write(ctx context.Context, r *ndef.Record) error {
+	ctx, cancel := c.context(ctx)
+	defer cancel()
+	if err := c.sdk().WriteNDEF(ctx, ndef.NewMessage(r), nil); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "written:", describe(r))
+	return nil
+}
+
+// describe returns a one line description of r.
+func describe(r *ndef.Record) string {
+	if uri, err := r.URI(); err == nil {
+		return "uri " + uri
+	}
+	if text, lang, err := r.Text(); err == nil {
+		return fmt.Sprintf("text [%s] %s", lang, text)
+	}
+	if mt, err := r.MIMEType(); err == nil {
+		return fmt.Sprintf("%s %d bytes", mt, len(r.Payload))
+	}
+	return fmt.Sprintf("tnf %d type %q payload %X", r.TNF, r.Type, r.Payload)
+}
+
+// dumpPages prints n pages of a Type 2 tag, or as many as its capability
+// container announces when n is zero.
+func dumpPages(w io.Writer, card transport.Card, n int) error {
+	read := func(page int) ([]byte, error) {
+		resp, err := card.Transmit([]byte{0xFF, 0xB0, 0x00, byte(page), 0x04})
+		if err != nil {
+			return nil, err
+		}
+		if err := apdu.CheckStatusFromData(resp); err != nil {
+			return nil, fmt.Errorf("read page %d: %w", page, err)
+		}
+		if len(resp) < 6 {
+			return nil, fmt.Errorf("read page %d: short response", page)
+		}
+		return resp[:4], nil
+	}
+	if n <= 0 {
+		cc, err := read(3)
+		if err != nil {
+			return err
+		}
+		n = 4 + int(cc[2])*2 // Header pages and the data area of 8 byte units.
+	}
+	for page := 0; page < n && page <= 0xFF; page++ {
+		p, err := read(page)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%3d: % X  %s\n", page, p, printable(p))
+	}
+	return nil
+}
+
+func printable(b []byte) string {
+	out := append([]byte(nil), b...)
+	for i, c := range out {
+		if c < 0x20 || c > 0x7E {
+			out[i] = '.'
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/happy-sdk/scardkit"
+	"github.com/happy-sdk/scardkit/x/virtualreader"
+)
+
+func TestRun(t *testing.T) {
+	vr := virtualreader.New("Virtual Reader 00", "Other Reader 01")
+	ntag := virtualreader.NewNTAG215([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	tests := []struct {
+		args []string
+		want string // Substring of the output.
+	}{
+		{[]string{"readers"}, "1: Other Reader 01"},
+		{[]string{"-reader", "^Virtual", "wait"}, "uid    04112233445566"},
+		{[]string{"write-url", "https://Example.com/x"}, "written: uri https://example.com/x"},
+		{[]string{"ndef"}, "0: uri https://example.com/x"},
+		{[]string{"write-text", "-lang", "de", "hallo", "welt"}, "written: text [de] hallo welt"},
+		{[]string{"ndef"}, "0: text [de] hallo welt"},
+		{[]string{"pages"}, "  3: E1 10 3E 00  ..>."},
+		{[]string{"apdu", "FFCA000000"}, "< 041122334455669000"},
+	}
+	for _, tt := range tests {
+		if err := vr.Insert("Virtual Reader 00", ntag); err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		if err := run(context.Background(), append([]string{"-timeout", "1s"}, tt.args...), &out, io.Discard, scardkit.WithBackend(vr)); err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+			continue
+		}
+		if !strings.Contains(out.String(), tt.want) {
+			t.Errorf("%v: output %q lacks %q", tt.args, out.String(), tt.want)
+		}
+	}
+
+	for _, args := range [][]string{nil, {"frobnicate"}, {"apdu", "zz"}, {"write-url"}} {
+		if err := run(context.Background(), args, io.Discard, io.Discard, scardkit.WithBackend(vr)); err == nil {
+			t.Errorf("%v: no error", args)
+		}
+	}
+}
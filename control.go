@@ -0,0 +1,60 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"errors"
+	"fmt"
+
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+)
+
+// ErrDirectUnsupported is returned by ControlReader when the backend cannot
+// connect to readers in direct mode.
+var ErrDirectUnsupported = errors.New("backend does not support direct reader connections")
+
+// directConnector is implemented by backends connecting to readers in
+// direct mode.
+type directConnector interface {
+	connectDirect(reader string) (*pcsc.Card, error)
+}
+
+func (*pcscBackend) connectDirect(reader string) (*pcsc.Card, error) {
+	return pcsc.ConnectDirect(reader)
+}
+
+// ControlReader connects to the reader name in direct mode, with or without
+// a card present, and runs fn with the connection, e.g. to send escape
+// commands setting the LEDs, buzzer or polling configuration at startup. The
+// reader is not waited on for cards while fn runs, and it fails when the
+// reader has a card session in flight.
+func (sdk *SDK) ControlReader(name string, fn func(c *pcsc.Card) error) (err error) {
+	dc, ok := sdk.backend.(directConnector)
+	if !ok {
+		return ErrDirectUnsupported
+	}
+	sdk.mu.Lock()
+	if sdk.busy[name] {
+		sdk.mu.Unlock()
+		return fmt.Errorf("reader %s has a card session in flight", name)
+	}
+	if sdk.busy == nil {
+		sdk.busy = make(map[string]bool)
+	}
+	sdk.busy[name] = true
+	sdk.mu.Unlock()
+	defer sdk.setBusy(name, false)
+
+	c, err := dc.connectDirect(name)
+	if err != nil {
+		return fmt.Errorf("connect %s: %w", name, err)
+	}
+	defer func() {
+		if derr := c.Disconnect(); derr != nil && err == nil {
+			err = derr
+		}
+	}()
+	return fn(c)
+}
@@ -0,0 +1,46 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+)
+
+func TestControlReader(t *testing.T) {
+	sdk := New()
+	var mode pcsc.ShareMode
+	err := sdk.ControlReader("reader", func(c *pcsc.Card) error {
+		mode = c.ShareMode()
+		if idle := sdk.idleReaders([]cardreader.Reader{{Name: "reader"}}); len(idle) != 0 {
+			t.Error("reader is waited on during its control session")
+		}
+		return nil
+	})
+	if err != nil || mode != pcsc.ShareDirect {
+		t.Errorf("ControlReader() share mode %v, error %v", mode, err)
+	}
+	if idle := sdk.idleReaders([]cardreader.Reader{{Name: "reader"}}); len(idle) != 1 {
+		t.Error("reader still busy after its control session")
+	}
+
+	fail := errors.New("escape failed")
+	if err := sdk.ControlReader("reader", func(*pcsc.Card) error { return fail }); err != fail {
+		t.Errorf("ControlReader() error = %v, want %v", err, fail)
+	}
+
+	sdk.setBusy("reader", true)
+	if err := sdk.ControlReader("reader", func(*pcsc.Card) error { return nil }); err == nil {
+		t.Error("ControlReader() on a reader with a card session succeeded")
+	}
+
+	b := &fakeBackend{reader: cardreader.Reader{Name: "virtual"}}
+	if err := New(WithBackend(b)).ControlReader("virtual", func(*pcsc.Card) error { return nil }); !errors.Is(err, ErrDirectUnsupported) {
+		t.Errorf("ControlReader() error = %v, want %v", err, ErrDirectUnsupported)
+	}
+}
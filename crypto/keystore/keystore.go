@@ -0,0 +1,200 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package keystore supplies card authentication keys to the drivers, so
+// keys are kept in a file, the environment or an HSM instead of the
+// application code. A Token runs the operations with keys which never leave
+// it; PKCS11 adapts a PKCS#11 session provided by the application. Keys are
+// identified by an application defined id, such as "gp/isd/enc", and a key
+// version, zero when the card has no versions.
+package keystore
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/happy-sdk/scardkit/crypto/securemem"
+)
+
+// ErrKeyNotFound is returned by providers without the requested key.
+var ErrKeyNotFound = errors.New("keystore: key not found")
+
+// ErrNotExtractable is returned by providers holding keys which never leave
+// them, such as an HSM.
+var ErrNotExtractable = errors.New("keystore: key is not extractable")
+
+// Provider supplies keys. GetKey returns a copy of the key id of the given
+// version, which the caller may zeroize once done, or an error wrapping
+// ErrKeyNotFound.
+type Provider interface {
+	GetKey(id string, version int) ([]byte, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface.
+type ProviderFunc func(id string, version int) ([]byte, error)
+
+// GetKey calls f(id, version).
+func (f ProviderFunc) GetKey(id string, version int) ([]byte, error) { return f(id, version) }
+
+// KeyID identifies a key of a Map.
+type KeyID struct {
+	ID      string
+	Version int
+}
+
+// Map is an in-memory provider, e.g. for tests.
+type Map map[KeyID][]byte
+
+// GetKey returns a copy of the key.
+func (m Map) GetKey(id string, version int) ([]byte, error) {
+	key, ok := m[KeyID{id, version}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s version %d", ErrKeyNotFound, id, version)
+	}
+	return append([]byte(nil), key...), nil
+}
+
+// Chain returns a provider asking providers in order, returning the first
+// key found.
+func Chain(providers ...Provider) Provider {
+	return ProviderFunc(func(id string, version int) ([]byte, error) {
+		for _, p := range providers {
+			key, err := p.GetKey(id, version)
+			if !errors.Is(err, ErrKeyNotFound) {
+				return key, err
+			}
+		}
+		return nil, fmt.Errorf("%w: %s version %d", ErrKeyNotFound, id, version)
+	})
+}
+
+// fileKey is a key in a key file.
+type fileKey struct {
+	ID      string `json:"id"`
+	Version int    `json:"version,omitempty"`
+	Key     string `json:"key"`
+}
+
+// File is a provider of keys read from a JSON key file of the form
+//
+//	{"keys": [{"id": "gp/isd/enc", "version": 1, "key": "404142...4F"}]}
+//
+// with keys in hex.
+type File struct {
+	keys Map
+}
+
+// OpenFile reads the key file at path. On Unix it refuses files readable
+// or writable by other users than the owner.
+func OpenFile(path string) (*File, error) {
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if fi.Mode().Perm()&0o077 != 0 {
+			return nil, fmt.Errorf("keystore: %s is accessible by other users (mode %v)", path, fi.Mode().Perm())
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer securemem.Zero(data)
+	return ParseFile(data)
+}
+
+// ParseFile parses the contents of a key file.
+func ParseFile(data []byte) (*File, error) {
+	var doc struct {
+		Keys []fileKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("keystore: %w", err)
+	}
+	f := &File{keys: make(Map, len(doc.Keys))}
+	for _, k := range doc.Keys {
+		key, err := hex.DecodeString(strings.ReplaceAll(k.Key, " ", ""))
+		if err != nil || len(key) == 0 {
+			return nil, fmt.Errorf("keystore: invalid key %s version %d", k.ID, k.Version)
+		}
+		f.keys[KeyID{k.ID, k.Version}] = key
+	}
+	return f, nil
+}
+
+// GetKey returns a copy of the key.
+func (f *File) GetKey(id string, version int) ([]byte, error) { return f.keys.GetKey(id, version) }
+
+// Close zeroizes the keys of the file.
+func (f *File) Close() {
+	for id, key := range f.keys {
+		securemem.Zero(key)
+		delete(f.keys, id)
+	}
+}
+
+// Env is a provider of keys in hex read from environment variables. The
+// variable of a key is Prefix followed by its id in upper case with other
+// characters than letters and digits replaced by underscores, and for
+// versions other than zero "_V" and the version: the key "gp/isd/enc"
+// version 1 is read from SCARDKIT_KEY_GP_ISD_ENC_V1 with the default prefix.
+type Env struct {
+	// Prefix of the variables, "SCARDKIT_KEY_" when empty.
+	Prefix string
+}
+
+// Var returns the name of the variable of a key.
+func (e Env) Var(id string, version int) string {
+	prefix := e.Prefix
+	if prefix == "" {
+		prefix = "SCARDKIT_KEY_"
+	}
+	name := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, id)
+	if version != 0 {
+		name += "_V" + strconv.Itoa(version)
+	}
+	return prefix + name
+}
+
+// GetKey decodes the key from its variable.
+func (e Env) GetKey(id string, version int) ([]byte, error) {
+	name := e.Var(id, version)
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s version %d", ErrKeyNotFound, id, version)
+	}
+	key, err := hex.DecodeString(v)
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("keystore: invalid key in %s", name)
+	}
+	return key, nil
+}
+
+// HSM is a provider of keys held in a hardware security module reached
+// through its PKCS#11 module. Keys stored there are not extractable, so
+// GetKey fails with ErrNotExtractable for keys the HSM holds; drivers need
+// to run their cryptography in the HSM instead, see PKCS11.
+type HSM struct {
+	Module string // Path of the PKCS#11 module.
+	Slot   uint   // Slot of the token.
+	PIN    string // User PIN of the token.
+}
+
+// GetKey fails with ErrNotExtractable.
+func (h *HSM) GetKey(id string, version int) ([]byte, error) {
+	return nil, fmt.Errorf("%w: %s version %d in %s slot %d", ErrNotExtractable, id, version, h.Module, h.Slot)
+}
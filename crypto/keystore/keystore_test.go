@@ -0,0 +1,151 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package keystore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestProviders(t *testing.T) {
+	t.Setenv("TEST_KEY_CLASSIC_SECTOR_1", "A0A1A2A3A4A5")
+	t.Setenv("TEST_KEY_GP_ISD_ENC_V2", "not hex")
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	data := []byte(`{"keys": [{"id": "gp/isd/enc", "version": 1, "key": "40 41 42 43"}, {"id": "ntag424/app", "key": "00112233"}]}`)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	mem := Map{{"mem", 3}: {0x01}}
+	p := Chain(mem, file, Env{Prefix: "TEST_KEY_"})
+
+	tests := []struct {
+		id      string
+		version int
+		want    []byte
+		err     error
+	}{
+		{"mem", 3, []byte{0x01}, nil},
+		{"gp/isd/enc", 1, []byte{0x40, 0x41, 0x42, 0x43}, nil},
+		{"ntag424/app", 0, []byte{0x00, 0x11, 0x22, 0x33}, nil},
+		{"classic/sector-1", 0, []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}, nil},
+		{"gp/isd/enc", 2, nil, nil},
+		{"gp/isd/enc", 3, nil, ErrKeyNotFound},
+	}
+	for _, tt := range tests {
+		got, err := p.GetKey(tt.id, tt.version)
+		if tt.want == nil {
+			if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Errorf("GetKey(%s, %d) error = %v, want %v", tt.id, tt.version, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("GetKey(%s, %d) = %X, %v, want %X", tt.id, tt.version, got, err, tt.want)
+		}
+	}
+
+	got, _ := file.GetKey("ntag424/app", 0)
+	got[0] = 0xFF
+	if again, _ := file.GetKey("ntag424/app", 0); again[0] != 0x00 {
+		t.Error("GetKey() returned the stored key, not a copy")
+	}
+	file.Close()
+	if _, err := file.GetKey("ntag424/app", 0); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("GetKey() after Close() error = %v", err)
+	}
+
+	if _, err := (&HSM{Module: "softhsm2.so"}).GetKey("gp/isd/enc", 1); !errors.Is(err, ErrNotExtractable) {
+		t.Errorf("HSM.GetKey() error = %v", err)
+	}
+
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(path, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := OpenFile(path); err == nil {
+			t.Error("OpenFile() accepted a world readable key file")
+		}
+	}
+}
+
+// fakePKCS11 runs the operations with the keys of a SoftToken, checking
+// the mechanisms requested.
+type fakePKCS11 struct {
+	labels []string
+	soft   Token
+	mechs  []uint
+}
+
+func (f *fakePKCS11) FindKey(label string) (uint, error) {
+	for i, l := range f.labels {
+		if l == label {
+			return uint(i), nil
+		}
+	}
+	return 0, ErrKeyNotFound
+}
+
+func (f *fakePKCS11) alg(mech uint) Algorithm {
+	f.mechs = append(f.mechs, mech)
+	if mech == MechanismDES3CBC {
+		return DES3
+	}
+	return AES
+}
+
+func (f *fakePKCS11) Encrypt(mech uint, iv []byte, key uint, data []byte) ([]byte, error) {
+	return f.soft.Encrypt(f.labels[key], 0, f.alg(mech), data)
+}
+
+func (f *fakePKCS11) Decrypt(mech uint, iv []byte, key uint, data []byte) ([]byte, error) {
+	return f.soft.Decrypt(f.labels[key], 0, f.alg(mech), data)
+}
+
+func (f *fakePKCS11) Sign(mech uint, _ []byte, key uint, data []byte) ([]byte, error) {
+	f.alg(mech)
+	return f.soft.CMAC(f.labels[key], 0, data)
+}
+
+func TestPKCS11(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 16)
+	soft := SoftToken(Map{{ID: "gp/enc#2"}: key})
+	session := &fakePKCS11{labels: []string{"gp/enc#2"}, soft: soft}
+	tok := &PKCS11{Session: session}
+	data := bytes.Repeat([]byte{0x01}, 16)
+
+	for _, alg := range []Algorithm{AES, DES3} {
+		want, _ := SoftToken(Map{{ID: "gp/enc", Version: 2}: key}).Encrypt("gp/enc", 2, alg, data)
+		got, err := tok.Encrypt("gp/enc", 2, alg, data)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%v: Encrypt() = %X, %v, want %X", alg, got, err, want)
+		}
+		if plain, err := tok.Decrypt("gp/enc", 2, alg, got); err != nil || !bytes.Equal(plain, data) {
+			t.Errorf("%v: Decrypt() = %X, %v", alg, plain, err)
+		}
+	}
+	if _, err := tok.CMAC("gp/enc", 2, data); err != nil {
+		t.Errorf("CMAC() error = %v", err)
+	}
+	want := []uint{MechanismAESCBC, MechanismAESCBC, MechanismDES3CBC, MechanismDES3CBC, MechanismAESCMAC}
+	if fmt.Sprint(session.mechs) != fmt.Sprint(want) {
+		t.Errorf("mechanisms = %X, want %X", session.mechs, want)
+	}
+	if _, err := tok.GetKey("gp/enc", 2); !errors.Is(err, ErrNotExtractable) {
+		t.Errorf("GetKey() error = %v, want ErrNotExtractable", err)
+	}
+	if _, err := tok.CMAC("gp/enc", 1, data); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("CMAC() with a missing key error = %v, want ErrKeyNotFound", err)
+	}
+}
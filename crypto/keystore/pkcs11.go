@@ -0,0 +1,119 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package keystore
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// PKCS#11 mechanisms (CKM_*) used by PKCS11.
+const (
+	MechanismDES3CBC = 0x00000133
+	MechanismAESCBC  = 0x00001082
+	MechanismAESCMAC = 0x0000108A
+)
+
+// PKCS11Session is the part of a logged in PKCS#11 session used by PKCS11.
+// This module ships no PKCS#11 binding, keeping it free of cgo; the
+// application implements the interface with an adapter of one, such as
+// github.com/miekg/pkcs11, opened on the module, slot and PIN of its HSM.
+type PKCS11Session interface {
+	// FindKey returns the handle of the secret key labelled label
+	// (CKA_LABEL), or an error wrapping ErrKeyNotFound.
+	FindKey(label string) (uint, error)
+	// Encrypt runs C_EncryptInit and C_Encrypt.
+	Encrypt(mechanism uint, param []byte, key uint, data []byte) ([]byte, error)
+	// Decrypt runs C_DecryptInit and C_Decrypt.
+	Decrypt(mechanism uint, param []byte, key uint, data []byte) ([]byte, error)
+	// Sign runs C_SignInit and C_Sign.
+	Sign(mechanism uint, param []byte, key uint, data []byte) ([]byte, error)
+}
+
+// PKCS11 is a Token running the operations in a PKCS#11 token, such as an
+// HSM, through the PKCS11Session of the application, so master keys never
+// leave it. It only maps the operations of the drivers to PKCS#11
+// mechanisms. Keys are found by label. GetKey fails with ErrNotExtractable.
+type PKCS11 struct {
+	Session PKCS11Session
+	// Label returns the label of a key. By default it is the id, followed by
+	// "#" and the version for versions other than zero.
+	Label func(id string, version int) string
+}
+
+func (p *PKCS11) key(id string, version int) (uint, error) {
+	label := id
+	if p.Label != nil {
+		label = p.Label(id, version)
+	} else if version != 0 {
+		label += "#" + strconv.Itoa(version)
+	}
+	h, err := p.Session.FindKey(label)
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("keystore: find key %s: %w", label, err)
+	}
+	return h, nil
+}
+
+// GetKey fails with ErrNotExtractable for keys of the token.
+func (p *PKCS11) GetKey(id string, version int) ([]byte, error) {
+	if _, err := p.key(id, version); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: %s version %d", ErrNotExtractable, id, version)
+}
+
+// cbc returns the CBC mechanism of alg with a zero IV.
+func cbc(alg Algorithm) (uint, []byte) {
+	if alg == DES3 {
+		return MechanismDES3CBC, make([]byte, 8)
+	}
+	return MechanismAESCBC, make([]byte, 16)
+}
+
+// Encrypt encrypts data in the token with the AES or DES3 CBC mechanism.
+func (p *PKCS11) Encrypt(id string, version int, alg Algorithm, data []byte) ([]byte, error) {
+	h, err := p.key(id, version)
+	if err != nil {
+		return nil, err
+	}
+	mech, iv := cbc(alg)
+	out, err := p.Session.Encrypt(mech, iv, h, data)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: encrypt with %s: %w", id, err)
+	}
+	return out, nil
+}
+
+// Decrypt decrypts data in the token with the AES or DES3 CBC mechanism.
+func (p *PKCS11) Decrypt(id string, version int, alg Algorithm, data []byte) ([]byte, error) {
+	h, err := p.key(id, version)
+	if err != nil {
+		return nil, err
+	}
+	mech, iv := cbc(alg)
+	out, err := p.Session.Decrypt(mech, iv, h, data)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: decrypt with %s: %w", id, err)
+	}
+	return out, nil
+}
+
+// CMAC signs msg in the token with the AES CMAC mechanism.
+func (p *PKCS11) CMAC(id string, version int, msg []byte) ([]byte, error) {
+	h, err := p.key(id, version)
+	if err != nil {
+		return nil, err
+	}
+	out, err := p.Session.Sign(MechanismAESCMAC, nil, h, msg)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: cmac with %s: %w", id, err)
+	}
+	return out, nil
+}
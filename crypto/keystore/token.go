@@ -0,0 +1,104 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/crypto"
+	"github.com/happy-sdk/scardkit/crypto/securemem"
+)
+
+// Algorithm is the block cipher of a key.
+type Algorithm int
+
+const (
+	AES  Algorithm = iota // AES-128, AES-192 or AES-256.
+	DES3                  // Two or three key triple DES.
+)
+
+// String returns the name of the algorithm.
+func (a Algorithm) String() string {
+	if a == DES3 {
+		return "3DES"
+	}
+	return "AES"
+}
+
+// Token runs the cryptographic operations of the drivers with keys which
+// stay in it, such as the master keys of an issuance system kept in an HSM.
+// The drivers derive session keys through a token, so only session keys
+// reach the host.
+type Token interface {
+	Provider
+	// Encrypt encrypts data, a multiple of the block size, with the key in
+	// CBC mode with a zero IV.
+	Encrypt(id string, version int, alg Algorithm, data []byte) ([]byte, error)
+	// Decrypt decrypts data, a multiple of the block size, with the key in
+	// CBC mode with a zero IV.
+	Decrypt(id string, version int, alg Algorithm, data []byte) ([]byte, error)
+	// CMAC returns the AES CMAC of msg with the key.
+	CMAC(id string, version int, msg []byte) ([]byte, error)
+}
+
+// SoftToken returns a token running the operations in software with the
+// keys of p, zeroizing them after each operation. It lets drivers use the
+// same code path with keys from any provider.
+func SoftToken(p Provider) Token { return softToken{p} }
+
+type softToken struct{ Provider }
+
+func (t softToken) block(id string, version int, alg Algorithm) (cipher.Block, error) {
+	key, err := t.GetKey(id, version)
+	if err != nil {
+		return nil, err
+	}
+	defer securemem.Zero(key)
+	switch {
+	case alg == AES:
+		return aes.NewCipher(key)
+	case len(key) == 16:
+		return des.NewTripleDESCipher(append(key[:16:16], key[:8]...))
+	default:
+		return des.NewTripleDESCipher(key)
+	}
+}
+
+func (t softToken) Encrypt(id string, version int, alg Algorithm, data []byte) ([]byte, error) {
+	b, err := t.block(id, version, alg)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%b.BlockSize() != 0 {
+		return nil, fmt.Errorf("keystore: data of %d bytes is not a multiple of the block size", len(data))
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(b, make([]byte, b.BlockSize())).CryptBlocks(out, data)
+	return out, nil
+}
+
+func (t softToken) Decrypt(id string, version int, alg Algorithm, data []byte) ([]byte, error) {
+	b, err := t.block(id, version, alg)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%b.BlockSize() != 0 {
+		return nil, fmt.Errorf("keystore: data of %d bytes is not a multiple of the block size", len(data))
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(b, make([]byte, b.BlockSize())).CryptBlocks(out, data)
+	return out, nil
+}
+
+func (t softToken) CMAC(id string, version int, msg []byte) ([]byte, error) {
+	b, err := t.block(id, version, AES)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.CMAC(b, msg), nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+// ErrPadding is returned by Unpad for data without valid padding.
+var ErrPadding = errors.New("invalid padding")
+
+// Pad pads data to a multiple of blockSize following ISO/IEC 9797-1 padding
+// method 2: a single 0x80 byte followed by zeros.
+func Pad(data []byte, blockSize int) []byte {
+	out := make([]byte, len(data), len(data)+blockSize)
+	copy(out, data)
+	out = append(out, 0x80)
+	for len(out)%blockSize != 0 {
+		out = append(out, 0x00)
+	}
+	return out
+}
+
+// Unpad removes ISO/IEC 9797-1 padding method 2 from data.
+func Unpad(data []byte) ([]byte, error) {
+	for i := len(data) - 1; i >= 0; i-- {
+		switch data[i] {
+		case 0x00:
+			continue
+		case 0x80:
+			return data[:i], nil
+		}
+		break
+	}
+	return nil, ErrPadding
+}
+
+// CMAC returns the CMAC of msg under b (NIST SP 800-38B, RFC 4493).
+func CMAC(b cipher.Block, msg []byte) []byte {
+	bs := b.BlockSize()
+	k1 := make([]byte, bs)
+	b.Encrypt(k1, k1)
+	k1 = shiftSubkey(k1)
+	k2 := shiftSubkey(append([]byte(nil), k1...))
+
+	n := (len(msg) + bs - 1) / bs
+	last := make([]byte, bs)
+	if n > 0 && len(msg)%bs == 0 {
+		subtle.XORBytes(last, msg[(n-1)*bs:], k1)
+	} else {
+		if n == 0 {
+			n = 1
+		}
+		copy(last, msg[(n-1)*bs:])
+		last[len(msg)-(n-1)*bs] = 0x80
+		subtle.XORBytes(last, last, k2)
+	}
+
+	x := make([]byte, bs)
+	for i := 0; i < n-1; i++ {
+		subtle.XORBytes(x, x, msg[i*bs:(i+1)*bs])
+		b.Encrypt(x, x)
+	}
+	subtle.XORBytes(x, x, last)
+	b.Encrypt(x, x)
+	return x
+}
+
+// shiftSubkey derives the next CMAC subkey from k in place.
+func shiftSubkey(k []byte) []byte {
+	msb := k[0] & 0x80
+	for i := 0; i < len(k)-1; i++ {
+		k[i] = k[i]<<1 | k[i+1]>>7
+	}
+	k[len(k)-1] <<= 1
+	if msb != 0 {
+		if len(k) == 8 {
+			k[len(k)-1] ^= 0x1B
+		} else {
+			k[len(k)-1] ^= 0x87
+		}
+	}
+	return k
+}
+
+// RetailMAC returns the ISO/IEC 9797-1 MAC algorithm 3 (retail MAC) of msg
+// with the 16 byte two-key DES key. msg must already be padded to a multiple
+// of 8 bytes, see Pad.
+func RetailMAC(key, msg []byte) ([]byte, error) {
+	return RetailMACIV(key, make([]byte, des.BlockSize), msg)
+}
+
+// RetailMACIV returns the retail MAC of msg like RetailMAC, starting from
+// the initial chaining vector iv instead of zeros.
+func RetailMACIV(key, iv, msg []byte) ([]byte, error) {
+	if len(iv) != des.BlockSize {
+		return nil, fmt.Errorf("retail mac iv must be %d bytes, got %d", des.BlockSize, len(iv))
+	}
+	if len(key) != 16 {
+		return nil, fmt.Errorf("retail mac key must be 16 bytes, got %d", len(key))
+	}
+	if len(msg) == 0 || len(msg)%des.BlockSize != 0 {
+		return nil, fmt.Errorf("retail mac message must be padded to %d bytes", des.BlockSize)
+	}
+	k1, err := des.NewCipher(key[:8])
+	if err != nil {
+		return nil, err
+	}
+	k2, err := des.NewCipher(key[8:])
+	if err != nil {
+		return nil, err
+	}
+	x := append([]byte(nil), iv...)
+	for i := 0; i < len(msg); i += des.BlockSize {
+		subtle.XORBytes(x, x, msg[i:i+des.BlockSize])
+		k1.Encrypt(x, x)
+	}
+	k2.Decrypt(x, x)
+	k1.Encrypt(x, x)
+	return x, nil
+}
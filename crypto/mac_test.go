@@ -0,0 +1,65 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestCMAC(t *testing.T) {
+	// RFC 4493 test vectors.
+	block, _ := aes.NewCipher(unhex("2b7e151628aed2a6abf7158809cf4f3c"))
+	tests := []struct {
+		msg, want string
+	}{
+		{"", "bb1d6929e95937287fa37d129b756746"},
+		{"6bc1bee22e409f96e93d7e117393172a", "070a16b46b4d4144f79bdd9dd04a287c"},
+		{"6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411", "dfa66747de9ae63030ca32611497c827"},
+	}
+	for _, tt := range tests {
+		if got := CMAC(block, unhex(tt.msg)); !bytes.Equal(got, unhex(tt.want)) {
+			t.Errorf("CMAC(%s) = %x, want %s", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestRetailMAC(t *testing.T) {
+	// ICAO Doc 9303 part 11, worked example of secure messaging.
+	key := unhex("F1CB1F1FB5ADF208806B89DC579DC1F8")
+	msg := Pad(unhex("887022120C06C2270CA4020C800000008709016375432908C044F6"), 8)
+	got, err := RetailMAC(key, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := unhex("BF8B92D635FF24F8"); !bytes.Equal(got, want) {
+		t.Errorf("RetailMAC() = %X, want %X", got, want)
+	}
+}
+
+func TestPad(t *testing.T) {
+	for _, data := range [][]byte{{}, {1, 2, 3}, bytes.Repeat([]byte{0x80}, 8)} {
+		padded := Pad(data, 8)
+		if len(padded)%8 != 0 || len(padded) <= len(data) {
+			t.Errorf("Pad(% X) = % X", data, padded)
+		}
+		if got, err := Unpad(padded); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("Unpad(% X) = % X, %v", padded, got, err)
+		}
+	}
+	if _, err := Unpad([]byte{1, 0, 0}); err != ErrPadding {
+		t.Errorf("Unpad() without padding error = %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package securemem helps handling key material and other sensitive buffers:
+// zeroizing them once a session ends and keeping them out of logs.
+//
+// Go may copy memory behind the scenes, e.g. when growing a slice or moving
+// a stack, and cipher.Block values keep their own key schedule, so zeroizing
+// limits the lifetime of sensitive data in memory rather than guaranteeing
+// no copy remains.
+package securemem
+
+import (
+	"log/slog"
+	"runtime"
+)
+
+// Redacted replaces sensitive values in formatted output and logs.
+const Redacted = "[redacted]"
+
+// Zero overwrites the buffers bufs with zeros.
+func Zero(bufs ...[]byte) {
+	for _, b := range bufs {
+		for i := range b {
+			b[i] = 0
+		}
+		// Keep the writes from being optimized away as dead stores.
+		runtime.KeepAlive(b)
+	}
+}
+
+// Bytes is a sensitive buffer, such as a session key. It formats and logs
+// as Redacted, whatever the verb, so it cannot leak through fmt or slog.
+type Bytes []byte
+
+// Clone returns a copy of b as Bytes.
+func Clone(b []byte) Bytes {
+	if b == nil {
+		return nil
+	}
+	return append(Bytes(nil), b...)
+}
+
+// Zero overwrites b with zeros.
+func (b Bytes) Zero() { Zero(b) }
+
+// String returns Redacted.
+func (b Bytes) String() string { return Redacted }
+
+// GoString returns Redacted, for the %#v verb.
+func (b Bytes) GoString() string { return Redacted }
+
+// LogValue returns Redacted as slog value.
+func (b Bytes) LogValue() slog.Value { return slog.StringValue(Redacted) }
@@ -0,0 +1,39 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package securemem
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestZero(t *testing.T) {
+	a, b := []byte{1, 2, 3}, Clone([]byte{4, 5})
+	Zero(a, nil)
+	b.Zero()
+	if !bytes.Equal(a, make([]byte, 3)) || !bytes.Equal(b, make([]byte, 2)) {
+		t.Errorf("buffers after Zero() = %X, %X", a, []byte(b))
+	}
+	if Clone(nil) != nil {
+		t.Error("Clone(nil) != nil")
+	}
+}
+
+func TestBytesRedacted(t *testing.T) {
+	key := Bytes{0xDE, 0xAD, 0xBE, 0xEF}
+	for _, verb := range []string{"%v", "%s", "%x", "%X", "% X", "%#v", "%q"} {
+		if got := fmt.Sprintf(verb, key); strings.Contains(strings.ToUpper(got), "DEAD") {
+			t.Errorf("Sprintf(%q) = %s", verb, got)
+		}
+	}
+	var logs bytes.Buffer
+	slog.New(slog.NewTextHandler(&logs, nil)).Info("session", "key", key)
+	if !strings.Contains(logs.String(), "key="+Redacted) {
+		t.Errorf("log = %s", logs.String())
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+)
+
+// WithTapDebounce makes Run ignore a card seen on a reader again within d of
+// the last time the same UID was seen there, e.g. because of a bouncy field
+// or a card taken away and put back right away. Every sighting restarts the
+// window, so with backends reporting a card left on a reader repeatedly the
+// card is handled once. Cards without a UID are never suppressed. See
+// Rearm to handle a card left on a reader again.
+func WithTapDebounce(d time.Duration) Option {
+	return func(sdk *SDK) {
+		sdk.tapDebounce = d
+	}
+}
+
+// tap is the last card seen on a reader.
+type tap struct {
+	uid  []byte
+	time time.Time
+}
+
+// bounced records ev as the last card seen on its reader and reports
+// whether the same card was seen there within the tap debounce.
+func (sdk *SDK) bounced(ev cardreader.Event) bool {
+	if sdk.tapDebounce <= 0 || len(ev.UID) == 0 {
+		return false
+	}
+	sdk.mu.Lock()
+	defer sdk.mu.Unlock()
+	if sdk.lastTaps == nil {
+		sdk.lastTaps = make(map[string]tap)
+	}
+	last, ok := sdk.lastTaps[ev.Reader]
+	sdk.lastTaps[ev.Reader] = tap{uid: ev.UID, time: ev.Time}
+	return ok && bytes.Equal(last.uid, ev.UID) && ev.Time.Sub(last.time) < sdk.tapDebounce
+}
+
+// rearmer is implemented by backends reporting a card left on a reader
+// once, see Rearm.
+type rearmer interface {
+	rearm(readers ...string)
+}
+
+// Rearm makes the next wait report the card left on the readers named, all
+// readers when none is named, again, as if it was just tapped, and clears
+// their tap debounce. By default a card left on a reader is reported once,
+// so e.g. WriteNDEF after ReadNDEF waits for the card to be tapped again
+// unless Rearm is called in between.
+func (sdk *SDK) Rearm(readers ...string) {
+	if r, ok := sdk.backend.(rearmer); ok {
+		r.rearm(readers...)
+	}
+	sdk.mu.Lock()
+	defer sdk.mu.Unlock()
+	if len(readers) == 0 {
+		clear(sdk.lastTaps)
+	}
+	for _, name := range readers {
+		delete(sdk.lastTaps, name)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+func TestBounced(t *testing.T) {
+	sdk := New(WithTapDebounce(time.Second))
+	start := time.Now()
+	tests := []struct {
+		reader string
+		uid    []byte
+		after  time.Duration
+		want   bool
+	}{
+		{"r0", []byte{1}, 0, false},
+		{"r0", []byte{1}, 500 * time.Millisecond, true},
+		{"r0", []byte{1}, 1400 * time.Millisecond, true}, // Window restarted at 500 ms.
+		{"r1", []byte{1}, 1500 * time.Millisecond, false},
+		{"r0", []byte{2}, 1600 * time.Millisecond, false},
+		{"r0", []byte{1}, 1700 * time.Millisecond, false},
+		{"r0", nil, 1800 * time.Millisecond, false},
+		{"r0", []byte{1}, 3000 * time.Millisecond, false},
+	}
+	for i, tt := range tests {
+		ev := cardreader.Event{Reader: tt.reader, UID: tt.uid, Time: start.Add(tt.after)}
+		if got := sdk.bounced(ev); got != tt.want {
+			t.Errorf("%d: bounced(%s, %X) = %v, want %v", i, tt.reader, tt.uid, got, tt.want)
+		}
+	}
+}
+
+func TestRunTapDebounce(t *testing.T) {
+	b := &fakeBackend{reader: cardreader.Reader{Name: "virtual"}, card: &memCard{}}
+	var calls atomic.Int32
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	sdk := New(WithBackend(b), WithTapDebounce(time.Hour), WithCardHandler(func(context.Context, cardreader.Event, transport.Card) error {
+		calls.Add(1)
+		return nil
+	}))
+	if err := sdk.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler called %d times for a card left on the reader, want 1", n)
+	}
+}
+
+func TestCardInserted(t *testing.T) {
+	present := pcsc.StatePresent | 3<<16
+	tests := []struct {
+		name      string
+		prev, cur pcsc.State
+		want      bool
+	}{
+		{"first sight", pcsc.StateUnaware, present, true},
+		{"left in reader", present, present, false},
+		{"reinserted", present, pcsc.StatePresent | 5<<16, true},
+		{"after empty", pcsc.StateEmpty | 2<<16, present, true},
+		{"mute", pcsc.StateUnaware, present | pcsc.StateMute, false},
+		{"empty", present, pcsc.StateEmpty | 4<<16, false},
+	}
+	for _, tt := range tests {
+		if got := cardInserted(tt.prev, tt.cur); got != tt.want {
+			t.Errorf("%s: cardInserted() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRearm(t *testing.T) {
+	present := pcsc.StatePresent | 3<<16
+	sdk := New(WithTapDebounce(time.Hour))
+	b := sdk.backend.(*pcscBackend)
+	b.remember([]pcsc.ReaderState{
+		{Reader: "a", CurrentState: pcsc.StateEmpty, EventState: present},
+		{Reader: "b", CurrentState: pcsc.StateEmpty, EventState: present},
+	}, 0)
+	ev := cardreader.Event{Reader: "a", UID: []byte{1}, Time: time.Now()}
+	sdk.bounced(ev)
+
+	sdk.Rearm("a")
+	if !cardInserted(b.known["a"], present) {
+		t.Error("card left on a not reported after Rearm(a)")
+	}
+	if sdk.bounced(ev) {
+		t.Error("card on a debounced after Rearm(a)")
+	}
+	if _, ok := b.known["b"]; !ok {
+		t.Error("Rearm(a) forgot b")
+	}
+	sdk.Rearm()
+	if len(b.known) != 0 || len(sdk.lastTaps) != 0 {
+		t.Errorf("Rearm() kept %v, %v", b.known, sdk.lastTaps)
+	}
+}
@@ -31,7 +31,12 @@ type Transaction struct {
 
 // Card represents an EMV card with its relevant data.
 type Card struct {
-	// ... Fields like card number, expiration date, etc. ...
+	AID            []byte // Application read.
+	Label          string // Application label, e.g. "VISA DEBIT".
+	PAN            string // Primary account number.
+	Expiry         string // Expiry date as YYMM.
+	ServiceCode    string // Service code from track 2, if present.
+	CardholderName string // Cardholder name, often empty on contactless cards.
 }
 
 // NewTransaction creates a new EMV transaction.
@@ -49,11 +54,35 @@ func ProcessTransaction(t *Transaction) (string, error) { return "", nil }
 // ReadCard extracts card data from an EMV card.
 func ReadCard(reader io.Reader) (*Card, error) { return nil, nil }
 
-// ValidateCard checks the validity of an EMV card.
-func ValidateCard(card *Card) bool { return false }
+// ValidateCard checks the validity of an EMV card: its PAN must consist of
+// 12 to 19 digits passing the Luhn check.
+func ValidateCard(card *Card) bool {
+	if card == nil || len(card.PAN) < 12 || len(card.PAN) > 19 {
+		return false
+	}
+	return CalculateLuhnChecksum(card.PAN) == 0
+}
 
 // CalculateLuhnChecksum calculates the Luhn checksum for card validation.
-func CalculateLuhnChecksum(number string) int { return 0 }
+// It returns the Luhn sum of number modulo 10, which is zero for numbers
+// ending in a valid check digit, or -1 when number contains non-digits.
+func CalculateLuhnChecksum(number string) int {
+	sum := 0
+	for i := 0; i < len(number); i++ {
+		c := number[len(number)-1-i]
+		if c < '0' || c > '9' {
+			return -1
+		}
+		d := int(c - '0')
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum % 10
+}
 
 // VerifyTransactionSignature verifies the digital signature of a transaction.
 func VerifyTransactionSignature(t *Transaction, signature []byte) bool { return false }
@@ -0,0 +1,286 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package emv
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// Directory names of the payment system environments.
+var (
+	PSE  = []byte("1PAY.SYS.DDF01") // Contact payment system environment.
+	PPSE = []byte("2PAY.SYS.DDF01") // Proximity payment system environment.
+)
+
+// ErrNoApplications is returned when a card lists no payment applications.
+var ErrNoApplications = errors.New("emv: no payment applications")
+
+// Application is a payment application listed by the payment system
+// environment of a card.
+type Application struct {
+	AID      []byte
+	Label    string
+	Priority int // Application priority indicator, 0 when absent; lower is preferred.
+}
+
+// SelectPPSE selects the proximity payment system environment and returns
+// the applications of its directory, ordered by priority.
+func SelectPPSE(tr apdu.Transceiver) ([]Application, error) {
+	fci, err := selectName(tr, PPSE)
+	if err != nil {
+		return nil, fmt.Errorf("emv: select ppse: %w", err)
+	}
+	dir, ok := iso7816.FindTLV(fci, 0xBF0C)
+	if !ok {
+		return nil, ErrNoApplications
+	}
+	return parseDirectory(dir)
+}
+
+// SelectPSE selects the contact payment system environment and returns the
+// applications of the directory records of its SFI, ordered by priority.
+func SelectPSE(tr apdu.Transceiver) ([]Application, error) {
+	fci, err := selectName(tr, PSE)
+	if err != nil {
+		return nil, fmt.Errorf("emv: select pse: %w", err)
+	}
+	sfi, ok := iso7816.FindTLV(fci, 0x88)
+	if !ok || len(sfi) != 1 {
+		return nil, fmt.Errorf("emv: pse without directory sfi")
+	}
+	var apps []Application
+	for rec := 1; rec <= 0xFF; rec++ {
+		data, err := readRecord(tr, sfi[0], rec)
+		var se *apdu.StatusError
+		if errors.As(err, &se) && se.SW1 == 0x6A && se.SW2 == 0x83 {
+			break // Record not found, end of directory.
+		}
+		if err != nil {
+			return nil, err
+		}
+		found, err := parseDirectory(data)
+		if err != nil {
+			return nil, err
+		}
+		apps = append(apps, found...)
+	}
+	sortApplications(apps)
+	return apps, nil
+}
+
+// ListApplications returns the payment applications of the card, trying the
+// proximity environment first and the contact one second.
+func ListApplications(tr apdu.Transceiver) ([]Application, error) {
+	apps, err := SelectPPSE(tr)
+	if err == nil && len(apps) > 0 {
+		return apps, nil
+	}
+	apps, perr := SelectPSE(tr)
+	if perr != nil {
+		return nil, errors.Join(err, perr)
+	}
+	if len(apps) == 0 {
+		return nil, ErrNoApplications
+	}
+	return apps, nil
+}
+
+// parseDirectory collects the application templates (tag 61) in data.
+func parseDirectory(data []byte) ([]Application, error) {
+	tlvs, err := iso7816.ParseTLV(data)
+	if err != nil {
+		return nil, err
+	}
+	var apps []Application
+	for _, t := range tlvs {
+		switch t.Tag {
+		case 0x70: // Record template
+			found, err := parseDirectory(t.Value)
+			if err != nil {
+				return nil, err
+			}
+			apps = append(apps, found...)
+		case 0x61:
+			aid, ok := iso7816.FindTLV(t.Value, 0x4F)
+			if !ok {
+				continue
+			}
+			app := Application{AID: aid}
+			if label, ok := iso7816.FindTLV(t.Value, 0x50); ok {
+				app.Label = string(label)
+			}
+			if p, ok := iso7816.FindTLV(t.Value, 0x87); ok && len(p) == 1 {
+				app.Priority = int(p[0] & 0x0F)
+			}
+			apps = append(apps, app)
+		}
+	}
+	sortApplications(apps)
+	return apps, nil
+}
+
+func sortApplications(apps []Application) {
+	sort.SliceStable(apps, func(i, j int) bool {
+		pi, pj := apps[i].Priority, apps[j].Priority
+		if pi == 0 || pj == 0 {
+			return pi != 0 && pj == 0
+		}
+		return pi < pj
+	})
+}
+
+// Read lists the payment applications of the card and reads the preferred
+// one. Only data readable without authentication is returned.
+func Read(tr apdu.Transceiver) (*Card, error) {
+	apps, err := ListApplications(tr)
+	if err != nil {
+		return nil, err
+	}
+	return ReadApplication(tr, apps[0])
+}
+
+// ReadApplication selects app, initiates processing with GET PROCESSING
+// OPTIONS and reads the records listed in the application file locator to
+// collect the PAN, expiry date and cardholder name.
+func ReadApplication(tr apdu.Transceiver, app Application) (*Card, error) {
+	fci, err := selectName(tr, app.AID)
+	if err != nil {
+		return nil, fmt.Errorf("emv: select %X: %w", app.AID, err)
+	}
+	card := &Card{AID: app.AID, Label: app.Label}
+	if label, ok := iso7816.FindTLV(fci, 0x50); ok && card.Label == "" {
+		card.Label = string(label)
+	}
+
+	pdol, _ := iso7816.FindTLV(fci, 0x9F38)
+	data := iso7816.AppendTLV(nil, 0x83, pdolData(pdol))
+	gpo, err := transmit(tr, append(append([]byte{0x80, 0xA8, 0x00, 0x00, byte(len(data))}, data...), 0x00))
+	if err != nil {
+		return nil, fmt.Errorf("emv: get processing options: %w", err)
+	}
+	var afl []byte
+	tlvs, err := iso7816.ParseTLV(gpo)
+	if err != nil || len(tlvs) != 1 {
+		return nil, fmt.Errorf("emv: malformed get processing options response")
+	}
+	switch tlvs[0].Tag {
+	case 0x80: // Format 1: AIP followed by AFL.
+		if len(tlvs[0].Value) < 2 {
+			return nil, fmt.Errorf("emv: malformed get processing options response")
+		}
+		afl = tlvs[0].Value[2:]
+	case 0x77: // Format 2
+		afl, _ = iso7816.FindTLV(tlvs[0].Value, 0x94)
+		card.collect(tlvs[0].Value)
+	}
+
+	if len(afl)%4 != 0 {
+		return nil, fmt.Errorf("emv: malformed application file locator")
+	}
+	for i := 0; i < len(afl); i += 4 {
+		sfi, first, last := afl[i]>>3, int(afl[i+1]), int(afl[i+2])
+		for rec := first; rec <= last && rec != 0; rec++ {
+			data, err := readRecord(tr, sfi, rec)
+			if err != nil {
+				return nil, fmt.Errorf("emv: read record %d of sfi %d: %w", rec, sfi, err)
+			}
+			card.collect(data)
+		}
+	}
+	if card.PAN == "" {
+		return nil, fmt.Errorf("emv: no pan found")
+	}
+	return card, nil
+}
+
+// pdolData returns the data for a processing options data object list,
+// supplying terminal transaction qualifiers for contactless kernels and
+// zeros for every other element.
+func pdolData(pdol []byte) []byte {
+	var data []byte
+	for len(pdol) > 0 {
+		n := 1
+		if pdol[0]&0x1F == 0x1F {
+			for n < len(pdol) && pdol[n]&0x80 != 0 {
+				n++
+			}
+			n++
+		}
+		if n >= len(pdol) {
+			break
+		}
+		tag, l := pdol[:n], int(pdol[n])
+		value := make([]byte, l)
+		if hex.EncodeToString(tag) == "9f66" && l == 4 {
+			copy(value, []byte{0x36, 0x00, 0x40, 0x00}) // TTQ: contactless EMV and magstripe, online capable.
+		}
+		data = append(data, value...)
+		pdol = pdol[n+1:]
+	}
+	return data
+}
+
+// collect extracts card data from the data objects in data.
+func (c *Card) collect(data []byte) {
+	if pan, ok := iso7816.FindTLV(data, 0x5A); ok && c.PAN == "" {
+		c.PAN = strings.TrimRight(strings.ToUpper(hex.EncodeToString(pan)), "F")
+	}
+	if exp, ok := iso7816.FindTLV(data, 0x5F24); ok && len(exp) == 3 && c.Expiry == "" {
+		c.Expiry = hex.EncodeToString(exp[:2])
+	}
+	if name, ok := iso7816.FindTLV(data, 0x5F20); ok && c.CardholderName == "" {
+		c.CardholderName = strings.TrimSpace(string(name))
+	}
+	for _, tag := range []iso7816.Tag{0x57, 0x9F6B} {
+		if t2, ok := iso7816.FindTLV(data, tag); ok {
+			c.track2(t2)
+		}
+	}
+}
+
+// track2 extracts PAN, expiry and service code from track 2 equivalent
+// data: PAN, separator D, YYMM expiry, three digit service code.
+func (c *Card) track2(t2 []byte) {
+	s := strings.ToUpper(hex.EncodeToString(t2))
+	pan, rest, ok := strings.Cut(s, "D")
+	if !ok || len(rest) < 7 {
+		return
+	}
+	if c.PAN == "" {
+		c.PAN = pan
+	}
+	if c.Expiry == "" {
+		c.Expiry = rest[:4]
+	}
+	if c.ServiceCode == "" {
+		c.ServiceCode = rest[4:7]
+	}
+}
+
+func selectName(tr apdu.Transceiver, name []byte) ([]byte, error) {
+	return transmit(tr, append(append([]byte{0x00, 0xA4, 0x04, 0x00, byte(len(name))}, name...), 0x00))
+}
+
+func readRecord(tr apdu.Transceiver, sfi byte, rec int) ([]byte, error) {
+	return transmit(tr, []byte{0x00, 0xB2, byte(rec), sfi<<3 | 0x04, 0x00})
+}
+
+func transmit(tr apdu.Transceiver, cmd []byte) ([]byte, error) {
+	resp, err := tr.Transmit(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		return nil, err
+	}
+	return resp[:len(resp)-2], nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package emv
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+var visaAID = []byte{0xA0, 0x00, 0x00, 0x00, 0x03, 0x10, 0x10}
+
+// fakeCard emulates a contactless Visa card with a PPSE, a PDOL requesting
+// the terminal transaction qualifiers and one record in SFI 1.
+type fakeCard struct {
+	selected []byte
+	ttq      []byte
+}
+
+func tlv(tag iso7816.Tag, parts ...[]byte) []byte {
+	return iso7816.AppendTLV(nil, tag, bytes.Join(parts, nil))
+}
+
+func unhex(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	return b
+}
+
+func (f *fakeCard) Transmit(cmd []byte) ([]byte, error) {
+	ok := []byte{0x90, 0x00}
+	switch {
+	case cmd[1] == 0xA4:
+		f.selected = cmd[5 : 5+int(cmd[4])]
+		switch {
+		case bytes.Equal(f.selected, PPSE):
+			entry := tlv(0x61, tlv(0x4F, visaAID), tlv(0x50, []byte("VISA DEBIT")), tlv(0x87, []byte{0x01}))
+			return append(tlv(0x6F, tlv(0x84, PPSE), tlv(0xA5, tlv(0xBF0C, entry))), ok...), nil
+		case bytes.Equal(f.selected, visaAID):
+			return append(tlv(0x6F, tlv(0x84, visaAID), tlv(0xA5, tlv(0x9F38, unhex("9F66049F0206")))), ok...), nil
+		}
+	case cmd[1] == 0xA8:
+		f.ttq = cmd[7:11]
+		return append(tlv(0x77, tlv(0x82, unhex("2000")), tlv(0x94, unhex("08010100"))), ok...), nil
+	case cmd[1] == 0xB2 && cmd[2] == 1 && cmd[3] == 0x0C:
+		return append(tlv(0x70, tlv(0x57, unhex("4761739001010010D25122011143804489")), tlv(0x5F20, []byte("DOE/JOHN  "))), ok...), nil
+	}
+	return []byte{0x6A, 0x82}, nil
+}
+
+func TestRead(t *testing.T) {
+	f := &fakeCard{}
+	card, err := Read(f)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := Card{AID: visaAID, Label: "VISA DEBIT", PAN: "4761739001010010", Expiry: "2512", ServiceCode: "201", CardholderName: "DOE/JOHN"}
+	if !bytes.Equal(card.AID, want.AID) || card.Label != want.Label || card.PAN != want.PAN ||
+		card.Expiry != want.Expiry || card.ServiceCode != want.ServiceCode || card.CardholderName != want.CardholderName {
+		t.Errorf("Read() = %+v, want %+v", *card, want)
+	}
+	if !bytes.Equal(f.ttq, []byte{0x36, 0x00, 0x40, 0x00}) {
+		t.Errorf("PDOL TTQ = %X", f.ttq)
+	}
+	if !ValidateCard(card) {
+		t.Error("ValidateCard() = false for a valid test PAN")
+	}
+}
+
+func TestLuhn(t *testing.T) {
+	tests := []struct {
+		number string
+		want   int
+	}{
+		{"4761739001010010", 0},
+		{"4761739001010011", 1},
+		{"79927398713", 0},
+		{"12a4", -1},
+	}
+	for _, tt := range tests {
+		if got := CalculateLuhnChecksum(tt.number); got != tt.want {
+			t.Errorf("CalculateLuhnChecksum(%s) = %d, want %d", tt.number, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+)
+
+// ErrAllReadersFailed is returned by Run under ContinueOthers, joined with
+// the reader errors, once every selected reader failed.
+var ErrAllReadersFailed = errors.New("all readers failed")
+
+// ReaderError reports a failure of a single reader, e.g. a card on it which
+// could not be connected. Backends return it for errors which do not affect
+// the other readers.
+type ReaderError struct {
+	Reader string
+	Err    error
+}
+
+func (e *ReaderError) Error() string { return fmt.Sprintf("reader %s: %v", e.Reader, e.Err) }
+
+func (e *ReaderError) Unwrap() error { return e.Err }
+
+// ReaderFailurePolicy decides how Run reacts to a *ReaderError.
+type ReaderFailurePolicy uint8
+
+const (
+	// FailFast makes Run return the first reader error.
+	FailFast ReaderFailurePolicy = iota
+	// ContinueOthers makes Run stop monitoring a failed reader until it is
+	// detached and keep monitoring the others. Run returns the errors of
+	// the failed readers joined with its own result, or with
+	// ErrAllReadersFailed once every selected reader failed.
+	ContinueOthers
+)
+
+// WithReaderFailurePolicy sets how Run reacts to failures of single readers,
+// FailFast by default.
+func WithReaderFailurePolicy(p ReaderFailurePolicy) Option {
+	return func(sdk *SDK) {
+		sdk.failurePolicy = p
+	}
+}
+
+// readerFailed records the failure of a reader under ContinueOthers,
+// excluding it from monitoring.
+func (sdk *SDK) readerFailed(err *ReaderError) {
+	sdk.mu.Lock()
+	defer sdk.mu.Unlock()
+	if sdk.failed == nil {
+		sdk.failed = make(map[string]bool)
+	}
+	sdk.failed[err.Reader] = true
+	sdk.readerErrs = append(sdk.readerErrs, err)
+}
+
+// withReaderErrors joins err with the reader errors recorded by Run and
+// clears them. Without reader errors err is returned as is.
+func (sdk *SDK) withReaderErrors(err error) error {
+	sdk.mu.Lock()
+	defer sdk.mu.Unlock()
+	if len(sdk.readerErrs) == 0 {
+		return err
+	}
+	errs := append([]error{err}, sdk.readerErrs...)
+	sdk.readerErrs = nil
+	return errors.Join(errs...)
+}
+
+// workingReaders filters out failed readers in place. It reports false
+// when readers is not empty and all of them failed.
+func (sdk *SDK) workingReaders(readers []cardreader.Reader) ([]cardreader.Reader, bool) {
+	sdk.mu.RLock()
+	defer sdk.mu.RUnlock()
+	if len(sdk.failed) == 0 {
+		return readers, true
+	}
+	working := readers[:0]
+	for _, r := range readers {
+		if !sdk.failed[r.Name] {
+			working = append(working, r)
+		}
+	}
+	return working, len(working) > 0 || len(readers) == 0
+}
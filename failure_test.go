@@ -0,0 +1,89 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// brokenBackend fails waits on its broken readers and presents a card on
+// the others.
+type brokenBackend struct {
+	readers []cardreader.Reader
+	broken  map[string]bool
+}
+
+func (b *brokenBackend) ListReaders() ([]cardreader.Reader, error) { return b.readers, nil }
+
+func (b *brokenBackend) WaitCard(ctx context.Context, readers []cardreader.Reader, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, cardreader.Reader{}, err
+	}
+	for _, r := range readers {
+		if b.broken[r.Name] {
+			return nil, cardreader.Reader{}, &ReaderError{Reader: r.Name, Err: errors.New("firmware crashed")}
+		}
+	}
+	return &memCard{}, readers[0], nil
+}
+
+func TestReaderFailurePolicy(t *testing.T) {
+	readers := []cardreader.Reader{*cardreader.NewReader("r0"), *cardreader.NewReader("r1")}
+	tests := []struct {
+		name    string
+		policy  ReaderFailurePolicy
+		broken  map[string]bool
+		failed  []string
+		handled bool
+		want    error
+	}{
+		{"fail fast", FailFast, map[string]bool{"r0": true}, []string{"r0"}, false, nil},
+		{"continue others", ContinueOthers, map[string]bool{"r0": true}, []string{"r0"}, true, context.DeadlineExceeded},
+		{"all failed", ContinueOthers, map[string]bool{"r0": true, "r1": true}, []string{"r0", "r1"}, false, ErrAllReadersFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			sdk := New(
+				WithBackend(&brokenBackend{readers: readers, broken: tt.broken}),
+				WithReaderFailurePolicy(tt.policy),
+				WithCardHandler(func(context.Context, cardreader.Event, transport.Card) error {
+					calls.Add(1)
+					return nil
+				}),
+			)
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			err := sdk.Run(ctx)
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Run() error = %v, want %v", err, tt.want)
+			}
+			var failed []string
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				for _, err := range joined.Unwrap() {
+					var rerr *ReaderError
+					if errors.As(err, &rerr) {
+						failed = append(failed, rerr.Reader)
+					}
+				}
+			} else if rerr := (*ReaderError)(nil); errors.As(err, &rerr) {
+				failed = append(failed, rerr.Reader)
+			}
+			if len(failed) != len(tt.failed) {
+				t.Errorf("Run() error = %v, want errors of readers %v", err, tt.failed)
+			}
+			if handled := calls.Load() > 0; handled != tt.handled {
+				t.Errorf("handled cards = %v, want %v", handled, tt.handled)
+			}
+		})
+	}
+}
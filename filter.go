@@ -0,0 +1,171 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// Filter is a rule selecting events, evaluated by the SDK before dispatch
+// so handlers and sinks only see the events they subscribed to. All
+// non-empty fields must match; the values of a field are alternatives. The
+// zero Filter matches every event.
+type Filter struct {
+	Events []cardreader.EventType
+	// Readers are reader names or path.Match patterns, such as "ACS *".
+	Readers []string
+	// Zones are zone names, see WithZones.
+	Zones []string
+	// Types are tag types. The SDK detects the types of the cards it
+	// dispatches with its detector, probing the cards, while Match detects
+	// them from the ATR of the event with tag.Detect.
+	Types       []tag.Type
+	UIDPrefixes [][]byte
+	// NDEFTypes are record types, such as "U", "T" or "text/vcard", one of
+	// which the NDEF message of the card must hold. Filters with NDEFTypes
+	// make the SDK read the NDEF message of cards, see
+	// cardreader.Event.NDEF.
+	NDEFTypes []string
+	// Func, when set, is an additional predicate.
+	Func func(ev cardreader.Event) bool
+}
+
+// Match reports whether ev passes f.
+func (f *Filter) Match(ev cardreader.Event) bool {
+	return f.match(ev, func() tag.Type { return tag.Detect(tag.Signature{ATR: ev.ATR}) })
+}
+
+// match reports whether ev passes f, typ returning the tag type of its
+// card. typ is only called when f has Types.
+func (f *Filter) match(ev cardreader.Event, typ func() tag.Type) bool {
+	if len(f.Events) > 0 && !contains(f.Events, ev.Type) {
+		return false
+	}
+	if len(f.Readers) > 0 && !matchAny(f.Readers, func(p string) bool {
+		ok, _ := path.Match(p, ev.Reader)
+		return ok || p == ev.Reader
+	}) {
+		return false
+	}
+	if len(f.Zones) > 0 && !contains(f.Zones, ev.Zone) {
+		return false
+	}
+	if len(f.Types) > 0 && (len(ev.ATR) == 0 || !contains(f.Types, typ())) {
+		return false
+	}
+	if len(f.UIDPrefixes) > 0 && !matchAny(f.UIDPrefixes, func(p []byte) bool {
+		return len(ev.UID) > 0 && bytes.HasPrefix(ev.UID, p)
+	}) {
+		return false
+	}
+	if len(f.NDEFTypes) > 0 && (ev.NDEF == nil || !matchAny(ev.NDEF.Records, func(r *ndef.Record) bool {
+		return contains(f.NDEFTypes, string(r.Type))
+	})) {
+		return false
+	}
+	return f.Func == nil || f.Func(ev)
+}
+
+func contains[T comparable](s []T, v T) bool {
+	return matchAny(s, func(e T) bool { return e == v })
+}
+
+func matchAny[T any](s []T, fn func(T) bool) bool {
+	for _, e := range s {
+		if fn(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterHandler is a handler registered for events passing a filter.
+type filterHandler struct {
+	filter  Filter
+	handler CardHandler
+}
+
+// HandleFilter registers h for cards whose events pass f. Filter handlers
+// take precedence over ATR and tag type handlers and are tried in
+// registration order.
+func (sdk *SDK) HandleFilter(f Filter, h CardHandler) {
+	sdk.mu.Lock()
+	defer sdk.mu.Unlock()
+	sdk.filterHandlers = append(sdk.filterHandlers, filterHandler{filter: f, handler: h})
+}
+
+// WithFilteredEventSink registers s like WithEventSink, passing it only
+// the events passing f. State changed events are passed only when
+// f.Events lists cardreader.EventStateChanged.
+func WithFilteredEventSink(s EventSink, buffer int, f Filter) Option {
+	return func(sdk *SDK) {
+		WithEventSink(s, buffer)(sdk)
+		sdk.sinks[len(sdk.sinks)-1].filter = &f
+	}
+}
+
+// dispatchHandler returns the handler for ev: the first filter handler
+// it passes, else the handler for its ATR or tag type.
+func (sdk *SDK) dispatchHandler(ev cardreader.Event, typ *cardType) CardHandler {
+	sdk.mu.RLock()
+	filters := sdk.filterHandlers
+	sdk.mu.RUnlock()
+	for _, h := range filters {
+		if h.filter.match(ev, typ.get) {
+			return h.handler
+		}
+	}
+	return sdk.handlerFor(ev.ATR, typ)
+}
+
+// readNDEF sets the NDEF message of ev, read from card, when a filter
+// inspects it.
+func (sdk *SDK) readNDEF(ctx context.Context, ev *cardreader.Event, card transport.Card) {
+	sdk.mu.RLock()
+	needed := matchAny(sdk.filterHandlers, func(h filterHandler) bool { return len(h.filter.NDEFTypes) > 0 }) ||
+		matchAny(sdk.sinks, func(q *sinkQueue) bool { return q.filter != nil && len(q.filter.NDEFTypes) > 0 })
+	sdk.mu.RUnlock()
+	if !needed {
+		return
+	}
+	data, err := sdk.detector.ReadNDEF(card, nil)
+	if err != nil {
+		sdk.logger.DebugContext(ctx, "read ndef for filters", "reader", ev.Reader, "error", err)
+		return
+	}
+	msg := ndef.NewMessage()
+	if len(data) > 0 && msg.Unmarshal(data) == nil {
+		ev.NDEF = msg
+	}
+}
+
+// ndefSummary returns the short summary of msg recorded as the NDEF of the
+// last card of a reader: the text or URI of its first record, else its
+// type, followed by the number of further records. It returns an empty
+// string for a nil or empty message.
+func ndefSummary(msg *ndef.Message) string {
+	if msg == nil || len(msg.Records) == 0 {
+		return ""
+	}
+	r := msg.Records[0]
+	summary := string(r.Type)
+	if text, _, err := r.Text(); err == nil {
+		summary = text
+	} else if uri, err := r.URI(); err == nil {
+		summary = uri
+	}
+	if n := len(msg.Records) - 1; n > 0 {
+		summary += fmt.Sprintf(" (+%d more)", n)
+	}
+	return summary
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/transport"
+	"github.com/happy-sdk/scardkit/x/virtualreader"
+)
+
+func TestFilterMatch(t *testing.T) {
+	ntag := virtualreader.NewNTAG215(nil)
+	ev := cardreader.Event{
+		Type:   cardreader.EventCardInserted,
+		Reader: "ACS ACR1252 0",
+		ATR:    ntag.ATR(),
+		UID:    []byte{0x04, 0xA1, 0xB2},
+		NDEF:   ndef.NewMessage(ndef.NewURIRecord("https://example.com")),
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"zero", Filter{}, true},
+		{"event", Filter{Events: []cardreader.EventType{cardreader.EventReaderAdded, cardreader.EventCardInserted}}, true},
+		{"other event", Filter{Events: []cardreader.EventType{cardreader.EventReaderAdded}}, false},
+		{"reader pattern", Filter{Readers: []string{"ACS *"}}, true},
+		{"reader name", Filter{Readers: []string{"ACS ACR1252 0"}}, true},
+		{"other reader", Filter{Readers: []string{"Identiv *"}}, false},
+		{"tag type", Filter{Types: []tag.Type{tag.TypeNTAG, tag.TypeUltralight}}, true},
+		{"other tag type", Filter{Types: []tag.Type{tag.TypeDESFire}}, false},
+		{"uid prefix", Filter{UIDPrefixes: [][]byte{{0x05}, {0x04, 0xA1}}}, true},
+		{"other uid prefix", Filter{UIDPrefixes: [][]byte{{0x04, 0xA2}}}, false},
+		{"ndef type", Filter{NDEFTypes: []string{"T", "U"}}, true},
+		{"other ndef type", Filter{NDEFTypes: []string{"text/vcard"}}, false},
+		{"func", Filter{Func: func(ev cardreader.Event) bool { return len(ev.UID) == 3 }}, true},
+		{"all fields", Filter{Readers: []string{"ACS *"}, UIDPrefixes: [][]byte{{0x04}}, NDEFTypes: []string{"T"}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(ev); got != tt.want {
+			t.Errorf("%s: Match() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHandleFilter(t *testing.T) {
+	vr := virtualreader.New("gate")
+	ntag := virtualreader.NewNTAG215([]byte{0x04, 1, 2, 3, 4, 5, 6})
+	data, _ := ndef.NewMessage(ndef.NewURIRecord("https://example.com/door")).Marshal()
+	if err := tag.WriteNDEF(ntag, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := vr.Insert("gate", ntag); err != nil {
+		t.Fatal(err)
+	}
+
+	var called string
+	handler := func(name string) CardHandler {
+		return func(_ context.Context, ev cardreader.Event, _ transport.Card) error {
+			called = name
+			return nil
+		}
+	}
+	urls := make(chan cardreader.Event, 4)
+	vcards := make(chan cardreader.Event, 4)
+	sdk := New(WithBackend(vr), WithCardHandler(handler("default")),
+		WithFilteredEventSink(ChanSink(urls), 0, Filter{NDEFTypes: []string{"U"}}),
+		WithFilteredEventSink(ChanSink(vcards), 0, Filter{NDEFTypes: []string{"text/vcard"}}),
+	)
+	sdk.HandleFilter(Filter{Readers: []string{"lobby"}}, handler("lobby"))
+	sdk.HandleFilter(Filter{NDEFTypes: []string{"U"}}, handler("url"))
+	if _, err := sdk.WaitForCard(context.Background(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if called != "url" {
+		t.Errorf("handler %q called, want url", called)
+	}
+	r, err := sdk.LookupReader("gate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last, ok := r.LastCard(); !ok || last.NDEF != "https://example.com/door" {
+		t.Errorf("LastCard() = %+v, %v; want the URI of the message", last, ok)
+	}
+	if err := sdk.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 1 || len(vcards) != 0 {
+		t.Errorf("sinks got %d url and %d vcard events, want 1 and 0", len(urls), len(vcards))
+	}
+}
+
+func TestNDEFSummary(t *testing.T) {
+	text, _ := ndef.NewTextRecord("en", "hello")
+	tests := []struct {
+		msg  *ndef.Message
+		want string
+	}{
+		{nil, ""},
+		{ndef.NewMessage(), ""},
+		{ndef.NewMessage(ndef.NewURIRecord("https://example.com/door")), "https://example.com/door"},
+		{ndef.NewMessage(text, ndef.NewURIRecord("https://example.com")), "hello (+1 more)"},
+		{ndef.NewMessage(ndef.NewRecord(ndef.TNFMedia, []byte("text/vcard"), nil, nil)), "text/vcard"},
+	}
+	for _, tt := range tests {
+		if got := ndefSummary(tt.msg); got != tt.want {
+			t.Errorf("ndefSummary() = %q, want %q", got, tt.want)
+		}
+	}
+}
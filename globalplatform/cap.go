@@ -0,0 +1,88 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package globalplatform
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// CAP is a Java Card converted applet file prepared for loading.
+type CAP struct {
+	PackageAID []byte
+	Applets    [][]byte // AIDs of the applets defined by the package.
+	// LoadFile is the load file data block: the components required on the
+	// card in load order, wrapped in a C4 data object.
+	LoadFile []byte
+}
+
+// capLoadOrder lists the components of a CAP file sent to the card, in the
+// order required for loading. Descriptor and Debug stay off-card.
+var capLoadOrder = []string{
+	"Header", "Directory", "Import", "Applet", "Class", "Method",
+	"StaticField", "Export", "ConstantPool", "RefLocation",
+}
+
+// ParseCAP parses a CAP file, the ZIP archive produced by the Java Card
+// converter.
+func ParseCAP(r io.ReaderAt, size int64) (*CAP, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("globalplatform: cap: %w", err)
+	}
+	components := make(map[string][]byte)
+	for _, f := range zr.File {
+		if path.Ext(f.Name) != ".cap" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("globalplatform: cap: %w", err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("globalplatform: cap: %w", err)
+		}
+		components[path.Base(f.Name[:len(f.Name)-4])] = data
+	}
+
+	header, ok := components["Header"]
+	if !ok {
+		return nil, fmt.Errorf("globalplatform: cap: missing header component")
+	}
+	// tag(1) size(2) magic(4) minor(1) major(1) flags(1) package minor(1)
+	// major(1) AID length(1) AID
+	if len(header) < 13 || !bytes.Equal(header[3:7], []byte{0xDE, 0xCA, 0xFF, 0xED}) || len(header) < 13+int(header[12]) {
+		return nil, fmt.Errorf("globalplatform: cap: malformed header component")
+	}
+	cap := &CAP{PackageAID: header[13 : 13+int(header[12])]}
+
+	if applet, ok := components["Applet"]; ok {
+		if len(applet) < 4 {
+			return nil, fmt.Errorf("globalplatform: cap: malformed applet component")
+		}
+		b := applet[4:]
+		for i := 0; i < int(applet[3]); i++ {
+			if len(b) < 1 || len(b) < 3+int(b[0]) {
+				return nil, fmt.Errorf("globalplatform: cap: malformed applet component")
+			}
+			cap.Applets = append(cap.Applets, b[1:1+int(b[0])])
+			b = b[3+int(b[0]):]
+		}
+	}
+
+	var block []byte
+	for _, name := range capLoadOrder {
+		block = append(block, components[name]...)
+	}
+	cap.LoadFile = iso7816.AppendTLV(nil, 0xC4, block)
+	return cap, nil
+}
@@ -0,0 +1,311 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package globalplatform implements GlobalPlatform card management: opening
+// SCP02 and SCP03 secure channels to the issuer security domain, listing
+// card content with GET STATUS and loading, installing and deleting Java
+// Card applets.
+package globalplatform
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+	"github.com/happy-sdk/scardkit/sckutils"
+)
+
+// ISD is the AID of the issuer security domain.
+var ISD = []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x00, 0x00, 0x00}
+
+// Select selects the application aid and returns its FCI.
+func Select(tr apdu.Transceiver, aid []byte) ([]byte, error) {
+	resp, err := transmit(tr, append(append([]byte{0x00, 0xA4, 0x04, 0x00, byte(len(aid))}, aid...), 0x00))
+	if err != nil {
+		return nil, fmt.Errorf("globalplatform: select %X: %w", aid, err)
+	}
+	return resp, nil
+}
+
+// Session is an authenticated secure channel to a security domain. It
+// implements apdu.Transceiver, protecting every command sent through it.
+type Session struct {
+	tr  apdu.Transceiver
+	mu  sync.Mutex
+	sc  secureChannel
+	scp byte
+}
+
+// OpenSecureChannel selects the issuer security domain and authenticates to
+// it with keys, using SCP02 or SCP03 as announced by the card, and returns a
+// session protecting commands at level.
+func OpenSecureChannel(tr apdu.Transceiver, keys Keys, level SecurityLevel) (*Session, error) {
+	if _, err := Select(tr, ISD); err != nil {
+		return nil, err
+	}
+	hostChallenge := make([]byte, 8)
+	if _, err := randRead(hostChallenge); err != nil {
+		return nil, err
+	}
+	resp, err := transmit(tr, append(append([]byte{0x80, 0x50, keys.Version, 0x00, 0x08}, hostChallenge...), 0x00))
+	if err != nil {
+		return nil, fmt.Errorf("globalplatform: initialize update: %w", err)
+	}
+	if len(resp) < 12 {
+		return nil, fmt.Errorf("globalplatform: initialize update response of %d bytes", len(resp))
+	}
+
+	s := &Session{tr: tr, scp: resp[11]}
+	var hostCryptogram []byte
+	switch s.scp {
+	case 0x02:
+		s.sc, hostCryptogram, err = newSCP02(keys, hostChallenge, resp)
+	case 0x03:
+		s.sc, hostCryptogram, err = newSCP03(keys, hostChallenge, resp)
+	default:
+		return nil, fmt.Errorf("globalplatform: unsupported secure channel protocol %02X", s.scp)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	auth := iso7816.NewCommandAPDU(0x80, 0x82, byte(level), 0x00, 0, hostCryptogram)
+	if _, err := s.transmit(auth); err != nil {
+		return nil, fmt.Errorf("globalplatform: external authenticate: %w", err)
+	}
+	s.sc.setLevel(level)
+	return s, nil
+}
+
+// SCP returns the secure channel protocol of the session, 0x02 or 0x03.
+func (s *Session) SCP() byte { return s.scp }
+
+// Close ends the session and zeroizes its session keys and chaining state.
+// Commands sent through a closed session fail with ErrSessionClosed.
+func (s *Session) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sc != nil {
+		s.sc.zero()
+		s.sc = nil
+	}
+}
+
+// Transmit protects cmd and sends it to the card. Responses are returned
+// unchanged since the session does not request R-MAC.
+func (s *Session) Transmit(cmd []byte) ([]byte, error) {
+	c, err := iso7816.UnmarshalCommandAPDU(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return s.transmitRaw(c)
+}
+
+func (s *Session) transmitRaw(c *iso7816.CommandAPDU) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sc == nil {
+		return nil, ErrSessionClosed
+	}
+	wrapped, err := s.sc.wrap(c)
+	if err != nil {
+		return nil, err
+	}
+	return s.tr.Transmit(wrapped)
+}
+
+// transmit sends c through the secure channel and checks the status words.
+func (s *Session) transmit(c *iso7816.CommandAPDU) ([]byte, error) {
+	resp, err := s.transmitRaw(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		return nil, err
+	}
+	return resp[:len(resp)-2], nil
+}
+
+// Scope selects the card content listed by GetStatus.
+type Scope byte
+
+const (
+	ScopeISD              Scope = 0x80 // Issuer security domain.
+	ScopeApplications     Scope = 0x40 // Applications and security domains.
+	ScopeLoadFiles        Scope = 0x20 // Executable load files.
+	ScopeLoadFilesModules Scope = 0x10 // Executable load files and their modules.
+)
+
+// Status is an entry of the card content listed by GetStatus.
+type Status struct {
+	AID        []byte
+	LifeCycle  byte
+	Privileges []byte   // Privileges of applications and security domains.
+	Modules    [][]byte // Executable modules of load files.
+}
+
+// GetStatus lists the card content in scope.
+func (s *Session) GetStatus(scope Scope) ([]Status, error) {
+	var out []Status
+	p2 := byte(0x02) // TLV response format
+	for {
+		resp, err := s.transmitRaw(iso7816.NewCommandAPDU(0x80, 0xF2, byte(scope), p2, 0, []byte{0x4F, 0x00}))
+		if err != nil {
+			return nil, err
+		}
+		if len(resp) < 2 {
+			return nil, fmt.Errorf("globalplatform: get status: short response")
+		}
+		sw := uint16(resp[len(resp)-2])<<8 | uint16(resp[len(resp)-1])
+		if sw == 0x6A88 && len(out) == 0 {
+			return nil, nil // Referenced data not found: nothing in scope.
+		}
+		if sw != 0x9000 && sw != 0x6310 {
+			return nil, fmt.Errorf("globalplatform: get status: %w", apdu.CheckStatusFromData(resp))
+		}
+		entries, err := parseStatus(resp[:len(resp)-2])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, entries...)
+		if sw == 0x9000 {
+			return out, nil
+		}
+		p2 |= 0x01 // Next occurrence.
+	}
+}
+
+func parseStatus(data []byte) ([]Status, error) {
+	tlvs, err := iso7816.ParseTLV(data)
+	if err != nil {
+		return nil, fmt.Errorf("globalplatform: get status: %w", err)
+	}
+	var out []Status
+	for _, t := range tlvs {
+		if t.Tag != 0xE3 {
+			continue
+		}
+		fields, err := t.Children()
+		if err != nil {
+			return nil, err
+		}
+		var st Status
+		for _, f := range fields {
+			switch f.Tag {
+			case 0x4F:
+				st.AID = f.Value
+			case 0x9F70:
+				if len(f.Value) > 0 {
+					st.LifeCycle = f.Value[0]
+				}
+			case 0xC5:
+				st.Privileges = f.Value
+			case 0x84:
+				st.Modules = append(st.Modules, f.Value)
+			}
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// Delete deletes the application or load file aid, together with its
+// related objects, such as the instances of a load file, when related is set.
+func (s *Session) Delete(aid []byte, related bool) error {
+	var p2 byte
+	if related {
+		p2 = 0x80
+	}
+	_, err := s.transmit(iso7816.NewCommandAPDU(0x80, 0xE4, 0x00, p2, 0, iso7816.AppendTLV(nil, 0x4F, aid)))
+	if err != nil {
+		return fmt.Errorf("globalplatform: delete %X: %w", aid, err)
+	}
+	return nil
+}
+
+// lv appends b prefixed with its length.
+func lv(out, b []byte) []byte { return append(append(out, byte(len(b))), b...) }
+
+// InstallForLoad prepares loading the load file loadFile associated with
+// the security domain sd, the ISD when sd is nil.
+func (s *Session) InstallForLoad(loadFile, sd []byte) error {
+	if sd == nil {
+		sd = ISD
+	}
+	data := lv(lv(nil, loadFile), sd)
+	data = append(data, 0x00, 0x00, 0x00) // No hash, parameters or token.
+	if _, err := s.transmit(iso7816.NewCommandAPDU(0x80, 0xE6, 0x02, 0x00, 0, data)); err != nil {
+		return fmt.Errorf("globalplatform: install for load: %w", err)
+	}
+	return nil
+}
+
+// loadBlockSize is the size of the load file data sent per LOAD command,
+// leaving room for the C-MAC and padding within a short APDU.
+const loadBlockSize = 0xE0
+
+// Load sends the load file data block, such as CAP.LoadFile, after
+// InstallForLoad, reporting progress after each block when progress is not
+// nil.
+func (s *Session) Load(block []byte, progress sckutils.ProgressFunc) error {
+	blocks := (len(block) + loadBlockSize - 1) / loadBlockSize
+	if blocks > 0x100 {
+		return fmt.Errorf("globalplatform: load file of %d bytes too large", len(block))
+	}
+	for i := 0; i < blocks; i++ {
+		p1 := byte(0x00)
+		if i == blocks-1 {
+			p1 = 0x80 // Last block.
+		}
+		chunk := block[i*loadBlockSize : min((i+1)*loadBlockSize, len(block))]
+		if _, err := s.transmit(iso7816.NewCommandAPDU(0x80, 0xE8, p1, byte(i), 0, chunk)); err != nil {
+			return fmt.Errorf("globalplatform: load block %d: %w", i, err)
+		}
+		progress.Report(min((i+1)*loadBlockSize, len(block)), len(block))
+	}
+	return nil
+}
+
+// InstallForInstall installs the applet module of loadFile as application
+// app and makes it selectable. params are the application specific install
+// parameters, passed in a C9 data object.
+func (s *Session) InstallForInstall(loadFile, module, app []byte, privileges byte, params []byte) error {
+	data := lv(lv(lv(nil, loadFile), module), app)
+	data = lv(data, []byte{privileges})
+	data = lv(data, iso7816.AppendTLV(nil, 0xC9, params))
+	data = append(data, 0x00) // No token.
+	if _, err := s.transmit(iso7816.NewCommandAPDU(0x80, 0xE6, 0x0C, 0x00, 0, data)); err != nil {
+		return fmt.Errorf("globalplatform: install for install: %w", err)
+	}
+	return nil
+}
+
+// InstallCAP loads cap and installs each of its applets under its own AID
+// with default privileges and no parameters.
+func (s *Session) InstallCAP(cap *CAP, progress sckutils.ProgressFunc) error {
+	if err := s.InstallForLoad(cap.PackageAID, nil); err != nil {
+		return err
+	}
+	if err := s.Load(cap.LoadFile, progress); err != nil {
+		return err
+	}
+	var errs []error
+	for _, applet := range cap.Applets {
+		errs = append(errs, s.InstallForInstall(cap.PackageAID, applet, applet, 0x00, nil))
+	}
+	return errors.Join(errs...)
+}
+
+func transmit(tr apdu.Transceiver, cmd []byte) ([]byte, error) {
+	resp, err := tr.Transmit(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		return nil, err
+	}
+	return resp[:len(resp)-2], nil
+}
@@ -0,0 +1,260 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package globalplatform
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/aes"
+	"crypto/des"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/crypto"
+	"github.com/happy-sdk/scardkit/crypto/keystore"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+func unhex(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	return b
+}
+
+var (
+	hostChallenge = unhex("0102030405060708")
+	appAID        = unhex("A000000062030101")
+)
+
+// fakeCard emulates a security domain with the default keys, verifying the
+// host cryptogram and the C-MAC of every command after INITIALIZE UPDATE.
+type fakeCard struct {
+	scp      byte
+	keys     Keys
+	verify   func(cmd []byte) bool
+	host     []byte // Expected host cryptogram.
+	authed   bool
+	commands [][]byte // Commands received after authentication.
+}
+
+func (f *fakeCard) Transmit(cmd []byte) ([]byte, error) {
+	ok := []byte{0x90, 0x00}
+	switch {
+	case cmd[1] == 0xA4:
+		return ok, nil
+	case cmd[1] == 0x50:
+		return f.initializeUpdate(cmd[5:13]), nil
+	}
+	if f.verify == nil || !f.verify(cmd) {
+		return []byte{0x69, 0x82}, nil
+	}
+	if cmd[1] == 0x82 {
+		if !bytes.Equal(cmd[5:13], f.host) {
+			return []byte{0x63, 0x00}, nil
+		}
+		f.authed = true
+		return ok, nil
+	}
+	if !f.authed {
+		return []byte{0x69, 0x85}, nil
+	}
+	f.commands = append(f.commands, cmd)
+	if cmd[1] == 0xF2 {
+		if cmd[3]&0x01 == 0 {
+			entry := iso7816.AppendTLV(nil, 0xE3, bytes.Join([][]byte{
+				iso7816.AppendTLV(nil, 0x4F, ISD),
+				iso7816.AppendTLV(nil, 0x9F70, []byte{0x0F}),
+				iso7816.AppendTLV(nil, 0xC5, []byte{0x9E, 0x00, 0x00}),
+			}, nil))
+			return append(entry, 0x63, 0x10), nil
+		}
+		entry := iso7816.AppendTLV(nil, 0xE3, bytes.Join([][]byte{
+			iso7816.AppendTLV(nil, 0x4F, appAID),
+			iso7816.AppendTLV(nil, 0x9F70, []byte{0x07}),
+			iso7816.AppendTLV(nil, 0xC5, []byte{0x00, 0x00, 0x00}),
+		}, nil))
+		return append(entry, ok...), nil
+	}
+	return ok, nil
+}
+
+// unwrapped returns cmd without C-MAC and Le.
+func unwrapped(cmd []byte) []byte {
+	n := 5 + int(cmd[4])
+	return cmd[:n-8]
+}
+
+func (f *fakeCard) initializeUpdate(host []byte) []byte {
+	div := make([]byte, 10)
+	switch f.scp {
+	case 0x03:
+		cardChallenge := unhex("1112131415161718")
+		context := append(append([]byte(nil), host...), cardChallenge...)
+		macKey, _ := scp03KDF(cmacWith(f.keys.MAC), 0x06, 128, context)
+		cryptogram, _ := scp03KDF(cmacWith(macKey), 0x00, 64, context)
+		f.host, _ = scp03KDF(cmacWith(macKey), 0x01, 64, context)
+		smac, _ := aes.NewCipher(macKey)
+		chain := make([]byte, 16)
+		f.verify = func(cmd []byte) bool {
+			chain = crypto.CMAC(smac, append(append([]byte(nil), chain...), unwrapped(cmd)...))
+			return bytes.Equal(chain[:8], cmd[5+int(cmd[4])-8:5+int(cmd[4])])
+		}
+		resp := append(append(div, 0x30, 0x03, 0x00), cardChallenge...)
+		return append(append(resp, cryptogram...), 0x90, 0x00)
+	default:
+		seq, cardChallenge := unhex("000A"), unhex("212223242526")
+		encKey, _ := scp02Derive(f.keys, "enc", 0x0182, seq)
+		macKey, _ := scp02Derive(f.keys, "mac", 0x0101, seq)
+		senc, _ := tripleDES(encKey)
+		cryptogram := fullMAC(senc, bytes.Join([][]byte{host, seq, cardChallenge}, nil))
+		f.host = fullMAC(senc, bytes.Join([][]byte{seq, cardChallenge, host}, nil))
+		k1, _ := des.NewCipher(macKey[:8])
+		var last []byte
+		f.verify = func(cmd []byte) bool {
+			icv := make([]byte, 8)
+			if last != nil {
+				k1.Encrypt(icv, last)
+			}
+			last, _ = crypto.RetailMACIV(macKey, icv, crypto.Pad(unwrapped(cmd), 8))
+			return bytes.Equal(last, cmd[5+int(cmd[4])-8:5+int(cmd[4])])
+		}
+		resp := append(append(div, 0x20, 0x02), seq...)
+		resp = append(append(resp, cardChallenge...), cryptogram...)
+		return append(resp, 0x90, 0x00)
+	}
+}
+
+func TestOpenSecureChannel(t *testing.T) {
+	defer func(old func([]byte) (int, error)) { randRead = old }(randRead)
+	randRead = func(b []byte) (int, error) { return copy(b, hostChallenge), nil }
+
+	tests := []struct {
+		name string
+		scp  byte
+	}{
+		{"scp02", 0x02},
+		{"scp03", 0x03},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeCard{scp: tt.scp, keys: DefaultKeys()}
+			s, err := OpenSecureChannel(f, DefaultKeys(), SecurityMAC)
+			if err != nil {
+				t.Fatalf("OpenSecureChannel() error = %v", err)
+			}
+			if s.SCP() != tt.scp {
+				t.Errorf("SCP() = %02X, want %02X", s.SCP(), tt.scp)
+			}
+
+			status, err := s.GetStatus(ScopeApplications)
+			if err != nil {
+				t.Fatalf("GetStatus() error = %v", err)
+			}
+			if len(status) != 2 || !bytes.Equal(status[0].AID, ISD) || !bytes.Equal(status[1].AID, appAID) || status[1].LifeCycle != 0x07 {
+				t.Errorf("GetStatus() = %+v", status)
+			}
+			if err := s.Delete(appAID, true); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			last := f.commands[len(f.commands)-1]
+			if want := unhex("84E4008012" + "4F08A000000062030101"); !bytes.Equal(unwrapped(last), want) {
+				t.Errorf("DELETE = %X, want %X", unwrapped(last), want)
+			}
+			s.Close()
+			if _, err := s.GetStatus(ScopeApplications); !errors.Is(err, ErrSessionClosed) {
+				t.Errorf("GetStatus() after Close() error = %v, want ErrSessionClosed", err)
+			}
+		})
+	}
+}
+
+func TestOpenSecureChannelToken(t *testing.T) {
+	defer func(old func([]byte) (int, error)) { randRead = old }(randRead)
+	randRead = func(b []byte) (int, error) { return copy(b, hostChallenge), nil }
+
+	token := keystore.SoftToken(keystore.Map{
+		{ID: "isd/enc"}: DefaultTestKey,
+		{ID: "isd/mac"}: DefaultTestKey,
+	})
+	for _, scp := range []byte{0x02, 0x03} {
+		f := &fakeCard{scp: scp, keys: DefaultKeys()}
+		s, err := OpenSecureChannel(f, TokenKeys(token, "isd", 0), SecurityMAC)
+		if err != nil {
+			t.Fatalf("SCP%02X: OpenSecureChannel() error = %v", scp, err)
+		}
+		if _, err := s.GetStatus(ScopeApplications); err != nil {
+			t.Errorf("SCP%02X: GetStatus() error = %v", scp, err)
+		}
+	}
+}
+
+func TestOpenSecureChannelWrongKeys(t *testing.T) {
+	defer func(old func([]byte) (int, error)) { randRead = old }(randRead)
+	randRead = func(b []byte) (int, error) { return copy(b, hostChallenge), nil }
+
+	keys := Keys{ENC: make([]byte, 16), MAC: make([]byte, 16), DEK: make([]byte, 16)}
+	for _, scp := range []byte{0x02, 0x03} {
+		f := &fakeCard{scp: scp, keys: keys}
+		if _, err := OpenSecureChannel(f, DefaultKeys(), SecurityMAC); !errors.Is(err, ErrCardCryptogram) {
+			t.Errorf("SCP%02X: OpenSecureChannel() error = %v, want ErrCardCryptogram", scp, err)
+		}
+	}
+}
+
+func TestParseCAP(t *testing.T) {
+	pkg := unhex("A00000006203")
+	header := append(unhex("0100"+"10"+"DECAFFED"+"0102"+"04"+"0001"), byte(len(pkg)))
+	header = append(header, pkg...)
+	header[2] = byte(len(header) - 3)
+	applet := append(unhex("030000"+"01"), byte(len(appAID)))
+	applet = append(append(applet, appAID...), 0x00, 0x10)
+	applet[2] = byte(len(applet) - 3)
+	method := unhex("070003000102")
+	debug := unhex("0C000100")
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range map[string][]byte{
+		"com/example/javacard/Method.cap": method,
+		"com/example/javacard/Header.cap": header,
+		"com/example/javacard/Applet.cap": applet,
+		"com/example/javacard/Debug.cap":  debug,
+		"META-INF/MANIFEST.MF":            []byte("Manifest-Version: 1.0\n"),
+	} {
+		w, _ := zw.Create(name)
+		w.Write(data)
+	}
+	zw.Close()
+
+	cap, err := ParseCAP(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("ParseCAP() error = %v", err)
+	}
+	if !bytes.Equal(cap.PackageAID, pkg) {
+		t.Errorf("PackageAID = %X, want %X", cap.PackageAID, pkg)
+	}
+	if len(cap.Applets) != 1 || !bytes.Equal(cap.Applets[0], appAID) {
+		t.Errorf("Applets = %X, want [%X]", cap.Applets, appAID)
+	}
+	want := iso7816.AppendTLV(nil, 0xC4, bytes.Join([][]byte{header, applet, method}, nil))
+	if !bytes.Equal(cap.LoadFile, want) {
+		t.Errorf("LoadFile = %X, want %X", cap.LoadFile, want)
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	p := keystore.Map{
+		{ID: "isd/enc", Version: 1}: DefaultTestKey,
+		{ID: "isd/mac", Version: 1}: DefaultTestKey,
+		{ID: "isd/dek", Version: 1}: DefaultTestKey,
+	}
+	keys, err := LoadKeys(p, "isd", 1)
+	if err != nil || keys.Version != 1 || !bytes.Equal(keys.ENC, DefaultTestKey) || !bytes.Equal(keys.DEK, DefaultTestKey) {
+		t.Errorf("LoadKeys() = %+v, %v", keys, err)
+	}
+	if _, err := LoadKeys(p, "isd", 2); !errors.Is(err, keystore.ErrKeyNotFound) {
+		t.Errorf("LoadKeys() error = %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package globalplatform
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/crypto"
+	"github.com/happy-sdk/scardkit/crypto/keystore"
+	"github.com/happy-sdk/scardkit/crypto/securemem"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// SecurityLevel is the protection applied to commands sent through a
+// secure channel.
+type SecurityLevel byte
+
+const (
+	SecurityMAC    SecurityLevel = 0x01 // C-MAC
+	SecurityMACEnc SecurityLevel = 0x03 // C-DECRYPTION and C-MAC
+)
+
+// Keys are the static secure channel keys of a security domain.
+type Keys struct {
+	Version       byte // Key version number, zero selects the first available key set.
+	ENC, MAC, DEK []byte
+
+	// Token, when set, holds the ENC and MAC keys as ID+"/enc" and
+	// ID+"/mac" and derives the session keys, so the static keys never
+	// reach the host. ENC and MAC are unused then. See TokenKeys.
+	Token keystore.Token
+	ID    string
+}
+
+// TokenKeys returns the key set version of a security domain held by t,
+// with its keys stored as id+"/enc" and id+"/mac". SCP03 keys must be
+// AES-128 keys.
+func TokenKeys(t keystore.Token, id string, version byte) Keys {
+	return Keys{Version: version, Token: t, ID: id}
+}
+
+// static returns the static key name, "enc" or "mac".
+func (k Keys) static(name string) []byte {
+	if name == "enc" {
+		return k.ENC
+	}
+	return k.MAC
+}
+
+// size returns the size of the static key name.
+func (k Keys) size(name string) int {
+	if k.Token != nil {
+		return 16
+	}
+	return len(k.static(name))
+}
+
+// cmac returns the AES CMAC of msg with the static key name.
+func (k Keys) cmac(name string, msg []byte) ([]byte, error) {
+	if k.Token != nil {
+		return k.Token.CMAC(k.ID+"/"+name, int(k.Version), msg)
+	}
+	block, err := aes.NewCipher(k.static(name))
+	if err != nil {
+		return nil, err
+	}
+	return crypto.CMAC(block, msg), nil
+}
+
+// encrypt3DES encrypts data with the static key name with 3DES in CBC mode
+// and a zero IV.
+func (k Keys) encrypt3DES(name string, data []byte) ([]byte, error) {
+	if k.Token != nil {
+		return k.Token.Encrypt(k.ID+"/"+name, int(k.Version), keystore.DES3, data)
+	}
+	block, err := tripleDES(k.static(name))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, make([]byte, des.BlockSize)).CryptBlocks(out, data)
+	return out, nil
+}
+
+// DefaultTestKey is the well-known key 40..4F of development cards.
+var DefaultTestKey = []byte{
+	0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47,
+	0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F,
+}
+
+// DefaultKeys returns the key set of development cards using
+// DefaultTestKey for all keys.
+func DefaultKeys() Keys {
+	return Keys{ENC: DefaultTestKey, MAC: DefaultTestKey, DEK: DefaultTestKey}
+}
+
+// LoadKeys returns the key set version of a security domain from p, with
+// its keys stored as id+"/enc", id+"/mac" and id+"/dek".
+func LoadKeys(p keystore.Provider, id string, version byte) (Keys, error) {
+	keys := Keys{Version: version}
+	for _, k := range []struct {
+		name string
+		key  *[]byte
+	}{{"enc", &keys.ENC}, {"mac", &keys.MAC}, {"dek", &keys.DEK}} {
+		b, err := p.GetKey(id+"/"+k.name, int(version))
+		if err != nil {
+			return Keys{}, err
+		}
+		*k.key = b
+	}
+	return keys, nil
+}
+
+// ErrCardCryptogram is returned when the card cryptogram does not match the
+// keys, e.g. because the card uses other keys.
+var ErrCardCryptogram = errors.New("globalplatform: card cryptogram mismatch")
+
+// ErrSessionClosed is returned for commands sent through a closed session.
+var ErrSessionClosed = errors.New("globalplatform: session closed")
+
+// randRead generates host challenges, replaced in tests.
+var randRead = rand.Read
+
+// secureChannel wraps commands of an authenticated session.
+type secureChannel interface {
+	wrap(cmd *iso7816.CommandAPDU) ([]byte, error)
+	setLevel(level SecurityLevel)
+	// zero zeroizes the session keys and chaining state.
+	zero()
+}
+
+// scp03 implements Secure Channel Protocol 03 (GlobalPlatform Amendment D)
+// with AES session keys and CMAC chaining.
+type scp03 struct {
+	senc, smac cipher.Block
+	chain      []byte // MAC chaining value.
+	counter    []byte // Encryption counter.
+	level      SecurityLevel
+}
+
+// scp03KDF is the NIST SP 800-108 counter mode KDF of SCP03 with the CMAC
+// function cmac.
+func scp03KDF(cmac func(msg []byte) ([]byte, error), constant byte, bits int, context []byte) ([]byte, error) {
+	var out []byte
+	for i := 1; len(out)*8 < bits; i++ {
+		data := make([]byte, 11, 16+len(context))
+		data = append(data, constant, 0x00, byte(bits>>8), byte(bits), byte(i))
+		mac, err := cmac(append(data, context...))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, mac...)
+	}
+	return out[:bits/8], nil
+}
+
+// cmacWith returns the AES CMAC function of key.
+func cmacWith(key []byte) func(msg []byte) ([]byte, error) {
+	return func(msg []byte) ([]byte, error) {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.CMAC(block, msg), nil
+	}
+}
+
+// staticCMAC returns the CMAC function of the static key name of keys.
+func staticCMAC(keys Keys, name string) func(msg []byte) ([]byte, error) {
+	return func(msg []byte) ([]byte, error) { return keys.cmac(name, msg) }
+}
+
+// newSCP03 derives the session keys from the INITIALIZE UPDATE response,
+// verifies the card cryptogram and returns the host cryptogram.
+func newSCP03(keys Keys, hostChallenge, resp []byte) (*scp03, []byte, error) {
+	if len(resp) < 29 {
+		return nil, nil, fmt.Errorf("globalplatform: scp03 initialize update response of %d bytes", len(resp))
+	}
+	cardChallenge, cardCryptogram := resp[13:21], resp[21:29]
+	context := append(append([]byte(nil), hostChallenge...), cardChallenge...)
+
+	encKey, err := scp03KDF(staticCMAC(keys, "enc"), 0x04, keys.size("enc")*8, context)
+	if err != nil {
+		return nil, nil, err
+	}
+	macKey, err := scp03KDF(staticCMAC(keys, "mac"), 0x06, keys.size("mac")*8, context)
+	if err != nil {
+		return nil, nil, err
+	}
+	want, err := scp03KDF(cmacWith(macKey), 0x00, 64, context)
+	if err != nil {
+		return nil, nil, err
+	}
+	if subtle.ConstantTimeCompare(want, cardCryptogram) != 1 {
+		return nil, nil, ErrCardCryptogram
+	}
+	host, err := scp03KDF(cmacWith(macKey), 0x01, 64, context)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &scp03{chain: make([]byte, 16), counter: make([]byte, 16), level: SecurityMAC}
+	s.senc, _ = aes.NewCipher(encKey)
+	s.smac, _ = aes.NewCipher(macKey)
+	securemem.Zero(encKey, macKey)
+	return s, host, nil
+}
+
+func (s *scp03) setLevel(level SecurityLevel) { s.level = level }
+
+func (s *scp03) zero() { securemem.Zero(s.chain, s.counter) }
+
+func (s *scp03) wrap(cmd *iso7816.CommandAPDU) ([]byte, error) {
+	data := cmd.Data
+	if s.level&0x02 != 0 {
+		incrementCounter(s.counter)
+		if len(data) > 0 {
+			iv := make([]byte, aes.BlockSize)
+			s.senc.Encrypt(iv, s.counter)
+			data = crypto.Pad(data, aes.BlockSize)
+			cipher.NewCBCEncrypter(s.senc, iv).CryptBlocks(data, data)
+		}
+	}
+	header := smHeader(cmd, len(data))
+	s.chain = crypto.CMAC(s.smac, append(append(append([]byte(nil), s.chain...), header...), data...))
+	return assemble(header, data, s.chain[:8], cmd.Ne)
+}
+
+func incrementCounter(c []byte) {
+	for i := len(c) - 1; i >= 0; i-- {
+		c[i]++
+		if c[i] != 0 {
+			return
+		}
+	}
+}
+
+// scp02 implements Secure Channel Protocol 02 with 3DES session keys and
+// retail MAC chaining with ICV encryption (i=15 and 55).
+type scp02 struct {
+	senc  cipher.Block
+	cmac  []byte // C-MAC session key.
+	icv   []byte // Last C-MAC, nil before the first command.
+	level SecurityLevel
+}
+
+func tripleDES(key []byte) (cipher.Block, error) {
+	if len(key) != 16 {
+		return nil, fmt.Errorf("globalplatform: 3des keys must be 16 bytes, got %d", len(key))
+	}
+	return des.NewTripleDESCipher(append(append([]byte(nil), key...), key[:8]...))
+}
+
+// scp02Derive derives a session key from the static key name of keys.
+func scp02Derive(keys Keys, name string, constant uint16, seq []byte) ([]byte, error) {
+	data := make([]byte, 16)
+	data[0], data[1] = byte(constant>>8), byte(constant)
+	copy(data[2:4], seq)
+	return keys.encrypt3DES(name, data)
+}
+
+// fullMAC is the ISO/IEC 9797-1 MAC algorithm 1 with 3DES, used for SCP02
+// cryptograms.
+func fullMAC(block cipher.Block, msg []byte) []byte {
+	padded := crypto.Pad(msg, des.BlockSize)
+	cipher.NewCBCEncrypter(block, make([]byte, des.BlockSize)).CryptBlocks(padded, padded)
+	return padded[len(padded)-des.BlockSize:]
+}
+
+// newSCP02 derives the session keys from the INITIALIZE UPDATE response,
+// verifies the card cryptogram and returns the host cryptogram.
+func newSCP02(keys Keys, hostChallenge, resp []byte) (*scp02, []byte, error) {
+	if len(resp) < 28 {
+		return nil, nil, fmt.Errorf("globalplatform: scp02 initialize update response of %d bytes", len(resp))
+	}
+	seq, cardChallenge, cardCryptogram := resp[12:14], resp[14:20], resp[20:28]
+	encKey, err := scp02Derive(keys, "enc", 0x0182, seq)
+	if err != nil {
+		return nil, nil, err
+	}
+	macKey, err := scp02Derive(keys, "mac", 0x0101, seq)
+	if err != nil {
+		return nil, nil, err
+	}
+	senc, _ := tripleDES(encKey)
+	securemem.Zero(encKey)
+
+	var msg []byte
+	msg = append(append(append(msg, hostChallenge...), seq...), cardChallenge...)
+	if subtle.ConstantTimeCompare(fullMAC(senc, msg), cardCryptogram) != 1 {
+		return nil, nil, ErrCardCryptogram
+	}
+	msg = append(append(append(msg[:0], seq...), cardChallenge...), hostChallenge...)
+	return &scp02{senc: senc, cmac: macKey, level: SecurityMAC}, fullMAC(senc, msg), nil
+}
+
+func (s *scp02) setLevel(level SecurityLevel) { s.level = level }
+
+func (s *scp02) zero() { securemem.Zero(s.cmac, s.icv) }
+
+func (s *scp02) wrap(cmd *iso7816.CommandAPDU) ([]byte, error) {
+	icv := make([]byte, des.BlockSize)
+	if s.icv != nil {
+		k1, err := des.NewCipher(s.cmac[:8])
+		if err != nil {
+			return nil, err
+		}
+		k1.Encrypt(icv, s.icv)
+	}
+	// The C-MAC is computed over the plain command data.
+	header := smHeader(cmd, len(cmd.Data))
+	mac, err := crypto.RetailMACIV(s.cmac, icv, crypto.Pad(append(header, cmd.Data...), des.BlockSize))
+	if err != nil {
+		return nil, err
+	}
+	s.icv = mac
+
+	data := cmd.Data
+	if s.level&0x02 != 0 && len(data) > 0 {
+		data = crypto.Pad(data, des.BlockSize)
+		cipher.NewCBCEncrypter(s.senc, make([]byte, des.BlockSize)).CryptBlocks(data, data)
+	}
+	return assemble(header, data, mac, cmd.Ne)
+}
+
+// smHeader returns the header of cmd with the secure messaging class bit
+// set and Lc covering n data bytes and the C-MAC.
+func smHeader(cmd *iso7816.CommandAPDU, n int) []byte {
+	return []byte{cmd.Cla | 0x04, cmd.Ins, cmd.P1, cmd.P2, byte(n + 8)}
+}
+
+// assemble returns the wrapped command of header, data and mac.
+func assemble(header, data, mac []byte, ne int) ([]byte, error) {
+	if len(data)+len(mac) > 255 {
+		return nil, fmt.Errorf("globalplatform: command data of %d bytes too long for the secure channel", len(data))
+	}
+	out := append([]byte{header[0], header[1], header[2], header[3], byte(len(data) + len(mac))}, data...)
+	out = append(out, mac...)
+	if ne > 0 {
+		out = append(out, byte(ne))
+	}
+	return out, nil
+}
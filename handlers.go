@@ -0,0 +1,109 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// atrHandler is a handler registered for ATRs matching a pattern.
+type atrHandler struct {
+	pattern tag.Pattern
+	handler CardHandler
+}
+
+// Handle registers h for cards detected as tag type t, replacing any handler
+// registered for t before. Types are detected with the detector of the SDK,
+// see WithDetector, probing the card so card drivers take part.
+func (sdk *SDK) Handle(t tag.Type, h CardHandler) {
+	sdk.mu.Lock()
+	defer sdk.mu.Unlock()
+	if sdk.typeHandlers == nil {
+		sdk.typeHandlers = make(map[tag.Type]CardHandler)
+	}
+	sdk.typeHandlers[t] = h
+}
+
+// HandleATR registers h for cards whose ATR matches p. ATR handlers take
+// precedence over tag type handlers and are tried in registration order.
+func (sdk *SDK) HandleATR(p tag.Pattern, h CardHandler) {
+	sdk.mu.Lock()
+	defer sdk.mu.Unlock()
+	sdk.atrHandlers = append(sdk.atrHandlers, atrHandler{pattern: p, handler: h})
+}
+
+// WithDetector makes the SDK detect tag types with d instead of
+// tag.Default, so the card drivers registered with d take part.
+func WithDetector(d *tag.Detector) Option {
+	return func(sdk *SDK) {
+		if d != nil {
+			sdk.detector = d
+		}
+	}
+}
+
+// cardType is the tag type of a card, detected once when first needed so
+// card drivers only probe cards whose dispatch depends on their type.
+type cardType struct {
+	detect func() tag.Type
+	done   bool
+	typ    tag.Type
+}
+
+// get returns the tag type of the card, detecting it on first use.
+func (t *cardType) get() tag.Type {
+	if !t.done {
+		t.typ, t.done = t.detect(), true
+	}
+	return t.typ
+}
+
+// detectType returns the lazily detected tag type of card, probed through
+// the instrumented card with ctx.
+func (sdk *SDK) detectType(ctx context.Context, card transport.Card, reader string) *cardType {
+	return &cardType{detect: func() tag.Type {
+		return sdk.detector.DetectCard(instrumentedCard{Card: card, ctx: ctx, reader: reader, sdk: sdk})
+	}}
+}
+
+// sessionType returns the tag type of a card for its CardContext: the
+// detected type when dispatch detected it, else the type of its ATR, so
+// cards are not probed for handlers not depending on their type.
+func (sdk *SDK) sessionType(typ *cardType, atr []byte) tag.Type {
+	if typ.done {
+		return typ.typ
+	}
+	return sdk.detector.Detect(tag.Signature{ATR: atr})
+}
+
+// handlerFor returns the handler for a card with the given ATR and tag
+// type: the first ATR handler matching it, else the handler of its tag type,
+// else the handler set with WithCardHandler. It returns nil when none
+// applies. The type is only detected when type handlers are registered and
+// no ATR handler applies.
+func (sdk *SDK) handlerFor(atr []byte, typ *cardType) CardHandler {
+	sdk.mu.RLock()
+	for _, h := range sdk.atrHandlers {
+		if h.pattern.Match(atr) {
+			sdk.mu.RUnlock()
+			return h.handler
+		}
+	}
+	byType := len(sdk.typeHandlers) > 0
+	sdk.mu.RUnlock()
+	var t tag.Type
+	if byType {
+		t = typ.get()
+	}
+	sdk.mu.RLock()
+	defer sdk.mu.RUnlock()
+	if h, ok := sdk.typeHandlers[t]; ok && byType {
+		return h
+	}
+	return sdk.cardHandler
+}
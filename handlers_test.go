@@ -0,0 +1,113 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+func TestHandlerDispatch(t *testing.T) {
+	var called string
+	handler := func(name string) CardHandler {
+		return func(context.Context, cardreader.Event, transport.Card) error {
+			called = name
+			return nil
+		}
+	}
+	sdk := New(WithCardHandler(handler("default")))
+	sdk.Handle(tag.TypeDESFire, handler("desfire"))
+	sdk.HandleATR(tag.MustParsePattern("3B 02 14 ??"), handler("atr"))
+
+	tests := []struct {
+		atr     string
+		want    string
+		detects bool
+	}{
+		{"3B021450", "atr", false},
+		{"3B8180018080", "desfire", true},
+		{"3B00", "default", true},
+	}
+	for _, tt := range tests {
+		atr, _ := hex.DecodeString(tt.atr)
+		called = ""
+		typ := &cardType{detect: func() tag.Type { return tag.Detect(tag.Signature{ATR: atr}) }}
+		h := sdk.handlerFor(atr, typ)
+		if typ.done != tt.detects {
+			t.Errorf("handlerFor(%s) detected the type = %v, want %v", tt.atr, typ.done, tt.detects)
+		}
+		if h == nil {
+			t.Fatalf("handlerFor(%s) = nil", tt.atr)
+		}
+		_ = h(context.Background(), cardreader.Event{}, nil)
+		if called != tt.want {
+			t.Errorf("handlerFor(%s) called %q, want %q", tt.atr, called, tt.want)
+		}
+	}
+
+	typ := &cardType{detect: func() tag.Type { return tag.TypeUnknown }}
+	if h := New().handlerFor(nil, typ); h != nil || typ.done {
+		t.Error("handlerFor() without handlers returned a handler")
+	}
+}
+
+// testDriverType is the tag type of the card driver of
+// TestHandleDriverType, registered once as tag types are global.
+var testDriverType = tag.RegisterType("scardkit-test-driver")
+
+func TestHandleDriverType(t *testing.T) {
+	atr := []byte{0x3B, 0x02, 0xAC, 0x3E}
+	d := tag.NewDetector()
+	probes := 0
+	d.Register(testDriverType, tag.MatchFunc(func(a, _ []byte, probe apdu.Transceiver) bool {
+		if probe == nil {
+			return false
+		}
+		probes++
+		return bytes.Equal(a, atr)
+	}))
+	tests := []struct {
+		name     string
+		register func(sdk *SDK, h CardHandler)
+		probes   int
+	}{
+		{"type", func(sdk *SDK, h CardHandler) { sdk.Handle(testDriverType, h) }, 1},
+		{"filter", func(sdk *SDK, h CardHandler) { sdk.HandleFilter(Filter{Types: []tag.Type{testDriverType}}, h) }, 1},
+		{"atr", func(sdk *SDK, h CardHandler) {
+			sdk.Handle(testDriverType, h)
+			sdk.HandleATR(tag.MustParsePattern("3B 02 AC 3E"), h)
+		}, 0},
+	}
+	for _, tt := range tests {
+		probes = 0
+		var got tag.Type
+		called := false
+		sdk := New(WithDetector(d), WithBackend(&fakeBackend{reader: *cardreader.NewReader("virtual"), card: &memCard{atr: atr}}))
+		tt.register(sdk, CardFunc(func(c *CardContext) error {
+			called, got = true, c.Type
+			return nil
+		}))
+		if _, err := sdk.WaitForCard(context.Background(), time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if !called || probes != tt.probes {
+			t.Errorf("%s: handler called = %v after %d probes, want %d", tt.name, called, probes, tt.probes)
+		}
+		if tt.probes > 0 && got != testDriverType {
+			t.Errorf("%s: handler saw type %v, want %v", tt.name, got, testDriverType)
+		}
+	}
+	if typ := tag.Detect(tag.Signature{ATR: atr}); typ == testDriverType {
+		t.Error("the driver leaked into tag.Default")
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+)
+
+// DefaultHistorySize is the number of entries kept by SDK.History.
+const DefaultHistorySize = 256
+
+// HistoryKind classifies a history entry.
+type HistoryKind uint8
+
+const (
+	HistoryReaderAdded      HistoryKind = iota + 1 // Reader appeared in the reader list.
+	HistoryReaderRemoved                           // Reader disappeared from the reader list.
+	HistoryStateChanged                            // Reader state changed, PC/SC backend only.
+	HistoryCardConnected                           // SDK connected to a card.
+	HistoryReaderSelected                          // Reader put back into use.
+	HistoryReaderDeselected                        // Reader taken out of use.
+)
+
+// String returns the name of the kind.
+func (k HistoryKind) String() string {
+	switch k {
+	case HistoryReaderAdded:
+		return "reader added"
+	case HistoryReaderRemoved:
+		return "reader removed"
+	case HistoryStateChanged:
+		return "state changed"
+	case HistoryCardConnected:
+		return "card connected"
+	case HistoryReaderSelected:
+		return "reader selected"
+	case HistoryReaderDeselected:
+		return "reader deselected"
+	default:
+		return "unknown"
+	}
+}
+
+// HistoryEntry is a reader event recorded by the SDK.
+type HistoryEntry struct {
+	Time     time.Time
+	Reader   string
+	Kind     HistoryKind
+	From, To pcsc.State // Reader states of HistoryStateChanged entries.
+}
+
+// WithHistorySize sets the number of entries kept by History. A size not
+// greater than zero disables the history.
+func WithHistorySize(n int) Option {
+	return func(sdk *SDK) {
+		sdk.history = newRing(n)
+	}
+}
+
+// History returns the most recent reader events, oldest first. It is meant
+// for postmortem debugging, e.g. of a reader which stopped seeing cards, and
+// is kept without enabling any logging.
+func (sdk *SDK) History() []HistoryEntry { return sdk.history.entries() }
+
+// ring is a bounded history buffer, safe for concurrent use. The zero
+// value and a nil ring record nothing.
+type ring struct {
+	mu   sync.Mutex
+	buf  []HistoryEntry
+	next int
+	full bool
+}
+
+func newRing(n int) *ring {
+	if n <= 0 {
+		return nil
+	}
+	return &ring{buf: make([]HistoryEntry, n)}
+}
+
+func (r *ring) add(e HistoryEntry) {
+	if r == nil || len(r.buf) == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf[r.next] = e
+	r.next = (r.next + 1) % len(r.buf)
+	r.full = r.full || r.next == 0
+}
+
+func (r *ring) entries() []HistoryEntry {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]HistoryEntry(nil), r.buf[:r.next]...)
+	}
+	return append(append([]HistoryEntry(nil), r.buf[r.next:]...), r.buf[:r.next]...)
+}
+
+// recordReaders adds entries for readers which appeared in or disappeared
+// from the reader list since the last call, and emits their events.
+func (sdk *SDK) recordReaders(readers []cardreader.Reader) {
+	var events []cardreader.Event
+	sdk.mu.Lock()
+	if sdk.sameReaders(readers) {
+		sdk.mu.Unlock()
+		return
+	}
+	seen := make(map[string]bool, len(readers))
+	for _, r := range readers {
+		seen[r.Name] = true
+		if !sdk.knownReaders[r.Name] {
+			sdk.history.add(HistoryEntry{Reader: r.Name, Kind: HistoryReaderAdded})
+			events = append(events, cardreader.Event{Type: cardreader.EventReaderAdded, Reader: r.Name})
+		}
+	}
+	for name := range sdk.knownReaders {
+		if !seen[name] {
+			delete(sdk.failed, name)
+			sdk.history.add(HistoryEntry{Reader: name, Kind: HistoryReaderRemoved})
+			events = append(events, cardreader.Event{Type: cardreader.EventReaderRemoved, Reader: name})
+		}
+	}
+	sdk.knownReaders = seen
+	sdk.mu.Unlock()
+	for _, ev := range events {
+		sdk.emit(ev)
+	}
+}
+
+// sameReaders reports whether readers are the readers of the last reader
+// list, so polling an unchanged list does not allocate. sdk.mu is held.
+func (sdk *SDK) sameReaders(readers []cardreader.Reader) bool {
+	if sdk.knownReaders == nil || len(readers) != len(sdk.knownReaders) {
+		return false
+	}
+	for _, r := range readers {
+		if !sdk.knownReaders[r.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// recordState adds an entry for a reader state change seen by the PC/SC
+// backend.
+func (sdk *SDK) recordState(reader string, from, to pcsc.State) {
+	sdk.history.add(HistoryEntry{Reader: reader, Kind: HistoryStateChanged, From: from, To: to})
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+)
+
+func TestRing(t *testing.T) {
+	tests := []struct {
+		size, adds int
+		want       []string
+	}{
+		{0, 3, nil},
+		{3, 0, nil},
+		{3, 2, []string{"r0", "r1"}},
+		{3, 3, []string{"r0", "r1", "r2"}},
+		{3, 5, []string{"r2", "r3", "r4"}},
+	}
+	for _, tt := range tests {
+		r := newRing(tt.size)
+		for i := 0; i < tt.adds; i++ {
+			r.add(HistoryEntry{Reader: "r" + string(rune('0'+i))})
+		}
+		got := r.entries()
+		if len(got) != len(tt.want) {
+			t.Errorf("size %d, %d adds: entries() = %v, want %v", tt.size, tt.adds, got, tt.want)
+			continue
+		}
+		for i, e := range got {
+			if e.Reader != tt.want[i] || e.Time.IsZero() {
+				t.Errorf("size %d, %d adds: entry %d = %+v, want reader %s", tt.size, tt.adds, i, e, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	b := &fakeBackend{reader: cardreader.Reader{Name: "virtual"}, card: &memCard{}, empty: 1}
+	sdk := New(WithBackend(b))
+	if _, err := sdk.WaitForCard(context.Background(), time.Second); err != nil {
+		t.Fatalf("WaitForCard() error = %v", err)
+	}
+	b.reader = cardreader.Reader{Name: "other"}
+	sdk.recordReaders([]cardreader.Reader{b.reader})
+	sdk.recordState("other", pcsc.StateEmpty, pcsc.StatePresent|pcsc.StateInUse)
+
+	want := []struct {
+		reader string
+		kind   HistoryKind
+	}{
+		{"virtual", HistoryReaderAdded},
+		{"virtual", HistoryCardConnected},
+		{"other", HistoryReaderAdded},
+		{"virtual", HistoryReaderRemoved},
+		{"other", HistoryStateChanged},
+	}
+	got := sdk.History()
+	if len(got) != len(want) {
+		t.Fatalf("History() = %+v, want %d entries", got, len(want))
+	}
+	for i, w := range want {
+		if got[i].Reader != w.reader || got[i].Kind != w.kind {
+			t.Errorf("entry %d = %s %s, want %s %s", i, got[i].Reader, got[i].Kind, w.reader, w.kind)
+		}
+	}
+	if s := got[4].To.String(); s != "PRESENT|INUSE" {
+		t.Errorf("To.String() = %q", s)
+	}
+	if h := New(WithHistorySize(0)).History(); h != nil {
+		t.Errorf("disabled History() = %v", h)
+	}
+}
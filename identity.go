@@ -0,0 +1,35 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+)
+
+// WithIdentityResolver makes the SDK resolve the UID of each card with r
+// before the card inserted event is emitted and dispatched, so sinks,
+// filters and handlers see its Identity. Cards whose lookup fails are
+// still handled, without identity, and the failure is logged. Wrap r in a
+// cardreader.CachedResolver to avoid a lookup per tap.
+func WithIdentityResolver(r cardreader.IdentityResolver) Option {
+	return func(sdk *SDK) {
+		sdk.identities = r
+	}
+}
+
+// resolveIdentity sets the Identity of ev when the SDK has a resolver.
+func (sdk *SDK) resolveIdentity(ctx context.Context, ev *cardreader.Event) {
+	if sdk.identities == nil {
+		return
+	}
+	events := []cardreader.Event{*ev}
+	if err := cardreader.ResolveIdentities(ctx, sdk.identities, events); err != nil {
+		sdk.logger.WarnContext(ctx, "resolve card identity", "reader", ev.Reader, "error", err)
+		return
+	}
+	ev.Identity = events[0].Identity
+}
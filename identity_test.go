@@ -0,0 +1,68 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/transport"
+	"github.com/happy-sdk/scardkit/x/virtualreader"
+)
+
+func TestWithIdentityResolver(t *testing.T) {
+	alice := &cardreader.Identity{ID: "42", Name: "Alice"}
+	tests := []struct {
+		name string
+		err  error
+		want *cardreader.Identity
+	}{
+		{"resolved", nil, alice},
+		{"lookup failed", errors.New("directory down"), nil},
+	}
+	for _, tt := range tests {
+		vr := virtualreader.New("gate")
+		if err := vr.Insert("gate", virtualreader.NewNTAG215([]byte{0x04, 1, 2, 3, 4, 5, 6})); err != nil {
+			t.Fatal(err)
+		}
+		resolver := cardreader.IdentityResolverFunc(func(_ context.Context, uids []string) (map[string]*cardreader.Identity, error) {
+			if tt.err != nil {
+				return nil, tt.err
+			}
+			return map[string]*cardreader.Identity{"04010203040506": alice}, nil
+		})
+		var handled *cardreader.Identity
+		events := make(chan cardreader.Event, 4)
+		sdk := New(WithBackend(vr), WithIdentityResolver(resolver), WithEventSink(ChanSink(events), 0),
+			WithCardHandler(func(_ context.Context, ev cardreader.Event, _ transport.Card) error {
+				handled = ev.Identity
+				return nil
+			}))
+		if _, err := sdk.WaitForCard(context.Background(), time.Second); err != nil {
+			t.Fatalf("%s: WaitForCard() error = %v", tt.name, err)
+		}
+		if err := sdk.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if handled != tt.want {
+			t.Errorf("%s: handler identity = %v, want %v", tt.name, handled, tt.want)
+		}
+		inserted := 0
+		for len(events) > 0 {
+			if ev := <-events; ev.Type == cardreader.EventCardInserted {
+				inserted++
+				if ev.Identity != tt.want {
+					t.Errorf("%s: emitted identity = %v, want %v", tt.name, ev.Identity, tt.want)
+				}
+			}
+		}
+		if inserted != 1 {
+			t.Errorf("%s: %d card inserted events emitted, want 1", tt.name, inserted)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package integrations bridges card events to other systems. Its
+// subpackages, such as webhook, are optional and share Event, the JSON form
+// of a card event.
+package integrations
+
+import (
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+)
+
+// Event is the JSON form of a cardreader.Event. Byte strings are upper case
+// hex.
+type Event struct {
+	Type      string    `json:"type"`
+	Reader    string    `json:"reader"`
+	Zone      string    `json:"zone,omitempty"`
+	UID       string    `json:"uid,omitempty"`
+	ATR       string    `json:"atr,omitempty"`
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"session_id,omitempty"`
+	Identity  *Identity `json:"identity,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	// NDEF are the records of the NDEF message read from the card, if any.
+	NDEF []Record `json:"ndef,omitempty"`
+}
+
+// Identity is the JSON form of a cardreader.Identity.
+type Identity struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Record is the JSON form of an NDEF record. Text and URI hold the decoded
+// content of well-known text and URI records.
+type Record struct {
+	TNF     uint8  `json:"tnf"`
+	Type    string `json:"type,omitempty"`
+	ID      string `json:"id,omitempty"`
+	Payload string `json:"payload,omitempty"`
+	Text    string `json:"text,omitempty"`
+	Lang    string `json:"lang,omitempty"`
+	URI     string `json:"uri,omitempty"`
+}
+
+// NewEvent returns the JSON form of ev.
+func NewEvent(ev cardreader.Event) Event {
+	e := Event{
+		Type:      ev.Type.String(),
+		Reader:    ev.Reader,
+		Zone:      ev.Zone,
+		UID:       upperHex(ev.UID),
+		ATR:       upperHex(ev.ATR),
+		Time:      ev.Time,
+		SessionID: ev.SessionID,
+		Reason:    ev.Reason,
+	}
+	if id := ev.Identity; id != nil {
+		e.Identity = &Identity{ID: id.ID, Name: id.Name, Attributes: id.Attributes}
+	}
+	if ev.NDEF != nil {
+		e.SetNDEF(ev.NDEF)
+	}
+	return e
+}
+
+// SetNDEF sets the records of e from msg.
+func (e *Event) SetNDEF(msg *ndef.Message) {
+	e.NDEF = e.NDEF[:0]
+	for _, r := range msg.Records {
+		rec := Record{
+			TNF:     uint8(r.TNF),
+			Type:    string(r.Type),
+			ID:      string(r.ID),
+			Payload: upperHex(r.Payload),
+		}
+		if text, lang, err := r.Text(); err == nil {
+			rec.Text, rec.Lang = text, lang
+		} else if uri, err := r.URI(); err == nil {
+			rec.URI = uri
+		}
+		e.NDEF = append(e.NDEF, rec)
+	}
+}
+
+// ReadNDEF reads the NDEF message of card into e. Cards without an NDEF
+// message leave e unchanged.
+func (e *Event) ReadNDEF(card tag.Card) error {
+	data, err := tag.ReadNDEF(card)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	msg := ndef.NewMessage()
+	if err := msg.Unmarshal(data); err != nil {
+		return err
+	}
+	e.SetNDEF(msg)
+	return nil
+}
+
+func upperHex(b []byte) string {
+	return strings.ToUpper(hex.EncodeToString(b))
+}
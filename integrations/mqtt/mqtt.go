@@ -0,0 +1,376 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package mqtt publishes card events and reader health to an MQTT 3.1.1
+// broker, the usual setup of access control and attendance systems built
+// on single board computers with readers. A Publisher keeps a connection to
+// the broker, reconnecting with backoff, and delivers queued messages at
+// most once (QoS 0) or at least once (QoS 1).
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit"
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/integrations"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// QoS is the quality of service of a message.
+type QoS byte
+
+const (
+	AtMostOnce  QoS = 0 // Delivered at most once, never acknowledged.
+	AtLeastOnce QoS = 1 // Redelivered until acknowledged by the broker.
+)
+
+// Defaults of a Publisher.
+const (
+	DefaultEventTopic  = "scardkit/{reader}/events"
+	DefaultHealthTopic = "scardkit/{reader}/health"
+	DefaultKeepAlive   = 30 * time.Second
+	DefaultQueueSize   = 256
+	DefaultBackoff     = time.Second
+	MaxBackoff         = time.Minute
+)
+
+// ErrQueueFull is returned when a message is published while the queue is
+// full, i.e. the broker is unreachable for long.
+var ErrQueueFull = errors.New("mqtt: queue full")
+
+// ErrPasswordWithoutUsername is returned by Run for a Publisher with a
+// Password but no Username, which MQTT 3.1.1 does not allow.
+var ErrPasswordWithoutUsername = errors.New("mqtt: password set without username")
+
+// message is a queued message.
+type message struct {
+	topic   string
+	payload []byte
+	qos     QoS
+	retain  bool
+}
+
+// Health is the message published for a reader to HealthTopic.
+type Health struct {
+	Reader     string    `json:"reader"`
+	State      string    `json:"state"`
+	Present    bool      `json:"present"`
+	Mute       bool      `json:"mute,omitempty"`
+	EventCount int       `json:"event_count"`
+	Time       time.Time `json:"time"`
+}
+
+// Publisher publishes to a broker. Its zero value, with Broker set, is
+// ready to use; Run maintains the connection.
+type Publisher struct {
+	// Broker is the host:port of the broker.
+	Broker             string
+	ClientID           string
+	Username, Password string // Password requires Username.
+	// Dial, when set, connects to the broker instead of a plain TCP
+	// connection, e.g. with TLS.
+	Dial func(ctx context.Context) (net.Conn, error)
+
+	// EventTopic and HealthTopic are the topics events and reader health
+	// are published to; {reader} is replaced with the reader name.
+	EventTopic, HealthTopic string
+	// StatusTopic, when set, receives a retained "online" on connect and
+	// "offline" as the will of the connection.
+	StatusTopic string
+	QoS         QoS
+	// KeepAlive is the interval of keep alive pings, DefaultKeepAlive
+	// when 0.
+	KeepAlive time.Duration
+	// QueueSize is the number of messages queued, DefaultQueueSize when 0.
+	QueueSize int
+	// Backoff is the delay before the first reconnect, doubling up to
+	// MaxBackoff, DefaultBackoff when 0.
+	Backoff time.Duration
+
+	// Readers, when set, is polled every HealthInterval to publish the
+	// health of the readers, e.g. the Readers method of an SDK.
+	Readers        func() ([]scardkit.ReaderInfo, error)
+	HealthInterval time.Duration
+	// ReadNDEF makes HandleCard include the NDEF message of the card.
+	ReadNDEF bool
+	// Logger, when set, logs connection failures.
+	Logger *slog.Logger
+
+	once  sync.Once
+	queue chan *message
+	// pending is a QoS 1 message sent but not acknowledged when the
+	// connection was lost, sent again on reconnect.
+	pending *message
+	nextID  uint16
+}
+
+func (p *Publisher) init() {
+	p.once.Do(func() {
+		n := p.QueueSize
+		if n <= 0 {
+			n = DefaultQueueSize
+		}
+		p.queue = make(chan *message, n)
+	})
+}
+
+// HandleCard publishes the event of the card, with its NDEF message when
+// ReadNDEF is set. It has the signature of a scardkit.CardHandler.
+func (p *Publisher) HandleCard(ctx context.Context, ev cardreader.Event, card transport.Card) error {
+	e := integrations.NewEvent(ev)
+	if p.ReadNDEF {
+		if err := e.ReadNDEF(card); err != nil {
+			return fmt.Errorf("read ndef: %w", err)
+		}
+	}
+	return p.PublishEvent(e)
+}
+
+// PublishEvent queues e for EventTopic.
+func (p *Publisher) PublishEvent(e integrations.Event) error {
+	return p.publishJSON(p.topic(p.EventTopic, DefaultEventTopic, e.Reader), e, false)
+}
+
+// PublishHealth queues the health of readers for HealthTopic, retained so
+// subscribers get the last state right away.
+func (p *Publisher) PublishHealth(readers []scardkit.ReaderInfo) error {
+	now := time.Now()
+	for _, r := range readers {
+		h := Health{
+			Reader:     r.Name,
+			State:      r.State.String(),
+			Present:    r.Present(),
+			Mute:       r.Mute(),
+			EventCount: r.EventCount,
+			Time:       now,
+		}
+		if err := p.publishJSON(p.topic(p.HealthTopic, DefaultHealthTopic, r.Name), h, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Publish queues payload for topic.
+func (p *Publisher) Publish(topic string, payload []byte, retain bool) error {
+	p.init()
+	select {
+	case p.queue <- &message{topic: topic, payload: payload, qos: p.QoS, retain: retain}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (p *Publisher) publishJSON(topic string, v any, retain bool) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return p.Publish(topic, payload, retain)
+}
+
+// topic expands the {reader} placeholder of t, or def when t is empty.
+// Characters special to MQTT topics are replaced in reader names.
+func (p *Publisher) topic(t, def, reader string) string {
+	if t == "" {
+		t = def
+	}
+	return strings.ReplaceAll(t, "{reader}", strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(reader))
+}
+
+// Run connects to the broker and delivers queued messages until ctx is
+// done, reconnecting whenever the connection is lost. It returns the error
+// of ctx, or ErrPasswordWithoutUsername right away.
+func (p *Publisher) Run(ctx context.Context) error {
+	if p.Password != "" && p.Username == "" {
+		return ErrPasswordWithoutUsername
+	}
+	p.init()
+	backoff := p.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+	delay := backoff
+	for {
+		conn, err := p.connect(ctx)
+		if err == nil {
+			delay = backoff
+			err = p.serve(ctx, conn)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if p.Logger != nil {
+			p.Logger.Warn("mqtt connection lost", slog.String("broker", p.Broker), slog.Any("err", err))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, MaxBackoff)
+	}
+}
+
+// connect opens a session with the broker.
+func (p *Publisher) connect(ctx context.Context) (*conn, error) {
+	var nc net.Conn
+	var err error
+	if p.Dial != nil {
+		nc, err = p.Dial(ctx)
+	} else {
+		nc, err = (&net.Dialer{}).DialContext(ctx, "tcp", p.Broker)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var will *message
+	if p.StatusTopic != "" {
+		will = &message{topic: p.StatusTopic, payload: []byte("offline"), qos: p.QoS, retain: true}
+	}
+	c := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	nc.SetDeadline(time.Now().Add(p.keepAlive()))
+	if err := c.write(connectPacket(p.ClientID, p.Username, p.Password, uint16(p.keepAlive()/time.Second), will)); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	ack, err := readPacket(c.r)
+	if err == nil {
+		err = connackError(ack)
+	}
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	nc.SetDeadline(time.Time{})
+	if p.StatusTopic != "" {
+		if err := c.write(publishPacket(&message{topic: p.StatusTopic, payload: []byte("online"), retain: true}, 0, false)); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (p *Publisher) keepAlive() time.Duration {
+	if p.KeepAlive <= 0 {
+		return DefaultKeepAlive
+	}
+	return p.KeepAlive
+}
+
+// conn is a connection to the broker.
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *conn) write(p packet) error {
+	_, err := c.Write(p.marshal())
+	return err
+}
+
+// serve delivers messages on c until it fails or ctx is done.
+func (p *Publisher) serve(ctx context.Context, c *conn) error {
+	done := make(chan struct{})
+	defer close(done)
+	defer c.Close()
+	acks := make(chan uint16, 1)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			pk, err := readPacket(c.r)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if pk.typ == packetPuback && len(pk.body) == 2 {
+				select {
+				case acks <- binary.BigEndian.Uint16(pk.body):
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	ping := time.NewTicker(p.keepAlive() / 2)
+	defer ping.Stop()
+	var health <-chan time.Time
+	if p.Readers != nil && p.HealthInterval > 0 {
+		t := time.NewTicker(p.HealthInterval)
+		defer t.Stop()
+		health = t.C
+	}
+	if p.pending != nil {
+		if err := p.send(c, p.pending, true, acks, readErr); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			c.write(packet{typ: packetDisconnect})
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case <-ping.C:
+			if err := c.write(packet{typ: packetPingreq}); err != nil {
+				return err
+			}
+		case <-health:
+			if readers, err := p.Readers(); err == nil {
+				p.PublishHealth(readers)
+			}
+		case m := <-p.queue:
+			if err := p.send(c, m, false, acks, readErr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// send publishes m, waiting for the acknowledgement of QoS 1 messages. A
+// message not acknowledged is kept pending.
+func (p *Publisher) send(c *conn, m *message, dup bool, acks <-chan uint16, readErr <-chan error) error {
+	if m.qos == AtMostOnce {
+		return c.write(publishPacket(m, 0, false))
+	}
+	p.pending = m
+	p.nextID++
+	if p.nextID == 0 {
+		p.nextID = 1
+	}
+	id := p.nextID
+	if err := c.write(publishPacket(m, id, dup)); err != nil {
+		return err
+	}
+	timeout := time.NewTimer(p.keepAlive())
+	defer timeout.Stop()
+	for {
+		select {
+		case got := <-acks:
+			if got == id {
+				p.pending = nil
+				return nil
+			}
+		case err := <-readErr:
+			return err
+		case <-timeout.C:
+			return errors.New("mqtt: publish not acknowledged")
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit"
+	"github.com/happy-sdk/scardkit/integrations"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+)
+
+// broker is a session of the fake broker.
+type broker struct {
+	t *testing.T
+	c net.Conn
+	r *bufio.Reader
+}
+
+func accept(t *testing.T, l net.Listener) *broker {
+	t.Helper()
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetDeadline(time.Now().Add(2 * time.Second))
+	b := &broker{t: t, c: c, r: bufio.NewReader(c)}
+	p := b.read(packetConnect)
+	// Clean session, will retained, user name and password.
+	if flags := p.body[7]; flags != 0x02|0x04|0x08|0x20|0x80|0x40 {
+		t.Errorf("CONNECT flags %08b", flags)
+	}
+	b.c.Write(packet{typ: packetConnack, body: []byte{0, 0}}.marshal())
+	if topic, _, payload, _ := b.publish(); topic != "kiosk/status" || string(payload) != "online" {
+		t.Errorf("status %s %q", topic, payload)
+	}
+	return b
+}
+
+func (b *broker) read(typ byte) packet {
+	b.t.Helper()
+	p, err := readPacket(b.r)
+	if err != nil || p.typ != typ {
+		b.t.Fatalf("packet %d, %v, want %d", p.typ, err, typ)
+	}
+	return p
+}
+
+func (b *broker) publish() (topic string, id uint16, payload []byte, flags byte) {
+	b.t.Helper()
+	p := b.read(packetPublish)
+	n := int(binary.BigEndian.Uint16(p.body))
+	topic, rest := string(p.body[2:2+n]), p.body[2+n:]
+	if p.flags&0x06 != 0 {
+		id, rest = binary.BigEndian.Uint16(rest), rest[2:]
+	}
+	return topic, id, rest, p.flags
+}
+
+func TestPublisher(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	p := &Publisher{
+		Broker:      l.Addr().String(),
+		ClientID:    "kiosk-1",
+		Username:    "u",
+		Password:    "p",
+		StatusTopic: "kiosk/status",
+		QoS:         AtLeastOnce,
+		Backoff:     time.Millisecond,
+	}
+	if err := p.PublishEvent(integrations.Event{Type: "card-inserted", Reader: "ACS ACR122U/0", UID: "04A1"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	// The first session is lost before the event is acknowledged.
+	b := accept(t, l)
+	if topic, _, _, flags := b.publish(); topic != "scardkit/ACS ACR122U_0/events" || flags&0x08 != 0 {
+		t.Errorf("event %s flags %04b", topic, flags)
+	}
+	b.c.Close()
+
+	b = accept(t, l)
+	topic, id, payload, flags := b.publish()
+	var e integrations.Event
+	if json.Unmarshal(payload, &e); topic != "scardkit/ACS ACR122U_0/events" || flags&0x08 == 0 || e.UID != "04A1" {
+		t.Errorf("redelivered event %s flags %04b %s", topic, flags, payload)
+	}
+	b.c.Write(packet{typ: packetPuback, body: binary.BigEndian.AppendUint16(nil, id)}.marshal())
+
+	p.PublishHealth([]scardkit.ReaderInfo{{Name: "r0", State: pcsc.StatePresent, EventCount: 3}})
+	topic, id, payload, flags = b.publish()
+	var h Health
+	if json.Unmarshal(payload, &h); topic != "scardkit/r0/health" || flags&0x01 == 0 || !h.Present || h.EventCount != 3 {
+		t.Errorf("health %s flags %04b %s", topic, flags, payload)
+	}
+	b.c.Write(packet{typ: packetPuback, body: binary.BigEndian.AppendUint16(nil, id)}.marshal())
+
+	cancel()
+	b.read(packetDisconnect)
+}
+
+func TestPacket(t *testing.T) {
+	for _, n := range []int{0, 127, 128, 16383, 16384, 300000} {
+		p := packet{typ: packetPublish, flags: 2, body: make([]byte, n)}
+		got, err := readPacket(bufio.NewReader(bytes.NewReader(p.marshal())))
+		if err != nil || got.typ != p.typ || got.flags != p.flags || len(got.body) != n {
+			t.Errorf("%d: readPacket() = %d %d %d, %v", n, got.typ, got.flags, len(got.body), err)
+		}
+	}
+	for _, tt := range []struct {
+		username, password string
+		flags              byte
+	}{
+		{"", "", 0x02},
+		{"u", "", 0x82},
+		{"u", "p", 0xC2},
+		{"", "p", 0x02},
+	} {
+		if p := connectPacket("c", tt.username, tt.password, 30, nil); p.body[7] != tt.flags {
+			t.Errorf("connectPacket(%q, %q) flags = %02X, want %02X", tt.username, tt.password, p.body[7], tt.flags)
+		}
+	}
+	if err := (&Publisher{Broker: "localhost:1", Password: "p"}).Run(context.Background()); !errors.Is(err, ErrPasswordWithoutUsername) {
+		t.Errorf("Run() with a password only error = %v", err)
+	}
+	for rc, ok := range []bool{true, false, false, false, false, false} {
+		if err := connackError(packet{typ: packetConnack, body: []byte{0, byte(rc)}}); (err == nil) != ok {
+			t.Errorf("connackError(%d) = %v", rc, err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Control packet types of MQTT 3.1.1.
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetPingreq    = 12
+	packetPingresp   = 13
+	packetDisconnect = 14
+)
+
+// maxRemaining is the largest remaining length of a packet.
+const maxRemaining = 268435455
+
+// errMalformed is returned for packets which cannot be decoded.
+var errMalformed = errors.New("mqtt: malformed packet")
+
+// packet is an MQTT control packet.
+type packet struct {
+	typ   byte
+	flags byte
+	body  []byte
+}
+
+func (p packet) marshal() []byte {
+	out := []byte{p.typ<<4 | p.flags}
+	n := len(p.body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, p.body...)
+}
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	h, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	n, mul := 0, 1
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		n += int(b&0x7F) * mul
+		if b&0x80 == 0 {
+			break
+		}
+		if mul *= 128; i == 3 {
+			return packet{}, errMalformed
+		}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{typ: h >> 4, flags: h & 0x0F, body: body}, nil
+}
+
+// appendString appends s as an MQTT UTF-8 string.
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// connectPacket returns the CONNECT packet of opts, with a clean session.
+func connectPacket(clientID, username, password string, keepAlive uint16, will *message) packet {
+	flags := byte(0x02)
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, 0)
+	body = binary.BigEndian.AppendUint16(body, keepAlive)
+	body = appendString(body, clientID)
+	if will != nil {
+		flags |= 0x04 | byte(will.qos)<<3
+		if will.retain {
+			flags |= 0x20
+		}
+		body = appendString(body, will.topic)
+		body = appendString(body, string(will.payload))
+	}
+	if username != "" {
+		flags |= 0x80
+		body = appendString(body, username)
+	}
+	// MQTT 3.1.1 section 3.1.2.9: no password without a username.
+	if password != "" && username != "" {
+		flags |= 0x40
+		body = appendString(body, password)
+	}
+	body[7] = flags
+	return packet{typ: packetConnect, body: body}
+}
+
+// connackError returns the error of a CONNACK packet, nil when accepted.
+func connackError(p packet) error {
+	if p.typ != packetConnack || len(p.body) != 2 {
+		return fmt.Errorf("mqtt: unexpected packet %d, want CONNACK", p.typ)
+	}
+	switch rc := p.body[1]; rc {
+	case 0:
+		return nil
+	case 1:
+		return errors.New("mqtt: connection refused: unacceptable protocol version")
+	case 2:
+		return errors.New("mqtt: connection refused: identifier rejected")
+	case 3:
+		return errors.New("mqtt: connection refused: server unavailable")
+	case 4:
+		return errors.New("mqtt: connection refused: bad user name or password")
+	case 5:
+		return errors.New("mqtt: connection refused: not authorized")
+	default:
+		return fmt.Errorf("mqtt: connection refused: code %d", rc)
+	}
+}
+
+// publishPacket returns the PUBLISH packet of m, with packet id id for QoS 1.
+func publishPacket(m *message, id uint16, dup bool) packet {
+	flags := byte(m.qos) << 1
+	if dup {
+		flags |= 0x08
+	}
+	if m.retain {
+		flags |= 0x01
+	}
+	body := appendString(nil, m.topic)
+	if m.qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	return packet{typ: packetPublish, flags: flags, body: append(body, m.payload...)}
+}
@@ -0,0 +1,218 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package webhook POSTs card events to an HTTP endpoint, turning any reader
+// into a network tap source. Events are queued and delivered in order by
+// Run, retrying failed deliveries with exponential backoff; a Hook is also
+// a scardkit.EventSink. With a secret set, every request carries an
+// HMAC-SHA256 signature of its body which the receiver checks with Verify.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/integrations"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// SignatureHeader is the request header carrying the signature of the body,
+// "sha256=" followed by the hex HMAC-SHA256 of the body keyed with the
+// secret.
+const SignatureHeader = "X-Scardkit-Signature"
+
+// Defaults of a Hook.
+const (
+	DefaultQueueSize   = 256
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = time.Second
+	DefaultTimeout     = 10 * time.Second
+)
+
+// ErrQueueFull is returned when an event is enqueued while the queue is
+// full, i.e. the endpoint is unreachable for long.
+var ErrQueueFull = errors.New("webhook: queue full")
+
+// StatusError reports a delivery rejected by the endpoint.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook: endpoint responded %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Hook delivers events to URL. Its zero value, with URL set, is ready to
+// use; the queue is created on first use.
+type Hook struct {
+	URL string
+	// Secret, when set, signs every request, see SignatureHeader.
+	Secret []byte
+	// Header holds additional request headers, such as Authorization.
+	Header http.Header
+	// Client sends the requests, a client with DefaultTimeout when nil.
+	Client *http.Client
+	// QueueSize is the number of events queued, DefaultQueueSize when 0.
+	QueueSize int
+	// MaxAttempts bounds the deliveries of an event, DefaultMaxAttempts
+	// when 0.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubling on every
+	// attempt, DefaultBackoff when 0.
+	Backoff time.Duration
+	// ReadNDEF makes HandleCard include the NDEF message of the card.
+	ReadNDEF bool
+	// Logger, when set, logs events dropped after MaxAttempts.
+	Logger *slog.Logger
+
+	once  sync.Once
+	queue chan integrations.Event
+}
+
+func (h *Hook) init() {
+	h.once.Do(func() {
+		n := h.QueueSize
+		if n <= 0 {
+			n = DefaultQueueSize
+		}
+		h.queue = make(chan integrations.Event, n)
+	})
+}
+
+// HandleCard enqueues the event of the card, with its NDEF message when
+// ReadNDEF is set. It has the signature of a scardkit.CardHandler.
+func (h *Hook) HandleCard(ctx context.Context, ev cardreader.Event, card transport.Card) error {
+	e := integrations.NewEvent(ev)
+	if h.ReadNDEF {
+		if err := e.ReadNDEF(card); err != nil {
+			return fmt.Errorf("read ndef: %w", err)
+		}
+	}
+	return h.Enqueue(e)
+}
+
+// Publish delivers ev right away, retrying failed deliveries with the
+// backoff of the Hook up to MaxAttempts times until ctx is done, see
+// Deliver. It makes a Hook a scardkit.EventSink, whose buffering replaces
+// the queue of the Hook and which cancels ctx when Shutdown stops waiting.
+func (h *Hook) Publish(ctx context.Context, ev cardreader.Event) error {
+	return h.Deliver(ctx, integrations.NewEvent(ev))
+}
+
+// Enqueue queues e for delivery by Run. It returns ErrQueueFull without
+// blocking when the queue is full.
+func (h *Hook) Enqueue(e integrations.Event) error {
+	h.init()
+	select {
+	case h.queue <- e:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run delivers queued events until ctx is done, returning its error.
+// Events failing MaxAttempts deliveries are dropped and logged.
+func (h *Hook) Run(ctx context.Context) error {
+	h.init()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e := <-h.queue:
+			if err := h.Deliver(ctx, e); err != nil && ctx.Err() == nil && h.Logger != nil {
+				h.Logger.Warn("webhook event dropped", slog.String("reader", e.Reader), slog.String("uid", e.UID), slog.Any("err", err))
+			}
+		}
+	}
+}
+
+// Deliver POSTs e to URL, retrying network errors and 5xx and 429 responses
+// up to MaxAttempts times.
+func (h *Hook) Deliver(ctx context.Context, e integrations.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	attempts, backoff := h.MaxAttempts, h.Backoff
+	if attempts <= 0 {
+		attempts = DefaultMaxAttempts
+	}
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+	for i := 1; ; i++ {
+		err = h.post(ctx, body)
+		var se *StatusError
+		if err == nil || i >= attempts || (errors.As(err, &se) && se.StatusCode < 500 && se.StatusCode != http.StatusTooManyRequests) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (h *Hook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range h.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(h.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(h.Secret, body))
+	}
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// Sign returns the SignatureHeader value of body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether sig, the SignatureHeader of a request, is the
+// signature of body.
+func Verify(secret, body []byte, sig string) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+	if err != nil || !strings.HasPrefix(sig, "sha256=") {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/integrations"
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/x/virtualreader"
+)
+
+// tapped is a virtual tag as handed to card handlers.
+type tapped struct{ tag.Card }
+
+func (tapped) Disconnect() error { return nil }
+
+func TestHook(t *testing.T) {
+	secret := []byte("s3cret")
+	var calls atomic.Int32
+	got := make(chan integrations.Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify(secret, body, r.Header.Get(SignatureHeader)) || r.Header.Get("Authorization") != "Bearer t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var e integrations.Event
+		json.Unmarshal(body, &e)
+		got <- e
+	}))
+	defer srv.Close()
+
+	uid := []byte{0x04, 1, 2, 3, 4, 5, 6}
+	ntag := virtualreader.NewNTAG215(uid)
+	data, _ := ndef.NewMessage(ndef.NewURIRecord("https://example.com/t")).Marshal()
+	if err := tag.WriteNDEF(ntag, data); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Hook{URL: srv.URL, Secret: secret, Header: http.Header{"Authorization": {"Bearer t"}}, Backoff: time.Millisecond, ReadNDEF: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Run(ctx)
+	ev := cardreader.Event{Type: cardreader.EventCardInserted, Reader: "r0", UID: uid, Time: time.Now()}
+	if err := h.HandleCard(ctx, ev, tapped{ntag}); err != nil {
+		t.Fatalf("HandleCard() error = %v", err)
+	}
+	select {
+	case e := <-got:
+		if e.Type != "card-inserted" || e.Reader != "r0" || e.UID != "04010203040506" || len(e.NDEF) != 1 || e.NDEF[0].URI != "https://example.com/t" {
+			t.Errorf("delivered %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event not delivered")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("%d deliveries, want 2", n)
+	}
+
+	unsigned := &Hook{URL: srv.URL, Backoff: time.Millisecond}
+	var se *StatusError
+	if err := unsigned.Deliver(ctx, integrations.NewEvent(ev)); !errors.As(err, &se) || se.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Deliver() unsigned error = %v", err)
+	}
+
+	full := &Hook{URL: srv.URL, QueueSize: 1}
+	full.Enqueue(integrations.Event{})
+	if err := full.Enqueue(integrations.Event{}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Enqueue() error = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	ev := cardreader.Event{Type: cardreader.EventCardInserted, Reader: "r0", Time: time.Now()}
+
+	tests := []struct {
+		name    string
+		backoff time.Duration
+		timeout time.Duration
+		calls   int32
+		wantErr error
+	}{
+		{"retried", time.Millisecond, time.Second, 3, nil},
+		{"cancelled", time.Hour, 50 * time.Millisecond, 1, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		calls.Store(0)
+		h := &Hook{URL: srv.URL, Backoff: tt.backoff}
+		ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+		err := h.Publish(ctx, ev)
+		cancel()
+		if !errors.Is(err, tt.wantErr) || tt.wantErr == nil && err != nil {
+			t.Errorf("%s: Publish() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if n := calls.Load(); n != tt.calls {
+			t.Errorf("%s: %d deliveries, want %d", tt.name, n, tt.calls)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"uid":"04"}`)
+	sig := Sign([]byte("k"), body)
+	tests := []struct {
+		secret, body []byte
+		sig          string
+		want         bool
+	}{
+		{[]byte("k"), body, sig, true},
+		{[]byte("x"), body, sig, false},
+		{[]byte("k"), []byte(`{}`), sig, false},
+		{[]byte("k"), body, sig[len("sha256="):], false},
+		{[]byte("k"), body, "sha256=zz", false},
+	}
+	for i, tt := range tests {
+		if got := Verify(tt.secret, tt.body, tt.sig); got != tt.want {
+			t.Errorf("%d: Verify() = %v, want %v", i, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package websocket streams card events as JSON over WebSocket (RFC 6455),
+// so browser-based kiosks and dashboards react to taps in real time. Server
+// is an http.Handler upgrading requests to WebSocket connections, each
+// receiving every event published as a text message.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/integrations"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// DefaultBuffer is the default number of events buffered per connection.
+const DefaultBuffer = 64
+
+// writeTimeout bounds writing a frame to a connection.
+const writeTimeout = 10 * time.Second
+
+// acceptGUID is the GUID of the Sec-WebSocket-Accept computation.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Frame opcodes.
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// maxFrame bounds the payload of frames read from clients, which only send
+// control frames.
+const maxFrame = 4096
+
+// Server streams events to WebSocket clients. Its zero value streams to
+// clients without a token but, as browsers let any page open WebSocket
+// connections, rejects upgrades requested by pages of other origins; set
+// Token to require one and AllowedOrigins to admit other pages.
+type Server struct {
+	// Token, when set, is required from clients either as a bearer token
+	// in the Authorization header or, for browsers, the token query
+	// parameter.
+	Token string
+	// AllowedOrigins are the origins, such as https://kiosk.example.com,
+	// whose pages may connect besides those served from the host of the
+	// request; "*" allows every origin. Clients sending no Origin header,
+	// which browsers always send, are not restricted.
+	AllowedOrigins []string
+	// CheckOrigin, when set, decides whether to accept the upgrade request
+	// r instead of AllowedOrigins.
+	CheckOrigin func(r *http.Request) bool
+	// Buffer is the number of events buffered per connection,
+	// DefaultBuffer when 0. Connections falling further behind are closed.
+	Buffer int
+	// ReadNDEF makes HandleCard include the NDEF message of the card.
+	ReadNDEF bool
+
+	mu    sync.Mutex
+	conns map[*conn]struct{}
+}
+
+// conn is a client connection.
+type conn struct {
+	net.Conn
+	out  chan []byte
+	done chan struct{}
+	once sync.Once
+	wmu  sync.Mutex
+}
+
+// write writes a frame, serializing the writes of the streaming loop and
+// the pongs of the read loop.
+func (c *conn) write(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return writeFrame(c.Conn, op, payload)
+}
+
+func (c *conn) close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.Conn.Close()
+	})
+}
+
+// ServeHTTP upgrades the request to a WebSocket connection and streams
+// events to it until the client or the server closes it.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.originAllowed(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket unsupported", http.StatusInternalServerError)
+		return
+	}
+	nc, rw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", accept(key))
+	if err := rw.Flush(); err != nil {
+		nc.Close()
+		return
+	}
+
+	buf := s.Buffer
+	if buf <= 0 {
+		buf = DefaultBuffer
+	}
+	c := &conn{Conn: nc, out: make(chan []byte, buf), done: make(chan struct{})}
+	s.mu.Lock()
+	if s.conns == nil {
+		s.conns = make(map[*conn]struct{})
+	}
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.close()
+	}()
+	go s.read(c, rw.Reader)
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.out:
+			if err := c.write(opText, msg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// read handles the frames sent by the client: pings are answered and a
+// close frame ends the connection.
+func (s *Server) read(c *conn, r *bufio.Reader) {
+	defer c.close()
+	for {
+		op, payload, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		switch op {
+		case opPing:
+			if c.write(opPong, payload) != nil {
+				return
+			}
+		case opClose:
+			c.write(opClose, payload)
+			return
+		}
+	}
+}
+
+// Publish sends e to every connection.
+func (s *Server) Publish(e integrations.Event) error {
+	msg, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		select {
+		case c.out <- msg:
+		default:
+			// The client falls behind, drop it rather than block taps.
+			c.close()
+		}
+	}
+	return nil
+}
+
+// HandleCard publishes the event of the card, with its NDEF message when
+// ReadNDEF is set. It has the signature of a scardkit.CardHandler.
+func (s *Server) HandleCard(ctx context.Context, ev cardreader.Event, card transport.Card) error {
+	e := integrations.NewEvent(ev)
+	if s.ReadNDEF {
+		if err := e.ReadNDEF(card); err != nil {
+			return fmt.Errorf("read ndef: %w", err)
+		}
+	}
+	return s.Publish(e)
+}
+
+// Conns returns the number of open connections.
+func (s *Server) Conns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// Close closes every connection.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.close()
+	}
+	return nil
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.Token == "" {
+		return true
+	}
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+// originAllowed reports whether the page requesting the upgrade r may
+// connect, see AllowedOrigins and CheckOrigin.
+func (s *Server) originAllowed(r *http.Request) bool {
+	if s.CheckOrigin != nil {
+		return s.CheckOrigin(r)
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, o := range s.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// accept returns the Sec-WebSocket-Accept of key.
+func accept(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// writeFrame writes an unmasked final frame.
+func writeFrame(c net.Conn, op byte, payload []byte) error {
+	hdr := []byte{0x80 | op, 0}
+	switch n := len(payload); {
+	case n < 126:
+		hdr[1] = byte(n)
+	case n <= 0xFFFF:
+		hdr[1] = 126
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(n))
+	default:
+		hdr[1] = 127
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+	c.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := (&net.Buffers{hdr, payload}).WriteTo(c)
+	return err
+}
+
+// errFrameTooLarge is returned for client frames over maxFrame.
+var errFrameTooLarge = errors.New("websocket: frame too large")
+
+// readFrame reads a frame sent by a client, unmasking its payload.
+func readFrame(r io.Reader) (op byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := uint64(hdr[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxFrame {
+		return 0, nil, errFrameTooLarge
+	}
+	var mask [4]byte
+	masked := hdr[1]&0x80 != 0
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return hdr[0] & 0x0F, payload, nil
+}
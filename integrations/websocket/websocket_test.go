@@ -0,0 +1,122 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package websocket
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/integrations"
+)
+
+func TestAccept(t *testing.T) {
+	// The example of RFC 6455 section 1.3.
+	if got := accept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("accept() = %s", got)
+	}
+}
+
+func dial(t *testing.T, url, query string, headers ...string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	c, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(c, "GET /events%s HTTP/1.1\r\nHost: kiosk\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n%s\r\n", query, strings.Join(headers, ""))
+	r := bufio.NewReader(c)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, r, resp
+}
+
+func TestServer(t *testing.T) {
+	s := &Server{Token: "t0k"}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	defer s.Close()
+
+	for _, query := range []string{"", "?token=bad"} {
+		c, _, resp := dial(t, srv.URL, query)
+		c.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("query %q: status %d, want 401", query, resp.StatusCode)
+		}
+	}
+
+	c, r, resp := dial(t, srv.URL, "?token=t0k")
+	defer c.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("upgrade response %d %v", resp.StatusCode, resp.Header)
+	}
+	for deadline := time.Now().Add(time.Second); s.Conns() == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := s.Publish(integrations.Event{Type: "card-inserted", Reader: "r0", UID: "04A1"}); err != nil {
+		t.Fatal(err)
+	}
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	op, payload, err := readFrame(r)
+	var e integrations.Event
+	if err != nil || op != opText || json.Unmarshal(payload, &e) != nil || e.UID != "04A1" {
+		t.Fatalf("event frame %x %s, %v", op, payload, err)
+	}
+
+	// Client frames are masked.
+	c.Write([]byte{0x80 | opPing, 0x80 | 2, 1, 2, 3, 4, 'h' ^ 1, 'i' ^ 2})
+	if op, payload, err := readFrame(r); err != nil || op != opPong || string(payload) != "hi" {
+		t.Errorf("pong frame %x %q, %v", op, payload, err)
+	}
+	c.Write([]byte{0x80 | opClose, 0x80, 0, 0, 0, 0})
+	if op, _, err := readFrame(r); err != nil || op != opClose {
+		t.Errorf("close frame %x, %v", op, err)
+	}
+	for deadline := time.Now().Add(time.Second); s.Conns() != 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if n := s.Conns(); n != 0 {
+		t.Errorf("Conns() = %d after close", n)
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	tests := []struct {
+		name   string
+		s      *Server
+		origin string
+		want   int
+	}{
+		{"no origin", &Server{}, "", http.StatusSwitchingProtocols},
+		{"same origin", &Server{}, "http://kiosk", http.StatusSwitchingProtocols},
+		{"cross origin", &Server{}, "https://evil.example", http.StatusForbidden},
+		{"allowed", &Server{AllowedOrigins: []string{"https://dash.example"}}, "https://dash.example", http.StatusSwitchingProtocols},
+		{"not allowed", &Server{AllowedOrigins: []string{"https://dash.example"}}, "https://evil.example", http.StatusForbidden},
+		{"any", &Server{AllowedOrigins: []string{"*"}}, "https://evil.example", http.StatusSwitchingProtocols},
+		{"check", &Server{CheckOrigin: func(r *http.Request) bool { return false }}, "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(tt.s)
+		var headers []string
+		if tt.origin != "" {
+			headers = append(headers, "Origin: "+tt.origin+"\r\n")
+		}
+		c, _, resp := dial(t, srv.URL, "", headers...)
+		c.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+		tt.s.Close()
+		srv.Close()
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+)
+
+// ErrNoJournal is returned by Replay without a journal.
+var ErrNoJournal = errors.New("sdk has no event journal")
+
+// Journal keeps the events of the SDK for Replay, such as a
+// *journal.Journal keeping them on disk.
+type Journal interface {
+	// Append records ev.
+	Append(ev cardreader.Event) error
+	// Events calls fn with the events recorded at or after since, oldest
+	// first, stopping at the first error of fn.
+	Events(since time.Time, fn func(ev cardreader.Event) error) error
+}
+
+// WithJournal makes the SDK record every event in j before passing it to
+// the event sinks, so Replay can deliver the events a sink missed during an
+// outage.
+func WithJournal(j Journal) Option {
+	return func(sdk *SDK) {
+		sdk.journal = j
+	}
+}
+
+// Replay passes the events journaled at or after since to sink again,
+// oldest first, e.g. once the consumer behind one of the event sinks
+// recovered from an outage. Other sinks do not receive the events again.
+// Unlike live events, replayed events are published right away, stopping at
+// the first error of sink or when ctx is done. It returns the number of
+// events replayed.
+func (sdk *SDK) Replay(ctx context.Context, since time.Time, sink EventSink) (int, error) {
+	if sdk.journal == nil {
+		return 0, ErrNoJournal
+	}
+	n := 0
+	err := sdk.journal.Events(since, func(ev cardreader.Event) error {
+		select {
+		case <-sdk.sinkStop:
+			return ErrClosed
+		default:
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := sink.Publish(ctx, ev); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
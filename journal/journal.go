@@ -0,0 +1,241 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package journal keeps card events on disk, so taps seen while a consumer
+// is down are not lost: the SDK appends every event to a Journal, see
+// scardkit.WithJournal, and SDK.Replay delivers them again once the
+// consumer recovered. A Journal is a directory of append-only JSON lines
+// files, rotated by size.
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+)
+
+// Defaults of Open.
+const (
+	DefaultMaxSize  = 16 << 20
+	DefaultMaxFiles = 8
+)
+
+// current is the name of the file events are appended to; rotated files
+// are named events-<unix nanoseconds>.jsonl.
+const current = "events.jsonl"
+
+// Journal is an on-disk event journal, safe for concurrent use.
+type Journal struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// Open opens the journal in dir, creating it when missing. The current
+// file is rotated once it would exceed maxSize bytes, DefaultMaxSize when
+// 0, and the oldest files are removed to keep at most maxFiles,
+// DefaultMaxFiles when 0.
+func Open(dir string, maxSize int64, maxFiles int) (*Journal, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultMaxFiles
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	j := &Journal{dir: dir, maxSize: maxSize, maxFiles: maxFiles}
+	if err := j.open(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// open opens the current file, cutting a last line left partial by a
+// crash, so the events appended after it stay readable.
+func (j *Journal) open() error {
+	f, err := os.OpenFile(filepath.Join(j.dir, current), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	size, err := complete(f)
+	if err == nil && size >= 0 {
+		err = f.Truncate(size)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if size < 0 {
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		size = fi.Size()
+	}
+	j.f, j.size = f, size
+	return nil
+}
+
+// complete returns the size of f up to its last newline, or -1 when f is
+// empty or ends with one.
+func complete(f *os.File) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 4096)
+	for end := fi.Size(); end > 0; {
+		n := int64(len(buf))
+		if n > end {
+			n = end
+		}
+		if _, err := f.ReadAt(buf[:n], end-n); err != nil {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			if size := end - n + int64(i) + 1; size != fi.Size() {
+				return size, nil
+			}
+			return -1, nil
+		}
+		end -= n
+	}
+	if fi.Size() == 0 {
+		return -1, nil
+	}
+	return 0, nil
+}
+
+// Append appends ev to the journal.
+func (j *Journal) Append(ev cardreader.Event) error {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return os.ErrClosed
+	}
+	if j.size > 0 && j.size+int64(len(line)) > j.maxSize {
+		if err := j.rotate(); err != nil {
+			return fmt.Errorf("rotate journal: %w", err)
+		}
+	}
+	n, err := j.f.Write(line)
+	j.size += int64(n)
+	return err
+}
+
+// rotate renames the current file and removes the oldest files.
+func (j *Journal) rotate() error {
+	if err := j.f.Close(); err != nil {
+		return err
+	}
+	j.f = nil
+	name := fmt.Sprintf("events-%020d.jsonl", time.Now().UnixNano())
+	if err := os.Rename(filepath.Join(j.dir, current), filepath.Join(j.dir, name)); err != nil {
+		return err
+	}
+	files, err := j.files()
+	if err != nil {
+		return err
+	}
+	for len(files) > j.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return j.open()
+}
+
+// files returns the journal files, oldest first.
+func (j *Journal) files() ([]string, error) {
+	rotated, err := filepath.Glob(filepath.Join(j.dir, "events-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(rotated)
+	return append(rotated, filepath.Join(j.dir, current)), nil
+}
+
+// Events calls fn with the events journaled at or after since, oldest
+// first, stopping at the first error of fn.
+func (j *Journal) Events(since time.Time, fn func(ev cardreader.Event) error) error {
+	j.mu.Lock()
+	files, err := j.files()
+	j.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		if err := readFile(name, since, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFile(name string, since time.Time, fn func(ev cardreader.Event) error) error {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		// Rotated away meanwhile.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	// A last line cut short by a crash, before Open repaired it, is
+	// skipped, other invalid lines fail.
+	var invalid error
+	for n := 1; sc.Scan(); n++ {
+		if invalid != nil {
+			return invalid
+		}
+		var ev cardreader.Event
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			invalid = fmt.Errorf("%s:%d: %w", filepath.Base(name), n, err)
+			continue
+		}
+		if ev.Time.Before(since) {
+			continue
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// Close closes the journal.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return nil
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+)
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(dir, 400, 3)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	start := time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		ev := cardreader.Event{Type: cardreader.EventCardInserted, Reader: "gate", UID: []byte{byte(i)}, Time: start.Add(time.Duration(i) * time.Minute)}
+		if err := j.Append(ev); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if len(files) != 3 {
+		t.Errorf("%d files, want 3", len(files))
+	}
+
+	tests := []struct {
+		since      time.Time
+		first, end byte
+	}{
+		{time.Time{}, 0, 20},
+		{start.Add(17 * time.Minute), 17, 20},
+		{start.Add(time.Hour), 0, 0},
+	}
+	for _, tt := range tests {
+		var uids []byte
+		err := j.Events(tt.since, func(ev cardreader.Event) error {
+			uids = append(uids, ev.UID[0])
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Events(%v) error = %v", tt.since, err)
+		}
+		// The oldest events were rotated away.
+		if len(uids) == 0 && tt.end > 0 || len(uids) > 0 && (uids[len(uids)-1] != tt.end-1 || (tt.first > 0 && uids[0] != tt.first)) {
+			t.Errorf("Events(%v) = %v, want up to %d from %d", tt.since, uids, tt.end-1, tt.first)
+		}
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A line cut short by a crash is skipped, and cut on open so the
+	// events appended after it are read.
+	f, _ := os.OpenFile(filepath.Join(dir, current), os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"Type":1,"Rea`)
+	f.Close()
+	n := 0
+	if err := (&Journal{dir: dir}).Events(start.Add(17*time.Minute), func(cardreader.Event) error { n++; return nil }); err != nil || n != 3 {
+		t.Errorf("Events() before repair = %d, %v", n, err)
+	}
+	j, err = Open(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if err := j.Append(cardreader.Event{Type: cardreader.EventCardInserted, Reader: "after", Time: start.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	if err := j.Events(start.Add(17*time.Minute), func(ev cardreader.Event) error { got = append(got, ev.Reader); return nil }); err != nil || len(got) != 4 || got[3] != "after" {
+		t.Errorf("Events() after a crash = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/journal"
+)
+
+func TestReplay(t *testing.T) {
+	if _, err := New().Replay(context.Background(), time.Time{}, ChanSink(nil)); !errors.Is(err, ErrNoJournal) {
+		t.Errorf("Replay() without journal error = %v", err)
+	}
+
+	j, err := journal.Open(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	var down atomic.Bool
+	var missed, healthy atomic.Int32
+	down.Store(true)
+	delivered := make(chan cardreader.Event, 8)
+	sink := SinkFunc(func(ctx context.Context, ev cardreader.Event) error {
+		if down.Load() {
+			missed.Add(1)
+			return errors.New("consumer down")
+		}
+		delivered <- ev
+		return nil
+	})
+	other := SinkFunc(func(ctx context.Context, ev cardreader.Event) error {
+		healthy.Add(1)
+		return nil
+	})
+	sdk := New(WithBackend(&fakeBackend{reader: *cardreader.NewReader("virtual"), card: &memCard{}}), WithJournal(j), WithEventSink(sink, 1), WithEventSink(other, 0))
+
+	start := time.Now()
+	if _, err := sdk.WaitForCard(context.Background(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second); (missed.Load() < 2 || healthy.Load() < 2) && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := sdk.Replay(context.Background(), start, sink); err == nil {
+		t.Error("Replay() to a failing sink succeeded")
+	}
+	down.Store(false)
+	n, err := sdk.Replay(context.Background(), start, sink)
+	// State changes are not journaled.
+	if err != nil || n != 2 {
+		t.Fatalf("Replay() = %d, %v, want 2 events", n, err)
+	}
+	for _, want := range []cardreader.EventType{cardreader.EventReaderAdded, cardreader.EventCardInserted} {
+		select {
+		case ev := <-delivered:
+			if ev.Type != want || ev.Reader != "virtual" {
+				t.Errorf("replayed %v on %s, want %v", ev.Type, ev.Reader, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("event not replayed")
+		}
+	}
+
+	if err := sdk.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := healthy.Load(); n != 2 {
+		t.Errorf("healthy sink received %d events, want 2", n)
+	}
+	if _, err := sdk.Replay(context.Background(), start, sink); !errors.Is(err, ErrClosed) {
+		t.Errorf("Replay() after Shutdown error = %v", err)
+	}
+}
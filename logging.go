@@ -0,0 +1,60 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Log subsystems, the keys of WithLogLevels. Their records carry a
+// subsystem attribute.
+const (
+	LogSDK    = "sdk"    // Lifecycle: readers, card sessions, sinks and the watchdog.
+	LogPCSC   = "pcsc"   // Wire level: APDU hex dumps of the SDK helpers, at debug level.
+	LogDriver = "driver" // Drivers given Logger(LogDriver).
+)
+
+// WithLogLevels sets the minimum level of the records of each subsystem,
+// e.g. debug for LogPCSC to see APDU hex dumps and info for LogSDK to hide
+// the card session chatter. The levels apply on top of the handler of the
+// logger set with WithLogger, which must enable them as well. Subsystems
+// without a level log everything the handler enables.
+func WithLogLevels(levels map[string]slog.Level) Option {
+	return func(sdk *SDK) {
+		sdk.logLevels = make(map[string]slog.Level, len(levels))
+		for name, l := range levels {
+			sdk.logLevels[name] = l
+		}
+	}
+}
+
+// Logger returns the logger of subsystem, such as LogDriver for the
+// drivers used by handlers, honoring WithLogLevels.
+func (sdk *SDK) Logger(subsystem string) *slog.Logger {
+	h := sdk.baseLogger.Handler()
+	if l, ok := sdk.logLevels[subsystem]; ok {
+		h = levelHandler{Handler: h, min: l}
+	}
+	return slog.New(h).With(slog.String("subsystem", subsystem))
+}
+
+// levelHandler drops the records below min.
+type levelHandler struct {
+	slog.Handler
+	min slog.Level
+}
+
+func (h levelHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return l >= h.min && h.Handler.Enabled(ctx, l)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithAttrs(attrs), min: h.min}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithGroup(name), min: h.min}
+}
@@ -0,0 +1,46 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/x/virtualreader"
+)
+
+func TestLogLevels(t *testing.T) {
+	vr := virtualreader.New("r0")
+	if err := vr.Insert("r0", virtualreader.NewNTAG215([]byte{0x04, 1, 2, 3, 4, 5, 6})); err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	sdk := New(
+		WithBackend(vr),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		WithLogLevels(map[string]slog.Level{LogPCSC: slog.LevelDebug, LogSDK: slog.LevelInfo}),
+	)
+	if _, err := sdk.ReadNDEF(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	sdk.Logger(LogDriver).Debug("driver detail")
+
+	tests := []struct {
+		record string
+		want   bool
+	}{
+		{"msg=apdu subsystem=pcsc cmd=ffb0", true},
+		{"card session started", false},
+		{`msg="driver detail" subsystem=driver`, true},
+	}
+	for _, tt := range tests {
+		if got := strings.Contains(logs.String(), tt.record); got != tt.want {
+			t.Errorf("log contains %q = %v, want %v:\n%s", tt.record, got, tt.want, logs.String())
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"expvar"
+	"time"
+)
+
+// Metrics receives counters and latencies per reader, e.g. to export them
+// to a monitoring system. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// CardTapped counts a card connected on reader.
+	CardTapped(reader string)
+	// TransmitError counts a failed APDU exchange on reader.
+	TransmitError(reader string, err error)
+	// Reconnected counts a reconnect to the card on reader.
+	Reconnected(reader string)
+	// ObserveAPDU records the round-trip latency of an APDU exchange.
+	ObserveAPDU(reader string, d time.Duration)
+}
+
+// WithMetrics sets the metrics the SDK reports to.
+func WithMetrics(m Metrics) Option {
+	return func(sdk *SDK) {
+		if m != nil {
+			sdk.metrics = m
+		}
+	}
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) CardTapped(string)                 {}
+func (nopMetrics) TransmitError(string, error)       {}
+func (nopMetrics) Reconnected(string)                {}
+func (nopMetrics) ObserveAPDU(string, time.Duration) {}
+
+// ExpvarMetrics implements Metrics with counters published through expvar,
+// served as JSON on /debug/vars when net/http/pprof or expvar's handler is
+// in use. Each counter is a map keyed by reader name.
+type ExpvarMetrics struct {
+	taps           *expvar.Map
+	transmitErrors *expvar.Map
+	reconnects     *expvar.Map
+	apdus          *expvar.Map
+	apduLatency    *expvar.Map // Sum of round-trip latencies in nanoseconds.
+}
+
+// NewExpvarMetrics returns metrics published as the expvar map name. Like
+// expvar.NewMap it panics when name is already in use.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	m := &ExpvarMetrics{
+		taps:           new(expvar.Map).Init(),
+		transmitErrors: new(expvar.Map).Init(),
+		reconnects:     new(expvar.Map).Init(),
+		apdus:          new(expvar.Map).Init(),
+		apduLatency:    new(expvar.Map).Init(),
+	}
+	root := expvar.NewMap(name)
+	root.Set("card_taps", m.taps)
+	root.Set("transmit_errors", m.transmitErrors)
+	root.Set("reconnects", m.reconnects)
+	root.Set("apdus", m.apdus)
+	root.Set("apdu_latency_ns", m.apduLatency)
+	return m
+}
+
+// CardTapped implements Metrics.
+func (m *ExpvarMetrics) CardTapped(reader string) { m.taps.Add(reader, 1) }
+
+// TransmitError implements Metrics.
+func (m *ExpvarMetrics) TransmitError(reader string, _ error) { m.transmitErrors.Add(reader, 1) }
+
+// Reconnected implements Metrics.
+func (m *ExpvarMetrics) Reconnected(reader string) { m.reconnects.Add(reader, 1) }
+
+// ObserveAPDU implements Metrics.
+func (m *ExpvarMetrics) ObserveAPDU(reader string, d time.Duration) {
+	m.apdus.Add(reader, 1)
+	m.apduLatency.Add(reader, int64(d))
+}
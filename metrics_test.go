@@ -0,0 +1,52 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
+type fakeCard struct {
+	apdu.TransceiverFunc
+}
+
+func (fakeCard) ATR() []byte { return nil }
+
+func TestMeteredCard(t *testing.T) {
+	m := NewExpvarMetrics("scardkit_test_metrics")
+	fail := errors.New("card removed")
+	card := instrumentedCard{
+		Card: fakeCard{func(cmd []byte) ([]byte, error) {
+			if cmd[0] == 0xFF {
+				return nil, fail
+			}
+			return []byte{0x90, 0x00}, nil
+		}},
+		ctx:    context.Background(),
+		reader: "ACS ACR122U PICC Interface 00",
+		sdk:    New(WithMetrics(m)),
+	}
+
+	for _, cmd := range [][]byte{{0x00}, {0x00}, {0xFF}} {
+		_, _ = card.Transmit(cmd)
+	}
+	m.CardTapped(card.reader)
+
+	counters := expvar.Get("scardkit_test_metrics").(*expvar.Map)
+	for name, want := range map[string]int64{"apdus": 2, "transmit_errors": 1, "card_taps": 1, "reconnects": 0} {
+		var got int64
+		if v := counters.Get(name).(*expvar.Map).Get(card.reader); v != nil {
+			got = v.(*expvar.Int).Value()
+		}
+		if got != want {
+			t.Errorf("%s = %d, want %d", name, got, want)
+		}
+	}
+}
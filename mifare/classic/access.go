@@ -0,0 +1,166 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package classic
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrAccessBits is returned for access bytes breaking the inverted bits
+// rule, which a card treats as a permanently blocked sector.
+var ErrAccessBits = errors.New("classic: inconsistent access bits")
+
+// Access is the access condition of a block, the bits C1 C2 C3 packed as
+// C1<<2 | C2<<1 | C3.
+type Access uint8
+
+// Common access conditions.
+const (
+	AccessDataTransport    Access = 0b000 // Read and written with A or B.
+	AccessDataReadOnly     Access = 0b010 // Read with A or B, never written.
+	AccessDataWriteB       Access = 0b100 // Read with A or B, written with B.
+	AccessDataValueB       Access = 0b110 // Value block: B increments, A or B decrement.
+	AccessDataValueDebit   Access = 0b001 // Value block: A or B read and decrement only.
+	AccessDataB            Access = 0b011 // Read and written with B only.
+	AccessDataReadB        Access = 0b101 // Read with B only.
+	AccessDataNever        Access = 0b111 // Blocked.
+	AccessTrailerTransport Access = 0b001 // Key A writes keys and access bits.
+	AccessTrailerKeyB      Access = 0b011 // Key B writes keys and access bits.
+	AccessTrailerFrozen    Access = 0b111 // Keys and access bits never written.
+)
+
+// AccessBits are the access conditions of a sector: of its data blocks, or
+// groups of five blocks in the large sectors of 4K cards, and of its trailer
+// at index 3.
+type AccessBits [4]Access
+
+// DefaultAccessBits are the factory access bits, FF 07 80.
+var DefaultAccessBits = AccessBits{AccessDataTransport, AccessDataTransport, AccessDataTransport, AccessTrailerTransport}
+
+// DecodeAccessBits decodes the three access bytes of a sector trailer,
+// returning ErrAccessBits when the inverted copies do not match.
+func DecodeAccessBits(b []byte) (AccessBits, error) {
+	if len(b) < 3 {
+		return AccessBits{}, fmt.Errorf("access bits must be 3 bytes, got %d", len(b))
+	}
+	c1, c2, c3 := b[1]>>4, b[2]&0x0F, b[2]>>4
+	if ^b[0]&0x0F != c1 || ^b[0]>>4 != c2 || ^b[1]&0x0F != c3 {
+		return AccessBits{}, fmt.Errorf("%w: % X", ErrAccessBits, b[:3])
+	}
+	var a AccessBits
+	for i := range a {
+		a[i] = Access((c1>>i&1)<<2 | (c2>>i&1)<<1 | c3>>i&1)
+	}
+	return a, nil
+}
+
+// Encode returns the three access bytes of a sector trailer.
+func (a AccessBits) Encode() [3]byte {
+	var c1, c2, c3 byte
+	for i, acc := range a {
+		c1 |= byte(acc>>2&1) << i
+		c2 |= byte(acc>>1&1) << i
+		c3 |= byte(acc&1) << i
+	}
+	return [3]byte{^c2<<4 | ^c1&0x0F, c1<<4 | ^c3&0x0F, c3<<4 | c2}
+}
+
+// Permission tells which keys grant an operation.
+type Permission uint8
+
+const (
+	Never Permission = iota
+	WithKeyA
+	WithKeyB
+	WithKeyAB
+)
+
+// String returns "never", "A", "B" or "A|B".
+func (p Permission) String() string {
+	return [...]string{"never", "A", "B", "A|B"}[p&3]
+}
+
+// DataAccess are the operations permitted on a data block.
+type DataAccess struct {
+	Read, Write, Increment, Decrement Permission
+}
+
+// TrailerAccess are the operations permitted on a sector trailer. Key A is
+// never readable; a readable key B cannot be used to authenticate.
+type TrailerAccess struct {
+	KeyAWrite, AccessRead, AccessWrite, KeyBRead, KeyBWrite Permission
+}
+
+var dataAccess = [8]DataAccess{
+	0b000: {WithKeyAB, WithKeyAB, WithKeyAB, WithKeyAB},
+	0b010: {WithKeyAB, Never, Never, Never},
+	0b100: {WithKeyAB, WithKeyB, Never, Never},
+	0b110: {WithKeyAB, WithKeyB, WithKeyB, WithKeyAB},
+	0b001: {WithKeyAB, Never, Never, WithKeyAB},
+	0b011: {WithKeyB, WithKeyB, Never, Never},
+	0b101: {WithKeyB, Never, Never, Never},
+	0b111: {Never, Never, Never, Never},
+}
+
+var trailerAccess = [8]TrailerAccess{
+	0b000: {WithKeyA, WithKeyA, Never, WithKeyA, WithKeyA},
+	0b010: {Never, WithKeyA, Never, WithKeyA, Never},
+	0b100: {WithKeyB, WithKeyAB, Never, Never, WithKeyB},
+	0b110: {Never, WithKeyAB, Never, Never, Never},
+	0b001: {WithKeyA, WithKeyA, WithKeyA, WithKeyA, WithKeyA},
+	0b011: {WithKeyB, WithKeyAB, WithKeyB, Never, WithKeyB},
+	0b101: {Never, WithKeyAB, WithKeyB, Never, Never},
+	0b111: {Never, WithKeyAB, Never, Never, Never},
+}
+
+// Data returns the operations a permits on a data block.
+func (a Access) Data() DataAccess { return dataAccess[a&7] }
+
+// Trailer returns the operations a permits on a sector trailer.
+func (a Access) Trailer() TrailerAccess { return trailerAccess[a&7] }
+
+// Trailer is the content of a sector trailer. Keys read back from a card
+// are masked: key A always reads as zeros, key B unless readable.
+type Trailer struct {
+	KeyA   Key
+	Access AccessBits
+	GPB    byte // General purpose byte, free for applications.
+	KeyB   Key
+}
+
+// ParseTrailer decodes a sector trailer block.
+func ParseTrailer(block []byte) (Trailer, error) {
+	if len(block) != BlockSize {
+		return Trailer{}, fmt.Errorf("trailer must be %d bytes, got %d", BlockSize, len(block))
+	}
+	access, err := DecodeAccessBits(block[6:9])
+	if err != nil {
+		return Trailer{}, err
+	}
+	t := Trailer{Access: access, GPB: block[9]}
+	copy(t.KeyA[:], block[:6])
+	copy(t.KeyB[:], block[10:])
+	return t, nil
+}
+
+// Marshal encodes the trailer as a block.
+func (t Trailer) Marshal() []byte {
+	access := t.Access.Encode()
+	out := make([]byte, 0, BlockSize)
+	out = append(out, t.KeyA[:]...)
+	out = append(out, access[:]...)
+	out = append(out, t.GPB)
+	return append(out, t.KeyB[:]...)
+}
+
+// WriteTrailer writes the trailer of an authenticated sector. Unless freeze
+// is set, it refuses access bits which never allow changing them again.
+func (c *Card) WriteTrailer(sector int, t Trailer, freeze bool) error {
+	if !freeze && t.Access[3].Trailer().AccessWrite == Never {
+		return fmt.Errorf("trailer access %03b of sector %d never allows changing the access bits again", t.Access[3], sector)
+	}
+	return c.WriteBlock(TrailerBlock(sector), t.Marshal())
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package classic
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestAccessBits(t *testing.T) {
+	tests := []struct {
+		bytes [3]byte
+		bits  AccessBits
+	}{
+		{[3]byte{0xFF, 0x07, 0x80}, DefaultAccessBits},
+		{[3]byte{0x78, 0x77, 0x88}, AccessBits{AccessDataWriteB, AccessDataWriteB, AccessDataWriteB, AccessTrailerKeyB}},
+		{[3]byte{0x7F, 0x07, 0x88}, AccessBits{0, 0, 0, 0b011}},
+		{[3]byte{0x0F, 0x00, 0xFF}, AccessBits{AccessDataB, AccessDataB, AccessDataB, AccessTrailerKeyB}},
+		{[3]byte{0x00, 0xF0, 0xFF}, AccessBits{AccessDataNever, AccessDataNever, AccessDataNever, AccessTrailerFrozen}},
+	}
+	for _, tt := range tests {
+		got, err := DecodeAccessBits(tt.bytes[:])
+		if err != nil || got != tt.bits {
+			t.Errorf("DecodeAccessBits(% X) = %v, %v; want %v", tt.bytes, got, err, tt.bits)
+		}
+		if enc := tt.bits.Encode(); enc != tt.bytes {
+			t.Errorf("Encode(%v) = % X, want % X", tt.bits, enc, tt.bytes)
+		}
+	}
+	for a := Access(0); a < 8; a++ {
+		bits := AccessBits{a, 7 - a, a, 7 - a}
+		enc := bits.Encode()
+		if got, err := DecodeAccessBits(enc[:]); err != nil || got != bits {
+			t.Errorf("round trip of %v = %v, %v", bits, got, err)
+		}
+	}
+	if _, err := DecodeAccessBits([]byte{0xFF, 0x07, 0x00}); !errors.Is(err, ErrAccessBits) {
+		t.Errorf("DecodeAccessBits() of inconsistent bytes error = %v, want ErrAccessBits", err)
+	}
+}
+
+func TestAccessPermissions(t *testing.T) {
+	if got := AccessDataWriteB.Data(); got != (DataAccess{Read: WithKeyAB, Write: WithKeyB}) {
+		t.Errorf("AccessDataWriteB.Data() = %+v", got)
+	}
+	if got := AccessTrailerKeyB.Trailer(); got.KeyAWrite != WithKeyB || got.AccessWrite != WithKeyB || got.KeyBRead != Never {
+		t.Errorf("AccessTrailerKeyB.Trailer() = %+v", got)
+	}
+	if got := AccessTrailerTransport.Trailer().KeyBRead.String(); got != "A" {
+		t.Errorf("transport key B read = %s, want A", got)
+	}
+}
+
+func TestTrailer(t *testing.T) {
+	block := []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5, 0x78, 0x77, 0x88, 0xC1, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	tr, err := ParseTrailer(block)
+	if err != nil {
+		t.Fatalf("ParseTrailer() error = %v", err)
+	}
+	want := Trailer{KeyA: DefaultKeys[1], Access: AccessBits{AccessDataWriteB, AccessDataWriteB, AccessDataWriteB, AccessTrailerKeyB}, GPB: 0xC1, KeyB: Key{1, 2, 3, 4, 5, 6}}
+	if tr != want {
+		t.Errorf("ParseTrailer() = %+v, want %+v", tr, want)
+	}
+	if got := tr.Marshal(); !bytes.Equal(got, block) {
+		t.Errorf("Marshal() = % X, want % X", got, block)
+	}
+
+	fake := newFakeCard()
+	card := NewCard(fake)
+	if err := card.Authenticate(TrailerBlock(1), KeyA, DefaultKeys[0]); err != nil {
+		t.Fatal(err)
+	}
+	frozen := Trailer{Access: AccessBits{0, 0, 0, AccessTrailerFrozen}}
+	if err := card.WriteTrailer(1, frozen, false); err == nil {
+		t.Error("WriteTrailer() accepted frozen access bits")
+	}
+	if err := card.WriteTrailer(1, tr, false); err != nil {
+		t.Fatalf("WriteTrailer() error = %v", err)
+	}
+	if !bytes.Equal(fake.mem[TrailerBlock(1)][:], block) {
+		t.Errorf("trailer = % X", fake.mem[TrailerBlock(1)])
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package classic drives MIFARE Classic cards through the storage card
+// commands of PC/SC readers (PC/SC part 3): loading keys, authenticating
+// sectors and reading and writing blocks.
+package classic
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/crypto/keystore"
+	"github.com/happy-sdk/scardkit/crypto/securemem"
+)
+
+// BlockSize is the size of a block in bytes.
+const BlockSize = 16
+
+// ErrAuth is returned when a sector rejects the key presented.
+var ErrAuth = errors.New("classic: authentication failed")
+
+// KeyType selects key A or key B of a sector.
+type KeyType byte
+
+const (
+	KeyA KeyType = 0x60
+	KeyB KeyType = 0x61
+)
+
+// String returns "A" or "B".
+func (t KeyType) String() string {
+	if t == KeyB {
+		return "B"
+	}
+	return "A"
+}
+
+// Key is a 48-bit sector key.
+type Key [6]byte
+
+// String returns the key as upper case hex.
+func (k Key) String() string { return fmt.Sprintf("%X", k[:]) }
+
+// ParseKey parses a key from 12 hex digits.
+func ParseKey(s string) (Key, error) {
+	var k Key
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(k) {
+		return k, fmt.Errorf("invalid key %q", s)
+	}
+	copy(k[:], b)
+	return k, nil
+}
+
+// LoadKey returns the key id from p.
+func LoadKey(p keystore.Provider, id string) (Key, error) {
+	var k Key
+	b, err := p.GetKey(id, 0)
+	if err != nil {
+		return k, err
+	}
+	defer securemem.Zero(b)
+	if len(b) != len(k) {
+		return k, fmt.Errorf("key %s of %d bytes, want %d", id, len(b), len(k))
+	}
+	copy(k[:], b)
+	return k, nil
+}
+
+// Size is the memory layout of a Classic card.
+type Size int
+
+const (
+	SizeMini Size = 5  // MIFARE Mini, 5 sectors.
+	Size1K   Size = 16 // MIFARE Classic 1K, 16 sectors.
+	Size4K   Size = 40 // MIFARE Classic 4K, 32 small and 8 large sectors.
+)
+
+// Sectors returns the number of sectors.
+func (s Size) Sectors() int { return int(s) }
+
+// SectorBlocks returns the number of blocks of sector, 4 or, for the last
+// 8 sectors of a 4K card, 16.
+func SectorBlocks(sector int) int {
+	if sector < 32 {
+		return 4
+	}
+	return 16
+}
+
+// FirstBlock returns the first block of sector.
+func FirstBlock(sector int) int {
+	if sector < 32 {
+		return sector * 4
+	}
+	return 128 + (sector-32)*16
+}
+
+// TrailerBlock returns the sector trailer block of sector, holding its keys
+// and access conditions.
+func TrailerBlock(sector int) int { return FirstBlock(sector) + SectorBlocks(sector) - 1 }
+
+// Card is a Classic card behind a PC/SC reader.
+type Card struct {
+	tr apdu.Transceiver
+	// KeySlot is the reader key slot keys are loaded into, 0 by default.
+	KeySlot byte
+}
+
+// NewCard returns the card reached through tr, typically a card connected
+// through a PC/SC reader.
+func NewCard(tr apdu.Transceiver) *Card { return &Card{tr: tr} }
+
+func (c *Card) transmit(op string, cmd []byte) ([]byte, error) {
+	resp, err := c.tr.Transmit(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return resp[:len(resp)-2], nil
+}
+
+// Authenticate loads key into the reader and authenticates the sector of
+// block with it, returning ErrAuth when the card rejects it. A card rejecting
+// a key usually halts and must be reactivated before the next attempt.
+func (c *Card) Authenticate(block int, kt KeyType, key Key) error {
+	if block < 0 || block > 0xFF {
+		return fmt.Errorf("block %d out of range", block)
+	}
+	if _, err := c.transmit("load key", append([]byte{0xFF, 0x82, 0x00, c.KeySlot, 0x06}, key[:]...)); err != nil {
+		return err
+	}
+	resp, err := c.tr.Transmit([]byte{0xFF, 0x86, 0x00, 0x00, 0x05, 0x01, 0x00, byte(block), byte(kt), c.KeySlot})
+	if err != nil {
+		return fmt.Errorf("authenticate block %d: %w", block, err)
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		var se *apdu.StatusError
+		if errors.As(err, &se) && se.SW1 == 0x63 {
+			return fmt.Errorf("%w: block %d key %s", ErrAuth, block, kt)
+		}
+		return fmt.Errorf("authenticate block %d: %w", block, err)
+	}
+	return nil
+}
+
+// ReadBlock reads a block of an authenticated sector.
+func (c *Card) ReadBlock(block int) ([]byte, error) {
+	if block < 0 || block > 0xFF {
+		return nil, fmt.Errorf("block %d out of range", block)
+	}
+	data, err := c.transmit(fmt.Sprintf("read block %d", block), []byte{0xFF, 0xB0, 0x00, byte(block), BlockSize})
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != BlockSize {
+		return nil, fmt.Errorf("read block %d: short response of %d bytes", block, len(data))
+	}
+	return data, nil
+}
+
+// WriteBlock writes a block of an authenticated sector. Writing a sector
+// trailer changes the keys and access conditions of the sector.
+func (c *Card) WriteBlock(block int, data []byte) error {
+	if block <= 0 || block > 0xFF {
+		return fmt.Errorf("block %d out of range", block)
+	}
+	if len(data) != BlockSize {
+		return fmt.Errorf("block data must be %d bytes, got %d", BlockSize, len(data))
+	}
+	_, err := c.transmit(fmt.Sprintf("write block %d", block), append([]byte{0xFF, 0xD6, 0x00, byte(block), BlockSize}, data...))
+	return err
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package classic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/crypto/keystore"
+)
+
+// fakeCard emulates a Classic 1K card behind a PC/SC reader. Sector keys
+// are read from the sector trailers.
+type fakeCard struct {
+	mem      [64][BlockSize]byte
+	loaded   Key
+	authed   int // Authenticated sector, -1 when none.
+	attempts int
+}
+
+func newFakeCard() *fakeCard {
+	f := &fakeCard{authed: -1}
+	for s := 0; s < 16; s++ {
+		copy(f.mem[TrailerBlock(s)][:], []byte{
+			0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x07, 0x80, 0x69, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		})
+	}
+	return f
+}
+
+func (f *fakeCard) Transmit(cmd []byte) ([]byte, error) {
+	switch cmd[1] {
+	case 0x82:
+		copy(f.loaded[:], cmd[5:11])
+		return []byte{0x90, 0x00}, nil
+	case 0x86:
+		f.attempts++
+		block, kt := int(cmd[7]), KeyType(cmd[8])
+		trailer := f.mem[TrailerBlock(block/4)]
+		key := trailer[:6]
+		if kt == KeyB {
+			key = trailer[10:]
+		}
+		if !bytes.Equal(key, f.loaded[:]) {
+			f.authed = -1
+			return []byte{0x63, 0x00}, nil
+		}
+		f.authed = block / 4
+		return []byte{0x90, 0x00}, nil
+	case 0xB0, 0xD6:
+		block := int(cmd[3])
+		if block/4 != f.authed {
+			return []byte{0x69, 0x82}, nil
+		}
+		if cmd[1] == 0xD6 {
+			copy(f.mem[block][:], cmd[5:])
+			return []byte{0x90, 0x00}, nil
+		}
+		return append(append([]byte(nil), f.mem[block][:]...), 0x90, 0x00), nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+func TestSectorLayout(t *testing.T) {
+	tests := []struct{ sector, first, trailer int }{
+		{0, 0, 3},
+		{15, 60, 63},
+		{31, 124, 127},
+		{32, 128, 143},
+		{39, 240, 255},
+	}
+	for _, tt := range tests {
+		if first, trailer := FirstBlock(tt.sector), TrailerBlock(tt.sector); first != tt.first || trailer != tt.trailer {
+			t.Errorf("sector %d: blocks %d-%d, want %d-%d", tt.sector, first, trailer, tt.first, tt.trailer)
+		}
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	p := keystore.Map{{ID: "transit"}: {0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}, {ID: "short"}: {0x01}}
+	if k, err := LoadKey(p, "transit"); err != nil || k.String() != "A0A1A2A3A4A5" {
+		t.Errorf("LoadKey() = %v, %v", k, err)
+	}
+	if _, err := LoadKey(p, "short"); err == nil {
+		t.Error("LoadKey() accepted a 1 byte key")
+	}
+}
+
+func TestReadWriteBlock(t *testing.T) {
+	card := NewCard(newFakeCard())
+	if _, err := card.ReadBlock(4); err == nil {
+		t.Error("ReadBlock() without authentication succeeded")
+	}
+	if err := card.Authenticate(4, KeyA, Key{0, 0, 0, 0, 0, 0}); !errors.Is(err, ErrAuth) {
+		t.Errorf("Authenticate() with a wrong key error = %v, want ErrAuth", err)
+	}
+	if err := card.Authenticate(4, KeyA, DefaultKeys[0]); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	data := bytes.Repeat([]byte{0x5A}, BlockSize)
+	if err := card.WriteBlock(5, data); err != nil {
+		t.Fatalf("WriteBlock() error = %v", err)
+	}
+	if got, err := card.ReadBlock(5); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("ReadBlock() = % X, %v", got, err)
+	}
+	if err := card.WriteBlock(0, data); err == nil {
+		t.Error("WriteBlock() accepted the manufacturer block")
+	}
+}
+
+func TestAudit(t *testing.T) {
+	fake := newFakeCard()
+	secret := Key{1, 2, 3, 4, 5, 6}
+	mad := Key{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}
+	copy(fake.mem[TrailerBlock(1)][:6], mad[:])
+	copy(fake.mem[TrailerBlock(1)][10:], secret[:])
+	copy(fake.mem[TrailerBlock(2)][:6], secret[:])
+	copy(fake.mem[TrailerBlock(2)][10:], secret[:])
+
+	var reactivated int
+	found, err := Audit(context.Background(), NewCard(fake), AuditOptions{
+		Keys:       []Key{DefaultKeys[0], mad},
+		Reactivate: func() error { reactivated++; return nil },
+	})
+	if err != nil {
+		t.Fatalf("Audit() error = %v", err)
+	}
+	if len(found) != 16 {
+		t.Fatalf("Audit() reported %d sectors, want 16", len(found))
+	}
+	if s := found[0]; s.A == nil || *s.A != DefaultKeys[0] || s.B == nil || *s.B != DefaultKeys[0] {
+		t.Errorf("sector 0 = %+v", s)
+	}
+	if s := found[1]; s.A == nil || *s.A != mad || s.B != nil {
+		t.Errorf("sector 1 = %+v", s)
+	}
+	if s := found[2]; s.A != nil || s.B != nil {
+		t.Errorf("sector 2 = %+v", s)
+	}
+	// Sector 1: A fails once, B twice; sector 2: both keys fail twice.
+	if reactivated != 7 {
+		t.Errorf("reactivated %d times, want 7", reactivated)
+	}
+
+	fake.attempts = 0
+	start := time.Now()
+	_, err = Audit(context.Background(), NewCard(fake), AuditOptions{Keys: []Key{DefaultKeys[0]}, Size: SizeMini, Interval: 5 * time.Millisecond})
+	if err != nil || fake.attempts != 10 {
+		t.Fatalf("Audit() made %d attempts, %v", fake.attempts, err)
+	}
+	if d := time.Since(start); d < 45*time.Millisecond {
+		t.Errorf("10 attempts took %v with an interval of 5ms", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Audit(ctx, NewCard(fake), AuditOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Audit() with a canceled context error = %v", err)
+	}
+}
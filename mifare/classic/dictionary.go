@@ -0,0 +1,116 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package classic
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultKeys are well-known keys: transport keys, the NFC Forum MAD and
+// NDEF keys and keys widely published for legacy deployments.
+var DefaultKeys = []Key{
+	{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+	{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5},
+	{0xD3, 0xF7, 0xD3, 0xF7, 0xD3, 0xF7},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5},
+	{0x4D, 0x3A, 0x99, 0xC3, 0x51, 0xDD},
+	{0x1A, 0x98, 0x2C, 0x7E, 0x45, 0x9A},
+	{0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF},
+	{0x71, 0x4C, 0x5C, 0x88, 0x6E, 0x97},
+	{0x58, 0x7E, 0xE5, 0xF9, 0x35, 0x0F},
+	{0xA0, 0x47, 0x8C, 0xC3, 0x90, 0x91},
+	{0x53, 0x3C, 0xB6, 0xC7, 0x23, 0xF6},
+	{0x8F, 0xD0, 0xA4, 0xF2, 0x56, 0xE9},
+}
+
+// SectorKeys reports the keys found for a sector; nil when not found.
+type SectorKeys struct {
+	Sector int
+	A, B   *Key
+}
+
+// AuditOptions configure Audit.
+type AuditOptions struct {
+	// Keys is the dictionary tried, DefaultKeys when nil.
+	Keys []Key
+	// Size is the layout of the card, Size1K when zero.
+	Size Size
+	// Interval is the minimum delay between authentication attempts,
+	// limiting the rate at which keys are tried.
+	Interval time.Duration
+	// Reactivate, when set, is called after a rejected key to reactivate
+	// the halted card, e.g. by reconnecting to it with a reset.
+	Reactivate func() error
+	// Progress, when set, is called after every attempt.
+	Progress func(sector int, kt KeyType, key Key, ok bool)
+}
+
+// Audit tries the keys of the dictionary on both keys of every sector and
+// reports the sectors it opened and with which keys. It is meant for
+// auditing legacy deployments still using default or leaked keys. Errors
+// other than rejected keys end the audit, returning the sectors audited so
+// far.
+func Audit(ctx context.Context, card *Card, opts AuditOptions) ([]SectorKeys, error) {
+	keys, size := opts.Keys, opts.Size
+	if keys == nil {
+		keys = DefaultKeys
+	}
+	if size == 0 {
+		size = Size1K
+	}
+	var last time.Time
+	try := func(block int, kt KeyType, key Key) (bool, error) {
+		if wait := opts.Interval - time.Since(last); opts.Interval > 0 && wait > 0 {
+			select {
+			case <-ctx.Done():
+				return false, ctx.Err()
+			case <-time.After(wait):
+			}
+		} else if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		last = time.Now()
+		err := card.Authenticate(block, kt, key)
+		if errors.Is(err, ErrAuth) {
+			if opts.Reactivate != nil {
+				if err := opts.Reactivate(); err != nil {
+					return false, err
+				}
+			}
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	found := make([]SectorKeys, 0, size.Sectors())
+	for sector := 0; sector < size.Sectors(); sector++ {
+		sk := SectorKeys{Sector: sector}
+		for _, kt := range []KeyType{KeyA, KeyB} {
+			for _, key := range keys {
+				ok, err := try(TrailerBlock(sector), kt, key)
+				if err != nil {
+					return found, err
+				}
+				if opts.Progress != nil {
+					opts.Progress(sector, kt, key, ok)
+				}
+				if ok {
+					key := key
+					if kt == KeyA {
+						sk.A = &key
+					} else {
+						sk.B = &key
+					}
+					break
+				}
+			}
+		}
+		found = append(found, sk)
+	}
+	return found, nil
+}
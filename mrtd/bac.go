@@ -0,0 +1,103 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package mrtd
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"io"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/crypto"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// BAC performs Basic Access Control with the keys derived from key on the
+// selected eMRTD application and returns the established secure messaging.
+func BAC(tr apdu.Transceiver, key MRZKey) (*iso7816.SecureMessaging, error) {
+	return bac(tr, key, rand.Reader)
+}
+
+// bac performs BAC drawing RND.IFD and K.IFD from rnd.
+func bac(tr apdu.Transceiver, key MRZKey, rnd io.Reader) (*iso7816.SecureMessaging, error) {
+	seed, err := key.seed()
+	if err != nil {
+		return nil, err
+	}
+	kenc, kmac := kdf(seed, kdfEnc, 16, true), kdf(seed, kdfMAC, 16, true)
+
+	rndIC, err := transmit(tr, iso7816.NewCommandAPDU(0x00, 0x84, 0x00, 0x00, 8, nil))
+	if err != nil {
+		return nil, fmt.Errorf("mrtd: get challenge: %w", err)
+	}
+	if len(rndIC) != 8 {
+		return nil, fmt.Errorf("mrtd: get challenge returned %d bytes", len(rndIC))
+	}
+	s := make([]byte, 32)
+	if _, err := io.ReadFull(rnd, s[:8]); err != nil {
+		return nil, err
+	}
+	copy(s[8:16], rndIC)
+	if _, err := io.ReadFull(rnd, s[16:]); err != nil {
+		return nil, err
+	}
+	rndIFD, kIFD := s[:8], s[16:]
+
+	eIFD, err := tdesCBC(kenc, s, true)
+	if err != nil {
+		return nil, err
+	}
+	mIFD, err := crypto.RetailMAC(kmac, crypto.Pad(eIFD, des.BlockSize))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := transmit(tr, iso7816.NewCommandAPDU(0x00, 0x82, 0x00, 0x00, 40, append(eIFD, mIFD...)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: mutual authenticate: %w", ErrAuthentication, err)
+	}
+	if len(resp) != 40 {
+		return nil, fmt.Errorf("mrtd: mutual authenticate returned %d bytes", len(resp))
+	}
+	mIC, err := crypto.RetailMAC(kmac, crypto.Pad(resp[:32], des.BlockSize))
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(mIC, resp[32:]) != 1 {
+		return nil, fmt.Errorf("%w: chip cryptogram checksum mismatch", ErrAuthentication)
+	}
+	r, err := tdesCBC(kenc, resp[:32], false)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(r[:8], rndIC) || !bytes.Equal(r[8:16], rndIFD) {
+		return nil, fmt.Errorf("%w: chip returned other challenges", ErrAuthentication)
+	}
+
+	kseed := make([]byte, 16)
+	subtle.XORBytes(kseed, kIFD, r[16:])
+	ssc := append(append([]byte(nil), rndIC[4:]...), rndIFD[4:]...)
+	return iso7816.NewSecureMessaging3DES(kdf(kseed, kdfEnc, 16, true), kdf(kseed, kdfMAC, 16, true), ssc)
+}
+
+// tdesCBC encrypts or decrypts data with the two-key 3DES key k in CBC
+// mode with a zero IV.
+func tdesCBC(k, data []byte, encrypt bool) ([]byte, error) {
+	block, err := des.NewTripleDESCipher(append(append([]byte(nil), k...), k[:8]...))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	iv := make([]byte, des.BlockSize)
+	if encrypt {
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	} else {
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	}
+	return out, nil
+}
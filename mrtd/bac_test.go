@@ -0,0 +1,107 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package mrtd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// specimen is the key of the ICAO Doc 9303 part 11 worked example.
+var specimen = MRZKey{DocumentNumber: "L898902C", DateOfBirth: "690806", DateOfExpiry: "940623"}
+
+func TestCheckDigit(t *testing.T) {
+	tests := []struct {
+		field string
+		want  int
+	}{
+		{"L898902C<", 3},
+		{"690806", 1},
+		{"940623", 6},
+		{"D23145890", 7},
+		{"<<<<<<<<<", 0},
+	}
+	for _, tt := range tests {
+		if got, err := CheckDigit(tt.field); err != nil || got != tt.want {
+			t.Errorf("CheckDigit(%s) = %d, %v, want %d", tt.field, got, err, tt.want)
+		}
+	}
+	if _, err := CheckDigit("l8989"); err == nil {
+		t.Error("CheckDigit() accepted a lower case letter")
+	}
+}
+
+func TestMRZKey(t *testing.T) {
+	info, err := specimen.Info()
+	if err != nil || info != "L898902C<369080619406236" {
+		t.Fatalf("Info() = %s, %v", info, err)
+	}
+	seed, err := specimen.seed()
+	if err != nil || !bytes.Equal(seed, unhex("239AB9CB282DAF66231DC5A4DF6BFBAE")) {
+		t.Fatalf("seed() = %X, %v", seed, err)
+	}
+	if got := kdf(seed, kdfEnc, 16, true); !bytes.Equal(got, unhex("AB94FDECF2674FDFB9B391F85D7F76F2")) {
+		t.Errorf("Kenc = %X", got)
+	}
+	if got := kdf(seed, kdfMAC, 16, true); !bytes.Equal(got, unhex("7962D9ECE03D1ACD4C76089DCE131543")) {
+		t.Errorf("Kmac = %X", got)
+	}
+	if _, err := (MRZKey{DocumentNumber: "L898902C", DateOfBirth: "6908"}).Info(); err == nil {
+		t.Error("Info() accepted a short date")
+	}
+}
+
+// TestBAC replays the worked example of ICAO Doc 9303 part 11.
+func TestBAC(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutual string
+		err    error
+	}{
+		{"specimen", "46B9342A41396CD7386BF5803104D7CEDC122B9132139BAF2EEDC94EE178534F2F2D235D074D74499000", nil},
+		{"wrong key", "6300", ErrAuthentication},
+		{"bad checksum", "46B9342A41396CD7386BF5803104D7CEDC122B9132139BAF2EEDC94EE178534F2F2D235D074D74489000", ErrAuthentication},
+	}
+	for _, tt := range tests {
+		var cmds [][]byte
+		tr := apdu.TransceiverFunc(func(cmd []byte) ([]byte, error) {
+			cmds = append(cmds, cmd)
+			if cmd[1] == 0x84 {
+				return unhex("4608F919887022129000"), nil
+			}
+			return unhex(tt.mutual), nil
+		})
+		rnd := bytes.NewReader(unhex("781723860C06C2260B795240CB7049B01C19B33E32804F0B"))
+		sm, err := bac(tr, specimen, rnd)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: bac() error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		want := unhex("008200002872C29C2371CC9BDB65B779B8E8D37B29ECC154AA56A8799FAE2F498F76ED92F25F1448EEA8AD90A728")
+		if len(cmds) != 2 || !bytes.Equal(cmds[1], want) {
+			t.Errorf("%s: mutual authenticate = %X", tt.name, cmds[len(cmds)-1])
+		}
+		if err != nil {
+			continue
+		}
+		// The session keys and SSC protect SELECT EF.COM as in the example.
+		got, err := sm.Protect(unhex("00A4020C02011E"))
+		if err != nil || !bytes.Equal(got, unhex("0CA4020C158709016375432908C044F68E08BF8B92D635FF24F800")) {
+			t.Errorf("%s: Protect() = %X, %v", tt.name, got, err)
+		}
+	}
+}
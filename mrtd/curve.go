@@ -0,0 +1,177 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package mrtd
+
+import (
+	"crypto/elliptic"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// curve is a short Weierstrass curve y² = x³ + ax + b over GF(p) with the
+// generator (gx, gy) of order n. PACE generic mapping needs point addition
+// with a generator derived per session, and the Brainpool curves used by
+// most documents, neither of which crypto/ecdh offers. The affine
+// arithmetic here is not constant time; it only handles keys used for a
+// single session.
+type curve struct {
+	p, a, b, n *big.Int
+	gx, gy     *big.Int
+}
+
+// point is an affine curve point, nil coordinates being the point at
+// infinity.
+type point struct{ x, y *big.Int }
+
+func hexInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("mrtd: bad curve constant " + s)
+	}
+	return n
+}
+
+// nistCurve returns the parameters of a NIST curve of crypto/elliptic,
+// whose a is -3.
+func nistCurve(c elliptic.Curve) *curve {
+	params := c.Params()
+	return &curve{
+		p:  params.P,
+		a:  new(big.Int).Sub(params.P, big.NewInt(3)),
+		b:  params.B,
+		n:  params.N,
+		gx: params.Gx,
+		gy: params.Gy,
+	}
+}
+
+var (
+	brainpoolP256r1 = &curve{
+		p:  hexInt("A9FB57DBA1EEA9BC3E660A909D838D726E3BF623D52620282013481D1F6E5377"),
+		a:  hexInt("7D5A0975FC2C3057EEF67530417AFFE7FB8055C126DC5C6CE94A4B44F330B5D9"),
+		b:  hexInt("26DC5C6CE94A4B44F330B5D9BBD77CBF958416295CF7E1CE6BCCDC18FF8C07B6"),
+		n:  hexInt("A9FB57DBA1EEA9BC3E660A909D838D718C397AA3B561A6F7901E0E82974856A7"),
+		gx: hexInt("8BD2AEB9CB7E57CB2C4B482FFC81B7AFB9DE27E1E3BD23C23A4453BD9ACE3262"),
+		gy: hexInt("547EF835C3DAC4FD97F8461A14611DC9C27745132DED8E545C1D54C72F046997"),
+	}
+	brainpoolP384r1 = &curve{
+		p:  hexInt("8CB91E82A3386D280F5D6F7E50E641DF152F7109ED5456B412B1DA197FB71123ACD3A729901D1A71874700133107EC53"),
+		a:  hexInt("7BC382C63D8C150C3C72080ACE05AFA0C2BEA28E4FB22787139165EFBA91F90F8AA5814A503AD4EB04A8C7DD22CE2826"),
+		b:  hexInt("04A8C7DD22CE28268B39B55416F0447C2FB77DE107DCD2A62E880EA53EEB62D57CB4390295DBC9943AB78696FA504C11"),
+		n:  hexInt("8CB91E82A3386D280F5D6F7E50E641DF152F7109ED5456B31F166E6CAC0425A7CF3AB6AF6B7FC3103B883202E9046565"),
+		gx: hexInt("1D1C64F068CF45FFA2A63A81B7C13F6B8847A3E77EF14FE3DB7FCAFE0CBD10E8E826E03436D646AAEF87B2E247D4AF1E"),
+		gy: hexInt("8ABE1D7520F9C2A45CB1EB8E95CFD55262B70B29FEEC5864E19C054FF99129280E4646217791811142820341263C5315"),
+	}
+)
+
+// standardizedCurve returns the curve of a standardized PACE domain
+// parameter identifier of BSI TR-03110 part 3.
+func standardizedCurve(id int) (*curve, error) {
+	switch id {
+	case 12:
+		return nistCurve(elliptic.P256()), nil
+	case 13:
+		return brainpoolP256r1, nil
+	case 15:
+		return nistCurve(elliptic.P384()), nil
+	case 16:
+		return brainpoolP384r1, nil
+	case 18:
+		return nistCurve(elliptic.P521()), nil
+	}
+	return nil, fmt.Errorf("%w: domain parameters %d", ErrUnsupported, id)
+}
+
+func (c *curve) generator() point { return point{c.gx, c.gy} }
+
+// size returns the length in bytes of a field element.
+func (c *curve) size() int { return (c.p.BitLen() + 7) / 8 }
+
+func (c *curve) onCurve(q point) bool {
+	if q.x == nil || q.x.Sign() < 0 || q.x.Cmp(c.p) >= 0 || q.y.Sign() < 0 || q.y.Cmp(c.p) >= 0 {
+		return false
+	}
+	lhs := new(big.Int).Mul(q.y, q.y)
+	rhs := new(big.Int).Mul(q.x, q.x)
+	rhs.Add(rhs, c.a).Mul(rhs, q.x).Add(rhs, c.b)
+	return lhs.Sub(lhs, rhs).Mod(lhs, c.p).Sign() == 0
+}
+
+func (c *curve) add(q, r point) point {
+	switch {
+	case q.x == nil:
+		return r
+	case r.x == nil:
+		return q
+	}
+	var l *big.Int
+	if q.x.Cmp(r.x) == 0 {
+		if s := new(big.Int).Add(q.y, r.y); s.Mod(s, c.p).Sign() == 0 {
+			return point{}
+		}
+		// l = (3x² + a) / 2y
+		num := new(big.Int).Mul(q.x, q.x)
+		num.Mul(num, big.NewInt(3)).Add(num, c.a)
+		den := new(big.Int).Lsh(q.y, 1)
+		l = num.Mul(num, den.ModInverse(den.Mod(den, c.p), c.p))
+	} else {
+		// l = (y2 - y1) / (x2 - x1)
+		num := new(big.Int).Sub(r.y, q.y)
+		den := new(big.Int).Sub(r.x, q.x)
+		l = num.Mul(num, den.ModInverse(den.Mod(den, c.p), c.p))
+	}
+	l.Mod(l, c.p)
+	x := new(big.Int).Mul(l, l)
+	x.Sub(x, q.x).Sub(x, r.x).Mod(x, c.p)
+	y := new(big.Int).Sub(q.x, x)
+	y.Mul(y, l).Sub(y, q.y).Mod(y, c.p)
+	return point{x, y}
+}
+
+func (c *curve) mul(q point, k *big.Int) point {
+	var r point
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		r = c.add(r, r)
+		if k.Bit(i) == 1 {
+			r = c.add(r, q)
+		}
+	}
+	return r
+}
+
+// generateKey returns a private key in [1, n-1] read from rnd.
+func (c *curve) generateKey(rnd io.Reader) (*big.Int, error) {
+	b := make([]byte, (c.n.BitLen()+7)/8+8)
+	if _, err := io.ReadFull(rnd, b); err != nil {
+		return nil, err
+	}
+	k := new(big.Int).SetBytes(b)
+	k.Mod(k, new(big.Int).Sub(c.n, big.NewInt(1)))
+	return k.Add(k, big.NewInt(1)), nil
+}
+
+// marshal encodes q as an uncompressed point.
+func (c *curve) marshal(q point) []byte {
+	n := c.size()
+	out := make([]byte, 1+2*n)
+	out[0] = 0x04
+	q.x.FillBytes(out[1 : 1+n])
+	q.y.FillBytes(out[1+n:])
+	return out
+}
+
+// unmarshal decodes an uncompressed point and checks it lies on c.
+func (c *curve) unmarshal(b []byte) (point, error) {
+	n := c.size()
+	if len(b) != 1+2*n || b[0] != 0x04 {
+		return point{}, fmt.Errorf("mrtd: malformed public key of %d bytes", len(b))
+	}
+	q := point{new(big.Int).SetBytes(b[1 : 1+n]), new(big.Int).SetBytes(b[1+n:])}
+	if !c.onCurve(q) {
+		return point{}, fmt.Errorf("mrtd: public key not on curve")
+	}
+	return q, nil
+}
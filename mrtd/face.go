@@ -0,0 +1,84 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package mrtd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Image data types of ISO/IEC 19794-5.
+const (
+	imageJPEG     = 0x00
+	imageJPEG2000 = 0x01
+)
+
+// FaceImage is a facial image of an ISO/IEC 19794-5 facial record.
+type FaceImage struct {
+	// MIMEType is "image/jpeg" or "image/jp2".
+	MIMEType string
+	Width    int
+	Height   int
+	// Gender is the gender recorded, 1 male, 2 female, 0 or 0xFF unknown.
+	Gender byte
+	// Data is the encoded image.
+	Data []byte
+}
+
+var (
+	jpegMagic     = []byte{0xFF, 0xD8}
+	jpeg2000Magic = []byte{0x00, 0x00, 0x00, 0x0C, 0x6A, 0x50, 0x20, 0x20}
+	jpeg2000Code  = []byte{0xFF, 0x4F, 0xFF, 0x51}
+)
+
+// ParseFacialRecord extracts the images of an ISO/IEC 19794-5 facial
+// record, the biometric data block of a DG2 biometric template.
+func ParseFacialRecord(b []byte) ([]FaceImage, error) {
+	if len(b) < 14 || !bytes.Equal(b[:4], []byte("FAC\x00")) {
+		return nil, fmt.Errorf("mrtd: not a facial record")
+	}
+	n := int(binary.BigEndian.Uint16(b[12:14]))
+	rest := b[14:]
+	var images []FaceImage
+	for i := 0; i < n; i++ {
+		// Facial information, feature points and image information
+		// precede the image data.
+		if len(rest) < 20 {
+			return nil, fmt.Errorf("mrtd: facial record truncated")
+		}
+		size := int(binary.BigEndian.Uint32(rest[:4]))
+		points := int(binary.BigEndian.Uint16(rest[4:6]))
+		start := 20 + 8*points + 12
+		if size < start || size > len(rest) {
+			return nil, fmt.Errorf("mrtd: facial record block of %d bytes", size)
+		}
+		info := rest[20+8*points : start]
+		img := FaceImage{
+			Width:  int(binary.BigEndian.Uint16(info[2:4])),
+			Height: int(binary.BigEndian.Uint16(info[4:6])),
+			Gender: rest[6],
+			Data:   rest[start:size],
+		}
+		img.MIMEType = imageType(info[1], img.Data)
+		images = append(images, img)
+		rest = rest[size:]
+	}
+	return images, nil
+}
+
+// imageType returns the MIME type of the image data of type typ, relying
+// on the data itself as issuers do not always set the type right.
+func imageType(typ byte, data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, jpegMagic):
+		return "image/jpeg"
+	case bytes.HasPrefix(data, jpeg2000Magic), bytes.HasPrefix(data, jpeg2000Code):
+		return "image/jp2"
+	case typ == imageJPEG2000:
+		return "image/jp2"
+	}
+	return "image/jpeg"
+}
@@ -0,0 +1,135 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package mrtd
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// Key is an access key of a document, an MRZKey or a CAN.
+type Key interface {
+	// password returns the PACE password and its reference.
+	password() ([]byte, byte, error)
+}
+
+// MRZKey is the access key printed in the machine readable zone. Dates are
+// given as YYMMDD.
+type MRZKey struct {
+	DocumentNumber string
+	DateOfBirth    string
+	DateOfExpiry   string
+}
+
+// Info returns the MRZ information the keys are derived from: the document
+// number, date of birth and date of expiry, each followed by its check
+// digit.
+func (k MRZKey) Info() (string, error) {
+	num := strings.ToUpper(k.DocumentNumber)
+	for len(num) < 9 {
+		num += "<"
+	}
+	if len(k.DateOfBirth) != 6 || len(k.DateOfExpiry) != 6 {
+		return "", fmt.Errorf("mrtd: dates must be given as YYMMDD")
+	}
+	var b strings.Builder
+	for _, field := range []string{num, k.DateOfBirth, k.DateOfExpiry} {
+		d, err := CheckDigit(field)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(field)
+		b.WriteByte('0' + byte(d))
+	}
+	return b.String(), nil
+}
+
+// seed returns the key seed of BAC, the first 16 bytes of the SHA-1 hash of
+// the MRZ information.
+func (k MRZKey) seed() ([]byte, error) {
+	info, err := k.Info()
+	if err != nil {
+		return nil, err
+	}
+	h := sha1.Sum([]byte(info))
+	return h[:16], nil
+}
+
+func (k MRZKey) password() ([]byte, byte, error) {
+	info, err := k.Info()
+	if err != nil {
+		return nil, 0, err
+	}
+	h := sha1.Sum([]byte(info))
+	return h[:], passwordMRZ, nil
+}
+
+// CAN is the card access number printed on documents supporting PACE.
+type CAN string
+
+func (c CAN) password() ([]byte, byte, error) {
+	if len(c) == 0 {
+		return nil, 0, fmt.Errorf("mrtd: empty card access number")
+	}
+	return []byte(c), passwordCAN, nil
+}
+
+// PACE password references.
+const (
+	passwordMRZ = 0x01
+	passwordCAN = 0x02
+)
+
+// CheckDigit computes the check digit of an MRZ field with the repeating
+// weights 7, 3, 1. Digits count their value, letters A to Z 10 to 35 and
+// the filler '<' zero.
+func CheckDigit(field string) (int, error) {
+	weights := [3]int{7, 3, 1}
+	sum := 0
+	for i := 0; i < len(field); i++ {
+		var v int
+		switch c := field[i]; {
+		case c >= '0' && c <= '9':
+			v = int(c - '0')
+		case c >= 'A' && c <= 'Z':
+			v = int(c-'A') + 10
+		case c == '<':
+		default:
+			return 0, fmt.Errorf("mrtd: invalid MRZ character %q", c)
+		}
+		sum += v * weights[i%3]
+	}
+	return sum % 10, nil
+}
+
+// KDF counters of ICAO Doc 9303 part 11.
+const (
+	kdfEnc = 1
+	kdfMAC = 2
+	kdfPI  = 3
+)
+
+// kdf derives a key of keyLen bytes from the shared secret k and counter c:
+// 16 byte keys from SHA-1 with 3DES parity for two-key 3DES, AES-128 keys
+// from SHA-1 and longer AES keys from SHA-256.
+func kdf(k []byte, c byte, keyLen int, tdes bool) []byte {
+	msg := append(append([]byte(nil), k...), 0, 0, 0, c)
+	if keyLen > 16 {
+		h := sha256.Sum256(msg)
+		return h[:keyLen]
+	}
+	h := sha1.Sum(msg)
+	key := h[:16]
+	if tdes {
+		for i, b := range key {
+			// Set odd parity in the least significant bit.
+			p := b>>7 ^ b>>6 ^ b>>5 ^ b>>4 ^ b>>3 ^ b>>2 ^ b>>1
+			key[i] = b&0xFE | ^p&0x01
+		}
+	}
+	return key
+}
@@ -0,0 +1,145 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package mrtd
+
+import (
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// dataGroupTags are the tags of the data groups 1 to 16, as listed in
+// EF.COM.
+var dataGroupTags = [...]byte{0x61, 0x75, 0x63, 0x76, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6A, 0x6B, 0x6C, 0x6D, 0x6E, 0x6F, 0x70}
+
+// COM is the content of EF.COM.
+type COM struct {
+	// LDSVersion is the version of the logical data structure, e.g. "0107".
+	LDSVersion string
+	// UnicodeVersion is the Unicode version used, e.g. "040000".
+	UnicodeVersion string
+	// DataGroups are the numbers of the data groups present.
+	DataGroups []int
+}
+
+// ParseCOM parses EF.COM.
+func ParseCOM(b []byte) (*COM, error) {
+	v, err := template(b, 0x60)
+	if err != nil {
+		return nil, fmt.Errorf("mrtd: EF.COM: %w", err)
+	}
+	tlvs, err := iso7816.ParseTLV(v)
+	if err != nil {
+		return nil, fmt.Errorf("mrtd: EF.COM: %w", err)
+	}
+	com := &COM{}
+	for _, t := range tlvs {
+		switch t.Tag {
+		case 0x5F01:
+			com.LDSVersion = string(t.Value)
+		case 0x5F36:
+			com.UnicodeVersion = string(t.Value)
+		case 0x5C:
+			for _, tag := range t.Value {
+				for i, dg := range dataGroupTags {
+					if tag == dg {
+						com.DataGroups = append(com.DataGroups, i+1)
+					}
+				}
+			}
+		}
+	}
+	return com, nil
+}
+
+// DG1 is the content of data group 1.
+type DG1 struct {
+	// MRZ is the machine readable zone, its lines concatenated: 90
+	// characters for TD1 cards, 72 for TD2 and 88 for TD3 passports.
+	MRZ string
+	// Fields are the data elements of the machine readable zone.
+	Fields MRZ
+}
+
+// ParseDG1 parses data group 1.
+func ParseDG1(b []byte) (*DG1, error) {
+	v, err := template(b, 0x61)
+	if err != nil {
+		return nil, fmt.Errorf("mrtd: DG1: %w", err)
+	}
+	mrz, ok := iso7816.FindTLV(v, 0x5F1F)
+	if !ok {
+		return nil, fmt.Errorf("mrtd: DG1: missing MRZ data object")
+	}
+	fields, err := ParseMRZ(string(mrz))
+	if err != nil {
+		return nil, err
+	}
+	return &DG1{MRZ: string(mrz), Fields: *fields}, nil
+}
+
+// DG2 is the content of data group 2.
+type DG2 struct {
+	// Faces are the biometric data blocks of the encoded faces, each an
+	// ISO/IEC 19794-5 facial record.
+	Faces [][]byte
+	// Images are the facial images of the biometric data blocks.
+	Images []FaceImage
+}
+
+// ParseDG2 parses data group 2, a biometric information group template.
+func ParseDG2(b []byte) (*DG2, error) {
+	v, err := template(b, 0x75)
+	if err != nil {
+		return nil, fmt.Errorf("mrtd: DG2: %w", err)
+	}
+	group, err := template(v, 0x7F61)
+	if err != nil {
+		return nil, fmt.Errorf("mrtd: DG2: %w", err)
+	}
+	tlvs, err := iso7816.ParseTLV(group)
+	if err != nil {
+		return nil, fmt.Errorf("mrtd: DG2: %w", err)
+	}
+	dg := &DG2{}
+	for _, t := range tlvs {
+		if t.Tag != 0x7F60 {
+			continue
+		}
+		children, err := t.Children()
+		if err != nil {
+			return nil, fmt.Errorf("mrtd: DG2: %w", err)
+		}
+		for _, c := range children {
+			if c.Tag != 0x5F2E && c.Tag != 0x7F2E {
+				continue
+			}
+			dg.Faces = append(dg.Faces, c.Value)
+			if c.Tag == 0x7F2E {
+				// Enciphered or otherwise wrapped data block.
+				continue
+			}
+			images, err := ParseFacialRecord(c.Value)
+			if err != nil {
+				return nil, err
+			}
+			dg.Images = append(dg.Images, images...)
+		}
+	}
+	return dg, nil
+}
+
+// template returns the value of the data object tag which b must start
+// with.
+func template(b []byte, tag iso7816.Tag) ([]byte, error) {
+	tlvs, err := iso7816.ParseTLV(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(tlvs) == 0 || tlvs[0].Tag != tag {
+		return nil, fmt.Errorf("missing template %X", uint32(tag))
+	}
+	return tlvs[0].Value, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package mrtd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+func TestParseMRZ(t *testing.T) {
+	tests := []struct {
+		name string
+		mrz  string
+		want MRZ
+	}{
+		{
+			name: "td3",
+			mrz:  "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<" + "L898902C36UTO7408122F1204159ZE184226B<<<<<10",
+			want: MRZ{DocumentCode: "P", IssuingState: "UTO", PrimaryIdentifier: "ERIKSSON", SecondaryIdentifier: "ANNA MARIA",
+				DocumentNumber: "L898902C3", Nationality: "UTO", DateOfBirth: "740812", Sex: "F", DateOfExpiry: "120415", OptionalData: "ZE184226B"},
+		},
+		{
+			name: "td2",
+			mrz:  "I<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<" + "D231458907UTO7408122F1204159<<<<<<<6",
+			want: MRZ{DocumentCode: "I", IssuingState: "UTO", PrimaryIdentifier: "ERIKSSON", SecondaryIdentifier: "ANNA MARIA",
+				DocumentNumber: "D23145890", Nationality: "UTO", DateOfBirth: "740812", Sex: "F", DateOfExpiry: "120415"},
+		},
+		{
+			name: "td1",
+			mrz:  "I<UTOD231458907<<<<<<<<<<<<<<<" + "7408122F1204159UTO<<<<<<<<<<<6" + "ERIKSSON<<ANNA<MARIA<<<<<<<<<<",
+			want: MRZ{DocumentCode: "I", IssuingState: "UTO", PrimaryIdentifier: "ERIKSSON", SecondaryIdentifier: "ANNA MARIA",
+				DocumentNumber: "D23145890", Nationality: "UTO", DateOfBirth: "740812", Sex: "F", DateOfExpiry: "120415"},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseMRZ(tt.mrz)
+		if err != nil {
+			t.Errorf("%s: ParseMRZ() error = %v", tt.name, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("%s: ParseMRZ() = %+v\nwant %+v", tt.name, *got, tt.want)
+		}
+	}
+
+	if key := (&MRZ{DocumentNumber: "L898902C", DateOfBirth: "690806", DateOfExpiry: "940623"}).Key(); key != specimen {
+		t.Errorf("Key() = %+v", key)
+	}
+	for _, bad := range []string{
+		"P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<" + "L898902C46UTO7408122F1204159ZE184226B<<<<<10",
+		"P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<" + "L898902C36UTO7408122F1204159ZE184226B<<<<<11",
+		"P<UTOERIKSSON",
+	} {
+		if _, err := ParseMRZ(bad); err == nil {
+			t.Errorf("ParseMRZ(%s) succeeded", bad)
+		}
+	}
+}
+
+// facialRecord returns an ISO/IEC 19794-5 facial record holding img with
+// two feature points.
+func facialRecord(img []byte, width, height int) []byte {
+	block := make([]byte, 20+16+12)
+	binary.BigEndian.PutUint32(block, uint32(len(block)+len(img)))
+	binary.BigEndian.PutUint16(block[4:], 2)
+	block[6] = 2
+	info := block[36:]
+	binary.BigEndian.PutUint16(info[2:], uint16(width))
+	binary.BigEndian.PutUint16(info[4:], uint16(height))
+	block = append(block, img...)
+
+	header := []byte("FAC\x00010\x00\x00\x00\x00\x00\x00\x01")
+	binary.BigEndian.PutUint32(header[8:], uint32(len(header)+len(block)))
+	return append(header, block...)
+}
+
+func TestParseDG2(t *testing.T) {
+	jpeg := append([]byte{0xFF, 0xD8, 0xFF, 0xE0}, bytes.Repeat([]byte{0x42}, 100)...)
+	jp2 := append([]byte{0x00, 0x00, 0x00, 0x0C, 0x6A, 0x50, 0x20, 0x20, 0x0D, 0x0A}, bytes.Repeat([]byte{0x17}, 50)...)
+	var group []byte
+	group = iso7816.AppendTLV(group, 0x02, []byte{0x02})
+	for _, rec := range [][]byte{facialRecord(jpeg, 480, 640), facialRecord(jp2, 240, 320)} {
+		instance := iso7816.AppendTLV(iso7816.AppendTLV(nil, 0xA1, []byte{0x87, 0x02, 0x01, 0x01}), 0x5F2E, rec)
+		group = iso7816.AppendTLV(group, 0x7F60, instance)
+	}
+	dg, err := ParseDG2(iso7816.AppendTLV(nil, 0x75, iso7816.AppendTLV(nil, 0x7F61, group)))
+	if err != nil {
+		t.Fatalf("ParseDG2() error = %v", err)
+	}
+	if len(dg.Faces) != 2 || len(dg.Images) != 2 {
+		t.Fatalf("ParseDG2() = %d faces, %d images", len(dg.Faces), len(dg.Images))
+	}
+	tests := []struct {
+		mime          string
+		width, height int
+		data          []byte
+	}{
+		{"image/jpeg", 480, 640, jpeg},
+		{"image/jp2", 240, 320, jp2},
+	}
+	for i, tt := range tests {
+		img := dg.Images[i]
+		if img.MIMEType != tt.mime || img.Width != tt.width || img.Height != tt.height || img.Gender != 2 || !bytes.Equal(img.Data, tt.data) {
+			t.Errorf("image %d = %s %dx%d gender %d, %d bytes", i, img.MIMEType, img.Width, img.Height, img.Gender, len(img.Data))
+		}
+	}
+
+	rec := facialRecord(jpeg, 1, 1)
+	for _, bad := range [][]byte{rec[:30], append([]byte("FAX"), rec[3:]...)} {
+		if _, err := ParseFacialRecord(bad); err == nil {
+			t.Errorf("ParseFacialRecord(%X) succeeded", bad)
+		}
+	}
+}
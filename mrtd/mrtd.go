@@ -0,0 +1,255 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package mrtd reads electronic machine readable travel documents (ICAO Doc
+// 9303), such as passports and identity cards: it opens the eMRTD
+// application with PACE or Basic Access Control using keys derived from the
+// machine readable zone or the card access number, reads and parses the
+// files of the logical data structure over secure messaging, and verifies
+// them against the document security object signed by the issuing state.
+package mrtd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// AID is the application identifier of the eMRTD application.
+var AID = []byte{0xA0, 0x00, 0x00, 0x02, 0x47, 0x10, 0x01}
+
+var (
+	// ErrAuthentication is returned when access control fails, e.g. for
+	// a wrong document number or dates.
+	ErrAuthentication = errors.New("mrtd: authentication failed")
+	// ErrUnsupported is returned for access control protocols and domain
+	// parameters which are not implemented.
+	ErrUnsupported = errors.New("mrtd: unsupported protocol")
+)
+
+// File identifiers of the logical data structure.
+const (
+	FIDCardAccess = 0x011C
+	FIDCOM        = 0x011E
+	FIDSOD        = 0x011D
+	FIDDG1        = 0x0101
+	FIDDG2        = 0x0102
+)
+
+// FIDDataGroup returns the file identifier of data group n, 1 to 16.
+func FIDDataGroup(n int) uint16 { return 0x0100 + uint16(n) }
+
+// maxRead is the most bytes read by one READ BINARY, so the protected
+// response fits a short APDU.
+const maxRead = 0xDF
+
+// Document is an opened eMRTD application.
+type Document struct {
+	tr apdu.Transceiver
+	// Protocol is the access control protocol which opened the document,
+	// "PACE" or "BAC".
+	Protocol string
+}
+
+// Open opens the eMRTD application on the card behind tr with key. It
+// performs PACE when EF.CardAccess lists a supported protocol, and Basic
+// Access Control otherwise, which requires an MRZKey.
+func Open(tr apdu.Transceiver, key Key) (*Document, error) {
+	infos, err := readCardAccess(tr)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		if !info.Supported() {
+			continue
+		}
+		sm, err := PACE(tr, key, info)
+		if err != nil {
+			return nil, err
+		}
+		d := newDocument(tr, sm, "PACE")
+		if _, err := iso7816.Select(d.tr, iso7816.SelectByName, iso7816.ReturnNone, AID); err != nil {
+			return nil, fmt.Errorf("mrtd: select application: %w", err)
+		}
+		return d, nil
+	}
+
+	mrz, ok := key.(MRZKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: basic access control requires an MRZ key", ErrUnsupported)
+	}
+	if _, err := iso7816.Select(tr, iso7816.SelectByName, iso7816.ReturnNone, AID); err != nil {
+		return nil, fmt.Errorf("mrtd: select application: %w", err)
+	}
+	sm, err := BAC(tr, mrz)
+	if err != nil {
+		return nil, err
+	}
+	return newDocument(tr, sm, "BAC"), nil
+}
+
+// newDocument returns a document reading over sm. Responses announced with
+// 61xx are collected below secure messaging, which protects the collected
+// response as a whole.
+func newDocument(tr apdu.Transceiver, sm *iso7816.SecureMessaging, protocol string) *Document {
+	return &Document{tr: apdu.Wrap(tr, sm.Middleware(), apdu.GetResponse()), Protocol: protocol}
+}
+
+// readCardAccess reads the PACE protocols of EF.CardAccess, none when the
+// document has no such file.
+func readCardAccess(tr apdu.Transceiver) ([]PACEInfo, error) {
+	var se *apdu.StatusError
+	err := iso7816.SelectFID(tr, FIDCardAccess)
+	if errors.As(err, &se) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mrtd: select card access: %w", err)
+	}
+	b, err := iso7816.Binary{Transceiver: tr}.ReadAll()
+	if errors.As(err, &se) || (err == nil && len(b) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mrtd: read card access: %w", err)
+	}
+	return ParseCardAccess(b)
+}
+
+// Transceiver returns the transceiver of the document, protecting commands
+// with the secure messaging of its access control.
+func (d *Document) Transceiver() apdu.Transceiver { return d.tr }
+
+// ReadFile selects the elementary file fid of the application and reads
+// it, its size taken from the length of its outer data object.
+func (d *Document) ReadFile(fid uint16) ([]byte, error) {
+	if _, err := iso7816.Select(d.tr, iso7816.SelectChildEF, iso7816.ReturnNone, []byte{byte(fid >> 8), byte(fid)}); err != nil {
+		return nil, fmt.Errorf("mrtd: select file %04X: %w", fid, err)
+	}
+	bin := iso7816.Binary{Transceiver: d.tr, MaxRead: maxRead}
+	head, err := bin.Read(0, 4)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("mrtd: read file %04X: %w", fid, err)
+	}
+	size, err := objectSize(head)
+	if err != nil {
+		return nil, fmt.Errorf("mrtd: file %04X: %w", fid, err)
+	}
+	if size <= len(head) {
+		return head[:size], nil
+	}
+	// Offsets up to 7FFF are read with READ BINARY, further ones with the
+	// odd instruction addressing the offset in a data object.
+	out := head
+	if n := min(size, 0x8000); n > len(out) {
+		var rest []byte
+		rest, err = bin.Read(len(out), n-len(out))
+		out = append(out, rest...)
+	}
+	for err == nil && len(out) < size {
+		var chunk []byte
+		chunk, err = d.readOdd(len(out), min(size-len(out), maxRead-8))
+		if err == nil && len(chunk) == 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		out = append(out, chunk...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mrtd: read file %04X: %w", fid, err)
+	}
+	return out, nil
+}
+
+// readOdd reads n bytes at offset with READ BINARY B1.
+func (d *Document) readOdd(offset, n int) ([]byte, error) {
+	off := []byte{byte(offset >> 16), byte(offset >> 8), byte(offset)}
+	cmd := iso7816.NewCommandAPDU(0x00, 0xB1, 0x00, 0x00, 0, iso7816.AppendTLV(nil, 0x54, off))
+	cmd.Ne = n + 4
+	resp, err := transmit(d.tr, cmd)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := iso7816.FindTLV(resp, 0x53)
+	if !ok {
+		return nil, fmt.Errorf("missing discretionary data object")
+	}
+	return data, nil
+}
+
+// objectSize returns the total size of the BER-TLV data object whose start
+// is in head.
+func objectSize(head []byte) (int, error) {
+	if len(head) < 2 {
+		return 0, fmt.Errorf("truncated data object")
+	}
+	l, hdr := int(head[1]), 2
+	if l&0x80 != 0 {
+		n := l & 0x7F
+		if n == 0 || n > 2 || len(head) < 2+n {
+			return 0, fmt.Errorf("invalid data object length")
+		}
+		l = 0
+		for _, c := range head[2 : 2+n] {
+			l = l<<8 | int(c)
+		}
+		hdr += n
+	}
+	return hdr + l, nil
+}
+
+// COM reads and parses EF.COM.
+func (d *Document) COM() (*COM, error) {
+	b, err := d.ReadFile(FIDCOM)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCOM(b)
+}
+
+// DG1 reads and parses data group 1, the machine readable zone.
+func (d *Document) DG1() (*DG1, error) {
+	b, err := d.ReadFile(FIDDG1)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDG1(b)
+}
+
+// DG2 reads and parses data group 2, the encoded faces.
+func (d *Document) DG2() (*DG2, error) {
+	b, err := d.ReadFile(FIDDG2)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDG2(b)
+}
+
+// SOD reads and parses EF.SOD, the document security object.
+func (d *Document) SOD() (*SOD, error) {
+	b, err := d.ReadFile(FIDSOD)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSOD(b)
+}
+
+// transmit sends cmd, collecting responses announced by 61xx, and returns
+// the response data.
+func transmit(tr apdu.Transceiver, cmd *iso7816.CommandAPDU) ([]byte, error) {
+	raw, err := cmd.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := apdu.Wrap(tr, apdu.GetResponse()).Transmit(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		return nil, err
+	}
+	return resp[:len(resp)-2], nil
+}
@@ -0,0 +1,379 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package mrtd
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/crypto"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// chip emulates an eMRTD performing PACE with generic mapping over AES
+// secure messaging, then serving its files.
+type chip struct {
+	t        *testing.T
+	info     PACEInfo
+	password Key
+	files    map[uint16][]byte
+
+	selected []byte
+	cmds     int
+
+	// PACE state.
+	curve *curve
+	nonce []byte
+	skMap *big.Int
+	g     point
+	sk    *big.Int
+	pk    []byte
+	pkPCD []byte
+
+	// Secure messaging state, set once PACE succeeded.
+	kenc, kmac cipher.Block
+	ssc        []byte
+}
+
+func newChip(t *testing.T, info PACEInfo, password Key, files map[uint16][]byte) *chip {
+	ca, err := asn1.MarshalWithParams([]struct {
+		Protocol asn1.ObjectIdentifier
+		Version  int
+		Params   int
+	}{{info.Protocol, info.Version, info.ParameterID}}, "set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files[FIDCardAccess] = ca
+	return &chip{t: t, info: info, password: password, files: files}
+}
+
+func (c *chip) Transmit(raw []byte) ([]byte, error) {
+	c.cmds++
+	if c.ssc == nil {
+		cmd, err := iso7816.UnmarshalCommandAPDU(raw)
+		if err != nil {
+			return nil, err
+		}
+		data, sw := c.process(cmd)
+		return append(data, byte(sw>>8), byte(sw)), nil
+	}
+	cmd, err := c.unprotect(raw)
+	if err != nil {
+		c.t.Errorf("chip: %v", err)
+		c.ssc = nil
+		return []byte{0x69, 0x88}, nil
+	}
+	data, sw := c.process(cmd)
+	return c.protect(data, sw), nil
+}
+
+func (c *chip) process(cmd *iso7816.CommandAPDU) ([]byte, uint16) {
+	switch cmd.Ins {
+	case 0xA4:
+		if cmd.P1 == iso7816.SelectByName {
+			return nil, 0x9000
+		}
+		if f, ok := c.files[uint16(cmd.Data[0])<<8|uint16(cmd.Data[1])]; ok {
+			c.selected = f
+			return nil, 0x9000
+		}
+		return nil, 0x6A82
+	case 0xB0, 0xB1:
+		off := int(cmd.P1)<<8 | int(cmd.P2)
+		if cmd.Ins == 0xB1 {
+			o, _ := iso7816.FindTLV(cmd.Data, 0x54)
+			off = int(new(big.Int).SetBytes(o).Int64())
+		}
+		if off >= len(c.selected) {
+			return nil, 0x6B00
+		}
+		n := cmd.Ne
+		if cmd.Ins == 0xB1 {
+			n -= 4
+		}
+		data := c.selected[off:min(off+n, len(c.selected))]
+		if cmd.Ins == 0xB1 {
+			data = iso7816.AppendTLV(nil, 0x53, data)
+		}
+		return data, 0x9000
+	case 0x22:
+		return nil, 0x9000
+	case 0x86:
+		return c.pace(cmd.Data)
+	}
+	return nil, 0x6D00
+}
+
+// pace answers the steps of GENERAL AUTHENTICATE.
+func (c *chip) pace(data []byte) ([]byte, uint16) {
+	keyLen, _ := c.info.keyLen()
+	c.curve, _ = standardizedCurve(c.info.ParameterID)
+	pi, _, _ := c.password.password()
+	oid, _ := asn1.Marshal(c.info.Protocol)
+	oid = oid[2:]
+	respond := func(tag iso7816.Tag, v []byte) ([]byte, uint16) {
+		return iso7816.AppendTLV(nil, 0x7C, iso7816.AppendTLV(nil, tag, v)), 0x9000
+	}
+
+	if v, ok := iso7816.FindTLV(data, 0x81); ok {
+		pkMap, err := c.curve.unmarshal(v)
+		if err != nil {
+			return nil, 0x6A80
+		}
+		c.skMap, _ = c.curve.generateKey(rand.Reader)
+		h := c.curve.mul(pkMap, c.skMap)
+		c.g = c.curve.add(c.curve.mul(c.curve.generator(), new(big.Int).SetBytes(c.nonce)), h)
+		return respond(0x82, c.curve.marshal(c.curve.mul(c.curve.generator(), c.skMap)))
+	}
+	if v, ok := iso7816.FindTLV(data, 0x83); ok {
+		c.pkPCD = v
+		c.sk, _ = c.curve.generateKey(rand.Reader)
+		c.pk = c.curve.marshal(c.curve.mul(c.g, c.sk))
+		return respond(0x84, c.pk)
+	}
+	if v, ok := iso7816.FindTLV(data, 0x85); ok {
+		pkPCD, _ := c.curve.unmarshal(c.pkPCD)
+		k := make([]byte, c.curve.size())
+		c.curve.mul(pkPCD, c.sk).x.FillBytes(k)
+		kenc, kmac := kdf(k, kdfEnc, keyLen, false), kdf(k, kdfMAC, keyLen, false)
+		if want, _ := authToken(kmac, oid, c.pk); !bytes.Equal(v, want) {
+			return nil, 0x6300
+		}
+		token, _ := authToken(kmac, oid, c.pkPCD)
+		c.kenc, _ = aes.NewCipher(kenc)
+		c.kmac, _ = aes.NewCipher(kmac)
+		c.ssc = make([]byte, 16)
+		return respond(0x86, token)
+	}
+	c.nonce = make([]byte, 16)
+	rand.Read(c.nonce)
+	kpi, _ := aes.NewCipher(kdf(pi, kdfPI, keyLen, false))
+	z := make([]byte, 16)
+	cipher.NewCBCEncrypter(kpi, make([]byte, 16)).CryptBlocks(z, c.nonce)
+	return respond(0x80, z)
+}
+
+func (c *chip) incrementSSC() {
+	for i := len(c.ssc) - 1; i >= 0; i-- {
+		if c.ssc[i]++; c.ssc[i] != 0 {
+			return
+		}
+	}
+}
+
+func (c *chip) iv() []byte {
+	iv := make([]byte, 16)
+	c.kenc.Encrypt(iv, c.ssc)
+	return iv
+}
+
+func (c *chip) mac(data []byte) []byte {
+	return crypto.CMAC(c.kmac, crypto.Pad(append(append([]byte(nil), c.ssc...), data...), 16))[:8]
+}
+
+// unprotect verifies and decrypts a protected command.
+func (c *chip) unprotect(raw []byte) (*iso7816.CommandAPDU, error) {
+	c.incrementSSC()
+	pcmd, err := iso7816.UnmarshalCommandAPDU(raw)
+	if err != nil {
+		return nil, err
+	}
+	cmd := &iso7816.CommandAPDU{Cla: pcmd.Cla &^ 0x0C, Ins: pcmd.Ins, P1: pcmd.P1, P2: pcmd.P2}
+	macInput := crypto.Pad(raw[:4], 16)
+	tlvs, err := iso7816.ParseTLV(pcmd.Data)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tlvs {
+		switch t.Tag {
+		case 0x85, 0x87:
+			ct := t.Value
+			if t.Tag == 0x87 {
+				ct = ct[1:]
+			}
+			buf := make([]byte, len(ct))
+			cipher.NewCBCDecrypter(c.kenc, c.iv()).CryptBlocks(buf, ct)
+			if cmd.Data, err = crypto.Unpad(buf); err != nil {
+				return nil, err
+			}
+		case 0x97:
+			cmd.Ne = int(t.Value[0])
+			if cmd.Ne == 0 {
+				cmd.Ne = 256
+			}
+		case 0x8E:
+			if !bytes.Equal(t.Value, c.mac(macInput)) {
+				return nil, errors.New("command checksum mismatch")
+			}
+			continue
+		}
+		macInput = iso7816.AppendTLV(macInput, t.Tag, t.Value)
+	}
+	return cmd, nil
+}
+
+// protect returns the protected response of data and sw.
+func (c *chip) protect(data []byte, sw uint16) []byte {
+	c.incrementSSC()
+	var dos []byte
+	if len(data) > 0 {
+		padded := crypto.Pad(data, 16)
+		cipher.NewCBCEncrypter(c.kenc, c.iv()).CryptBlocks(padded, padded)
+		dos = iso7816.AppendTLV(dos, 0x87, append([]byte{0x01}, padded...))
+	}
+	dos = iso7816.AppendTLV(dos, 0x99, []byte{byte(sw >> 8), byte(sw)})
+	dos = iso7816.AppendTLV(dos, 0x8E, c.mac(dos))
+	return append(dos, 0x90, 0x00)
+}
+
+var (
+	paceBrainpool = PACEInfo{Protocol: asn1.ObjectIdentifier{0, 4, 0, 127, 0, 7, 2, 2, 4, 2, 2}, Version: 2, ParameterID: 13}
+	paceP256      = PACEInfo{Protocol: asn1.ObjectIdentifier{0, 4, 0, 127, 0, 7, 2, 2, 4, 2, 4}, Version: 2, ParameterID: 12}
+)
+
+func testFiles() map[uint16][]byte {
+	mrz := "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<L898902C36UTO7408122F1204159ZE184226B<<<<<10"
+	img := make([]byte, 0x8100)
+	for i := range img {
+		img[i] = byte(i)
+	}
+	img[0], img[1] = 0xFF, 0xD8
+	instance := iso7816.AppendTLV(iso7816.AppendTLV(nil, 0xA1, []byte{0x80, 0x02, 0x01, 0x01}), 0x5F2E, facialRecord(img, 480, 640))
+	group := iso7816.AppendTLV(iso7816.AppendTLV(nil, 0x02, []byte{0x01}), 0x7F60, instance)
+	return map[uint16][]byte{
+		FIDCOM: iso7816.AppendTLV(nil, 0x60, append(append(
+			iso7816.AppendTLV(nil, 0x5F01, []byte("0107")),
+			iso7816.AppendTLV(nil, 0x5F36, []byte("040000"))...),
+			iso7816.AppendTLV(nil, 0x5C, []byte{0x61, 0x75, 0x6E})...)),
+		FIDDG1: iso7816.AppendTLV(nil, 0x61, iso7816.AppendTLV(nil, 0x5F1F, []byte(mrz))),
+		FIDDG2: iso7816.AppendTLV(nil, 0x75, iso7816.AppendTLV(nil, 0x7F61, group)),
+	}
+}
+
+func TestOpenPACE(t *testing.T) {
+	tests := []struct {
+		name  string
+		info  PACEInfo
+		chip  Key
+		key   Key
+		err   error
+		files bool
+	}{
+		{"brainpool can", paceBrainpool, CAN("123456"), CAN("123456"), nil, true},
+		{"p256 mrz", paceP256, specimen, specimen, nil, false},
+		{"wrong can", paceBrainpool, CAN("123456"), CAN("654321"), ErrAuthentication, false},
+	}
+	for _, tt := range tests {
+		c := newChip(t, tt.info, tt.chip, testFiles())
+		doc, err := Open(c, tt.key)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: Open() error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if doc.Protocol != "PACE" {
+			t.Errorf("%s: Protocol = %s", tt.name, doc.Protocol)
+		}
+		if !tt.files {
+			continue
+		}
+
+		com, err := doc.COM()
+		if err != nil || com.LDSVersion != "0107" || com.UnicodeVersion != "040000" || len(com.DataGroups) != 3 || com.DataGroups[2] != 14 {
+			t.Errorf("%s: COM() = %+v, %v", tt.name, com, err)
+		}
+		dg1, err := doc.DG1()
+		if err != nil || dg1.Fields.PrimaryIdentifier != "ERIKSSON" {
+			t.Errorf("%s: DG1() = %+v, %v", tt.name, dg1, err)
+		}
+		dg2, err := doc.DG2()
+		if err != nil || len(dg2.Images) != 1 || len(dg2.Images[0].Data) != 0x8100 || dg2.Images[0].Data[0x80FF] != 0xFF {
+			t.Errorf("%s: DG2() = %+v, %v", tt.name, dg2, err)
+		}
+		var se *apdu.StatusError
+		if _, err := doc.ReadFile(FIDDataGroup(3)); !errors.As(err, &se) || se.SW1 != 0x6A {
+			t.Errorf("%s: ReadFile(DG3) error = %v", tt.name, err)
+		}
+	}
+}
+
+func TestOpenBAC(t *testing.T) {
+	var cmds [][]byte
+	tr := apdu.TransceiverFunc(func(cmd []byte) ([]byte, error) {
+		cmds = append(cmds, cmd)
+		if cmd[1] == 0xA4 && cmd[2] == 0x00 {
+			return []byte{0x6A, 0x82}, nil
+		}
+		if cmd[1] == 0x84 {
+			return []byte{0x69, 0x85}, nil
+		}
+		return []byte{0x90, 0x00}, nil
+	})
+	if _, err := Open(tr, CAN("123456")); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Open() with CAN error = %v, want %v", err, ErrUnsupported)
+	}
+	cmds = nil
+	if _, err := Open(tr, specimen); err == nil {
+		t.Fatal("Open() succeeded without BAC")
+	}
+	if len(cmds) != 3 || !bytes.Equal(cmds[1], unhex("00A4040C07A0000002471001")) || cmds[2][1] != 0x84 {
+		t.Errorf("Open() sent %X", cmds)
+	}
+}
+
+func TestCurves(t *testing.T) {
+	for _, id := range []int{12, 13, 15, 16, 18} {
+		c, err := standardizedCurve(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := c.generator()
+		if !c.onCurve(g) {
+			t.Errorf("curve %d: generator not on curve", id)
+		}
+		if c.mul(g, c.n).x != nil {
+			t.Errorf("curve %d: n·G is not the point at infinity", id)
+		}
+		k := big.NewInt(12345)
+		q := c.mul(g, k)
+		if r := c.mul(g, new(big.Int).Add(k, big.NewInt(1))); !c.onCurve(q) || c.add(q, g).x.Cmp(r.x) != 0 {
+			t.Errorf("curve %d: inconsistent addition", id)
+		}
+		if _, err := c.unmarshal(c.marshal(q)); err != nil {
+			t.Errorf("curve %d: unmarshal() error = %v", id, err)
+		}
+	}
+	if _, err := standardizedCurve(14); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("standardizedCurve(14) error = %v", err)
+	}
+}
+
+func TestParseCardAccess(t *testing.T) {
+	ca := unhex("31143012060A04007F0007020204020202010202010D") // PACE ECDH-GM AES-128, brainpoolP256r1
+	infos, err := ParseCardAccess(ca)
+	if err != nil || len(infos) != 1 || !infos[0].Protocol.Equal(paceBrainpool.Protocol) || infos[0].Version != 2 || infos[0].ParameterID != 13 {
+		t.Fatalf("ParseCardAccess() = %+v, %v", infos, err)
+	}
+	if !infos[0].Supported() {
+		t.Error("Supported() = false")
+	}
+	dh := PACEInfo{Protocol: asn1.ObjectIdentifier{0, 4, 0, 127, 0, 7, 2, 2, 4, 1, 2}, Version: 2, ParameterID: 0}
+	if dh.Supported() {
+		t.Error("Supported() = true for DH generic mapping")
+	}
+	if _, err := ParseCardAccess([]byte{0x31, 0x05}); err == nil {
+		t.Error("ParseCardAccess() accepted truncated data")
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package mrtd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MRZ is the data of a machine readable zone.
+type MRZ struct {
+	// DocumentCode is the document type, e.g. "P" for passports or "ID".
+	DocumentCode string
+	// IssuingState is the three letter code of the issuing state.
+	IssuingState string
+	// PrimaryIdentifier is the surname of the holder.
+	PrimaryIdentifier string
+	// SecondaryIdentifier are the given names of the holder, separated by
+	// spaces.
+	SecondaryIdentifier string
+	DocumentNumber      string
+	// Nationality is the three letter code of the nationality.
+	Nationality string
+	// DateOfBirth and DateOfExpiry are given as YYMMDD.
+	DateOfBirth  string
+	DateOfExpiry string
+	// Sex is "M", "F" or "X" when unspecified.
+	Sex string
+	// OptionalData holds the optional data elements, e.g. the personal
+	// number of passports, without fillers.
+	OptionalData string
+}
+
+// Key returns the access key of the document of m.
+func (m *MRZ) Key() MRZKey {
+	return MRZKey{DocumentNumber: m.DocumentNumber, DateOfBirth: m.DateOfBirth, DateOfExpiry: m.DateOfExpiry}
+}
+
+// ParseMRZ parses a machine readable zone, its lines concatenated, of TD1
+// (3×30 characters), TD2 (2×36) or TD3 (2×44) size documents and checks
+// its check digits.
+func ParseMRZ(s string) (*MRZ, error) {
+	m := &MRZ{}
+	var err error
+	switch len(s) {
+	case 90:
+		err = m.parseTD1(s)
+	case 72:
+		err = m.parseTD23(s[:36], s[36:])
+	case 88:
+		err = m.parseTD23(s[:44], s[44:])
+	default:
+		return nil, fmt.Errorf("mrtd: MRZ of %d characters", len(s))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mrtd: MRZ: %w", err)
+	}
+	return m, nil
+}
+
+// parseTD1 parses the three lines of a TD1 machine readable zone.
+func (m *MRZ) parseTD1(s string) error {
+	l1, l2, l3 := s[:30], s[30:60], s[60:]
+	m.DocumentCode, m.IssuingState = field(l1[:2]), l1[2:5]
+	num, cd, opt := l1[5:14], l1[14:15], l1[15:30]
+	if cd == "<" {
+		// Document numbers of more than 9 characters continue in the
+		// optional data, followed by their check digit.
+		end := strings.IndexByte(opt, '<')
+		if end < 1 {
+			return fmt.Errorf("truncated long document number")
+		}
+		num, cd, opt = num+opt[:end-1], opt[end-1:end], opt[end:]
+	}
+	m.DocumentNumber = field(num)
+	m.DateOfBirth, m.Sex, m.DateOfExpiry, m.Nationality = l2[:6], sex(l2[7]), l2[8:14], field(l2[15:18])
+	m.OptionalData = strings.TrimSpace(field(opt) + " " + field(l2[18:29]))
+	m.PrimaryIdentifier, m.SecondaryIdentifier = names(l3)
+	return checkDigits([][2]string{
+		{num, cd},
+		{l2[:6], l2[6:7]},
+		{l2[8:14], l2[14:15]},
+		{l1[5:30] + l2[:7] + l2[8:15] + l2[18:29], l2[29:30]},
+	})
+}
+
+// parseTD23 parses the two lines of a TD2 or TD3 machine readable zone,
+// which differ in the length of the name and optional data.
+func (m *MRZ) parseTD23(l1, l2 string) error {
+	n := len(l2)
+	m.DocumentCode, m.IssuingState = field(l1[:2]), l1[2:5]
+	m.PrimaryIdentifier, m.SecondaryIdentifier = names(l1[5:])
+	m.DocumentNumber, m.Nationality = field(l2[:9]), field(l2[10:13])
+	m.DateOfBirth, m.Sex, m.DateOfExpiry = l2[13:19], sex(l2[20]), l2[21:27]
+	checks := [][2]string{
+		{l2[:9], l2[9:10]},
+		{l2[13:19], l2[19:20]},
+		{l2[21:27], l2[27:28]},
+		{l2[:10] + l2[13:20] + l2[21:n-1], l2[n-1:]},
+	}
+	if n == 44 {
+		// TD3 optional data has a check digit of its own, which may be a
+		// filler when the data is empty.
+		m.OptionalData = field(l2[28:42])
+		if l2[42] != '<' || m.OptionalData != "" {
+			checks = append(checks, [2]string{l2[28:42], l2[42:43]})
+		}
+	} else {
+		m.OptionalData = field(l2[28:35])
+	}
+	return checkDigits(checks)
+}
+
+// checkDigits verifies the check digits of pairs of data and check digit.
+func checkDigits(checks [][2]string) error {
+	for _, c := range checks {
+		d, err := CheckDigit(c[0])
+		if err != nil {
+			return err
+		}
+		if c[1] != string(rune('0'+d)) {
+			return fmt.Errorf("check digit %s of %s does not match %d", c[1], c[0], d)
+		}
+	}
+	return nil
+}
+
+// field returns the data of an MRZ field without trailing fillers.
+func field(s string) string { return strings.TrimRight(s, "<") }
+
+// names splits the name field into the primary and secondary identifiers.
+func names(s string) (string, string) {
+	primary, secondary, _ := strings.Cut(field(s), "<<")
+	return strings.Join(strings.FieldsFunc(primary, isFiller), " "), strings.Join(strings.FieldsFunc(secondary, isFiller), " ")
+}
+
+func isFiller(r rune) bool { return r == '<' }
+
+func sex(c byte) string {
+	if c == '<' {
+		return "X"
+	}
+	return string(c)
+}
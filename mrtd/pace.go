@@ -0,0 +1,247 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package mrtd
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/crypto"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// oidPACE is id-PACE of BSI TR-03110 part 3; a PACE protocol appends the
+// key agreement and mapping, then the cipher, e.g. 2.2 for
+// id-PACE-ECDH-GM-AES-CBC-CMAC-128.
+var oidPACE = asn1.ObjectIdentifier{0, 4, 0, 127, 0, 7, 2, 2, 4}
+
+// PACE key agreement with generic mapping over elliptic curves.
+const paceECDHGM = 2
+
+// PACEInfo is a PACE protocol supported by a document, as listed in
+// EF.CardAccess.
+type PACEInfo struct {
+	Protocol asn1.ObjectIdentifier
+	Version  int
+	// ParameterID is the standardized domain parameter identifier, e.g. 13
+	// for brainpoolP256r1.
+	ParameterID int
+}
+
+// Supported reports whether PACE supports the protocol and domain
+// parameters of info: ECDH generic mapping with AES over the NIST P-256,
+// P-384 and P-521 curves and the Brainpool P256r1 and P384r1 curves.
+func (info PACEInfo) Supported() bool {
+	if _, err := info.keyLen(); err != nil {
+		return false
+	}
+	_, err := standardizedCurve(info.ParameterID)
+	return err == nil
+}
+
+// keyLen returns the AES key length of the protocol.
+func (info PACEInfo) keyLen() (int, error) {
+	p := info.Protocol
+	if len(p) == len(oidPACE)+2 && p[:len(oidPACE)].Equal(oidPACE) && p[len(oidPACE)] == paceECDHGM {
+		switch p[len(oidPACE)+1] {
+		case 2:
+			return 16, nil
+		case 3:
+			return 24, nil
+		case 4:
+			return 32, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: protocol %s", ErrUnsupported, p)
+}
+
+// securityInfo is the generic structure of the SecurityInfos of
+// EF.CardAccess.
+type securityInfo struct {
+	Protocol asn1.ObjectIdentifier
+	Required asn1.RawValue
+	Optional asn1.RawValue `asn1:"optional"`
+}
+
+// ParseCardAccess parses the SecurityInfos of EF.CardAccess and returns the
+// PACE protocols listed.
+func ParseCardAccess(b []byte) ([]PACEInfo, error) {
+	var infos []securityInfo
+	if _, err := asn1.UnmarshalWithParams(b, &infos, "set"); err != nil {
+		return nil, fmt.Errorf("mrtd: card access: %w", err)
+	}
+	var out []PACEInfo
+	for _, si := range infos {
+		p := si.Protocol
+		if len(p) != len(oidPACE)+2 || !p[:len(oidPACE)].Equal(oidPACE) {
+			continue
+		}
+		info := PACEInfo{Protocol: p}
+		if _, err := asn1.Unmarshal(si.Required.FullBytes, &info.Version); err != nil {
+			return nil, fmt.Errorf("mrtd: card access: %s version: %w", p, err)
+		}
+		if si.Optional.Tag == asn1.TagInteger {
+			if _, err := asn1.Unmarshal(si.Optional.FullBytes, &info.ParameterID); err != nil {
+				return nil, fmt.Errorf("mrtd: card access: %s parameters: %w", p, err)
+			}
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+// PACE performs Password Authenticated Connection Establishment with key
+// using the protocol info and returns the established secure messaging.
+func PACE(tr apdu.Transceiver, key Key, info PACEInfo) (*iso7816.SecureMessaging, error) {
+	return pace(tr, key, info, rand.Reader)
+}
+
+// pace performs PACE drawing the terminal keys from rnd.
+func pace(tr apdu.Transceiver, key Key, info PACEInfo, rnd io.Reader) (*iso7816.SecureMessaging, error) {
+	keyLen, err := info.keyLen()
+	if err != nil {
+		return nil, err
+	}
+	c, err := standardizedCurve(info.ParameterID)
+	if err != nil {
+		return nil, err
+	}
+	pi, ref, err := key.password()
+	if err != nil {
+		return nil, err
+	}
+	oid, err := asn1.Marshal(info.Protocol)
+	if err != nil {
+		return nil, err
+	}
+	oid = oid[2:]
+
+	setAT := iso7816.AppendTLV(iso7816.AppendTLV(nil, 0x80, oid), 0x83, []byte{ref})
+	if _, err := transmit(tr, iso7816.NewCommandAPDU(0x00, 0x22, 0xC1, 0xA4, 0, setAT)); err != nil {
+		return nil, fmt.Errorf("mrtd: pace set authentication template: %w", err)
+	}
+
+	// Decrypt the nonce with the password key.
+	z, err := generalAuthenticate(tr, false, 0x80, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(z) == 0 || len(z)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("mrtd: pace encrypted nonce of %d bytes", len(z))
+	}
+	kpi, err := aes.NewCipher(kdf(pi, kdfPI, keyLen, false))
+	if err != nil {
+		return nil, err
+	}
+	s := make([]byte, len(z))
+	cipher.NewCBCDecrypter(kpi, make([]byte, aes.BlockSize)).CryptBlocks(s, z)
+
+	// Map the nonce to a session generator G' = s·G + H.
+	skMap, err := c.generateKey(rnd)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := generalAuthenticate(tr, false, 0x82, 0x81, c.marshal(c.mul(c.generator(), skMap)))
+	if err != nil {
+		return nil, err
+	}
+	pkMapIC, err := c.unmarshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	h := c.mul(pkMapIC, skMap)
+	g := c.add(c.mul(c.generator(), new(big.Int).SetBytes(s)), h)
+	if g.x == nil {
+		return nil, fmt.Errorf("%w: mapped generator is the point at infinity", ErrAuthentication)
+	}
+
+	// Agree on the session key seed with ephemeral keys over G'.
+	sk, err := c.generateKey(rnd)
+	if err != nil {
+		return nil, err
+	}
+	pk := c.marshal(c.mul(g, sk))
+	resp, err = generalAuthenticate(tr, false, 0x84, 0x83, pk)
+	if err != nil {
+		return nil, err
+	}
+	pkIC, err := c.unmarshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(resp, pk) {
+		return nil, fmt.Errorf("%w: chip echoed the terminal key", ErrAuthentication)
+	}
+	shared := c.mul(pkIC, sk)
+	if shared.x == nil {
+		return nil, fmt.Errorf("%w: shared secret is the point at infinity", ErrAuthentication)
+	}
+	k := make([]byte, c.size())
+	shared.x.FillBytes(k)
+	kenc, kmac := kdf(k, kdfEnc, keyLen, false), kdf(k, kdfMAC, keyLen, false)
+
+	// Exchange authentication tokens over the other side's public key.
+	token, err := authToken(kmac, oid, resp)
+	if err != nil {
+		return nil, err
+	}
+	tIC, err := generalAuthenticate(tr, true, 0x86, 0x85, token)
+	if err != nil {
+		return nil, err
+	}
+	want, err := authToken(kmac, oid, pk)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(tIC, want) != 1 {
+		return nil, fmt.Errorf("%w: chip authentication token mismatch", ErrAuthentication)
+	}
+	return iso7816.NewSecureMessagingAES(kenc, kmac, make([]byte, aes.BlockSize))
+}
+
+// generalAuthenticate sends a step of GENERAL AUTHENTICATE with the data
+// object in of value in the dynamic authentication data, in none when zero,
+// and returns the value of the data object out of the response. Steps but
+// the last are chained.
+func generalAuthenticate(tr apdu.Transceiver, last bool, out, in iso7816.Tag, value []byte) ([]byte, error) {
+	var data []byte
+	if in != 0 {
+		data = iso7816.AppendTLV(nil, in, value)
+	}
+	cla := byte(0x10)
+	if last {
+		cla = 0x00
+	}
+	cmd := iso7816.NewCommandAPDU(cla, 0x86, 0x00, 0x00, 0, iso7816.AppendTLV(nil, 0x7C, data))
+	cmd.Ne = 256
+	resp, err := transmit(tr, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("%w: pace general authenticate: %w", ErrAuthentication, err)
+	}
+	v, ok := iso7816.FindTLV(resp, out)
+	if !ok {
+		return nil, fmt.Errorf("mrtd: pace general authenticate: missing data object %X", uint32(out))
+	}
+	return v, nil
+}
+
+// authToken computes the PACE authentication token over the public key pk
+// with the protocol object identifier oid.
+func authToken(kmac, oid, pk []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kmac)
+	if err != nil {
+		return nil, err
+	}
+	data := iso7816.AppendTLV(iso7816.AppendTLV(nil, 0x06, oid), 0x86, pk)
+	return crypto.CMAC(block, iso7816.AppendTLV(nil, 0x7F49, data))[:8], nil
+}
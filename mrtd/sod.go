@@ -0,0 +1,318 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package mrtd
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrVerification is returned when the document security object does not
+// verify: a data group hash, the signature or the signer certificate.
+var ErrVerification = errors.New("mrtd: verification failed")
+
+var (
+	oidSignedData     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidLDSSecurity    = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 1}
+	oidMessageDigest  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidRSAEncryption  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidRSAPSS         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+	oidECPublicKey    = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidECDSAPlain     = asn1.ObjectIdentifier{0, 4, 0, 127, 0, 7, 1, 1, 4, 1}
+	oidSignatureAlgos = map[string]x509.SignatureAlgorithm{
+		"1.2.840.113549.1.1.5":  x509.SHA1WithRSA,
+		"1.2.840.113549.1.1.11": x509.SHA256WithRSA,
+		"1.2.840.113549.1.1.12": x509.SHA384WithRSA,
+		"1.2.840.113549.1.1.13": x509.SHA512WithRSA,
+		"1.2.840.10045.4.1":     x509.ECDSAWithSHA1,
+		"1.2.840.10045.4.3.2":   x509.ECDSAWithSHA256,
+		"1.2.840.10045.4.3.3":   x509.ECDSAWithSHA384,
+		"1.2.840.10045.4.3.4":   x509.ECDSAWithSHA512,
+	}
+	oidHashes = map[string]crypto.Hash{
+		"1.3.14.3.2.26":          crypto.SHA1,
+		"2.16.840.1.101.3.4.2.4": crypto.SHA224,
+		"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+		"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+		"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+	}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type encapContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     []byte `asn1:"explicit,optional,tag:0"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type ldsSecurityObject struct {
+	Version         int
+	HashAlgorithm   pkix.AlgorithmIdentifier
+	DataGroupHashes []dataGroupHash
+	VersionInfo     asn1.RawValue `asn1:"optional"`
+}
+
+type dataGroupHash struct {
+	Number int
+	Hash   []byte
+}
+
+// SOD is the document security object, EF.SOD: the hashes of the data
+// groups signed by the document signer of the issuing state.
+type SOD struct {
+	// Hash is the hash function of the data group hashes.
+	Hash crypto.Hash
+	// Hashes are the data group hashes by data group number.
+	Hashes map[int][]byte
+	// Signer is the document signer certificate, nil when the security
+	// object does not include it.
+	Signer *x509.Certificate
+
+	content   []byte
+	signer    signerInfo
+	signedRaw []byte
+}
+
+// ParseSOD parses EF.SOD.
+func ParseSOD(b []byte) (*SOD, error) {
+	v, err := template(b, 0x77)
+	if err != nil {
+		return nil, fmt.Errorf("mrtd: EF.SOD: %w", err)
+	}
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(v, &ci); err != nil {
+		return nil, fmt.Errorf("mrtd: EF.SOD: %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("mrtd: EF.SOD: content type %s is not signed data", ci.ContentType)
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("mrtd: EF.SOD: %w", err)
+	}
+	if !sd.EncapContentInfo.ContentType.Equal(oidLDSSecurity) {
+		return nil, fmt.Errorf("mrtd: EF.SOD: content type %s is not an LDS security object", sd.EncapContentInfo.ContentType)
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("mrtd: EF.SOD: %d signer infos", len(sd.SignerInfos))
+	}
+	var lds ldsSecurityObject
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.Content, &lds); err != nil {
+		return nil, fmt.Errorf("mrtd: EF.SOD: security object: %w", err)
+	}
+	h, ok := oidHashes[lds.HashAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("%w: hash algorithm %s", ErrUnsupported, lds.HashAlgorithm.Algorithm)
+	}
+	sod := &SOD{
+		Hash:    h,
+		Hashes:  make(map[int][]byte, len(lds.DataGroupHashes)),
+		content: sd.EncapContentInfo.Content,
+		signer:  sd.SignerInfos[0],
+	}
+	for _, dg := range lds.DataGroupHashes {
+		sod.Hashes[dg.Number] = dg.Hash
+	}
+	if len(sd.Certificates.Bytes) > 0 {
+		certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("mrtd: EF.SOD: certificates: %w", err)
+		}
+		sod.Signer = signerCertificate(certs, sod.signer.SID)
+	}
+	if len(sod.signer.SignedAttrs.FullBytes) > 0 {
+		// Signed attributes are signed with their SET OF tag rather than
+		// the implicit context tag.
+		sod.signedRaw = append([]byte{0x31}, sod.signer.SignedAttrs.FullBytes[1:]...)
+	}
+	return sod, nil
+}
+
+// signerCertificate returns the certificate of certs identified by sid, an
+// issuer and serial number or a subject key identifier.
+func signerCertificate(certs []*x509.Certificate, sid asn1.RawValue) *x509.Certificate {
+	var ias issuerAndSerial
+	if sid.Tag == asn1.TagSequence {
+		if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+			return nil
+		}
+	}
+	for _, c := range certs {
+		switch {
+		case ias.Serial != nil:
+			if c.SerialNumber.Cmp(ias.Serial) == 0 && bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) {
+				return c
+			}
+		case sid.Class == asn1.ClassContextSpecific && sid.Tag == 0:
+			if bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// VerifyDataGroup checks data, the content of data group n, against its
+// hash in the security object.
+func (s *SOD) VerifyDataGroup(n int, data []byte) error {
+	want, ok := s.Hashes[n]
+	if !ok {
+		return fmt.Errorf("%w: no hash of DG%d", ErrVerification, n)
+	}
+	h := s.Hash.New()
+	h.Write(data)
+	if subtle.ConstantTimeCompare(h.Sum(nil), want) != 1 {
+		return fmt.Errorf("%w: hash of DG%d does not match", ErrVerification, n)
+	}
+	return nil
+}
+
+// Verify checks the signature of the security object with the document
+// signer certificate and the signer certificate against the country
+// signing CA certificates cscas. Certificate validity periods are not
+// checked, as documents outlive their signer certificates.
+func (s *SOD) Verify(cscas []*x509.Certificate) error {
+	if s.Signer == nil {
+		return fmt.Errorf("%w: no document signer certificate", ErrVerification)
+	}
+	if err := s.verifySignature(); err != nil {
+		return err
+	}
+	for _, ca := range cscas {
+		if !bytes.Equal(s.Signer.RawIssuer, ca.RawSubject) {
+			continue
+		}
+		if err := s.Signer.CheckSignatureFrom(ca); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: document signer %q not issued by a trusted CSCA", ErrVerification, s.Signer.Subject)
+}
+
+// verifySignature checks the signature of the signer info over the
+// security object, or over its signed attributes which then must hold the
+// digest of the security object.
+func (s *SOD) verifySignature() error {
+	h, ok := oidHashes[s.signer.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return fmt.Errorf("%w: digest algorithm %s", ErrUnsupported, s.signer.DigestAlgorithm.Algorithm)
+	}
+	signed := s.content
+	if s.signedRaw != nil {
+		var attrs []attribute
+		if _, err := asn1.UnmarshalWithParams(s.signedRaw, &attrs, "set"); err != nil {
+			return fmt.Errorf("mrtd: EF.SOD: signed attributes: %w", err)
+		}
+		digest := h.New()
+		digest.Write(s.content)
+		var found bool
+		for _, a := range attrs {
+			if !a.Type.Equal(oidMessageDigest) {
+				continue
+			}
+			var md []byte
+			if _, err := asn1.Unmarshal(a.Values.Bytes, &md); err != nil {
+				return fmt.Errorf("mrtd: EF.SOD: message digest: %w", err)
+			}
+			if subtle.ConstantTimeCompare(md, digest.Sum(nil)) != 1 {
+				return fmt.Errorf("%w: message digest does not match the security object", ErrVerification)
+			}
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("%w: signed attributes without message digest", ErrVerification)
+		}
+		signed = s.signedRaw
+	}
+	alg, sig, err := signatureAlgorithm(s.signer.SignatureAlgorithm.Algorithm, h, s.signer.Signature)
+	if err != nil {
+		return err
+	}
+	if err := s.Signer.CheckSignature(alg, signed, sig); err != nil {
+		return fmt.Errorf("%w: %w", ErrVerification, err)
+	}
+	return nil
+}
+
+// signatureAlgorithm maps the signature algorithm oid of a signer info
+// with digest h to its x509 algorithm, converting plain ECDSA signatures
+// to DER.
+func signatureAlgorithm(oid asn1.ObjectIdentifier, h crypto.Hash, sig []byte) (x509.SignatureAlgorithm, []byte, error) {
+	if alg, ok := oidSignatureAlgos[oid.String()]; ok {
+		return alg, sig, nil
+	}
+	algs := map[crypto.Hash][3]x509.SignatureAlgorithm{
+		crypto.SHA1:   {x509.SHA1WithRSA, x509.UnknownSignatureAlgorithm, x509.ECDSAWithSHA1},
+		crypto.SHA256: {x509.SHA256WithRSA, x509.SHA256WithRSAPSS, x509.ECDSAWithSHA256},
+		crypto.SHA384: {x509.SHA384WithRSA, x509.SHA384WithRSAPSS, x509.ECDSAWithSHA384},
+		crypto.SHA512: {x509.SHA512WithRSA, x509.SHA512WithRSAPSS, x509.ECDSAWithSHA512},
+	}[h]
+	var alg x509.SignatureAlgorithm
+	switch {
+	case oid.Equal(oidRSAEncryption):
+		alg = algs[0]
+	case oid.Equal(oidRSAPSS):
+		alg = algs[1]
+	case oid.Equal(oidECPublicKey):
+		alg = algs[2]
+	case len(oid) == len(oidECDSAPlain)+1 && oid[:len(oidECDSAPlain)].Equal(oidECDSAPlain):
+		alg = algs[2]
+		if len(sig) == 0 || len(sig)%2 != 0 {
+			return 0, nil, fmt.Errorf("%w: plain ECDSA signature of %d bytes", ErrVerification, len(sig))
+		}
+		r, s := new(big.Int).SetBytes(sig[:len(sig)/2]), new(big.Int).SetBytes(sig[len(sig)/2:])
+		der, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+		if err != nil {
+			return 0, nil, err
+		}
+		sig = der
+	}
+	if alg == x509.UnknownSignatureAlgorithm {
+		return 0, nil, fmt.Errorf("%w: signature algorithm %s with %s", ErrUnsupported, oid, h)
+	}
+	return alg, sig, nil
+}
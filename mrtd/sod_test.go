@@ -0,0 +1,191 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package mrtd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+var oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+
+// newCert issues a certificate for key, self-signed when parent is nil.
+func newCert(t *testing.T, name string, key crypto.Signer, parent *x509.Certificate, parentKey crypto.Signer) *x509.Certificate {
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(int64(len(name))),
+		Subject:               pkix.Name{Country: []string{"UT"}, CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		tmpl.IsCA, tmpl.KeyUsage = true, x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// sodOptions shape the security object built by buildSOD.
+type sodOptions struct {
+	sigAlg     asn1.ObjectIdentifier
+	plain      bool // ECDSA signature as r || s
+	noAttrs    bool // sign the content rather than signed attributes
+	tamperHash bool // change a data group hash after signing
+}
+
+// buildSOD returns EF.SOD over the data groups dgs signed by ds with key.
+func buildSOD(t *testing.T, ds *x509.Certificate, key crypto.Signer, dgs map[int][]byte, opts sodOptions) []byte {
+	lds := ldsSecurityObject{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256}}
+	for n := 1; n <= len(dgs); n++ {
+		h := sha256.Sum256(dgs[n])
+		lds.DataGroupHashes = append(lds.DataGroupHashes, dataGroupHash{Number: n, Hash: h[:]})
+	}
+	content, err := asn1.Marshal(lds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := content
+	var attrsRaw asn1.RawValue
+	if !opts.noAttrs {
+		md := sha256.Sum256(content)
+		mdDER, _ := asn1.Marshal(md[:])
+		ctDER, _ := asn1.Marshal(oidLDSSecurity)
+		attrs := []attribute{
+			{Type: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}, Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: ctDER}},
+			{Type: oidMessageDigest, Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: mdDER}},
+		}
+		if signed, err = asn1.MarshalWithParams(attrs, "set"); err != nil {
+			t.Fatal(err)
+		}
+		attrsRaw = asn1.RawValue{FullBytes: append([]byte{0xA0}, signed[1:]...)}
+	}
+	digest := sha256.Sum256(signed)
+	sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.plain {
+		var rs struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sig, &rs); err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		rs.R.FillBytes(sig[:32])
+		rs.S.FillBytes(sig[32:])
+	}
+	if opts.tamperHash {
+		lds.DataGroupHashes[0].Hash[0] ^= 0xFF
+		content, _ = asn1.Marshal(lds)
+	}
+
+	sid, _ := asn1.Marshal(issuerAndSerial{Issuer: asn1.RawValue{FullBytes: ds.RawIssuer}, Serial: ds.SerialNumber})
+	sd := signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: encapContentInfo{ContentType: oidLDSSecurity, Content: content},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: ds.Raw},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttrs:        attrsRaw,
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: opts.sigAlg},
+			Signature:          sig,
+		}},
+	}
+	sdDER, err := asn1.Marshal(sd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ci, err := asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{oidSignedData, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sdDER}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return iso7816.AppendTLV(nil, 0x77, ci)
+}
+
+func TestSOD(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	cscaKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	csca := newCert(t, "CSCA", cscaKey, nil, nil)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other := newCert(t, "CSCA", otherKey, nil, nil)
+
+	dgs := map[int][]byte{1: []byte("dg1"), 2: []byte("dg2")}
+	tests := []struct {
+		name  string
+		key   crypto.Signer
+		opts  sodOptions
+		cscas []*x509.Certificate
+		err   error
+	}{
+		{"ecdsa", ecKey, sodOptions{sigAlg: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}}, []*x509.Certificate{other, csca}, nil},
+		{"ecdsa plain", ecKey, sodOptions{sigAlg: asn1.ObjectIdentifier{0, 4, 0, 127, 0, 7, 1, 1, 4, 1, 3}, plain: true}, []*x509.Certificate{csca}, nil},
+		{"rsa content", rsaKey, sodOptions{sigAlg: oidRSAEncryption, noAttrs: true}, []*x509.Certificate{csca}, nil},
+		{"untrusted", ecKey, sodOptions{sigAlg: oidECPublicKey}, []*x509.Certificate{other}, ErrVerification},
+		{"tampered", ecKey, sodOptions{sigAlg: oidECPublicKey, tamperHash: true}, []*x509.Certificate{csca}, ErrVerification},
+		{"tampered content", rsaKey, sodOptions{sigAlg: oidRSAEncryption, noAttrs: true, tamperHash: true}, []*x509.Certificate{csca}, ErrVerification},
+		{"unsupported", ecKey, sodOptions{sigAlg: asn1.ObjectIdentifier{1, 2, 3}}, []*x509.Certificate{csca}, ErrUnsupported},
+	}
+	for _, tt := range tests {
+		ds := newCert(t, "DS "+tt.name, tt.key, csca, cscaKey)
+		sod, err := ParseSOD(buildSOD(t, ds, tt.key, dgs, tt.opts))
+		if err != nil {
+			t.Errorf("%s: ParseSOD() error = %v", tt.name, err)
+			continue
+		}
+		if sod.Signer == nil || sod.Signer.Subject.CommonName != ds.Subject.CommonName || sod.Hash != crypto.SHA256 {
+			t.Errorf("%s: ParseSOD() signer %v, hash %v", tt.name, sod.Signer, sod.Hash)
+		}
+		if err := sod.Verify(tt.cscas); !errors.Is(err, tt.err) {
+			t.Errorf("%s: Verify() error = %v, want %v", tt.name, err, tt.err)
+		}
+		if tt.opts.tamperHash {
+			continue
+		}
+		if err := sod.VerifyDataGroup(2, dgs[2]); err != nil {
+			t.Errorf("%s: VerifyDataGroup(2) error = %v", tt.name, err)
+		}
+		if err := sod.VerifyDataGroup(1, dgs[2]); !errors.Is(err, ErrVerification) {
+			t.Errorf("%s: VerifyDataGroup(1) of other data error = %v", tt.name, err)
+		}
+		if err := sod.VerifyDataGroup(3, nil); !errors.Is(err, ErrVerification) {
+			t.Errorf("%s: VerifyDataGroup(3) error = %v", tt.name, err)
+		}
+	}
+
+	for _, bad := range [][]byte{{0x77, 0x02, 0x30, 0x00}, {0x60, 0x00}} {
+		if _, err := ParseSOD(bad); err == nil {
+			t.Errorf("ParseSOD(%X) succeeded", bad)
+		}
+	}
+}
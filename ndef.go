@@ -0,0 +1,108 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/sckutils"
+)
+
+// ReadNDEF waits for the next card on the selected readers, detects its tag
+// type with the detector of the SDK, see WithDetector, reads the NDEF
+// message stored on it and disconnects. It covers the common case of
+// reading a single tag without setting up event handling. The card is not
+// reported again while left on the reader, see Rearm. When progress is not
+// nil, it is reported the message bytes read, see tag.Detector.ReadNDEF.
+func (sdk *SDK) ReadNDEF(ctx context.Context, progress sckutils.ProgressFunc) (*ndef.Message, error) {
+	msg := ndef.NewMessage()
+	err := sdk.withNextCard(ctx, func(card tag.Card) error {
+		data, err := sdk.detector.ReadNDEF(card, progress)
+		if err != nil || len(data) == 0 {
+			return err
+		}
+		return msg.Unmarshal(data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// WriteNDEF waits for the next card on the selected readers, detects its tag
+// type like ReadNDEF, replaces the NDEF message stored on it with msg and
+// disconnects. When progress is not nil, it is reported the bytes written,
+// see tag.Detector.WriteNDEF.
+func (sdk *SDK) WriteNDEF(ctx context.Context, msg *ndef.Message, progress sckutils.ProgressFunc) error {
+	data, err := msg.Marshal()
+	if err != nil {
+		return err
+	}
+	return sdk.withNextCard(ctx, func(card tag.Card) error {
+		return sdk.detector.WriteNDEF(card, data, progress)
+	})
+}
+
+// transactor is a card supporting PC/SC transactions, such as the cards of
+// backends wrapping the PC/SC one.
+type transactor interface {
+	BeginTransaction() error
+	EndTransaction(d pcsc.Disposition) error
+}
+
+// withNextCard waits for the next card and runs fn on it, within a
+// transaction for cards supporting them. Like handleCard, it counts as a
+// card session and returns ErrClosed after Shutdown.
+func (sdk *SDK) withNextCard(ctx context.Context, fn func(card tag.Card) error) (err error) {
+	sdk.mu.RLock()
+	closed := sdk.closed
+	sdk.mu.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+	defer sdk.stopMonitoring()
+	card, reader, err := sdk.waitCard(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if derr := card.Disconnect(); derr != nil && err == nil {
+			err = derr
+		}
+	}()
+	done, err := sdk.beginSession()
+	if err != nil {
+		return err
+	}
+	defer done()
+	ev := sdk.newCardEvent(card, reader)
+	end := sdk.logSession(ev, card)
+	defer func() { end(err) }()
+	ctx, span := sdk.startCardSpan(ctx, ev, card)
+	defer func() { endSpan(span, err) }()
+	defer sdk.traceTransport(ctx, reader.Name, card)()
+	if pc, ok := card.(*pcsc.Card); ok {
+		return pc.Transaction(func(c *pcsc.Card) error {
+			return fn(instrumentedCard{Card: sdk.reconnecting(c, reader.Name), ctx: ctx, reader: reader.Name, sdk: sdk})
+		})
+	}
+	t, ok := card.(transactor)
+	if !ok {
+		return fn(instrumentedCard{Card: sdk.reconnecting(card, reader.Name), ctx: ctx, reader: reader.Name, sdk: sdk})
+	}
+	if err := t.BeginTransaction(); err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if eerr := t.EndTransaction(pcsc.LeaveCard); eerr != nil && err == nil {
+			err = fmt.Errorf("end transaction: %w", eerr)
+		}
+	}()
+	return fn(instrumentedCard{Card: sdk.reconnecting(card, reader.Name), ctx: ctx, reader: reader.Name, sdk: sdk})
+}
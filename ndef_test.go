@@ -0,0 +1,54 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/x/virtualreader"
+)
+
+func TestReadNDEFDetector(t *testing.T) {
+	ntag := virtualreader.NewNTAG215([]byte{0x04, 1, 2, 3, 4, 5, 6})
+	p := tag.MustParsePattern(fmt.Sprintf("% X", ntag.ATR()))
+	topaz := tag.NewDetector()
+	topaz.Override(tag.Rule{ATR: &p, Type: tag.TypeTopaz})
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr error
+	}{
+		{"default detector", nil, nil},
+		{"overridden type", []Option{WithDetector(topaz)}, tag.ErrUnsupported},
+	}
+	for _, tt := range tests {
+		vr := virtualreader.New("gate")
+		if err := vr.Insert("gate", ntag); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := New(append(tt.opts, WithBackend(vr))...).WriteNDEF(ctx, ndef.NewMessage(ndef.NewURIRecord("https://example.com")), nil)
+		if tt.wantErr == nil && err != nil || !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: WriteNDEF() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if err := vr.Remove("gate"); err != nil {
+			t.Fatal(err)
+		}
+		if err := vr.Insert("gate", ntag); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := New(append(tt.opts, WithBackend(vr))...).ReadNDEF(ctx, nil); tt.wantErr == nil && err != nil || !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: ReadNDEF() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		cancel()
+	}
+}
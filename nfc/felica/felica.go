@@ -0,0 +1,192 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package felica provides helpers for FeliCa (JIS X 6319-4) cards. It builds
+// and parses the Read Without Encryption and Write Without Encryption frames,
+// including service code lists and block lists, so FeliCa Lite and open
+// service reads do not require manual frame math.
+package felica
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// BlockSize is the size of a FeliCa data block in bytes.
+	BlockSize = 16
+	// IDmSize is the size of the manufacture ID (IDm) in bytes.
+	IDmSize = 8
+	// MaxServices is the maximum number of services in a single command.
+	MaxServices = 16
+
+	CommandReadWithoutEncryption   = 0x06
+	ResponseReadWithoutEncryption  = 0x07
+	CommandWriteWithoutEncryption  = 0x08
+	ResponseWriteWithoutEncryption = 0x09
+)
+
+// ServiceCode identifies a FeliCa service. The lower 6 bits hold the access
+// attribute, the upper 10 bits the service number.
+type ServiceCode uint16
+
+const (
+	ServiceLiteRW ServiceCode = 0x0009 // FeliCa Lite random service, read/write.
+	ServiceLiteRO ServiceCode = 0x000B // FeliCa Lite random service, read-only.
+)
+
+// Encryptionless reports whether the service can be accessed without
+// authentication, i.e. with the Read/Write Without Encryption commands.
+func (s ServiceCode) Encryptionless() bool { return s&0x01 == 0x01 }
+
+// Number returns the service number.
+func (s ServiceCode) Number() uint16 { return uint16(s) >> 6 }
+
+// Attribute returns the access attribute of the service.
+func (s ServiceCode) Attribute() uint8 { return uint8(s & 0x3F) }
+
+// Block addresses a block within one of the services of a command.
+type Block struct {
+	Service int    // Index into the service code list of the command.
+	Number  uint16 // Block number within the service.
+	Purse   bool   // Access mode cashback, only valid for purse services.
+}
+
+// EncodeServiceCodeList encodes service codes as the little-endian service
+// code list used by FeliCa commands.
+func EncodeServiceCodeList(services []ServiceCode) ([]byte, error) {
+	if len(services) == 0 || len(services) > MaxServices {
+		return nil, fmt.Errorf("service count %d out of range 1-%d", len(services), MaxServices)
+	}
+	out := make([]byte, 0, 2*len(services))
+	for _, s := range services {
+		if !s.Encryptionless() {
+			return nil, fmt.Errorf("service 0x%04X requires authentication", uint16(s))
+		}
+		out = binary.LittleEndian.AppendUint16(out, uint16(s))
+	}
+	return out, nil
+}
+
+// EncodeBlockList encodes blocks as a FeliCa block list. Blocks with a number
+// below 256 use the 2-byte element format, others the 3-byte format.
+func EncodeBlockList(blocks []Block, services int) ([]byte, error) {
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("empty block list")
+	}
+	var out []byte
+	for _, b := range blocks {
+		if b.Service < 0 || b.Service >= services || b.Service > 0x0F {
+			return nil, fmt.Errorf("block service index %d out of range", b.Service)
+		}
+		head := byte(b.Service)
+		if b.Purse {
+			head |= 0x10
+		}
+		if b.Number <= 0xFF {
+			out = append(out, head|0x80, byte(b.Number))
+			continue
+		}
+		out = append(out, head)
+		out = binary.LittleEndian.AppendUint16(out, b.Number)
+	}
+	return out, nil
+}
+
+// ReadWithoutEncryption builds a Read Without Encryption command frame,
+// including the leading length byte.
+func ReadWithoutEncryption(idm []byte, services []ServiceCode, blocks []Block) ([]byte, error) {
+	return buildFrame(CommandReadWithoutEncryption, idm, services, blocks, nil)
+}
+
+// WriteWithoutEncryption builds a Write Without Encryption command frame,
+// including the leading length byte. data must hold BlockSize bytes for each
+// block.
+func WriteWithoutEncryption(idm []byte, services []ServiceCode, blocks []Block, data []byte) ([]byte, error) {
+	if len(data) != len(blocks)*BlockSize {
+		return nil, fmt.Errorf("data length %d, want %d for %d blocks", len(data), len(blocks)*BlockSize, len(blocks))
+	}
+	return buildFrame(CommandWriteWithoutEncryption, idm, services, blocks, data)
+}
+
+// ParseReadResponse parses a Read Without Encryption response frame and
+// returns the data of the read blocks.
+func ParseReadResponse(idm, frame []byte) ([]byte, error) {
+	body, err := parseResponse(ResponseReadWithoutEncryption, idm, frame)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < 1 {
+		return nil, fmt.Errorf("read response missing block count")
+	}
+	n := int(body[0])
+	if len(body)-1 != n*BlockSize {
+		return nil, fmt.Errorf("read response has %d data bytes, want %d", len(body)-1, n*BlockSize)
+	}
+	return body[1:], nil
+}
+
+// ParseWriteResponse parses a Write Without Encryption response frame.
+func ParseWriteResponse(idm, frame []byte) error {
+	_, err := parseResponse(ResponseWriteWithoutEncryption, idm, frame)
+	return err
+}
+
+// StatusError is returned when a card reports non-zero status flags.
+type StatusError struct {
+	Flag1 byte
+	Flag2 byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("felica status flags %02X %02X", e.Flag1, e.Flag2)
+}
+
+func buildFrame(code byte, idm []byte, services []ServiceCode, blocks []Block, data []byte) ([]byte, error) {
+	if len(idm) != IDmSize {
+		return nil, fmt.Errorf("idm length %d, want %d", len(idm), IDmSize)
+	}
+	scl, err := EncodeServiceCodeList(services)
+	if err != nil {
+		return nil, err
+	}
+	bl, err := EncodeBlockList(blocks, len(services))
+	if err != nil {
+		return nil, err
+	}
+
+	frame := []byte{0, code}
+	frame = append(frame, idm...)
+	frame = append(frame, byte(len(services)))
+	frame = append(frame, scl...)
+	frame = append(frame, byte(len(blocks)))
+	frame = append(frame, bl...)
+	frame = append(frame, data...)
+	if len(frame) > 0xFF {
+		return nil, fmt.Errorf("frame length %d exceeds 255 bytes", len(frame))
+	}
+	frame[0] = byte(len(frame))
+	return frame, nil
+}
+
+func parseResponse(code byte, idm, frame []byte) ([]byte, error) {
+	const header = 2 + IDmSize + 2
+	if len(frame) < header {
+		return nil, fmt.Errorf("response too short: %d bytes", len(frame))
+	}
+	if int(frame[0]) != len(frame) {
+		return nil, fmt.Errorf("response length byte %d, frame has %d bytes", frame[0], len(frame))
+	}
+	if frame[1] != code {
+		return nil, fmt.Errorf("unexpected response code 0x%02X, want 0x%02X", frame[1], code)
+	}
+	if !bytes.Equal(frame[2:2+IDmSize], idm) {
+		return nil, fmt.Errorf("response idm does not match")
+	}
+	if sf1, sf2 := frame[2+IDmSize], frame[3+IDmSize]; sf1 != 0 || sf2 != 0 {
+		return nil, &StatusError{Flag1: sf1, Flag2: sf2}
+	}
+	return frame[header:], nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package felica
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var testIDm = []byte{0x01, 0x2E, 0x4C, 0xD6, 0x53, 0x0F, 0x2C, 0x11}
+
+func TestEncodeBlockList(t *testing.T) {
+	tests := []struct {
+		name    string
+		blocks  []Block
+		want    []byte
+		wantErr bool
+	}{
+		{"two byte", []Block{{Service: 0, Number: 0x0E}}, []byte{0x80, 0x0E}, false},
+		{"three byte", []Block{{Service: 1, Number: 0x0102}}, []byte{0x01, 0x02, 0x01}, false},
+		{"purse", []Block{{Service: 0, Number: 1, Purse: true}}, []byte{0x90, 0x01}, false},
+		{"bad service index", []Block{{Service: 2}}, nil, true},
+		{"empty", nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EncodeBlockList(tt.blocks, 2)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EncodeBlockList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("EncodeBlockList() = % X, want % X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadWithoutEncryption(t *testing.T) {
+	got, err := ReadWithoutEncryption(testIDm, []ServiceCode{ServiceLiteRO}, []Block{{Number: 0}, {Number: 0x82}})
+	if err != nil {
+		t.Fatalf("ReadWithoutEncryption() error = %v", err)
+	}
+	want := append([]byte{0x12, 0x06}, testIDm...)
+	want = append(want, 0x01, 0x0B, 0x00, 0x02, 0x80, 0x00, 0x80, 0x82)
+	if !bytes.Equal(got, want) {
+		t.Errorf("ReadWithoutEncryption() = % X, want % X", got, want)
+	}
+
+	if _, err := ReadWithoutEncryption(testIDm, []ServiceCode{0x0008}, []Block{{}}); err == nil {
+		t.Error("ReadWithoutEncryption() accepted a service requiring authentication")
+	}
+}
+
+func TestParseReadResponse(t *testing.T) {
+	data := bytes.Repeat([]byte{0xAB}, BlockSize)
+	frame := append([]byte{0, ResponseReadWithoutEncryption}, testIDm...)
+	frame = append(frame, 0x00, 0x00, 0x01)
+	frame = append(frame, data...)
+	frame[0] = byte(len(frame))
+
+	got, err := ParseReadResponse(testIDm, frame)
+	if err != nil {
+		t.Fatalf("ParseReadResponse() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("ParseReadResponse() = % X, want % X", got, data)
+	}
+
+	failed := append([]byte{12, ResponseReadWithoutEncryption}, testIDm...)
+	failed = append(failed, 0x01, 0xA6)
+	var serr *StatusError
+	if _, err := ParseReadResponse(testIDm, failed); !errors.As(err, &serr) || serr.Flag2 != 0xA6 {
+		t.Errorf("ParseReadResponse() error = %v, want StatusError with flag2 A6", err)
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package identify tells the exact model of a contactless card, such as
+// NTAG213, 215 or 216, MIFARE Classic 1K or 4K or DESFire EV1, EV2 or EV3,
+// and its memory size. It refines the tag type detected from the ATR with
+// the ISO 14443 Type A activation data (ATQA and SAK) and the GET VERSION
+// response of NXP cards.
+package identify
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// Result is what is known about a card.
+type Result struct {
+	Type  tag.Type
+	Model string // Product name, empty when unknown.
+	// Memory is the size in bytes of the memory available to
+	// applications: the user memory of Type 2 tags, the total memory of
+	// MIFARE Classic and DESFire. 0 when unknown.
+	Memory int
+
+	ATR        []byte
+	Historical []byte
+	// ATQA and SAK are set when HasTypeA reports the card or reader told
+	// them.
+	ATQA     uint16
+	SAK      byte
+	HasTypeA bool
+	Version  []byte // GET VERSION response, if any.
+}
+
+// String returns the model, or the tag type when the model is unknown,
+// followed by the memory size.
+func (r *Result) String() string {
+	name := r.Model
+	if name == "" {
+		name = r.Type.String()
+	}
+	if r.Memory > 0 {
+		return fmt.Sprintf("%s (%d bytes)", name, r.Memory)
+	}
+	return name
+}
+
+// TypeA is implemented by cards reporting their ISO 14443 Type A
+// activation data, such as the cards of pn532 readers.
+type TypeA interface {
+	ATQA() uint16
+	SAK() byte
+}
+
+// Identifier identifies cards. The zero value is ready to use.
+type Identifier struct {
+	// TypeACommand is the reader specific pseudo-APDU returning the ATQA,
+	// two bytes most significant first, and the SAK of the card. Cards
+	// implementing TypeA do not need it; when nil, cards which do not are
+	// identified without their activation data.
+	TypeACommand []byte
+	// PassThrough is the pseudo-APDU header wrapping native commands of
+	// Type 2 tags, FF 00 00 00 when nil.
+	PassThrough []byte
+}
+
+// Identify identifies card using a zero Identifier.
+func Identify(card tag.Card) (*Result, error) {
+	var id Identifier
+	return id.Identify(card)
+}
+
+// Identify identifies card. Commands the card or reader does not support
+// only leave the result less precise; errors are returned only for failures
+// to reach the card.
+func (id *Identifier) Identify(card tag.Card) (*Result, error) {
+	r := &Result{ATR: card.ATR(), Type: tag.Detect(tag.Signature{ATR: card.ATR()})}
+	if atr, err := iso7816.ParseATR(r.ATR); err == nil {
+		r.Historical = atr.Historical
+	}
+	if err := id.readTypeA(card, r); err != nil {
+		return nil, err
+	}
+
+	var err error
+	switch r.Type {
+	case tag.TypeUltralight, tag.TypeNTAG:
+		r.Version, err = id.type2Version(card)
+	case tag.TypeDESFire, tag.TypeISODEP:
+		r.Version, err = desfireVersion(card)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t := tag.Detect(tag.Signature{ATR: r.ATR, Version: r.Version}); t != tag.TypeUnknown {
+		r.Type = t
+	}
+
+	switch {
+	case len(r.Version) == 8:
+		identifyType2(r)
+	case len(r.Version) >= 7:
+		identifyDESFire(r)
+	}
+	if r.Model == "" {
+		identifyATR(r)
+	}
+	return r, nil
+}
+
+func (id *Identifier) readTypeA(card tag.Card, r *Result) error {
+	if a, ok := card.(TypeA); ok {
+		r.ATQA, r.SAK, r.HasTypeA = a.ATQA(), a.SAK(), true
+		return nil
+	}
+	if id.TypeACommand == nil {
+		return nil
+	}
+	resp, err := transmit(card, id.TypeACommand)
+	if err != nil || len(resp) != 3 {
+		return err
+	}
+	r.ATQA, r.SAK, r.HasTypeA = uint16(resp[0])<<8|uint16(resp[1]), resp[2], true
+	return nil
+}
+
+// transmit sends cmd, returning nil data without error when the card
+// answers with an error status.
+func transmit(card tag.Card, cmd []byte) ([]byte, error) {
+	resp, err := card.Transmit(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		var se *apdu.StatusError
+		if errors.As(err, &se) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return resp[:len(resp)-2], nil
+}
+
+func (id *Identifier) type2Version(card tag.Card) ([]byte, error) {
+	header := id.PassThrough
+	if header == nil {
+		header = []byte{0xFF, 0x00, 0x00, 0x00}
+	}
+	resp, err := transmit(card, append(append([]byte(nil), header...), 0x01, 0x60))
+	if err != nil || len(resp) != 8 {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// desfireVersion sends the wrapped native GetVersion and returns the three
+// response frames concatenated.
+func desfireVersion(card tag.Card) ([]byte, error) {
+	var version []byte
+	cmd := []byte{0x90, 0x60, 0x00, 0x00, 0x00}
+	for i := 0; i < 3; i++ {
+		resp, err := card.Transmit(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp) < 2 || resp[len(resp)-2] != 0x91 {
+			return nil, nil
+		}
+		version = append(version, resp[:len(resp)-2]...)
+		switch resp[len(resp)-1] {
+		case 0xAF:
+			cmd = []byte{0x90, 0xAF, 0x00, 0x00, 0x00}
+		case 0x00:
+			return version, nil
+		default:
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+// storageSize decodes the storage size byte of a GET VERSION response: the
+// exponent of the size in its upper seven bits.
+func storageSize(b byte) int { return 1 << (b >> 1) }
+
+var type2Models = map[[2]byte]struct {
+	name   string
+	memory int
+}{
+	{0x03, 0x0B}: {"MIFARE Ultralight EV1 MF0UL11", 48},
+	{0x03, 0x0E}: {"MIFARE Ultralight EV1 MF0UL21", 128},
+	{0x04, 0x0B}: {"NTAG210", 48},
+	{0x04, 0x0E}: {"NTAG212", 128},
+	{0x04, 0x0F}: {"NTAG213", 144},
+	{0x04, 0x11}: {"NTAG215", 504},
+	{0x04, 0x13}: {"NTAG216", 888},
+}
+
+func identifyType2(r *Result) {
+	v := r.Version
+	if m, ok := type2Models[[2]byte{v[2], v[6]}]; ok {
+		r.Model, r.Memory = m.name, m.memory
+		return
+	}
+	r.Memory = storageSize(v[6])
+}
+
+func identifyDESFire(r *Result) {
+	v := r.Version
+	if v[0] != 0x04 {
+		return
+	}
+	r.Memory = storageSize(v[5])
+	switch v[1] {
+	case 0x01, 0x81:
+		gen := map[byte]string{0x00: "", 0x01: " EV1", 0x12: " EV2", 0x30: " EV3"}
+		if g, ok := gen[v[3]]; ok {
+			r.Model = fmt.Sprintf("MIFARE DESFire%s %dK", g, r.Memory/1024)
+		}
+	case 0x04:
+		if v[3] == 0x30 {
+			r.Model = "NTAG 424 DNA"
+			r.Memory = 416
+		}
+	case 0x08:
+		r.Model = "MIFARE DESFire Light"
+	}
+}
+
+func identifyATR(r *Result) {
+	if r.HasTypeA {
+		switch r.SAK {
+		case 0x08, 0x88:
+			r.Type = tag.TypeMifareClassic1K
+		case 0x18:
+			r.Type = tag.TypeMifareClassic4K
+		case 0x09:
+			r.Type = tag.TypeMifareMini
+		case 0x10:
+			r.Model, r.Memory = "MIFARE Plus 2K", 2048
+			return
+		case 0x11:
+			r.Model, r.Memory = "MIFARE Plus 4K", 4096
+			return
+		}
+	}
+	switch r.Type {
+	case tag.TypeMifareClassic1K:
+		r.Model, r.Memory = "MIFARE Classic 1K", 1024
+	case tag.TypeMifareClassic4K:
+		r.Model, r.Memory = "MIFARE Classic 4K", 4096
+	case tag.TypeMifareMini:
+		r.Model, r.Memory = "MIFARE Mini", 320
+	case tag.TypeUltralight:
+		r.Model, r.Memory = "MIFARE Ultralight", 48
+	case tag.TypeUltralightC:
+		r.Model, r.Memory = "MIFARE Ultralight C", 144
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package identify
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/x/virtualreader"
+)
+
+// fakeCard reports atr and answers the pseudo-APDU FF CA F0 00 00 with
+// typeA, everything else with 6A 81.
+type fakeCard struct {
+	atr   string
+	typeA []byte
+}
+
+var typeACommand = []byte{0xFF, 0xCA, 0xF0, 0x00, 0x00}
+
+func (c *fakeCard) ATR() []byte {
+	b, _ := hex.DecodeString(c.atr)
+	return b
+}
+
+func (c *fakeCard) Transmit(cmd []byte) ([]byte, error) {
+	if bytes.Equal(cmd, typeACommand) && c.typeA != nil {
+		return append(append([]byte(nil), c.typeA...), 0x90, 0x00), nil
+	}
+	return []byte{0x6A, 0x81}, nil
+}
+
+// typeACard reports its activation data like a pn532 card.
+type typeACard struct {
+	fakeCard
+	atqa uint16
+	sak  byte
+}
+
+func (c *typeACard) ATQA() uint16 { return c.atqa }
+func (c *typeACard) SAK() byte    { return c.sak }
+
+const classic1KATR = "3B8F8001804F0CA000000306030001000000006A"
+
+func TestIdentify(t *testing.T) {
+	tests := []struct {
+		name   string
+		card   tag.Card
+		typ    tag.Type
+		model  string
+		memory int
+	}{
+		{"ntag215", virtualreader.NewNTAG215([]byte{0x04, 1, 2, 3, 4, 5, 6}), tag.TypeNTAG, "NTAG215", 504},
+		{"desfire", virtualreader.NewDESFire([]byte{0x04, 1, 2, 3, 4, 5, 6}, nil), tag.TypeDESFire, "MIFARE DESFire EV2 8K", 8192},
+		{"classic 1k by atr", &fakeCard{atr: classic1KATR}, tag.TypeMifareClassic1K, "MIFARE Classic 1K", 1024},
+		{"classic 4k by sak", &typeACard{fakeCard: fakeCard{atr: classic1KATR}, atqa: 0x0002, sak: 0x18}, tag.TypeMifareClassic4K, "MIFARE Classic 4K", 4096},
+		{"plus by command", &fakeCard{atr: classic1KATR, typeA: []byte{0x00, 0x04, 0x11}}, tag.TypeMifareClassic1K, "MIFARE Plus 4K", 4096},
+		{"unknown", &fakeCard{atr: "3B00"}, tag.TypeUnknown, "", 0},
+	}
+	id := Identifier{TypeACommand: typeACommand}
+	for _, tt := range tests {
+		r, err := id.Identify(tt.card)
+		if err != nil {
+			t.Errorf("%s: Identify() error = %v", tt.name, err)
+			continue
+		}
+		if r.Type != tt.typ || r.Model != tt.model || r.Memory != tt.memory {
+			t.Errorf("%s: Identify() = %s, %q, %d; want %s, %q, %d", tt.name, r.Type, r.Model, r.Memory, tt.typ, tt.model, tt.memory)
+		}
+	}
+}
+
+func TestResultString(t *testing.T) {
+	r := &Result{Type: tag.TypeNTAG, Model: "NTAG213", Memory: 144}
+	if got := r.String(); got != "NTAG213 (144 bytes)" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := (&Result{Type: tag.TypeFeliCa}).String(); got != "felica" {
+		t.Errorf("String() = %q", got)
+	}
+}
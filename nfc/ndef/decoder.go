@@ -0,0 +1,182 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxPayload is the default limit of a Decoder on the payload size
+// of a record, chunks reassembled.
+const DefaultMaxPayload = 1 << 20
+
+// ErrPayloadTooLarge is returned by a Decoder for records whose payload
+// exceeds its limit.
+var ErrPayloadTooLarge = errors.New("ndef: payload exceeds the limit")
+
+// Decoder reads the records of an NDEF message from a stream. Unlike
+// Message.Unmarshal it does not need the serialized message in memory:
+// each payload is allocated once at its final size, or grown chunk by
+// chunk for chunked records.
+type Decoder struct {
+	// MaxPayload limits the payload size of a record, chunks reassembled.
+	// Zero selects DefaultMaxPayload.
+	MaxPayload int
+
+	r     io.Reader
+	off   int // Offset of the next record in the message.
+	count int // Records read, chunks counted individually.
+	ended bool
+}
+
+// NewDecoder returns a decoder reading a message from r.
+func NewDecoder(r io.Reader) *Decoder { return &Decoder{r: r} }
+
+// Next returns the next record of the message, reassembling chunked
+// records. It returns io.EOF after the record carrying the message end
+// flag; a stream ending before it fails with io.ErrUnexpectedEOF.
+func (d *Decoder) Next() (*Record, error) {
+	if d.ended {
+		return nil, io.EOF
+	}
+	flags, rec, err := d.record(nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case rec.TNF == TNFUnchanged:
+		return nil, fmt.Errorf("ndef: unchanged type outside of a chunked record")
+	case flags&flagCF == 0:
+		return rec, nil
+	}
+	for flags&flagCF != 0 {
+		if flags&flagME != 0 {
+			return nil, fmt.Errorf("ndef: message ends inside a chunked record")
+		}
+		var chunk *Record
+		off := d.off
+		flags, chunk, err = d.record(rec)
+		if err != nil {
+			return nil, err
+		}
+		if chunk.TNF != TNFUnchanged || len(chunk.Type) != 0 || len(chunk.ID) != 0 {
+			return nil, fmt.Errorf("ndef: invalid middle or terminating chunk at offset %d", off)
+		}
+	}
+	return rec, nil
+}
+
+// Decode reads the complete message into m.
+func (d *Decoder) Decode(m *Message) error {
+	m.Records = nil
+	for {
+		r, err := d.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		m.Records = append(m.Records, r)
+	}
+}
+
+// record reads a record. When chunked is not nil the payload is appended
+// to the payload of chunked instead of a new buffer.
+func (d *Decoder) record(chunked *Record) (byte, *Record, error) {
+	limit := d.MaxPayload
+	if limit <= 0 {
+		limit = DefaultMaxPayload
+	}
+	var hdr [7]byte
+	if err := d.read(hdr[:3], d.count > 0); err != nil {
+		return 0, nil, err
+	}
+	flags, typeLen, n := hdr[0], int(hdr[1]), 3
+	if d.count == 0 && flags&flagMB == 0 {
+		return 0, nil, fmt.Errorf("ndef: first record lacks message begin flag")
+	}
+	if d.count > 0 && flags&flagMB != 0 {
+		return 0, nil, fmt.Errorf("ndef: message begin flag at offset %d", d.off)
+	}
+	var payloadLen uint64
+	if flags&flagSR != 0 {
+		payloadLen = uint64(hdr[2])
+	} else {
+		if err := d.read(hdr[3:6], true); err != nil {
+			return 0, nil, err
+		}
+		payloadLen = uint64(binary.BigEndian.Uint32(hdr[2:6]))
+		n = 6
+	}
+	idLen := 0
+	if flags&flagIL != 0 {
+		if err := d.read(hdr[n:n+1], true); err != nil {
+			return 0, nil, err
+		}
+		idLen = int(hdr[n])
+		n++
+	}
+	total := payloadLen
+	if chunked != nil {
+		total += uint64(len(chunked.Payload))
+	}
+	if total > uint64(limit) {
+		return 0, nil, fmt.Errorf("%w: %d bytes at offset %d, limit %d", ErrPayloadTooLarge, total, d.off, limit)
+	}
+
+	rec := &Record{TNF: TNF(flags & maskTNF)}
+	var err error
+	if rec.Type, err = d.field(typeLen); err != nil {
+		return 0, nil, err
+	}
+	if rec.ID, err = d.field(idLen); err != nil {
+		return 0, nil, err
+	}
+	if chunked != nil {
+		start := len(chunked.Payload)
+		chunked.Payload = append(chunked.Payload, make([]byte, payloadLen)...)
+		if err := d.read(chunked.Payload[start:], true); err != nil {
+			return 0, nil, err
+		}
+	} else if rec.Payload, err = d.field(int(payloadLen)); err != nil {
+		return 0, nil, err
+	}
+	d.off += n + typeLen + idLen + int(payloadLen)
+	d.count++
+	d.ended = flags&flagME != 0
+	return flags, rec, nil
+}
+
+// field reads a field of n bytes, nil when n is zero.
+func (d *Decoder) field(n int) ([]byte, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	b := make([]byte, n)
+	if err := d.read(b, true); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// read fills b. A stream ending before b is filled fails with
+// io.ErrUnexpectedEOF, unless it is empty and started is not set.
+func (d *Decoder) read(b []byte, started bool) error {
+	n, err := io.ReadFull(d.r, b)
+	switch {
+	case err == nil:
+		return nil
+	case err == io.EOF && n == 0 && !started:
+		return fmt.Errorf("ndef: empty message")
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		return fmt.Errorf("ndef: truncated record at offset %d: %w", d.off, io.ErrUnexpectedEOF)
+	default:
+		return err
+	}
+}
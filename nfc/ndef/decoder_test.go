@@ -0,0 +1,67 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecoder(t *testing.T) {
+	big := bytes.Repeat([]byte{'x'}, 5000)
+	media, _ := NewMIMERecord("application/octet-stream", big)
+	msg := NewMessage(NewURIRecord("https://example.com"), media, NewRecord(TNFWellKnown, TypeText, []byte("id"), []byte("\x02enhi")))
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Message
+	if err := NewDecoder(iotest.OneByteReader(bytes.NewReader(data))).Decode(&got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(got.Records, msg.Records) {
+		t.Errorf("Decode() = %+v", got.Records)
+	}
+
+	chunked, _ := hex.DecodeString("B101025504613600016256000163")
+	d := NewDecoder(bytes.NewReader(chunked))
+	r, err := d.Next()
+	if err != nil || string(r.Payload) != "\x04abc" {
+		t.Fatalf("Next() = %+v, %v", r, err)
+	}
+	if _, err := d.Next(); err != io.EOF {
+		t.Errorf("Next() after the message end error = %v", err)
+	}
+
+	d = NewDecoder(bytes.NewReader(data))
+	d.MaxPayload = 4096
+	if _, err := d.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Next(); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Next() of a 5000 byte payload error = %v", err)
+	}
+	d = NewDecoder(bytes.NewReader(chunked))
+	d.MaxPayload = 3
+	if _, err := d.Next(); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Next() of a 4 byte chunked payload error = %v", err)
+	}
+
+	for _, bad := range []string{"", "91010855046578616D706C65", "D1010855046578", "51010055", "B101025504617600016200", "B10102550461D6000162"} {
+		b, _ := hex.DecodeString(bad)
+		if err := NewDecoder(bytes.NewReader(b)).Decode(&Message{}); err == nil {
+			t.Errorf("Decode(%s) accepted", bad)
+		}
+	}
+	b, _ := hex.DecodeString("D1010855046578")
+	if err := NewDecoder(bytes.NewReader(b)).Decode(&Message{}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Decode() of a truncated record error = %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExternalTypePrefix is the URN prefix of NFC Forum external types.
+const ExternalTypePrefix = "urn:nfc:ext:"
+
+// AndroidApplicationType is the external type of Android Application Records.
+const AndroidApplicationType = "android.com:pkg"
+
+// NewExternalRecord creates an NFC Forum external type record. typ has the
+// form "domain:type" and may carry the "urn:nfc:ext:" prefix, which is not
+// stored in the record. The type is case-insensitive and stored lower-cased.
+func NewExternalRecord(typ string, payload []byte) (*Record, error) {
+	name, err := normalizeExternalType(typ)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecord(TNFExternal, []byte(name), nil, payload), nil
+}
+
+// NewAndroidApplicationRecord creates an Android Application Record (AAR)
+// launching the app with the package name pkg, e.g. "com.example.app".
+// Android opens the app when a tag whose message contains the record is read.
+func NewAndroidApplicationRecord(pkg string) (*Record, error) {
+	if pkg == "" || strings.ContainsAny(pkg, " /:") {
+		return nil, fmt.Errorf("ndef: invalid android package name %q", pkg)
+	}
+	return NewExternalRecord(AndroidApplicationType, []byte(pkg))
+}
+
+// ExternalType returns the type of an external type record in its full
+// "urn:nfc:ext:domain:type" form.
+func (r *Record) ExternalType() (string, error) {
+	if r.TNF != TNFExternal {
+		return "", fmt.Errorf("ndef: not an external type record")
+	}
+	return ExternalTypePrefix + strings.ToLower(string(r.Type)), nil
+}
+
+// AndroidPackage returns the package name of an Android Application Record.
+func (r *Record) AndroidPackage() (string, error) {
+	if r.TNF != TNFExternal || !strings.EqualFold(string(r.Type), AndroidApplicationType) {
+		return "", fmt.Errorf("ndef: not an android application record")
+	}
+	return string(r.Payload), nil
+}
+
+func normalizeExternalType(typ string) (string, error) {
+	name := typ
+	if len(name) >= len(ExternalTypePrefix) && strings.EqualFold(name[:len(ExternalTypePrefix)], ExternalTypePrefix) {
+		name = name[len(ExternalTypePrefix):]
+	}
+	name = strings.ToLower(name)
+	domain, kind, ok := strings.Cut(name, ":")
+	if !ok || domain == "" || kind == "" {
+		return "", fmt.Errorf("ndef: external type %q is not of the form domain:type", typ)
+	}
+	for _, c := range name {
+		if c <= 0x20 || c >= 0x7F {
+			return "", fmt.Errorf("ndef: external type %q contains invalid characters", typ)
+		}
+	}
+	if len(name) > 0xFF {
+		return "", fmt.Errorf("ndef: external type longer than 255 bytes")
+	}
+	return name, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewAndroidApplicationRecord(t *testing.T) {
+	r, err := NewAndroidApplicationRecord("com.example.app")
+	if err != nil {
+		t.Fatalf("NewAndroidApplicationRecord() error = %v", err)
+	}
+	data, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := append([]byte{0xD4, 0x0F, 0x0F}, "android.com:pkgcom.example.app"...)
+	if !bytes.Equal(data, want) {
+		t.Errorf("Marshal() = % X, want % X", data, want)
+	}
+	if pkg, err := r.AndroidPackage(); err != nil || pkg != "com.example.app" {
+		t.Errorf("AndroidPackage() = %q, %v", pkg, err)
+	}
+
+	if _, err := NewAndroidApplicationRecord("com example"); err == nil {
+		t.Error("NewAndroidApplicationRecord() accepted invalid package name")
+	}
+}
+
+func TestNewExternalRecord(t *testing.T) {
+	r, err := NewExternalRecord("urn:nfc:ext:Example.com:Badge", []byte{1})
+	if err != nil {
+		t.Fatalf("NewExternalRecord() error = %v", err)
+	}
+	if string(r.Type) != "example.com:badge" {
+		t.Errorf("Type = %q, want %q", r.Type, "example.com:badge")
+	}
+	if typ, _ := r.ExternalType(); typ != "urn:nfc:ext:example.com:badge" {
+		t.Errorf("ExternalType() = %q", typ)
+	}
+	for _, bad := range []string{"example.com", ":badge", "example.com:", "exa mple:x"} {
+		if _, err := NewExternalRecord(bad, nil); err == nil {
+			t.Errorf("NewExternalRecord(%q) succeeded", bad)
+		}
+	}
+}
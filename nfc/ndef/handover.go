@@ -0,0 +1,288 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// Record types of the NFC Forum Connection Handover specification.
+var (
+	TypeHandoverSelect      = []byte("Hs")
+	TypeHandoverRequest     = []byte("Hr")
+	TypeAlternativeCarrier  = []byte("ac")
+	TypeCollisionResolution = []byte("cr")
+)
+
+// HandoverVersion is the Connection Handover version written by this
+// package, 1.3.
+const HandoverVersion = 0x13
+
+// MIMEBluetoothOOB is the media type of Bluetooth BR/EDR out-of-band
+// carrier configuration records.
+const MIMEBluetoothOOB = "application/vnd.bluetooth.ep.oob"
+
+// CarrierPowerState is the power state of an alternative carrier.
+type CarrierPowerState uint8
+
+const (
+	CarrierInactive   CarrierPowerState = 0x00
+	CarrierActive     CarrierPowerState = 0x01
+	CarrierActivating CarrierPowerState = 0x02
+	CarrierUnknown    CarrierPowerState = 0x03
+)
+
+// AlternativeCarrier describes a carrier offered in a handover message. Its
+// references point to the IDs of the carrier configuration and auxiliary data
+// records of the message.
+type AlternativeCarrier struct {
+	PowerState     CarrierPowerState
+	CarrierDataRef string
+	AuxDataRefs    []string
+}
+
+// Handover is the content of a Handover Select or Handover Request record.
+type Handover struct {
+	Request   bool   // Handover Request when true, Handover Select otherwise.
+	Version   byte   // Major version in the upper, minor in the lower nibble.
+	Collision uint16 // Random number of a Handover Request.
+	Carriers  []AlternativeCarrier
+}
+
+// NewHandoverSelect creates a Handover Select record offering carriers.
+func NewHandoverSelect(carriers ...AlternativeCarrier) (*Record, error) {
+	return (&Handover{Version: HandoverVersion, Carriers: carriers}).record()
+}
+
+// NewHandoverRequest creates a Handover Request record offering carriers.
+// random is used for collision resolution between two requesters.
+func NewHandoverRequest(random uint16, carriers ...AlternativeCarrier) (*Record, error) {
+	return (&Handover{Request: true, Version: HandoverVersion, Collision: random, Carriers: carriers}).record()
+}
+
+// Handover parses a Handover Select or Handover Request record.
+func (r *Record) Handover() (*Handover, error) {
+	if r.TNF != TNFWellKnown {
+		return nil, fmt.Errorf("ndef: not a handover record")
+	}
+	h := &Handover{}
+	switch string(r.Type) {
+	case string(TypeHandoverSelect):
+	case string(TypeHandoverRequest):
+		h.Request = true
+	default:
+		return nil, fmt.Errorf("ndef: not a handover record")
+	}
+	if len(r.Payload) == 0 {
+		return nil, fmt.Errorf("ndef: empty handover payload")
+	}
+	h.Version = r.Payload[0]
+	if len(r.Payload) == 1 {
+		return h, nil
+	}
+
+	var inner Message
+	if err := inner.Unmarshal(r.Payload[1:]); err != nil {
+		return nil, fmt.Errorf("ndef: handover records: %w", err)
+	}
+	for _, rec := range inner.Records {
+		if rec.TNF != TNFWellKnown {
+			continue
+		}
+		switch string(rec.Type) {
+		case string(TypeCollisionResolution):
+			if len(rec.Payload) != 2 {
+				return nil, fmt.Errorf("ndef: invalid collision resolution record")
+			}
+			h.Collision = binary.BigEndian.Uint16(rec.Payload)
+		case string(TypeAlternativeCarrier):
+			ac, err := parseAlternativeCarrier(rec.Payload)
+			if err != nil {
+				return nil, err
+			}
+			h.Carriers = append(h.Carriers, ac)
+		}
+	}
+	return h, nil
+}
+
+func (h *Handover) record() (*Record, error) {
+	var records []*Record
+	if h.Request {
+		records = append(records, NewRecord(TNFWellKnown, TypeCollisionResolution, nil,
+			binary.BigEndian.AppendUint16(nil, h.Collision)))
+	}
+	for _, ac := range h.Carriers {
+		payload, err := ac.marshal()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, NewRecord(TNFWellKnown, TypeAlternativeCarrier, nil, payload))
+	}
+
+	payload := []byte{h.Version}
+	if len(records) > 0 {
+		inner, err := NewMessage(records...).Marshal()
+		if err != nil {
+			return nil, err
+		}
+		payload = append(payload, inner...)
+	}
+	typ := TypeHandoverSelect
+	if h.Request {
+		typ = TypeHandoverRequest
+	}
+	return NewRecord(TNFWellKnown, typ, nil, payload), nil
+}
+
+func (ac AlternativeCarrier) marshal() ([]byte, error) {
+	if ac.CarrierDataRef == "" || len(ac.CarrierDataRef) > 0xFF {
+		return nil, fmt.Errorf("ndef: invalid carrier data reference %q", ac.CarrierDataRef)
+	}
+	if len(ac.AuxDataRefs) > 0xFF {
+		return nil, fmt.Errorf("ndef: too many auxiliary data references")
+	}
+	out := []byte{byte(ac.PowerState & 0x03), byte(len(ac.CarrierDataRef))}
+	out = append(out, ac.CarrierDataRef...)
+	out = append(out, byte(len(ac.AuxDataRefs)))
+	for _, ref := range ac.AuxDataRefs {
+		if len(ref) > 0xFF {
+			return nil, fmt.Errorf("ndef: auxiliary data reference too long")
+		}
+		out = append(out, byte(len(ref)))
+		out = append(out, ref...)
+	}
+	return out, nil
+}
+
+func parseAlternativeCarrier(p []byte) (AlternativeCarrier, error) {
+	var ac AlternativeCarrier
+	errTrunc := fmt.Errorf("ndef: truncated alternative carrier record")
+	if len(p) < 2 {
+		return ac, errTrunc
+	}
+	ac.PowerState = CarrierPowerState(p[0] & 0x03)
+	n := int(p[1])
+	if len(p) < 3+n {
+		return ac, errTrunc
+	}
+	ac.CarrierDataRef = string(p[2 : 2+n])
+	pos := 2 + n
+	count := int(p[pos])
+	pos++
+	for i := 0; i < count; i++ {
+		if pos >= len(p) || pos+1+int(p[pos]) > len(p) {
+			return ac, errTrunc
+		}
+		ac.AuxDataRefs = append(ac.AuxDataRefs, string(p[pos+1:pos+1+int(p[pos])]))
+		pos += 1 + int(p[pos])
+	}
+	return ac, nil
+}
+
+// Extended Inquiry Response data types used in Bluetooth OOB records.
+const (
+	eirShortenedName = 0x08
+	eirCompleteName  = 0x09
+	eirClassOfDevice = 0x0D
+)
+
+// BluetoothOOB is the out-of-band pairing data of a Bluetooth BR/EDR device.
+type BluetoothOOB struct {
+	Address net.HardwareAddr // Device address, most significant byte first.
+	Name    string           // Local name of the device.
+	Class   uint32           // Class of device, lower 24 bits.
+}
+
+// NewBluetoothOOBRecord creates a Bluetooth OOB carrier configuration record
+// with the given record ID, referenced from alternative carrier records.
+func NewBluetoothOOBRecord(id string, oob BluetoothOOB) (*Record, error) {
+	if len(oob.Address) != 6 {
+		return nil, fmt.Errorf("ndef: bluetooth address must be 6 bytes")
+	}
+	if len(oob.Name) > 0xFE {
+		return nil, fmt.Errorf("ndef: bluetooth name too long")
+	}
+	payload := []byte{0, 0}
+	for i := 5; i >= 0; i-- {
+		payload = append(payload, oob.Address[i])
+	}
+	if oob.Name != "" {
+		payload = append(payload, byte(len(oob.Name)+1), eirCompleteName)
+		payload = append(payload, oob.Name...)
+	}
+	if oob.Class != 0 {
+		payload = append(payload, 4, eirClassOfDevice,
+			byte(oob.Class), byte(oob.Class>>8), byte(oob.Class>>16))
+	}
+	binary.LittleEndian.PutUint16(payload, uint16(len(payload)))
+
+	r, err := NewMIMERecord(MIMEBluetoothOOB, payload)
+	if err != nil {
+		return nil, err
+	}
+	r.ID = []byte(id)
+	return r, nil
+}
+
+// BluetoothOOB parses a Bluetooth OOB carrier configuration record.
+func (r *Record) BluetoothOOB() (*BluetoothOOB, error) {
+	if !r.isMIME(MIMEBluetoothOOB) {
+		return nil, fmt.Errorf("ndef: not a bluetooth oob record")
+	}
+	p := r.Payload
+	if len(p) < 8 {
+		return nil, fmt.Errorf("ndef: truncated bluetooth oob record")
+	}
+	n := int(binary.LittleEndian.Uint16(p))
+	if n < 8 || n > len(p) {
+		return nil, fmt.Errorf("ndef: invalid bluetooth oob data length %d", n)
+	}
+	oob := &BluetoothOOB{Address: make(net.HardwareAddr, 6)}
+	for i := 0; i < 6; i++ {
+		oob.Address[i] = p[7-i]
+	}
+	for eir := p[8:n]; len(eir) > 0; {
+		l := int(eir[0])
+		if l == 0 {
+			break
+		}
+		if l+1 > len(eir) {
+			return nil, fmt.Errorf("ndef: truncated bluetooth eir data")
+		}
+		typ, data := eir[1], eir[2:l+1]
+		switch typ {
+		case eirCompleteName:
+			oob.Name = string(data)
+		case eirShortenedName:
+			if oob.Name == "" {
+				oob.Name = string(data)
+			}
+		case eirClassOfDevice:
+			if len(data) == 3 {
+				oob.Class = uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+			}
+		}
+		eir = eir[l+1:]
+	}
+	return oob, nil
+}
+
+// NewBluetoothHandover creates a static Handover Select message for
+// tap-to-pair tags, offering the Bluetooth device described by oob.
+func NewBluetoothHandover(oob BluetoothOOB) (*Message, error) {
+	const ref = "0"
+	hs, err := NewHandoverSelect(AlternativeCarrier{PowerState: CarrierActive, CarrierDataRef: ref})
+	if err != nil {
+		return nil, err
+	}
+	bt, err := NewBluetoothOOBRecord(ref, oob)
+	if err != nil {
+		return nil, err
+	}
+	return NewMessage(hs, bt), nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestBluetoothHandover(t *testing.T) {
+	addr, _ := net.ParseMAC("00:0d:18:a0:4e:9c")
+	msg, err := NewBluetoothHandover(BluetoothOOB{Address: addr, Name: "Speaker", Class: 0x240404})
+	if err != nil {
+		t.Fatalf("NewBluetoothHandover() error = %v", err)
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var got Message
+	if err := got.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(got.Records) != 2 {
+		t.Fatalf("records = %d, want 2", len(got.Records))
+	}
+	hs, err := got.Records[0].Handover()
+	if err != nil {
+		t.Fatalf("Handover() error = %v", err)
+	}
+	if hs.Request || hs.Version != HandoverVersion || len(hs.Carriers) != 1 {
+		t.Fatalf("Handover() = %+v", hs)
+	}
+	if ac := hs.Carriers[0]; ac.PowerState != CarrierActive || ac.CarrierDataRef != string(got.Records[1].ID) {
+		t.Errorf("carrier = %+v, want active carrier referencing %q", ac, got.Records[1].ID)
+	}
+
+	oob, err := got.Records[1].BluetoothOOB()
+	if err != nil {
+		t.Fatalf("BluetoothOOB() error = %v", err)
+	}
+	if !bytes.Equal(oob.Address, addr) || oob.Name != "Speaker" || oob.Class != 0x240404 {
+		t.Errorf("BluetoothOOB() = %+v", oob)
+	}
+	if p := got.Records[1].Payload; p[2] != 0x9C || p[7] != 0x00 {
+		t.Errorf("address not encoded little-endian: % X", p[2:8])
+	}
+}
+
+func TestHandoverRequest(t *testing.T) {
+	r, err := NewHandoverRequest(0xBEEF, AlternativeCarrier{
+		PowerState:     CarrierActivating,
+		CarrierDataRef: "bt",
+		AuxDataRefs:    []string{"aux1", "aux2"},
+	})
+	if err != nil {
+		t.Fatalf("NewHandoverRequest() error = %v", err)
+	}
+	h, err := r.Handover()
+	if err != nil {
+		t.Fatalf("Handover() error = %v", err)
+	}
+	if !h.Request || h.Collision != 0xBEEF || len(h.Carriers) != 1 {
+		t.Fatalf("Handover() = %+v", h)
+	}
+	ac := h.Carriers[0]
+	if ac.PowerState != CarrierActivating || ac.CarrierDataRef != "bt" || len(ac.AuxDataRefs) != 2 || ac.AuxDataRefs[1] != "aux2" {
+		t.Errorf("carrier = %+v", ac)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// NewMIMERecord creates a media-type record. mediaType is validated as an
+// RFC 2046 media type and stored lower-cased without parameters.
+func NewMIMERecord(mediaType string, payload []byte) (*Record, error) {
+	mt, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return nil, fmt.Errorf("ndef: invalid media type %q: %w", mediaType, err)
+	}
+	if !strings.Contains(mt, "/") {
+		return nil, fmt.Errorf("ndef: media type %q lacks a subtype", mediaType)
+	}
+	return NewRecord(TNFMedia, []byte(mt), nil, payload), nil
+}
+
+// MIMEType returns the media type of a media-type record.
+func (r *Record) MIMEType() (string, error) {
+	if r.TNF != TNFMedia {
+		return "", fmt.Errorf("ndef: not a media-type record")
+	}
+	return strings.ToLower(string(r.Type)), nil
+}
+
+// isMIME reports whether r is a media-type record of mediaType.
+func (r *Record) isMIME(mediaType string) bool {
+	return r.TNF == TNFMedia && strings.EqualFold(string(r.Type), mediaType)
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVCardRecord(t *testing.T) {
+	want := Contact{
+		Name:         "Jane Doe",
+		Organization: "Happy, Inc.",
+		Phone:        "+3725555555",
+		Email:        "jane@example.com",
+		Note:         "line one\nline two",
+	}
+	r, err := NewVCardRecord(want)
+	if err != nil {
+		t.Fatalf("NewVCardRecord() error = %v", err)
+	}
+	if mt, _ := r.MIMEType(); mt != MIMEVCard {
+		t.Errorf("MIMEType() = %q, want %q", mt, MIMEVCard)
+	}
+	got, err := r.VCard()
+	if err != nil {
+		t.Fatalf("VCard() error = %v", err)
+	}
+	if *got != want {
+		t.Errorf("VCard() = %+v, want %+v", *got, want)
+	}
+}
+
+func TestParseVCardFolded(t *testing.T) {
+	data := "BEGIN:VCARD\r\nVERSION:2.1\r\nN:Doe;John\r\nitem1.EMAIL;TYPE=INTERNET:john@exa\r\n mple.com\r\nEND:VCARD\r\n"
+	c, err := ParseVCard([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseVCard() error = %v", err)
+	}
+	if c.Name != "Doe John" || c.Email != "john@example.com" {
+		t.Errorf("ParseVCard() = %+v", c)
+	}
+	if _, err := ParseVCard([]byte("FN:x")); err == nil {
+		t.Error("ParseVCard() accepted payload without BEGIN:VCARD")
+	}
+}
+
+func TestWiFiRecord(t *testing.T) {
+	want := WiFiCredential{
+		SSID:           "happy-net",
+		AuthType:       WiFiAuthWPA2Personal,
+		EncryptionType: WiFiEncryptionAES,
+		NetworkKey:     "secret-passphrase",
+	}
+	r, err := NewWiFiRecord(want)
+	if err != nil {
+		t.Fatalf("NewWiFiRecord() error = %v", err)
+	}
+	got, err := r.WiFi()
+	if err != nil {
+		t.Fatalf("WiFi() error = %v", err)
+	}
+	if got.SSID != want.SSID || got.AuthType != want.AuthType || got.EncryptionType != want.EncryptionType || got.NetworkKey != want.NetworkKey {
+		t.Errorf("WiFi() = %+v, want %+v", got, want)
+	}
+	if got.MAC.String() != "ff:ff:ff:ff:ff:ff" {
+		t.Errorf("MAC = %s, want broadcast", got.MAC)
+	}
+
+	if _, err := NewWiFiRecord(WiFiCredential{SSID: strings.Repeat("x", 33)}); err == nil {
+		t.Error("NewWiFiRecord() accepted a 33 byte ssid")
+	}
+	if _, err := NewMIMERecord("not a type", nil); err == nil {
+		t.Error("NewMIMERecord() accepted an invalid media type")
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package ndef implements the NFC Data Exchange Format (NDEF) as specified by
+// the NFC Forum. It provides encoding and decoding of NDEF messages and
+// records together with helpers for the commonly used record types.
+package ndef
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// TNF is the Type Name Format of a record, describing how its type is to be
+// interpreted.
+type TNF uint8
+
+const (
+	TNFEmpty       TNF = 0x00 // Empty record.
+	TNFWellKnown   TNF = 0x01 // NFC Forum well-known type (RTD).
+	TNFMedia       TNF = 0x02 // Media type as defined in RFC 2046.
+	TNFAbsoluteURI TNF = 0x03 // Absolute URI as defined in RFC 3986.
+	TNFExternal    TNF = 0x04 // NFC Forum external type.
+	TNFUnknown     TNF = 0x05 // Unknown type.
+	TNFUnchanged   TNF = 0x06 // Continuation of a chunked payload.
+	TNFReserved    TNF = 0x07 // Reserved.
+)
+
+// Record header flags.
+const (
+	flagMB  = 0x80 // Message begin.
+	flagME  = 0x40 // Message end.
+	flagCF  = 0x20 // Chunk flag.
+	flagSR  = 0x10 // Short record.
+	flagIL  = 0x08 // ID length present.
+	maskTNF = 0x07
+)
+
+// Record is a single NDEF record.
+type Record struct {
+	TNF     TNF
+	Type    []byte
+	ID      []byte
+	Payload []byte
+}
+
+// NewRecord creates a new record.
+func NewRecord(tnf TNF, typ, id, payload []byte) *Record {
+	return &Record{TNF: tnf, Type: typ, ID: id, Payload: payload}
+}
+
+// Message is an NDEF message, an ordered list of records.
+type Message struct {
+	Records []*Record
+}
+
+// NewMessage creates a new message from records.
+func NewMessage(records ...*Record) *Message {
+	return &Message{Records: records}
+}
+
+// Marshal serializes the message. Records with a payload shorter than 256
+// bytes are encoded as short records.
+func (m *Message) Marshal() ([]byte, error) {
+	if len(m.Records) == 0 {
+		return nil, fmt.Errorf("ndef: empty message")
+	}
+	var out []byte
+	for i, r := range m.Records {
+		var err error
+		out, err = r.appendTo(out, i == 0, i == len(m.Records)-1)
+		if err != nil {
+			return nil, fmt.Errorf("ndef: record %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
+
+// Unmarshal sets the message from its serialized form. Chunked records are
+// reassembled into a single record.
+func (m *Message) Unmarshal(data []byte) error {
+	m.Records = nil
+	var chunked *Record
+	for pos := 0; pos < len(data); {
+		h, err := parseHeader(data[pos:])
+		if err != nil {
+			return fmt.Errorf("ndef: record at offset %d: %w", pos, err)
+		}
+		if pos == 0 && h.flags&flagMB == 0 {
+			return fmt.Errorf("ndef: first record lacks message begin flag")
+		}
+		if pos > 0 && h.flags&flagMB != 0 {
+			return fmt.Errorf("ndef: message begin flag at offset %d", pos)
+		}
+		rec := h.record(data[pos:])
+		pos += h.size
+
+		switch {
+		case chunked != nil:
+			if rec.TNF != TNFUnchanged || len(rec.Type) != 0 || len(rec.ID) != 0 {
+				return fmt.Errorf("ndef: invalid middle or terminating chunk at offset %d", pos-h.size)
+			}
+			chunked.Payload = append(chunked.Payload, rec.Payload...)
+			if h.flags&flagCF == 0 {
+				m.Records = append(m.Records, chunked)
+				chunked = nil
+			}
+		case rec.TNF == TNFUnchanged:
+			return fmt.Errorf("ndef: unchanged type outside of a chunked record")
+		case h.flags&flagCF != 0:
+			chunked = rec
+		default:
+			m.Records = append(m.Records, rec)
+		}
+
+		if h.flags&flagME != 0 {
+			if pos != len(data) {
+				return fmt.Errorf("ndef: %d bytes after message end", len(data)-pos)
+			}
+			if chunked != nil {
+				return fmt.Errorf("ndef: message ends inside a chunked record")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("ndef: message end flag missing")
+}
+
+// Marshal serializes the record as a single record message.
+func (r *Record) Marshal() ([]byte, error) {
+	return NewMessage(r).Marshal()
+}
+
+func (r *Record) appendTo(out []byte, mb, me bool) ([]byte, error) {
+	if r.TNF > TNFReserved {
+		return nil, fmt.Errorf("invalid tnf %d", r.TNF)
+	}
+	if len(r.Type) > 0xFF {
+		return nil, fmt.Errorf("type longer than 255 bytes")
+	}
+	if len(r.ID) > 0xFF {
+		return nil, fmt.Errorf("id longer than 255 bytes")
+	}
+	if uint64(len(r.Payload)) > 0xFFFFFFFF {
+		return nil, fmt.Errorf("payload longer than 2^32-1 bytes")
+	}
+
+	flags := byte(r.TNF)
+	if mb {
+		flags |= flagMB
+	}
+	if me {
+		flags |= flagME
+	}
+	short := len(r.Payload) <= 0xFF
+	if short {
+		flags |= flagSR
+	}
+	if len(r.ID) > 0 {
+		flags |= flagIL
+	}
+
+	out = append(out, flags, byte(len(r.Type)))
+	if short {
+		out = append(out, byte(len(r.Payload)))
+	} else {
+		out = binary.BigEndian.AppendUint32(out, uint32(len(r.Payload)))
+	}
+	if len(r.ID) > 0 {
+		out = append(out, byte(len(r.ID)))
+	}
+	out = append(out, r.Type...)
+	out = append(out, r.ID...)
+	out = append(out, r.Payload...)
+	return out, nil
+}
+
+// header is a decoded record header.
+type header struct {
+	flags      byte
+	typeLen    int
+	idLen      int
+	payloadLen int
+	headerLen  int
+	size       int // Total size of the record.
+}
+
+func parseHeader(data []byte) (header, error) {
+	var h header
+	if len(data) < 3 {
+		return h, fmt.Errorf("truncated header")
+	}
+	h.flags = data[0]
+	h.typeLen = int(data[1])
+	pos := 2
+	if h.flags&flagSR != 0 {
+		h.payloadLen = int(data[pos])
+		pos++
+	} else {
+		if len(data) < pos+4 {
+			return h, fmt.Errorf("truncated payload length")
+		}
+		h.payloadLen = int(binary.BigEndian.Uint32(data[pos:]))
+		pos += 4
+	}
+	if h.flags&flagIL != 0 {
+		if len(data) < pos+1 {
+			return h, fmt.Errorf("truncated id length")
+		}
+		h.idLen = int(data[pos])
+		pos++
+	}
+	h.headerLen = pos
+	h.size = pos + h.typeLen + h.idLen + h.payloadLen
+	if h.size < pos || h.size > len(data) {
+		return h, fmt.Errorf("record length %d exceeds available %d bytes", h.size, len(data))
+	}
+	return h, nil
+}
+
+func (h header) record(data []byte) *Record {
+	pos := h.headerLen
+	r := &Record{TNF: TNF(h.flags & maskTNF)}
+	r.Type = clone(data[pos : pos+h.typeLen])
+	pos += h.typeLen
+	r.ID = clone(data[pos : pos+h.idLen])
+	pos += h.idLen
+	r.Payload = clone(data[pos : pos+h.payloadLen])
+	return r
+}
+
+func clone(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
@@ -0,0 +1,73 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMessageMarshal(t *testing.T) {
+	msg := NewMessage(
+		NewURIRecord("https://happy-sdk.com"),
+		NewRecord(TNFMedia, []byte("text/plain"), []byte("1"), bytes.Repeat([]byte("a"), 300)),
+	)
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	wantFirst := []byte{0x91, 0x01, 0x0E, 'U', 0x04}
+	if !bytes.HasPrefix(data, wantFirst) {
+		t.Errorf("first record header = % X, want % X", data[:5], wantFirst)
+	}
+
+	var got Message
+	if err := got.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(got.Records) != 2 {
+		t.Fatalf("Unmarshal() records = %d, want 2", len(got.Records))
+	}
+	for i, r := range msg.Records {
+		g := got.Records[i]
+		if g.TNF != r.TNF || !bytes.Equal(g.Type, r.Type) || !bytes.Equal(g.ID, r.ID) || !bytes.Equal(g.Payload, r.Payload) {
+			t.Errorf("record %d = %+v, want %+v", i, g, r)
+		}
+	}
+}
+
+func TestMessageUnmarshalChunked(t *testing.T) {
+	data := []byte{
+		0xB2, 0x0A, 0x02, 't', 'e', 'x', 't', '/', 'p', 'l', 'a', 'i', 'n', 'a', 'b',
+		0x36, 0x00, 0x02, 'c', 'd',
+		0x56, 0x00, 0x01, 'e',
+	}
+	var msg Message
+	if err := msg.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(msg.Records) != 1 || string(msg.Records[0].Payload) != "abcde" {
+		t.Errorf("Unmarshal() = %+v, want one record with payload abcde", msg.Records)
+	}
+}
+
+func TestMessageUnmarshalInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"missing begin": {0x51, 0x01, 0x01, 'U', 0x00},
+		"missing end":   {0x91, 0x01, 0x01, 'U', 0x00},
+		"truncated":     {0xD1, 0x01, 0x05, 'U', 0x00},
+		"trailing":      {0xD1, 0x01, 0x01, 'U', 0x00, 0x00},
+		"bad chunk":     {0xB2, 0x01, 0x01, 'x', 'a', 0x52, 0x01, 0x01, 'x', 'b'},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			var msg Message
+			if err := msg.Unmarshal(data); err == nil {
+				t.Errorf("Unmarshal(% X) succeeded", data)
+			}
+		})
+	}
+}
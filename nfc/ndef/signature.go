@@ -0,0 +1,337 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// TypeSignature is the record type of the NFC Forum Signature well-known
+// type.
+var TypeSignature = []byte("Sig")
+
+// signatureVersion is the Signature RTD version implemented, 2.0.
+const signatureVersion = 0x20
+
+// SignatureType is the algorithm of a signature record.
+type SignatureType uint8
+
+const (
+	SignatureNone             SignatureType = 0x00 // Marks the start of a signed range, without signature.
+	SignatureRSAPSS1024       SignatureType = 0x01
+	SignatureRSAPKCS1v15_1024 SignatureType = 0x02
+	SignatureDSA1024          SignatureType = 0x03
+	SignatureECDSAP192        SignatureType = 0x04
+	SignatureRSAPSS2048       SignatureType = 0x05
+	SignatureRSAPKCS1v15_2048 SignatureType = 0x06
+	SignatureDSA2048          SignatureType = 0x07
+	SignatureECDSAP224        SignatureType = 0x08
+	SignatureECDSAK233        SignatureType = 0x09
+	SignatureECDSAB233        SignatureType = 0x0A
+	SignatureECDSAP256        SignatureType = 0x0B
+)
+
+// HashSHA256 is the hash type of signature records, the only one defined.
+const HashSHA256 = 0x02
+
+// CertificateFormat is the format of the certificates of a signature
+// record.
+type CertificateFormat uint8
+
+const (
+	CertificateX509 CertificateFormat = 0x00
+	CertificateM2M  CertificateFormat = 0x01
+)
+
+// Errors of signature verification.
+var (
+	ErrUnsigned          = errors.New("ndef: message is not signed")
+	ErrUnsignedRecords   = errors.New("ndef: records follow the last signature")
+	ErrSignatureMismatch = errors.New("ndef: signature verification failed")
+)
+
+// Signature is the payload of a signature record. It signs the records
+// preceding it up to the previous signature record or the start of the
+// message.
+type Signature struct {
+	Type  SignatureType
+	Hash  uint8  // Hash algorithm, HashSHA256.
+	Value []byte // Signature; ECDSA signatures are ASN.1 DER encoded.
+	URI   string // Location of the signature when not embedded in Value.
+
+	CertificateFormat CertificateFormat
+	Certificates      [][]byte // Certificate chain, signer first.
+	CertificateURI    string   // Location of the next certificate of the chain, if any.
+}
+
+// Marshal encodes the signature as a record payload.
+func (s *Signature) Marshal() ([]byte, error) {
+	if len(s.Certificates) > 0x0F {
+		return nil, fmt.Errorf("ndef: %d certificates exceed the limit of 15", len(s.Certificates))
+	}
+	out := []byte{signatureVersion}
+	field := []byte(s.URI)
+	sigType := byte(s.Type) & 0x7F
+	if s.URI != "" {
+		sigType |= 0x80
+	} else {
+		field = s.Value
+	}
+	if len(field) > 0xFFFF {
+		return nil, fmt.Errorf("ndef: signature longer than 65535 bytes")
+	}
+	out = append(out, sigType, s.Hash)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(field)))
+	out = append(out, field...)
+
+	certs := byte(s.CertificateFormat&0x07)<<4 | byte(len(s.Certificates))
+	if s.CertificateURI != "" {
+		certs |= 0x80
+	}
+	out = append(out, certs)
+	for _, c := range s.Certificates {
+		if len(c) > 0xFFFF {
+			return nil, fmt.Errorf("ndef: certificate longer than 65535 bytes")
+		}
+		out = binary.BigEndian.AppendUint16(out, uint16(len(c)))
+		out = append(out, c...)
+	}
+	if s.CertificateURI != "" {
+		out = binary.BigEndian.AppendUint16(out, uint16(len(s.CertificateURI)))
+		out = append(out, s.CertificateURI...)
+	}
+	return out, nil
+}
+
+// ParseSignature decodes the payload of a signature record.
+func ParseSignature(payload []byte) (*Signature, error) {
+	b := payload
+	take := func(n int) ([]byte, error) {
+		if len(b) < n {
+			return nil, fmt.Errorf("ndef: truncated signature payload")
+		}
+		v := b[:n]
+		b = b[n:]
+		return v, nil
+	}
+	takeField := func() ([]byte, error) {
+		n, err := take(2)
+		if err != nil {
+			return nil, err
+		}
+		return take(int(binary.BigEndian.Uint16(n)))
+	}
+
+	head, err := take(3)
+	if err != nil {
+		return nil, err
+	}
+	if head[0]>>4 != signatureVersion>>4 {
+		return nil, fmt.Errorf("ndef: unsupported signature version 0x%02X", head[0])
+	}
+	s := &Signature{Type: SignatureType(head[1] & 0x7F), Hash: head[2]}
+	field, err := takeField()
+	if err != nil {
+		return nil, err
+	}
+	if head[1]&0x80 != 0 {
+		s.URI = string(field)
+	} else {
+		s.Value = clone(field)
+	}
+	if s.Type == SignatureNone {
+		return s, nil // A start marker carries no certificate chain.
+	}
+
+	certs, err := take(1)
+	if err != nil {
+		return nil, err
+	}
+	s.CertificateFormat = CertificateFormat(certs[0] >> 4 & 0x07)
+	for i := 0; i < int(certs[0]&0x0F); i++ {
+		c, err := takeField()
+		if err != nil {
+			return nil, err
+		}
+		s.Certificates = append(s.Certificates, clone(c))
+	}
+	if certs[0]&0x80 != 0 {
+		uri, err := takeField()
+		if err != nil {
+			return nil, err
+		}
+		s.CertificateURI = string(uri)
+	}
+	return s, nil
+}
+
+// signedData returns the data signed by a signature record: the type, ID
+// and payload of each record, concatenated.
+func signedData(records []*Record) []byte {
+	var data []byte
+	for _, r := range records {
+		data = append(append(append(data, r.Type...), r.ID...), r.Payload...)
+	}
+	return data
+}
+
+func isSignature(r *Record) bool {
+	return r.TNF == TNFWellKnown && string(r.Type) == string(TypeSignature)
+}
+
+// Sign appends a signature record signing the records added since the last
+// signature record with signer, an ECDSA P-256 or P-224 key or an RSA 2048
+// key used with PKCS #1 v1.5. The X.509 certificates of the chain, signer
+// first, are embedded in the record when given.
+func (m *Message) Sign(signer crypto.Signer, certs ...*x509.Certificate) error {
+	start := 0
+	for i, r := range m.Records {
+		if isSignature(r) {
+			start = i + 1
+		}
+	}
+	if start == len(m.Records) {
+		return fmt.Errorf("ndef: no records to sign")
+	}
+	s := &Signature{Hash: HashSHA256, CertificateFormat: CertificateX509}
+	switch pub := signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			s.Type = SignatureECDSAP256
+		case elliptic.P224():
+			s.Type = SignatureECDSAP224
+		default:
+			return fmt.Errorf("ndef: unsupported ecdsa curve %s", pub.Curve.Params().Name)
+		}
+	case *rsa.PublicKey:
+		if pub.N.BitLen() != 2048 {
+			return fmt.Errorf("ndef: unsupported rsa key of %d bits", pub.N.BitLen())
+		}
+		s.Type = SignatureRSAPKCS1v15_2048
+	default:
+		return fmt.Errorf("ndef: unsupported signer key %T", pub)
+	}
+	digest := sha256.Sum256(signedData(m.Records[start:]))
+	var err error
+	if s.Value, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+		return fmt.Errorf("ndef: sign: %w", err)
+	}
+	for _, c := range certs {
+		s.Certificates = append(s.Certificates, c.Raw)
+	}
+	payload, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+	m.Records = append(m.Records, NewRecord(TNFWellKnown, TypeSignature, nil, payload))
+	return nil
+}
+
+// Verify checks the signature records of the message. Each must carry an
+// embedded signature verifying with the key of its first certificate, and
+// the certificates must chain to opts.Roots; a nil opts uses the system
+// roots. Records following the last signature fail with
+// ErrUnsignedRecords, messages without signature with ErrUnsigned.
+func (m *Message) Verify(opts *x509.VerifyOptions) error {
+	return m.verify(func(s *Signature) (crypto.PublicKey, error) {
+		if s.CertificateFormat != CertificateX509 || len(s.Certificates) == 0 {
+			return nil, fmt.Errorf("ndef: signature without x509 certificate")
+		}
+		leaf, err := x509.ParseCertificate(s.Certificates[0])
+		if err != nil {
+			return nil, fmt.Errorf("ndef: signer certificate: %w", err)
+		}
+		vo := x509.VerifyOptions{KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+		if opts != nil {
+			vo = *opts
+		}
+		if vo.Intermediates == nil {
+			vo.Intermediates = x509.NewCertPool()
+		}
+		for _, der := range s.Certificates[1:] {
+			c, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("ndef: chain certificate: %w", err)
+			}
+			vo.Intermediates.AddCert(c)
+		}
+		if _, err := leaf.Verify(vo); err != nil {
+			return nil, fmt.Errorf("ndef: signer certificate: %w", err)
+		}
+		return leaf.PublicKey, nil
+	})
+}
+
+// VerifyKey checks the signature records of the message against pub,
+// ignoring embedded certificates, as described for Verify.
+func (m *Message) VerifyKey(pub crypto.PublicKey) error {
+	return m.verify(func(*Signature) (crypto.PublicKey, error) { return pub, nil })
+}
+
+func (m *Message) verify(key func(s *Signature) (crypto.PublicKey, error)) error {
+	start, signed := 0, false
+	for i, r := range m.Records {
+		if !isSignature(r) {
+			continue
+		}
+		s, err := ParseSignature(r.Payload)
+		if err != nil {
+			return fmt.Errorf("ndef: record %d: %w", i, err)
+		}
+		records := m.Records[start:i]
+		start = i + 1
+		if s.Type == SignatureNone {
+			continue
+		}
+		if s.URI != "" {
+			return fmt.Errorf("ndef: record %d: signature by reference to %s is not supported", i, s.URI)
+		}
+		if s.Hash != HashSHA256 {
+			return fmt.Errorf("ndef: record %d: unsupported hash type 0x%02X", i, s.Hash)
+		}
+		pub, err := key(s)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		digest := sha256.Sum256(signedData(records))
+		if !verifySignature(s, pub, digest[:]) {
+			return fmt.Errorf("%w: record %d", ErrSignatureMismatch, i)
+		}
+		signed = true
+	}
+	switch {
+	case !signed:
+		return ErrUnsigned
+	case start < len(m.Records):
+		return ErrUnsignedRecords
+	}
+	return nil
+}
+
+func verifySignature(s *Signature, pub crypto.PublicKey, digest []byte) bool {
+	switch s.Type {
+	case SignatureECDSAP192, SignatureECDSAP224, SignatureECDSAP256:
+		k, ok := pub.(*ecdsa.PublicKey)
+		return ok && ecdsa.VerifyASN1(k, digest, s.Value)
+	case SignatureRSAPKCS1v15_1024, SignatureRSAPKCS1v15_2048:
+		k, ok := pub.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, s.Value) == nil
+	case SignatureRSAPSS1024, SignatureRSAPSS2048:
+		k, ok := pub.(*rsa.PublicKey)
+		return ok && rsa.VerifyPSS(k, crypto.SHA256, digest, s.Value, nil) == nil
+	default:
+		return false
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSignatureMarshal(t *testing.T) {
+	tests := []*Signature{
+		{Type: SignatureNone},
+		{Type: SignatureECDSAP256, Hash: HashSHA256, Value: []byte{1, 2, 3}, Certificates: [][]byte{{4}, {5, 6}}},
+		{Type: SignatureRSAPSS2048, Hash: HashSHA256, URI: "https://example.com/sig", CertificateURI: "https://example.com/ca"},
+	}
+	for _, s := range tests {
+		payload, err := s.Marshal()
+		if err != nil {
+			t.Fatalf("Marshal(%+v) error = %v", s, err)
+		}
+		got, err := ParseSignature(payload)
+		if err != nil {
+			t.Fatalf("ParseSignature(%X) error = %v", payload, err)
+		}
+		if !reflect.DeepEqual(got, s) {
+			t.Errorf("ParseSignature(%X) = %+v, want %+v", payload, got, s)
+		}
+	}
+	for _, payload := range [][]byte{nil, {0x10, 0, 0, 0, 0}, {0x20, 0x0B, 0x02, 0x00, 0x05, 1}} {
+		if _, err := ParseSignature(payload); err == nil {
+			t.Errorf("ParseSignature(%X) succeeded", payload)
+		}
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tag signer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	opts := &x509.VerifyOptions{Roots: roots}
+
+	msg := NewMessage(NewURIRecord("https://example.com"))
+	if err := msg.Sign(key, cert); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := NewMessage()
+	if err := parsed.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := parsed.Verify(opts); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if err := parsed.VerifyKey(&key.PublicKey); err != nil {
+		t.Errorf("VerifyKey() error = %v", err)
+	}
+	if err := parsed.Verify(&x509.VerifyOptions{Roots: x509.NewCertPool()}); err == nil {
+		t.Error("Verify() accepted an untrusted certificate")
+	}
+
+	parsed.Records[0].Payload[1] ^= 0xFF
+	if err := parsed.Verify(opts); !errors.Is(err, ErrSignatureMismatch) {
+		t.Errorf("Verify() of a tampered record error = %v, want ErrSignatureMismatch", err)
+	}
+	parsed.Records[0].Payload[1] ^= 0xFF
+
+	parsed.Records = append(parsed.Records, NewURIRecord("https://evil.example"))
+	if err := parsed.Verify(opts); !errors.Is(err, ErrUnsignedRecords) {
+		t.Errorf("Verify() with a trailing record error = %v, want ErrUnsignedRecords", err)
+	}
+	if err := NewMessage(NewURIRecord("x")).VerifyKey(&key.PublicKey); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("VerifyKey() of an unsigned message error = %v, want ErrUnsigned", err)
+	}
+}
+
+func TestSignRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := NewMessage(NewURIRecord("a"))
+	if err := msg.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	msg.Records = append(msg.Records, NewURIRecord("b"))
+	if err := msg.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Records) != 4 {
+		t.Fatalf("%d records, want 4", len(msg.Records))
+	}
+	if err := msg.VerifyKey(&key.PublicKey); err != nil {
+		t.Errorf("VerifyKey() error = %v", err)
+	}
+	if err := msg.Sign(key); err == nil {
+		t.Error("Sign() without new records succeeded")
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"fmt"
+	"unicode/utf16"
+)
+
+// TypeText is the record type of the NFC Forum Text well-known type.
+var TypeText = []byte("T")
+
+// NewTextRecord creates a UTF-8 text record for text in the language lang,
+// an IANA language code such as "en" or "en-US".
+func NewTextRecord(lang, text string) (*Record, error) {
+	if len(lang) == 0 || len(lang) > 0x3F {
+		return nil, fmt.Errorf("ndef: invalid text language code %q", lang)
+	}
+	payload := append(append([]byte{byte(len(lang))}, lang...), text...)
+	return NewRecord(TNFWellKnown, TypeText, nil, payload), nil
+}
+
+// Text returns the text and language code of a text record. UTF-16 text is
+// converted to UTF-8.
+func (r *Record) Text() (text, lang string, err error) {
+	if r.TNF != TNFWellKnown || string(r.Type) != string(TypeText) {
+		return "", "", fmt.Errorf("ndef: not a text record")
+	}
+	if len(r.Payload) == 0 {
+		return "", "", fmt.Errorf("ndef: empty text payload")
+	}
+	status := r.Payload[0]
+	n := int(status & 0x3F)
+	if len(r.Payload) < 1+n {
+		return "", "", fmt.Errorf("ndef: text language code exceeds the payload")
+	}
+	lang, body := string(r.Payload[1:1+n]), r.Payload[1+n:]
+	if status&0x80 == 0 {
+		return string(body), lang, nil
+	}
+	if len(body)%2 != 0 {
+		return "", "", fmt.Errorf("ndef: odd length utf-16 text")
+	}
+	big := true
+	if len(body) >= 2 && body[0] == 0xFF && body[1] == 0xFE {
+		big, body = false, body[2:]
+	} else if len(body) >= 2 && body[0] == 0xFE && body[1] == 0xFF {
+		body = body[2:]
+	}
+	u := make([]uint16, len(body)/2)
+	for i := range u {
+		if big {
+			u[i] = uint16(body[2*i])<<8 | uint16(body[2*i+1])
+		} else {
+			u[i] = uint16(body[2*i+1])<<8 | uint16(body[2*i])
+		}
+	}
+	return string(utf16.Decode(u)), lang, nil
+}
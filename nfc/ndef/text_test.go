@@ -0,0 +1,38 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import "testing"
+
+func TestText(t *testing.T) {
+	r, err := NewTextRecord("en", "héllo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(r.Payload); got != "\x02enhéllo" {
+		t.Errorf("payload = %q", got)
+	}
+	tests := []struct {
+		payload    []byte
+		text, lang string
+		ok         bool
+	}{
+		{r.Payload, "héllo", "en", true},
+		{[]byte{0x82, 'd', 'e', 0xFE, 0xFF, 0x00, 'H', 0x00, 'i'}, "Hi", "de", true},
+		{[]byte{0x82, 'd', 'e', 0xFF, 0xFE, 'H', 0x00, 'i', 0x00}, "Hi", "de", true},
+		{[]byte{0x82, 'd', 'e', 0x00, 'H', 0x00}, "", "", false},
+		{[]byte{0x05, 'e', 'n'}, "", "", false},
+		{nil, "", "", false},
+	}
+	for _, tt := range tests {
+		text, lang, err := NewRecord(TNFWellKnown, TypeText, nil, tt.payload).Text()
+		if (err == nil) != tt.ok || text != tt.text || lang != tt.lang {
+			t.Errorf("Text() of %X = %q, %q, %v", tt.payload, text, lang, err)
+		}
+	}
+	if _, err := NewTextRecord("", "x"); err == nil {
+		t.Error("NewTextRecord() accepted an empty language code")
+	}
+}
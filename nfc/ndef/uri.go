@@ -0,0 +1,143 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// TypeURI is the record type of the NFC Forum URI well-known type.
+var TypeURI = []byte("U")
+
+// uriPrefixes is the URI identifier code table of the URI RTD, indexed by
+// identifier code.
+var uriPrefixes = [...]string{
+	0x00: "",
+	0x01: "http://www.",
+	0x02: "https://www.",
+	0x03: "http://",
+	0x04: "https://",
+	0x05: "tel:",
+	0x06: "mailto:",
+	0x07: "ftp://anonymous:anonymous@",
+	0x08: "ftp://ftp.",
+	0x09: "ftps://",
+	0x0A: "sftp://",
+	0x0B: "smb://",
+	0x0C: "nfs://",
+	0x0D: "ftp://",
+	0x0E: "dav://",
+	0x0F: "news:",
+	0x10: "telnet://",
+	0x11: "imap:",
+	0x12: "rtsp://",
+	0x13: "urn:",
+	0x14: "pop:",
+	0x15: "sip:",
+	0x16: "sips:",
+	0x17: "tftp:",
+	0x18: "btspp://",
+	0x19: "btl2cap://",
+	0x1A: "btgoep://",
+	0x1B: "tcpobex://",
+	0x1C: "irdaobex://",
+	0x1D: "file://",
+	0x1E: "urn:epc:id:",
+	0x1F: "urn:epc:tag:",
+	0x20: "urn:epc:pat:",
+	0x21: "urn:epc:raw:",
+	0x22: "urn:epc:",
+	0x23: "urn:nfc:",
+}
+
+// URIPrefix returns the URI prefix of an identifier code.
+func URIPrefix(code byte) (string, bool) {
+	if int(code) >= len(uriPrefixes) {
+		return "", false
+	}
+	return uriPrefixes[code], true
+}
+
+// EncodeURI returns the URI record payload for uri, abbreviated with the
+// longest matching prefix of the identifier code table.
+func EncodeURI(uri string) []byte {
+	code, best := 0, 0
+	for i, p := range uriPrefixes {
+		if len(p) > best && strings.HasPrefix(uri, p) {
+			code, best = i, len(p)
+		}
+	}
+	return append([]byte{byte(code)}, uri[best:]...)
+}
+
+// DecodeURI returns the URI encoded in a URI record payload.
+func DecodeURI(payload []byte) (string, error) {
+	if len(payload) == 0 {
+		return "", fmt.Errorf("ndef: empty uri payload")
+	}
+	prefix, ok := URIPrefix(payload[0])
+	if !ok {
+		return "", fmt.Errorf("ndef: reserved uri identifier code 0x%02X", payload[0])
+	}
+	return prefix + string(payload[1:]), nil
+}
+
+// NewURIRecord creates a URI record for uri without validation.
+func NewURIRecord(uri string) *Record {
+	return NewRecord(TNFWellKnown, TypeURI, nil, EncodeURI(uri))
+}
+
+// NewURL validates and normalizes rawURL and creates a URI record for it
+// using the optimal prefix byte. The URL must be absolute; surrounding
+// spaces are trimmed, the scheme and host are lower-cased and, for http(s)
+// URLs, the default port and a root path without query or fragment are
+// dropped, so equal URLs take the same bytes on the tag.
+func NewURL(rawURL string) (*Record, error) {
+	u, err := NormalizeURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewURIRecord(u), nil
+}
+
+// NormalizeURL validates rawURL as an absolute URL and returns it normalized
+// as described for NewURL.
+func NormalizeURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", fmt.Errorf("ndef: invalid url: %w", err)
+	}
+	if u.Scheme == "" {
+		return "", fmt.Errorf("ndef: url %q is not absolute", rawURL)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Opaque == "" {
+		if u.Host == "" && (u.Scheme == "http" || u.Scheme == "https") {
+			return "", fmt.Errorf("ndef: url %q has no host", rawURL)
+		}
+		u.Host = strings.ToLower(u.Host)
+		if port := u.Port(); u.Scheme == "http" && port == "80" || u.Scheme == "https" && port == "443" {
+			u.Host = strings.TrimSuffix(u.Host, ":"+port)
+		}
+		if (u.Scheme == "http" || u.Scheme == "https") && u.Path == "/" && u.RawQuery == "" && u.Fragment == "" {
+			u.Path, u.RawPath = "", ""
+		}
+	}
+	return u.String(), nil
+}
+
+// URI returns the URI of a URI or absolute URI record.
+func (r *Record) URI() (string, error) {
+	switch {
+	case r.TNF == TNFWellKnown && string(r.Type) == string(TypeURI):
+		return DecodeURI(r.Payload)
+	case r.TNF == TNFAbsoluteURI:
+		return string(r.Type), nil
+	default:
+		return "", fmt.Errorf("ndef: not a uri record")
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want []byte
+	}{
+		{"https://www.example.com", append([]byte{0x02}, "example.com"...)},
+		{"https://example.com", append([]byte{0x04}, "example.com"...)},
+		{"tel:+3725555555", append([]byte{0x05}, "+3725555555"...)},
+		{"urn:epc:id:sgtin:1", append([]byte{0x1E}, "sgtin:1"...)},
+		{"urn:nfc:ext:x", append([]byte{0x23}, "ext:x"...)},
+		{"geo:1,2", append([]byte{0x00}, "geo:1,2"...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			got := EncodeURI(tt.uri)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("EncodeURI() = % X, want % X", got, tt.want)
+			}
+			back, err := DecodeURI(got)
+			if err != nil || back != tt.uri {
+				t.Errorf("DecodeURI() = %q, %v; want %q", back, err, tt.uri)
+			}
+		})
+	}
+
+	if _, err := DecodeURI([]byte{0x24, 'x'}); err == nil {
+		t.Error("DecodeURI() accepted reserved identifier code")
+	}
+}
+
+func TestNewURL(t *testing.T) {
+	r, err := NewURL(" HTTPS://Example.COM/Path?q=1 ")
+	if err != nil {
+		t.Fatalf("NewURL() error = %v", err)
+	}
+	if got, _ := r.URI(); got != "https://example.com/Path?q=1" {
+		t.Errorf("URI() = %q, want normalized url", got)
+	}
+	if r.Payload[0] != 0x04 {
+		t.Errorf("prefix code = 0x%02X, want 0x04", r.Payload[0])
+	}
+
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"https://example.com/", "https://example.com"},
+		{"HTTP://Example.com:80/", "http://example.com"},
+		{"https://example.com:443/?q=1", "https://example.com/?q=1"},
+		{"https://example.com:8443/", "https://example.com:8443"},
+		{"mailto:Info@Example.com", "mailto:Info@Example.com"},
+	}
+	for _, tt := range tests {
+		if got, err := NormalizeURL(tt.raw); err != nil || got != tt.want {
+			t.Errorf("NormalizeURL(%q) = %q, %v; want %q", tt.raw, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"example.com", "https://", "http://%zz"} {
+		if _, err := NewURL(bad); err == nil {
+			t.Errorf("NewURL(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// Severity grades a diagnostic of Validate.
+type Severity uint8
+
+const (
+	// SeverityWarning marks encodings the specification allows but
+	// discourages, or which some readers handle poorly.
+	SeverityWarning Severity = iota + 1
+	// SeverityError marks violations of the specification.
+	SeverityError
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("severity(%d)", uint8(s))
+	}
+}
+
+// Diagnostic is a finding of Validate.
+type Diagnostic struct {
+	Severity Severity
+	Record   int // Index of the record in the message, -1 for the message as a whole.
+	Offset   int // Offset of the record in the message.
+	Message  string
+}
+
+// String returns the diagnostic as a single line.
+func (d Diagnostic) String() string {
+	if d.Record < 0 {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s: record %d at offset %d: %s", d.Severity, d.Record, d.Offset, d.Message)
+}
+
+// Diagnostics are the findings of Validate in message order.
+type Diagnostics []Diagnostic
+
+// Err returns an error listing the diagnostics when at least one is an
+// error, nil otherwise.
+func (ds Diagnostics) Err() error {
+	var errs []error
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			errs = append(errs, errors.New(d.String()))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("ndef: invalid message: %w", errors.Join(errs...))
+}
+
+// Validate checks data, the serialized form of an NDEF message, for
+// conformance with the NDEF specification and the record type definitions
+// of the well-known types it knows: the message begin and end flags, the
+// chunking of records, the short record flag against the payload length,
+// the type and ID fields allowed by each TNF and the payload of URI and
+// Text records. It reports every finding rather than stopping at the first,
+// unless the record framing itself is broken.
+func Validate(data []byte) Diagnostics {
+	var ds Diagnostics
+	report := func(sev Severity, rec, off int, format string, args ...any) {
+		ds = append(ds, Diagnostic{Severity: sev, Record: rec, Offset: off, Message: fmt.Sprintf(format, args...)})
+	}
+	if len(data) == 0 {
+		report(SeverityError, -1, 0, "empty message")
+		return ds
+	}
+
+	chunked := false
+	ended := false
+	pos, i := 0, 0
+	for ; pos < len(data); i++ {
+		if ended {
+			report(SeverityError, -1, pos, "%d bytes after the message end", len(data)-pos)
+			return ds
+		}
+		h, err := parseHeader(data[pos:])
+		if err != nil {
+			report(SeverityError, i, pos, "%v", err)
+			return ds
+		}
+		off := pos
+		pos += h.size
+		r := h.record(data[off:])
+		mb, me, cf := h.flags&flagMB != 0, h.flags&flagME != 0, h.flags&flagCF != 0
+
+		if i == 0 && !mb {
+			report(SeverityError, i, off, "first record lacks the message begin flag")
+		}
+		if i > 0 && mb {
+			report(SeverityError, i, off, "message begin flag on a record other than the first")
+		}
+		if h.flags&flagSR == 0 && h.payloadLen <= 0xFF {
+			report(SeverityWarning, i, off, "payload of %d bytes in the long record format", h.payloadLen)
+		}
+		if h.flags&flagIL != 0 && h.idLen == 0 {
+			report(SeverityWarning, i, off, "id length flag set with an empty id")
+		}
+
+		if chunked {
+			// Middle and terminating chunks.
+			if r.TNF != TNFUnchanged {
+				report(SeverityError, i, off, "chunk with tnf %d, want unchanged", r.TNF)
+			}
+			if h.typeLen != 0 || h.flags&flagIL != 0 {
+				report(SeverityError, i, off, "chunk with a type or id")
+			}
+		} else {
+			validateRecord(r, cf, func(sev Severity, format string, args ...any) {
+				report(sev, i, off, format, args...)
+			})
+		}
+		chunked = cf
+		if me {
+			ended = true
+			if cf {
+				report(SeverityError, i, off, "message ends inside a chunked record")
+			}
+		}
+	}
+	if !ended {
+		report(SeverityError, -1, pos, "message end flag missing")
+	}
+	return ds
+}
+
+// validateRecord checks the fields of a record which is not a middle or
+// terminating chunk. firstChunk is set for the first chunk of a chunked
+// record, whose payload is incomplete.
+func validateRecord(r *Record, firstChunk bool, report func(sev Severity, format string, args ...any)) {
+	switch r.TNF {
+	case TNFEmpty:
+		if len(r.Type) > 0 || len(r.ID) > 0 || len(r.Payload) > 0 {
+			report(SeverityError, "empty record with a type, id or payload")
+		}
+		return
+	case TNFUnknown:
+		if len(r.Type) > 0 {
+			report(SeverityError, "unknown type record with a type")
+		}
+		return
+	case TNFUnchanged:
+		report(SeverityError, "unchanged tnf outside of a chunked record")
+		return
+	case TNFReserved:
+		report(SeverityError, "reserved tnf")
+		return
+	}
+	if len(r.Type) == 0 {
+		report(SeverityError, "tnf %d requires a type", r.TNF)
+		return
+	}
+	for _, c := range r.Type {
+		if c < 0x20 || c > 0x7E {
+			report(SeverityError, "type %q contains characters other than printable ascii", r.Type)
+			return
+		}
+	}
+
+	switch r.TNF {
+	case TNFMedia:
+		if _, _, err := mime.ParseMediaType(string(r.Type)); err != nil || !strings.Contains(string(r.Type), "/") {
+			report(SeverityError, "invalid media type %q", r.Type)
+		}
+	case TNFExternal:
+		if _, err := normalizeExternalType(string(r.Type)); err != nil {
+			report(SeverityError, "invalid external type %q", r.Type)
+		}
+	case TNFWellKnown:
+		if firstChunk {
+			return
+		}
+		switch string(r.Type) {
+		case string(TypeURI):
+			if _, err := DecodeURI(r.Payload); err != nil {
+				report(SeverityError, "%v", strings.TrimPrefix(err.Error(), "ndef: "))
+			}
+		case string(TypeText):
+			if _, _, err := r.Text(); err != nil {
+				report(SeverityError, "%v", strings.TrimPrefix(err.Error(), "ndef: "))
+			} else if r.Payload[0]&0x40 != 0 {
+				report(SeverityError, "text status byte with the reserved bit set")
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		want []string // Diagnostics in order, matched by substring.
+	}{
+		{"uri", "D1010855046578616D706C65", nil},
+		{"empty", "", []string{"error: empty message"}},
+		{"no message begin", "51010855046578616D706C65", []string{"error: record 0 at offset 0: first record lacks"}},
+		{"no message end", "91010855046578616D706C65", []string{"error: message end flag missing"}},
+		{"trailing bytes", "D1010855046578616D706C65FF", []string{"bytes after the message end"}},
+		{"long format", "C1010000000855046578616D706C65", []string{"warning: record 0 at offset 0: payload of 8 bytes in the long record format"}},
+		{"second message begin", "9101015500D1010155FF", []string{"error: record 1 at offset 5: message begin flag", "error: record 1 at offset 5: reserved uri identifier code"}},
+		{"empty tnf with type", "D0010055", []string{"empty record with a type"}},
+		{"unknown tnf with type", "D5010058", []string{"unknown type record with a type"}},
+		{"reserved tnf", "D70000", []string{"reserved tnf"}},
+		{"unchanged alone", "D60000", []string{"unchanged tnf outside"}},
+		{"well-known without type", "D10000", []string{"tnf 1 requires a type"}},
+		{"bad media type", "D203006E6F6E", []string{"invalid media type"}},
+		{"bad external type", "D403006E6F6E", []string{"invalid external type"}},
+		{"chunked", "B1010255046176000162", []string{"message ends inside a chunked record"}},
+		{"chunked ok", "B101025504613600016256000163", nil},
+		{"chunk with type", "B101025504615601015562", []string{"chunk with a type"}},
+		{"text", "D101075402656E6869686F", nil},
+		{"text lang overflow", "D101035409656E", []string{"text language code exceeds"}},
+		{"truncated", "D1010A5504", []string{"error: record 0 at offset 0: record length"}},
+	}
+	for _, tt := range tests {
+		data, err := hex.DecodeString(tt.hex)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		ds := Validate(data)
+		if len(ds) != len(tt.want) {
+			t.Errorf("%s: Validate() = %v, want %d diagnostics", tt.name, ds, len(tt.want))
+			continue
+		}
+		for i, d := range ds {
+			if !strings.Contains(d.String(), tt.want[i]) {
+				t.Errorf("%s: diagnostic %d = %q, want %q", tt.name, i, d, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestDiagnosticsErr(t *testing.T) {
+	if err := (Diagnostics{{Severity: SeverityWarning, Record: 0, Message: "w"}}).Err(); err != nil {
+		t.Errorf("Err() of warnings = %v", err)
+	}
+	msg, _ := NewMessage(NewURIRecord("https://example.com"), NewRecord(TNFEmpty, nil, nil, nil)).Marshal()
+	if err := Validate(msg).Err(); err != nil {
+		t.Errorf("Validate() of a marshaled message = %v", err)
+	}
+	if err := Validate([]byte{0xD7, 0, 0}).Err(); err == nil || !strings.Contains(err.Error(), "reserved tnf") {
+		t.Errorf("Err() = %v", err)
+	}
+}
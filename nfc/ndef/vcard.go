@@ -0,0 +1,123 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MIMEVCard is the media type of vCard contact records.
+const MIMEVCard = "text/vcard"
+
+// Contact is the subset of a vCard commonly stored on tags.
+type Contact struct {
+	Name         string // Formatted name (FN).
+	Organization string
+	Title        string
+	Phone        string
+	Email        string
+	URL          string
+	Note         string
+}
+
+// NewVCardRecord creates a text/vcard record holding c as a vCard 3.0.
+func NewVCardRecord(c Contact) (*Record, error) {
+	if c.Name == "" {
+		return nil, fmt.Errorf("ndef: vcard requires a name")
+	}
+	var b strings.Builder
+	b.WriteString("BEGIN:VCARD\r\nVERSION:3.0\r\n")
+	line := func(prop, value string) {
+		if value != "" {
+			b.WriteString(prop + ":" + escapeVCard(value) + "\r\n")
+		}
+	}
+	line("FN", c.Name)
+	line("N", c.Name)
+	line("ORG", c.Organization)
+	line("TITLE", c.Title)
+	line("TEL", c.Phone)
+	line("EMAIL", c.Email)
+	line("URL", c.URL)
+	line("NOTE", c.Note)
+	b.WriteString("END:VCARD\r\n")
+	return NewMIMERecord(MIMEVCard, []byte(b.String()))
+}
+
+// VCard parses the contact of a text/vcard (or text/x-vcard) record.
+func (r *Record) VCard() (*Contact, error) {
+	if !r.isMIME(MIMEVCard) && !r.isMIME("text/x-vcard") {
+		return nil, fmt.Errorf("ndef: not a vcard record")
+	}
+	return ParseVCard(r.Payload)
+}
+
+// ParseVCard parses a vCard 2.1, 3.0 or 4.0 payload into a Contact.
+// Properties not represented by Contact are ignored.
+func ParseVCard(data []byte) (*Contact, error) {
+	// Unfold continuation lines (RFC 6350 section 3.2).
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\n ", "")
+	text = strings.ReplaceAll(text, "\n\t", "")
+
+	var c Contact
+	begun := false
+	for _, l := range strings.Split(text, "\n") {
+		if l == "" {
+			continue
+		}
+		prop, value, ok := strings.Cut(l, ":")
+		if !ok {
+			continue
+		}
+		name, _, _ := strings.Cut(prop, ";")
+		if _, after, grouped := strings.Cut(name, "."); grouped {
+			name = after
+		}
+		name = strings.ToUpper(name)
+		value = unescapeVCard(value)
+		switch name {
+		case "BEGIN":
+			begun = strings.EqualFold(value, "VCARD")
+		case "FN":
+			c.Name = value
+		case "N":
+			if c.Name == "" {
+				c.Name = strings.TrimSpace(strings.ReplaceAll(value, ";", " "))
+			}
+		case "ORG":
+			c.Organization = value
+		case "TITLE":
+			c.Title = value
+		case "TEL":
+			setOnce(&c.Phone, value)
+		case "EMAIL":
+			setOnce(&c.Email, value)
+		case "URL":
+			setOnce(&c.URL, value)
+		case "NOTE":
+			c.Note = value
+		}
+	}
+	if !begun {
+		return nil, fmt.Errorf("ndef: vcard lacks BEGIN:VCARD")
+	}
+	return &c, nil
+}
+
+func setOnce(dst *string, value string) {
+	if *dst == "" {
+		*dst = value
+	}
+}
+
+var (
+	vcardEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`, ",", `\,`, ";", `\;`)
+	vcardUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";")
+)
+
+func escapeVCard(s string) string   { return vcardEscaper.Replace(s) }
+func unescapeVCard(s string) string { return vcardUnescaper.Replace(s) }
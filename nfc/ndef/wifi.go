@@ -0,0 +1,165 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ndef
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// MIMEWiFi is the media type of Wi-Fi Simple Configuration records, as used
+// by Android Wi-Fi sharing.
+const MIMEWiFi = "application/vnd.wfa.wsc"
+
+// WiFiAuthType is a Wi-Fi Simple Configuration authentication type.
+type WiFiAuthType uint16
+
+const (
+	WiFiAuthOpen            WiFiAuthType = 0x0001
+	WiFiAuthWPAPersonal     WiFiAuthType = 0x0002
+	WiFiAuthShared          WiFiAuthType = 0x0004
+	WiFiAuthWPAEnterprise   WiFiAuthType = 0x0008
+	WiFiAuthWPA2Enterprise  WiFiAuthType = 0x0010
+	WiFiAuthWPA2Personal    WiFiAuthType = 0x0020
+	WiFiAuthWPAWPA2Personal WiFiAuthType = 0x0022
+)
+
+// WiFiEncryptionType is a Wi-Fi Simple Configuration encryption type.
+type WiFiEncryptionType uint16
+
+const (
+	WiFiEncryptionNone    WiFiEncryptionType = 0x0001
+	WiFiEncryptionWEP     WiFiEncryptionType = 0x0002
+	WiFiEncryptionTKIP    WiFiEncryptionType = 0x0004
+	WiFiEncryptionAES     WiFiEncryptionType = 0x0008
+	WiFiEncryptionAESTKIP WiFiEncryptionType = 0x000C
+)
+
+// Wi-Fi Simple Configuration attribute IDs.
+const (
+	wscAuthType       = 0x1003
+	wscCredential     = 0x100E
+	wscEncryptionType = 0x100F
+	wscMACAddress     = 0x1020
+	wscNetworkIndex   = 0x1026
+	wscNetworkKey     = 0x1027
+	wscSSID           = 0x1045
+	wscVersion        = 0x104A
+)
+
+// WiFiCredential is a Wi-Fi network credential.
+type WiFiCredential struct {
+	SSID           string
+	AuthType       WiFiAuthType
+	EncryptionType WiFiEncryptionType
+	NetworkKey     string
+	MAC            net.HardwareAddr // BSSID, broadcast address when unset.
+}
+
+// NewWiFiRecord creates an application/vnd.wfa.wsc record holding c.
+func NewWiFiRecord(c WiFiCredential) (*Record, error) {
+	if c.SSID == "" || len(c.SSID) > 32 {
+		return nil, fmt.Errorf("ndef: wifi ssid length %d out of range 1-32", len(c.SSID))
+	}
+	if len(c.NetworkKey) > 64 {
+		return nil, fmt.Errorf("ndef: wifi network key longer than 64 bytes")
+	}
+	if c.AuthType == 0 {
+		c.AuthType = WiFiAuthOpen
+	}
+	if c.EncryptionType == 0 {
+		c.EncryptionType = WiFiEncryptionNone
+	}
+	mac := c.MAC
+	if mac == nil {
+		mac = net.HardwareAddr{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	}
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("ndef: wifi mac address must be 6 bytes")
+	}
+
+	var cred []byte
+	cred = appendTLV(cred, wscNetworkIndex, []byte{0x01})
+	cred = appendTLV(cred, wscSSID, []byte(c.SSID))
+	cred = appendTLV(cred, wscAuthType, binary.BigEndian.AppendUint16(nil, uint16(c.AuthType)))
+	cred = appendTLV(cred, wscEncryptionType, binary.BigEndian.AppendUint16(nil, uint16(c.EncryptionType)))
+	cred = appendTLV(cred, wscNetworkKey, []byte(c.NetworkKey))
+	cred = appendTLV(cred, wscMACAddress, mac)
+
+	payload := appendTLV(nil, wscVersion, []byte{0x10})
+	payload = appendTLV(payload, wscCredential, cred)
+	return NewMIMERecord(MIMEWiFi, payload)
+}
+
+// WiFi parses the first credential of an application/vnd.wfa.wsc record.
+func (r *Record) WiFi() (*WiFiCredential, error) {
+	if !r.isMIME(MIMEWiFi) {
+		return nil, fmt.Errorf("ndef: not a wifi record")
+	}
+	return ParseWiFi(r.Payload)
+}
+
+// ParseWiFi parses the first credential of a Wi-Fi Simple Configuration
+// payload.
+func ParseWiFi(data []byte) (*WiFiCredential, error) {
+	attrs, err := parseTLVs(data)
+	if err != nil {
+		return nil, err
+	}
+	cred, ok := attrs[wscCredential]
+	if !ok {
+		return nil, fmt.Errorf("ndef: wifi payload has no credential")
+	}
+	fields, err := parseTLVs(cred)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &WiFiCredential{
+		SSID:       string(fields[wscSSID]),
+		NetworkKey: string(fields[wscNetworkKey]),
+	}
+	if v := fields[wscAuthType]; len(v) == 2 {
+		c.AuthType = WiFiAuthType(binary.BigEndian.Uint16(v))
+	}
+	if v := fields[wscEncryptionType]; len(v) == 2 {
+		c.EncryptionType = WiFiEncryptionType(binary.BigEndian.Uint16(v))
+	}
+	if v := fields[wscMACAddress]; len(v) == 6 {
+		c.MAC = net.HardwareAddr(v)
+	}
+	if c.SSID == "" {
+		return nil, fmt.Errorf("ndef: wifi credential has no ssid")
+	}
+	return c, nil
+}
+
+func appendTLV(out []byte, id uint16, value []byte) []byte {
+	out = binary.BigEndian.AppendUint16(out, id)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(value)))
+	return append(out, value...)
+}
+
+// parseTLVs parses Wi-Fi Simple Configuration attributes, keeping the first
+// occurrence of each ID.
+func parseTLVs(data []byte) (map[uint16][]byte, error) {
+	attrs := make(map[uint16][]byte)
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("ndef: truncated wifi attribute")
+		}
+		id := binary.BigEndian.Uint16(data)
+		n := int(binary.BigEndian.Uint16(data[2:]))
+		if len(data) < 4+n {
+			return nil, fmt.Errorf("ndef: wifi attribute 0x%04X exceeds payload", id)
+		}
+		if _, ok := attrs[id]; !ok {
+			attrs[id] = data[4 : 4+n]
+		}
+		data = data[4+n:]
+	}
+	return attrs, nil
+}
@@ -7,28 +7,221 @@
 // NFC tags, based on the specifications provided by NXP.
 package ntag
 
+import (
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/sckutils"
+)
+
 const (
 	// Constants defining page sizes, memory layout, etc.
 	PageSize = 4 // bytes
+	// UserMemoryStart is the first page of the user memory.
+	UserMemoryStart = 4
+	// CapabilityContainerPage is the page holding the capability container.
+	CapabilityContainerPage = 3
 	// ...
 )
 
-// NewTag initializes a new NTAG NFC tag representation.
-func NewTag(uid []byte) *Tag { return nil }
+// Model identifies a member of the NTAG21x family.
+type Model uint8
+
+const (
+	ModelUnknown Model = iota
+	ModelNTAG213
+	ModelNTAG215
+	ModelNTAG216
+)
+
+// String returns the product name of the model.
+func (m Model) String() string {
+	switch m {
+	case ModelNTAG213:
+		return "NTAG213"
+	case ModelNTAG215:
+		return "NTAG215"
+	case ModelNTAG216:
+		return "NTAG216"
+	default:
+		return "unknown"
+	}
+}
+
+// Pages returns the total number of pages of the model.
+func (m Model) Pages() int {
+	switch m {
+	case ModelNTAG213:
+		return 45
+	case ModelNTAG215:
+		return 135
+	case ModelNTAG216:
+		return 231
+	default:
+		return 0
+	}
+}
+
+// UserMemory returns the size of the user memory of the model in bytes.
+func (m Model) UserMemory() int {
+	switch m {
+	case ModelNTAG213:
+		return 144
+	case ModelNTAG215:
+		return 504
+	case ModelNTAG216:
+		return 888
+	default:
+		return 0
+	}
+}
+
+// NewTag initializes a new NTAG NFC tag representation communicating
+// through tr, typically a card connected through a PC/SC reader.
+func NewTag(uid []byte, tr apdu.Transceiver) *Tag {
+	return &Tag{uid: uid, tr: tr}
+}
 
 // Tag represents an NTAG NFC tag with its attributes.
 type Tag struct {
 	// Fields like UID, memory layout, etc.
+	uid   []byte
+	tr    apdu.Transceiver
+	model Model
+	ex    Exchanger
 }
 
+// UID returns the UID of the tag.
+func (t *Tag) UID() []byte { return t.uid }
+
 // ReadPage reads a specific page from the NFC tag.
-func (t *Tag) ReadPage(pageNumber int) (*Page, error) { return nil, nil }
+func (t *Tag) ReadPage(pageNumber int) (*Page, error) {
+	if pageNumber < 0 || pageNumber > 0xFF {
+		return nil, fmt.Errorf("page %d out of range", pageNumber)
+	}
+	resp, err := t.tr.Transmit([]byte{0xFF, 0xB0, 0x00, byte(pageNumber), PageSize})
+	if err != nil {
+		return nil, fmt.Errorf("read page %d: %w", pageNumber, err)
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		return nil, fmt.Errorf("read page %d: %w", pageNumber, err)
+	}
+	if len(resp)-2 < PageSize {
+		return nil, fmt.Errorf("read page %d: short response of %d bytes", pageNumber, len(resp)-2)
+	}
+	p := &Page{Number: pageNumber}
+	if err := p.Unmarshal(resp[:PageSize]); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
 
 // WritePage writes data to a specific page on the NFC tag.
-func (t *Tag) WritePage(pageNumber int, data []byte) error { return nil }
+func (t *Tag) WritePage(pageNumber int, data []byte) error {
+	if pageNumber < 0 || pageNumber > 0xFF {
+		return fmt.Errorf("page %d out of range", pageNumber)
+	}
+	if len(data) != PageSize {
+		return fmt.Errorf("page data must be %d bytes, got %d", PageSize, len(data))
+	}
+	cmd := append([]byte{0xFF, 0xD6, 0x00, byte(pageNumber), PageSize}, data...)
+	resp, err := t.tr.Transmit(cmd)
+	if err != nil {
+		return fmt.Errorf("write page %d: %w", pageNumber, err)
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		return fmt.Errorf("write page %d: %w", pageNumber, err)
+	}
+	return nil
+}
+
+// Model detects the model of the tag from the memory size announced in its
+// capability container.
+func (t *Tag) Model() (Model, error) {
+	if t.model != ModelUnknown {
+		return t.model, nil
+	}
+	cc, err := t.ReadPage(CapabilityContainerPage)
+	if err != nil {
+		return ModelUnknown, err
+	}
+	switch cc.Data[2] {
+	case 0x12:
+		t.model = ModelNTAG213
+	case 0x3E:
+		t.model = ModelNTAG215
+	case 0x6D:
+		t.model = ModelNTAG216
+	default:
+		return ModelUnknown, fmt.Errorf("unknown capability container size 0x%02X", cc.Data[2])
+	}
+	return t.model, nil
+}
 
 // CalculateTagCapacity returns the total memory capacity of the tag.
-func (t *Tag) CalculateTagCapacity() int { return 0 }
+func (t *Tag) CalculateTagCapacity() int {
+	m, err := t.Model()
+	if err != nil {
+		return 0
+	}
+	return m.Pages() * PageSize
+}
+
+// Dump reads the complete memory of the tag, reporting progress after each
+// page when progress is not nil.
+func (t *Tag) Dump(progress sckutils.ProgressFunc) ([]byte, error) {
+	m, err := t.Model()
+	if err != nil {
+		return nil, err
+	}
+	return t.readPages(0, m.Pages(), progress)
+}
+
+// ReadUserMemory reads the user memory of the tag, reporting progress after
+// each page when progress is not nil.
+func (t *Tag) ReadUserMemory(progress sckutils.ProgressFunc) ([]byte, error) {
+	m, err := t.Model()
+	if err != nil {
+		return nil, err
+	}
+	return t.readPages(UserMemoryStart, m.UserMemory()/PageSize, progress)
+}
+
+// WriteUserMemory writes data to the user memory of the tag starting at its
+// first page, reporting progress after each page when progress is not nil.
+// The last page is padded with zeros.
+func (t *Tag) WriteUserMemory(data []byte, progress sckutils.ProgressFunc) error {
+	m, err := t.Model()
+	if err != nil {
+		return err
+	}
+	if len(data) > m.UserMemory() {
+		return fmt.Errorf("%d bytes exceed the %d bytes user memory of %s", len(data), m.UserMemory(), m)
+	}
+	for done := 0; done < len(data); done += PageSize {
+		page := make([]byte, PageSize)
+		copy(page, data[done:])
+		if err := t.WritePage(UserMemoryStart+done/PageSize, page); err != nil {
+			return err
+		}
+		progress.Report(min(done+PageSize, len(data)), len(data))
+	}
+	return nil
+}
+
+func (t *Tag) readPages(first, count int, progress sckutils.ProgressFunc) ([]byte, error) {
+	total := count * PageSize
+	out := make([]byte, 0, total)
+	for i := 0; i < count; i++ {
+		p, err := t.ReadPage(first + i)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p.Data...)
+		progress.Report(len(out), total)
+	}
+	return out, nil
+}
 
 // VerifyIntegrity checks the integrity of the NFC tag data.
 func (t *Tag) VerifyIntegrity() bool { return false }
@@ -36,10 +229,23 @@ func (t *Tag) VerifyIntegrity() bool { return false }
 // Page represents a data page in the NTAG NFC tag.
 type Page struct {
 	// Fields representing page data and other attributes.
+	Number int
+	Data   []byte
 }
 
 // MarshalPage serializes a Page into a byte slice.
-func (p *Page) Marshal() ([]byte, error) { return nil, nil }
+func (p *Page) Marshal() ([]byte, error) {
+	if len(p.Data) != PageSize {
+		return nil, fmt.Errorf("page data must be %d bytes, got %d", PageSize, len(p.Data))
+	}
+	return append([]byte(nil), p.Data...), nil
+}
 
 // UnmarshalPage sets the Page fields from a byte slice.
-func (p *Page) Unmarshal(data []byte) error { return nil }
+func (p *Page) Unmarshal(data []byte) error {
+	if len(data) != PageSize {
+		return fmt.Errorf("page data must be %d bytes, got %d", PageSize, len(data))
+	}
+	p.Data = append([]byte(nil), data...)
+	return nil
+}
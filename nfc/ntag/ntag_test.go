@@ -0,0 +1,94 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ntag
+
+import (
+	"bytes"
+	"testing"
+)
+
+// fakeTag emulates an NTAG behind a PC/SC reader answering the READ BINARY
+// and UPDATE BINARY storage card pseudo-APDUs.
+type fakeTag struct {
+	mem     []byte
+	counter uint32
+	sig     []byte
+}
+
+func newFakeTag(m Model) *fakeTag {
+	f := &fakeTag{mem: make([]byte, m.Pages()*PageSize)}
+	cc := map[Model]byte{ModelNTAG213: 0x12, ModelNTAG215: 0x3E, ModelNTAG216: 0x6D}[m]
+	copy(f.mem[CapabilityContainerPage*PageSize:], []byte{0xE1, 0x10, cc, 0x00})
+	return f
+}
+
+func (f *fakeTag) Transmit(cmd []byte) ([]byte, error) {
+	page := int(cmd[3])
+	if page*PageSize >= len(f.mem) {
+		return []byte{0x6A, 0x82}, nil
+	}
+	switch cmd[1] {
+	case 0x00:
+		return f.native(cmd[5:])
+	case 0xB0:
+		return append(append([]byte(nil), f.mem[page*PageSize:(page+1)*PageSize]...), 0x90, 0x00), nil
+	case 0xD6:
+		copy(f.mem[page*PageSize:], cmd[5:9])
+		return []byte{0x90, 0x00}, nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+func TestTagUserMemoryProgress(t *testing.T) {
+	fake := newFakeTag(ModelNTAG215)
+	tag := NewTag([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, fake)
+
+	if m, err := tag.Model(); err != nil || m != ModelNTAG215 {
+		t.Fatalf("Model() = %v, %v; want NTAG215", m, err)
+	}
+	if got := tag.CalculateTagCapacity(); got != 540 {
+		t.Errorf("CalculateTagCapacity() = %d, want 540", got)
+	}
+
+	data := bytes.Repeat([]byte{0xA5}, 10)
+	var reports [][2]int
+	if err := tag.WriteUserMemory(data, func(done, total int) {
+		reports = append(reports, [2]int{done, total})
+	}); err != nil {
+		t.Fatalf("WriteUserMemory() error = %v", err)
+	}
+	want := [][2]int{{4, 10}, {8, 10}, {10, 10}}
+	if len(reports) != len(want) {
+		t.Fatalf("progress reports = %v, want %v", reports, want)
+	}
+	for i := range want {
+		if reports[i] != want[i] {
+			t.Errorf("progress report %d = %v, want %v", i, reports[i], want[i])
+		}
+	}
+
+	last := 0
+	mem, err := tag.ReadUserMemory(func(done, total int) {
+		if done <= last || total != 504 {
+			t.Errorf("progress went from %d to %d of %d", last, done, total)
+		}
+		last = done
+	})
+	if err != nil {
+		t.Fatalf("ReadUserMemory() error = %v", err)
+	}
+	if !bytes.Equal(mem[:10], data) || mem[10] != 0 || last != 504 {
+		t.Errorf("ReadUserMemory() = % X..., progress ended at %d", mem[:12], last)
+	}
+
+	dump, err := tag.Dump(nil)
+	if err != nil || len(dump) != 540 {
+		t.Errorf("Dump() = %d bytes, %v; want 540 bytes", len(dump), err)
+	}
+
+	if err := tag.WriteUserMemory(make([]byte, 505), nil); err == nil {
+		t.Error("WriteUserMemory() accepted data larger than user memory")
+	}
+}
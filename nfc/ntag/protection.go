@@ -0,0 +1,310 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ntag
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
+// Native NTAG21x commands.
+const (
+	cmdPwdAuth = 0x1B
+	cmdReadCnt = 0x39
+	cmdReadSig = 0x3C
+)
+
+// Access configuration bits of the ACCESS byte.
+const (
+	accessProt       = 0x80
+	accessCfgLck     = 0x40
+	accessCntEnabled = 0x10
+	accessCntPwdProt = 0x08
+	accessAuthLim    = 0x07
+)
+
+// AuthDisabled is the AUTH0 value leaving all pages unprotected.
+const AuthDisabled = 0xFF
+
+// SignatureSize is the size of the originality signature in bytes.
+const SignatureSize = 32
+
+// ErrOriginality is returned when the originality signature of a tag does
+// not verify with the NXP public key.
+var ErrOriginality = errors.New("ntag: originality signature verification failed")
+
+// Exchanger sends native NTAG commands to a tag and returns its response.
+type Exchanger interface {
+	Exchange(frame []byte) ([]byte, error)
+}
+
+// ExchangerFunc adapts a function to the Exchanger interface.
+type ExchangerFunc func(frame []byte) ([]byte, error)
+
+// Exchange calls f(frame).
+func (f ExchangerFunc) Exchange(frame []byte) ([]byte, error) { return f(frame) }
+
+// PassThrough returns an Exchanger wrapping frames into the pseudo-APDU
+// pass-through command of a reader. header is the APDU header preceding the
+// frame, e.g. FF 00 00 00 on ACS readers; Lc is appended automatically and
+// the status words of the response are verified and stripped.
+func PassThrough(tr apdu.Transceiver, header []byte) Exchanger {
+	return ExchangerFunc(func(frame []byte) ([]byte, error) {
+		cmd := append(append(append([]byte(nil), header...), byte(len(frame))), frame...)
+		resp, err := tr.Transmit(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if err := apdu.CheckStatusFromData(resp); err != nil {
+			return nil, err
+		}
+		return resp[:len(resp)-2], nil
+	})
+}
+
+// SetExchanger sets how native commands reach the tag. By default they are
+// sent with PassThrough and the header FF 00 00 00.
+func (t *Tag) SetExchanger(ex Exchanger) { t.ex = ex }
+
+func (t *Tag) exchange(frame []byte) ([]byte, error) {
+	if t.ex == nil {
+		t.ex = PassThrough(t.tr, []byte{0xFF, 0x00, 0x00, 0x00})
+	}
+	return t.ex.Exchange(frame)
+}
+
+// Protection is the password protection configuration of a tag.
+type Protection struct {
+	// Auth0 is the first page protected by the password, AuthDisabled
+	// leaves all pages unprotected.
+	Auth0 byte
+	// ReadProtected extends the protection to reads; by default only
+	// writes require the password.
+	ReadProtected bool
+	// AuthLimit is the number of failed authentications, up to 7, after
+	// which the password is locked for good; 0 allows unlimited attempts.
+	AuthLimit uint8
+	// CounterEnabled makes the tag count the reads of its NDEF message.
+	CounterEnabled bool
+	// CounterProtected requires the password to read the counter.
+	CounterProtected bool
+	// Locked reports the configuration is locked for good, CFGLCK.
+	Locked bool
+}
+
+// configPage returns the first configuration page, CFG0, of the model.
+func (t *Tag) configPage() (int, error) {
+	m, err := t.Model()
+	if err != nil {
+		return 0, err
+	}
+	return m.Pages() - 4, nil
+}
+
+// Protection reads the password protection configuration of the tag.
+func (t *Tag) Protection() (Protection, error) {
+	cfg, err := t.configPage()
+	if err != nil {
+		return Protection{}, err
+	}
+	cfg0, err := t.ReadPage(cfg)
+	if err != nil {
+		return Protection{}, err
+	}
+	cfg1, err := t.ReadPage(cfg + 1)
+	if err != nil {
+		return Protection{}, err
+	}
+	access := cfg1.Data[0]
+	return Protection{
+		Auth0:            cfg0.Data[3],
+		ReadProtected:    access&accessProt != 0,
+		AuthLimit:        access & accessAuthLim,
+		CounterEnabled:   access&accessCntEnabled != 0,
+		CounterProtected: access&accessCntPwdProt != 0,
+		Locked:           access&accessCfgLck != 0,
+	}, nil
+}
+
+// SetProtection writes the password protection configuration of the tag,
+// keeping the other configuration bytes. ACCESS is written before AUTH0 so
+// the tag is never left protected with a partial configuration. Setting
+// Locked locks the configuration for good.
+func (t *Tag) SetProtection(p Protection) error {
+	if p.AuthLimit > accessAuthLim {
+		return fmt.Errorf("auth limit %d exceeds %d", p.AuthLimit, accessAuthLim)
+	}
+	cfg, err := t.configPage()
+	if err != nil {
+		return err
+	}
+	cfg0, err := t.ReadPage(cfg)
+	if err != nil {
+		return err
+	}
+	cfg1, err := t.ReadPage(cfg + 1)
+	if err != nil {
+		return err
+	}
+	access := cfg1.Data[0] &^ (accessProt | accessCfgLck | accessCntEnabled | accessCntPwdProt | accessAuthLim)
+	access |= p.AuthLimit
+	for _, f := range []struct {
+		set bool
+		bit byte
+	}{
+		{p.ReadProtected, accessProt},
+		{p.Locked, accessCfgLck},
+		{p.CounterEnabled, accessCntEnabled},
+		{p.CounterProtected, accessCntPwdProt},
+	} {
+		if f.set {
+			access |= f.bit
+		}
+	}
+	cfg1.Data[0] = access
+	if err := t.WritePage(cfg+1, cfg1.Data); err != nil {
+		return err
+	}
+	cfg0.Data[3] = p.Auth0
+	return t.WritePage(cfg, cfg0.Data)
+}
+
+// Lock makes the tag permanently read-only: it sets the write access of
+// the capability container to 0Fh, then all dynamic and static lock bits.
+// Locking cannot be undone.
+func (t *Tag) Lock() error {
+	cfg, err := t.configPage()
+	if err != nil {
+		return err
+	}
+	cc, err := t.ReadPage(CapabilityContainerPage)
+	if err != nil {
+		return err
+	}
+	cc.Data[3] = 0x0F
+	if err := t.WritePage(CapabilityContainerPage, cc.Data); err != nil {
+		return err
+	}
+	// The dynamic lock bytes precede CFG0, their last byte is RFUI.
+	if err := t.WritePage(cfg-1, []byte{0xFF, 0xFF, 0xFF, 0x00}); err != nil {
+		return err
+	}
+	// The tag ORs the static lock bytes into page 2, ignoring its first two
+	// bytes; they lock the capability container, so they come last.
+	return t.WritePage(2, []byte{0x00, 0x00, 0xFF, 0xFF})
+}
+
+// SetPassword writes the 32-bit password and the 16-bit password
+// acknowledge returned by a successful authentication. Both read as zeros.
+func (t *Tag) SetPassword(pwd [4]byte, pack [2]byte) error {
+	cfg, err := t.configPage()
+	if err != nil {
+		return err
+	}
+	if err := t.WritePage(cfg+2, pwd[:]); err != nil {
+		return err
+	}
+	return t.WritePage(cfg+3, []byte{pack[0], pack[1], 0x00, 0x00})
+}
+
+// Authenticate sends PWD_AUTH with pwd and returns the password acknowledge
+// of the tag, which callers should compare with the expected one to detect
+// tags accepting any password.
+func (t *Tag) Authenticate(pwd [4]byte) ([2]byte, error) {
+	resp, err := t.exchange([]byte{cmdPwdAuth, pwd[0], pwd[1], pwd[2], pwd[3]})
+	if err != nil {
+		return [2]byte{}, fmt.Errorf("authenticate: %w", err)
+	}
+	if len(resp) != 2 {
+		return [2]byte{}, fmt.Errorf("authenticate: unexpected response % X", resp)
+	}
+	return [2]byte{resp[0], resp[1]}, nil
+}
+
+// ReadCounter reads the NFC counter, the number of reads of the NDEF
+// message since the counter was enabled with Protection.CounterEnabled.
+func (t *Tag) ReadCounter() (uint32, error) {
+	resp, err := t.exchange([]byte{cmdReadCnt, 0x02})
+	if err != nil {
+		return 0, fmt.Errorf("read counter: %w", err)
+	}
+	if len(resp) != 3 {
+		return 0, fmt.Errorf("read counter: unexpected response % X", resp)
+	}
+	return uint32(resp[0]) | uint32(resp[1])<<8 | uint32(resp[2])<<16, nil
+}
+
+// ReadSignature reads the originality signature programmed by NXP, an ECDSA
+// signature of the UID with r and s concatenated.
+func (t *Tag) ReadSignature() ([]byte, error) {
+	resp, err := t.exchange([]byte{cmdReadSig, 0x00})
+	if err != nil {
+		return nil, fmt.Errorf("read signature: %w", err)
+	}
+	if len(resp) != SignatureSize {
+		return nil, fmt.Errorf("read signature: unexpected response of %d bytes", len(resp))
+	}
+	return resp, nil
+}
+
+// VerifyOriginality reads the originality signature of the tag and verifies
+// it with the NXP NTAG21x public key, returning ErrOriginality when it does
+// not match.
+func (t *Tag) VerifyOriginality() error {
+	sig, err := t.ReadSignature()
+	if err != nil {
+		return err
+	}
+	return VerifySignature(NXPPublicKey, t.uid, sig)
+}
+
+// VerifySignature verifies the originality signature sig of the tag with
+// the given uid using pub. The UID is signed as is, without hashing.
+func VerifySignature(pub *ecdsa.PublicKey, uid, sig []byte) error {
+	if len(sig) != SignatureSize {
+		return fmt.Errorf("signature must be %d bytes, got %d", SignatureSize, len(sig))
+	}
+	r := new(big.Int).SetBytes(sig[:SignatureSize/2])
+	s := new(big.Int).SetBytes(sig[SignatureSize/2:])
+	if !ecdsa.Verify(pub, uid, r, s) {
+		return ErrOriginality
+	}
+	return nil
+}
+
+// Secp128r1 returns the SEC 2 curve secp128r1 used by the originality
+// signature.
+func Secp128r1() elliptic.Curve { return secp128r1 }
+
+var secp128r1 = &elliptic.CurveParams{
+	Name:    "secp128r1",
+	BitSize: 128,
+	P:       hexInt("FFFFFFFDFFFFFFFFFFFFFFFFFFFFFFFF"),
+	N:       hexInt("FFFFFFFE0000000075A30D1B9038A115"),
+	B:       hexInt("E87579C11079F43DD824993C2CEE5ED3"),
+	Gx:      hexInt("161FF7528B899B2D0C28607CA52C5B86"),
+	Gy:      hexInt("CF5AC8395BAFEB13C02DA292DDED7A83"),
+}
+
+// NXPPublicKey is the NXP public key of the NTAG21x originality signature.
+var NXPPublicKey = &ecdsa.PublicKey{
+	Curve: secp128r1,
+	X:     hexInt("494E1A386D3D3CFE3DC10E5DE68A499B"),
+	Y:     hexInt("1C202DB5B132393E89ED19FE5BE8BC61"),
+}
+
+func hexInt(s string) *big.Int {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return new(big.Int).SetBytes(b)
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ntag
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+// native answers the native commands wrapped in the pass-through
+// pseudo-APDU, NAKing with 63 00.
+func (f *fakeTag) native(frame []byte) ([]byte, error) {
+	cfg := len(f.mem)/PageSize - 4
+	switch {
+	case frame[0] == cmdPwdAuth && len(frame) == 5:
+		if !bytes.Equal(frame[1:], f.mem[(cfg+2)*PageSize:(cfg+3)*PageSize]) {
+			return []byte{0x63, 0x00}, nil
+		}
+		return append(append([]byte(nil), f.mem[(cfg+3)*PageSize:(cfg+3)*PageSize+2]...), 0x90, 0x00), nil
+	case frame[0] == cmdReadCnt:
+		return []byte{byte(f.counter), byte(f.counter >> 8), byte(f.counter >> 16), 0x90, 0x00}, nil
+	case frame[0] == cmdReadSig:
+		return append(append([]byte(nil), f.sig...), 0x90, 0x00), nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+func TestProtection(t *testing.T) {
+	fake := newFakeTag(ModelNTAG213)
+	copy(fake.mem[0x29*PageSize:], []byte{0x04, 0x00, 0x00, AuthDisabled})
+	tag := NewTag([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, fake)
+
+	if err := tag.SetPassword([4]byte{1, 2, 3, 4}, [2]byte{0xAB, 0xCD}); err != nil {
+		t.Fatalf("SetPassword() error = %v", err)
+	}
+	want := Protection{Auth0: 0x10, ReadProtected: true, AuthLimit: 3, CounterEnabled: true}
+	if err := tag.SetProtection(want); err != nil {
+		t.Fatalf("SetProtection() error = %v", err)
+	}
+	if got := fake.mem[0x29*PageSize : 0x2B*PageSize]; !bytes.Equal(got, []byte{0x04, 0x00, 0x00, 0x10, 0x93, 0x00, 0x00, 0x00}) {
+		t.Errorf("configuration pages = % X", got)
+	}
+	if got, err := tag.Protection(); err != nil || got != want {
+		t.Errorf("Protection() = %+v, %v; want %+v", got, err, want)
+	}
+	if err := tag.SetProtection(Protection{AuthLimit: 8}); err == nil {
+		t.Error("SetProtection() accepted an auth limit of 8")
+	}
+
+	if pack, err := tag.Authenticate([4]byte{1, 2, 3, 4}); err != nil || pack != [2]byte{0xAB, 0xCD} {
+		t.Errorf("Authenticate() = % X, %v", pack, err)
+	}
+	if _, err := tag.Authenticate([4]byte{0, 0, 0, 0}); err == nil {
+		t.Error("Authenticate() with a wrong password succeeded")
+	}
+
+	fake.counter = 0x010203
+	if n, err := tag.ReadCounter(); err != nil || n != 0x010203 {
+		t.Errorf("ReadCounter() = %X, %v", n, err)
+	}
+
+	if err := tag.Lock(); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	if cc, lock := fake.mem[3*PageSize:4*PageSize], fake.mem[0x28*PageSize:0x29*PageSize]; cc[3] != 0x0F || !bytes.Equal(lock, []byte{0xFF, 0xFF, 0xFF, 0x00}) || !bytes.Equal(fake.mem[10:12], []byte{0xFF, 0xFF}) {
+		t.Errorf("pages after Lock() = % X", fake.mem[:0x29*PageSize])
+	}
+}
+
+func TestVerifyOriginality(t *testing.T) {
+	if !Secp128r1().IsOnCurve(NXPPublicKey.X, NXPPublicKey.Y) {
+		t.Fatal("NXP public key is not on secp128r1")
+	}
+	key, err := ecdsa.GenerateKey(Secp128r1(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid := []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	r, s, err := ecdsa.Sign(rand.Reader, key, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, SignatureSize)
+	r.FillBytes(sig[:SignatureSize/2])
+	s.FillBytes(sig[SignatureSize/2:])
+
+	fake := newFakeTag(ModelNTAG215)
+	fake.sig = sig
+	tag := NewTag(uid, fake)
+	got, err := tag.ReadSignature()
+	if err != nil || !bytes.Equal(got, sig) {
+		t.Fatalf("ReadSignature() = % X, %v", got, err)
+	}
+	if err := VerifySignature(&key.PublicKey, uid, sig); err != nil {
+		t.Errorf("VerifySignature() error = %v", err)
+	}
+	if err := VerifySignature(&key.PublicKey, []byte{0x04, 0, 0, 0, 0, 0, 0}, sig); !errors.Is(err, ErrOriginality) {
+		t.Errorf("VerifySignature() of another UID error = %v, want ErrOriginality", err)
+	}
+	if err := tag.VerifyOriginality(); !errors.Is(err, ErrOriginality) {
+		t.Errorf("VerifyOriginality() with a foreign key error = %v, want ErrOriginality", err)
+	}
+}
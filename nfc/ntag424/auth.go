@@ -0,0 +1,195 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ntag424
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/crypto"
+	"github.com/happy-sdk/scardkit/crypto/keystore"
+	"github.com/happy-sdk/scardkit/crypto/securemem"
+)
+
+// ErrAuth is returned when the tag or the reader fails to prove knowledge of
+// the key during authentication.
+var ErrAuth = errors.New("ntag424: authentication failed")
+
+// Tag is an NTAG 424 DNA tag reached with ISO 7816-4 wrapped native
+// commands.
+type Tag struct {
+	tr   apdu.Transceiver
+	rand io.Reader
+}
+
+// NewTag returns the tag reached through tr, typically a card connected
+// through a PC/SC reader with the NDEF application selected.
+func NewTag(tr apdu.Transceiver) *Tag {
+	return &Tag{tr: tr, rand: rand.Reader}
+}
+
+// LoadKey returns the AES key id of the given key version from p.
+func LoadKey(p keystore.Provider, id string, version byte) ([]byte, error) {
+	key, err := p.GetKey(id, int(version))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key %s of %d bytes, want %d", id, len(key), KeySize)
+	}
+	return key, nil
+}
+
+// Session is the secure messaging state established by an authentication.
+// Its keys are redacted when formatted or logged; call Close once the
+// session ends to zeroize them.
+type Session struct {
+	KeyNo      byte
+	TI         []byte          // Transaction identifier.
+	ENCKey     securemem.Bytes // SesAuthENCKey.
+	MACKey     securemem.Bytes // SesAuthMACKey.
+	CmdCounter uint16
+}
+
+// Close zeroizes the session keys.
+func (s *Session) Close() {
+	s.ENCKey.Zero()
+	s.MACKey.Zero()
+	s.ENCKey, s.MACKey = nil, nil
+}
+
+// command sends a wrapped native command and returns the response data and
+// the native status, the second status word.
+func (t *Tag) command(ins byte, data []byte) ([]byte, byte, error) {
+	cmd := []byte{0x90, ins, 0x00, 0x00}
+	if len(data) > 0 {
+		cmd = append(append(cmd, byte(len(data))), data...)
+	}
+	resp, err := t.tr.Transmit(append(cmd, 0x00))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp) < 2 || resp[len(resp)-2] != 0x91 {
+		return nil, 0, apdu.CheckStatusFromData(resp)
+	}
+	return resp[:len(resp)-2], resp[len(resp)-1], nil
+}
+
+// AuthenticateEV2First authenticates with the AES key number keyNo and
+// returns the session it establishes.
+func (t *Tag) AuthenticateEV2First(keyNo byte, key []byte) (*Session, error) {
+	b, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return t.authenticate(keyNo, blockOps(b))
+}
+
+// AuthenticateEV2FirstToken authenticates with the AES key number keyNo
+// held by tok as the key id of the given key version, so the key never
+// reaches the host, and returns the session it establishes.
+func (t *Tag) AuthenticateEV2FirstToken(keyNo byte, tok keystore.Token, id string, version byte) (*Session, error) {
+	return t.authenticate(keyNo, keyOps{
+		encrypt: func(data []byte) ([]byte, error) { return tok.Encrypt(id, int(version), keystore.AES, data) },
+		decrypt: func(data []byte) ([]byte, error) { return tok.Decrypt(id, int(version), keystore.AES, data) },
+		cmac:    func(msg []byte) ([]byte, error) { return tok.CMAC(id, int(version), msg) },
+	})
+}
+
+// keyOps are the operations of an authentication with a key, AES in CBC
+// mode with a zero IV and CMAC.
+type keyOps struct {
+	encrypt, decrypt func(data []byte) ([]byte, error)
+	cmac             func(msg []byte) ([]byte, error)
+}
+
+// blockOps returns the operations with the key of b.
+func blockOps(b cipher.Block) keyOps {
+	crypt := func(mode func(cipher.Block, []byte) cipher.BlockMode) func([]byte) ([]byte, error) {
+		return func(data []byte) ([]byte, error) {
+			out := make([]byte, len(data))
+			mode(b, make([]byte, aes.BlockSize)).CryptBlocks(out, data)
+			return out, nil
+		}
+	}
+	return keyOps{
+		encrypt: crypt(cipher.NewCBCEncrypter),
+		decrypt: crypt(cipher.NewCBCDecrypter),
+		cmac:    func(msg []byte) ([]byte, error) { return crypto.CMAC(b, msg), nil },
+	}
+}
+
+func (t *Tag) authenticate(keyNo byte, k keyOps) (*Session, error) {
+	resp, status, err := t.command(0x71, []byte{keyNo, 0x00})
+	if err != nil {
+		return nil, fmt.Errorf("authenticate: %w", err)
+	}
+	if status != 0xAF || len(resp) != aes.BlockSize {
+		return nil, fmt.Errorf("authenticate: unexpected response % X, status 91%02X", resp, status)
+	}
+	rndB, err := k.decrypt(resp)
+	if err != nil {
+		return nil, err
+	}
+	rndA := make([]byte, aes.BlockSize)
+	defer securemem.Zero(rndA, rndB)
+	if _, err := io.ReadFull(t.rand, rndA); err != nil {
+		return nil, err
+	}
+	token, err := k.encrypt(append(append([]byte(nil), rndA...), rotate(rndB)...))
+	if err != nil {
+		return nil, err
+	}
+	resp, status, err = t.command(0xAF, token)
+	if err != nil {
+		return nil, fmt.Errorf("authenticate: %w", err)
+	}
+	if status == 0xAE {
+		return nil, ErrAuth
+	}
+	if status != 0x00 || len(resp) != 2*aes.BlockSize {
+		return nil, fmt.Errorf("authenticate: unexpected response % X, status 91%02X", resp, status)
+	}
+	if resp, err = k.decrypt(resp); err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(resp[4:20], rotate(rndA)) != 1 {
+		return nil, ErrAuth
+	}
+
+	enc, err := k.cmac(authVector(0xA5, rndA, rndB))
+	if err != nil {
+		return nil, err
+	}
+	mac, err := k.cmac(authVector(0x5A, rndA, rndB))
+	if err != nil {
+		return nil, err
+	}
+	return &Session{KeyNo: keyNo, TI: bytes.Clone(resp[:4]), ENCKey: enc, MACKey: mac}, nil
+}
+
+// authVector returns the session vector deriving a session key from the
+// random numbers exchanged during authentication.
+func authVector(label byte, rndA, rndB []byte) []byte {
+	sv := []byte{label, ^label, 0x00, 0x01, 0x00, 0x80}
+	sv = append(sv, rndA[:2]...)
+	for i := 0; i < 6; i++ {
+		sv = append(sv, rndA[2+i]^rndB[i])
+	}
+	sv = append(sv, rndB[6:]...)
+	return append(sv, rndA[8:]...)
+}
+
+// rotate returns b rotated left by one byte.
+func rotate(b []byte) []byte {
+	return append(bytes.Clone(b[1:]), b[0])
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package ntag424
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/crypto/keystore"
+)
+
+func TestVerify(t *testing.T) {
+	zero := make([]byte, KeySize)
+	tests := []struct {
+		name     string
+		v        Verifier
+		url      string
+		uid      string
+		ctr      uint32
+		fileData string
+	}{
+		{
+			"encrypted picc data",
+			Verifier{PICCParam: "e", MACParam: "c"},
+			"https://choose.url.com/ntag424?e=EF963FF7828658A599F3041510671E88&c=94EED9EE65337086",
+			"04DE5F1EACC040", 61, "",
+		},
+		{
+			"encrypted file data",
+			Verifier{MACInputParam: "enc"},
+			"https://sdm.example.com/tag?picc_data=FD91EC264309878BE6345CBE53BADF40&enc=CEE9A53E3E463EF1F459635736738962&cmac=ECC1E7F6C6C73BF6",
+			"04958CAA5C5E80", 8, "xxxxxxxxxxxxxxxx",
+		},
+	}
+	for _, tt := range tests {
+		tt.v.MetaReadKey, tt.v.FileReadKey = zero, zero
+		msg, err := tt.v.Verify(tt.url)
+		if err != nil {
+			t.Errorf("%s: Verify() error = %v", tt.name, err)
+			continue
+		}
+		if got := hex.EncodeToString(msg.UID); !bytes.EqualFold([]byte(got), []byte(tt.uid)) || msg.ReadCounter != tt.ctr || string(msg.FileData) != tt.fileData {
+			t.Errorf("%s: Verify() = %s, %d, %q", tt.name, got, msg.ReadCounter, msg.FileData)
+		}
+		tampered := tt.url[:len(tt.url)-1] + "0"
+		if _, err := tt.v.Verify(tampered); !errors.Is(err, ErrMAC) {
+			t.Errorf("%s: Verify() of a tampered MAC error = %v, want ErrMAC", tt.name, err)
+		}
+	}
+}
+
+func TestVerifyReplay(t *testing.T) {
+	v := Verifier{MetaReadKey: make([]byte, KeySize), FileReadKey: make([]byte, KeySize), PICCParam: "e", MACParam: "c", Counters: &MemoryCounters{}}
+	url := "https://choose.url.com/ntag424?e=EF963FF7828658A599F3041510671E88&c=94EED9EE65337086"
+	if _, err := v.Verify(url); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if _, err := v.Verify(url); !errors.Is(err, ErrReplay) {
+		t.Errorf("Verify() of a replayed URL error = %v, want ErrReplay", err)
+	}
+}
+
+// fakeTag answers AuthenticateEV2First with key.
+type fakeTag struct {
+	key, rndB []byte
+	rndA      []byte
+}
+
+func (f *fakeTag) Transmit(cmd []byte) ([]byte, error) {
+	b, _ := newCipher(f.key)
+	switch cmd[1] {
+	case 0x71:
+		out := bytes.Clone(f.rndB)
+		cbc(b, out, true)
+		return append(out, 0x91, 0xAF), nil
+	case 0xAF:
+		token := bytes.Clone(cmd[5:37])
+		cbc(b, token, false)
+		if !bytes.Equal(token[16:], rotate(f.rndB)) {
+			return []byte{0x91, 0xAE}, nil
+		}
+		f.rndA = token[:16]
+		out := append(append([]byte{1, 2, 3, 4}, rotate(f.rndA)...), make([]byte, 12)...)
+		cbc(b, out, true)
+		return append(out, 0x91, 0x00), nil
+	}
+	return []byte{0x91, 0x1C}, nil
+}
+
+func TestAuthenticateEV2First(t *testing.T) {
+	key := bytes.Repeat([]byte{0x11}, KeySize)
+	fake := &fakeTag{key: key, rndB: bytes.Repeat([]byte{0xB0}, 16)}
+	tag := NewTag(fake)
+	tag.rand = bytes.NewReader(bytes.Repeat([]byte{0xA0}, 16))
+	s, err := tag.AuthenticateEV2First(0, key)
+	if err != nil {
+		t.Fatalf("AuthenticateEV2First() error = %v", err)
+	}
+	if !bytes.Equal(s.TI, []byte{1, 2, 3, 4}) || len(s.ENCKey) != KeySize || bytes.Equal(s.ENCKey, s.MACKey) {
+		t.Errorf("session = %+v", s)
+	}
+	enc := s.ENCKey
+	if s.Close(); s.ENCKey != nil || !bytes.Equal(enc, make([]byte, KeySize)) {
+		t.Errorf("session keys not zeroized by Close()")
+	}
+	sv := authVector(0xA5, fake.rndA, fake.rndB)
+	if len(sv) != 32 || sv[0] != 0xA5 || sv[1] != 0x5A || sv[8] != 0xA0^0xB0 {
+		t.Errorf("session vector = % X", sv)
+	}
+
+	tag.rand = bytes.NewReader(bytes.Repeat([]byte{0xA0}, 16))
+	tok := keystore.SoftToken(keystore.Map{{ID: "app", Version: 1}: key})
+	ts, err := tag.AuthenticateEV2FirstToken(0, tok, "app", 1)
+	if err != nil || !bytes.Equal(ts.TI, []byte{1, 2, 3, 4}) || len(ts.MACKey) != KeySize {
+		t.Errorf("AuthenticateEV2FirstToken() = %+v, %v", ts, err)
+	}
+
+	tag.rand = bytes.NewReader(make([]byte, 16))
+	if _, err := tag.AuthenticateEV2First(0, make([]byte, KeySize)); !errors.Is(err, ErrAuth) {
+		t.Errorf("AuthenticateEV2First() with a wrong key error = %v, want ErrAuth", err)
+	}
+}
+
+func cbc(b cipher.Block, data []byte, encrypt bool) {
+	iv := make([]byte, b.BlockSize())
+	if encrypt {
+		cipher.NewCBCEncrypter(b, iv).CryptBlocks(data, data)
+		return
+	}
+	cipher.NewCBCDecrypter(b, iv).CryptBlocks(data, data)
+}
@@ -0,0 +1,329 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package ntag424 supports NTAG 424 DNA tags: AES authentication with the
+// tag and verification of the Secure Dynamic Messaging (SDM) output, the
+// Secure Unique NFC (SUN) URLs generated on every tap, following NXP AN12196.
+package ntag424
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/happy-sdk/scardkit/crypto"
+)
+
+// KeySize is the size of the AES-128 keys of the tag.
+const KeySize = 16
+
+// UIDSize is the size of the tag UID.
+const UIDSize = 7
+
+// MACSize is the size of the truncated SDM MAC.
+const MACSize = 8
+
+// Errors of SUN message verification.
+var (
+	ErrMAC    = errors.New("ntag424: sdm mac mismatch")
+	ErrReplay = errors.New("ntag424: sdm read counter not increasing")
+)
+
+// PICC data tag bits.
+const (
+	piccUIDMirror = 0x80
+	piccCtrMirror = 0x40
+	piccUIDLength = 0x0F
+)
+
+// PICCData is the tag identity mirrored into an SDM message.
+type PICCData struct {
+	UID []byte // Nil unless mirrored.
+	// ReadCounter is SDMReadCtr, incremented by the tag on every read of
+	// the file; HasCounter reports whether it was mirrored.
+	ReadCounter uint32
+	HasCounter  bool
+}
+
+// DecryptPICCData decrypts the encrypted PICC data of an SDM message with
+// the SDM meta read key.
+func DecryptPICCData(metaReadKey, enc []byte) (PICCData, error) {
+	b, err := newCipher(metaReadKey)
+	if err != nil {
+		return PICCData{}, err
+	}
+	if len(enc) != aes.BlockSize {
+		return PICCData{}, fmt.Errorf("encrypted picc data must be %d bytes, got %d", aes.BlockSize, len(enc))
+	}
+	plain := make([]byte, aes.BlockSize)
+	cipher.NewCBCDecrypter(b, make([]byte, aes.BlockSize)).CryptBlocks(plain, enc)
+
+	var p PICCData
+	tag, rest := plain[0], plain[1:]
+	if tag&piccUIDMirror != 0 {
+		if n := int(tag & piccUIDLength); n != UIDSize {
+			return PICCData{}, fmt.Errorf("picc data uid length %d, want %d; wrong key?", n, UIDSize)
+		}
+		p.UID, rest = rest[:UIDSize], rest[UIDSize:]
+	}
+	if tag&piccCtrMirror != 0 {
+		p.ReadCounter = uint32(rest[0]) | uint32(rest[1])<<8 | uint32(rest[2])<<16
+		p.HasCounter = true
+	}
+	return p, nil
+}
+
+// sessionVector returns the session vector deriving an SDM session key:
+// label, UID and counter padded with zeros to a block boundary.
+func (p PICCData) sessionVector(label byte) []byte {
+	sv := []byte{label, ^label, 0x00, 0x01, 0x00, 0x80}
+	sv = append(sv, p.UID...)
+	if p.HasCounter {
+		sv = append(sv, counterBytes(p.ReadCounter)...)
+	}
+	for len(sv)%aes.BlockSize != 0 {
+		sv = append(sv, 0x00)
+	}
+	return sv
+}
+
+// SessionKeys derives the SDM session encryption and MAC keys of the tap
+// identified by p from the SDM file read key.
+func SessionKeys(fileReadKey []byte, p PICCData) (enc, mac []byte, err error) {
+	b, err := newCipher(fileReadKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return crypto.CMAC(b, p.sessionVector(0xC3)), crypto.CMAC(b, p.sessionVector(0x3C)), nil
+}
+
+// MAC returns the truncated SDM MAC of input under the session MAC key:
+// the bytes at odd offsets of its CMAC.
+func MAC(sessionMACKey, input []byte) ([]byte, error) {
+	b, err := newCipher(sessionMACKey)
+	if err != nil {
+		return nil, err
+	}
+	full := crypto.CMAC(b, input)
+	mac := make([]byte, MACSize)
+	for i := range mac {
+		mac[i] = full[2*i+1]
+	}
+	return mac, nil
+}
+
+// DecryptFileData decrypts the encrypted file data of an SDM message with
+// the session encryption key of the tap, whose read counter is ctr.
+func DecryptFileData(sessionENCKey []byte, ctr uint32, data []byte) ([]byte, error) {
+	b, err := newCipher(sessionENCKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted file data of %d bytes is not a multiple of %d", len(data), aes.BlockSize)
+	}
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, counterBytes(ctr))
+	b.Encrypt(iv, iv)
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(b, iv).CryptBlocks(plain, data)
+	return plain, nil
+}
+
+func counterBytes(ctr uint32) []byte { return []byte{byte(ctr), byte(ctr >> 8), byte(ctr >> 16)} }
+
+func newCipher(key []byte) (cipher.Block, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("aes key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return aes.NewCipher(key)
+}
+
+// CounterStore remembers the last read counter seen for each tag.
+type CounterStore interface {
+	// Advance reports whether ctr is greater than the last counter seen for
+	// uid, recording it if so.
+	Advance(uid []byte, ctr uint32) bool
+}
+
+// MemoryCounters is a CounterStore kept in memory. The zero value is ready
+// to use.
+type MemoryCounters struct {
+	mu   sync.Mutex
+	last map[string]uint32
+}
+
+// Advance implements CounterStore.
+func (m *MemoryCounters) Advance(uid []byte, ctr uint32) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if last, ok := m.last[string(uid)]; ok && ctr <= last {
+		return false
+	}
+	if m.last == nil {
+		m.last = make(map[string]uint32)
+	}
+	m.last[string(uid)] = ctr
+	return true
+}
+
+// Verifier verifies the SUN URLs of tags sharing the same SDM keys and
+// mirroring configuration. The parameter names default to those of the
+// AN12196 examples: picc_data, enc and cmac.
+type Verifier struct {
+	MetaReadKey []byte // SDM meta read key decrypting the PICC data.
+	FileReadKey []byte // SDM file read key deriving the session keys.
+
+	PICCParam string // Encrypted PICC data.
+	UIDParam  string // Plain UID, with plain mirroring.
+	CtrParam  string // Plain read counter, with plain mirroring.
+	EncParam  string // Encrypted file data, if mirrored.
+	MACParam  string // SDM MAC.
+
+	// MACInputParam is the parameter whose value starts the MAC input,
+	// which ends before the MAC value, matching SDMMACInputOffset. When
+	// empty the MAC input is empty, SDMMACInputOffset equal to SDMMACOffset.
+	MACInputParam string
+
+	// Counters, when set, rejects taps whose read counter does not
+	// increase with ErrReplay.
+	Counters CounterStore
+}
+
+// Message is a verified SUN message.
+type Message struct {
+	PICCData
+	FileData []byte // Decrypted file data, if mirrored.
+}
+
+func param(name, def string) string {
+	if name == "" {
+		return def
+	}
+	return name
+}
+
+// Verify checks the SDM MAC of the SUN URL rawURL and returns the tag
+// identity and file data it carries.
+func (v *Verifier) Verify(rawURL string) (*Message, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	hexParam := func(name string) ([]byte, error) {
+		b, err := hex.DecodeString(q.Get(name))
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", name, err)
+		}
+		return b, nil
+	}
+
+	var msg Message
+	if enc := q.Get(param(v.PICCParam, "picc_data")); enc != "" || v.UIDParam == "" {
+		b, err := hexParam(param(v.PICCParam, "picc_data"))
+		if err != nil {
+			return nil, err
+		}
+		if msg.PICCData, err = DecryptPICCData(v.MetaReadKey, b); err != nil {
+			return nil, err
+		}
+	} else {
+		if msg.UID, err = hexParam(v.UIDParam); err != nil {
+			return nil, err
+		}
+		if len(msg.UID) != UIDSize {
+			return nil, fmt.Errorf("uid of %d bytes, want %d", len(msg.UID), UIDSize)
+		}
+		if v.CtrParam != "" {
+			ctr, err := hexParam(v.CtrParam)
+			if err != nil {
+				return nil, err
+			}
+			if len(ctr) != 3 {
+				return nil, fmt.Errorf("read counter of %d bytes, want 3", len(ctr))
+			}
+			msg.ReadCounter = uint32(ctr[0])<<16 | uint32(ctr[1])<<8 | uint32(ctr[2])
+			msg.HasCounter = true
+		}
+	}
+
+	encKey, macKey, err := SessionKeys(v.FileReadKey, msg.PICCData)
+	if err != nil {
+		return nil, err
+	}
+	macParam := param(v.MACParam, "cmac")
+	got, err := hexParam(macParam)
+	if err != nil {
+		return nil, err
+	}
+	input, err := macInput(rawURL, v.MACInputParam, macParam)
+	if err != nil {
+		return nil, err
+	}
+	want, err := MAC(macKey, []byte(input))
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return nil, ErrMAC
+	}
+
+	if enc := q.Get(param(v.EncParam, "enc")); enc != "" {
+		b, err := hexParam(param(v.EncParam, "enc"))
+		if err != nil {
+			return nil, err
+		}
+		if msg.FileData, err = DecryptFileData(encKey, msg.ReadCounter, b); err != nil {
+			return nil, err
+		}
+	}
+	if v.Counters != nil && msg.HasCounter && !v.Counters.Advance(msg.UID, msg.ReadCounter) {
+		return nil, ErrReplay
+	}
+	return &msg, nil
+}
+
+// macInput returns the part of rawURL from the value of the parameter from
+// up to the value of the MAC parameter, as mirrored by the tag.
+func macInput(rawURL, from, mac string) (string, error) {
+	end := valueOffset(rawURL, mac)
+	if end < 0 {
+		return "", fmt.Errorf("missing parameter %s", mac)
+	}
+	if from == "" {
+		return "", nil
+	}
+	start := valueOffset(rawURL, from)
+	if start < 0 || start > end {
+		return "", fmt.Errorf("missing parameter %s before %s", from, mac)
+	}
+	return rawURL[start:end], nil
+}
+
+// valueOffset returns the offset of the value of the query parameter name
+// in rawURL, or -1.
+func valueOffset(rawURL, name string) int {
+	q := strings.IndexByte(rawURL, '?')
+	if q < 0 {
+		return -1
+	}
+	for i := q; i < len(rawURL); {
+		if strings.HasPrefix(rawURL[i+1:], name+"=") {
+			return i + 1 + len(name) + 1
+		}
+		next := strings.IndexByte(rawURL[i+1:], '&')
+		if next < 0 {
+			return -1
+		}
+		i += 1 + next
+	}
+	return -1
+}
@@ -0,0 +1,193 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package provisioning
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// errBatchDone ends the run of a batch.
+var errBatchDone = errors.New("batch done")
+
+// Batch encodes a run of tags with a Provisioner, counting successes and
+// failures. Hand its HandleCard to an SDK and pass the SDK's Run to Run.
+type Batch struct {
+	Provisioner *Provisioner
+	// Count ends the batch once as many tags were provisioned, 0 runs
+	// until the context is done.
+	Count int
+	// OnFailure, when set, is called with each failed result, e.g. to
+	// prompt the operator to re-tap or discard the tag, and reports whether
+	// the batch continues.
+	OnFailure func(Result) bool
+
+	mu      sync.Mutex
+	start   time.Time
+	end     time.Time
+	results []Result
+	ok, dup int
+	stop    context.CancelCauseFunc
+}
+
+// Stats are the statistics of a batch.
+type Stats struct {
+	Succeeded, Failed int
+	// Duplicates are the failures rejected by the registry.
+	Duplicates int
+	Elapsed    time.Duration
+	// TagsPerMinute is the throughput of tags provisioned successfully.
+	TagsPerMinute float64
+}
+
+// Run calls run, typically the Run method of an SDK handling cards with
+// HandleCard, with a context canceled once the batch ends: when Count tags
+// were provisioned or OnFailure stopped it, in which case Run returns nil.
+func (b *Batch) Run(ctx context.Context, run func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	b.mu.Lock()
+	b.start, b.end, b.stop = time.Now(), time.Time{}, cancel
+	b.mu.Unlock()
+
+	err := run(ctx)
+	b.mu.Lock()
+	b.end, b.stop = time.Now(), nil
+	b.mu.Unlock()
+	if context.Cause(ctx) == errBatchDone {
+		return nil
+	}
+	return err
+}
+
+// HandleCard provisions the card of ev and records its result. It has the
+// signature of a scardkit.CardHandler.
+func (b *Batch) HandleCard(ctx context.Context, ev cardreader.Event, card transport.Card) error {
+	res := b.Provisioner.Provision(ctx, ev.Reader, ev.UID, card)
+	b.mu.Lock()
+	b.results = append(b.results, res)
+	if res.OK() {
+		b.ok++
+	} else if errors.Is(res.Err, ErrDuplicate) {
+		b.dup++
+	}
+	done := b.Count > 0 && b.ok >= b.Count
+	stop := b.stop
+	b.mu.Unlock()
+
+	if !res.OK() && b.OnFailure != nil && !b.OnFailure(res) {
+		done = true
+	}
+	if done && stop != nil {
+		stop(errBatchDone)
+	}
+	return res.Err
+}
+
+// Results returns the results recorded so far.
+func (b *Batch) Results() []Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Result(nil), b.results...)
+}
+
+// Stats returns the statistics of the batch, running or ended.
+func (b *Batch) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := Stats{Succeeded: b.ok, Failed: len(b.results) - b.ok, Duplicates: b.dup}
+	if !b.start.IsZero() {
+		end := b.end
+		if end.IsZero() {
+			end = time.Now()
+		}
+		s.Elapsed = end.Sub(b.start)
+	}
+	if s.Elapsed > 0 {
+		s.TagsPerMinute = float64(s.Succeeded) / s.Elapsed.Minutes()
+	}
+	return s
+}
+
+// record is a result as written to reports.
+type record struct {
+	Time       time.Time `json:"time"`
+	Profile    string    `json:"profile,omitempty"`
+	Reader     string    `json:"reader"`
+	UID        string    `json:"uid"`
+	OK         bool      `json:"ok"`
+	Steps      []string  `json:"steps"`
+	DurationMS int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func newRecord(r Result) record {
+	rec := record{
+		Time:       r.Start,
+		Profile:    r.Profile,
+		Reader:     r.Reader,
+		UID:        strings.ToUpper(hex.EncodeToString(r.UID)),
+		OK:         r.OK(),
+		Steps:      make([]string, len(r.Steps)),
+		DurationMS: r.Duration.Milliseconds(),
+	}
+	for i, s := range r.Steps {
+		rec.Steps[i] = s.String()
+	}
+	if r.Err != nil {
+		rec.Error = r.Err.Error()
+	}
+	return rec
+}
+
+// WriteCSV writes the results as CSV with a header row.
+func (b *Batch) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"time", "profile", "reader", "uid", "ok", "steps", "duration_ms", "error"}); err != nil {
+		return err
+	}
+	for _, r := range b.Results() {
+		rec := newRecord(r)
+		if err := cw.Write([]string{
+			rec.Time.Format(time.RFC3339Nano), rec.Profile, rec.Reader, rec.UID,
+			strconv.FormatBool(rec.OK), strings.Join(rec.Steps, " "),
+			strconv.FormatInt(rec.DurationMS, 10), rec.Error,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the statistics and the results as a JSON document.
+func (b *Batch) WriteJSON(w io.Writer) error {
+	s := b.Stats()
+	doc := struct {
+		Succeeded     int      `json:"succeeded"`
+		Failed        int      `json:"failed"`
+		Duplicates    int      `json:"duplicates"`
+		ElapsedMS     int64    `json:"elapsed_ms"`
+		TagsPerMinute float64  `json:"tags_per_minute"`
+		Results       []record `json:"results"`
+	}{s.Succeeded, s.Failed, s.Duplicates, s.Elapsed.Milliseconds(), s.TagsPerMinute, []record{}}
+	for _, r := range b.Results() {
+		doc.Results = append(doc.Results, newRecord(r))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package provisioning
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/x/virtualreader"
+)
+
+// tapped is a virtual tag as handed to card handlers.
+type tapped struct{ tag.Card }
+
+func (tapped) Disconnect() error { return nil }
+
+// pulled is a tag removed from the reader before it was written.
+type pulled struct{ tag.Card }
+
+func (pulled) Transmit([]byte) ([]byte, error) { return nil, errors.New("card removed") }
+
+func TestBatch(t *testing.T) {
+	profile := Profile{Name: "stickers", NDEF: ndef.NewMessage(ndef.NewURIRecord("https://example.com")), Verify: true}
+	tests := []struct {
+		name      string
+		count     int
+		stopOnErr bool
+		ok, fail  int
+		prompts   int
+	}{
+		{"count", 3, false, 3, 1, 1},
+		{"operator stop", 10, true, 1, 1, 1},
+	}
+	for _, tt := range tests {
+		prompts := 0
+		b := &Batch{
+			Provisioner: &Provisioner{Profile: profile},
+			Count:       tt.count,
+			OnFailure: func(Result) bool {
+				prompts++
+				return !tt.stopOnErr
+			},
+		}
+		// The second tag is pulled away from the reader.
+		run := func(ctx context.Context) error {
+			for i := byte(0); ctx.Err() == nil; i++ {
+				uid := []byte{0x04, 0, 0, 0, 0, 0, i}
+				var card tag.Card = virtualreader.NewNTAG215(uid)
+				if i == 1 {
+					card = pulled{card}
+				}
+				b.HandleCard(ctx, cardreader.Event{Reader: "r0", UID: uid}, tapped{card})
+			}
+			return ctx.Err()
+		}
+		if err := b.Run(context.Background(), run); err != nil {
+			t.Fatalf("%s: Run() error = %v", tt.name, err)
+		}
+		s := b.Stats()
+		if s.Succeeded != tt.ok || s.Failed != tt.fail || prompts != tt.prompts || s.Elapsed <= 0 || s.TagsPerMinute <= 0 {
+			t.Errorf("%s: Stats() = %+v, %d prompts", tt.name, s, prompts)
+		}
+
+		var csv bytes.Buffer
+		if err := b.WriteCSV(&csv); err != nil {
+			t.Fatalf("%s: WriteCSV() error = %v", tt.name, err)
+		}
+		lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+		if len(lines) != tt.ok+tt.fail+1 || !strings.HasPrefix(lines[0], "time,profile,") || !strings.Contains(lines[1], ",stickers,r0,04000000000000,true,write-ndef verify,") {
+			t.Errorf("%s: WriteCSV() =\n%s", tt.name, csv.String())
+		}
+
+		var js bytes.Buffer
+		if err := b.WriteJSON(&js); err != nil {
+			t.Fatalf("%s: WriteJSON() error = %v", tt.name, err)
+		}
+		var doc struct {
+			Succeeded int
+			Results   []struct {
+				OK    bool
+				Error string
+			}
+		}
+		if err := json.Unmarshal(js.Bytes(), &doc); err != nil || doc.Succeeded != tt.ok || len(doc.Results) != tt.ok+tt.fail || doc.Results[1].OK || doc.Results[1].Error == "" {
+			t.Errorf("%s: WriteJSON() = %s, %v", tt.name, js.String(), err)
+		}
+	}
+}
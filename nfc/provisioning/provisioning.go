@@ -0,0 +1,281 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package provisioning encodes batches of tags following a declarative
+// profile: formatting them, writing an NDEF message, verifying it, setting
+// a password and locking them. A Provisioner runs the profile against every
+// tag tapped, reporting progress and a result record per tag, the core of
+// an encoding station. A Registry of the UIDs provisioned rejects tags
+// encoded before, also in other SDK workflows through Guard.
+package provisioning
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/ntag"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// ErrUnsupported is returned for steps the tag does not support, such as
+// setting a password on other tags than NTAG.
+var ErrUnsupported = errors.New("provisioning: step not supported by the tag")
+
+// ErrVerify is returned when the NDEF message read back differs from the
+// message written.
+var ErrVerify = errors.New("provisioning: ndef message read back differs")
+
+// Step is a stage of provisioning a tag.
+type Step uint8
+
+const (
+	StepFormat    Step = iota + 1 // Write an empty NDEF message.
+	StepWriteNDEF                 // Write the NDEF message of the profile.
+	StepVerify                    // Read the NDEF message back and compare.
+	StepPassword                  // Set the NTAG password protection.
+	StepLock                      // Make the tag permanently read-only.
+)
+
+// String returns the name of the step.
+func (s Step) String() string {
+	switch s {
+	case StepFormat:
+		return "format"
+	case StepWriteNDEF:
+		return "write-ndef"
+	case StepVerify:
+		return "verify"
+	case StepPassword:
+		return "password"
+	case StepLock:
+		return "lock"
+	default:
+		return fmt.Sprintf("step(%d)", uint8(s))
+	}
+}
+
+// Password is the NTAG password protection set by a profile.
+type Password struct {
+	PWD  [4]byte
+	PACK [2]byte
+	// Auth0 is the first page protected by the password.
+	Auth0 byte
+	// ReadProtected requires the password for reads as well as writes.
+	ReadProtected bool
+	// AuthLimit is the number of failed authentications, up to 7, after
+	// which the password is locked for good; 0 allows unlimited attempts.
+	AuthLimit uint8
+}
+
+// Profile declares how tags are provisioned. Its steps run in the order of
+// the Step constants, those not configured are skipped.
+type Profile struct {
+	Name string
+	// Format writes an empty NDEF message first.
+	Format bool
+	// NDEF is the message written to every tag, nil to write none.
+	NDEF *ndef.Message
+	// Message, when set, builds the message written to a tag from its UID
+	// instead of NDEF, e.g. to embed the UID in a URL.
+	Message func(uid []byte) (*ndef.Message, error)
+	// Verify reads the message back after writing it.
+	Verify bool
+	// Password, when set, protects NTAG tags with a password.
+	Password *Password
+	// Lock makes NTAG tags permanently read-only. It cannot be undone.
+	Lock bool
+}
+
+// Steps returns the steps the profile runs.
+func (p *Profile) Steps() []Step {
+	var steps []Step
+	writes := p.NDEF != nil || p.Message != nil
+	for _, s := range []struct {
+		step Step
+		on   bool
+	}{
+		{StepFormat, p.Format},
+		{StepWriteNDEF, writes},
+		{StepVerify, writes && p.Verify},
+		{StepPassword, p.Password != nil},
+		{StepLock, p.Lock},
+	} {
+		if s.on {
+			steps = append(steps, s.step)
+		}
+	}
+	return steps
+}
+
+// Progress reports that the step Step of provisioning a tag starts, after
+// Done of Total steps completed.
+type Progress struct {
+	Reader      string
+	UID         []byte
+	Step        Step
+	Done, Total int
+}
+
+// Result is the record of provisioning a tag.
+type Result struct {
+	Profile  string
+	Reader   string
+	UID      []byte
+	Start    time.Time
+	Duration time.Duration
+	// Steps are the steps completed.
+	Steps []Step
+	// Err is the error of the step which failed, nil when all completed.
+	Err error
+}
+
+// OK reports whether all steps completed.
+func (r Result) OK() bool { return r.Err == nil }
+
+// Provisioner runs a profile against tags.
+type Provisioner struct {
+	Profile Profile
+	// OnProgress, when set, is called before each step.
+	OnProgress func(Progress)
+	// OnResult, when set, is called with the result of each tag.
+	OnResult func(Result)
+	// Registry, when set, rejects tags whose UID it holds with
+	// ErrDuplicate. The UID of every tag is claimed before its first step
+	// and released when a step fails.
+	Registry Registry
+}
+
+// Provision runs the profile on the tag card with the given UID, tapped on
+// reader. It stops at the first step failing or when ctx is done.
+func (p *Provisioner) Provision(ctx context.Context, reader string, uid []byte, card tag.Card) Result {
+	res := Result{Profile: p.Profile.Name, Reader: reader, UID: uid, Start: time.Now()}
+	steps := p.Profile.Steps()
+	var written []byte
+	claimed := false
+	if p.Registry != nil && len(uid) > 0 {
+		var err error
+		claimed, err = p.Registry.Claim(uid)
+		switch {
+		case err != nil:
+			res.Err = fmt.Errorf("register: %w", err)
+			steps = nil
+		case !claimed:
+			res.Err = fmt.Errorf("%X: %w", uid, ErrDuplicate)
+			steps = nil
+		}
+	}
+	for i, step := range steps {
+		if err := ctx.Err(); err != nil {
+			res.Err = err
+			break
+		}
+		if p.OnProgress != nil {
+			p.OnProgress(Progress{Reader: reader, UID: uid, Step: step, Done: i, Total: len(steps)})
+		}
+		var err error
+		switch step {
+		case StepFormat:
+			err = tag.Format(card)
+		case StepWriteNDEF:
+			written, err = p.message(uid)
+			if err == nil {
+				err = tag.WriteNDEF(card, written)
+			}
+		case StepVerify:
+			var got []byte
+			if got, err = tag.ReadNDEF(card); err == nil && !bytes.Equal(got, written) {
+				err = ErrVerify
+			}
+		case StepPassword:
+			err = p.setPassword(card, uid)
+		case StepLock:
+			err = p.lock(card, uid)
+		}
+		if err != nil {
+			res.Err = fmt.Errorf("%s: %w", step, err)
+			break
+		}
+		res.Steps = append(res.Steps, step)
+	}
+	if res.Err != nil && claimed {
+		if err := p.Registry.Release(uid); err != nil {
+			res.Err = errors.Join(res.Err, fmt.Errorf("release: %w", err))
+		}
+	}
+	res.Duration = time.Since(res.Start)
+	if p.OnResult != nil {
+		p.OnResult(res)
+	}
+	return res
+}
+
+// HandleCard provisions the card of ev and returns the error of its result.
+// It has the signature of a scardkit.CardHandler, so a Provisioner can
+// handle the cards of an SDK.
+func (p *Provisioner) HandleCard(ctx context.Context, ev cardreader.Event, card transport.Card) error {
+	return p.Provision(ctx, ev.Reader, ev.UID, card).Err
+}
+
+// message returns the raw NDEF message written to the tag uid.
+func (p *Provisioner) message(uid []byte) ([]byte, error) {
+	msg := p.Profile.NDEF
+	if p.Profile.Message != nil {
+		var err error
+		if msg, err = p.Profile.Message(uid); err != nil {
+			return nil, err
+		}
+	}
+	return msg.Marshal()
+}
+
+// ntagOf returns card as NTAG, failing for other tags.
+func ntagOf(card tag.Card, uid []byte) (*ntag.Tag, error) {
+	typ := tag.Detect(tag.Signature{ATR: card.ATR()})
+	if typ.ForumType() != tag.ForumType2 {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, typ)
+	}
+	return ntag.NewTag(uid, card), nil
+}
+
+func (p *Provisioner) setPassword(card tag.Card, uid []byte) error {
+	t, err := ntagOf(card, uid)
+	if err != nil {
+		return err
+	}
+	pw := p.Profile.Password
+	if err := t.SetPassword(pw.PWD, pw.PACK); err != nil {
+		return err
+	}
+	prot, err := t.Protection()
+	if err != nil {
+		return err
+	}
+	prot.Auth0, prot.ReadProtected, prot.AuthLimit = pw.Auth0, pw.ReadProtected, pw.AuthLimit
+	return t.SetProtection(prot)
+}
+
+// lock locks the tag, first authenticating with the password the profile
+// set, which protects the dynamic lock bytes from AUTH0 on.
+func (p *Provisioner) lock(card tag.Card, uid []byte) error {
+	t, err := ntagOf(card, uid)
+	if err != nil {
+		return err
+	}
+	if pw := p.Profile.Password; pw != nil {
+		pack, err := t.Authenticate(pw.PWD)
+		if err != nil {
+			return err
+		}
+		if pack != pw.PACK {
+			return fmt.Errorf("authenticate: password acknowledge % X, want % X", pack, pw.PACK)
+		}
+	}
+	return t.Lock()
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package provisioning
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/x/virtualreader"
+)
+
+func TestProvision(t *testing.T) {
+	uid := []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	profile := Profile{
+		Name:   "badges",
+		Format: true,
+		Message: func(uid []byte) (*ndef.Message, error) {
+			return ndef.NewMessage(ndef.NewURIRecord(fmt.Sprintf("https://example.com/b/%X", uid))), nil
+		},
+		Verify:   true,
+		Password: &Password{PWD: [4]byte{1, 2, 3, 4}, PACK: [2]byte{0xAB, 0xCD}, Auth0: 4},
+		Lock:     true,
+	}
+
+	tests := []struct {
+		name  string
+		card  tag.Card
+		steps int
+		err   error
+	}{
+		{"ntag215", virtualreader.NewNTAG215(uid), 5, nil},
+		{"desfire", virtualreader.NewDESFire(uid, nil), 3, ErrUnsupported},
+	}
+	for _, tt := range tests {
+		var progress []Step
+		var results []Result
+		p := &Provisioner{
+			Profile:    profile,
+			OnProgress: func(pr Progress) { progress = append(progress, pr.Step) },
+			OnResult:   func(r Result) { results = append(results, r) },
+		}
+		res := p.Provision(context.Background(), "reader", uid, tt.card)
+		if !errors.Is(res.Err, tt.err) || len(res.Steps) != tt.steps || res.Profile != "badges" {
+			t.Errorf("%s: Provision() = %+v, want %d steps and error %v", tt.name, res, tt.steps, tt.err)
+		}
+		if len(results) != 1 || len(progress) != min(tt.steps+1, 5) {
+			t.Errorf("%s: %d results, progress %v", tt.name, len(results), progress)
+		}
+	}
+
+	ntag := virtualreader.NewNTAG215(uid)
+	p := &Provisioner{Profile: profile}
+	if res := p.Provision(context.Background(), "reader", uid, ntag); !res.OK() {
+		t.Fatalf("Provision() error = %v", res.Err)
+	}
+	mem := ntag.Memory()
+	if !bytes.Contains(mem, []byte("example.com/b/04010203040506")) {
+		t.Error("ndef message not written")
+	}
+	if mem[3*4+3] != 0x0F || mem[131*4+3] != 4 || !bytes.Equal(mem[130*4:130*4+3], []byte{0xFF, 0xFF, 0xFF}) {
+		t.Errorf("tag not locked and protected: cc % X, dynamic lock % X, cfg0 % X", mem[12:16], mem[520:524], mem[524:528])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if res := p.Provision(ctx, "reader", uid, ntag); !errors.Is(res.Err, context.Canceled) || len(res.Steps) != 0 {
+		t.Errorf("Provision() with a canceled context = %+v", res)
+	}
+}
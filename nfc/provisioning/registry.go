@@ -0,0 +1,171 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package provisioning
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/happy-sdk/scardkit"
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// ErrDuplicate is returned for tags whose UID was provisioned before.
+var ErrDuplicate = errors.New("provisioning: tag already provisioned")
+
+// Registry records the UIDs of the tags provisioned, so a Provisioner
+// rejects tags encoded before or duplicate UIDs. It is safe for concurrent
+// use.
+type Registry interface {
+	// Claim records uid and reports whether it was not recorded before.
+	// Of concurrent claims of the same UID only one reports true.
+	Claim(uid []byte) (bool, error)
+	// Release removes uid, claimed for a tag which then failed.
+	Release(uid []byte) error
+}
+
+// Guard returns a handler calling next for cards whose UID r had not
+// recorded, claiming it first, so any SDK workflow rejects duplicate tags.
+// Cards recorded before fail with an error wrapping ErrDuplicate, logged by
+// the SDK, after onDuplicate, when set, was called with their event, e.g.
+// to alert the operator. The UID is released when next fails, so the tag
+// may be tapped again.
+func Guard(r Registry, onDuplicate func(ev cardreader.Event), next scardkit.CardHandler) scardkit.CardHandler {
+	return func(ctx context.Context, ev cardreader.Event, card transport.Card) error {
+		if len(ev.UID) == 0 {
+			return next(ctx, ev, card)
+		}
+		claimed, err := r.Claim(ev.UID)
+		if err != nil {
+			return fmt.Errorf("provisioning: claim %X: %w", ev.UID, err)
+		}
+		if !claimed {
+			if onDuplicate != nil {
+				onDuplicate(ev)
+			}
+			return fmt.Errorf("%X: %w", ev.UID, ErrDuplicate)
+		}
+		if err := next(ctx, ev, card); err != nil {
+			if rerr := r.Release(ev.UID); rerr != nil {
+				return errors.Join(err, fmt.Errorf("provisioning: release %X: %w", ev.UID, rerr))
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+// MemoryRegistry is an in-memory Registry lasting a session.
+type MemoryRegistry struct {
+	mu   sync.Mutex
+	uids map[string]bool
+}
+
+// Claim records uid and reports whether it was not recorded before.
+func (r *MemoryRegistry) Claim(uid []byte) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.uids[string(uid)] {
+		return false, nil
+	}
+	if r.uids == nil {
+		r.uids = make(map[string]bool)
+	}
+	r.uids[string(uid)] = true
+	return true, nil
+}
+
+// Release removes uid.
+func (r *MemoryRegistry) Release(uid []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.uids, string(uid))
+	return nil
+}
+
+// Seen reports whether uid is recorded.
+func (r *MemoryRegistry) Seen(uid []byte) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.uids[string(uid)]
+}
+
+// Len returns the number of UIDs recorded.
+func (r *MemoryRegistry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.uids)
+}
+
+// FileRegistry is a Registry persisted to a directory holding an empty file
+// per UID, named by the UID in upper case hex. Files are created
+// exclusively, so duplicates are caught across sessions and across the
+// processes and stations sharing the directory, each claim seeing the
+// claims made elsewhere.
+type FileRegistry struct {
+	dir string
+}
+
+// OpenRegistry opens the registry in directory dir, creating it when
+// missing.
+func OpenRegistry(dir string) (*FileRegistry, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileRegistry{dir: dir}, nil
+}
+
+func (r *FileRegistry) path(uid []byte) string {
+	return filepath.Join(r.dir, strings.ToUpper(hex.EncodeToString(uid)))
+}
+
+// Claim creates the file of uid and reports whether it did not exist.
+func (r *FileRegistry) Claim(uid []byte) (bool, error) {
+	f, err := os.OpenFile(r.path(uid), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, f.Close()
+}
+
+// Release removes the file of uid.
+func (r *FileRegistry) Release(uid []byte) error {
+	err := os.Remove(r.path(uid))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Seen reports whether uid is recorded.
+func (r *FileRegistry) Seen(uid []byte) bool {
+	_, err := os.Stat(r.path(uid))
+	return err == nil
+}
+
+// Len returns the number of UIDs recorded.
+func (r *FileRegistry) Len() (int, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range entries {
+		if _, err := hex.DecodeString(e.Name()); err == nil && e.Type().IsRegular() {
+			n++
+		}
+	}
+	return n, nil
+}
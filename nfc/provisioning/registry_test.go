@@ -0,0 +1,137 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package provisioning
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/transport"
+	"github.com/happy-sdk/scardkit/x/virtualreader"
+)
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "04A1B2C3D4E5F6"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := OpenRegistry(dir)
+	if err != nil {
+		t.Fatalf("OpenRegistry() error = %v", err)
+	}
+
+	p := &Provisioner{Profile: Profile{NDEF: ndef.NewMessage(ndef.NewURIRecord("https://example.com"))}, Registry: reg}
+	failing := removedTag{virtualreader.NewNTAG215([]byte{0x04, 9, 9, 9, 9, 9, 9})}
+	tests := []struct {
+		uid  []byte
+		card tag.Card
+		err  error
+	}{
+		{[]byte{0x04, 0xA1, 0xB2, 0xC3, 0xD4, 0xE5, 0xF6}, nil, ErrDuplicate},
+		{[]byte{0x04, 1, 2, 3, 4, 5, 6}, nil, nil},
+		{[]byte{0x04, 1, 2, 3, 4, 5, 6}, nil, ErrDuplicate},
+		// A failed tag is released, so it may be provisioned again.
+		{[]byte{0x04, 9, 9, 9, 9, 9, 9}, failing, errRemoved},
+		{[]byte{0x04, 9, 9, 9, 9, 9, 9}, nil, nil},
+	}
+	for i, tt := range tests {
+		card := tt.card
+		if card == nil {
+			card = virtualreader.NewNTAG215(tt.uid)
+		}
+		res := p.Provision(context.Background(), "reader", tt.uid, card)
+		if !errors.Is(res.Err, tt.err) {
+			t.Errorf("%d: Provision(%X) error = %v, want %v", i, tt.uid, res.Err, tt.err)
+		}
+	}
+	if n, err := reg.Len(); n != 3 || err != nil {
+		t.Errorf("Len() = %d, %v, want 3", n, err)
+	}
+
+	// Stations sharing the directory see each other's claims.
+	other, err := OpenRegistry(dir)
+	if err != nil {
+		t.Fatalf("OpenRegistry() error = %v", err)
+	}
+	if !other.Seen([]byte{0x04, 1, 2, 3, 4, 5, 6}) {
+		t.Error("other station does not see the claimed uid")
+	}
+	if ok, err := other.Claim([]byte{0x04, 1, 2, 3, 4, 5, 6}); ok || err != nil {
+		t.Errorf("Claim() of a claimed uid = %v, %v", ok, err)
+	}
+}
+
+var errRemoved = errors.New("tag removed")
+
+// removedTag fails every command, like a tag taken off the reader.
+type removedTag struct{ *virtualreader.NTAG }
+
+func (removedTag) Transmit([]byte) ([]byte, error) { return nil, errRemoved }
+
+func TestRegistryClaimRace(t *testing.T) {
+	for _, reg := range []Registry{&MemoryRegistry{}, mustOpenRegistry(t)} {
+		var wins atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if ok, err := reg.Claim([]byte{0x04, 7}); err != nil {
+					t.Error(err)
+				} else if ok {
+					wins.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if n := wins.Load(); n != 1 {
+			t.Errorf("%T: %d concurrent claims succeeded, want 1", reg, n)
+		}
+	}
+}
+
+func mustOpenRegistry(t *testing.T) *FileRegistry {
+	reg, err := OpenRegistry(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
+
+func TestGuard(t *testing.T) {
+	reg := &MemoryRegistry{}
+	var alerts []string
+	fail := errors.New("write failed")
+	var next error
+	h := Guard(reg, func(ev cardreader.Event) { alerts = append(alerts, ev.Reader) }, func(context.Context, cardreader.Event, transport.Card) error {
+		return next
+	})
+	ev := cardreader.Event{Reader: "station", UID: []byte{0x04, 1}}
+	tests := []struct {
+		next, want error
+		alerts     int
+	}{
+		{fail, fail, 0},
+		{nil, nil, 0},
+		{nil, ErrDuplicate, 1},
+	}
+	for i, tt := range tests {
+		next = tt.next
+		if err := h(context.Background(), ev, nil); !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+			t.Errorf("%d: handler error = %v, want %v", i, err, tt.want)
+		}
+		if len(alerts) != tt.alerts {
+			t.Errorf("%d: %d alerts, want %d", i, len(alerts), tt.alerts)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package tag
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
+// Matcher identifies the cards of a card driver. atr is the ATR of the card
+// and historical its historical bytes, nil when the ATR does not parse.
+// probe exchanges commands with the card when it is connected and is nil
+// when only its signature is known; matchers probing it should send
+// commands harmless to the cards of other drivers.
+type Matcher interface {
+	Match(atr, historical []byte, probe apdu.Transceiver) bool
+}
+
+// MatchFunc is a function used as a Matcher.
+type MatchFunc func(atr, historical []byte, probe apdu.Transceiver) bool
+
+// Match calls f.
+func (f MatchFunc) Match(atr, historical []byte, probe apdu.Transceiver) bool {
+	return f(atr, historical, probe)
+}
+
+// driverMatcher is a matcher registered for a tag type.
+type driverMatcher struct {
+	typ     Type
+	matcher Matcher
+}
+
+// RegisterType allocates a new tag type with the given name for a card
+// driver outside this module, typically in an init function. It panics when
+// name is taken or no type is left.
+func RegisterType(name string) Type {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	var last Type
+	for typ, n := range typeNames {
+		if n == name {
+			panic(fmt.Sprintf("tag: type %q registered twice", name))
+		}
+		last = max(last, typ)
+	}
+	if last == math.MaxUint8 {
+		panic("tag: too many tag types")
+	}
+	typeNames[last+1] = name
+	return last + 1
+}
+
+// Register makes d report t for the cards m matches. Matchers are asked in
+// registration order after the override rules and before the built-in
+// decisions, so a driver may also claim cards of a built-in type.
+func (d *Detector) Register(t Type, m Matcher) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.matchers = append(d.matchers, driverMatcher{typ: t, matcher: m})
+}
+
+// DetectCard returns the tag type of card, which registered matchers may
+// probe. Without registered matchers no command is sent.
+func (d *Detector) DetectCard(card Card) Type {
+	return d.detect(Signature{ATR: card.ATR()}, card)
+}
+
+// Register registers a matcher with the Default detector.
+func Register(t Type, m Matcher) { Default.Register(t, m) }
+
+// DetectCard returns the tag type of card using the Default detector.
+func DetectCard(card Card) Type { return Default.DetectCard(card) }
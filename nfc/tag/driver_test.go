@@ -0,0 +1,81 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package tag
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
+// probedCard answers SELECT of the AID of a custom applet.
+type probedCard struct {
+	atr   []byte
+	sent  int
+	known bool
+}
+
+func (c *probedCard) ATR() []byte { return c.atr }
+
+func (c *probedCard) Transmit(cmd []byte) ([]byte, error) {
+	c.sent++
+	if c.known && bytes.Equal(cmd, []byte{0x00, 0xA4, 0x04, 0x00, 0x03, 0xF0, 0x01, 0x02, 0x00}) {
+		return []byte{0x90, 0x00}, nil
+	}
+	return []byte{0x6A, 0x82}, nil
+}
+
+func TestRegisterType(t *testing.T) {
+	typ := RegisterType("acme-badge")
+	if typ.String() != "acme-badge" {
+		t.Errorf("String() = %q", typ)
+	}
+	var got Type
+	if err := got.UnmarshalText([]byte("acme-badge")); err != nil || got != typ {
+		t.Errorf("UnmarshalText() = %v, %v", got, err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterType() accepted a taken name")
+		}
+	}()
+	RegisterType("ntag")
+}
+
+func TestDetectorRegister(t *testing.T) {
+	applet := RegisterType("acme-applet")
+	d := NewDetector()
+	d.Register(applet, MatchFunc(func(atr, hist []byte, probe apdu.Transceiver) bool {
+		if probe == nil || !bytes.Equal(hist, []byte{0x80}) {
+			return false
+		}
+		resp, err := probe.Transmit([]byte{0x00, 0xA4, 0x04, 0x00, 0x03, 0xF0, 0x01, 0x02, 0x00})
+		return err == nil && apdu.CheckStatusFromData(resp) == nil
+	}))
+
+	desfire := mustHex("3B8180018080")
+	tests := []struct {
+		name string
+		card *probedCard
+		want Type
+	}{
+		{"applet", &probedCard{atr: desfire, known: true}, applet},
+		{"other card", &probedCard{atr: desfire}, TypeDESFire},
+		{"not probed", &probedCard{atr: mustHex("3B8F8001804F0CA0000003060300030000000068")}, TypeUltralight},
+	}
+	for _, tt := range tests {
+		if got := d.DetectCard(tt.card); got != tt.want {
+			t.Errorf("%s: DetectCard() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if got := d.Detect(Signature{ATR: desfire}); got != TypeDESFire {
+		t.Errorf("Detect() without probe = %v", got)
+	}
+	card := &probedCard{atr: desfire}
+	if NewDetector().DetectCard(card); card.sent != 0 {
+		t.Errorf("DetectCard() without matchers sent %d commands", card.sent)
+	}
+}
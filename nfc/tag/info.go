@@ -0,0 +1,413 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package tag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/sckutils"
+)
+
+// Card is a connected card the tag operations work on. pcsc.Card
+// implements it.
+type Card interface {
+	apdu.Transceiver
+	ATR() []byte
+}
+
+// ForumType is the NFC Forum tag type of a tag.
+type ForumType uint8
+
+const (
+	ForumTypeNone ForumType = 0 // Not an NFC Forum tag.
+	ForumType2    ForumType = 2
+	ForumType3    ForumType = 3
+	ForumType4    ForumType = 4
+	ForumType5    ForumType = 5
+)
+
+// ForumType returns the NFC Forum tag type of tags of type t.
+func (t Type) ForumType() ForumType {
+	switch t {
+	case TypeUltralight, TypeUltralightC, TypeNTAG:
+		return ForumType2
+	case TypeFeliCa:
+		return ForumType3
+	case TypeDESFire, TypeISODEP:
+		return ForumType4
+	case TypeISO15693:
+		return ForumType5
+	default:
+		return ForumTypeNone
+	}
+}
+
+// ErrUnsupported is returned for tags whose NFC Forum type is not supported
+// by the operation.
+var ErrUnsupported = errors.New("tag: unsupported tag type")
+
+// ErrNotFormatted is returned for tags not formatted for NDEF.
+var ErrNotFormatted = errors.New("tag: not formatted for ndef")
+
+// Info describes the NDEF capabilities of a tag.
+type Info struct {
+	Type       Type
+	ForumType  ForumType
+	Capacity   int  // Maximum NDEF message length in bytes.
+	Writable   bool // The NDEF message may be written.
+	NDEFLength int  // Length of the current NDEF message in bytes.
+}
+
+// ReadInfo detects the tag type of card with the Default detector and
+// reports its NDEF capacity, writability and current message length, so
+// applications can decide how much data fits before writing. Tags not
+// formatted for NDEF return ErrNotFormatted.
+func ReadInfo(card Card) (*Info, error) { return Default.ReadInfo(card) }
+
+// Format prepares a tag for NDEF like Detector.Format, detecting its tag
+// type with the Default detector.
+func Format(card Card) error { return Default.Format(card) }
+
+// ReadInfo is like the package level ReadInfo but detects the tag type of
+// card from its ATR with d, honouring its override rules.
+func (d *Detector) ReadInfo(card Card) (*Info, error) {
+	typ := d.Detect(Signature{ATR: card.ATR()})
+	info := &Info{Type: typ, ForumType: typ.ForumType()}
+	var err error
+	switch info.ForumType {
+	case ForumType2:
+		err = readType2Info(card, info)
+	case ForumType3:
+		err = readType3Info(card, info)
+	case ForumType4:
+		err = readType4Info(card, info)
+	case ForumType5:
+		err = readType5Info(card, info)
+	default:
+		return info, fmt.Errorf("%w: %s", ErrUnsupported, typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Format prepares a tag for NDEF by writing an empty NDEF message. Type 2
+// tags must carry a valid capability container, which is one-time
+// programmable and factory set on NTAG, and keep the Lock and Memory
+// Control TLVs leading their data area; Type 3 tags must carry a valid
+// attribute information block; Type 4 tags must contain the NDEF
+// application; Type 5 tags must carry a valid capability container. The
+// tag type is detected like ReadInfo.
+func (d *Detector) Format(card Card) error {
+	info, err := d.ReadInfo(card)
+	if err != nil {
+		return err
+	}
+	if !info.Writable {
+		return fmt.Errorf("tag: %s is read-only", info.Type)
+	}
+	switch info.ForumType {
+	case ForumType2:
+		start, err := type2ControlEnd(card, info.Capacity)
+		if err != nil {
+			return err
+		}
+		return writeType2Data(card, start, []byte{0x03, 0x00, 0xFE}, nil)
+	case ForumType3:
+		return writeType3NDEF(card, nil, nil)
+	case ForumType4:
+		return updateBinary(card, 0, []byte{0x00, 0x00})
+	case ForumType5:
+		return writeType5NDEF(card, nil, nil)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupported, info.Type)
+	}
+}
+
+// Type 2 Tag memory layout.
+const (
+	type2PageSize  = 4
+	type2CCPage    = 3
+	type2DataStart = 4
+)
+
+func readType2Page(card Card, page int) ([]byte, error) {
+	resp, err := card.Transmit([]byte{0xFF, 0xB0, 0x00, byte(page), type2PageSize})
+	if err != nil {
+		return nil, fmt.Errorf("tag: read page %d: %w", page, err)
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		return nil, fmt.Errorf("tag: read page %d: %w", page, err)
+	}
+	if len(resp) < type2PageSize+2 {
+		return nil, fmt.Errorf("tag: read page %d: short response", page)
+	}
+	return resp[:type2PageSize], nil
+}
+
+func writeType2Page(card Card, page int, data []byte) error {
+	resp, err := card.Transmit(append([]byte{0xFF, 0xD6, 0x00, byte(page), type2PageSize}, data...))
+	if err != nil {
+		return fmt.Errorf("tag: write page %d: %w", page, err)
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		return fmt.Errorf("tag: write page %d: %w", page, err)
+	}
+	return nil
+}
+
+func readType2Info(card Card, info *Info) error {
+	var err error
+	info.Capacity, info.Writable, err = readType2CC(card)
+	if err != nil {
+		return err
+	}
+	_, info.NDEFLength, err = findType2NDEF(card, info.Capacity)
+	return err
+}
+
+// readType2CC returns the data area size and write access announced by the
+// capability container of a Type 2 Tag.
+func readType2CC(card Card) (capacity int, writable bool, err error) {
+	cc, err := readType2Page(card, type2CCPage)
+	if err != nil {
+		return 0, false, err
+	}
+	if cc[0] != 0xE1 {
+		return 0, false, ErrNotFormatted
+	}
+	return int(cc[2]) * 8, cc[3]&0x0F == 0x00, nil
+}
+
+// findType2NDEF walks the TLV blocks of the data area up to the NDEF message
+// TLV and returns the offset of its value within the data area and its
+// length. A terminator TLV before any NDEF message TLV, or the end of the
+// data area, yields a zero length.
+func findType2NDEF(card Card, capacity int) (offset, length int, err error) {
+	d := &type2Data{card: card, capacity: capacity}
+	for pos := 0; pos < capacity; {
+		if err := d.need(pos + 1); err != nil {
+			return 0, 0, err
+		}
+		switch d.buf[pos] {
+		case 0x00: // NULL TLV
+			pos++
+			continue
+		case 0xFE: // Terminator TLV
+			return 0, 0, nil
+		}
+		l, hdr, err := d.tlvLength(pos)
+		if err != nil {
+			return 0, 0, err
+		}
+		if d.buf[pos] == 0x03 {
+			if pos+hdr+l > capacity {
+				return 0, 0, fmt.Errorf("tag: ndef message exceeds data area")
+			}
+			return pos + hdr, l, nil
+		}
+		pos += hdr + l
+	}
+	return 0, 0, nil
+}
+
+// type2ControlEnd returns the offset within the data area following the
+// Lock Control, Memory Control and proprietary TLVs leading it, where the
+// NDEF message TLV is written.
+func type2ControlEnd(card Card, capacity int) (int, error) {
+	d := &type2Data{card: card, capacity: capacity}
+	end := 0
+	for pos := 0; pos < capacity; {
+		if err := d.need(pos + 1); err != nil {
+			return 0, err
+		}
+		switch d.buf[pos] {
+		case 0x00: // NULL TLV
+			pos++
+			continue
+		case 0x01, 0x02, 0xFD: // Lock Control, Memory Control and proprietary TLVs
+		default:
+			return end, nil
+		}
+		l, hdr, err := d.tlvLength(pos)
+		if err != nil {
+			return 0, err
+		}
+		pos += hdr + l
+		end = pos
+	}
+	if end > capacity {
+		return 0, fmt.Errorf("tag: tlv exceeds data area")
+	}
+	return end, nil
+}
+
+// tlvLength decodes the length field b of a TLV, returning the length of
+// the value and of the type and length fields.
+func tlvLength(b []byte) (l, hdr int) {
+	if b[0] == 0xFF {
+		return int(binary.BigEndian.Uint16(b[1:])), 4
+	}
+	return int(b[0]), 2
+}
+
+// type2Data reads the data area of a Type 2 Tag page by page as needed.
+type type2Data struct {
+	card     Card
+	capacity int
+	buf      []byte
+}
+
+// need reads pages until the first n bytes of the data area are buffered.
+func (d *type2Data) need(n int) error {
+	for len(d.buf) < n {
+		if len(d.buf) >= d.capacity {
+			return fmt.Errorf("tag: tlv exceeds data area")
+		}
+		p, err := readType2Page(d.card, type2DataStart+len(d.buf)/type2PageSize)
+		if err != nil {
+			return err
+		}
+		d.buf = append(d.buf, p...)
+	}
+	return nil
+}
+
+// tlvLength reads the length field of the TLV at pos, reading only the
+// three byte length format when the length byte announces it.
+func (d *type2Data) tlvLength(pos int) (l, hdr int, err error) {
+	if err := d.need(pos + 2); err != nil {
+		return 0, 0, err
+	}
+	if d.buf[pos+1] == 0xFF {
+		if err := d.need(pos + 4); err != nil {
+			return 0, 0, err
+		}
+	}
+	l, hdr = tlvLength(d.buf[pos+1:])
+	return l, hdr, nil
+}
+
+// writeType2Data writes data at offset off of the data area, keeping the
+// bytes preceding it in its first page, and reports the bytes of data
+// written after each page to progress.
+func writeType2Data(card Card, off int, data []byte, progress sckutils.ProgressFunc) error {
+	page := type2DataStart + off/type2PageSize
+	total, skip := len(data), off%type2PageSize
+	if skip > 0 {
+		head, err := readType2Page(card, page)
+		if err != nil {
+			return err
+		}
+		data = append(head[:skip:skip], data...)
+	}
+	for i := 0; i < len(data); i += type2PageSize {
+		p := make([]byte, type2PageSize)
+		copy(p, data[i:])
+		if err := writeType2Page(card, page+i/type2PageSize, p); err != nil {
+			return err
+		}
+		progress.Report(min(i+type2PageSize-skip, total), total)
+	}
+	return nil
+}
+
+// Type 4 Tag application and file identifiers.
+var (
+	type4AID    = []byte{0xD2, 0x76, 0x00, 0x00, 0x85, 0x01, 0x01}
+	type4CCFile = []byte{0xE1, 0x03}
+)
+
+func transmitOK(card Card, cmd []byte) ([]byte, error) {
+	resp, err := card.Transmit(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		return nil, err
+	}
+	return resp[:len(resp)-2], nil
+}
+
+// selectFile selects a file by identifier (p1 0x00) or an application by
+// name (p1 0x04) as required by the Type 4 Tag specification.
+func selectFile(card Card, p1 byte, id []byte) error {
+	var cmd []byte
+	if p1 == 0x04 {
+		cmd = append(append([]byte{0x00, 0xA4, 0x04, 0x00, byte(len(id))}, id...), 0x00)
+	} else {
+		cmd = append([]byte{0x00, 0xA4, p1, 0x0C, byte(len(id))}, id...)
+	}
+	_, err := transmitOK(card, cmd)
+	return err
+}
+
+func readBinary(card Card, offset, n int) ([]byte, error) {
+	return transmitOK(card, []byte{0x00, 0xB0, byte(offset >> 8), byte(offset), byte(n)})
+}
+
+func updateBinary(card Card, offset int, data []byte) error {
+	cmd := append([]byte{0x00, 0xD6, byte(offset >> 8), byte(offset), byte(len(data))}, data...)
+	_, err := transmitOK(card, cmd)
+	return err
+}
+
+// type4File describes the NDEF file of a Type 4 Tag as announced by its
+// capability container.
+type type4File struct {
+	capacity int  // Maximum NDEF message length.
+	writable bool // Write access granted.
+	mle, mlc int  // Maximum R-APDU and C-APDU data sizes.
+}
+
+// openType4 selects the NDEF application, reads the capability container and
+// leaves the NDEF file selected.
+func openType4(card Card) (*type4File, error) {
+	if err := selectFile(card, 0x04, type4AID); err != nil {
+		return nil, fmt.Errorf("%w: select ndef application: %s", ErrNotFormatted, err)
+	}
+	if err := selectFile(card, 0x00, type4CCFile); err != nil {
+		return nil, fmt.Errorf("tag: select capability container: %w", err)
+	}
+	cc, err := readBinary(card, 0, 15)
+	if err != nil {
+		return nil, fmt.Errorf("tag: read capability container: %w", err)
+	}
+	if len(cc) < 15 || cc[7] != 0x04 || cc[8] < 0x06 {
+		return nil, fmt.Errorf("tag: invalid capability container")
+	}
+	f := &type4File{
+		capacity: int(binary.BigEndian.Uint16(cc[11:13])) - 2,
+		writable: cc[14] == 0x00,
+		mle:      int(binary.BigEndian.Uint16(cc[3:5])),
+		mlc:      int(binary.BigEndian.Uint16(cc[5:7])),
+	}
+	if f.mle < 1 || f.mlc < 1 {
+		return nil, fmt.Errorf("tag: invalid capability container")
+	}
+	if err := selectFile(card, 0x00, cc[9:11]); err != nil {
+		return nil, fmt.Errorf("tag: select ndef file: %w", err)
+	}
+	return f, nil
+}
+
+func readType4Info(card Card, info *Info) error {
+	f, err := openType4(card)
+	if err != nil {
+		return err
+	}
+	info.Capacity = f.capacity
+	info.Writable = f.writable
+	nlen, err := readBinary(card, 0, 2)
+	if err != nil || len(nlen) != 2 {
+		return fmt.Errorf("tag: read ndef length: %v", err)
+	}
+	info.NDEFLength = int(binary.BigEndian.Uint16(nlen))
+	return nil
+}
@@ -0,0 +1,268 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package tag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+var (
+	swOK       = []byte{0x90, 0x00}
+	swNotFound = []byte{0x6A, 0x82}
+)
+
+// fakeType2 emulates a Type 2 Tag behind a PC/SC reader.
+type fakeType2 struct{ mem []byte }
+
+func (f *fakeType2) ATR() []byte { return atrHex("3B8F8001804F0CA00000030603000300000000") }
+
+func (f *fakeType2) Transmit(cmd []byte) ([]byte, error) {
+	off := int(cmd[3]) * 4
+	if off+4 > len(f.mem) {
+		return swNotFound, nil
+	}
+	if cmd[1] == 0xD6 {
+		copy(f.mem[off:], cmd[5:9])
+		return swOK, nil
+	}
+	return append(append([]byte(nil), f.mem[off:off+4]...), swOK...), nil
+}
+
+// fakeType4 emulates a Type 4 Tag with a capability container and an NDEF file.
+type fakeType4 struct {
+	selected []byte
+	files    map[string][]byte
+}
+
+func newFakeType4(ndef []byte, writeAccess byte) *fakeType4 {
+	cc := []byte{0x00, 0x0F, 0x20, 0x00, 0x3B, 0x00, 0x34, 0x04, 0x06, 0xE1, 0x04, 0x08, 0x00, 0x00, writeAccess}
+	file := make([]byte, 0x0800)
+	file[0], file[1] = byte(len(ndef)>>8), byte(len(ndef))
+	copy(file[2:], ndef)
+	return &fakeType4{files: map[string][]byte{"\xE1\x03": cc, "\xE1\x04": file}}
+}
+
+func (f *fakeType4) ATR() []byte { return mustHex("3B8180018080") }
+
+func (f *fakeType4) Transmit(cmd []byte) ([]byte, error) {
+	switch cmd[1] {
+	case 0xA4:
+		id := cmd[5 : 5+int(cmd[4])]
+		if cmd[2] == 0x04 {
+			if !bytes.Equal(id, type4AID) {
+				return swNotFound, nil
+			}
+			return swOK, nil
+		}
+		if _, ok := f.files[string(id)]; !ok {
+			return swNotFound, nil
+		}
+		f.selected = id
+		return swOK, nil
+	case 0xB0:
+		file := f.files[string(f.selected)]
+		off := int(cmd[2])<<8 | int(cmd[3])
+		return append(append([]byte(nil), file[off:off+int(cmd[4])]...), swOK...), nil
+	case 0xD6:
+		file := f.files[string(f.selected)]
+		off := int(cmd[2])<<8 | int(cmd[3])
+		copy(file[off:], cmd[5:5+int(cmd[4])])
+		return swOK, nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+// fakeType3 emulates a Type 3 Tag behind the direct transmit of a PC/SC
+// reader, mem holding its NDEF service blocks.
+type fakeType3 struct {
+	idm []byte
+	mem []byte
+}
+
+func newFakeType3(ndef []byte) *fakeType3 {
+	f := &fakeType3{idm: []byte{0x01, 0x2E, 0x3C, 0x4A, 0x5B, 0x6D, 0x7E, 0x8F}, mem: make([]byte, 14*16)}
+	attr := []byte{0x10, 0x04, 0x01, 0x00, 0x0D, 0, 0, 0, 0, 0x00, 0x01, 0, 0, byte(len(ndef))}
+	copy(f.mem, attr)
+	binary.BigEndian.PutUint16(f.mem[14:], type3Checksum(attr))
+	copy(f.mem[16:], ndef)
+	return f
+}
+
+func (f *fakeType3) ATR() []byte { return atrHex("3B8F8001804F0CA00000030611003B00000000") }
+
+func (f *fakeType3) Transmit(cmd []byte) ([]byte, error) {
+	if cmd[1] == 0xCA {
+		return append(append([]byte(nil), f.idm...), swOK...), nil
+	}
+	frame := cmd[5:]
+	n := int(frame[13])
+	data := frame[14+2*n:]
+	resp := append([]byte{0, frame[1] + 1}, f.idm...)
+	resp = append(resp, 0x00, 0x00)
+	if frame[1] == 0x06 {
+		resp = append(resp, byte(n))
+	}
+	for i := 0; i < n; i++ {
+		off := int(frame[15+2*i]) * 16
+		if frame[1] == 0x08 {
+			copy(f.mem[off:off+16], data[i*16:])
+		} else {
+			resp = append(resp, f.mem[off:off+16]...)
+		}
+	}
+	resp[0] = byte(len(resp))
+	return append(resp, swOK...), nil
+}
+
+// fakeType5 emulates a Type 5 Tag with 4 byte blocks behind the direct
+// transmit of a PC/SC reader.
+type fakeType5 struct{ mem []byte }
+
+func (f *fakeType5) ATR() []byte { return atrHex("3B8F8001804F0CA0000003060B001400000000") }
+
+func (f *fakeType5) Transmit(cmd []byte) ([]byte, error) {
+	frame := cmd[5:]
+	resp := []byte{0x00}
+	switch frame[1] {
+	case 0x01: // Inventory
+		resp = append(resp, 0x00, 0xE0, 0x04, 0x01, 0x50, 0x11, 0x22, 0x33, 0x44)
+	case 0x23: // Read Multiple Blocks
+		first, n := int(frame[10]), int(frame[11])+1
+		resp = append(resp, f.mem[first*4:(first+n)*4]...)
+	case 0x21: // Write Single Block
+		copy(f.mem[int(frame[10])*4:], frame[11:15])
+	default:
+		resp = []byte{0x01, 0x01}
+	}
+	return append(resp, swOK...), nil
+}
+
+func TestReadInfoType2(t *testing.T) {
+	mem := make([]byte, 135*4)
+	copy(mem[12:], []byte{0xE1, 0x10, 0x3E, 0x00})
+	copy(mem[16:], []byte{0x01, 0x03, 0xA0, 0x0C, 0x34, 0x03, 0x10, 0xD1})
+	card := &fakeType2{mem: mem}
+
+	info, err := ReadInfo(card)
+	if err != nil {
+		t.Fatalf("ReadInfo() error = %v", err)
+	}
+	want := Info{Type: TypeUltralight, ForumType: ForumType2, Capacity: 496, Writable: true, NDEFLength: 0x10}
+	if *info != want {
+		t.Errorf("ReadInfo() = %+v, want %+v", *info, want)
+	}
+
+	if err := Format(card); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if info, err := ReadInfo(card); err != nil || info.NDEFLength != 0 {
+		t.Errorf("ReadInfo() after Format = %+v, %v; want empty message", info, err)
+	}
+	if want := []byte{0x01, 0x03, 0xA0, 0x0C, 0x34, 0x03, 0x00, 0xFE}; !bytes.Equal(card.mem[16:24], want) {
+		t.Errorf("data area after Format = % X, want % X", card.mem[16:24], want)
+	}
+
+	card.mem[12] = 0x00
+	if _, err := ReadInfo(card); !errors.Is(err, ErrNotFormatted) {
+		t.Errorf("ReadInfo() unformatted error = %v, want %v", err, ErrNotFormatted)
+	}
+}
+
+func TestReadInfoType4(t *testing.T) {
+	card := newFakeType4([]byte{0xD1, 0x01, 0x01, 'U', 0x00}, 0x00)
+	info, err := ReadInfo(card)
+	if err != nil {
+		t.Fatalf("ReadInfo() error = %v", err)
+	}
+	want := Info{Type: TypeDESFire, ForumType: ForumType4, Capacity: 0x07FE, Writable: true, NDEFLength: 5}
+	if *info != want {
+		t.Errorf("ReadInfo() = %+v, want %+v", *info, want)
+	}
+
+	if err := Format(card); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if info, _ := ReadInfo(card); info.NDEFLength != 0 {
+		t.Errorf("NDEFLength after Format = %d, want 0", info.NDEFLength)
+	}
+
+	if err := Format(newFakeType4(nil, 0xFF)); err == nil {
+		t.Error("Format() of a read-only tag succeeded")
+	}
+}
+
+func TestReadInfoType2Blank(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte // At the end of the data area.
+	}{
+		{"blank", nil},
+		{"empty message at end", []byte{0x03, 0x00, 0xFE}},
+	}
+	for _, tt := range tests {
+		mem := make([]byte, 20*4)
+		copy(mem[12:], []byte{0xE1, 0x10, 0x06, 0x00})
+		copy(mem[16+48-len(tt.data):], tt.data)
+		card := &fakeType2{mem: mem}
+
+		info, err := ReadInfo(card)
+		if err != nil {
+			t.Fatalf("%s: ReadInfo() error = %v", tt.name, err)
+		}
+		if info.Capacity != 48 || info.NDEFLength != 0 {
+			t.Errorf("%s: ReadInfo() = %+v, want capacity 48 and empty message", tt.name, *info)
+		}
+		if msg, err := ReadNDEF(card); err != nil || len(msg) != 0 {
+			t.Errorf("%s: ReadNDEF() = % X, %v; want empty message", tt.name, msg, err)
+		}
+		if err := Format(card); err != nil {
+			t.Fatalf("%s: Format() error = %v", tt.name, err)
+		}
+		if want := []byte{0x03, 0x00, 0xFE}; !bytes.Equal(card.mem[16:19], want) {
+			t.Errorf("%s: data area after Format = % X, want % X", tt.name, card.mem[16:19], want)
+		}
+	}
+}
+
+func TestReadInfoType3And5(t *testing.T) {
+	msg := []byte{0xD1, 0x01, 0x01, 'U', 0x00}
+	type5 := &fakeType5{mem: make([]byte, 132*4)}
+	copy(type5.mem, []byte{0xE1, 0x40, 0x40, 0x00, 0x03, byte(len(msg))})
+	copy(type5.mem[6:], msg)
+	tests := []struct {
+		card Card
+		want Info
+	}{
+		{newFakeType3(msg), Info{Type: TypeFeliCa, ForumType: ForumType3, Capacity: 208, Writable: true, NDEFLength: 5}},
+		{type5, Info{Type: TypeISO15693, ForumType: ForumType5, Capacity: 512, Writable: true, NDEFLength: 5}},
+	}
+	for _, tt := range tests {
+		info, err := ReadInfo(tt.card)
+		if err != nil {
+			t.Fatalf("%s: ReadInfo() error = %v", tt.want.Type, err)
+		}
+		if *info != tt.want {
+			t.Errorf("ReadInfo() = %+v, want %+v", *info, tt.want)
+		}
+		if err := Format(tt.card); err != nil {
+			t.Fatalf("%s: Format() error = %v", tt.want.Type, err)
+		}
+		if info, err := ReadInfo(tt.card); err != nil || info.NDEFLength != 0 {
+			t.Errorf("%s: ReadInfo() after Format = %+v, %v; want empty message", tt.want.Type, info, err)
+		}
+	}
+
+	type3 := newFakeType3(nil)
+	type3.mem[0] = 0x00
+	if _, err := ReadInfo(type3); !errors.Is(err, ErrNotFormatted) {
+		t.Errorf("ReadInfo() unformatted type 3 error = %v, want %v", err, ErrNotFormatted)
+	}
+	if _, err := ReadInfo(&fakeType5{mem: make([]byte, 132*4)}); !errors.Is(err, ErrNotFormatted) {
+		t.Errorf("ReadInfo() unformatted type 5 error = %v, want %v", err, ErrNotFormatted)
+	}
+}
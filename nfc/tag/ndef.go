@@ -0,0 +1,176 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package tag
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/sckutils"
+)
+
+// ReadNDEF detects the tag type of card with the Default detector and
+// returns the raw NDEF message stored on it. An empty message yields an
+// empty slice.
+func ReadNDEF(card Card) ([]byte, error) { return Default.ReadNDEF(card, nil) }
+
+// WriteNDEF detects the tag type of card with the Default detector and
+// replaces the NDEF message stored on it with msg, the raw encoding of an
+// NDEF message.
+func WriteNDEF(card Card, msg []byte) error { return Default.WriteNDEF(card, msg, nil) }
+
+// ReadNDEF detects the tag type of card from its ATR, honouring the
+// override rules of d, and returns the raw NDEF message stored on it. An
+// empty message yields an empty slice. When progress is not nil, it is
+// reported the message bytes read after each exchange, so large messages
+// can be shown with a progress bar.
+func (d *Detector) ReadNDEF(card Card, progress sckutils.ProgressFunc) ([]byte, error) {
+	typ := d.Detect(Signature{ATR: card.ATR()})
+	switch typ.ForumType() {
+	case ForumType2:
+		return readType2NDEF(card, progress)
+	case ForumType3:
+		return readType3NDEF(card, progress)
+	case ForumType4:
+		return readType4NDEF(card, progress)
+	case ForumType5:
+		return readType5NDEF(card, progress)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, typ)
+	}
+}
+
+// WriteNDEF detects the tag type of card like ReadNDEF and replaces the
+// NDEF message stored on it with msg, the raw encoding of an NDEF message.
+// When progress is not nil, it is reported the message bytes written after
+// each exchange.
+func (d *Detector) WriteNDEF(card Card, msg []byte, progress sckutils.ProgressFunc) error {
+	typ := d.Detect(Signature{ATR: card.ATR()})
+	switch typ.ForumType() {
+	case ForumType2:
+		return writeType2NDEF(card, msg, progress)
+	case ForumType3:
+		return writeType3NDEF(card, msg, progress)
+	case ForumType4:
+		return writeType4NDEF(card, msg, progress)
+	case ForumType5:
+		return writeType5NDEF(card, msg, progress)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupported, typ)
+	}
+}
+
+func readType2NDEF(card Card, progress sckutils.ProgressFunc) ([]byte, error) {
+	capacity, _, err := readType2CC(card)
+	if err != nil {
+		return nil, err
+	}
+	off, n, err := findType2NDEF(card, capacity)
+	if err != nil {
+		return nil, err
+	}
+	msg := make([]byte, 0, n+type2PageSize)
+	for page := type2DataStart + off/type2PageSize; len(msg) < off%type2PageSize+n; page++ {
+		p, err := readType2Page(card, page)
+		if err != nil {
+			return nil, err
+		}
+		msg = append(msg, p...)
+		progress.Report(min(len(msg)-off%type2PageSize, n), n)
+	}
+	return msg[off%type2PageSize : off%type2PageSize+n], nil
+}
+
+func writeType2NDEF(card Card, msg []byte, progress sckutils.ProgressFunc) error {
+	capacity, writable, err := readType2CC(card)
+	if err != nil {
+		return err
+	}
+	if !writable {
+		return fmt.Errorf("tag: ndef data area is read-only")
+	}
+	start, err := type2ControlEnd(card, capacity)
+	if err != nil {
+		return err
+	}
+	tlv := ndefTLV(msg)
+	hdr := len(tlv) - len(msg)
+	if start+len(tlv) > capacity {
+		return fmt.Errorf("tag: %d byte message exceeds %d byte capacity", len(msg), capacity-start)
+	}
+	if start+len(tlv) < capacity {
+		tlv = append(tlv, 0xFE)
+	}
+	return writeType2Data(card, start, tlv, func(done, _ int) {
+		progress.Report(min(max(done-hdr, 0), len(msg)), len(msg))
+	})
+}
+
+// ndefTLV encodes msg as NDEF message TLV.
+func ndefTLV(msg []byte) []byte {
+	if len(msg) < 0xFF {
+		return append([]byte{0x03, byte(len(msg))}, msg...)
+	}
+	return append([]byte{0x03, 0xFF, byte(len(msg) >> 8), byte(len(msg))}, msg...)
+}
+
+func readType4NDEF(card Card, progress sckutils.ProgressFunc) ([]byte, error) {
+	f, err := openType4(card)
+	if err != nil {
+		return nil, err
+	}
+	nlen, err := readBinary(card, 0, 2)
+	if err != nil || len(nlen) != 2 {
+		return nil, fmt.Errorf("tag: read ndef length: %v", err)
+	}
+	n := int(binary.BigEndian.Uint16(nlen))
+	if n > f.capacity {
+		return nil, fmt.Errorf("tag: ndef length %d exceeds capacity %d", n, f.capacity)
+	}
+	chunk := min(f.mle, 0xFF)
+	msg := make([]byte, 0, n)
+	for len(msg) < n {
+		data, err := readBinary(card, 2+len(msg), min(chunk, n-len(msg)))
+		if err != nil {
+			return nil, fmt.Errorf("tag: read ndef file: %w", err)
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("tag: read ndef file: empty response")
+		}
+		msg = append(msg, data...)
+		progress.Report(min(len(msg), n), n)
+	}
+	return msg[:n], nil
+}
+
+// writeType4NDEF writes msg following the update procedure of the Type 4
+// Tag specification: the length is cleared first and set last, so an
+// interrupted write leaves an empty message rather than a corrupt one.
+func writeType4NDEF(card Card, msg []byte, progress sckutils.ProgressFunc) error {
+	f, err := openType4(card)
+	if err != nil {
+		return err
+	}
+	if !f.writable {
+		return fmt.Errorf("tag: ndef file is read-only")
+	}
+	if len(msg) > f.capacity {
+		return fmt.Errorf("tag: %d byte message exceeds %d byte capacity", len(msg), f.capacity)
+	}
+	if err := updateBinary(card, 0, []byte{0x00, 0x00}); err != nil {
+		return fmt.Errorf("tag: clear ndef length: %w", err)
+	}
+	chunk := min(f.mlc, 0xFF)
+	for off := 0; off < len(msg); off += chunk {
+		if err := updateBinary(card, 2+off, msg[off:min(off+chunk, len(msg))]); err != nil {
+			return fmt.Errorf("tag: write ndef file: %w", err)
+		}
+		progress.Report(min(off+chunk, len(msg)), len(msg))
+	}
+	if err := updateBinary(card, 0, []byte{byte(len(msg) >> 8), byte(len(msg))}); err != nil {
+		return fmt.Errorf("tag: set ndef length: %w", err)
+	}
+	return nil
+}
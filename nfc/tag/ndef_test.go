@@ -0,0 +1,153 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package tag
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNDEFRoundTrip(t *testing.T) {
+	type2 := func() Card {
+		mem := make([]byte, 135*4)
+		copy(mem[12:], []byte{0xE1, 0x10, 0x3E, 0x00})
+		copy(mem[16:], []byte{0x03, 0x00, 0xFE})
+		return &fakeType2{mem: mem}
+	}
+	// A Lock Control TLV and a Memory Control TLV lead the data area.
+	controlled := func() Card {
+		mem := make([]byte, 135*4)
+		copy(mem[12:], []byte{0xE1, 0x10, 0x3E, 0x00})
+		copy(mem[16:], []byte{0x01, 0x03, 0xA0, 0x0C, 0x34, 0x02, 0x03, 0xB0, 0x10, 0x44, 0x03, 0x00, 0xFE})
+		return &fakeType2{mem: mem}
+	}
+	type4 := func() Card { return newFakeType4(nil, 0x00) }
+	type3 := func() Card { return newFakeType3(nil) }
+	type5 := func() Card {
+		mem := make([]byte, 132*4)
+		copy(mem, []byte{0xE1, 0x40, 0x40, 0x00, 0x03, 0x00, 0xFE})
+		return &fakeType5{mem: mem}
+	}
+
+	tests := []struct {
+		name string
+		card func() Card
+		msg  []byte
+		keep []byte // Leading the data area after the write.
+	}{
+		{"type 2 short", type2, []byte{0xD1, 0x01, 0x01, 'U', 0x00}, nil},
+		{"type 2 long tlv", type2, bytes.Repeat([]byte{0xAB}, 300), nil},
+		{"type 2 control tlvs", controlled, bytes.Repeat([]byte{0xEF}, 30), []byte{0x01, 0x03, 0xA0, 0x0C, 0x34, 0x02, 0x03, 0xB0, 0x10, 0x44, 0x03, 30}},
+		{"type 4 chunked", type4, bytes.Repeat([]byte{0xCD}, 200), nil},
+		{"type 4 empty", type4, []byte{}, nil},
+		{"type 3 multi block", type3, bytes.Repeat([]byte{0x3C}, 100), nil},
+		{"type 5 multi block", type5, bytes.Repeat([]byte{0x5C}, 300), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := tt.card()
+			if err := WriteNDEF(card, tt.msg); err != nil {
+				t.Fatalf("WriteNDEF() error = %v", err)
+			}
+			got, err := ReadNDEF(card)
+			if err != nil {
+				t.Fatalf("ReadNDEF() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.msg) {
+				t.Errorf("ReadNDEF() = % X, want % X", got, tt.msg)
+			}
+			if f, ok := card.(*fakeType2); ok && !bytes.Equal(f.mem[16:16+len(tt.keep)], tt.keep) {
+				t.Errorf("data area = % X, want control TLVs % X kept", f.mem[16:32], tt.keep)
+			}
+		})
+	}
+}
+
+func TestWriteNDEFTooLarge(t *testing.T) {
+	if err := WriteNDEF(newFakeType4(nil, 0x00), make([]byte, 0x0800)); err == nil {
+		t.Error("WriteNDEF() accepted a message exceeding the capacity")
+	}
+}
+
+func TestNDEFProgress(t *testing.T) {
+	type2 := func() Card {
+		mem := make([]byte, 135*4)
+		copy(mem[12:], []byte{0xE1, 0x10, 0x3E, 0x00})
+		copy(mem[16:], []byte{0x03, 0x00, 0xFE})
+		return &fakeType2{mem: mem}
+	}
+	tests := []struct {
+		name string
+		card Card
+		msg  []byte
+	}{
+		{"type 2", type2(), bytes.Repeat([]byte{0xAB}, 300)},
+		{"type 4", newFakeType4(nil, 0x00), bytes.Repeat([]byte{0xCD}, 200)},
+		{"type 3", newFakeType3(nil), bytes.Repeat([]byte{0x3C}, 100)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reports [][2]int
+			progress := func(done, total int) { reports = append(reports, [2]int{done, total}) }
+			check := func(op string) {
+				t.Helper()
+				if len(reports) < 2 {
+					t.Fatalf("%s reported %v, want several steps", op, reports)
+				}
+				for i, r := range reports {
+					if r[1] != len(tt.msg) || r[0] > r[1] || i > 0 && r[0] < reports[i-1][0] {
+						t.Fatalf("%s reported %v, want monotonic progress of %d bytes", op, reports, len(tt.msg))
+					}
+				}
+				if last := reports[len(reports)-1]; last[0] != len(tt.msg) {
+					t.Errorf("%s last reported %v, want %d done", op, last, len(tt.msg))
+				}
+				reports = nil
+			}
+			if err := Default.WriteNDEF(tt.card, tt.msg, progress); err != nil {
+				t.Fatalf("WriteNDEF() error = %v", err)
+			}
+			check("WriteNDEF()")
+			if _, err := Default.ReadNDEF(tt.card, progress); err != nil {
+				t.Fatalf("ReadNDEF() error = %v", err)
+			}
+			check("ReadNDEF()")
+		})
+	}
+}
+
+func TestDetectorNDEF(t *testing.T) {
+	mem := make([]byte, 135*4)
+	copy(mem[12:], []byte{0xE1, 0x10, 0x3E, 0x00})
+	copy(mem[16:], []byte{0x03, 0x00, 0xFE})
+	// A reader reporting a Type 2 tag with an ATR not carrying its type.
+	unknown := &atrCard{Card: &fakeType2{mem: mem}, atr: mustHex("3B00")}
+	if _, err := ReadNDEF(unknown); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("ReadNDEF() error = %v, want %v", err, ErrUnsupported)
+	}
+
+	d := NewDetector()
+	p := MustParsePattern("3B 00")
+	d.Override(Rule{ATR: &p, Type: TypeNTAG})
+	msg := []byte{0xD1, 0x01, 0x01, 'U', 0x00}
+	if err := d.WriteNDEF(unknown, msg, nil); err != nil {
+		t.Fatalf("WriteNDEF() error = %v", err)
+	}
+	if got, err := d.ReadNDEF(unknown, nil); err != nil || !bytes.Equal(got, msg) {
+		t.Errorf("ReadNDEF() = % X, %v; want % X", got, err, msg)
+	}
+	if info, err := d.ReadInfo(unknown); err != nil || info.Type != TypeNTAG || info.NDEFLength != len(msg) {
+		t.Errorf("ReadInfo() = %+v, %v", info, err)
+	}
+}
+
+// atrCard reports atr as the ATR of Card.
+type atrCard struct {
+	Card
+	atr []byte
+}
+
+func (c *atrCard) ATR() []byte { return c.atr }
@@ -0,0 +1,289 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package tag decides which kind of NFC tag or contactless card is present
+// from the signatures a reader reports for it: the ATR, the ATS of ISO 14443-4
+// cards and the GET VERSION response of NXP tags. Built-in decisions follow
+// PC/SC Part 3 and the NXP datasheets; users may register override rules for
+// white-label chips that misreport themselves or need special handling, and
+// card drivers outside this module register their own types and matchers.
+package tag
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// Type is the kind of a tag, used to select the handler of a card.
+type Type uint8
+
+const (
+	TypeUnknown Type = iota
+	TypeMifareClassic1K
+	TypeMifareClassic4K
+	TypeMifareMini
+	TypeUltralight
+	TypeUltralightC
+	TypeNTAG
+	TypeDESFire
+	TypeISODEP // Other ISO 14443-4 card, e.g. an NFC Forum Type 4 Tag.
+	TypeFeliCa
+	TypeTopaz
+	TypeISO15693
+)
+
+var typeNames = map[Type]string{
+	TypeUnknown:         "unknown",
+	TypeMifareClassic1K: "mifare-classic-1k",
+	TypeMifareClassic4K: "mifare-classic-4k",
+	TypeMifareMini:      "mifare-mini",
+	TypeUltralight:      "mifare-ultralight",
+	TypeUltralightC:     "mifare-ultralight-c",
+	TypeNTAG:            "ntag",
+	TypeDESFire:         "mifare-desfire",
+	TypeISODEP:          "iso14443-4",
+	TypeFeliCa:          "felica",
+	TypeTopaz:           "topaz",
+	TypeISO15693:        "iso15693",
+}
+
+// typesMu guards typeNames, which RegisterType extends.
+var typesMu sync.RWMutex
+
+// String returns the name of the tag type.
+func (t Type) String() string {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("type(%d)", uint8(t))
+}
+
+// MarshalText encodes the type as its name.
+func (t Type) MarshalText() ([]byte, error) { return []byte(t.String()), nil }
+
+// UnmarshalText decodes a type name as returned by String.
+func (t *Type) UnmarshalText(b []byte) error {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	for typ, name := range typeNames {
+		if name == string(b) {
+			*t = typ
+			return nil
+		}
+	}
+	return fmt.Errorf("tag: unknown tag type %q", b)
+}
+
+// Signature holds what a reader reports about a card. Fields not known are
+// left empty.
+type Signature struct {
+	ATR     []byte
+	ATS     []byte
+	Version []byte // Response to GET VERSION (0x60) without status words.
+}
+
+// Pattern matches byte strings of the same length as Value, comparing only
+// the bits set in Mask. A nil Mask compares all bits.
+type Pattern struct {
+	Value []byte
+	Mask  []byte
+}
+
+// ParsePattern parses a hex pattern such as "3B 8F 80 01 ?? ??" where "??"
+// matches any byte. Spaces and colons are ignored.
+func ParsePattern(s string) (Pattern, error) {
+	s = strings.NewReplacer(" ", "", ":", "").Replace(s)
+	if len(s)%2 != 0 {
+		return Pattern{}, fmt.Errorf("tag: odd length pattern %q", s)
+	}
+	p := Pattern{Value: make([]byte, len(s)/2), Mask: make([]byte, len(s)/2)}
+	for i := 0; i < len(s); i += 2 {
+		if s[i:i+2] == "??" {
+			continue
+		}
+		b, err := hex.DecodeString(s[i : i+2])
+		if err != nil {
+			return Pattern{}, fmt.Errorf("tag: invalid pattern %q: %w", s, err)
+		}
+		p.Value[i/2], p.Mask[i/2] = b[0], 0xFF
+	}
+	return p, nil
+}
+
+// MustParsePattern is like ParsePattern but panics on error.
+func MustParsePattern(s string) Pattern {
+	p, err := ParsePattern(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Match reports whether b matches the pattern.
+func (p Pattern) Match(b []byte) bool {
+	if len(b) != len(p.Value) {
+		return false
+	}
+	if p.Mask == nil {
+		return bytes.Equal(b, p.Value)
+	}
+	for i := range b {
+		if b[i]&p.Mask[i] != p.Value[i]&p.Mask[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Rule forces Type for cards whose signature matches all of the non-nil
+// patterns of the rule.
+type Rule struct {
+	ATR     *Pattern
+	ATS     *Pattern
+	Version *Pattern
+	Type    Type
+}
+
+func (r Rule) match(sig Signature) bool {
+	if r.ATR == nil && r.ATS == nil && r.Version == nil {
+		return false
+	}
+	return (r.ATR == nil || r.ATR.Match(sig.ATR)) &&
+		(r.ATS == nil || r.ATS.Match(sig.ATS)) &&
+		(r.Version == nil || r.Version.Match(sig.Version))
+}
+
+// Detector decides the tag type of a card signature. It is safe for
+// concurrent use.
+type Detector struct {
+	mu       sync.RWMutex
+	rules    []Rule
+	matchers []driverMatcher
+}
+
+// NewDetector returns a detector using the built-in decisions only.
+func NewDetector() *Detector { return &Detector{} }
+
+// Override registers a rule taking precedence over the built-in decisions.
+// Rules are evaluated in registration order and the first match wins.
+func (d *Detector) Override(r Rule) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rules = append(d.rules, r)
+}
+
+// Detect returns the tag type of the card with the given signature.
+// Registered matchers are asked without a probe.
+func (d *Detector) Detect(sig Signature) Type { return d.detect(sig, nil) }
+
+// detect decides the tag type of sig: override rules first, then the
+// matchers registered by drivers, then the built-in decisions.
+func (d *Detector) detect(sig Signature, probe apdu.Transceiver) Type {
+	d.mu.RLock()
+	for _, r := range d.rules {
+		if r.match(sig) {
+			d.mu.RUnlock()
+			return r.Type
+		}
+	}
+	matchers := d.matchers
+	d.mu.RUnlock()
+
+	if len(matchers) > 0 {
+		var hist []byte
+		if atr, err := iso7816.ParseATR(sig.ATR); err == nil {
+			hist = atr.Historical
+		}
+		for _, m := range matchers {
+			if m.matcher.Match(sig.ATR, hist, probe) {
+				return m.typ
+			}
+		}
+	}
+	if t := detectVersion(sig.Version); t != TypeUnknown {
+		return t
+	}
+	return detectATR(sig.ATR, sig.ATS)
+}
+
+// Default is the detector used by the package level functions.
+var Default = NewDetector()
+
+// Override registers a rule with the Default detector.
+func Override(r Rule) { Default.Override(r) }
+
+// Detect returns the tag type of sig using the Default detector.
+func Detect(sig Signature) Type { return Default.Detect(sig) }
+
+// pcscRID is the start of PC/SC Part 3 historical bytes of contactless
+// storage cards, followed by the standard byte and the two card name bytes.
+var pcscRID = []byte{0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06}
+
+// Card names of PC/SC Part 3 storage cards.
+var pcscCardNames = map[uint16]Type{
+	0x0001: TypeMifareClassic1K,
+	0x0002: TypeMifareClassic4K,
+	0x0003: TypeUltralight,
+	0x0026: TypeMifareMini,
+	0x003A: TypeUltralightC,
+	0xF004: TypeTopaz,
+	0xF011: TypeFeliCa,
+	0xF012: TypeFeliCa,
+}
+
+func detectATR(raw, ats []byte) Type {
+	atr, err := iso7816.ParseATR(raw)
+	if err != nil {
+		return TypeUnknown
+	}
+	hist := atr.Historical
+	if len(hist) >= len(pcscRID)+3 && bytes.Equal(hist[:len(pcscRID)], pcscRID) {
+		standard := hist[len(pcscRID)]
+		name := uint16(hist[len(pcscRID)+1])<<8 | uint16(hist[len(pcscRID)+2])
+		if t, ok := pcscCardNames[name]; ok {
+			return t
+		}
+		switch standard {
+		case 0x0B, 0x0C:
+			return TypeISO15693
+		case 0x11:
+			return TypeFeliCa
+		}
+		return TypeUnknown
+	}
+
+	// ISO 14443-4 cards are reported with the ATS historical bytes,
+	// DESFire with the single historical byte 0x80.
+	if bytes.Equal(hist, []byte{0x80}) || (len(ats) == 6 && ats[5] == 0x80) {
+		return TypeDESFire
+	}
+	if len(atr.Protocols) >= 2 && atr.Protocols[1] == 1 && len(raw) > 3 && raw[2] == 0x80 && raw[3] == 0x01 {
+		return TypeISODEP
+	}
+	return TypeUnknown
+}
+
+func detectVersion(v []byte) Type {
+	if len(v) < 3 || v[0] != 0x00 || v[1] != 0x04 {
+		return TypeUnknown
+	}
+	switch v[2] {
+	case 0x03:
+		return TypeUltralight
+	case 0x04:
+		return TypeNTAG
+	case 0x01:
+		return TypeDESFire
+	default:
+		return TypeUnknown
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package tag
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// atrHex decodes an ATR without its check character and appends it.
+func atrHex(s string) []byte {
+	atr := mustHex(s)
+	var tck byte
+	for _, b := range atr[1:] {
+		tck ^= b
+	}
+	return append(atr, tck)
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		sig  Signature
+		want Type
+	}{
+		{"classic 1k", Signature{ATR: mustHex("3B8F8001804F0CA000000306030001000000006A")}, TypeMifareClassic1K},
+		{"ultralight", Signature{ATR: mustHex("3B8F8001804F0CA0000003060300030000000068")}, TypeUltralight},
+		{"ntag by version", Signature{
+			ATR:     mustHex("3B8F8001804F0CA0000003060300030000000068"),
+			Version: mustHex("0004040201001103"),
+		}, TypeNTAG},
+		{"felica", Signature{ATR: atrHex("3B8F8001804F0CA00000030611003B00000000")}, TypeFeliCa},
+		{"desfire", Signature{ATR: mustHex("3B8180018080")}, TypeDESFire},
+		{"type 4", Signature{ATR: atrHex("3B8A80010031C164084500000000")}, TypeISODEP},
+		{"garbage", Signature{ATR: []byte{0x01}}, TypeUnknown},
+	}
+
+	d := NewDetector()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.Detect(tt.sig); got != tt.want {
+				t.Errorf("Detect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectorOverride(t *testing.T) {
+	d := NewDetector()
+	atr := mustHex("3B8F8001804F0CA0000003060300030000000068")
+	if got := d.Detect(Signature{ATR: atr}); got != TypeUltralight {
+		t.Fatalf("Detect() = %v, want %v", got, TypeUltralight)
+	}
+
+	p := MustParsePattern("3B 8F 80 01 80 4F 0C A0 00 00 03 06 03 00 03 ?? ?? ?? ?? ??")
+	d.Override(Rule{ATR: &p, Type: TypeNTAG})
+	d.Override(Rule{ATR: &p, Type: TypeTopaz})
+	if got := d.Detect(Signature{ATR: atr}); got != TypeNTAG {
+		t.Errorf("Detect() with override = %v, want %v", got, TypeNTAG)
+	}
+
+	if _, err := ParsePattern("3B 8"); err == nil {
+		t.Error("ParsePattern() accepted odd length")
+	}
+	if _, err := ParsePattern("3B ZZ"); err == nil {
+		t.Error("ParsePattern() accepted invalid hex")
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package tag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/nfc/felica"
+	"github.com/happy-sdk/scardkit/sckutils"
+)
+
+// passThrough is the pseudo-APDU header wrapping the native commands of
+// Type 3 and Type 5 tags, the direct transmit command of PC/SC readers.
+var passThrough = []byte{0xFF, 0x00, 0x00, 0x00}
+
+// Type 3 Tag attribute information block fields.
+const (
+	type3Version  = 0x10 // Mapping version 1.0.
+	type3Writing  = 0x0F // WriteF of an interrupted write.
+	type3ReadOnly = 0x00 // RWFlag of read-only tags.
+)
+
+// type3Tag is a Type 3 Tag with its attribute information block, block 0
+// of its NDEF service.
+type type3Tag struct {
+	card Card
+	idm  []byte
+	attr []byte
+}
+
+// openType3 reads the IDm and the attribute information block of a Type 3
+// Tag.
+func openType3(card Card) (*type3Tag, error) {
+	idm, err := transmitOK(card, []byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+	if err != nil {
+		return nil, fmt.Errorf("tag: read idm: %w", err)
+	}
+	if len(idm) != felica.IDmSize {
+		return nil, fmt.Errorf("tag: idm of %d bytes", len(idm))
+	}
+	t := &type3Tag{card: card, idm: idm}
+	attr, err := t.read(0, 1, 1, nil)
+	var se *felica.StatusError
+	if errors.As(err, &se) {
+		return nil, fmt.Errorf("%w: read attribute information: %s", ErrNotFormatted, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tag: read attribute information: %w", err)
+	}
+	if attr[0]&0xF0 != type3Version&0xF0 || binary.BigEndian.Uint16(attr[14:]) != type3Checksum(attr) {
+		return nil, ErrNotFormatted
+	}
+	t.attr = attr
+	return t, nil
+}
+
+// type3Checksum returns the checksum of the attribute information block b.
+func type3Checksum(b []byte) uint16 {
+	var sum uint16
+	for _, c := range b[:14] {
+		sum += uint16(c)
+	}
+	return sum
+}
+
+func (t *type3Tag) nbr() int { return max(int(t.attr[1]), 1) }
+func (t *type3Tag) nbw() int { return max(int(t.attr[2]), 1) }
+func (t *type3Tag) capacity() int {
+	return int(binary.BigEndian.Uint16(t.attr[3:5])) * felica.BlockSize
+}
+func (t *type3Tag) writable() bool { return t.attr[10] != type3ReadOnly }
+func (t *type3Tag) length() int    { return int(t.attr[11])<<16 | int(t.attr[12])<<8 | int(t.attr[13]) }
+
+// read reads n blocks starting at block first, per at most chunk blocks,
+// reporting the bytes read to progress.
+func (t *type3Tag) read(first, n, chunk int, progress sckutils.ProgressFunc) ([]byte, error) {
+	var data []byte
+	for b := first; b < first+n; b += chunk {
+		frame, err := felica.ReadWithoutEncryption(t.idm, []felica.ServiceCode{felica.ServiceLiteRO}, blockList(b, min(chunk, first+n-b)))
+		if err != nil {
+			return nil, err
+		}
+		resp, err := transmitOK(t.card, append(append(append([]byte(nil), passThrough...), byte(len(frame))), frame...))
+		if err != nil {
+			return nil, err
+		}
+		d, err := felica.ParseReadResponse(t.idm, resp)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, d...)
+		progress.Report(len(data), n*felica.BlockSize)
+	}
+	return data, nil
+}
+
+// write writes data, padded to whole blocks, starting at block first,
+// reporting the bytes of data written to progress.
+func (t *type3Tag) write(first int, data []byte, progress sckutils.ProgressFunc) error {
+	total := len(data)
+	if r := len(data) % felica.BlockSize; r > 0 {
+		data = append(data, make([]byte, felica.BlockSize-r)...)
+	}
+	n := len(data) / felica.BlockSize
+	for b := 0; b < n; b += t.nbw() {
+		c := min(t.nbw(), n-b)
+		frame, err := felica.WriteWithoutEncryption(t.idm, []felica.ServiceCode{felica.ServiceLiteRW}, blockList(first+b, c),
+			data[b*felica.BlockSize:(b+c)*felica.BlockSize])
+		if err != nil {
+			return err
+		}
+		resp, err := transmitOK(t.card, append(append(append([]byte(nil), passThrough...), byte(len(frame))), frame...))
+		if err != nil {
+			return err
+		}
+		if err := felica.ParseWriteResponse(t.idm, resp); err != nil {
+			return err
+		}
+		progress.Report(min((b+c)*felica.BlockSize, total), total)
+	}
+	return nil
+}
+
+// writeAttr writes the attribute information block with the given WriteF
+// flag and NDEF message length.
+func (t *type3Tag) writeAttr(writeF byte, length int) error {
+	attr := append([]byte(nil), t.attr...)
+	attr[9] = writeF
+	attr[11], attr[12], attr[13] = byte(length>>16), byte(length>>8), byte(length)
+	binary.BigEndian.PutUint16(attr[14:], type3Checksum(attr))
+	if err := t.write(0, attr, nil); err != nil {
+		return fmt.Errorf("tag: write attribute information: %w", err)
+	}
+	t.attr = attr
+	return nil
+}
+
+// blockList returns the block list of n blocks of the first service
+// starting at block first.
+func blockList(first, n int) []felica.Block {
+	blocks := make([]felica.Block, n)
+	for i := range blocks {
+		blocks[i] = felica.Block{Number: uint16(first + i)}
+	}
+	return blocks
+}
+
+func readType3Info(card Card, info *Info) error {
+	t, err := openType3(card)
+	if err != nil {
+		return err
+	}
+	info.Capacity, info.Writable, info.NDEFLength = t.capacity(), t.writable(), t.length()
+	return nil
+}
+
+func readType3NDEF(card Card, progress sckutils.ProgressFunc) ([]byte, error) {
+	t, err := openType3(card)
+	if err != nil {
+		return nil, err
+	}
+	n := t.length()
+	if n > t.capacity() {
+		return nil, fmt.Errorf("tag: ndef length %d exceeds capacity %d", n, t.capacity())
+	}
+	data, err := t.read(1, (n+felica.BlockSize-1)/felica.BlockSize, t.nbr(), func(done, _ int) {
+		progress.Report(min(done, n), n)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tag: read ndef data: %w", err)
+	}
+	return data[:n], nil
+}
+
+// writeType3NDEF writes msg following the update procedure of the Type 3
+// Tag specification: WriteF marks the write in progress until the length
+// is set, so readers detect an interrupted write.
+func writeType3NDEF(card Card, msg []byte, progress sckutils.ProgressFunc) error {
+	t, err := openType3(card)
+	if err != nil {
+		return err
+	}
+	if !t.writable() {
+		return fmt.Errorf("tag: ndef data area is read-only")
+	}
+	if len(msg) > t.capacity() {
+		return fmt.Errorf("tag: %d byte message exceeds %d byte capacity", len(msg), t.capacity())
+	}
+	if err := t.writeAttr(type3Writing, t.length()); err != nil {
+		return err
+	}
+	if err := t.write(1, append([]byte(nil), msg...), progress); err != nil {
+		return fmt.Errorf("tag: write ndef data: %w", err)
+	}
+	return t.writeAttr(0x00, len(msg))
+}
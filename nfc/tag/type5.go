@@ -0,0 +1,83 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package tag
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/protocols/iso15693"
+	"github.com/happy-sdk/scardkit/sckutils"
+)
+
+// openType5 inventories the Type 5 Tag on the reader, adopts its block size
+// and reads its capability container.
+func openType5(card Card) (*iso15693.Tag, *iso15693.CapabilityContainer, error) {
+	t, _, err := iso15693.Inventory(iso15693.PassThrough(card, passThrough))
+	if err != nil {
+		return nil, nil, fmt.Errorf("tag: inventory: %w", err)
+	}
+	var cerr iso15693.Error
+	if _, err := t.SystemInfo(); err != nil && !errors.As(err, &cerr) {
+		return nil, nil, fmt.Errorf("tag: read system information: %w", err)
+	}
+	cc, err := t.ReadCapabilityContainer()
+	if errors.Is(err, iso15693.ErrNoCapabilityContainer) {
+		return nil, nil, ErrNotFormatted
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("tag: read capability container: %w", err)
+	}
+	return t, cc, nil
+}
+
+func readType5Info(card Card, info *Info) error {
+	t, cc, err := openType5(card)
+	if err != nil {
+		return err
+	}
+	info.Capacity, info.Writable = cc.DataSize, cc.Write
+	if !cc.Read {
+		return nil
+	}
+	msg, err := t.ReadNDEF()
+	if err != nil && !errors.Is(err, iso15693.ErrNoNDEFMessage) {
+		return fmt.Errorf("tag: read ndef message: %w", err)
+	}
+	info.NDEFLength = len(msg)
+	return nil
+}
+
+// readType5NDEF reads the NDEF message of a Type 5 Tag, reporting progress
+// once it is read.
+func readType5NDEF(card Card, progress sckutils.ProgressFunc) ([]byte, error) {
+	t, _, err := openType5(card)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := t.ReadNDEF()
+	if errors.Is(err, iso15693.ErrNoNDEFMessage) {
+		return []byte{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tag: %w", err)
+	}
+	progress.Report(len(msg), len(msg))
+	return msg, nil
+}
+
+// writeType5NDEF writes the NDEF message of a Type 5 Tag, reporting
+// progress once it is written.
+func writeType5NDEF(card Card, msg []byte, progress sckutils.ProgressFunc) error {
+	t, _, err := openType5(card)
+	if err != nil {
+		return err
+	}
+	if err := t.WriteNDEF(msg); err != nil {
+		return fmt.Errorf("tag: %w", err)
+	}
+	progress.Report(len(msg), len(msg))
+	return nil
+}
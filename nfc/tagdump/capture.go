@@ -0,0 +1,145 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package tagdump
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+)
+
+const type2PageSize = 4
+
+var (
+	ndefAID  = []byte{0xD2, 0x76, 0x00, 0x00, 0x85, 0x01, 0x01}
+	ccFileID = uint16(0xE103)
+)
+
+// Capture reads card into a dump: the memory of Type 2 Tags as announced
+// by their capability container, the capability container and NDEF files
+// of Type 4 Tags, and the NDEF message of both. Other tags are captured
+// with their UID and ATR only.
+func Capture(card tag.Card) (*Dump, error) {
+	d := &Dump{Time: time.Now(), ATR: card.ATR(), Type: tag.Detect(tag.Signature{ATR: card.ATR()})}
+	if resp, err := transmitOK(card, []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}); err == nil {
+		d.UID = resp
+	}
+	var err error
+	switch d.Type.ForumType() {
+	case tag.ForumType2:
+		err = captureType2(card, d)
+	case tag.ForumType4:
+		err = captureType4(card, d)
+	default:
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.NDEF, err = tag.ReadNDEF(card)
+	if err != nil && !errors.Is(err, tag.ErrNotFormatted) {
+		return nil, err
+	}
+	return d, nil
+}
+
+func transmitOK(card tag.Card, cmd []byte) ([]byte, error) {
+	resp, err := card.Transmit(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		return nil, err
+	}
+	return resp[:len(resp)-2], nil
+}
+
+func readPage(card tag.Card, page int) ([]byte, error) {
+	p, err := transmitOK(card, []byte{0xFF, 0xB0, 0x00, byte(page), type2PageSize})
+	if err != nil {
+		return nil, fmt.Errorf("tagdump: read page %d: %w", page, err)
+	}
+	if len(p) < type2PageSize {
+		return nil, fmt.Errorf("tagdump: read page %d: short response", page)
+	}
+	return p[:type2PageSize], nil
+}
+
+func captureType2(card tag.Card, d *Dump) error {
+	cc, err := readPage(card, 3)
+	if err != nil {
+		return err
+	}
+	// Header pages and the data area announced in 8 byte units.
+	pages := min(4+int(cc[2])*2, 0x100)
+	d.PageSize = type2PageSize
+	d.Memory = make([]byte, 0, pages*type2PageSize)
+	for page := 0; page < pages; page++ {
+		p, err := readPage(card, page)
+		if err != nil {
+			return err
+		}
+		d.Memory = append(d.Memory, p...)
+	}
+	return nil
+}
+
+func captureType4(card tag.Card, d *Dump) error {
+	if _, err := transmitOK(card, append(append([]byte{0x00, 0xA4, 0x04, 0x00, byte(len(ndefAID))}, ndefAID...), 0x00)); err != nil {
+		return nil // No NDEF application, nothing to read.
+	}
+	cc, err := readFile(card, ccFileID, 0)
+	if err != nil {
+		return err
+	}
+	d.Files = append(d.Files, File{ID: ccFileID, Data: cc})
+	if len(cc) < 15 || cc[7] != 0x04 {
+		return fmt.Errorf("tagdump: invalid capability container")
+	}
+	mle := max(int(binary.BigEndian.Uint16(cc[3:5])), 1)
+	id := binary.BigEndian.Uint16(cc[9:11])
+	ndef, err := readFile(card, id, mle)
+	if err != nil {
+		return err
+	}
+	d.Files = append(d.Files, File{ID: id, Data: ndef})
+	return nil
+}
+
+// readFile selects the elementary file id and reads the length field at
+// its start and the content it announces, in chunks of mle bytes. A zero
+// mle reads a capability container, whose length field covers itself.
+func readFile(card tag.Card, id uint16, mle int) ([]byte, error) {
+	if _, err := transmitOK(card, []byte{0x00, 0xA4, 0x00, 0x0C, 0x02, byte(id >> 8), byte(id)}); err != nil {
+		return nil, fmt.Errorf("tagdump: select file %04X: %w", id, err)
+	}
+	head, err := transmitOK(card, []byte{0x00, 0xB0, 0x00, 0x00, 0x02})
+	if err != nil || len(head) != 2 {
+		return nil, fmt.Errorf("tagdump: read file %04X length: %v", id, err)
+	}
+	size := int(binary.BigEndian.Uint16(head))
+	if mle == 0 {
+		mle = 0xFF
+	} else {
+		size += 2
+	}
+	data := head
+	for len(data) < size {
+		n := min(size-len(data), mle, 0xFF)
+		chunk, err := transmitOK(card, []byte{0x00, 0xB0, byte(len(data) >> 8), byte(len(data)), byte(n)})
+		if err != nil {
+			return nil, fmt.Errorf("tagdump: read file %04X: %w", id, err)
+		}
+		if len(chunk) == 0 {
+			return nil, fmt.Errorf("tagdump: read file %04X: empty response", id)
+		}
+		data = append(data, chunk...)
+	}
+	return data, nil
+}
@@ -0,0 +1,272 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package tagdump captures the complete contents of a tag into a Dump and
+// serializes it to JSON or a compact binary format, for backups, diffs and
+// sharing tag images between tools.
+package tagdump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/happy-sdk/scardkit/nfc/tag"
+)
+
+// Dump is the image of a tag.
+type Dump struct {
+	Time     time.Time // Time the tag was read.
+	Type     tag.Type
+	UID, ATR []byte
+	PageSize int    // Size of a memory page, zero when Memory is empty.
+	Memory   []byte // Memory of storage tags, e.g. Type 2 Tags.
+	Files    []File // Elementary files of ISO 7816-4 tags.
+	NDEF     []byte // Raw NDEF message, nil when none was read.
+}
+
+// File is an elementary file of an ISO 7816-4 tag.
+type File struct {
+	ID   uint16
+	Data []byte
+}
+
+// Page returns the memory page n, or nil when the dump does not hold it.
+func (d *Dump) Page(n int) []byte {
+	if d.PageSize == 0 || n < 0 || (n+1)*d.PageSize > len(d.Memory) {
+		return nil
+	}
+	return d.Memory[n*d.PageSize : (n+1)*d.PageSize]
+}
+
+// jsonDump is the JSON form of a Dump with byte strings in hex.
+type jsonDump struct {
+	Time     time.Time  `json:"time"`
+	Type     tag.Type   `json:"type"`
+	UID      string     `json:"uid"`
+	ATR      string     `json:"atr"`
+	PageSize int        `json:"page_size,omitempty"`
+	Pages    []string   `json:"pages,omitempty"`
+	Files    []jsonFile `json:"files,omitempty"`
+	NDEF     *string    `json:"ndef,omitempty"`
+}
+
+type jsonFile struct {
+	ID   string `json:"id"`
+	Data string `json:"data"`
+}
+
+// MarshalJSON encodes d with byte strings in hex and the memory split into
+// pages, so dumps diff line by line.
+func (d *Dump) MarshalJSON() ([]byte, error) {
+	j := jsonDump{
+		Time:     d.Time,
+		Type:     d.Type,
+		UID:      hex.EncodeToString(d.UID),
+		ATR:      hex.EncodeToString(d.ATR),
+		PageSize: d.PageSize,
+	}
+	for i := 0; d.PageSize > 0 && i < len(d.Memory); i += d.PageSize {
+		j.Pages = append(j.Pages, hex.EncodeToString(d.Memory[i:min(i+d.PageSize, len(d.Memory))]))
+	}
+	for _, f := range d.Files {
+		j.Files = append(j.Files, jsonFile{ID: fmt.Sprintf("%04X", f.ID), Data: hex.EncodeToString(f.Data)})
+	}
+	if d.NDEF != nil {
+		s := hex.EncodeToString(d.NDEF)
+		j.NDEF = &s
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a dump encoded by MarshalJSON.
+func (d *Dump) UnmarshalJSON(data []byte) error {
+	var j jsonDump
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	out := Dump{Time: j.Time, Type: j.Type, PageSize: j.PageSize}
+	var err error
+	if out.UID, err = decodeHex("uid", j.UID); err != nil {
+		return err
+	}
+	if out.ATR, err = decodeHex("atr", j.ATR); err != nil {
+		return err
+	}
+	for i, p := range j.Pages {
+		b, err := decodeHex(fmt.Sprintf("page %d", i), p)
+		if err != nil {
+			return err
+		}
+		out.Memory = append(out.Memory, b...)
+	}
+	for _, f := range j.Files {
+		id, err := decodeHex("file id", f.ID)
+		if err != nil || len(id) != 2 {
+			return fmt.Errorf("tagdump: invalid file id %q", f.ID)
+		}
+		b, err := decodeHex("file "+f.ID, f.Data)
+		if err != nil {
+			return err
+		}
+		out.Files = append(out.Files, File{ID: binary.BigEndian.Uint16(id), Data: b})
+	}
+	if j.NDEF != nil {
+		if out.NDEF, err = decodeHex("ndef", *j.NDEF); err != nil {
+			return err
+		}
+		if out.NDEF == nil {
+			out.NDEF = []byte{}
+		}
+	}
+	*d = out
+	return nil
+}
+
+func decodeHex(field, s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("tagdump: %s: %w", field, err)
+	}
+	return b, nil
+}
+
+// magic starts the binary format, followed by the format version and a
+// sequence of fields, each a field tag, a uvarint length and the value.
+// Readers skip fields with unknown tags.
+var magic = []byte("TAGD\x01")
+
+// Field tags of the binary format.
+const (
+	fieldTime     = 0x01 // Unix time in nanoseconds, 8 bytes big endian.
+	fieldType     = 0x02
+	fieldUID      = 0x03
+	fieldATR      = 0x04
+	fieldPageSize = 0x05 // uvarint.
+	fieldMemory   = 0x06
+	fieldFile     = 0x07 // 2 bytes file ID followed by the data.
+	fieldNDEF     = 0x08
+)
+
+// ErrFormat is returned for data which is neither a JSON nor a binary dump.
+var ErrFormat = errors.New("tagdump: unknown dump format")
+
+// MarshalBinary encodes d in the compact binary format.
+func (d *Dump) MarshalBinary() ([]byte, error) {
+	out := append([]byte(nil), magic...)
+	field := func(id byte, value []byte) {
+		out = append(out, id)
+		out = binary.AppendUvarint(out, uint64(len(value)))
+		out = append(out, value...)
+	}
+	if !d.Time.IsZero() {
+		field(fieldTime, binary.BigEndian.AppendUint64(nil, uint64(d.Time.UnixNano())))
+	}
+	field(fieldType, []byte{byte(d.Type)})
+	field(fieldUID, d.UID)
+	field(fieldATR, d.ATR)
+	if d.PageSize > 0 {
+		field(fieldPageSize, binary.AppendUvarint(nil, uint64(d.PageSize)))
+	}
+	if len(d.Memory) > 0 {
+		field(fieldMemory, d.Memory)
+	}
+	for _, f := range d.Files {
+		field(fieldFile, append(binary.BigEndian.AppendUint16(nil, f.ID), f.Data...))
+	}
+	if d.NDEF != nil {
+		field(fieldNDEF, d.NDEF)
+	}
+	return out, nil
+}
+
+// UnmarshalBinary decodes a dump encoded by MarshalBinary.
+func (d *Dump) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, magic[:4]) {
+		return ErrFormat
+	}
+	if len(data) < len(magic) || data[4] != magic[4] {
+		return fmt.Errorf("tagdump: unsupported binary format version")
+	}
+	var out Dump
+	for b := data[len(magic):]; len(b) > 0; {
+		id := b[0]
+		n, k := binary.Uvarint(b[1:])
+		if k <= 0 || uint64(len(b)-1-k) < n {
+			return fmt.Errorf("tagdump: truncated field 0x%02X", id)
+		}
+		v := append([]byte{}, b[1+k:1+k+int(n)]...)
+		b = b[1+k+int(n):]
+		switch id {
+		case fieldTime:
+			if len(v) != 8 {
+				return fmt.Errorf("tagdump: time of %d bytes", len(v))
+			}
+			out.Time = time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+		case fieldType:
+			if len(v) != 1 {
+				return fmt.Errorf("tagdump: type of %d bytes", len(v))
+			}
+			out.Type = tag.Type(v[0])
+		case fieldUID:
+			out.UID = nilIfEmpty(v)
+		case fieldATR:
+			out.ATR = nilIfEmpty(v)
+		case fieldPageSize:
+			size, k := binary.Uvarint(v)
+			if k != len(v) {
+				return fmt.Errorf("tagdump: invalid page size")
+			}
+			out.PageSize = int(size)
+		case fieldMemory:
+			out.Memory = v
+		case fieldFile:
+			if len(v) < 2 {
+				return fmt.Errorf("tagdump: file of %d bytes", len(v))
+			}
+			out.Files = append(out.Files, File{ID: binary.BigEndian.Uint16(v), Data: nilIfEmpty(v[2:])})
+		case fieldNDEF:
+			out.NDEF = v
+		}
+	}
+	*d = out
+	return nil
+}
+
+func nilIfEmpty(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+// Load reads a dump in either format.
+func Load(r io.Reader) (*Dump, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	d := new(Dump)
+	if bytes.HasPrefix(data, magic[:4]) {
+		if err := d.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, ErrFormat
+	}
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, fmt.Errorf("tagdump: decode json: %w", err)
+	}
+	return d, nil
+}
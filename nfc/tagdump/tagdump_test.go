@@ -0,0 +1,116 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package tagdump
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/transport"
+	"github.com/happy-sdk/scardkit/x/virtualreader"
+)
+
+// connect places t on a virtual reader and returns the connected card.
+func connect(t *testing.T, vt virtualreader.Tag) transport.Card {
+	t.Helper()
+	vr := virtualreader.New("r0")
+	if err := vr.Insert("r0", vt); err != nil {
+		t.Fatal(err)
+	}
+	readers, _ := vr.ListReaders()
+	card, _, err := vr.WaitCard(context.Background(), readers, time.Second)
+	if err != nil || card == nil {
+		t.Fatalf("WaitCard() = %v, %v", card, err)
+	}
+	return card
+}
+
+func TestCapture(t *testing.T) {
+	uid := []byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	msg := []byte{0xD1, 0x01, 0x04, 'U', 0x04, 'a', '.', 'b'}
+	ntag := virtualreader.NewNTAG215(uid)
+	if err := ntag.WriteNDEF(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := Capture(connect(t, ntag))
+	if err != nil {
+		t.Fatalf("Capture() NTAG error = %v", err)
+	}
+	if d.Type != tag.TypeUltralight || !bytes.Equal(d.UID, uid) || !bytes.Equal(d.NDEF, msg) {
+		t.Errorf("dump = %+v", d)
+	}
+	if len(d.Memory) != 128*4 || !bytes.Equal(d.Page(3), []byte{0xE1, 0x10, 0x3E, 0x00}) || d.Page(128) != nil {
+		t.Errorf("memory of %d bytes, page 3 %X", len(d.Memory), d.Page(3))
+	}
+
+	d, err = Capture(connect(t, virtualreader.NewDESFire(uid, msg)))
+	if err != nil {
+		t.Fatalf("Capture() DESFire error = %v", err)
+	}
+	if d.Type != tag.TypeDESFire || !bytes.Equal(d.NDEF, msg) || len(d.Files) != 2 || d.Files[1].ID != 0xE104 {
+		t.Fatalf("dump = %+v", d)
+	}
+	if want := append([]byte{0x00, byte(len(msg))}, msg...); !bytes.Equal(d.Files[1].Data, want) {
+		t.Errorf("ndef file = %X, want %X", d.Files[1].Data, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	dumps := []*Dump{
+		{
+			Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), Type: tag.TypeNTAG,
+			UID: []byte{1, 2, 3, 4, 5, 6, 7}, ATR: []byte{0x3B, 0x8F},
+			PageSize: 4, Memory: bytes.Repeat([]byte{0xAB}, 16), NDEF: []byte{},
+		},
+		{
+			Type: tag.TypeDESFire, UID: []byte{1, 2, 3, 4},
+			Files: []File{{ID: 0xE103, Data: []byte{0x00, 0x0F}}, {ID: 0xE104, Data: []byte{0x00, 0x00}}},
+		},
+	}
+	for i, want := range dumps {
+		j, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := want.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, data := range [][]byte{j, b} {
+			got, err := Load(bytes.NewReader(data))
+			if err != nil {
+				t.Errorf("%d: Load() error = %v", i, err)
+				continue
+			}
+			if !got.Time.Equal(want.Time) {
+				t.Errorf("%d: time %v, want %v", i, got.Time, want.Time)
+			}
+			got.Time = want.Time
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%d: Load() = %+v, want %+v", i, got, want)
+			}
+		}
+	}
+	if j, _ := json.Marshal(dumps[0]); !strings.Contains(string(j), `"type":"ntag"`) || !strings.Contains(string(j), `"pages":["abababab"`) {
+		t.Errorf("json = %s", j)
+	}
+
+	for _, bad := range []string{"", "garbage", "TAGD\x02", "TAGD\x01\x03\x05ab", `{"uid": "zz"}`} {
+		if _, err := Load(strings.NewReader(bad)); err == nil {
+			t.Errorf("Load(%q) accepted", bad)
+		}
+	}
+	if _, err := Load(strings.NewReader("garbage")); !errors.Is(err, ErrFormat) {
+		t.Errorf("Load() of garbage error = %v", err)
+	}
+}
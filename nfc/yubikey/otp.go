@@ -0,0 +1,160 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package yubikey
+
+import (
+	"context"
+	"crypto/aes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// modhex is the alphabet of modhex, hex digits mapped to keys placed alike
+// on common keyboard layouts.
+const modhex = "cbdefghijklnrtuv"
+
+func isModhex(s string) bool {
+	return strings.Trim(s, modhex) == ""
+}
+
+// DecodeModhex decodes the modhex string s.
+func DecodeModhex(s string) ([]byte, error) {
+	if len(s)%2 != 0 {
+		return nil, fmt.Errorf("yubikey: odd length modhex")
+	}
+	out := make([]byte, len(s)/2)
+	for i := range out {
+		hi, lo := strings.IndexByte(modhex, s[2*i]), strings.IndexByte(modhex, s[2*i+1])
+		if hi < 0 || lo < 0 {
+			return nil, fmt.Errorf("yubikey: invalid modhex %q", s[2*i:2*i+2])
+		}
+		out[i] = byte(hi<<4 | lo)
+	}
+	return out, nil
+}
+
+// EncodeModhex encodes b as modhex.
+func EncodeModhex(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		sb.WriteByte(modhex[c>>4])
+		sb.WriteByte(modhex[c&0x0F])
+	}
+	return sb.String()
+}
+
+// Token is the decrypted content of a Yubico OTP.
+type Token struct {
+	// PrivateID is the private identity of the key slot.
+	PrivateID [6]byte
+	// UseCounter counts the power-ups of the key with the slot used,
+	// SessionCounter the OTPs generated since. Together they increase
+	// with every OTP, so a validator rejects replays by remembering the
+	// last pair seen for a key.
+	UseCounter     uint16
+	SessionCounter byte
+	// Timestamp is a 24 bit clock of 8 Hz started at power-up.
+	Timestamp uint32
+	Random    uint16
+}
+
+// Counter returns the use and session counters as one increasing value.
+func (t *Token) Counter() uint32 {
+	return uint32(t.UseCounter)<<8 | uint32(t.SessionCounter)
+}
+
+// Decrypt decrypts the Yubico OTP otp with the AES-128 key of the key slot
+// and checks its checksum.
+func Decrypt(otp *OTP, key []byte) (*Token, error) {
+	if otp.HOTP || len(otp.Value) < 32 {
+		return nil, fmt.Errorf("%w: not a yubico otp", ErrInvalidOTP)
+	}
+	ct, err := DecodeModhex(otp.Value[len(otp.Value)-32:])
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 16 {
+		return nil, fmt.Errorf("yubikey: aes key of %d bytes", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("yubikey: %w", err)
+	}
+	pt := make([]byte, 16)
+	block.Decrypt(pt, ct)
+	if crc16(pt) != crcResidue {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidOTP)
+	}
+	t := &Token{
+		UseCounter:     binary.LittleEndian.Uint16(pt[6:8]),
+		Timestamp:      uint32(pt[8]) | uint32(pt[9])<<8 | uint32(pt[10])<<16,
+		SessionCounter: pt[11],
+		Random:         binary.LittleEndian.Uint16(pt[12:14]),
+	}
+	copy(t.PrivateID[:], pt[:6])
+	return t, nil
+}
+
+// OfflineValidator validates Yubico OTPs without a validation server: it
+// decrypts them with the AES key of the key slot, checks the private
+// identity and rejects OTPs whose counter does not exceed the last one it
+// accepted from the same key. Counters are kept in memory only.
+type OfflineValidator struct {
+	// Key returns the AES key and private identity of the key with the
+	// public identity publicID, ok false for unknown keys.
+	Key func(publicID string) (aesKey []byte, privateID [6]byte, ok bool)
+
+	mu   sync.Mutex
+	last map[string]uint32
+}
+
+// Validate decrypts and checks otp.
+func (v *OfflineValidator) Validate(_ context.Context, otp *OTP) error {
+	if otp.HOTP {
+		return fmt.Errorf("%w: not a yubico otp", ErrInvalidOTP)
+	}
+	key, privateID, ok := v.Key(otp.PublicID)
+	if !ok {
+		return fmt.Errorf("%w: unknown key %q", ErrInvalidOTP, otp.PublicID)
+	}
+	t, err := Decrypt(otp, key)
+	if err != nil {
+		return err
+	}
+	if t.PrivateID != privateID {
+		return fmt.Errorf("%w: private identity mismatch", ErrInvalidOTP)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if last, seen := v.last[otp.PublicID]; seen && t.Counter() <= last {
+		return fmt.Errorf("%w: replayed", ErrInvalidOTP)
+	}
+	if v.last == nil {
+		v.last = make(map[string]uint32)
+	}
+	v.last[otp.PublicID] = t.Counter()
+	return nil
+}
+
+// crcResidue is the CRC of a token including its checksum.
+const crcResidue = 0xF0B8
+
+// crc16 computes the ISO 13239 CRC of b.
+func crc16(b []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, c := range b {
+		crc ^= uint16(c)
+		for i := 0; i < 8; i++ {
+			lsb := crc & 1
+			crc >>= 1
+			if lsb != 0 {
+				crc ^= 0x8408
+			}
+		}
+	}
+	return crc
+}
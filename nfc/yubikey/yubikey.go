@@ -0,0 +1,170 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package yubikey reads the one-time passwords YubiKeys present over NFC:
+// a key tapped on a reader exposes an NFC Forum Type 4 tag whose NDEF
+// message carries a fresh OTP, typically in a URI such as
+// https://my.yubico.com/yk/#<otp>. Reader recognizes the key, reads and
+// checks the OTP and hands it to validation hooks, e.g. to verify it with a
+// validation server or to decrypt it with the AES key of the key slot.
+package yubikey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// OTPAID is the application identifier of the Yubico OTP application,
+// selected to recognize keys whose ATR does not name them.
+var OTPAID = []byte{0xA0, 0x00, 0x00, 0x05, 0x27, 0x20, 0x01}
+
+var (
+	// ErrNotYubiKey is returned for cards not recognized as YubiKeys.
+	ErrNotYubiKey = errors.New("yubikey: not a yubikey")
+	// ErrNoOTP is returned when the NDEF message carries no OTP.
+	ErrNoOTP = errors.New("yubikey: no otp")
+	// ErrInvalidOTP is returned for OTPs which are malformed or fail
+	// validation.
+	ErrInvalidOTP = errors.New("yubikey: invalid otp")
+)
+
+// OTP is a one-time password read from a YubiKey.
+type OTP struct {
+	// Value is the OTP as the key types it.
+	Value string
+	// PublicID is the modhex public identity prefixing a Yubico OTP,
+	// empty for keys configured without one and for OATH-HOTP codes.
+	PublicID string
+	// HOTP reports an OATH-HOTP code of 6 or 8 digits rather than a
+	// Yubico OTP.
+	HOTP bool
+	// Source is the URI or text of the NDEF record carrying the OTP.
+	Source string
+}
+
+// Validator checks an OTP read by a Reader.
+type Validator interface {
+	Validate(ctx context.Context, otp *OTP) error
+}
+
+// ValidatorFunc adapts a function to the Validator interface.
+type ValidatorFunc func(ctx context.Context, otp *OTP) error
+
+// Validate calls f(ctx, otp).
+func (f ValidatorFunc) Validate(ctx context.Context, otp *OTP) error { return f(ctx, otp) }
+
+// AllowPublicIDs returns a validator accepting only Yubico OTPs of the keys
+// with the given public identities.
+func AllowPublicIDs(ids ...string) Validator {
+	allowed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		allowed[id] = true
+	}
+	return ValidatorFunc(func(_ context.Context, otp *OTP) error {
+		if otp.HOTP || !allowed[otp.PublicID] {
+			return fmt.Errorf("%w: key %q not allowed", ErrInvalidOTP, otp.PublicID)
+		}
+		return nil
+	})
+}
+
+// Reader reads OTPs from YubiKeys. The zero value reads without
+// validation hooks.
+type Reader struct {
+	// Validators are called in order with each OTP read; the first error
+	// fails the read.
+	Validators []Validator
+}
+
+// Read reads the OTP of card using a zero Reader.
+func Read(ctx context.Context, card tag.Card) (*OTP, error) {
+	var r Reader
+	return r.Read(ctx, card)
+}
+
+// Read recognizes card as a YubiKey, reads the OTP of its NDEF message and
+// validates it with the validators of r.
+func (r *Reader) Read(ctx context.Context, card tag.Card) (*OTP, error) {
+	if !IsYubiKey(card) {
+		return nil, ErrNotYubiKey
+	}
+	raw, err := tag.ReadNDEF(card)
+	if err != nil {
+		return nil, fmt.Errorf("yubikey: %w", err)
+	}
+	var msg ndef.Message
+	if err := msg.Unmarshal(raw); err != nil {
+		return nil, fmt.Errorf("yubikey: %w", err)
+	}
+	otp, err := findOTP(&msg)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range r.Validators {
+		if err := v.Validate(ctx, otp); err != nil {
+			return nil, err
+		}
+	}
+	return otp, nil
+}
+
+// findOTP returns the OTP of the first URI or text record of msg.
+func findOTP(msg *ndef.Message) (*OTP, error) {
+	for _, rec := range msg.Records {
+		if uri, err := rec.URI(); err == nil {
+			return ParseOTP(uri)
+		}
+		if text, _, err := rec.Text(); err == nil {
+			return ParseOTP(text)
+		}
+	}
+	return nil, ErrNoOTP
+}
+
+// ParseOTP extracts and checks the OTP of s, the URI or text of an NDEF
+// record: the fragment of a URI, or else its last path element, or the
+// whole text.
+func ParseOTP(s string) (*OTP, error) {
+	value := strings.TrimSpace(s)
+	if i := strings.LastIndexByte(value, '#'); i >= 0 {
+		value = value[i+1:]
+	} else if i := strings.LastIndexByte(value, '/'); i >= 0 {
+		value = value[i+1:]
+	}
+	if value == "" {
+		return nil, ErrNoOTP
+	}
+	otp := &OTP{Value: value, Source: s}
+	switch {
+	case (len(value) == 6 || len(value) == 8) && strings.Trim(value, "0123456789") == "":
+		otp.HOTP = true
+	case len(value) >= 32 && len(value) <= 64 && len(value)%2 == 0 && isModhex(value):
+		otp.PublicID = value[:len(value)-32]
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidOTP, value)
+	}
+	return otp, nil
+}
+
+// IsYubiKey reports whether card is a YubiKey: its historical bytes name
+// it, or it has the Yubico OTP application.
+func IsYubiKey(card tag.Card) bool {
+	if atr, err := iso7816.ParseATR(card.ATR()); err == nil && bytes.Contains(bytes.ToLower(atr.Historical), []byte("yubikey")) {
+		return true
+	}
+	raw, err := iso7816.NewCommandAPDU(0x00, 0xA4, 0x04, 0x00, 0, OTPAID).Marshal()
+	if err != nil {
+		return false
+	}
+	resp, err := card.Transmit(raw)
+	return err == nil && apdu.CheckStatusFromData(resp) == nil
+}
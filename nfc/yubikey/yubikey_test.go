@@ -0,0 +1,185 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package yubikey
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+)
+
+var (
+	ndefAID   = []byte{0xD2, 0x76, 0x00, 0x00, 0x85, 0x01, 0x01}
+	aesKey    = bytes.Repeat([]byte{0x42}, 16)
+	privateID = [6]byte{1, 2, 3, 4, 5, 6}
+	publicID  = "ccccccbcgujh"
+)
+
+func mustHex(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	return b
+}
+
+// newOTP returns a Yubico OTP of publicID with the given counters.
+func newOTP(useCounter uint16, session byte) string {
+	pt := make([]byte, 16)
+	copy(pt, privateID[:])
+	binary.LittleEndian.PutUint16(pt[6:], useCounter)
+	pt[8], pt[9], pt[10], pt[11] = 0x10, 0x20, 0x30, session
+	binary.LittleEndian.PutUint16(pt[12:], 0xBEEF)
+	binary.LittleEndian.PutUint16(pt[14:], ^crc16(pt[:14]))
+	block, _ := aes.NewCipher(aesKey)
+	ct := make([]byte, 16)
+	block.Encrypt(ct, pt)
+	return publicID + EncodeModhex(ct)
+}
+
+// fakeKey emulates a YubiKey presenting msg as NDEF message of a Type 4
+// tag, with the OTP application when otp is set.
+type fakeKey struct {
+	atr      string
+	otp      bool
+	files    map[string][]byte
+	selected []byte
+}
+
+func newFakeKey(atr string, otp bool, msg *ndef.Message) *fakeKey {
+	raw, _ := msg.Marshal()
+	cc := []byte{0x00, 0x0F, 0x20, 0x00, 0x3B, 0x00, 0x34, 0x04, 0x06, 0xE1, 0x04, 0x00, 0xFF, 0x00, 0xFF}
+	file := append([]byte{byte(len(raw) >> 8), byte(len(raw))}, raw...)
+	return &fakeKey{atr: atr, otp: otp, files: map[string][]byte{"\xE1\x03": cc, "\xE1\x04": file}}
+}
+
+func (f *fakeKey) ATR() []byte { return mustHex(f.atr) }
+
+func (f *fakeKey) Transmit(cmd []byte) ([]byte, error) {
+	ok, notFound := []byte{0x90, 0x00}, []byte{0x6A, 0x82}
+	switch cmd[1] {
+	case 0xA4:
+		id := cmd[5 : 5+int(cmd[4])]
+		switch {
+		case cmd[2] == 0x04 && bytes.Equal(id, ndefAID):
+			return ok, nil
+		case cmd[2] == 0x04 && bytes.Equal(id, OTPAID) && f.otp:
+			return ok, nil
+		case cmd[2] == 0x00 && f.files[string(id)] != nil:
+			f.selected = id
+			return ok, nil
+		}
+		return notFound, nil
+	case 0xB0:
+		file := f.files[string(f.selected)]
+		off := int(cmd[2])<<8 | int(cmd[3])
+		end := min(off+int(cmd[4]), len(file))
+		return append(append([]byte(nil), file[off:end]...), ok...), nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+const (
+	yubiKeyATR = "3B8D80018073C021C057597562694B6579F9"
+	isoDEPATR  = "3B8180018080"
+)
+
+func TestRead(t *testing.T) {
+	otp := newOTP(7, 1)
+	text, _ := ndef.NewTextRecord("en", "287082")
+	tests := []struct {
+		name string
+		card *fakeKey
+		want *OTP
+		err  error
+	}{
+		{"uri", newFakeKey(yubiKeyATR, false, ndef.NewMessage(ndef.NewURIRecord("https://my.yubico.com/yk/#"+otp))),
+			&OTP{Value: otp, PublicID: publicID, Source: "https://my.yubico.com/yk/#" + otp}, nil},
+		{"legacy uri", newFakeKey(isoDEPATR, true, ndef.NewMessage(ndef.NewURIRecord("https://my.yubico.com/neo/"+otp))),
+			&OTP{Value: otp, PublicID: publicID, Source: "https://my.yubico.com/neo/" + otp}, nil},
+		{"hotp text", newFakeKey(yubiKeyATR, false, ndef.NewMessage(text)), &OTP{Value: "287082", HOTP: true, Source: "287082"}, nil},
+		{"not a yubikey", newFakeKey(isoDEPATR, false, ndef.NewMessage(ndef.NewURIRecord("https://example.com/#"+otp))), nil, ErrNotYubiKey},
+		{"no otp", newFakeKey(yubiKeyATR, false, ndef.NewMessage(ndef.NewRecord(ndef.TNFMedia, []byte("text/plain"), nil, []byte("hi")))), nil, ErrNoOTP},
+		{"malformed", newFakeKey(yubiKeyATR, false, ndef.NewMessage(ndef.NewURIRecord("https://my.yubico.com/yk/#notanotp"))), nil, ErrInvalidOTP},
+	}
+	for _, tt := range tests {
+		got, err := Read(context.Background(), tt.card)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: Read() error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if tt.want != nil && *got != *tt.want {
+			t.Errorf("%s: Read() = %+v, want %+v", tt.name, *got, *tt.want)
+		}
+	}
+}
+
+func TestValidators(t *testing.T) {
+	offline := &OfflineValidator{Key: func(id string) ([]byte, [6]byte, bool) {
+		return aesKey, privateID, id == publicID
+	}}
+	r := &Reader{Validators: []Validator{AllowPublicIDs(publicID), offline}}
+	read := func(value string) error {
+		card := newFakeKey(yubiKeyATR, false, ndef.NewMessage(ndef.NewURIRecord("https://my.yubico.com/yk/#"+value)))
+		_, err := r.Read(context.Background(), card)
+		return err
+	}
+
+	tampered := []byte(newOTP(9, 0))
+	last := len(tampered) - 1
+	tampered[last] = modhex[(strings.IndexByte(modhex, tampered[last])+1)%16]
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"first", newOTP(3, 5), true},
+		{"next session", newOTP(3, 6), true},
+		{"replay", newOTP(3, 6), false},
+		{"older", newOTP(2, 9), false},
+		{"next use", newOTP(4, 0), true},
+		{"tampered", string(tampered), false},
+		{"other key", "vvvvvvvvvvvv" + newOTP(5, 0)[12:], false},
+		{"hotp", "123456", false},
+	}
+	for _, tt := range tests {
+		err := read(tt.value)
+		if (err == nil) != tt.ok || (err != nil && !errors.Is(err, ErrInvalidOTP)) {
+			t.Errorf("%s: Read() error = %v", tt.name, err)
+		}
+	}
+
+	tok, err := Decrypt(&OTP{Value: newOTP(0x0102, 0x03)}, aesKey)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	want := Token{PrivateID: privateID, UseCounter: 0x0102, SessionCounter: 0x03, Timestamp: 0x302010, Random: 0xBEEF}
+	if *tok != want || tok.Counter() != 0x010203 {
+		t.Errorf("Decrypt() = %+v", *tok)
+	}
+	if _, err := Decrypt(&OTP{Value: newOTP(1, 1)}, aesKey[:8]); err == nil {
+		t.Error("Decrypt(short key) succeeded")
+	}
+}
+
+func TestModhex(t *testing.T) {
+	b := mustHex("0123456789ABCDEF")
+	s := EncodeModhex(b)
+	if s != "cbdefghijklnrtuv" {
+		t.Errorf("EncodeModhex() = %s", s)
+	}
+	if got, err := DecodeModhex(s); err != nil || !bytes.Equal(got, b) {
+		t.Errorf("DecodeModhex() = %X, %v", got, err)
+	}
+	for _, bad := range []string{"cbd", "cbxx"} {
+		if _, err := DecodeModhex(bad); err == nil {
+			t.Errorf("DecodeModhex(%s) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// CardHandler handles a card connected by the SDK. The card is disconnected
+// once the handler returns. With the default PC/SC backend card is a
+// *pcsc.Card. Handlers using more of the session, such as its logger or the
+// detected tag type, are written with CardFunc.
+type CardHandler func(ctx context.Context, ev cardreader.Event, card transport.Card) error
+
+// WithCardHandler sets the handler called for each card the SDK connects to
+// which has no handler registered with Handle or HandleATR.
+func WithCardHandler(h CardHandler) Option {
+	return func(sdk *SDK) {
+		sdk.cardHandler = h
+	}
+}
+
+// RunOnce waits for the first card on the selected readers, handles it with
+// the handler registered for it and returns. It is WaitForCard without a timeout.
+func (sdk *SDK) RunOnce(ctx context.Context) error {
+	_, err := sdk.WaitForCard(ctx, 0)
+	return err
+}
+
+// WaitForCard blocks until a card is present on one of the selected readers,
+// or until timeout elapses when it is greater than zero, in which case
+// pcsc.ErrTimeout is returned. The card is passed to the handler registered
+// for it, if any, and disconnected; the card inserted event is returned together with
+// the error of the handler. It suits command line tools and scripts which
+// process a single tap.
+func (sdk *SDK) WaitForCard(ctx context.Context, timeout time.Duration) (ev cardreader.Event, err error) {
+	sdk.mu.RLock()
+	closed := sdk.closed
+	sdk.mu.RUnlock()
+	if closed {
+		return ev, ErrClosed
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	defer sdk.stopMonitoring()
+	card, reader, err := sdk.waitCard(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && timeout > 0 {
+			return ev, fmt.Errorf("wait for card: %w", pcsc.ErrTimeout)
+		}
+		return ev, err
+	}
+	return sdk.handleCard(ctx, card, reader, false)
+}
+
+// beginSession counts a card session, which Shutdown waits for and the
+// state of the SDK reports, until the returned function is called. It
+// returns ErrClosed after Shutdown.
+func (sdk *SDK) beginSession() (func(), error) {
+	sdk.mu.Lock()
+	if sdk.closed {
+		sdk.mu.Unlock()
+		return nil, ErrClosed
+	}
+	sdk.inflight.Add(1)
+	sdk.sessions++
+	sdk.mu.Unlock()
+	sdk.refreshState()
+	return func() {
+		sdk.mu.Lock()
+		sdk.sessions--
+		sdk.mu.Unlock()
+		sdk.refreshState()
+		sdk.inflight.Done()
+	}, nil
+}
+
+// handleCard passes card to the handler registered for it, if any, and
+// disconnects it. With debounce set, cards suppressed by the tap debounce
+// are disconnected without calling the handler. It returns ErrClosed
+// without calling the handler after Shutdown.
+func (sdk *SDK) handleCard(ctx context.Context, card transport.Card, reader cardreader.Reader, debounce bool) (ev cardreader.Event, err error) {
+	defer func() {
+		if derr := card.Disconnect(); derr != nil {
+			sdk.logger.Warn("disconnect card", sessionGroup(ev, card), slog.Any("error", derr))
+			if err == nil {
+				err = derr
+			}
+		}
+	}()
+
+	ev = sdk.newCardEvent(card, reader)
+	if debounce && sdk.bounced(ev) {
+		sdk.logger.Debug("card suppressed by tap debounce", sessionGroup(ev, card))
+		return ev, nil
+	}
+	sdk.readNDEF(ctx, &ev, card)
+	sdk.recordCard(reader, cardreader.CardInfo{UID: ev.UID, ATR: ev.ATR, Time: ev.Time, NDEF: ndefSummary(ev.NDEF)})
+	sdk.resolveIdentity(ctx, &ev)
+	sdk.emit(ev)
+	sdk.dispatchZone(ev)
+	typ := sdk.detectType(ctx, card, reader.Name)
+	handler := sdk.dispatchHandler(ev, typ)
+	if handler == nil {
+		sdk.logger.Debug("card has no handler", sessionGroup(ev, card))
+		return ev, nil
+	}
+
+	done, err := sdk.beginSession()
+	if err != nil {
+		return ev, err
+	}
+	defer done()
+
+	end := sdk.logSession(ev, card)
+	defer func() { end(err) }()
+
+	if sdk.sessionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, ev.Time.Add(sdk.sessionTimeout))
+		defer cancel()
+	}
+	ctx, span := sdk.startCardSpan(ctx, ev, card)
+	defer func() { endSpan(span, err) }()
+	defer sdk.traceTransport(ctx, reader.Name, card)()
+	return ev, handler(sdk.withCardContext(ctx, ev, reader, sdk.sessionType(typ, ev.ATR), card), ev, card)
+}
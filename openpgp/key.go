@@ -0,0 +1,151 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package openpgp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// ErrNoKey is returned when a key slot holds no key.
+var ErrNoKey = errors.New("openpgp: no key")
+
+// Algorithm identifiers of the algorithm attributes.
+const (
+	AlgRSA   = 0x01
+	AlgECDH  = 0x12
+	AlgECDSA = 0x13
+	AlgEdDSA = 0x16
+)
+
+// Curve object identifiers of the algorithm attributes, DER encoded without
+// tag and length.
+var (
+	oidP256       = []byte{0x2A, 0x86, 0x48, 0xCE, 0x3D, 0x03, 0x01, 0x07}
+	oidP384       = []byte{0x2B, 0x81, 0x04, 0x00, 0x22}
+	oidP521       = []byte{0x2B, 0x81, 0x04, 0x00, 0x23}
+	oidEd25519    = []byte{0x2B, 0x06, 0x01, 0x04, 0x01, 0xDA, 0x47, 0x0F, 0x01}
+	oidCurve25519 = []byte{0x2B, 0x06, 0x01, 0x04, 0x01, 0x97, 0x55, 0x01, 0x05, 0x01}
+)
+
+// Attributes are the algorithm attributes of a key slot.
+type Attributes struct {
+	// Algorithm is AlgRSA, AlgECDH, AlgECDSA or AlgEdDSA.
+	Algorithm byte
+	// Bits is the modulus size of RSA keys.
+	Bits int
+	// Curve is the object identifier of the curve of ECC keys.
+	Curve []byte
+}
+
+// KeyAttributes reads the algorithm attributes of key from the application
+// related data.
+func KeyAttributes(tr apdu.Transceiver, key Key) (*Attributes, error) {
+	data, err := GetData(tr, 0x006E)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := iso7816.FindTLV(data, key.attributesTag())
+	if !ok || len(b) == 0 {
+		return nil, fmt.Errorf("openpgp: no algorithm attributes for %s", key)
+	}
+	attrs := &Attributes{Algorithm: b[0]}
+	switch {
+	case b[0] == AlgRSA && len(b) >= 3:
+		attrs.Bits = int(b[1])<<8 | int(b[2])
+	case b[0] == AlgECDH, b[0] == AlgECDSA, b[0] == AlgEdDSA:
+		// The curve may be followed by the import format FF.
+		attrs.Curve = bytes.TrimSuffix(b[1:], []byte{0xFF})
+	default:
+		return nil, fmt.Errorf("openpgp: %s algorithm attributes %X", key, b)
+	}
+	return attrs, nil
+}
+
+// PublicKey reads the public key of key: an *rsa.PublicKey, an
+// *ecdsa.PublicKey, an ed25519.PublicKey or, for ECDH keys, an
+// *ecdh.PublicKey.
+func PublicKey(tr apdu.Transceiver, key Key) (crypto.PublicKey, error) {
+	attrs, err := KeyAttributes(tr, key)
+	if err != nil {
+		return nil, err
+	}
+	cmd := iso7816.NewCommandAPDU(0x00, 0x47, 0x81, 0x00, 0, []byte{byte(key), 0x00})
+	cmd.Ne = 256
+	resp, err := transmit(tr, cmd)
+	var se *apdu.StatusError
+	if errors.As(err, &se) && se.SW1 == 0x6A && se.SW2 == 0x88 {
+		return nil, ErrNoKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("openpgp: read %s: %w", key, err)
+	}
+	tmpl, ok := iso7816.FindTLV(resp, 0x7F49)
+	if !ok {
+		return nil, fmt.Errorf("openpgp: read %s: missing public key template", key)
+	}
+	pub, err := parsePublicKey(attrs, tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("openpgp: %s: %w", key, err)
+	}
+	return pub, nil
+}
+
+// parsePublicKey decodes the public key template tmpl of a key with attrs.
+func parsePublicKey(attrs *Attributes, tmpl []byte) (crypto.PublicKey, error) {
+	if attrs.Algorithm == AlgRSA {
+		n, ok1 := iso7816.FindTLV(tmpl, 0x81)
+		e, ok2 := iso7816.FindTLV(tmpl, 0x82)
+		if !ok1 || !ok2 || len(e) == 0 || len(e) > 4 {
+			return nil, ErrNoKey
+		}
+		exp := new(big.Int).SetBytes(e)
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	}
+	point, ok := iso7816.FindTLV(tmpl, 0x86)
+	if !ok || len(point) == 0 {
+		return nil, ErrNoKey
+	}
+	switch {
+	case bytes.Equal(attrs.Curve, oidEd25519):
+		if len(point) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("ed25519 key of %d bytes", len(point))
+		}
+		return ed25519.PublicKey(point), nil
+	case bytes.Equal(attrs.Curve, oidCurve25519):
+		return ecdh.X25519().NewPublicKey(point)
+	}
+	var curve elliptic.Curve
+	var dh ecdh.Curve
+	switch {
+	case bytes.Equal(attrs.Curve, oidP256):
+		curve, dh = elliptic.P256(), ecdh.P256()
+	case bytes.Equal(attrs.Curve, oidP384):
+		curve, dh = elliptic.P384(), ecdh.P384()
+	case bytes.Equal(attrs.Curve, oidP521):
+		curve, dh = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %X", attrs.Curve)
+	}
+	if attrs.Algorithm == AlgECDH {
+		return dh.NewPublicKey(point)
+	}
+	x, y := elliptic.Unmarshal(curve, point)
+	if x == nil {
+		return nil, fmt.Errorf("invalid point")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package openpgp drives the OpenPGP card application (version 3.4 of the
+// functional specification): it selects the application, retrieves the
+// public keys of its key slots, verifies the PINs and computes signatures
+// and decryptions with PSO:COMPUTE DIGITAL SIGNATURE and PSO:DECIPHER.
+package openpgp
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// AID is the registered part of the application identifier of the OpenPGP
+// application, selected by partial name.
+var AID = []byte{0xD2, 0x76, 0x00, 0x01, 0x24, 0x01}
+
+// Application identifies the OpenPGP application of a card.
+type Application struct {
+	AID          []byte // Full application identifier.
+	Version      uint16 // Specification version, e.g. 0x0304 for 3.4.
+	Manufacturer uint16
+	Serial       uint32
+}
+
+// Key is a key slot of the OpenPGP application, named by the tag of its
+// control reference template.
+type Key byte
+
+const (
+	KeySignature      Key = 0xB6
+	KeyDecryption     Key = 0xB8
+	KeyAuthentication Key = 0xA4
+)
+
+// String returns the name of the key.
+func (k Key) String() string {
+	switch k {
+	case KeySignature:
+		return "signature key"
+	case KeyDecryption:
+		return "decryption key"
+	case KeyAuthentication:
+		return "authentication key"
+	default:
+		return fmt.Sprintf("key %02X", byte(k))
+	}
+}
+
+// attributesTag returns the tag of the algorithm attributes of the key.
+func (k Key) attributesTag() iso7816.Tag {
+	switch k {
+	case KeySignature:
+		return 0xC1
+	case KeyDecryption:
+		return 0xC2
+	default:
+		return 0xC3
+	}
+}
+
+// PIN is a password reference of the OpenPGP application.
+type PIN byte
+
+const (
+	// PW1Sign is the user PIN for signing.
+	PW1Sign PIN = 0x81
+	// PW1 is the user PIN for decryption and authentication.
+	PW1 PIN = 0x82
+	// PW3 is the admin PIN.
+	PW3 PIN = 0x83
+)
+
+// Select selects the OpenPGP application and reads its full identifier.
+func Select(tr apdu.Transceiver) (*Application, error) {
+	if _, err := transmit(tr, iso7816.NewCommandAPDU(0x00, 0xA4, 0x04, 0x00, 0, AID)); err != nil {
+		return nil, fmt.Errorf("openpgp: select: %w", err)
+	}
+	aid, err := GetData(tr, 0x004F)
+	if err != nil {
+		return nil, err
+	}
+	if len(aid) < 14 {
+		return nil, fmt.Errorf("openpgp: application identifier of %d bytes", len(aid))
+	}
+	return &Application{
+		AID:          aid,
+		Version:      binary.BigEndian.Uint16(aid[6:8]),
+		Manufacturer: binary.BigEndian.Uint16(aid[8:10]),
+		Serial:       binary.BigEndian.Uint32(aid[10:14]),
+	}, nil
+}
+
+// GetData reads the data object tag, e.g. 6E for the application related
+// data.
+func GetData(tr apdu.Transceiver, tag uint16) ([]byte, error) {
+	cmd := iso7816.NewCommandAPDU(0x00, 0xCA, byte(tag>>8), byte(tag), 0, nil)
+	cmd.Ne = 256
+	data, err := transmit(tr, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("openpgp: get data %04X: %w", tag, err)
+	}
+	return data, nil
+}
+
+// VerifyPIN verifies pin as the password pw. PW1Sign is required before
+// each signature unless the card keeps it valid, PW1 before decryption and
+// authentication.
+func VerifyPIN(tr apdu.Transceiver, pw PIN, pin string) error {
+	if n := len(pin); n < 6 || (pw == PW3 && n < 8) || n > 127 {
+		return fmt.Errorf("openpgp: pin of %d characters", n)
+	}
+	if _, err := transmit(tr, iso7816.NewCommandAPDU(0x00, 0x20, 0x00, byte(pw), 0, []byte(pin))); err != nil {
+		return fmt.Errorf("openpgp: verify pin %02X: %w", byte(pw), err)
+	}
+	return nil
+}
+
+// Retries returns the remaining attempts of the user PIN, the resetting
+// code and the admin PIN, read from the PW status bytes.
+func Retries(tr apdu.Transceiver) (pw1, rc, pw3 int, err error) {
+	data, err := GetData(tr, 0x00C4)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if len(data) < 7 {
+		return 0, 0, 0, fmt.Errorf("openpgp: pw status of %d bytes", len(data))
+	}
+	return int(data[4]), int(data[5]), int(data[6]), nil
+}
+
+// Sign computes a digital signature with the signature key over data: the
+// DigestInfo of the digest for RSA keys, the digest for ECDSA keys and the
+// message for EdDSA keys. ECDSA signatures are returned as r || s.
+func Sign(tr apdu.Transceiver, data []byte) ([]byte, error) {
+	return pso(tr, 0x9E, 0x9A, data)
+}
+
+// Authenticate computes a signature with the authentication key over data,
+// encoded as for Sign, using INTERNAL AUTHENTICATE.
+func Authenticate(tr apdu.Transceiver, data []byte) ([]byte, error) {
+	cmd := iso7816.NewCommandAPDU(0x00, 0x88, 0x00, 0x00, 0, data)
+	cmd.Ne = 256
+	resp, err := transmit(tr, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("openpgp: internal authenticate: %w", err)
+	}
+	return resp, nil
+}
+
+// Decipher decrypts the RSA cryptogram with the decryption key and returns
+// the message with its PKCS #1 v1.5 padding removed by the card.
+func Decipher(tr apdu.Transceiver, cryptogram []byte) ([]byte, error) {
+	return pso(tr, 0x80, 0x86, append([]byte{0x00}, cryptogram...))
+}
+
+// ECDH computes the shared secret of the ECDH decryption key with the
+// public key of the other party, an uncompressed point or, for Curve25519,
+// the 32 byte key.
+func ECDH(tr apdu.Transceiver, public []byte) ([]byte, error) {
+	data := iso7816.AppendTLV(nil, 0xA6, iso7816.AppendTLV(nil, 0x7F49, iso7816.AppendTLV(nil, 0x86, public)))
+	return pso(tr, 0x80, 0x86, data)
+}
+
+// pso sends PERFORM SECURITY OPERATION with p1 and p2 over data.
+func pso(tr apdu.Transceiver, p1, p2 byte, data []byte) ([]byte, error) {
+	cmd := iso7816.NewCommandAPDU(0x00, 0x2A, p1, p2, 0, data)
+	cmd.Ne = 256
+	resp, err := transmit(tr, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("openpgp: pso %02X%02X: %w", p1, p2, err)
+	}
+	return resp, nil
+}
+
+// transmit sends cmd, splitting its data with command chaining when it
+// exceeds a short APDU and collecting responses announced by 61xx.
+func transmit(tr apdu.Transceiver, cmd *iso7816.CommandAPDU) ([]byte, error) {
+	raw, err := cmd.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := apdu.Wrap(tr, apdu.Chaining(255), apdu.GetResponse()).Transmit(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		return nil, err
+	}
+	return resp[:len(resp)-2], nil
+}
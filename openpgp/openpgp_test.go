@@ -0,0 +1,221 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package openpgp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+var fullAID = []byte{0xD2, 0x76, 0x00, 0x01, 0x24, 0x01, 0x03, 0x04, 0x00, 0x06, 0x12, 0x34, 0x56, 0x78, 0x00, 0x00}
+
+// fakeCard emulates an OpenPGP application with an RSA signature key, an
+// ECDH decryption key and an optional ECDSA authentication key, all on
+// P-256. Responses longer than 256 bytes are returned in parts with 61xx,
+// and chained commands are collected before processing.
+type fakeCard struct {
+	sig      *rsa.PrivateKey
+	dec      *ecdh.PrivateKey
+	auth     *ecdsa.PrivateKey
+	verified map[byte]bool
+	chained  []byte
+	pending  []byte
+}
+
+func newFakeCard(t *testing.T, auth bool) *fakeCard {
+	f := &fakeCard{verified: map[byte]bool{}}
+	var err error
+	if f.sig, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if f.dec, err = ecdh.P256().GenerateKey(rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if auth {
+		if f.auth, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+func (f *fakeCard) respond(b []byte) []byte {
+	if len(b) <= 256 {
+		f.pending = nil
+		return append(append([]byte(nil), b...), 0x90, 0x00)
+	}
+	f.pending = b[256:]
+	return append(append([]byte(nil), b[:256]...), 0x61, byte(min(len(f.pending), 256)))
+}
+
+func (f *fakeCard) Transmit(raw []byte) ([]byte, error) {
+	cmd, err := iso7816.UnmarshalCommandAPDU(raw)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Cla&0x10 != 0 {
+		f.chained = append(f.chained, cmd.Data...)
+		return []byte{0x90, 0x00}, nil
+	}
+	data := append(f.chained, cmd.Data...)
+	f.chained = nil
+
+	switch cmd.Ins {
+	case 0xA4:
+		return []byte{0x90, 0x00}, nil
+	case 0xC0:
+		return f.respond(f.pending), nil
+	case 0xCA:
+		switch uint16(cmd.P1)<<8 | uint16(cmd.P2) {
+		case 0x004F:
+			return f.respond(fullAID), nil
+		case 0x006E:
+			attrs := iso7816.AppendTLV(nil, 0xC1, []byte{AlgRSA, 0x08, 0x00, 0x00, 0x20, 0x00})
+			attrs = iso7816.AppendTLV(attrs, 0xC2, append([]byte{AlgECDH}, oidP256...))
+			attrs = iso7816.AppendTLV(attrs, 0xC3, append(append([]byte{AlgECDSA}, oidP256...), 0xFF))
+			return f.respond(iso7816.AppendTLV(nil, 0x6E, append(iso7816.AppendTLV(nil, 0x4F, fullAID), iso7816.AppendTLV(nil, 0x73, attrs)...))), nil
+		case 0x00C4:
+			return f.respond([]byte{0x01, 0x7F, 0x7F, 0x7F, 0x03, 0x00, 0x02}), nil
+		}
+	case 0x20:
+		if string(data) != map[byte]string{0x81: "123456", 0x82: "123456", 0x83: "12345678"}[cmd.P2] {
+			return []byte{0x63, 0xC2}, nil
+		}
+		f.verified[cmd.P2] = true
+		return []byte{0x90, 0x00}, nil
+	case 0x47:
+		var tmpl []byte
+		switch Key(data[0]) {
+		case KeySignature:
+			tmpl = iso7816.AppendTLV(iso7816.AppendTLV(nil, 0x81, f.sig.N.Bytes()), 0x82, []byte{0x01, 0x00, 0x01})
+		case KeyDecryption:
+			tmpl = iso7816.AppendTLV(nil, 0x86, f.dec.PublicKey().Bytes())
+		case KeyAuthentication:
+			if f.auth == nil {
+				return []byte{0x6A, 0x88}, nil
+			}
+			tmpl = iso7816.AppendTLV(nil, 0x86, elliptic.Marshal(elliptic.P256(), f.auth.X, f.auth.Y))
+		}
+		return f.respond(iso7816.AppendTLV(nil, 0x7F49, tmpl)), nil
+	case 0x2A:
+		switch {
+		case cmd.P1 == 0x9E && f.verified[0x81]:
+			sig, _ := rsa.SignPKCS1v15(nil, f.sig, 0, data)
+			return f.respond(sig), nil
+		case cmd.P1 == 0x80 && f.verified[0x82] && data[0] == 0x00:
+			msg, err := rsa.DecryptPKCS1v15(nil, f.sig, data[1:])
+			if err != nil {
+				return []byte{0x6A, 0x80}, nil
+			}
+			return f.respond(msg), nil
+		case cmd.P1 == 0x80 && f.verified[0x82]:
+			point, _ := iso7816.FindTLV(data, 0x86)
+			pub, err := ecdh.P256().NewPublicKey(point)
+			if err != nil {
+				return []byte{0x6A, 0x80}, nil
+			}
+			secret, _ := f.dec.ECDH(pub)
+			return f.respond(secret), nil
+		}
+		return []byte{0x69, 0x82}, nil
+	case 0x88:
+		if !f.verified[0x82] {
+			return []byte{0x69, 0x82}, nil
+		}
+		r, s, _ := ecdsa.Sign(rand.Reader, f.auth, data)
+		return f.respond(append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)), nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+func TestCard(t *testing.T) {
+	f := newFakeCard(t, true)
+	app, err := Select(f)
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if !bytes.Equal(app.AID, fullAID) || app.Version != 0x0304 || app.Manufacturer != 0x0006 || app.Serial != 0x12345678 {
+		t.Errorf("Select() = %+v", app)
+	}
+	if pw1, rc, pw3, err := Retries(f); pw1 != 3 || rc != 0 || pw3 != 2 || err != nil {
+		t.Errorf("Retries() = %d, %d, %d, %v", pw1, rc, pw3, err)
+	}
+
+	pins := []struct {
+		pw  PIN
+		pin string
+		ok  bool
+	}{
+		{PW1Sign, "654321", false},
+		{PW3, "123456", false},
+		{PW1Sign, "123456", true},
+		{PW1, "123456", true},
+	}
+	for _, tt := range pins {
+		if err := VerifyPIN(f, tt.pw, tt.pin); (err == nil) != tt.ok {
+			t.Errorf("VerifyPIN(%02X, %s) error = %v", byte(tt.pw), tt.pin, err)
+		}
+	}
+	var se *apdu.StatusError
+	if err := VerifyPIN(f, PW1, "000000"); !errors.As(err, &se) || se.SW1 != 0x63 {
+		t.Errorf("VerifyPIN(wrong) error = %v", err)
+	}
+
+	digest := sha256.Sum256([]byte("message"))
+	for _, key := range []Key{KeySignature, KeyAuthentication} {
+		s, err := NewSigner(f, key)
+		if err != nil {
+			t.Fatalf("NewSigner(%s) error = %v", key, err)
+		}
+		sig, err := s.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("%s: Sign() error = %v", key, err)
+		}
+		switch pub := s.Public().(type) {
+		case *rsa.PublicKey:
+			err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+		case *ecdsa.PublicKey:
+			if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+				err = errors.New("invalid signature")
+			}
+		}
+		if err != nil {
+			t.Errorf("%s: %v", key, err)
+		}
+	}
+	if _, err := NewSigner(f, KeyDecryption); err == nil {
+		t.Error("NewSigner(decryption key) succeeded")
+	}
+
+	pub, err := PublicKey(f, KeyDecryption)
+	if err != nil {
+		t.Fatalf("PublicKey(decryption) error = %v", err)
+	}
+	peer, _ := ecdh.P256().GenerateKey(rand.Reader)
+	want, _ := peer.ECDH(pub.(*ecdh.PublicKey))
+	if got, err := ECDH(f, peer.PublicKey().Bytes()); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("ECDH() = %X, %v", got, err)
+	}
+
+	cryptogram, _ := rsa.EncryptPKCS1v15(rand.Reader, &f.sig.PublicKey, []byte("session key"))
+	if got, err := Decipher(f, cryptogram); err != nil || string(got) != "session key" {
+		t.Errorf("Decipher() = %q, %v", got, err)
+	}
+
+	if _, err := PublicKey(newFakeCard(t, false), KeyAuthentication); !errors.Is(err, ErrNoKey) {
+		t.Errorf("PublicKey(empty slot) error = %v", err)
+	}
+}
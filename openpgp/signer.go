@@ -0,0 +1,102 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package openpgp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
+// Signer is a crypto.Signer backed by the signature or the authentication
+// key of the card. The PIN must have been verified with VerifyPIN, PW1Sign
+// for the signature key and PW1 for the authentication key.
+type Signer struct {
+	tr  apdu.Transceiver
+	key Key
+	pub crypto.PublicKey
+}
+
+// NewSigner returns a signer for key, KeySignature or KeyAuthentication,
+// reading its public key from the card.
+func NewSigner(tr apdu.Transceiver, key Key) (*Signer, error) {
+	if key != KeySignature && key != KeyAuthentication {
+		return nil, fmt.Errorf("openpgp: %s cannot sign", key)
+	}
+	pub, err := PublicKey(tr, key)
+	if err != nil {
+		return nil, err
+	}
+	switch pub.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+	default:
+		return nil, fmt.Errorf("openpgp: %s is not a signing key", key)
+	}
+	return &Signer{tr: tr, key: key, pub: pub}, nil
+}
+
+// Public returns the public key of the signer.
+func (s *Signer) Public() crypto.PublicKey { return s.pub }
+
+// Sign signs digest with the key of the card: PKCS #1 v1.5 for RSA keys,
+// ASN.1 encoded ECDSA for ECDSA keys and Ed25519 for EdDSA keys, for which
+// digest is the message and opts must not name a hash. rand is unused, the
+// card provides its own randomness.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	data := digest
+	switch s.pub.(type) {
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return nil, fmt.Errorf("openpgp: rsa pss is not supported")
+		}
+		prefix, ok := digestInfoPrefixes[opts.HashFunc()]
+		if !ok || len(digest) != opts.HashFunc().Size() {
+			return nil, fmt.Errorf("openpgp: unsupported hash %v", opts.HashFunc())
+		}
+		data = append(append([]byte(nil), prefix...), digest...)
+	case ed25519.PublicKey:
+		if opts.HashFunc() != 0 {
+			return nil, fmt.Errorf("openpgp: ed25519 signs messages, not %v digests", opts.HashFunc())
+		}
+	}
+
+	var sig []byte
+	var err error
+	if s.key == KeySignature {
+		sig, err = Sign(s.tr, data)
+	} else {
+		sig, err = Authenticate(s.tr, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := s.pub.(*ecdsa.PublicKey); ok {
+		if len(sig) == 0 || len(sig)%2 != 0 {
+			return nil, fmt.Errorf("openpgp: ecdsa signature of %d bytes", len(sig))
+		}
+		half := len(sig) / 2
+		return asn1.Marshal(struct{ R, S *big.Int }{
+			new(big.Int).SetBytes(sig[:half]),
+			new(big.Int).SetBytes(sig[half:]),
+		})
+	}
+	return sig, nil
+}
+
+// digestInfoPrefixes are the DER encoded DigestInfo headers preceding the
+// digest in PKCS #1 v1.5 signatures.
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
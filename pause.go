@@ -0,0 +1,26 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+// Pause makes Run stop reacting to cards until Resume, e.g. while a kiosk
+// shows the result of the last tap. Run keeps monitoring the readers:
+// cards presented while paused are released without calling handlers, so
+// they are not handled on Resume either. The backend, reader selection and
+// handlers in flight are not affected.
+func (sdk *SDK) Pause() {
+	if !sdk.paused.Swap(true) {
+		sdk.logger.Info("card monitoring paused")
+	}
+}
+
+// Resume makes Run handle cards again after Pause.
+func (sdk *SDK) Resume() {
+	if sdk.paused.Swap(false) {
+		sdk.logger.Info("card monitoring resumed")
+	}
+}
+
+// Paused reports whether Run ignores cards.
+func (sdk *SDK) Paused() bool { return sdk.paused.Load() }
@@ -0,0 +1,51 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+func TestPauseResume(t *testing.T) {
+	b := &fakeBackend{reader: cardreader.Reader{Name: "virtual"}, card: &memCard{}}
+	var calls atomic.Int32
+	sdk := New(WithBackend(b), WithCardHandler(func(context.Context, cardreader.Event, transport.Card) error {
+		calls.Add(1)
+		return nil
+	}))
+
+	run := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if err := sdk.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+
+	sdk.Pause()
+	if !sdk.Paused() {
+		t.Fatal("Paused() = false after Pause()")
+	}
+	run()
+	if n := calls.Load(); n != 0 || !b.card.disconnected {
+		t.Errorf("while paused: handler called %d times, card disconnected %v", n, b.card.disconnected)
+	}
+
+	sdk.Resume()
+	if sdk.Paused() {
+		t.Fatal("Paused() = true after Resume()")
+	}
+	run()
+	if calls.Load() == 0 {
+		t.Error("handler not called after Resume()")
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Attr identifies a reader attribute (SCARD_ATTR_*), the attribute class in
+// the upper 16 bits and the tag in the lower 16 bits.
+type Attr uint32
+
+const (
+	AttrVendorName          Attr = 0x00010100
+	AttrVendorIFDType       Attr = 0x00010101
+	AttrVendorIFDVersion    Attr = 0x00010102
+	AttrVendorIFDSerialNo   Attr = 0x00010103
+	AttrChannelID           Attr = 0x00020110
+	AttrAsyncProtocolTypes  Attr = 0x00030120
+	AttrDefaultClk          Attr = 0x00030121
+	AttrMaxClk              Attr = 0x00030122
+	AttrDefaultDataRate     Attr = 0x00030123
+	AttrMaxDataRate         Attr = 0x00030124
+	AttrMaxIFSD             Attr = 0x00030125
+	AttrPowerMgmtSupport    Attr = 0x00040131
+	AttrCharacteristics     Attr = 0x00060150
+	AttrCurrentProtocolType Attr = 0x00080201
+	AttrCurrentClk          Attr = 0x00080202
+	AttrCurrentIFSC         Attr = 0x00080207
+	AttrCurrentIFSD         Attr = 0x00080208
+	AttrICCPresence         Attr = 0x00090300
+	AttrICCInterfaceStatus  Attr = 0x00090301
+	AttrATRString           Attr = 0x00090303
+	AttrICCTypePerATR       Attr = 0x00090304
+	AttrDeviceFriendlyName  Attr = 0x7FFF0003
+	AttrDeviceSystemName    Attr = 0x7FFF0004
+)
+
+var attrNames = map[Attr]string{
+	AttrVendorName:          "SCARD_ATTR_VENDOR_NAME",
+	AttrVendorIFDType:       "SCARD_ATTR_VENDOR_IFD_TYPE",
+	AttrVendorIFDVersion:    "SCARD_ATTR_VENDOR_IFD_VERSION",
+	AttrVendorIFDSerialNo:   "SCARD_ATTR_VENDOR_IFD_SERIAL_NO",
+	AttrChannelID:           "SCARD_ATTR_CHANNEL_ID",
+	AttrAsyncProtocolTypes:  "SCARD_ATTR_ASYNC_PROTOCOL_TYPES",
+	AttrDefaultClk:          "SCARD_ATTR_DEFAULT_CLK",
+	AttrMaxClk:              "SCARD_ATTR_MAX_CLK",
+	AttrDefaultDataRate:     "SCARD_ATTR_DEFAULT_DATA_RATE",
+	AttrMaxDataRate:         "SCARD_ATTR_MAX_DATA_RATE",
+	AttrMaxIFSD:             "SCARD_ATTR_MAX_IFSD",
+	AttrPowerMgmtSupport:    "SCARD_ATTR_POWER_MGMT_SUPPORT",
+	AttrCharacteristics:     "SCARD_ATTR_CHARACTERISTICS",
+	AttrCurrentProtocolType: "SCARD_ATTR_CURRENT_PROTOCOL_TYPE",
+	AttrCurrentClk:          "SCARD_ATTR_CURRENT_CLK",
+	AttrCurrentIFSC:         "SCARD_ATTR_CURRENT_IFSC",
+	AttrCurrentIFSD:         "SCARD_ATTR_CURRENT_IFSD",
+	AttrICCPresence:         "SCARD_ATTR_ICC_PRESENCE",
+	AttrICCInterfaceStatus:  "SCARD_ATTR_ICC_INTERFACE_STATUS",
+	AttrATRString:           "SCARD_ATTR_ATR_STRING",
+	AttrICCTypePerATR:       "SCARD_ATTR_ICC_TYPE_PER_ATR",
+	AttrDeviceFriendlyName:  "SCARD_ATTR_DEVICE_FRIENDLY_NAME",
+	AttrDeviceSystemName:    "SCARD_ATTR_DEVICE_SYSTEM_NAME",
+}
+
+// String returns the PC/SC name of the attribute, or its value in hex when
+// unknown.
+func (a Attr) String() string {
+	if name, ok := attrNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%08X", uint32(a))
+}
+
+// GetAttrib reads a reader attribute (SCardGetAttrib). Readers do not
+// support all attributes; those missing fail with SCARD_E_UNSUPPORTED_FEATURE
+// or SCARD_E_NOT_TRANSACTED depending on the driver.
+func (c *Card) GetAttrib(id Attr) ([]byte, error) { return nil, nil }
+
+// SetAttrib sets a reader attribute (SCardSetAttrib). Few drivers allow
+// setting any attribute.
+func (c *Card) SetAttrib(id Attr, value []byte) error { return nil }
+
+// AttrString decodes a string attribute, dropping the NUL terminator and
+// anything after it.
+func AttrString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// AttrUint32 decodes a DWORD attribute, which drivers report in host byte
+// order, i.e. little endian on all supported platforms.
+func AttrUint32(b []byte) (uint32, error) {
+	if len(b) != 4 {
+		return 0, fmt.Errorf("dword attribute of %d bytes", len(b))
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+// IFDVersion is the version of a reader, its firmware or driver depending
+// on the driver.
+type IFDVersion struct {
+	Major, Minor uint8
+	Build        uint16
+}
+
+// String returns the version as "major.minor.build".
+func (v IFDVersion) String() string { return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Build) }
+
+// ParseIFDVersion decodes the value of AttrVendorIFDVersion, a DWORD of the
+// form 0xMMmmbbbb.
+func ParseIFDVersion(b []byte) (IFDVersion, error) {
+	v, err := AttrUint32(b)
+	if err != nil {
+		return IFDVersion{}, err
+	}
+	return IFDVersion{Major: uint8(v >> 24), Minor: uint8(v >> 16), Build: uint16(v)}, nil
+}
+
+// ICCPresence is the value of AttrICCPresence.
+type ICCPresence uint8
+
+const (
+	ICCNotPresent  ICCPresence = 0 // No card in the reader.
+	ICCPresent     ICCPresence = 1 // Card present but not swallowed.
+	ICCSwallowed   ICCPresence = 2 // Card present and swallowed.
+	ICCConfiscated ICCPresence = 4 // Card confiscated.
+)
+
+// String returns a short description of the presence.
+func (p ICCPresence) String() string {
+	switch p {
+	case ICCNotPresent:
+		return "not present"
+	case ICCPresent:
+		return "present"
+	case ICCSwallowed:
+		return "swallowed"
+	case ICCConfiscated:
+		return "confiscated"
+	default:
+		return fmt.Sprintf("presence(%d)", uint8(p))
+	}
+}
+
+// VendorName returns the name of the reader vendor.
+func (c *Card) VendorName() (string, error) {
+	b, err := c.GetAttrib(AttrVendorName)
+	if err != nil {
+		return "", fmt.Errorf("get %s: %w", AttrVendorName, err)
+	}
+	return AttrString(b), nil
+}
+
+// IFDVersion returns the version reported by the reader driver.
+func (c *Card) IFDVersion() (IFDVersion, error) {
+	b, err := c.GetAttrib(AttrVendorIFDVersion)
+	if err != nil {
+		return IFDVersion{}, fmt.Errorf("get %s: %w", AttrVendorIFDVersion, err)
+	}
+	return ParseIFDVersion(b)
+}
+
+// MaxDataRate returns the maximum data rate of the reader in bits per
+// second.
+func (c *Card) MaxDataRate() (uint32, error) {
+	b, err := c.GetAttrib(AttrMaxDataRate)
+	if err != nil {
+		return 0, fmt.Errorf("get %s: %w", AttrMaxDataRate, err)
+	}
+	return AttrUint32(b)
+}
+
+// ICCPresence returns whether a card is present in the reader.
+func (c *Card) ICCPresence() (ICCPresence, error) {
+	b, err := c.GetAttrib(AttrICCPresence)
+	if err != nil {
+		return 0, fmt.Errorf("get %s: %w", AttrICCPresence, err)
+	}
+	if len(b) != 1 {
+		return 0, fmt.Errorf("icc presence attribute of %d bytes", len(b))
+	}
+	return ICCPresence(b[0]), nil
+}
+
+// ATRString returns the ATR of the card as reported by the reader.
+func (c *Card) ATRString() ([]byte, error) {
+	b, err := c.GetAttrib(AttrATRString)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", AttrATRString, err)
+	}
+	return b, nil
+}
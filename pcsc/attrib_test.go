@@ -0,0 +1,36 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import "testing"
+
+func TestAttrDecoding(t *testing.T) {
+	if got := AttrString([]byte("ACS\x00junk")); got != "ACS" {
+		t.Errorf("AttrString() = %q", got)
+	}
+	if got := AttrString([]byte("Identiv")); got != "Identiv" {
+		t.Errorf("AttrString() without terminator = %q", got)
+	}
+	v, err := ParseIFDVersion([]byte{0x0E, 0x02, 0x07, 0x02})
+	if err != nil || v != (IFDVersion{Major: 2, Minor: 7, Build: 0x020E}) || v.String() != "2.7.526" {
+		t.Errorf("ParseIFDVersion() = %v, %v", v, err)
+	}
+	if _, err := AttrUint32([]byte{1, 2}); err == nil {
+		t.Error("AttrUint32() accepted 2 bytes")
+	}
+	tests := []struct {
+		a    Attr
+		want string
+	}{
+		{AttrVendorName, "SCARD_ATTR_VENDOR_NAME"},
+		{AttrMaxDataRate, "SCARD_ATTR_MAX_DATA_RATE"},
+		{Attr(0x00070001), "0x00070001"},
+	}
+	for _, tt := range tests {
+		if got := tt.a.String(); got != tt.want {
+			t.Errorf("Attr(%#x).String() = %q, want %q", uint32(tt.a), got, tt.want)
+		}
+	}
+}
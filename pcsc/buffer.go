@@ -0,0 +1,109 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// TLVProperty is a reader property reported by FeatureGetTLVProperties
+// (PC/SC Part 10, 2.6.14).
+type TLVProperty uint8
+
+const (
+	PropLCDLayout                TLVProperty = 0x01
+	PropEntryValidationCondition TLVProperty = 0x02
+	PropTimeOut2                 TLVProperty = 0x03
+	PropLCDMaxCharacters         TLVProperty = 0x04
+	PropLCDMaxLines              TLVProperty = 0x05
+	PropMinPINSize               TLVProperty = 0x06
+	PropMaxPINSize               TLVProperty = 0x07
+	PropFirmwareID               TLVProperty = 0x08
+	PropPPDUSupport              TLVProperty = 0x09
+	PropMaxAPDUDataSize          TLVProperty = 0x0A
+	PropVendorID                 TLVProperty = 0x0B
+	PropProductID                TLVProperty = 0x0C
+)
+
+// TLVProperties are the values of reader properties, integers encoded in
+// little endian.
+type TLVProperties map[TLVProperty][]byte
+
+// Uint returns the integer value of the property p.
+func (props TLVProperties) Uint(p TLVProperty) (uint32, bool) {
+	v, ok := props[p]
+	if !ok || len(v) > 4 {
+		return 0, false
+	}
+	var b [4]byte
+	copy(b[:], v)
+	return binary.LittleEndian.Uint32(b[:]), true
+}
+
+// ParseTLVProperties parses the response to FeatureGetTLVProperties.
+func ParseTLVProperties(b []byte) (TLVProperties, error) {
+	props := make(TLVProperties)
+	for len(b) > 0 {
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return nil, fmt.Errorf("malformed tlv property")
+		}
+		props[TLVProperty(b[0])] = b[2 : 2+int(b[1])]
+		b = b[2+int(b[1]):]
+	}
+	return props, nil
+}
+
+// TLVProperties returns the properties of the reader, or nil when it does
+// not support FeatureGetTLVProperties.
+func (c *Card) TLVProperties() (TLVProperties, error) {
+	features, err := c.Features()
+	if err != nil {
+		return nil, err
+	}
+	code, ok := features[FeatureGetTLVProperties]
+	if !ok {
+		return nil, nil
+	}
+	resp, err := c.Control(code, nil)
+	if err != nil {
+		return nil, fmt.Errorf("get tlv properties: %w", err)
+	}
+	return ParseTLVProperties(resp)
+}
+
+// BufferSizes are the largest exchanges a reader handles.
+type BufferSizes struct {
+	// MaxCommandData is the largest data field of a command APDU. It is 255
+	// for readers handling only short APDUs or not reporting the size.
+	MaxCommandData int
+	// MaxIFSD is the largest T=1 information field the reader receives from
+	// the card, zero when unknown.
+	MaxIFSD int
+}
+
+// Extended reports whether the reader handles extended length APDUs.
+func (s BufferSizes) Extended() bool { return s.MaxCommandData > 255 }
+
+// BufferSizes queries the buffer sizes of the reader from its TLV properties
+// (dwMaxAPDUDataSize) and AttrMaxIFSD. Sizes the reader does not report
+// take their defaults, so large commands can be split to fit, e.g. with
+// apdu.Chaining(sizes.MaxCommandData).
+func (c *Card) BufferSizes() (BufferSizes, error) {
+	sizes := BufferSizes{MaxCommandData: 255}
+	props, err := c.TLVProperties()
+	if err != nil {
+		return sizes, err
+	}
+	if n, ok := props.Uint(PropMaxAPDUDataSize); ok && n > 255 {
+		sizes.MaxCommandData = int(min(n, 65535))
+	}
+	if b, err := c.GetAttrib(AttrMaxIFSD); err == nil {
+		if n, err := AttrUint32(b); err == nil {
+			sizes.MaxIFSD = int(n)
+		}
+	}
+	return sizes, nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestParseTLVProperties(t *testing.T) {
+	tests := []struct {
+		in      string
+		prop    TLVProperty
+		want    uint32
+		wantErr bool
+	}{
+		{"0A04000001000B02E60870020102", PropMaxAPDUDataSize, 0x10000, false},
+		{"0A04000001000B02E60870020102", PropVendorID, 0x08E6, false},
+		{"0A04000001000B02E60870020102", PropPPDUSupport, 0, false},
+		{"0A0400000100", PropMinPINSize, 0, false},
+		{"0A040000", 0, 0, true},
+		{"0A", 0, 0, true},
+	}
+	for _, tt := range tests {
+		b, _ := hex.DecodeString(tt.in)
+		props, err := ParseTLVProperties(b)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTLVProperties(%s) error = %v", tt.in, err)
+			continue
+		}
+		if got, _ := props.Uint(tt.prop); got != tt.want {
+			t.Errorf("ParseTLVProperties(%s).Uint(%#x) = %#x, want %#x", tt.in, tt.prop, got, tt.want)
+		}
+	}
+}
+
+func FuzzParseTLVProperties(f *testing.F) {
+	b, _ := hex.DecodeString("0A04000001000B02E60870020102")
+	f.Add(b)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		props, err := ParseTLVProperties(b)
+		if err != nil {
+			return
+		}
+		for p, v := range props {
+			if len(v) > len(b) {
+				t.Fatalf("ParseTLVProperties(% X) property %d = % X", b, p, v)
+			}
+			props.Uint(p)
+		}
+	})
+}
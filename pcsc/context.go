@@ -0,0 +1,166 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Infinite is the timeout value waiting without limit (INFINITE).
+const Infinite time.Duration = -1
+
+// State is a set of SCARD_STATE_* reader state flags.
+type State uint32
+
+const (
+	StateUnaware     State = 0x0000 // SCARD_STATE_UNAWARE
+	StateIgnore      State = 0x0001 // SCARD_STATE_IGNORE
+	StateChanged     State = 0x0002 // SCARD_STATE_CHANGED
+	StateUnknown     State = 0x0004 // SCARD_STATE_UNKNOWN
+	StateUnavailable State = 0x0008 // SCARD_STATE_UNAVAILABLE
+	StateEmpty       State = 0x0010 // SCARD_STATE_EMPTY
+	StatePresent     State = 0x0020 // SCARD_STATE_PRESENT
+	StateATRMatch    State = 0x0040 // SCARD_STATE_ATRMATCH
+	StateExclusive   State = 0x0080 // SCARD_STATE_EXCLUSIVE
+	StateInUse       State = 0x0100 // SCARD_STATE_INUSE
+	StateMute        State = 0x0200 // SCARD_STATE_MUTE
+	StateUnpowered   State = 0x0400 // SCARD_STATE_UNPOWERED
+)
+
+var stateNames = []struct {
+	flag State
+	name string
+}{
+	{StateIgnore, "IGNORE"},
+	{StateChanged, "CHANGED"},
+	{StateUnknown, "UNKNOWN"},
+	{StateUnavailable, "UNAVAILABLE"},
+	{StateEmpty, "EMPTY"},
+	{StatePresent, "PRESENT"},
+	{StateATRMatch, "ATRMATCH"},
+	{StateExclusive, "EXCLUSIVE"},
+	{StateInUse, "INUSE"},
+	{StateMute, "MUTE"},
+	{StateUnpowered, "UNPOWERED"},
+}
+
+// String returns the names of the flags set in s joined by "|", e.g.
+// "PRESENT|INUSE", or "UNAWARE" when none is set. The event counter is
+// omitted.
+func (s State) String() string {
+	var b strings.Builder
+	for _, n := range stateNames {
+		if s&n.flag != 0 {
+			if b.Len() > 0 {
+				b.WriteByte('|')
+			}
+			b.WriteString(n.name)
+		}
+	}
+	if b.Len() == 0 {
+		return "UNAWARE"
+	}
+	return b.String()
+}
+
+// Flags returns the state flags without the event counter.
+func (s State) Flags() State { return s & 0xFFFF }
+
+// EventCount returns the number of card insertions and removals on the
+// reader, reported by the resource manager in the upper 16 bits of the
+// event state.
+func (s State) EventCount() int { return int(s >> 16) }
+
+// ReaderState is the state of a reader as tracked by GetStatusChange
+// (SCARD_READERSTATE).
+type ReaderState struct {
+	Reader       string // Name of the reader.
+	CurrentState State  // State known to the caller.
+	EventState   State  // State reported by the resource manager.
+	ATR          []byte // ATR of the card in the reader.
+}
+
+// Context is a PC/SC resource manager context (SCARDCONTEXT).
+type Context struct {
+	// Fields holding the context handle.
+
+	// mu guards released. CancelPending holds it for reading, Release for
+	// writing, so a cancel never reaches a released handle.
+	mu       sync.RWMutex
+	released bool
+}
+
+// EstablishContext establishes a new PC/SC resource manager context.
+func EstablishContext() (*Context, error) { return &Context{}, nil }
+
+// Release releases the context and all resources allocated with it.
+// Releasing a context twice returns an error wrapping SCardEInvalidHandle.
+func (c *Context) Release() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.released {
+		return NewError("SCardReleaseContext", SCardEInvalidHandle)
+	}
+	c.released = true
+	return nil
+}
+
+// CancelPending wakes every blocking call pending on the context, such as a
+// GetStatusChange blocked in another goroutine (SCardCancel). The woken calls
+// return an error wrapping ErrCancelled. CancelPending is safe to call
+// concurrently with any other method of the context and from any number of
+// goroutines. When no call is pending it has no effect; after Release it
+// returns an error wrapping SCardEInvalidHandle.
+func (c *Context) CancelPending() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.released {
+		return NewError("SCardCancel", SCardEInvalidHandle)
+	}
+	return nil
+}
+
+// GetStatusChange blocks until the state of one of the readers differs from
+// its CurrentState or the timeout elapses (SCardGetStatusChange). A timeout
+// is reported with an error wrapping ErrTimeout.
+func (c *Context) GetStatusChange(timeout time.Duration, states []ReaderState) error {
+	c.mu.RLock()
+	released := c.released
+	c.mu.RUnlock()
+	if released {
+		return NewError("SCardGetStatusChange", SCardEInvalidHandle)
+	}
+	return nil
+}
+
+// WaitStatusChange waits for a change in the state of one of the readers
+// like GetStatusChange, but honours ctx. GetStatusChange is called with a
+// finite poll timeout and is cancelled with CancelPending as soon as ctx is done,
+// so the wait ends promptly on cancellation and returns ctx.Err().
+func (c *Context) WaitStatusChange(ctx context.Context, states []ReaderState, poll time.Duration) error {
+	stop := context.AfterFunc(ctx, func() { _ = c.CancelPending() })
+	defer stop()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := c.GetStatusChange(poll, states)
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, ErrTimeout):
+			continue
+		case errors.Is(err, ErrCancelled) && ctx.Err() != nil:
+			return ctx.Err()
+		default:
+			return err
+		}
+	}
+}
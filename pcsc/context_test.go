@@ -0,0 +1,98 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestContextCancelPendingConcurrent(t *testing.T) {
+	hctx, err := EstablishContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = hctx.CancelPending()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			states := []ReaderState{{Reader: "reader 00"}}
+			for j := 0; j < 100; j++ {
+				_ = hctx.WaitStatusChange(ctx, states, 10*time.Millisecond)
+			}
+		}()
+	}
+	cancel()
+	wg.Wait()
+
+	if err := hctx.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	var perr *Error
+	if err := hctx.CancelPending(); !errors.As(err, &perr) || perr.Code != SCardEInvalidHandle {
+		t.Errorf("CancelPending() after Release error = %v, want SCARD_E_INVALID_HANDLE", err)
+	}
+	if err := hctx.Release(); err == nil {
+		t.Error("second Release() succeeded")
+	}
+}
+
+func TestContextCancelPendingDuringRelease(t *testing.T) {
+	hctx, err := EstablishContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			if err := hctx.CancelPending(); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		_ = hctx.Release()
+	}()
+	wg.Wait()
+}
+
+func TestWaitStatusChangeCancelled(t *testing.T) {
+	hctx, err := EstablishContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hctx.Release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := hctx.WaitStatusChange(ctx, nil, time.Second); !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitStatusChange() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestStateEventCount(t *testing.T) {
+	s := State(0x00050122)
+	if got := s.EventCount(); got != 5 {
+		t.Errorf("EventCount() = %d, want 5", got)
+	}
+	if got := s.Flags(); got != StateChanged|StatePresent|StateInUse {
+		t.Errorf("Flags() = %#x, want %#x", got, StateChanged|StatePresent|StateInUse)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import "runtime"
+
+// ShareMode is how a connection shares its reader with other applications
+// (dwShareMode).
+type ShareMode uint32
+
+const (
+	ShareExclusive ShareMode = 1 // SCARD_SHARE_EXCLUSIVE, no other connection.
+	ShareShared    ShareMode = 2 // SCARD_SHARE_SHARED, the default.
+	ShareDirect    ShareMode = 3 // SCARD_SHARE_DIRECT, reader control without a card.
+)
+
+// String returns the name of the share mode.
+func (m ShareMode) String() string {
+	switch m {
+	case ShareExclusive:
+		return "exclusive"
+	case ShareShared:
+		return "shared"
+	case ShareDirect:
+		return "direct"
+	default:
+		return "undefined"
+	}
+}
+
+// EscapeCode returns the control code of CCID escape commands, the vendor
+// commands of a reader: SCARD_CTL_CODE(1) with pcsc-lite and
+// SCARD_CTL_CODE(3500) on Windows. Windows drivers accept it only once
+// escape commands are enabled in the registry.
+func EscapeCode() uint32 {
+	if runtime.GOOS == "windows" {
+		return CtlCode(3500)
+	}
+	return CtlCode(1)
+}
+
+// ConnectDirect connects to the reader readerName in direct mode, which
+// does not require a card, so the reader can be controlled with Control
+// and Escape, e.g. to set its LEDs or polling configuration before any card
+// arrives. No protocol is negotiated and Transmit is not available.
+func ConnectDirect(readerName string) (*Card, error) {
+	return &Card{reader: readerName, share: ShareDirect}, nil
+}
+
+// ShareMode returns the share mode of the connection.
+func (c *Card) ShareMode() ShareMode {
+	if c.share == 0 {
+		return ShareShared
+	}
+	return c.share
+}
+
+// Escape sends the vendor specific escape command cmd to the reader and
+// returns its response.
+func (c *Card) Escape(cmd []byte) ([]byte, error) { return c.Control(EscapeCode(), cmd) }
@@ -0,0 +1,68 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors classifying PC/SC failures by cause. An *Error whose return code has
+// a known cause wraps one of them, so callers can branch with errors.Is and
+// still retrieve the return code with errors.As.
+var (
+	ErrNoReaders         = errors.New("no readers available")
+	ErrReaderUnavailable = errors.New("reader unavailable")
+	ErrCardRemoved       = errors.New("card removed")
+	ErrCardReset         = errors.New("card reset")
+	ErrProtocolMismatch  = errors.New("protocol mismatch")
+	ErrTimeout           = errors.New("timeout")
+	ErrCancelled         = errors.New("cancelled")
+	ErrSharingViolation  = errors.New("sharing violation")
+)
+
+// Error is a failed PC/SC call together with its return code.
+type Error struct {
+	Op   string     // Name of the failed operation, e.g. "SCardConnect".
+	Code ReturnCode // Return code of the operation.
+}
+
+// NewError returns an *Error for a failed operation, or nil when code is
+// SCardSuccess.
+func NewError(op string, code ReturnCode) error {
+	if code == SCardSuccess {
+		return nil
+	}
+	return &Error{Op: op, Code: code}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", e.Op, e.Code.Message(), e.Code)
+}
+
+// Unwrap returns the error classifying the cause of the return code, or nil
+// when the cause is not classified.
+func (e *Error) Unwrap() error {
+	switch e.Code {
+	case SCardENoReadersAvailable:
+		return ErrNoReaders
+	case SCardEReaderUnavailable, SCardEUnknownReader:
+		return ErrReaderUnavailable
+	case SCardWRemovedCard, SCardENoSmartcard:
+		return ErrCardRemoved
+	case SCardWResetCard:
+		return ErrCardReset
+	case SCardEProtoMismatch:
+		return ErrProtocolMismatch
+	case SCardETimeout:
+		return ErrTimeout
+	case SCardECancelled:
+		return ErrCancelled
+	case SCardESharingViolation:
+		return ErrSharingViolation
+	default:
+		return nil
+	}
+}
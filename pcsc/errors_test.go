@@ -0,0 +1,59 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		code ReturnCode
+		want error
+	}{
+		{SCardENoReadersAvailable, ErrNoReaders},
+		{SCardEReaderUnavailable, ErrReaderUnavailable},
+		{SCardEUnknownReader, ErrReaderUnavailable},
+		{SCardWRemovedCard, ErrCardRemoved},
+		{SCardENoSmartcard, ErrCardRemoved},
+		{SCardWResetCard, ErrCardReset},
+		{SCardEProtoMismatch, ErrProtocolMismatch},
+		{SCardETimeout, ErrTimeout},
+		{SCardECancelled, ErrCancelled},
+		{SCardESharingViolation, ErrSharingViolation},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("0x%08X", uint32(tt.code)), func(t *testing.T) {
+			err := fmt.Errorf("connect: %w", NewError("SCardConnect", tt.code))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.want)
+			}
+			var perr *Error
+			if !errors.As(err, &perr) || perr.Code != tt.code {
+				t.Errorf("errors.As() code = %v, want 0x%08X", perr, uint32(tt.code))
+			}
+		})
+	}
+
+	if err := NewError("SCardConnect", SCardSuccess); err != nil {
+		t.Errorf("NewError(success) = %v, want nil", err)
+	}
+}
+
+func TestReturnCodeString(t *testing.T) {
+	if got := SCardWResetCard.String(); got != "SCARD_W_RESET_CARD" {
+		t.Errorf("String() = %q, want %q", got, "SCARD_W_RESET_CARD")
+	}
+	if got := ReturnCode(0x12345678).String(); got != "0x12345678" {
+		t.Errorf("String() = %q, want %q", got, "0x12345678")
+	}
+	err := SCardETimeout.Err("SCardGetStatusChange")
+	if got, want := err.Error(), "SCardGetStatusChange: command timeout (SCARD_E_TIMEOUT)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
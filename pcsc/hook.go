@@ -0,0 +1,48 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+// TransmitHook observes the exchanges of a card. It is called with each
+// command before it is sent and returns the function called with the
+// response or error of the exchange, so the exchange can be timed and
+// traced.
+type TransmitHook func(cmd []byte) func(resp []byte, err error)
+
+// SetTransmitHook makes h observe every exchange of the card, including
+// those of TransmitContext, TransmitReconnect and transactions, and returns
+// the hook it replaces. A nil h removes the hook. It is safe to call while
+// exchanges run.
+func (c *Card) SetTransmitHook(h TransmitHook) TransmitHook {
+	var prev *TransmitHook
+	if h == nil {
+		prev = c.hook.Swap(nil)
+	} else {
+		prev = c.hook.Swap(&h)
+	}
+	if prev == nil {
+		return nil
+	}
+	return *prev
+}
+
+// TransmitHook returns the hook observing the exchanges of the card, nil
+// when there is none.
+func (c *Card) TransmitHook() TransmitHook {
+	if h := c.hook.Load(); h != nil {
+		return *h
+	}
+	return nil
+}
+
+// observe reports the exchange of cmd to the hook of the card, if any, and
+// returns the function ending it.
+func (c *Card) observe(cmd []byte) func(resp []byte, err error) {
+	if h := c.TransmitHook(); h != nil {
+		if done := h(cmd); done != nil {
+			return done
+		}
+	}
+	return func([]byte, error) {}
+}
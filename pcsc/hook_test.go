@@ -0,0 +1,35 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSetTransmitHook(t *testing.T) {
+	c := &Card{}
+	var cmds [][]byte
+	done := 0
+	hook := func(cmd []byte) func([]byte, error) {
+		cmds = append(cmds, cmd)
+		return func([]byte, error) { done++ }
+	}
+	if prev := c.SetTransmitHook(hook); prev != nil {
+		t.Error("SetTransmitHook() returned a hook of a new card")
+	}
+	if _, err := c.TransmitReconnect([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00}, ReconnectPolicy{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 1 || !bytes.Equal(cmds[0], []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}) || done != 1 {
+		t.Errorf("hook saw %X, %d done", cmds, done)
+	}
+	if prev := c.SetTransmitHook(nil); prev == nil || c.TransmitHook() != nil {
+		t.Error("SetTransmitHook(nil) did not remove the hook")
+	}
+	if _, err := c.Transmit([]byte{0x00}); err != nil || len(cmds) != 1 {
+		t.Errorf("removed hook saw %d commands", len(cmds))
+	}
+}
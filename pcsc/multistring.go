@@ -0,0 +1,31 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import "strings"
+
+// ParseMultiString decodes a multi-string as returned by SCardListReaders
+// and SCardListReaderGroups: NUL terminated strings ending with an empty
+// string. Decoding stops at the first empty string or the end of b, so a
+// missing final terminator is tolerated. Invalid UTF-8 is replaced with
+// U+FFFD.
+func ParseMultiString(b []byte) []string {
+	var out []string
+	for len(b) > 0 {
+		i := 0
+		for i < len(b) && b[i] != 0 {
+			i++
+		}
+		if i == 0 {
+			break
+		}
+		out = append(out, strings.ToValidUTF8(string(b[:i]), "�"))
+		if i == len(b) {
+			break
+		}
+		b = b[i+1:]
+	}
+	return out
+}
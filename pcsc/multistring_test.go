@@ -0,0 +1,45 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseMultiString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"\x00", nil},
+		{"\x00\x00", nil},
+		{"ACS ACR122U 00 00\x00\x00", []string{"ACS ACR122U 00 00"}},
+		{"a\x00b\x00\x00", []string{"a", "b"}},
+		{"a\x00b", []string{"a", "b"}},
+		{"a\x00\x00b\x00\x00", []string{"a"}},
+		{"\xFFa\x00\x00", []string{"�a"}},
+	}
+	for _, tt := range tests {
+		if got := ParseMultiString([]byte(tt.in)); !slices.Equal(got, tt.want) {
+			t.Errorf("ParseMultiString(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func FuzzParseMultiString(f *testing.F) {
+	f.Add([]byte("a\x00b\x00\x00"))
+	f.Add([]byte("reader\x00"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, s := range ParseMultiString(b) {
+			if s == "" || strings.IndexByte(s, 0) >= 0 || !utf8.ValidString(s) {
+				t.Fatalf("ParseMultiString(%q) returned %q", b, s)
+			}
+		}
+	})
+}
@@ -0,0 +1,242 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
+// CtlCode returns the control code of a reader IOCTL (SCARD_CTL_CODE),
+// which differs between pcsc-lite and Windows.
+func CtlCode(code uint32) uint32 {
+	if runtime.GOOS == "windows" {
+		return 0x00310000 | code<<2
+	}
+	return 0x42000000 + code
+}
+
+// Control sends a control command to the reader (SCardControl) and returns
+// its response.
+func (c *Card) Control(code uint32, in []byte) ([]byte, error) { return nil, nil }
+
+// Feature is a PC/SC Part 10 reader feature tag.
+type Feature uint8
+
+const (
+	FeatureVerifyPINStart    Feature = 0x01
+	FeatureVerifyPINFinish   Feature = 0x02
+	FeatureModifyPINStart    Feature = 0x03
+	FeatureModifyPINFinish   Feature = 0x04
+	FeatureGetKeyPressed     Feature = 0x05
+	FeatureVerifyPINDirect   Feature = 0x06
+	FeatureModifyPINDirect   Feature = 0x07
+	FeatureMCTReaderDirect   Feature = 0x08
+	FeatureMCTUniversal      Feature = 0x09
+	FeatureIFDPINProperties  Feature = 0x0A
+	FeatureAbort             Feature = 0x0B
+	FeatureSetSPEMessage     Feature = 0x0C
+	FeatureVerifyPINDirectAP Feature = 0x0D
+	FeatureModifyPINDirectAP Feature = 0x0E
+	FeatureWriteDisplay      Feature = 0x0F
+	FeatureGetKey            Feature = 0x10
+	FeatureIFDDisplayProps   Feature = 0x11
+	FeatureGetTLVProperties  Feature = 0x12
+	FeatureCCIDESCCommand    Feature = 0x13
+	FeatureExecutePACE       Feature = 0x20
+)
+
+// ErrPinpadUnsupported is returned when the reader lacks the pinpad feature
+// an operation requires.
+var ErrPinpadUnsupported = errors.New("reader does not support pin entry")
+
+// Errors reported by the reader as status words when PIN entry ends without
+// a PIN.
+var (
+	ErrPINEntryTimeout   = errors.New("pin entry timed out")
+	ErrPINEntryCancelled = errors.New("pin entry cancelled")
+)
+
+// ParseFeatures parses the TLV response to CM_IOCTL_GET_FEATURE_REQUEST
+// into the control codes of the features supported by the reader.
+func ParseFeatures(b []byte) (map[Feature]uint32, error) {
+	features := make(map[Feature]uint32)
+	for len(b) > 0 {
+		if len(b) < 2 || int(b[1]) != 4 || len(b) < 6 {
+			return nil, fmt.Errorf("malformed feature tlv")
+		}
+		features[Feature(b[0])] = binary.BigEndian.Uint32(b[2:6])
+		b = b[6:]
+	}
+	return features, nil
+}
+
+// Features returns the PC/SC Part 10 features of the reader with their
+// control codes.
+func (c *Card) Features() (map[Feature]uint32, error) {
+	resp, err := c.Control(CtlCode(3400), nil)
+	if err != nil {
+		return nil, fmt.Errorf("get feature request: %w", err)
+	}
+	return ParseFeatures(resp)
+}
+
+// PINFormat is the encoding of a PIN in the PIN block.
+type PINFormat uint8
+
+const (
+	PINFormatBinary PINFormat = iota
+	PINFormatBCD
+	PINFormatASCII
+)
+
+// PINSpec describes how the reader inserts a PIN entered on its pinpad into
+// the command APDU sent to the card. Positions are in bytes.
+type PINSpec struct {
+	APDU           []byte    // Command APDU including the PIN block, e.g. VERIFY.
+	Format         PINFormat // Encoding of the PIN digits.
+	RightJustify   bool      // Right justify the PIN in the block.
+	Position       int       // Offset of the PIN within the APDU data.
+	BlockSize      int       // Size of the PIN block.
+	LengthBits     int       // Size of the PIN length field in bits, zero when absent.
+	LengthPosition int       // Offset of the PIN length field within the APDU data.
+	MinLen, MaxLen int       // Accepted number of PIN digits.
+	// EntryValidation is the bitmask of conditions ending the entry: 0x01
+	// maximum size reached, 0x02 validation key pressed, 0x04 timeout. Zero
+	// selects the validation key.
+	EntryValidation uint8
+	Timeout         time.Duration // Timeout of the entry, zero for the reader default.
+	LangID          uint16        // Language of the reader messages.
+}
+
+func (s PINSpec) header() ([]byte, error) {
+	if s.Position > 0x0F || s.LengthPosition > 0x0F || s.BlockSize > 0x0F || s.LengthBits > 0x0F {
+		return nil, fmt.Errorf("pin block layout out of range")
+	}
+	if s.MinLen < 0 || s.MaxLen > 0xFF || s.MinLen > s.MaxLen {
+		return nil, fmt.Errorf("invalid pin length range %d-%d", s.MinLen, s.MaxLen)
+	}
+	format := 0x80 | byte(s.Position)<<3 | byte(s.Format)
+	if s.RightJustify {
+		format |= 0x04
+	}
+	return []byte{
+		byte(min(s.Timeout/time.Second, 0xFF)),
+		0x00, // bTimerOut2
+		format,
+		byte(s.LengthBits)<<4 | byte(s.BlockSize),
+		0x10 | byte(s.LengthPosition),
+	}, nil
+}
+
+func (s PINSpec) validation() byte {
+	if s.EntryValidation == 0 {
+		return 0x02
+	}
+	return s.EntryValidation
+}
+
+func appendData(b, apdu []byte) []byte {
+	b = binary.LittleEndian.AppendUint32(append(b, 0x00, 0x00, 0x00), uint32(len(apdu)))
+	return append(b, apdu...)
+}
+
+// VerifyStructure returns the PIN_VERIFY_STRUCTURE of s.
+func (s PINSpec) VerifyStructure() ([]byte, error) {
+	b, err := s.header()
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, byte(s.MaxLen), byte(s.MinLen), s.validation(), 0x01)
+	b = binary.LittleEndian.AppendUint16(b, s.LangID)
+	b = append(b, 0x00) // bMsgIndex
+	return appendData(b, s.APDU), nil
+}
+
+// ModifySpec describes a PIN change entered on the pinpad. The old and new
+// PINs are inserted at their offsets within the APDU data.
+type ModifySpec struct {
+	PINSpec
+	OldOffset, NewOffset int
+	// Confirm requests the new PIN to be entered twice.
+	Confirm bool
+	// NoOldPIN skips entering the current PIN, e.g. for unblocking.
+	NoOldPIN bool
+}
+
+// ModifyStructure returns the PIN_MODIFY_STRUCTURE of s.
+func (s ModifySpec) ModifyStructure() ([]byte, error) {
+	b, err := s.header()
+	if err != nil {
+		return nil, err
+	}
+	var confirm byte
+	if s.Confirm {
+		confirm |= 0x01
+	}
+	if !s.NoOldPIN {
+		confirm |= 0x02
+	}
+	b = append(b, byte(s.OldOffset), byte(s.NewOffset), byte(s.MaxLen), byte(s.MinLen), confirm, s.validation(), 0x03)
+	b = binary.LittleEndian.AppendUint16(b, s.LangID)
+	b = append(b, 0x00, 0x01, 0x02) // bMsgIndex1-3
+	return appendData(b, s.APDU), nil
+}
+
+// VerifyPIN lets the user enter a PIN on the pinpad of the reader, which
+// sends it to the card within spec.APDU without exposing it to the host
+// (FEATURE_VERIFY_PIN_DIRECT). It returns the response APDU of the card.
+func (c *Card) VerifyPIN(spec PINSpec) ([]byte, error) {
+	b, err := spec.VerifyStructure()
+	if err != nil {
+		return nil, err
+	}
+	return c.pinCommand(FeatureVerifyPINDirect, b)
+}
+
+// ModifyPIN lets the user change a PIN on the pinpad of the reader
+// (FEATURE_MODIFY_PIN_DIRECT). It returns the response APDU of the card.
+func (c *Card) ModifyPIN(spec ModifySpec) ([]byte, error) {
+	b, err := spec.ModifyStructure()
+	if err != nil {
+		return nil, err
+	}
+	return c.pinCommand(FeatureModifyPINDirect, b)
+}
+
+func (c *Card) pinCommand(f Feature, structure []byte) ([]byte, error) {
+	features, err := c.Features()
+	if err != nil {
+		return nil, err
+	}
+	code, ok := features[f]
+	if !ok {
+		return nil, ErrPinpadUnsupported
+	}
+	resp, err := c.Control(code, structure)
+	if err != nil {
+		return nil, err
+	}
+	return resp, pinStatus(resp)
+}
+
+// pinStatus translates the status words of a pinpad operation.
+func pinStatus(resp []byte) error {
+	if len(resp) < 2 {
+		return fmt.Errorf("short pin entry response")
+	}
+	switch sw := binary.BigEndian.Uint16(resp[len(resp)-2:]); sw {
+	case 0x6400:
+		return ErrPINEntryTimeout
+	case 0x6401:
+		return ErrPINEntryCancelled
+	}
+	return apdu.CheckStatusFromData(resp)
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseFeatures(t *testing.T) {
+	b, _ := hex.DecodeString("0604423300120704423300130A0442330016")
+	f, err := ParseFeatures(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f[FeatureVerifyPINDirect] != 0x42330012 || f[FeatureModifyPINDirect] != 0x42330013 || len(f) != 3 {
+		t.Errorf("ParseFeatures() = %v", f)
+	}
+	if _, err := ParseFeatures(b[:5]); err == nil {
+		t.Error("ParseFeatures() accepted a truncated tlv")
+	}
+}
+
+func FuzzParseFeatures(f *testing.F) {
+	b, _ := hex.DecodeString("0604423300120704423300130A0442330016")
+	f.Add(b)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		features, err := ParseFeatures(b)
+		if err == nil && len(features) > len(b)/6 {
+			t.Fatalf("ParseFeatures(% X) = %v", b, features)
+		}
+	})
+}
+
+func TestPINStructures(t *testing.T) {
+	spec := PINSpec{
+		APDU:       []byte{0x00, 0x20, 0x00, 0x81, 0x08, 0x20, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		Format:     PINFormatBCD,
+		Position:   1,
+		BlockSize:  7,
+		LengthBits: 4,
+		MinLen:     4,
+		MaxLen:     12,
+		Timeout:    30 * time.Second,
+		LangID:     0x0409,
+	}
+	verify, err := spec.VerifyStructure()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := hex.DecodeString("1E00894710" + "0C04" + "02" + "01" + "0904" + "00" + "000000" + "0D000000" + "0020008108" + "20FFFFFFFFFFFFFF")
+	if !bytes.Equal(verify, want) {
+		t.Errorf("VerifyStructure() = %X\nwant %X", verify, want)
+	}
+
+	modify, err := ModifySpec{PINSpec: spec, OldOffset: 0, NewOffset: 8, Confirm: true}.ModifyStructure()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modify[5] != 0 || modify[6] != 8 || modify[9] != 0x03 || modify[11] != 0x03 || len(modify) != 24+len(spec.APDU) {
+		t.Errorf("ModifyStructure() = %X", modify)
+	}
+
+	if _, err := (PINSpec{MinLen: 8, MaxLen: 4}).VerifyStructure(); err == nil {
+		t.Error("VerifyStructure() accepted an invalid length range")
+	}
+}
+
+func TestPINStatus(t *testing.T) {
+	if err := pinStatus([]byte{0x64, 0x01}); !errors.Is(err, ErrPINEntryCancelled) {
+		t.Errorf("pinStatus(6401) = %v", err)
+	}
+	if err := pinStatus([]byte{0x90, 0x00}); err != nil {
+		t.Errorf("pinStatus(9000) = %v", err)
+	}
+}
@@ -7,6 +7,13 @@
 // to connect, communicate, and interact with smart cards through readers.
 package pscs
 
+import (
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
 const (
 	// Constants defining card and reader states, error codes, etc.
 	CardAbsent  = "CardAbsent"
@@ -28,13 +35,74 @@ type ReaderInfo struct {
 	// Fields like reader name, connection status, etc.
 }
 
+// Protocol is the transmission protocol negotiated with a card.
+type Protocol uint32
+
+const (
+	ProtocolUndefined Protocol = 0x0000
+	ProtocolT0        Protocol = 0x0001
+	ProtocolT1        Protocol = 0x0002
+	ProtocolRaw       Protocol = 0x0004
+)
+
+// String returns the name of the protocol, e.g. "T=1".
+func (p Protocol) String() string {
+	switch p {
+	case ProtocolT0:
+		return "T=0"
+	case ProtocolT1:
+		return "T=1"
+	case ProtocolRaw:
+		return "raw"
+	default:
+		return "undefined"
+	}
+}
+
 // Card represents a smart card in a PC/SC reader.
 type Card struct {
 	// Fields representing card properties and status.
+	atr      []byte
+	reader   string
+	protocol Protocol
+	share    ShareMode
+
+	// exchange holds a token while a TransmitContext exchange runs.
+	exchangeOnce sync.Once
+	exchange     chan struct{}
+
+	hook atomic.Pointer[TransmitHook] // See SetTransmitHook.
+}
+
+// ATR returns the Answer To Reset reported by the reader on connect.
+func (c *Card) ATR() []byte { return c.atr }
+
+// Reader returns the name of the reader the card is connected through.
+func (c *Card) Reader() string { return c.reader }
+
+// Protocol returns the protocol negotiated on connect.
+func (c *Card) Protocol() Protocol { return c.protocol }
+
+// LogValue implements slog.LogValuer, logging the card as a group of its
+// reader, protocol and ATR.
+func (c *Card) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("reader", c.reader),
+		slog.String("protocol", c.protocol.String()),
+		slog.String("atr", hex.EncodeToString(c.atr)),
+	)
 }
 
 // Transmit sends an APDU command to the card and receives a response.
-func (c *Card) Transmit(apduCommand []byte) ([]byte, error) { return nil, nil }
+func (c *Card) Transmit(apduCommand []byte) ([]byte, error) {
+	done := c.observe(apduCommand)
+	resp, err := c.transmit(apduCommand)
+	done(resp, err)
+	return resp, err
+}
+
+// transmit exchanges an APDU with the card (SCardTransmit).
+func (c *Card) transmit(apduCommand []byte) ([]byte, error) { return nil, nil }
 
 // Disconnect releases the connection with the card.
 func (c *Card) Disconnect() error { return nil }
@@ -0,0 +1,95 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Reconnect re-establishes the connection to the card after it was reset by
+// another application or removed and reinserted (SCardReconnect), applying
+// init to the card.
+func (c *Card) Reconnect(init Disposition) error { return nil }
+
+// ReconnectPolicy configures recovering from exchanges failing because the
+// card was reset or briefly lost mid-transaction.
+type ReconnectPolicy struct {
+	// Attempts is the number of reconnects per failed exchange, zero
+	// disables reconnecting.
+	Attempts int
+	// Init is the disposition applied to the card on reconnect.
+	Init Disposition
+	// Replay reports whether cmd is safe to retransmit after reconnecting.
+	// The card has lost its state, e.g. its selected application and
+	// authentication, so only stateless commands should be replayed. A nil
+	// Replay replays nothing.
+	Replay func(cmd []byte) bool
+	// OnReconnect, when not nil, is called after each successful reconnect.
+	OnReconnect func(cause error)
+}
+
+// ReplayStateless reports whether cmd is a PC/SC pseudo-APDU handled by the
+// reader which does not depend on card state, GET DATA and the READ BINARY
+// of storage cards. It is suitable as ReconnectPolicy.Replay.
+func ReplayStateless(cmd []byte) bool {
+	return len(cmd) >= 2 && cmd[0] == 0xFF && (cmd[1] == 0xCA || cmd[1] == 0xB0)
+}
+
+// ReconnectedError is returned when the connection to the card was
+// re-established after a reset or removal but the failed command was not
+// replayed. The card state was lost, so the caller has to restart its
+// session with the card.
+type ReconnectedError struct {
+	Cmd []byte // Command which failed.
+	Err error  // Error the command failed with.
+}
+
+func (e *ReconnectedError) Error() string {
+	return fmt.Sprintf("reconnected after %v, command not replayed", e.Err)
+}
+
+func (e *ReconnectedError) Unwrap() error { return e.Err }
+
+// TransmitReconnect transmits cmd like Transmit. When the exchange fails with
+// an error wrapping ErrCardReset or ErrCardRemoved, the card is reconnected
+// following p and cmd is replayed if p allows it; otherwise a
+// *ReconnectedError is returned.
+func (c *Card) TransmitReconnect(cmd []byte, p ReconnectPolicy) ([]byte, error) {
+	return p.transmit(cmd, c.Transmit, c.Reconnect)
+}
+
+// Reconnector is a card which can be reconnected after a reset or removal,
+// such as Card and the cards of backends wrapping it.
+type Reconnector interface {
+	Transmit(cmd []byte) ([]byte, error)
+	Reconnect(init Disposition) error
+}
+
+// Transmit transmits cmd to c following p like Card.TransmitReconnect, so
+// the cards of other backends recover the same way.
+func (p ReconnectPolicy) Transmit(c Reconnector, cmd []byte) ([]byte, error) {
+	return p.transmit(cmd, c.Transmit, c.Reconnect)
+}
+
+func (p ReconnectPolicy) transmit(cmd []byte, transmit func([]byte) ([]byte, error), reconnect func(Disposition) error) ([]byte, error) {
+	resp, err := transmit(cmd)
+	for attempt := 0; attempt < p.Attempts && err != nil; attempt++ {
+		if !errors.Is(err, ErrCardReset) && !errors.Is(err, ErrCardRemoved) {
+			return nil, err
+		}
+		if rerr := reconnect(p.Init); rerr != nil {
+			return nil, errors.Join(err, fmt.Errorf("reconnect: %w", rerr))
+		}
+		if p.OnReconnect != nil {
+			p.OnReconnect(err)
+		}
+		if p.Replay == nil || !p.Replay(cmd) {
+			return nil, &ReconnectedError{Cmd: cmd, Err: err}
+		}
+		resp, err = transmit(cmd)
+	}
+	return resp, err
+}
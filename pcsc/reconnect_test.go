@@ -0,0 +1,61 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReconnectPolicy(t *testing.T) {
+	reset := NewError("SCardTransmit", SCardWResetCard)
+	readUID := []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}
+	selectApp := []byte{0x00, 0xA4, 0x04, 0x00}
+
+	tests := []struct {
+		name       string
+		cmd        []byte
+		policy     ReconnectPolicy
+		failures   int
+		reconnErr  error
+		wantErr    error
+		wantReconn int
+	}{
+		{"disabled", readUID, ReconnectPolicy{}, 1, nil, ErrCardReset, 0},
+		{"replayed", readUID, ReconnectPolicy{Attempts: 2, Replay: ReplayStateless}, 1, nil, nil, 1},
+		{"attempts exhausted", readUID, ReconnectPolicy{Attempts: 2, Replay: ReplayStateless}, 3, nil, ErrCardReset, 2},
+		{"not replayed", selectApp, ReconnectPolicy{Attempts: 2, Replay: ReplayStateless}, 1, nil, ErrCardReset, 1},
+		{"reconnect fails", readUID, ReconnectPolicy{Attempts: 1, Replay: ReplayStateless}, 1, ErrReaderUnavailable, ErrReaderUnavailable, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures, reconnects := tt.failures, 0
+			tt.policy.OnReconnect = func(error) { reconnects++ }
+			_, err := tt.policy.transmit(tt.cmd,
+				func([]byte) ([]byte, error) {
+					if failures > 0 {
+						failures--
+						return nil, reset
+					}
+					return []byte{0x90, 0x00}, nil
+				},
+				func(Disposition) error { return tt.reconnErr },
+			)
+			if tt.wantErr == nil && err != nil || !errors.Is(err, tt.wantErr) {
+				t.Errorf("transmit() error = %v, want %v", err, tt.wantErr)
+			}
+			if reconnects != tt.wantReconn {
+				t.Errorf("reconnects = %d, want %d", reconnects, tt.wantReconn)
+			}
+		})
+	}
+
+	var rerr *ReconnectedError
+	p := ReconnectPolicy{Attempts: 1}
+	_, err := p.transmit(selectApp, func([]byte) ([]byte, error) { return nil, reset }, func(Disposition) error { return nil })
+	if !errors.As(err, &rerr) || string(rerr.Cmd) != string(selectApp) {
+		t.Errorf("transmit() error = %v, want *ReconnectedError", err)
+	}
+}
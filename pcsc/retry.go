@@ -0,0 +1,70 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultBusyRetryInterval is the default wait between connection attempts
+// to a reader held by another application.
+const DefaultBusyRetryInterval = 100 * time.Millisecond
+
+// BusyRetry configures waiting for a reader held by another application.
+// Short-lived PC/SC consumers frequently hold a reader for a moment, so a
+// connection failing with a sharing violation is retried until MaxWait has
+// elapsed.
+type BusyRetry struct {
+	MaxWait  time.Duration // Maximum total wait, zero disables retrying.
+	Interval time.Duration // Wait between attempts, DefaultBusyRetryInterval when zero.
+	// Backoff multiplies the wait after every attempt when greater than 1,
+	// up to MaxInterval when set.
+	Backoff     float64
+	MaxInterval time.Duration
+}
+
+// ConnectToCardRetry connects like ConnectToCard and retries following r as
+// long as the connection fails with an error wrapping ErrSharingViolation.
+// When MaxWait elapses the last error is returned.
+func ConnectToCardRetry(ctx context.Context, readerName string, r BusyRetry) (*Card, error) {
+	return r.do(ctx, func() (*Card, error) { return ConnectToCard(readerName) })
+}
+
+func (r BusyRetry) do(ctx context.Context, connect func() (*Card, error)) (*Card, error) {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultBusyRetryInterval
+	}
+	deadline := time.Now().Add(r.MaxWait)
+
+	for {
+		card, err := connect()
+		if err == nil || !errors.Is(err, ErrSharingViolation) {
+			return card, err
+		}
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil, err
+		}
+		if wait > interval {
+			wait = interval
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+		if r.Backoff > 1 {
+			interval = time.Duration(float64(interval) * r.Backoff)
+			if r.MaxInterval > 0 && interval > r.MaxInterval {
+				interval = r.MaxInterval
+			}
+		}
+	}
+}
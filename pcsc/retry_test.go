@@ -0,0 +1,65 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBusyRetry(t *testing.T) {
+	busy := NewError("SCardConnect", SCardESharingViolation)
+
+	attempts := 0
+	card, err := BusyRetry{MaxWait: time.Second, Interval: time.Millisecond}.do(context.Background(), func() (*Card, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, busy
+		}
+		return &Card{}, nil
+	})
+	if err != nil || card == nil || attempts != 3 {
+		t.Errorf("do() = %v, %v after %d attempts; want card after 3 attempts", card, err, attempts)
+	}
+
+	attempts = 0
+	_, err = BusyRetry{MaxWait: 20 * time.Millisecond, Interval: 5 * time.Millisecond}.do(context.Background(), func() (*Card, error) {
+		attempts++
+		return nil, busy
+	})
+	if !errors.Is(err, ErrSharingViolation) || attempts < 2 {
+		t.Errorf("do() error = %v after %d attempts; want sharing violation after retries", err, attempts)
+	}
+
+	attempts = 0
+	_, err = BusyRetry{MaxWait: time.Second}.do(context.Background(), func() (*Card, error) {
+		attempts++
+		return nil, NewError("SCardConnect", SCardENoSmartcard)
+	})
+	if !errors.Is(err, ErrCardRemoved) || attempts != 1 {
+		t.Errorf("do() error = %v after %d attempts; want no retry for other errors", err, attempts)
+	}
+
+	var times []time.Time
+	_, err = BusyRetry{MaxWait: 200 * time.Millisecond, Interval: 5 * time.Millisecond, Backoff: 2, MaxInterval: 20 * time.Millisecond}.do(context.Background(), func() (*Card, error) {
+		times = append(times, time.Now())
+		if len(times) == 5 {
+			return &Card{}, nil
+		}
+		return nil, busy
+	})
+	if err != nil || len(times) != 5 {
+		t.Fatalf("do() with backoff error = %v after %d attempts", err, len(times))
+	}
+	// Waits of 5, 10, 20 and 20 ms.
+	if d := times[3].Sub(times[2]); d < 20*time.Millisecond {
+		t.Errorf("third wait = %v, want at least 20ms", d)
+	}
+	if d := times[4].Sub(times[0]); d < 55*time.Millisecond {
+		t.Errorf("total wait = %v, want at least 55ms", d)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import "fmt"
+
+// ReturnCode is a PC/SC return value (SCARD_S_SUCCESS, SCARD_E_* or SCARD_W_*).
+type ReturnCode uint32
+
+const (
+	SCardSuccess                 ReturnCode = 0x00000000 // SCARD_S_SUCCESS
+	SCardFInternalError          ReturnCode = 0x80100001 // SCARD_F_INTERNAL_ERROR
+	SCardECancelled              ReturnCode = 0x80100002 // SCARD_E_CANCELLED
+	SCardEInvalidHandle          ReturnCode = 0x80100003 // SCARD_E_INVALID_HANDLE
+	SCardEInvalidParameter       ReturnCode = 0x80100004 // SCARD_E_INVALID_PARAMETER
+	SCardEInvalidTarget          ReturnCode = 0x80100005 // SCARD_E_INVALID_TARGET
+	SCardENoMemory               ReturnCode = 0x80100006 // SCARD_E_NO_MEMORY
+	SCardFWaitedTooLong          ReturnCode = 0x80100007 // SCARD_F_WAITED_TOO_LONG
+	SCardEInsufficientBuffer     ReturnCode = 0x80100008 // SCARD_E_INSUFFICIENT_BUFFER
+	SCardEUnknownReader          ReturnCode = 0x80100009 // SCARD_E_UNKNOWN_READER
+	SCardETimeout                ReturnCode = 0x8010000A // SCARD_E_TIMEOUT
+	SCardESharingViolation       ReturnCode = 0x8010000B // SCARD_E_SHARING_VIOLATION
+	SCardENoSmartcard            ReturnCode = 0x8010000C // SCARD_E_NO_SMARTCARD
+	SCardEUnknownCard            ReturnCode = 0x8010000D // SCARD_E_UNKNOWN_CARD
+	SCardECantDispose            ReturnCode = 0x8010000E // SCARD_E_CANT_DISPOSE
+	SCardEProtoMismatch          ReturnCode = 0x8010000F // SCARD_E_PROTO_MISMATCH
+	SCardENotReady               ReturnCode = 0x80100010 // SCARD_E_NOT_READY
+	SCardEInvalidValue           ReturnCode = 0x80100011 // SCARD_E_INVALID_VALUE
+	SCardESystemCancelled        ReturnCode = 0x80100012 // SCARD_E_SYSTEM_CANCELLED
+	SCardFCommError              ReturnCode = 0x80100013 // SCARD_F_COMM_ERROR
+	SCardFUnknownError           ReturnCode = 0x80100014 // SCARD_F_UNKNOWN_ERROR
+	SCardEInvalidATR             ReturnCode = 0x80100015 // SCARD_E_INVALID_ATR
+	SCardENotTransacted          ReturnCode = 0x80100016 // SCARD_E_NOT_TRANSACTED
+	SCardEReaderUnavailable      ReturnCode = 0x80100017 // SCARD_E_READER_UNAVAILABLE
+	SCardPShutdown               ReturnCode = 0x80100018 // SCARD_P_SHUTDOWN
+	SCardEPCITooSmall            ReturnCode = 0x80100019 // SCARD_E_PCI_TOO_SMALL
+	SCardEReaderUnsupported      ReturnCode = 0x8010001A // SCARD_E_READER_UNSUPPORTED
+	SCardEDuplicateReader        ReturnCode = 0x8010001B // SCARD_E_DUPLICATE_READER
+	SCardECardUnsupported        ReturnCode = 0x8010001C // SCARD_E_CARD_UNSUPPORTED
+	SCardENoService              ReturnCode = 0x8010001D // SCARD_E_NO_SERVICE
+	SCardEServiceStopped         ReturnCode = 0x8010001E // SCARD_E_SERVICE_STOPPED
+	SCardEUnexpected             ReturnCode = 0x8010001F // SCARD_E_UNEXPECTED
+	SCardEICCInstallation        ReturnCode = 0x80100020 // SCARD_E_ICC_INSTALLATION
+	SCardEICCCreateOrder         ReturnCode = 0x80100021 // SCARD_E_ICC_CREATEORDER
+	SCardEUnsupportedFeature     ReturnCode = 0x80100022 // SCARD_E_UNSUPPORTED_FEATURE
+	SCardEDirNotFound            ReturnCode = 0x80100023 // SCARD_E_DIR_NOT_FOUND
+	SCardEFileNotFound           ReturnCode = 0x80100024 // SCARD_E_FILE_NOT_FOUND
+	SCardENoDir                  ReturnCode = 0x80100025 // SCARD_E_NO_DIR
+	SCardENoFile                 ReturnCode = 0x80100026 // SCARD_E_NO_FILE
+	SCardENoAccess               ReturnCode = 0x80100027 // SCARD_E_NO_ACCESS
+	SCardEWriteTooMany           ReturnCode = 0x80100028 // SCARD_E_WRITE_TOO_MANY
+	SCardEBadSeek                ReturnCode = 0x80100029 // SCARD_E_BAD_SEEK
+	SCardEInvalidCHV             ReturnCode = 0x8010002A // SCARD_E_INVALID_CHV
+	SCardEUnknownResMng          ReturnCode = 0x8010002B // SCARD_E_UNKNOWN_RES_MNG
+	SCardENoSuchCertificate      ReturnCode = 0x8010002C // SCARD_E_NO_SUCH_CERTIFICATE
+	SCardECertificateUnavailable ReturnCode = 0x8010002D // SCARD_E_CERTIFICATE_UNAVAILABLE
+	SCardENoReadersAvailable     ReturnCode = 0x8010002E // SCARD_E_NO_READERS_AVAILABLE
+	SCardECommDataLost           ReturnCode = 0x8010002F // SCARD_E_COMM_DATA_LOST
+	SCardENoKeyContainer         ReturnCode = 0x80100030 // SCARD_E_NO_KEY_CONTAINER
+	SCardEServerTooBusy          ReturnCode = 0x80100031 // SCARD_E_SERVER_TOO_BUSY
+	SCardWUnsupportedCard        ReturnCode = 0x80100065 // SCARD_W_UNSUPPORTED_CARD
+	SCardWUnresponsiveCard       ReturnCode = 0x80100066 // SCARD_W_UNRESPONSIVE_CARD
+	SCardWUnpoweredCard          ReturnCode = 0x80100067 // SCARD_W_UNPOWERED_CARD
+	SCardWResetCard              ReturnCode = 0x80100068 // SCARD_W_RESET_CARD
+	SCardWRemovedCard            ReturnCode = 0x80100069 // SCARD_W_REMOVED_CARD
+	SCardWSecurityViolation      ReturnCode = 0x8010006A // SCARD_W_SECURITY_VIOLATION
+	SCardWWrongCHV               ReturnCode = 0x8010006B // SCARD_W_WRONG_CHV
+	SCardWCHVBlocked             ReturnCode = 0x8010006C // SCARD_W_CHV_BLOCKED
+	SCardWEOF                    ReturnCode = 0x8010006D // SCARD_W_EOF
+	SCardWCancelledByUser        ReturnCode = 0x8010006E // SCARD_W_CANCELLED_BY_USER
+	SCardWCardNotAuthenticated   ReturnCode = 0x8010006F // SCARD_W_CARD_NOT_AUTHENTICATED
+)
+
+// returnCodes maps return codes to their PC/SC name and a human-readable message.
+var returnCodes = map[ReturnCode]struct{ name, msg string }{
+	SCardSuccess:                 {"SCARD_S_SUCCESS", "command successful"},
+	SCardFInternalError:          {"SCARD_F_INTERNAL_ERROR", "internal error"},
+	SCardECancelled:              {"SCARD_E_CANCELLED", "command cancelled"},
+	SCardEInvalidHandle:          {"SCARD_E_INVALID_HANDLE", "invalid handle"},
+	SCardEInvalidParameter:       {"SCARD_E_INVALID_PARAMETER", "invalid parameter given"},
+	SCardEInvalidTarget:          {"SCARD_E_INVALID_TARGET", "invalid target given"},
+	SCardENoMemory:               {"SCARD_E_NO_MEMORY", "not enough memory"},
+	SCardFWaitedTooLong:          {"SCARD_F_WAITED_TOO_LONG", "waited too long"},
+	SCardEInsufficientBuffer:     {"SCARD_E_INSUFFICIENT_BUFFER", "insufficient buffer"},
+	SCardEUnknownReader:          {"SCARD_E_UNKNOWN_READER", "unknown reader specified"},
+	SCardETimeout:                {"SCARD_E_TIMEOUT", "command timeout"},
+	SCardESharingViolation:       {"SCARD_E_SHARING_VIOLATION", "sharing violation"},
+	SCardENoSmartcard:            {"SCARD_E_NO_SMARTCARD", "no smart card inserted"},
+	SCardEUnknownCard:            {"SCARD_E_UNKNOWN_CARD", "unknown card"},
+	SCardECantDispose:            {"SCARD_E_CANT_DISPOSE", "cannot dispose handle"},
+	SCardEProtoMismatch:          {"SCARD_E_PROTO_MISMATCH", "card protocol mismatch"},
+	SCardENotReady:               {"SCARD_E_NOT_READY", "subsystem not ready"},
+	SCardEInvalidValue:           {"SCARD_E_INVALID_VALUE", "invalid value given"},
+	SCardESystemCancelled:        {"SCARD_E_SYSTEM_CANCELLED", "system cancelled"},
+	SCardFCommError:              {"SCARD_F_COMM_ERROR", "rpc transport error"},
+	SCardFUnknownError:           {"SCARD_F_UNKNOWN_ERROR", "unknown error"},
+	SCardEInvalidATR:             {"SCARD_E_INVALID_ATR", "invalid atr"},
+	SCardENotTransacted:          {"SCARD_E_NOT_TRANSACTED", "transaction failed"},
+	SCardEReaderUnavailable:      {"SCARD_E_READER_UNAVAILABLE", "reader is unavailable"},
+	SCardPShutdown:               {"SCARD_P_SHUTDOWN", "operation aborted to allow the server to shut down"},
+	SCardEPCITooSmall:            {"SCARD_E_PCI_TOO_SMALL", "pci struct too small"},
+	SCardEReaderUnsupported:      {"SCARD_E_READER_UNSUPPORTED", "reader is unsupported"},
+	SCardEDuplicateReader:        {"SCARD_E_DUPLICATE_READER", "reader already exists"},
+	SCardECardUnsupported:        {"SCARD_E_CARD_UNSUPPORTED", "card is unsupported"},
+	SCardENoService:              {"SCARD_E_NO_SERVICE", "service not available"},
+	SCardEServiceStopped:         {"SCARD_E_SERVICE_STOPPED", "service was stopped"},
+	SCardEUnexpected:             {"SCARD_E_UNEXPECTED", "unexpected card error"},
+	SCardEICCInstallation:        {"SCARD_E_ICC_INSTALLATION", "cannot find a smart card reader"},
+	SCardEICCCreateOrder:         {"SCARD_E_ICC_CREATEORDER", "requested order of object creation is not supported"},
+	SCardEUnsupportedFeature:     {"SCARD_E_UNSUPPORTED_FEATURE", "feature not supported"},
+	SCardEDirNotFound:            {"SCARD_E_DIR_NOT_FOUND", "directory not found on the card"},
+	SCardEFileNotFound:           {"SCARD_E_FILE_NOT_FOUND", "file not found on the card"},
+	SCardENoDir:                  {"SCARD_E_NO_DIR", "path does not specify a directory"},
+	SCardENoFile:                 {"SCARD_E_NO_FILE", "path does not specify a file"},
+	SCardENoAccess:               {"SCARD_E_NO_ACCESS", "access is denied to the file"},
+	SCardEWriteTooMany:           {"SCARD_E_WRITE_TOO_MANY", "card cannot be written"},
+	SCardEBadSeek:                {"SCARD_E_BAD_SEEK", "error setting the file pointer"},
+	SCardEInvalidCHV:             {"SCARD_E_INVALID_CHV", "pin code is incorrect"},
+	SCardEUnknownResMng:          {"SCARD_E_UNKNOWN_RES_MNG", "unrecognized error code from a layered component"},
+	SCardENoSuchCertificate:      {"SCARD_E_NO_SUCH_CERTIFICATE", "requested certificate does not exist"},
+	SCardECertificateUnavailable: {"SCARD_E_CERTIFICATE_UNAVAILABLE", "requested certificate could not be obtained"},
+	SCardENoReadersAvailable:     {"SCARD_E_NO_READERS_AVAILABLE", "cannot find a smart card reader"},
+	SCardECommDataLost:           {"SCARD_E_COMM_DATA_LOST", "communications error with the card"},
+	SCardENoKeyContainer:         {"SCARD_E_NO_KEY_CONTAINER", "requested key container does not exist"},
+	SCardEServerTooBusy:          {"SCARD_E_SERVER_TOO_BUSY", "resource manager is too busy"},
+	SCardWUnsupportedCard:        {"SCARD_W_UNSUPPORTED_CARD", "card is not supported"},
+	SCardWUnresponsiveCard:       {"SCARD_W_UNRESPONSIVE_CARD", "card is unresponsive"},
+	SCardWUnpoweredCard:          {"SCARD_W_UNPOWERED_CARD", "card is unpowered"},
+	SCardWResetCard:              {"SCARD_W_RESET_CARD", "card was reset"},
+	SCardWRemovedCard:            {"SCARD_W_REMOVED_CARD", "card was removed"},
+	SCardWSecurityViolation:      {"SCARD_W_SECURITY_VIOLATION", "access was denied because of a security violation"},
+	SCardWWrongCHV:               {"SCARD_W_WRONG_CHV", "pin was not accepted"},
+	SCardWCHVBlocked:             {"SCARD_W_CHV_BLOCKED", "pin retry limit has been reached"},
+	SCardWEOF:                    {"SCARD_W_EOF", "end of the card file has been reached"},
+	SCardWCancelledByUser:        {"SCARD_W_CANCELLED_BY_USER", "action was cancelled by the user"},
+	SCardWCardNotAuthenticated:   {"SCARD_W_CARD_NOT_AUTHENTICATED", "no pin was presented to the card"},
+}
+
+// String returns the PC/SC name of the return code, e.g. "SCARD_E_TIMEOUT".
+func (rc ReturnCode) String() string {
+	if c, ok := returnCodes[rc]; ok {
+		return c.name
+	}
+	return fmt.Sprintf("0x%08X", uint32(rc))
+}
+
+// Message returns a human-readable description of the return code.
+func (rc ReturnCode) Message() string {
+	if c, ok := returnCodes[rc]; ok {
+		return c.msg
+	}
+	return "unknown pcsc return code"
+}
+
+// Err converts the return code of the operation op into an error, nil for
+// SCardSuccess.
+func (rc ReturnCode) Err(op string) error { return NewError(op, rc) }
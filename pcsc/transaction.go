@@ -0,0 +1,56 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
+// Disposition specifies the action taken on the card when a transaction
+// ends or a connection is released.
+type Disposition uint32
+
+const (
+	LeaveCard   Disposition = iota // SCARD_LEAVE_CARD, leave the card as it is.
+	ResetCard                      // SCARD_RESET_CARD, warm reset the card.
+	UnpowerCard                    // SCARD_UNPOWER_CARD, power down the card.
+	EjectCard                      // SCARD_EJECT_CARD, eject the card if supported.
+)
+
+// BeginTransaction starts a transaction on the card (SCardBeginTransaction).
+// Until EndTransaction is called other applications sharing the reader are
+// blocked from accessing the card.
+func (c *Card) BeginTransaction() error { return nil }
+
+// EndTransaction ends a transaction started with BeginTransaction
+// (SCardEndTransaction) and applies the given disposition to the card.
+func (c *Card) EndTransaction(d Disposition) error { return nil }
+
+// Transaction runs fn within a PC/SC transaction so that multi-APDU exchanges,
+// such as an authentication followed by a read, cannot be interleaved by other
+// processes using the same reader in shared mode. The transaction is always
+// ended with LeaveCard, also when fn returns an error.
+func (c *Card) Transaction(fn func(c *Card) error) (err error) {
+	if err := c.BeginTransaction(); err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if eerr := c.EndTransaction(LeaveCard); eerr != nil && err == nil {
+			err = fmt.Errorf("end transaction: %w", eerr)
+		}
+	}()
+	return fn(c)
+}
+
+// RunScript runs s on the card within a transaction.
+func (c *Card) RunScript(s *apdu.Script) (res *apdu.ScriptResult, err error) {
+	err = c.Transaction(func(c *Card) error {
+		res, err = s.Run(c)
+		return err
+	})
+	return res, err
+}
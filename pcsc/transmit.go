@@ -0,0 +1,61 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"context"
+	"fmt"
+)
+
+// TransmitContext transmits cmd like Transmit but returns as soon as ctx is
+// done, so a hung reader or a slow card does not block shutdown. The
+// resource manager cannot cancel a pending SCardTransmit, so the exchange
+// is abandoned rather than aborted: it keeps the card busy until it
+// completes and the next TransmitContext waits for it. Disconnecting the
+// card with a reset ends it on most drivers. A per-APDU timeout is set with
+// context.WithTimeout.
+func (c *Card) TransmitContext(ctx context.Context, cmd []byte) ([]byte, error) {
+	return c.transmitContext(ctx, cmd, c.Transmit)
+}
+
+// TransmitReconnectContext transmits cmd like TransmitReconnect, returning
+// as soon as ctx is done like TransmitContext.
+func (c *Card) TransmitReconnectContext(ctx context.Context, cmd []byte, p ReconnectPolicy) ([]byte, error) {
+	return c.transmitContext(ctx, cmd, func(cmd []byte) ([]byte, error) { return c.TransmitReconnect(cmd, p) })
+}
+
+func (c *Card) transmitContext(ctx context.Context, cmd []byte, transmit func([]byte) ([]byte, error)) ([]byte, error) {
+	c.exchangeOnce.Do(func() { c.exchange = make(chan struct{}, 1) })
+	return transmitContext(ctx, cmd, c.exchange, transmit)
+}
+
+func transmitContext(ctx context.Context, cmd []byte, busy chan struct{}, transmit func([]byte) ([]byte, error)) ([]byte, error) {
+	select {
+	case busy <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("transmit: %w", ctx.Err())
+	}
+	if err := ctx.Err(); err != nil {
+		<-busy
+		return nil, fmt.Errorf("transmit: %w", err)
+	}
+
+	type result struct {
+		resp []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := transmit(cmd)
+		<-busy
+		done <- result{resp, err}
+	}()
+	select {
+	case r := <-done:
+		return r.resp, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("transmit: exchange abandoned: %w", ctx.Err())
+	}
+}
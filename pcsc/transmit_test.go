@@ -0,0 +1,52 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransmitContext(t *testing.T) {
+	busy := make(chan struct{}, 1)
+	release := make(chan struct{})
+	hung := func(cmd []byte) ([]byte, error) {
+		<-release
+		return []byte{0x90, 0x00}, nil
+	}
+	echo := func(cmd []byte) ([]byte, error) { return cmd, nil }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := transmitContext(ctx, []byte{1}, busy, hung); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("transmitContext() of a hung exchange error = %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("abandoning the exchange took %v", d)
+	}
+
+	// The abandoned exchange keeps the card busy.
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel2()
+	if _, err := transmitContext(ctx2, []byte{2}, busy, echo); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("transmitContext() while busy error = %v", err)
+	}
+
+	close(release)
+	resp, err := transmitContext(context.Background(), []byte{3}, busy, echo)
+	if err != nil || !bytes.Equal(resp, []byte{3}) {
+		t.Errorf("transmitContext() after the exchange ended = % X, %v", resp, err)
+	}
+
+	canceled, cancel3 := context.WithCancel(context.Background())
+	cancel3()
+	if _, err := transmitContext(canceled, []byte{4}, busy, echo); !errors.Is(err, context.Canceled) {
+		t.Errorf("transmitContext() with a canceled context error = %v", err)
+	}
+}
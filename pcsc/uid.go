@@ -0,0 +1,60 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// ErrUIDUnavailable is returned by Card.UID when neither the reader nor the
+// ATR provide the card UID.
+var ErrUIDUnavailable = errors.New("card uid is not available")
+
+// getDataUID is the PC/SC Part 3 GET DATA pseudo-APDU requesting the card UID.
+var getDataUID = []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}
+
+// UID returns the UID or serial number of the card. It issues the standard
+// PC/SC GET DATA (FF CA 00 00) pseudo-APDU and, for readers which do not
+// support it, falls back to the serial number carried by the historical
+// bytes of the ATR of ISO/IEC 14443-B cards.
+func (c *Card) UID() ([]byte, error) {
+	resp, err := c.Transmit(getDataUID)
+	if err == nil && len(resp) > 2 && resp[len(resp)-2] == 0x90 && resp[len(resp)-1] == 0x00 {
+		uid := make([]byte, len(resp)-2)
+		copy(uid, resp)
+		return uid, nil
+	}
+
+	uid, aerr := uidFromATR(c.atr)
+	if errors.Is(aerr, ErrUIDUnavailable) && err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUIDUnavailable, err)
+	}
+	return uid, aerr
+}
+
+// atqbPUPI is the leading byte of historical bytes holding the ATQB of an
+// ISO/IEC 14443-B card, followed by its four byte PUPI.
+const atqbPUPI = 0x50
+
+// uidFromATR returns the PUPI of ISO/IEC 14443-B cards whose ATR historical
+// bytes hold their ATQB, or ErrUIDUnavailable for any other layout: the
+// historical bytes of other cards, such as the 0x80 category indicator of
+// DESFire cards or the PC/SC Part 3 storage card format, carry no serial.
+func uidFromATR(atr []byte) ([]byte, error) {
+	parsed, err := iso7816.ParseATR(atr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUIDUnavailable, err)
+	}
+	hist := parsed.Historical
+	if len(hist) < 5 || hist[0] != atqbPUPI {
+		return nil, ErrUIDUnavailable
+	}
+	uid := make([]byte, 4)
+	copy(uid, hist[1:5])
+	return uid, nil
+}
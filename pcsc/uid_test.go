@@ -0,0 +1,46 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pscs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestUIDFromATR(t *testing.T) {
+	tests := []struct {
+		atr  string
+		want string
+		err  error
+	}{
+		{"3B8180018080", "", ErrUIDUnavailable},
+		{"3B89800150123456789ABCDEF058", "12345678", nil},
+		{"3B0450123456", "", ErrUIDUnavailable},
+		{"3B8F8001804F0CA000000306030001000000006A", "", ErrUIDUnavailable},
+		{"3B00", "", ErrUIDUnavailable},
+		{"3B", "", ErrUIDUnavailable},
+	}
+	for _, tt := range tests {
+		atr, _ := hex.DecodeString(tt.atr)
+		want, _ := hex.DecodeString(tt.want)
+		uid, err := uidFromATR(atr)
+		if !errors.Is(err, tt.err) || (err == nil && !bytes.Equal(uid, want)) {
+			t.Errorf("uidFromATR(%s) = %X, %v, want %s, %v", tt.atr, uid, err, tt.want, tt.err)
+		}
+	}
+}
+
+func FuzzUIDFromATR(f *testing.F) {
+	atr, _ := hex.DecodeString("3B8180018080")
+	f.Add(atr)
+	f.Fuzz(func(t *testing.T, atr []byte) {
+		uid, err := uidFromATR(atr)
+		if (err == nil) == (len(uid) == 0) {
+			t.Fatalf("uidFromATR(% X) = % X, %v", atr, uid, err)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package piv reads Personal Identity Verification (NIST SP 800-73-4) cards:
+// it selects the PIV applet, retrieves the X.509 certificates of the key
+// slots and signs with their private keys using GENERAL AUTHENTICATE.
+package piv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// AID is the application identifier of the PIV applet.
+var AID = []byte{0xA0, 0x00, 0x00, 0x03, 0x08, 0x00, 0x00, 0x10, 0x00, 0x01, 0x00}
+
+// ErrNoCertificate is returned when a slot holds no certificate.
+var ErrNoCertificate = errors.New("piv: no certificate")
+
+// Slot is a key reference of the PIV applet.
+type Slot byte
+
+const (
+	SlotAuthentication     Slot = 0x9A // PIV Authentication
+	SlotSignature          Slot = 0x9C // Digital Signature
+	SlotKeyManagement      Slot = 0x9D // Key Management
+	SlotCardAuthentication Slot = 0x9E // Card Authentication, usable without PIN.
+)
+
+// String returns the name of the slot.
+func (s Slot) String() string {
+	switch s {
+	case SlotAuthentication:
+		return "PIV Authentication"
+	case SlotSignature:
+		return "Digital Signature"
+	case SlotKeyManagement:
+		return "Key Management"
+	case SlotCardAuthentication:
+		return "Card Authentication"
+	default:
+		return fmt.Sprintf("slot %02X", byte(s))
+	}
+}
+
+// object returns the tag of the data object holding the certificate of the
+// slot.
+func (s Slot) object() []byte {
+	switch s {
+	case SlotAuthentication:
+		return []byte{0x5F, 0xC1, 0x05}
+	case SlotSignature:
+		return []byte{0x5F, 0xC1, 0x0A}
+	case SlotKeyManagement:
+		return []byte{0x5F, 0xC1, 0x0B}
+	case SlotCardAuthentication:
+		return []byte{0x5F, 0xC1, 0x01}
+	default:
+		return nil
+	}
+}
+
+// Algorithm is the cryptographic mechanism of a slot key.
+type Algorithm byte
+
+const (
+	AlgRSA1024 Algorithm = 0x06
+	AlgRSA2048 Algorithm = 0x07
+	AlgECCP256 Algorithm = 0x11
+	AlgECCP384 Algorithm = 0x14
+)
+
+// Select selects the PIV applet.
+func Select(tr apdu.Transceiver) error {
+	if _, err := transmit(tr, iso7816.NewCommandAPDU(0x00, 0xA4, 0x04, 0x00, 0, AID)); err != nil {
+		return fmt.Errorf("piv: select: %w", err)
+	}
+	return nil
+}
+
+// VerifyPIN verifies the PIV card application PIN, which is required before
+// signing with all slots but SlotCardAuthentication.
+func VerifyPIN(tr apdu.Transceiver, pin string) error {
+	if len(pin) < 6 || len(pin) > 8 {
+		return fmt.Errorf("piv: pin must be 6 to 8 characters")
+	}
+	data := bytes.Repeat([]byte{0xFF}, 8)
+	copy(data, pin)
+	if _, err := transmit(tr, iso7816.NewCommandAPDU(0x00, 0x20, 0x00, 0x80, 0, data)); err != nil {
+		return fmt.Errorf("piv: verify pin: %w", err)
+	}
+	return nil
+}
+
+// GetData reads the data object tag, e.g. 5FC102 for the CHUID, and returns
+// its content.
+func GetData(tr apdu.Transceiver, tag []byte) ([]byte, error) {
+	cmd := iso7816.NewCommandAPDU(0x00, 0xCB, 0x3F, 0xFF, 0, iso7816.AppendTLV(nil, 0x5C, tag))
+	cmd.Ne = 256
+	resp, err := transmit(tr, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("piv: get data %X: %w", tag, err)
+	}
+	data, ok := iso7816.FindTLV(resp, 0x53)
+	if !ok {
+		return nil, fmt.Errorf("piv: get data %X: missing data object", tag)
+	}
+	return data, nil
+}
+
+// Certificate retrieves the certificate of slot.
+func Certificate(tr apdu.Transceiver, slot Slot) (*x509.Certificate, error) {
+	tag := slot.object()
+	if tag == nil {
+		return nil, fmt.Errorf("piv: %s has no certificate object", slot)
+	}
+	data, err := GetData(tr, tag)
+	var se *apdu.StatusError
+	if errors.As(err, &se) && se.SW1 == 0x6A && se.SW2 == 0x82 {
+		return nil, ErrNoCertificate
+	}
+	if err != nil {
+		return nil, err
+	}
+	der, ok := iso7816.FindTLV(data, 0x70)
+	if !ok || len(der) == 0 {
+		return nil, ErrNoCertificate
+	}
+	if info, ok := iso7816.FindTLV(data, 0x71); ok && len(info) > 0 && info[0]&0x01 != 0 {
+		zr, err := gzip.NewReader(bytes.NewReader(der))
+		if err != nil {
+			return nil, fmt.Errorf("piv: %s certificate: %w", slot, err)
+		}
+		if der, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("piv: %s certificate: %w", slot, err)
+		}
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("piv: %s certificate: %w", slot, err)
+	}
+	return cert, nil
+}
+
+// GeneralAuthenticate computes the private key operation of the key in
+// slot over challenge: a raw RSA operation over a padded block for RSA
+// keys, an ECDSA signature of a digest for ECC keys.
+func GeneralAuthenticate(tr apdu.Transceiver, slot Slot, alg Algorithm, challenge []byte) ([]byte, error) {
+	template := iso7816.AppendTLV(iso7816.AppendTLV(nil, 0x82, nil), 0x81, challenge)
+	cmd := iso7816.NewCommandAPDU(0x00, 0x87, byte(alg), byte(slot), 0, iso7816.AppendTLV(nil, 0x7C, template))
+	cmd.Ne = 256
+	resp, err := transmit(tr, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("piv: general authenticate %s: %w", slot, err)
+	}
+	sig, ok := iso7816.FindTLV(resp, 0x82)
+	if !ok {
+		return nil, fmt.Errorf("piv: general authenticate %s: missing response", slot)
+	}
+	return sig, nil
+}
+
+// transmit sends cmd, splitting its data with command chaining when it
+// exceeds a short APDU and collecting responses announced by 61xx.
+func transmit(tr apdu.Transceiver, cmd *iso7816.CommandAPDU) ([]byte, error) {
+	raw, err := cmd.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := apdu.Wrap(tr, apdu.Chaining(255), apdu.GetResponse()).Transmit(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := apdu.CheckStatusFromData(resp); err != nil {
+		return nil, err
+	}
+	return resp[:len(resp)-2], nil
+}
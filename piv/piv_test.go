@@ -0,0 +1,154 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package piv
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// fakeCard emulates a PIV applet with a certificate in SlotAuthentication.
+// Responses longer than 256 bytes are returned in parts with 61xx, and
+// chained commands are collected before processing.
+type fakeCard struct {
+	key      crypto.Signer
+	cert     []byte
+	verified bool
+	chained  []byte
+	pending  []byte
+}
+
+func (f *fakeCard) Transmit(raw []byte) ([]byte, error) {
+	cmd, err := iso7816.UnmarshalCommandAPDU(raw)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Cla&0x10 != 0 {
+		f.chained = append(f.chained, cmd.Data...)
+		return []byte{0x90, 0x00}, nil
+	}
+	data := append(f.chained, cmd.Data...)
+	f.chained = nil
+
+	switch cmd.Ins {
+	case 0xA4:
+		return []byte{0x90, 0x00}, nil
+	case 0x20:
+		f.verified = bytes.Equal(data, []byte("123456\xFF\xFF"))
+		if !f.verified {
+			return []byte{0x63, 0xC2}, nil
+		}
+		return []byte{0x90, 0x00}, nil
+	case 0xC0:
+		return f.respond(f.pending), nil
+	case 0xCB:
+		if !bytes.Equal(data, []byte{0x5C, 0x03, 0x5F, 0xC1, 0x05}) {
+			return []byte{0x6A, 0x82}, nil
+		}
+		obj := iso7816.AppendTLV(iso7816.AppendTLV(nil, 0x70, f.cert), 0x71, []byte{0x00})
+		return f.respond(iso7816.AppendTLV(nil, 0x53, obj)), nil
+	case 0x87:
+		if !f.verified {
+			return []byte{0x69, 0x82}, nil
+		}
+		challenge, _ := iso7816.FindTLV(data, 0x81)
+		var sig []byte
+		switch key := f.key.(type) {
+		case *rsa.PrivateKey:
+			m := new(big.Int).SetBytes(challenge)
+			sig = m.Exp(m, key.D, key.N).FillBytes(make([]byte, key.Size()))
+		case *ecdsa.PrivateKey:
+			sig, _ = key.Sign(rand.Reader, challenge, nil)
+		}
+		return f.respond(iso7816.AppendTLV(nil, 0x7C, iso7816.AppendTLV(nil, 0x82, sig))), nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+func (f *fakeCard) respond(data []byte) []byte {
+	if len(data) <= 256 {
+		f.pending = nil
+		return append(append([]byte(nil), data...), 0x90, 0x00)
+	}
+	f.pending = data[256:]
+	return append(append([]byte(nil), data[:256]...), 0x61, byte(min(len(f.pending), 256)))
+}
+
+func newFakeCard(t *testing.T, key crypto.Signer) *fakeCard {
+	t.Helper()
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "PIV test"}}
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeCard{key: key, cert: cert}
+}
+
+func TestSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte("hello"))
+
+	tests := []struct {
+		name   string
+		key    crypto.Signer
+		verify func(sig []byte) bool
+	}{
+		{"rsa2048", rsaKey, func(sig []byte) bool {
+			return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig) == nil
+		}},
+		{"p256", ecKey, func(sig []byte) bool {
+			return ecdsa.VerifyASN1(&ecKey.PublicKey, digest[:], sig)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := newFakeCard(t, tt.key)
+			if err := Select(card); err != nil {
+				t.Fatalf("Select() error = %v", err)
+			}
+			s, err := NewSigner(card, SlotAuthentication)
+			if err != nil {
+				t.Fatalf("NewSigner() error = %v", err)
+			}
+			if err := VerifyPIN(card, "123456"); err != nil {
+				t.Fatalf("VerifyPIN() error = %v", err)
+			}
+			sig, err := s.Sign(rand.Reader, digest[:], crypto.SHA256)
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			if !tt.verify(sig) {
+				t.Errorf("Sign() returned an invalid signature")
+			}
+		})
+	}
+}
+
+func TestCertificateMissing(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	card := newFakeCard(t, key)
+	if _, err := Certificate(card, SlotSignature); !errors.Is(err, ErrNoCertificate) {
+		t.Errorf("Certificate() error = %v, want ErrNoCertificate", err)
+	}
+}
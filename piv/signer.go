@@ -0,0 +1,112 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package piv
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+	"io"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
+// Signer is a crypto.Signer backed by the private key of a PIV slot. The
+// PIN must have been verified with VerifyPIN unless the slot is
+// SlotCardAuthentication.
+type Signer struct {
+	tr   apdu.Transceiver
+	slot Slot
+	alg  Algorithm
+	pub  crypto.PublicKey
+}
+
+// NewSigner returns a signer for slot, taking the public key and algorithm
+// from the certificate of the slot.
+func NewSigner(tr apdu.Transceiver, slot Slot) (*Signer, error) {
+	cert, err := Certificate(tr, slot)
+	if err != nil {
+		return nil, err
+	}
+	s := &Signer{tr: tr, slot: slot, pub: cert.PublicKey}
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		switch pub.Size() {
+		case 128:
+			s.alg = AlgRSA1024
+		case 256:
+			s.alg = AlgRSA2048
+		default:
+			return nil, fmt.Errorf("piv: unsupported rsa key size %d", pub.Size()*8)
+		}
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			s.alg = AlgECCP256
+		case elliptic.P384():
+			s.alg = AlgECCP384
+		default:
+			return nil, fmt.Errorf("piv: unsupported curve %s", pub.Curve.Params().Name)
+		}
+	default:
+		return nil, fmt.Errorf("piv: unsupported public key %T", cert.PublicKey)
+	}
+	return s, nil
+}
+
+// Public returns the public key of the slot.
+func (s *Signer) Public() crypto.PublicKey { return s.pub }
+
+// Sign signs digest with the key of the slot: PKCS #1 v1.5 for RSA keys,
+// ASN.1 encoded ECDSA for ECC keys. rand is unused, the card provides its
+// own randomness.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	challenge := digest
+	if pub, ok := s.pub.(*rsa.PublicKey); ok {
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return nil, fmt.Errorf("piv: rsa pss is not supported")
+		}
+		var err error
+		if challenge, err = pkcs1v15Pad(pub.Size(), opts.HashFunc(), digest); err != nil {
+			return nil, err
+		}
+	}
+	return GeneralAuthenticate(s.tr, s.slot, s.alg, challenge)
+}
+
+// digestInfoPrefixes are the DER encoded DigestInfo headers preceding the
+// digest in PKCS #1 v1.5 signatures.
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// pkcs1v15Pad returns the EMSA-PKCS1-v1_5 encoding of digest for a key of
+// size bytes.
+func pkcs1v15Pad(size int, hash crypto.Hash, digest []byte) ([]byte, error) {
+	prefix, ok := digestInfoPrefixes[hash]
+	if !ok {
+		return nil, fmt.Errorf("piv: unsupported hash %v", hash)
+	}
+	if len(digest) != hash.Size() {
+		return nil, fmt.Errorf("piv: digest of %d bytes for %v", len(digest), hash)
+	}
+	n := len(prefix) + len(digest)
+	if size < n+11 {
+		return nil, fmt.Errorf("piv: key too small for %v", hash)
+	}
+	em := make([]byte, size)
+	em[1] = 0x01
+	for i := 2; i < size-n-1; i++ {
+		em[i] = 0xFF
+	}
+	copy(em[size-n:], prefix)
+	copy(em[size-len(digest):], digest)
+	return em, nil
+}
@@ -0,0 +1,279 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package iso14443
+
+import (
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
+// fsTable maps FSCI and FSDI values to frame sizes in bytes as defined in
+// ISO/IEC 14443-4. Values above 0x0C are reserved and treated as 256 bytes.
+var fsTable = [16]int{16, 24, 32, 40, 48, 64, 96, 128, 256, 512, 1024, 2048, 4096, 256, 256, 256}
+
+// FrameSize returns the frame size in bytes for a FSCI or FSDI value.
+func FrameSize(fsi byte) int { return fsTable[fsi&0x0F] }
+
+// RATS returns the Request for Answer To Select command announcing the
+// frame size of the reader as fsdi and using the card identifier cid.
+func RATS(fsdi, cid byte) []byte {
+	return []byte{0xE0, (fsdi&0x0F)<<4 | cid&0x0F}
+}
+
+// ATS represents an Answer To Select of an ISO 14443-4 Type A card.
+type ATS struct {
+	Raw        []byte
+	FSCI       byte   // Frame size of the card integer.
+	TA         byte   // Supported bit rates, zero when absent.
+	FWI        byte   // Frame waiting time integer, 4 when absent.
+	SFGI       byte   // Start-up frame guard time integer.
+	NADSupport bool   // Card supports the node address.
+	CIDSupport bool   // Card supports the card identifier.
+	Historical []byte // Historical bytes.
+}
+
+// ParseATS parses an ATS as returned by the card, starting with the length
+// byte TL and without CRC.
+func ParseATS(data []byte) (*ATS, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty ats")
+	}
+	tl := int(data[0])
+	if tl != len(data) {
+		return nil, fmt.Errorf("ats length byte %d, got %d bytes", tl, len(data))
+	}
+	ats := &ATS{Raw: data, FSCI: 2, FWI: 4, CIDSupport: true}
+	if tl == 1 {
+		return ats, nil
+	}
+
+	t0 := data[1]
+	if t0&0x80 != 0 {
+		return nil, fmt.Errorf("invalid ats format byte 0x%02X", t0)
+	}
+	ats.FSCI = t0 & 0x0F
+	pos := 2
+	next := func(name string) (byte, error) {
+		if pos >= len(data) {
+			return 0, fmt.Errorf("ats truncated in %s", name)
+		}
+		b := data[pos]
+		pos++
+		return b, nil
+	}
+	var err error
+	if t0&0x10 != 0 {
+		if ats.TA, err = next("TA(1)"); err != nil {
+			return nil, err
+		}
+	}
+	if t0&0x20 != 0 {
+		tb, err := next("TB(1)")
+		if err != nil {
+			return nil, err
+		}
+		ats.FWI = tb >> 4
+		ats.SFGI = tb & 0x0F
+	}
+	if t0&0x40 != 0 {
+		tc, err := next("TC(1)")
+		if err != nil {
+			return nil, err
+		}
+		ats.NADSupport = tc&0x01 != 0
+		ats.CIDSupport = tc&0x02 != 0
+	}
+	ats.Historical = data[pos:]
+	return ats, nil
+}
+
+// FSC returns the maximum frame size the card accepts in bytes.
+func (a *ATS) FSC() int { return FrameSize(a.FSCI) }
+
+// Session holds the frame sizes and block options negotiated with an
+// ISO 14443-4 card, and the block number of the reader.
+type Session struct {
+	FSC    int  // Maximum frame size accepted by the card.
+	FSD    int  // Maximum frame size accepted by the reader.
+	CID    bool // A card identifier is sent with each block.
+	NAD    bool // A node address is sent with each block.
+	CardID byte // Card identifier sent when CID is set, as given in RATS.
+	Node   byte // Node address sent when NAD is set.
+
+	block byte // Current block number of the reader.
+}
+
+// NewSession returns the session negotiated when fsdi and cid were
+// announced in RATS and the card answered with ats. Card identifiers and
+// node addresses are sent when the ATS advertises their support.
+func NewSession(ats *ATS, fsdi, cid byte) *Session {
+	return &Session{
+		FSC:    ats.FSC(),
+		FSD:    FrameSize(fsdi),
+		CID:    ats.CIDSupport,
+		NAD:    ats.NADSupport,
+		CardID: cid & 0x0F,
+	}
+}
+
+// MaxINF returns the largest information field the card accepts in a single
+// I-block, excluding the prologue (PCB, CID, NAD) and the CRC.
+func (s *Session) MaxINF() int {
+	n := s.FSC - 3
+	if s.CID {
+		n--
+	}
+	if s.NAD {
+		n--
+	}
+	return n
+}
+
+// MaxResponseINF returns the largest information field the reader accepts in
+// a single I-block from the card.
+func (s *Session) MaxResponseINF() int {
+	n := s.FSD - 3
+	if s.CID {
+		n--
+	}
+	if s.NAD {
+		n--
+	}
+	return n
+}
+
+// Chunks splits an APDU into information fields fitting the frame size of
+// the card. More than one chunk requires block chaining.
+func (s *Session) Chunks(apdu []byte) [][]byte {
+	size := s.MaxINF()
+	if size <= 0 {
+		return nil
+	}
+	var chunks [][]byte
+	for len(apdu) > size {
+		chunks = append(chunks, apdu[:size])
+		apdu = apdu[size:]
+	}
+	return append(chunks, apdu)
+}
+
+// Block types and flags of the PCB of ISO 14443-4 blocks.
+const (
+	pcbI        = 0x02 // I-block, with the block number in bit 1.
+	pcbR        = 0xA2 // R-block, with the block number in bit 1.
+	pcbS        = 0xC2 // S-block.
+	pcbChaining = 0x10 // I-block chaining, or NAK in R-blocks.
+	pcbCID      = 0x08 // A card identifier follows the PCB.
+	pcbNAD      = 0x04 // A node address follows the PCB.
+	pcbWTX      = 0x30 // S(WTX) in S-blocks.
+)
+
+// IBlocks returns the I-blocks carrying apdu, without CRC, chained when it
+// does not fit the frame size of the card. Blocks are numbered from the
+// current block number of s, which Transceive advances.
+func (s *Session) IBlocks(apdu []byte) [][]byte {
+	chunks := s.Chunks(apdu)
+	blocks := make([][]byte, len(chunks))
+	block := s.block
+	for i, inf := range chunks {
+		pcb := byte(pcbI) | block
+		if i < len(chunks)-1 {
+			pcb |= pcbChaining
+		}
+		blocks[i] = append(s.prologue(pcb, true), inf...)
+		block ^= 1
+	}
+	return blocks
+}
+
+// prologue returns the prologue of a block with pcb, with the CID and,
+// for I-blocks when nad is set, the NAD of s.
+func (s *Session) prologue(pcb byte, nad bool) []byte {
+	p := []byte{pcb}
+	if s.CID {
+		p[0] |= pcbCID
+		p = append(p, s.CardID)
+	}
+	if s.NAD && nad {
+		p[0] |= pcbNAD
+		p = append(p, s.Node)
+	}
+	return p
+}
+
+// Transceive sends apdu to the card through tr, which exchanges ISO
+// 14443-4 blocks, the reader adding and checking the CRC. Commands longer
+// than the frame size of the card are chained, as are responses longer than
+// the frame size of the reader, and waiting time extensions requested by
+// the card are granted.
+func (s *Session) Transceive(tr apdu.Transceiver, cmd []byte) ([]byte, error) {
+	blocks := s.IBlocks(cmd)
+	if blocks == nil {
+		return nil, fmt.Errorf("frame size %d too small", s.FSC)
+	}
+	var resp []byte
+	for i, b := range blocks {
+		pcb, inf, err := s.exchange(tr, b)
+		if err != nil {
+			return nil, err
+		}
+		if i < len(blocks)-1 {
+			if pcb&0xE6 != pcbR&0xE6 || pcb&pcbChaining != 0 || pcb&0x01 != s.block {
+				return nil, fmt.Errorf("chained block %d not acknowledged, got PCB 0x%02X", i, pcb)
+			}
+			s.block ^= 1
+			continue
+		}
+		for {
+			if pcb&0xE2 != pcbI || pcb&0x01 != s.block {
+				return nil, fmt.Errorf("unexpected response block, PCB 0x%02X", pcb)
+			}
+			s.block ^= 1
+			resp = append(resp, inf...)
+			if pcb&pcbChaining == 0 {
+				return resp, nil
+			}
+			if pcb, inf, err = s.exchange(tr, s.prologue(pcbR|s.block, false)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return resp, nil
+}
+
+// exchange sends block and returns the PCB and information field of the
+// response, answering the S(WTX) requests of the card.
+func (s *Session) exchange(tr apdu.Transceiver, block []byte) (byte, []byte, error) {
+	for {
+		resp, err := tr.Transmit(block)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(resp) == 0 {
+			return 0, nil, fmt.Errorf("empty block")
+		}
+		pcb, inf := resp[0], resp[1:]
+		if pcb&pcbCID != 0 {
+			if len(inf) == 0 {
+				return 0, nil, fmt.Errorf("block truncated in CID")
+			}
+			inf = inf[1:]
+		}
+		if pcb&0xC0 == 0x00 && pcb&pcbNAD != 0 {
+			if len(inf) == 0 {
+				return 0, nil, fmt.Errorf("block truncated in NAD")
+			}
+			inf = inf[1:]
+		}
+		if pcb&0xF6 != pcbS|pcbWTX {
+			return pcb, inf, nil
+		}
+		if len(inf) == 0 {
+			return 0, nil, fmt.Errorf("S(WTX) without WTXM")
+		}
+		block = append(s.prologue(pcbS|pcbWTX, false), inf[0]&0x3F)
+	}
+}
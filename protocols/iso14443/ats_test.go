@@ -0,0 +1,128 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package iso14443
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestParseATS(t *testing.T) {
+	// ATS of a MIFARE DESFire EV1.
+	data := []byte{0x06, 0x75, 0x77, 0x81, 0x02, 0x80}
+	ats, err := ParseATS(data)
+	if err != nil {
+		t.Fatalf("ParseATS() error = %v", err)
+	}
+	if ats.FSC() != 64 {
+		t.Errorf("FSC() = %d, want 64", ats.FSC())
+	}
+	if ats.TA != 0x77 || ats.FWI != 8 || ats.SFGI != 1 {
+		t.Errorf("TA, FWI, SFGI = %02X, %d, %d; want 77, 8, 1", ats.TA, ats.FWI, ats.SFGI)
+	}
+	if !ats.CIDSupport || ats.NADSupport {
+		t.Errorf("CID, NAD support = %v, %v; want true, false", ats.CIDSupport, ats.NADSupport)
+	}
+	if !bytes.Equal(ats.Historical, []byte{0x80}) {
+		t.Errorf("Historical = % X, want 80", ats.Historical)
+	}
+
+	for _, bad := range [][]byte{nil, {0x05, 0x75}, {0x03, 0x70, 0x00}, {0x02, 0x80}} {
+		if _, err := ParseATS(bad); err == nil {
+			t.Errorf("ParseATS(% X) succeeded", bad)
+		}
+	}
+}
+
+func TestSessionChunks(t *testing.T) {
+	ats, err := ParseATS([]byte{0x05, 0x70, 0x00, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSession(ats, 8, 0)
+	s.CID = true
+	if s.FSC != 16 || s.FSD != 256 {
+		t.Fatalf("session sizes = %d, %d; want 16, 256", s.FSC, s.FSD)
+	}
+	if s.MaxINF() != 12 {
+		t.Errorf("MaxINF() = %d, want 12", s.MaxINF())
+	}
+	chunks := s.Chunks(make([]byte, 30))
+	if len(chunks) != 3 || len(chunks[0]) != 12 || len(chunks[2]) != 6 {
+		t.Errorf("Chunks() lengths wrong: %d chunks", len(chunks))
+	}
+	if !bytes.Equal(RATS(8, 0), []byte{0xE0, 0x80}) {
+		t.Errorf("RATS() = % X, want E0 80", RATS(8, 0))
+	}
+}
+
+func TestNewSession(t *testing.T) {
+	tests := []struct {
+		ats      []byte
+		cid, nad bool
+	}{
+		{[]byte{0x02, 0x05}, true, false},
+		{[]byte{0x03, 0x45, 0x00}, false, false},
+		{[]byte{0x03, 0x45, 0x03}, true, true},
+	}
+	for _, tt := range tests {
+		ats, err := ParseATS(tt.ats)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewSession(ats, 8, 0x13)
+		if s.FSC != 64 || s.CID != tt.cid || s.NAD != tt.nad || s.CardID != 0x03 {
+			t.Errorf("NewSession(% X) = %+v", tt.ats, s)
+		}
+	}
+}
+
+// card answers the blocks of a session as a scripted list of exchanges.
+type card struct {
+	t     *testing.T
+	steps [][2][]byte
+}
+
+func (c *card) Transmit(block []byte) ([]byte, error) {
+	if len(c.steps) == 0 {
+		return nil, fmt.Errorf("unexpected block % X", block)
+	}
+	step := c.steps[0]
+	c.steps = c.steps[1:]
+	if !bytes.Equal(block, step[0]) {
+		c.t.Errorf("block = % X, want % X", block, step[0])
+	}
+	return step[1], nil
+}
+
+func TestSessionTransceive(t *testing.T) {
+	s := &Session{FSC: 16, FSD: 16, CID: true, CardID: 1}
+	cmd := []byte{0x00, 0xD6, 0x00, 0x00, 0x0C, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	c := &card{t: t, steps: [][2][]byte{
+		// Chained command, acknowledged.
+		{append([]byte{0x1A, 0x01}, cmd[:12]...), {0xAA, 0x01}},
+		// Last block, answered with a waiting time extension first.
+		{append([]byte{0x0B, 0x01}, cmd[12:]...), {0xFA, 0x01, 0x03}},
+		{[]byte{0xFA, 0x01, 0x03}, {0x1B, 0x01, 0xCA, 0xFE}},
+		// Chained response, acknowledged by the reader.
+		{[]byte{0xAA, 0x01}, {0x0A, 0x01, 0x90, 0x00}},
+	}}
+	resp, err := s.Transceive(c, cmd)
+	if err != nil {
+		t.Fatalf("Transceive() error = %v", err)
+	}
+	if !bytes.Equal(resp, []byte{0xCA, 0xFE, 0x90, 0x00}) || len(c.steps) != 0 {
+		t.Errorf("Transceive() = % X, %d steps left", resp, len(c.steps))
+	}
+	if s.block != 1 {
+		t.Errorf("block number = %d, want 1", s.block)
+	}
+
+	c = &card{t: t, steps: [][2][]byte{{[]byte{0x0B, 0x01, 0x00}, {0xB3, 0x01}}}}
+	if _, err := s.Transceive(c, []byte{0x00}); err == nil {
+		t.Error("Transceive() accepted an R(NAK)")
+	}
+}
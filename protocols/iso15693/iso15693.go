@@ -0,0 +1,296 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package iso15693 provides tools for ISO/IEC 15693 vicinity cards such as
+// NXP ICODE SLIX and ST25DV/ST25TV tags. It implements the mandatory and
+// common optional commands (Inventory, Read/Write Single and Multiple Blocks,
+// Lock Block, Get System Information) over a raw frame exchange provided by
+// the reader pass-through, and the NFC Forum Type 5 Tag NDEF mapping.
+package iso15693
+
+import (
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
+// Request flags.
+const (
+	FlagHighDataRate = 0x02
+	FlagInventory    = 0x04
+	FlagAddressed    = 0x20 // Without FlagInventory.
+	FlagOneSlot      = 0x20 // With FlagInventory.
+	FlagOption       = 0x40
+)
+
+// Command codes.
+const (
+	CmdInventory          = 0x01
+	CmdReadSingleBlock    = 0x20
+	CmdWriteSingleBlock   = 0x21
+	CmdLockBlock          = 0x22
+	CmdReadMultipleBlocks = 0x23
+	CmdWriteMultiple      = 0x24
+	CmdGetSystemInfo      = 0x2B
+)
+
+// UIDSize is the size of an ISO 15693 UID in bytes.
+const UIDSize = 8
+
+// Exchanger exchanges raw ISO 15693 frames, without SOF, EOF and CRC, with
+// a tag through the reader.
+type Exchanger interface {
+	Exchange(frame []byte) ([]byte, error)
+}
+
+// ExchangerFunc adapts a function to the Exchanger interface.
+type ExchangerFunc func(frame []byte) ([]byte, error)
+
+// Exchange calls f(frame).
+func (f ExchangerFunc) Exchange(frame []byte) ([]byte, error) { return f(frame) }
+
+// PassThrough returns an Exchanger wrapping frames into the pseudo-APDU
+// pass-through command of a reader. header is the APDU header preceding the
+// frame, e.g. FF 00 00 00 on ACS readers; Lc is appended automatically and
+// the status words of the response are verified and stripped.
+func PassThrough(tr apdu.Transceiver, header []byte) Exchanger {
+	return ExchangerFunc(func(frame []byte) ([]byte, error) {
+		cmd := append(append(append([]byte(nil), header...), byte(len(frame))), frame...)
+		resp, err := tr.Transmit(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if err := apdu.CheckStatusFromData(resp); err != nil {
+			return nil, err
+		}
+		return resp[:len(resp)-2], nil
+	})
+}
+
+// Error is an error code returned by a tag.
+type Error byte
+
+func (e Error) Error() string {
+	switch e {
+	case 0x01:
+		return "iso15693: command not supported"
+	case 0x02:
+		return "iso15693: command not recognized"
+	case 0x03:
+		return "iso15693: option not supported"
+	case 0x10:
+		return "iso15693: block not available"
+	case 0x11:
+		return "iso15693: block already locked"
+	case 0x12:
+		return "iso15693: block locked, content cannot be changed"
+	case 0x13:
+		return "iso15693: block programming unsuccessful"
+	case 0x14:
+		return "iso15693: block lock unsuccessful"
+	default:
+		return fmt.Sprintf("iso15693: error 0x%02X", byte(e))
+	}
+}
+
+// Inventory performs a single slot inventory and returns the tag answering
+// it together with its DSFID.
+func Inventory(ex Exchanger) (*Tag, byte, error) {
+	resp, err := exchange(ex, []byte{FlagHighDataRate | FlagInventory | FlagOneSlot, CmdInventory, 0x00})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp) != 1+UIDSize {
+		return nil, 0, fmt.Errorf("inventory response of %d bytes", len(resp))
+	}
+	return NewTag(ex, reverse(resp[1:])), resp[0], nil
+}
+
+// Tag is an ISO 15693 tag addressed by its UID.
+type Tag struct {
+	ex        Exchanger
+	uid       []byte // Most significant byte first, as printed on tags.
+	blockSize int
+	blocks    int
+}
+
+// NewTag returns a tag addressed by uid, most significant byte first. The
+// block size defaults to 4 bytes until SystemInfo reports otherwise.
+func NewTag(ex Exchanger, uid []byte) *Tag {
+	return &Tag{ex: ex, uid: uid, blockSize: 4}
+}
+
+// UID returns the UID of the tag, most significant byte first.
+func (t *Tag) UID() []byte { return t.uid }
+
+// BlockSize returns the block size of the tag in bytes.
+func (t *Tag) BlockSize() int { return t.blockSize }
+
+// SystemInfo is the response to Get System Information.
+type SystemInfo struct {
+	UID       []byte
+	DSFID     *byte
+	AFI       *byte
+	Blocks    int // Number of blocks, zero when not reported.
+	BlockSize int // Block size in bytes, zero when not reported.
+	ICRef     *byte
+}
+
+// SystemInfo reads the system information of the tag and adopts the
+// reported memory size.
+func (t *Tag) SystemInfo() (*SystemInfo, error) {
+	resp, err := t.command(CmdGetSystemInfo, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 1+UIDSize {
+		return nil, fmt.Errorf("short system information")
+	}
+	flags := resp[0]
+	info := &SystemInfo{UID: reverse(resp[1 : 1+UIDSize])}
+	rest := resp[1+UIDSize:]
+	take := func(n int) ([]byte, error) {
+		if len(rest) < n {
+			return nil, fmt.Errorf("truncated system information")
+		}
+		b := rest[:n]
+		rest = rest[n:]
+		return b, nil
+	}
+	if flags&0x01 != 0 {
+		b, err := take(1)
+		if err != nil {
+			return nil, err
+		}
+		info.DSFID = &b[0]
+	}
+	if flags&0x02 != 0 {
+		b, err := take(1)
+		if err != nil {
+			return nil, err
+		}
+		info.AFI = &b[0]
+	}
+	if flags&0x04 != 0 {
+		b, err := take(2)
+		if err != nil {
+			return nil, err
+		}
+		info.Blocks = int(b[0]) + 1
+		info.BlockSize = int(b[1]&0x1F) + 1
+		t.blocks, t.blockSize = info.Blocks, info.BlockSize
+	}
+	if flags&0x08 != 0 {
+		b, err := take(1)
+		if err != nil {
+			return nil, err
+		}
+		info.ICRef = &b[0]
+	}
+	return info, nil
+}
+
+// ReadBlock reads a single block.
+func (t *Tag) ReadBlock(n int) ([]byte, error) {
+	if err := checkBlock(n); err != nil {
+		return nil, err
+	}
+	return t.command(CmdReadSingleBlock, false, byte(n))
+}
+
+// ReadBlocks reads count consecutive blocks starting at first.
+func (t *Tag) ReadBlocks(first, count int) ([]byte, error) {
+	if err := checkBlock(first); err != nil {
+		return nil, err
+	}
+	if count < 1 || count > 256 || first+count > 256 {
+		return nil, fmt.Errorf("block count %d out of range", count)
+	}
+	return t.command(CmdReadMultipleBlocks, false, byte(first), byte(count-1))
+}
+
+// WriteBlock writes a single block.
+func (t *Tag) WriteBlock(n int, data []byte) error {
+	if err := checkBlock(n); err != nil {
+		return err
+	}
+	if len(data) != t.blockSize {
+		return fmt.Errorf("block data must be %d bytes, got %d", t.blockSize, len(data))
+	}
+	_, err := t.command(CmdWriteSingleBlock, false, append([]byte{byte(n)}, data...)...)
+	return err
+}
+
+// WriteBlocks writes data to consecutive blocks starting at first, one
+// Write Single Block command per block since Write Multiple Blocks is rarely
+// supported. The last block is padded with zeros.
+func (t *Tag) WriteBlocks(first int, data []byte) error {
+	for i := 0; i*t.blockSize < len(data); i++ {
+		block := make([]byte, t.blockSize)
+		copy(block, data[i*t.blockSize:])
+		if err := t.WriteBlock(first+i, block); err != nil {
+			return fmt.Errorf("write block %d: %w", first+i, err)
+		}
+	}
+	return nil
+}
+
+// LockBlock permanently locks a block against writing.
+func (t *Tag) LockBlock(n int) error {
+	if err := checkBlock(n); err != nil {
+		return err
+	}
+	_, err := t.command(CmdLockBlock, false, byte(n))
+	return err
+}
+
+// command sends an addressed command and returns the response without the
+// response flags.
+func (t *Tag) command(cmd byte, option bool, params ...byte) ([]byte, error) {
+	flags := byte(FlagHighDataRate | FlagAddressed)
+	if option {
+		flags |= FlagOption
+	}
+	frame := append([]byte{flags, cmd}, reverse(t.uid)...)
+	frame = append(frame, params...)
+	resp, err := exchange(t.ex, frame)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// exchange sends frame and checks the response flags, returning the
+// response without them.
+func exchange(ex Exchanger, frame []byte) ([]byte, error) {
+	resp, err := ex.Exchange(frame)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) == 0 {
+		return nil, fmt.Errorf("empty response")
+	}
+	if resp[0]&0x01 != 0 {
+		if len(resp) < 2 {
+			return nil, fmt.Errorf("error response without code")
+		}
+		return nil, Error(resp[1])
+	}
+	return resp[1:], nil
+}
+
+func checkBlock(n int) error {
+	if n < 0 || n > 0xFF {
+		return fmt.Errorf("block %d out of range", n)
+	}
+	return nil
+}
+
+func reverse(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[len(b)-1-i] = b[i]
+	}
+	return out
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package iso15693
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// fakeTag emulates an ICODE SLIX with 28 blocks of 4 bytes.
+type fakeTag struct {
+	uid    []byte // Wire order, least significant byte first.
+	mem    []byte
+	locked map[int]bool
+}
+
+func newFakeTag() *fakeTag {
+	f := &fakeTag{
+		uid:    []byte{0x11, 0x22, 0x33, 0x44, 0x01, 0x04, 0x01, 0xE0},
+		mem:    make([]byte, 28*4),
+		locked: make(map[int]bool),
+	}
+	copy(f.mem, []byte{0xE1, 0x40, 0x0D, 0x01})
+	return f
+}
+
+func (f *fakeTag) Exchange(frame []byte) ([]byte, error) {
+	cmd := frame[1]
+	if cmd == CmdInventory {
+		return append([]byte{0x00, 0x00}, f.uid...), nil
+	}
+	if frame[0]&FlagAddressed == 0 || !bytes.Equal(frame[2:10], f.uid) {
+		return nil, errors.New("no response")
+	}
+	p := frame[10:]
+	switch cmd {
+	case CmdGetSystemInfo:
+		return append(append([]byte{0x00, 0x0F}, f.uid...), 0x00, 0x00, 27, 0x03, 0x01), nil
+	case CmdReadSingleBlock:
+		return append([]byte{0x00}, f.mem[int(p[0])*4:int(p[0])*4+4]...), nil
+	case CmdReadMultipleBlocks:
+		first, count := int(p[0]), int(p[1])+1
+		if (first+count)*4 > len(f.mem) {
+			return []byte{0x01, 0x10}, nil
+		}
+		return append([]byte{0x00}, f.mem[first*4:(first+count)*4]...), nil
+	case CmdWriteSingleBlock:
+		if f.locked[int(p[0])] {
+			return []byte{0x01, 0x12}, nil
+		}
+		copy(f.mem[int(p[0])*4:], p[1:5])
+		return []byte{0x00}, nil
+	case CmdLockBlock:
+		f.locked[int(p[0])] = true
+		return []byte{0x00}, nil
+	}
+	return []byte{0x01, 0x01}, nil
+}
+
+func TestInventoryAndBlocks(t *testing.T) {
+	fake := newFakeTag()
+	tag, dsfid, err := Inventory(fake)
+	if err != nil {
+		t.Fatalf("Inventory() error = %v", err)
+	}
+	if dsfid != 0 || !bytes.Equal(tag.UID(), []byte{0xE0, 0x01, 0x04, 0x01, 0x44, 0x33, 0x22, 0x11}) {
+		t.Errorf("Inventory() uid = % X, dsfid %d", tag.UID(), dsfid)
+	}
+
+	info, err := tag.SystemInfo()
+	if err != nil {
+		t.Fatalf("SystemInfo() error = %v", err)
+	}
+	if info.Blocks != 28 || info.BlockSize != 4 || info.ICRef == nil || *info.ICRef != 0x01 {
+		t.Errorf("SystemInfo() = %+v", info)
+	}
+
+	if err := tag.WriteBlock(5, []byte{1, 2, 3, 4}); err != nil {
+		t.Fatalf("WriteBlock() error = %v", err)
+	}
+	if got, err := tag.ReadBlock(5); err != nil || !bytes.Equal(got, []byte{1, 2, 3, 4}) {
+		t.Errorf("ReadBlock() = % X, %v", got, err)
+	}
+	if err := tag.LockBlock(5); err != nil {
+		t.Fatalf("LockBlock() error = %v", err)
+	}
+	if err := tag.WriteBlock(5, []byte{0, 0, 0, 0}); !errors.Is(err, Error(0x12)) {
+		t.Errorf("WriteBlock() to locked block error = %v, want %v", err, Error(0x12))
+	}
+}
+
+func TestNDEF(t *testing.T) {
+	tag := NewTag(newFakeTag(), []byte{0xE0, 0x01, 0x04, 0x01, 0x44, 0x33, 0x22, 0x11})
+	msg := []byte{0xD1, 0x01, 0x0C, 'U', 0x04, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm'}
+	if err := tag.WriteNDEF(msg); err != nil {
+		t.Fatalf("WriteNDEF() error = %v", err)
+	}
+	got, err := tag.ReadNDEF()
+	if err != nil {
+		t.Fatalf("ReadNDEF() error = %v", err)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("ReadNDEF() = % X, want % X", got, msg)
+	}
+	if err := tag.WriteNDEF(make([]byte, 200)); err == nil {
+		t.Error("WriteNDEF() accepted a message larger than the data area")
+	}
+}
+
+func TestNDEFDataArea(t *testing.T) {
+	msg := []byte{0xD1, 0x01, 0x01, 'U', 0x00}
+	tests := []struct {
+		name    string
+		area    []byte // Data area following the capability container.
+		cc      byte
+		keep    int // Leading bytes of the data area kept by the write.
+		wantErr error
+	}{
+		{"blank", nil, 0xE1, 0, nil},
+		{"lock control tlv", []byte{0x01, 0x03, 0xA0, 0x0C, 0x34}, 0xE1, 5, nil},
+		{"control and proprietary tlvs", []byte{0x00, 0x02, 0x03, 0xB0, 0x10, 0x44, 0xFD, 0x01, 0xAA, 0x03, 0x01, 0x00}, 0xE1, 9, nil},
+		{"extended addressing", nil, 0xE2, 0, ErrExtendedAddressing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeTag()
+			fake.mem[0] = tt.cc
+			copy(fake.mem[4:], tt.area)
+			tag := NewTag(fake, []byte{0xE0, 0x01, 0x04, 0x01, 0x44, 0x33, 0x22, 0x11})
+			if err := tag.WriteNDEF(msg); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WriteNDEF() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if _, err := tag.ReadNDEF(); !errors.Is(err, tt.wantErr) {
+					t.Errorf("ReadNDEF() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if !bytes.Equal(fake.mem[4:4+tt.keep], tt.area[:tt.keep]) {
+				t.Errorf("data area = % X, want % X kept", fake.mem[4:4+tt.keep], tt.area[:tt.keep])
+			}
+			if got := fake.mem[4+tt.keep]; got != 0x03 {
+				t.Errorf("tlv after kept bytes = %02X, want 03", got)
+			}
+			if got, err := tag.ReadNDEF(); err != nil || !bytes.Equal(got, msg) {
+				t.Errorf("ReadNDEF() = % X, %v; want % X", got, err, msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package iso15693
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoCapabilityContainer is returned for tags not formatted for NDEF.
+	ErrNoCapabilityContainer = errors.New("no ndef capability container")
+	// ErrNoNDEFMessage is returned by ReadNDEF for tags without an NDEF
+	// message TLV.
+	ErrNoNDEFMessage = errors.New("no ndef message tlv")
+	// ErrExtendedAddressing is returned for tags with an 0xE2 capability
+	// container, whose data area needs the two byte block addresses of the
+	// extended commands, which Tag does not implement.
+	ErrExtendedAddressing = errors.New("ndef data area needs extended block addressing")
+)
+
+// CapabilityContainer is the Type 5 Tag capability container stored at the
+// start of the tag memory.
+type CapabilityContainer struct {
+	Magic    byte // 0xE1, or 0xE2 for tags using two byte block addresses.
+	Version  byte // Major version in bits 7-6, minor in bits 5-4.
+	Read     bool // NDEF read access granted.
+	Write    bool // NDEF write access granted.
+	DataSize int  // Size of the data area in bytes.
+	Features byte
+	Length   int // Length of the capability container, 4 or 8 bytes.
+}
+
+// ParseCapabilityContainer parses a 4 or 8 byte Type 5 capability container.
+func ParseCapabilityContainer(b []byte) (*CapabilityContainer, error) {
+	if len(b) < 4 || (b[0] != 0xE1 && b[0] != 0xE2) {
+		return nil, ErrNoCapabilityContainer
+	}
+	cc := &CapabilityContainer{
+		Magic:    b[0],
+		Version:  b[1] & 0xF0,
+		Read:     b[1]&0x0C == 0x00,
+		Write:    b[1]&0x03 == 0x00,
+		DataSize: int(b[2]) * 8,
+		Features: b[3],
+		Length:   4,
+	}
+	if b[2] == 0 {
+		if len(b) < 8 {
+			return nil, fmt.Errorf("truncated extended capability container")
+		}
+		cc.DataSize = int(binary.BigEndian.Uint16(b[6:8])) * 8
+		cc.Length = 8
+	}
+	return cc, nil
+}
+
+// ReadCapabilityContainer reads the capability container of the tag.
+func (t *Tag) ReadCapabilityContainer() (*CapabilityContainer, error) {
+	b, err := t.readBytes(0, 8)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCapabilityContainer(b)
+}
+
+// ReadNDEF reads the NDEF message stored in the NDEF message TLV of the tag.
+func (t *Tag) ReadNDEF() ([]byte, error) {
+	cc, end, err := t.dataArea()
+	if err != nil {
+		return nil, err
+	}
+	if !cc.Read {
+		return nil, fmt.Errorf("ndef read access denied")
+	}
+	for pos := cc.Length; pos < end; {
+		hdr, err := t.readBytes(pos, min(4, end-pos))
+		if err != nil {
+			return nil, err
+		}
+		switch hdr[0] {
+		case 0x00:
+			pos++
+			continue
+		case 0xFE:
+			return nil, ErrNoNDEFMessage
+		}
+		l, n, err := tlvLength(hdr)
+		if err != nil {
+			return nil, err
+		}
+		if hdr[0] == 0x03 {
+			if pos+n+l > end {
+				return nil, fmt.Errorf("ndef message exceeds data area")
+			}
+			return t.readBytes(pos+n, l)
+		}
+		pos += n + l
+	}
+	return nil, ErrNoNDEFMessage
+}
+
+// WriteNDEF writes msg as NDEF message TLV after the capability container
+// and the Lock Control, Memory Control and proprietary TLVs leading the data
+// area, followed by a terminator TLV when it fits.
+func (t *Tag) WriteNDEF(msg []byte) error {
+	cc, end, err := t.dataArea()
+	if err != nil {
+		return err
+	}
+	if !cc.Write {
+		return fmt.Errorf("ndef write access denied")
+	}
+	start, err := t.controlEnd(cc.Length, end)
+	if err != nil {
+		return err
+	}
+	var tlv []byte
+	if len(msg) < 0xFF {
+		tlv = []byte{0x03, byte(len(msg))}
+	} else {
+		tlv = []byte{0x03, 0xFF, byte(len(msg) >> 8), byte(len(msg))}
+	}
+	tlv = append(tlv, msg...)
+	if start+len(tlv) > end {
+		return fmt.Errorf("%d byte ndef tlv exceeds %d byte data area", len(tlv), end-start)
+	}
+	if start+len(tlv) < end {
+		tlv = append(tlv, 0xFE)
+	}
+
+	// Preserve the bytes sharing the first written block.
+	first := start / t.blockSize
+	head, err := t.readBytes(first*t.blockSize, start-first*t.blockSize)
+	if err != nil {
+		return err
+	}
+	return t.WriteBlocks(first, append(head, tlv...))
+}
+
+// dataArea reads the capability container and returns it with the end
+// offset of the data area, clipped to the blocks single byte addresses reach.
+func (t *Tag) dataArea() (*CapabilityContainer, int, error) {
+	cc, err := t.ReadCapabilityContainer()
+	if err != nil {
+		return nil, 0, err
+	}
+	if cc.Magic == 0xE2 {
+		return nil, 0, ErrExtendedAddressing
+	}
+	return cc, min(cc.Length+cc.DataSize, 256*t.blockSize), nil
+}
+
+// controlEnd returns the offset following the Lock Control, Memory Control
+// and proprietary TLVs leading the data area between start and end, where
+// the NDEF message TLV is written.
+func (t *Tag) controlEnd(start, end int) (int, error) {
+	// Buffer the data area block by block rather than reading a block per
+	// TLV, as NULL TLVs may pad it byte by byte.
+	var buf []byte
+	need := func(pos int) ([]byte, error) {
+		for start+len(buf) < min(pos+4, end) {
+			b, err := t.readBytes(start+len(buf), min(t.blockSize, end-start-len(buf)))
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, b...)
+		}
+		return buf[pos-start : min(pos+4, end)-start], nil
+	}
+	next := start
+	for pos := start; pos < end; {
+		hdr, err := need(pos)
+		if err != nil {
+			return 0, err
+		}
+		switch hdr[0] {
+		case 0x00: // NULL TLV
+			pos++
+			continue
+		case 0x01, 0x02, 0xFD: // Lock Control, Memory Control and proprietary TLVs
+		default:
+			return next, nil
+		}
+		l, n, err := tlvLength(hdr)
+		if err != nil {
+			return 0, err
+		}
+		pos += n + l
+		if pos > end {
+			return 0, fmt.Errorf("tlv exceeds data area")
+		}
+		next = pos
+	}
+	return next, nil
+}
+
+// tlvLength decodes the length field of the TLV starting hdr, returning the
+// length of the value and of the type and length fields.
+func tlvLength(hdr []byte) (l, n int, err error) {
+	if len(hdr) < 2 || hdr[1] == 0xFF && len(hdr) < 4 {
+		return 0, 0, fmt.Errorf("truncated tlv")
+	}
+	if hdr[1] == 0xFF {
+		return int(binary.BigEndian.Uint16(hdr[2:4])), 4, nil
+	}
+	return int(hdr[1]), 2, nil
+}
+
+// readBytes reads n bytes starting at byte offset off.
+func (t *Tag) readBytes(off, n int) ([]byte, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	first := off / t.blockSize
+	last := (off + n - 1) / t.blockSize
+	var data []byte
+	for b := first; b <= last; {
+		count := min(last-b+1, 32)
+		chunk, err := t.ReadBlocks(b, count)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+		b += count
+	}
+	skip := off - first*t.blockSize
+	if len(data) < skip+n {
+		return nil, fmt.Errorf("short read")
+	}
+	return data[skip : skip+n], nil
+}
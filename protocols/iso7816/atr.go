@@ -0,0 +1,91 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package iso7816
+
+import "fmt"
+
+// ATR represents a parsed Answer To Reset as defined in ISO/IEC 7816-3.
+type ATR struct {
+	Raw        []byte // Raw ATR bytes.
+	TS         byte   // Initial character, 0x3B (direct) or 0x3F (inverse convention).
+	T0         byte   // Format character.
+	Interface  []byte // Interface bytes TAi, TBi, TCi and TDi in order of appearance.
+	Protocols  []int  // Protocols indicated by the TDi bytes, e.g. 0 and 1.
+	Historical []byte // Historical bytes.
+	TCK        byte   // Check character, zero when absent.
+	HasTCK     bool   // Indicates whether the ATR contains a check character.
+}
+
+// MaxATRLen is the largest ATR allowed by ISO/IEC 7816-3, TS followed by
+// at most 32 characters.
+const MaxATRLen = 33
+
+// ParseATR parses raw ATR bytes. When the ATR contains a check character
+// it is verified. ATRs longer than MaxATRLen are rejected.
+func ParseATR(data []byte) (*ATR, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("atr too short: %d bytes", len(data))
+	}
+	if len(data) > MaxATRLen {
+		return nil, fmt.Errorf("atr too long: %d bytes", len(data))
+	}
+	if data[0] != 0x3B && data[0] != 0x3F {
+		return nil, fmt.Errorf("invalid atr initial character 0x%02X", data[0])
+	}
+
+	atr := &ATR{Raw: data, TS: data[0], T0: data[1]}
+	k := int(data[1] & 0x0F)
+	y := data[1] >> 4
+	pos := 2
+	needTCK := false
+	for {
+		for bit := byte(0x01); bit <= 0x08; bit <<= 1 {
+			if y&bit == 0 {
+				continue
+			}
+			if pos >= len(data) {
+				return nil, fmt.Errorf("atr truncated in interface bytes")
+			}
+			atr.Interface = append(atr.Interface, data[pos])
+			pos++
+		}
+		if y&0x08 == 0 {
+			break
+		}
+		td := atr.Interface[len(atr.Interface)-1]
+		proto := int(td & 0x0F)
+		atr.Protocols = append(atr.Protocols, proto)
+		if proto != 0 {
+			needTCK = true
+		}
+		y = td >> 4
+	}
+
+	if pos+k > len(data) {
+		return nil, fmt.Errorf("atr truncated in historical bytes")
+	}
+	atr.Historical = data[pos : pos+k]
+	pos += k
+
+	if needTCK {
+		if pos >= len(data) {
+			return nil, fmt.Errorf("atr missing check character")
+		}
+		atr.TCK = data[pos]
+		atr.HasTCK = true
+		var x byte
+		for _, b := range data[1 : pos+1] {
+			x ^= b
+		}
+		if x != 0 {
+			return nil, fmt.Errorf("atr check character mismatch")
+		}
+		pos++
+	}
+	if pos != len(data) {
+		return nil, fmt.Errorf("atr has %d unexpected trailing bytes", len(data)-pos)
+	}
+	return atr, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package iso7816
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseATR(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      []byte
+		protocols  []int
+		historical []byte
+		wantErr    bool
+	}{
+		{
+			name: "NTAG215 via ACR122U",
+			input: []byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00,
+				0x03, 0x06, 0x03, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x68},
+			protocols:  []int{0, 1},
+			historical: []byte{0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06, 0x03, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:       "T=0 without TCK",
+			input:      []byte{0x3B, 0x02, 0x14, 0x50},
+			historical: []byte{0x14, 0x50},
+		},
+		{
+			name: "bad TCK",
+			input: []byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00,
+				0x03, 0x06, 0x03, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00},
+			wantErr: true,
+		},
+		{
+			name:    "truncated",
+			input:   []byte{0x3B, 0x8F, 0x80},
+			wantErr: true,
+		},
+		{
+			name:    "invalid TS",
+			input:   []byte{0x00, 0x00},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atr, err := ParseATR(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseATR() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !bytes.Equal(atr.Historical, tt.historical) {
+				t.Errorf("Historical = % X, want % X", atr.Historical, tt.historical)
+			}
+			if len(atr.Protocols) != len(tt.protocols) {
+				t.Fatalf("Protocols = %v, want %v", atr.Protocols, tt.protocols)
+			}
+			for i := range tt.protocols {
+				if atr.Protocols[i] != tt.protocols[i] {
+					t.Errorf("Protocols = %v, want %v", atr.Protocols, tt.protocols)
+				}
+			}
+		})
+	}
+}
+
+func FuzzParseATR(f *testing.F) {
+	f.Add([]byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x6A})
+	f.Add([]byte{0x3B, 0x81, 0x80, 0x01, 0x80, 0x80})
+	f.Add([]byte{0x3F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		atr, err := ParseATR(data)
+		if err != nil {
+			return
+		}
+		if len(data) > MaxATRLen || 2+len(atr.Interface)+len(atr.Historical) > len(data) || len(atr.Protocols) > len(atr.Interface) {
+			t.Fatalf("ParseATR(% X) = %+v", data, atr)
+		}
+	})
+}
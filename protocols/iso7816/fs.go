@@ -0,0 +1,358 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package iso7816
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
+// Selection methods of SELECT, sent in P1.
+const (
+	SelectByFID        = 0x00
+	SelectChildDF      = 0x01
+	SelectChildEF      = 0x02
+	SelectParentDF     = 0x03
+	SelectByName       = 0x04
+	SelectByPathFromMF = 0x08
+	SelectByPathFromDF = 0x09
+)
+
+// Responses requested from SELECT, sent in P2.
+const (
+	ReturnFCI  = 0x00
+	ReturnFCP  = 0x04
+	ReturnFMD  = 0x08
+	ReturnNone = 0x0C
+)
+
+// FIDMasterFile is the file identifier of the master file.
+const FIDMasterFile = 0x3F00
+
+// maxOffset is the largest offset READ BINARY and UPDATE BINARY address
+// with an even instruction byte; P1 bit 8 flags a short EF identifier.
+const maxOffset = 0x7FFF
+
+// Select sends SELECT with the selection method p1 and response p2 for id,
+// which is a file identifier, DF name or path depending on p1, and returns
+// the response data.
+func Select(tr apdu.Transceiver, p1, p2 byte, id []byte) ([]byte, error) {
+	cmd := &CommandAPDU{Ins: INSSelect, P1: p1, P2: p2, Data: id}
+	if p2&ReturnNone != ReturnNone {
+		cmd.Ne = 256
+	}
+	return transmit(tr, cmd)
+}
+
+// SelectAID selects the application named aid and returns its FCI.
+func SelectAID(tr apdu.Transceiver, aid []byte) ([]byte, error) {
+	return Select(tr, SelectByName, ReturnFCI, aid)
+}
+
+// SelectFID selects the file with identifier fid.
+func SelectFID(tr apdu.Transceiver, fid uint16) error {
+	_, err := Select(tr, SelectByFID, ReturnNone, []byte{byte(fid >> 8), byte(fid)})
+	return err
+}
+
+// SelectPath selects the file at path from the master file. A leading
+// FIDMasterFile in path is skipped.
+func SelectPath(tr apdu.Transceiver, path ...uint16) error {
+	if len(path) > 0 && path[0] == FIDMasterFile {
+		path = path[1:]
+	}
+	if len(path) == 0 {
+		return SelectFID(tr, FIDMasterFile)
+	}
+	id := make([]byte, 0, 2*len(path))
+	for _, fid := range path {
+		id = append(id, byte(fid>>8), byte(fid))
+	}
+	_, err := Select(tr, SelectByPathFromMF, ReturnNone, id)
+	return err
+}
+
+// Binary reads and updates the transparent EF currently selected, splitting
+// transfers into as many commands as needed.
+type Binary struct {
+	Transceiver apdu.Transceiver
+	// MaxRead is the most bytes requested by one READ BINARY, 256 when
+	// zero. Larger values need a card supporting extended length.
+	MaxRead int
+	// MaxWrite is the most bytes sent by one UPDATE BINARY, 255 when zero.
+	MaxWrite int
+}
+
+// ReadBinary reads n bytes at offset of the transparent EF currently
+// selected.
+func ReadBinary(tr apdu.Transceiver, offset, n int) ([]byte, error) {
+	return Binary{Transceiver: tr}.Read(offset, n)
+}
+
+// UpdateBinary writes data at offset of the transparent EF currently
+// selected.
+func UpdateBinary(tr apdu.Transceiver, offset int, data []byte) error {
+	return Binary{Transceiver: tr}.Write(offset, data)
+}
+
+// Read reads n bytes at offset. When the file ends first, it returns the
+// bytes read and io.ErrUnexpectedEOF.
+func (f Binary) Read(offset, n int) ([]byte, error) {
+	if offset < 0 || n < 0 || offset+n > maxOffset+1 {
+		return nil, fmt.Errorf("read of %d bytes at offset %d exceeds the file offsets", n, offset)
+	}
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		data, eof, err := f.read(offset+len(out), min(n-len(out), f.maxRead()))
+		out = append(out, data...)
+		if err != nil {
+			return out, err
+		}
+		if eof || len(data) == 0 {
+			return out, io.ErrUnexpectedEOF
+		}
+	}
+	return out, nil
+}
+
+// ReadAll reads the file from offset zero until its end, for files whose
+// size is not known.
+func (f Binary) ReadAll() ([]byte, error) {
+	var out []byte
+	for {
+		if len(out) > maxOffset {
+			return out, fmt.Errorf("file exceeds %d bytes", maxOffset+1)
+		}
+		chunk := min(maxOffset+1-len(out), f.maxRead())
+		data, eof, err := f.read(len(out), chunk)
+		out = append(out, data...)
+		if err != nil || eof || len(data) < chunk {
+			return out, err
+		}
+	}
+}
+
+// read sends one READ BINARY. It reports eof when the card signals the end
+// of the file, with warning 6282 or an offset beyond the end (6B00).
+func (f Binary) read(offset, n int) ([]byte, bool, error) {
+	cmd := &CommandAPDU{Ins: INSReadBinary, P1: byte(offset >> 8), P2: byte(offset), Ne: n}
+	data, sw, err := exchange(f.Transceiver, cmd)
+	switch {
+	case err != nil:
+		return nil, false, err
+	case sw == 0x9000:
+		return data, false, nil
+	case sw == 0x6282:
+		return data, true, nil
+	case sw == 0x6B00:
+		return nil, true, nil
+	}
+	return nil, false, &apdu.StatusError{SW1: byte(sw >> 8), SW2: byte(sw)}
+}
+
+// Write writes data at offset.
+func (f Binary) Write(offset int, data []byte) error {
+	if offset < 0 || offset+len(data) > maxOffset+1 {
+		return fmt.Errorf("write of %d bytes at offset %d exceeds the file offsets", len(data), offset)
+	}
+	size := f.MaxWrite
+	if size <= 0 {
+		size = 255
+	}
+	for len(data) > 0 {
+		chunk := data[:min(len(data), size)]
+		cmd := &CommandAPDU{Ins: INSUpdateBinary, P1: byte(offset >> 8), P2: byte(offset), Data: chunk}
+		if _, err := transmit(f.Transceiver, cmd); err != nil {
+			return fmt.Errorf("update binary at offset %d: %w", offset, err)
+		}
+		offset += len(chunk)
+		data = data[len(chunk):]
+	}
+	return nil
+}
+
+func (f Binary) maxRead() int {
+	if f.MaxRead <= 0 {
+		return 256
+	}
+	return f.MaxRead
+}
+
+// ReadRecord reads record rec of the EF with short identifier sfi, or of the
+// EF currently selected when sfi is zero.
+func ReadRecord(tr apdu.Transceiver, sfi byte, rec int) ([]byte, error) {
+	if sfi > 30 {
+		return nil, fmt.Errorf("short EF identifier %d out of range", sfi)
+	}
+	if rec < 1 || rec > 254 {
+		return nil, fmt.Errorf("record number %d out of range", rec)
+	}
+	return transmit(tr, &CommandAPDU{Ins: INSReadRecord, P1: byte(rec), P2: sfi<<3 | 0x04, Ne: 256})
+}
+
+// ReadRecords calls fn with the records 1, 2, ... of the EF with short
+// identifier sfi, or of the EF currently selected when sfi is zero, until
+// the card reports no further record (6A83) or fn returns an error.
+func ReadRecords(tr apdu.Transceiver, sfi byte, fn func(rec int, data []byte) error) error {
+	for rec := 1; rec <= 254; rec++ {
+		data, err := ReadRecord(tr, sfi, rec)
+		var se *apdu.StatusError
+		if errors.As(err, &se) && se.SW1 == 0x6A && se.SW2 == 0x83 {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read record %d: %w", rec, err)
+		}
+		if err := fn(rec, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FileControl holds the file control information returned by SELECT: the
+// parameters of an FCP template (62), the management data of an FMD
+// template (64) or both, in an FCI template (6F).
+type FileControl struct {
+	// Template is the tag of the parsed template.
+	Template Tag
+	// FID is the file identifier (83).
+	FID uint16
+	// Name is the DF name (84), e.g. the AID of an application.
+	Name []byte
+	// Size is the number of data bytes of a transparent EF (80), zero
+	// when not given.
+	Size int
+	// Allocated is the number of bytes allocated to the file (81).
+	Allocated int
+	// Descriptor is the file descriptor (82): the file descriptor byte,
+	// optionally followed by the data coding byte, the maximum record size
+	// and the number of records.
+	Descriptor []byte
+	// SFI is the short EF identifier (88), zero when not given.
+	SFI byte
+	// LifeCycle is the life cycle status byte (8A).
+	LifeCycle byte
+	// Proprietary is the value of the proprietary template (A5) or data
+	// (85), e.g. the application data of an EMV FCI.
+	Proprietary []byte
+	// Objects are all data objects of the template.
+	Objects []TLV
+}
+
+// IsDF reports whether the file descriptor marks a dedicated file.
+func (fc *FileControl) IsDF() bool {
+	return len(fc.Descriptor) > 0 && fc.Descriptor[0]&0x3F == 0x38
+}
+
+// Transparent reports whether the file descriptor marks a transparent EF.
+func (fc *FileControl) Transparent() bool {
+	return len(fc.Descriptor) > 0 && fc.Descriptor[0]&0x3F == 0x01
+}
+
+// ParseFileControl parses the FCP, FMD or FCI template returned by SELECT.
+func ParseFileControl(b []byte) (*FileControl, error) {
+	tlvs, err := ParseTLV(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(tlvs) == 0 {
+		return nil, fmt.Errorf("no file control template")
+	}
+	t := tlvs[0]
+	if t.Tag != 0x62 && t.Tag != 0x64 && t.Tag != 0x6F {
+		return nil, fmt.Errorf("tag %X is not a file control template", uint32(t.Tag))
+	}
+	fc := &FileControl{Template: t.Tag}
+	if err := fc.parse(t, 0); err != nil {
+		return nil, err
+	}
+	return fc, nil
+}
+
+// parse fills fc from the data objects of the template t. FCP and FMD
+// templates nested in an FCI template are flattened.
+func (fc *FileControl) parse(t TLV, depth int) error {
+	children, err := t.Children()
+	if err != nil {
+		return err
+	}
+	for _, c := range children {
+		fc.Objects = append(fc.Objects, c)
+		switch c.Tag {
+		case 0x62, 0x64:
+			if depth > 0 {
+				return fmt.Errorf("nested file control template %X", uint32(c.Tag))
+			}
+			if err := fc.parse(c, depth+1); err != nil {
+				return err
+			}
+		case 0x80:
+			fc.Size = beInt(c.Value)
+		case 0x81:
+			fc.Allocated = beInt(c.Value)
+		case 0x82:
+			fc.Descriptor = c.Value
+		case 0x83:
+			if len(c.Value) != 2 {
+				return fmt.Errorf("file identifier of %d bytes", len(c.Value))
+			}
+			fc.FID = uint16(c.Value[0])<<8 | uint16(c.Value[1])
+		case 0x84:
+			fc.Name = c.Value
+		case 0x85, 0xA5:
+			fc.Proprietary = c.Value
+		case 0x88:
+			if len(c.Value) > 0 {
+				fc.SFI = c.Value[0] >> 3
+			}
+		case 0x8A:
+			if len(c.Value) > 0 {
+				fc.LifeCycle = c.Value[0]
+			}
+		}
+	}
+	return nil
+}
+
+// beInt decodes a big-endian unsigned number of up to four bytes.
+func beInt(b []byte) int {
+	n := 0
+	for _, c := range b[:min(len(b), 4)] {
+		n = n<<8 | int(c)
+	}
+	return n
+}
+
+// exchange sends cmd, collecting response data the card announces with
+// 61 XX, and returns the response data and status words.
+func exchange(tr apdu.Transceiver, cmd *CommandAPDU) ([]byte, uint16, error) {
+	b, err := cmd.Marshal()
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := apdu.Wrap(tr, apdu.GetResponse()).Transmit(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp) < 2 {
+		return nil, 0, fmt.Errorf("response apdu of %d bytes", len(resp))
+	}
+	n := len(resp) - 2
+	return resp[:n], uint16(resp[n])<<8 | uint16(resp[n+1]), nil
+}
+
+// transmit sends cmd and returns the response data, or an *apdu.StatusError
+// unless the card answered 9000.
+func transmit(tr apdu.Transceiver, cmd *CommandAPDU) ([]byte, error) {
+	data, sw, err := exchange(tr, cmd)
+	if err == nil && sw != 0x9000 {
+		err = &apdu.StatusError{SW1: byte(sw >> 8), SW2: byte(sw)}
+	}
+	return data, err
+}
@@ -0,0 +1,246 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package iso7816
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
+// fsCard is a card with one transparent EF, 3F00/E104, and one record EF
+// with short identifier 1, answering in T=0 fashion with 61 XX.
+type fsCard struct {
+	binary   []byte
+	records  [][]byte
+	selected uint16
+	cmds     [][]byte
+	pending  []byte
+}
+
+func (c *fsCard) Transmit(cmd []byte) ([]byte, error) {
+	c.cmds = append(c.cmds, cmd)
+	if cmd[1] == INSGetResponse {
+		data := c.pending
+		c.pending = nil
+		return append(data, 0x90, 0x00), nil
+	}
+	parsed, err := UnmarshalCommandAPDU(cmd)
+	if err != nil {
+		return nil, err
+	}
+	switch parsed.Ins {
+	case INSSelect:
+		switch {
+		case parsed.P1 == SelectByName && bytes.Equal(parsed.Data, unhex("D2760000850101")):
+			c.pending = unhex("6F0E8407D2760000850101A503880108")
+			return []byte{0x61, byte(len(c.pending))}, nil
+		case parsed.P1 == SelectByFID && bytes.Equal(parsed.Data, []byte{0xE1, 0x04}),
+			parsed.P1 == SelectByPathFromMF && bytes.Equal(parsed.Data, []byte{0xE1, 0x04}):
+			c.selected = 0xE104
+			return []byte{0x90, 0x00}, nil
+		}
+		return []byte{0x6A, 0x82}, nil
+	case INSReadBinary:
+		off := int(parsed.P1)<<8 | int(parsed.P2)
+		if off >= len(c.binary) {
+			return []byte{0x6B, 0x00}, nil
+		}
+		end := min(off+parsed.Ne, len(c.binary))
+		if end-off < parsed.Ne {
+			return append(append([]byte(nil), c.binary[off:end]...), 0x62, 0x82), nil
+		}
+		return append(append([]byte(nil), c.binary[off:end]...), 0x90, 0x00), nil
+	case INSUpdateBinary:
+		off := int(parsed.P1)<<8 | int(parsed.P2)
+		if off+len(parsed.Data) > len(c.binary) {
+			return []byte{0x6B, 0x00}, nil
+		}
+		copy(c.binary[off:], parsed.Data)
+		return []byte{0x90, 0x00}, nil
+	case INSReadRecord:
+		if parsed.P2 != 1<<3|0x04 {
+			return []byte{0x6A, 0x82}, nil
+		}
+		if int(parsed.P1) > len(c.records) {
+			return []byte{0x6A, 0x83}, nil
+		}
+		return append(append([]byte(nil), c.records[parsed.P1-1]...), 0x90, 0x00), nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+func TestSelect(t *testing.T) {
+	c := &fsCard{}
+	fci, err := SelectAID(c, unhex("D2760000850101"))
+	if err != nil {
+		t.Fatalf("SelectAID() error = %v", err)
+	}
+	fc, err := ParseFileControl(fci)
+	if err != nil || !bytes.Equal(fc.Name, unhex("D2760000850101")) || fc.Template != 0x6F {
+		t.Errorf("ParseFileControl(%X) = %+v, %v", fci, fc, err)
+	}
+	if got := c.cmds[0]; !bytes.Equal(got, unhex("00A4040007D276000085010100")) {
+		t.Errorf("SELECT = %X", got)
+	}
+
+	tests := []struct {
+		name string
+		sel  func() error
+		want string
+	}{
+		{"fid", func() error { return SelectFID(c, 0xE104) }, "00A4000C02E104"},
+		{"path", func() error { return SelectPath(c, FIDMasterFile, 0xE104) }, "00A4080C02E104"},
+	}
+	for _, tt := range tests {
+		c.cmds, c.selected = nil, 0
+		if err := tt.sel(); err != nil || c.selected != 0xE104 {
+			t.Errorf("%s: error = %v, selected %04X", tt.name, err, c.selected)
+		}
+		if got := c.cmds[0]; !bytes.Equal(got, unhex(tt.want)) {
+			t.Errorf("%s: SELECT = %X, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	var se *apdu.StatusError
+	if err := SelectFID(c, 0x0001); !errors.As(err, &se) || se.SW1 != 0x6A || se.SW2 != 0x82 {
+		t.Errorf("SelectFID(missing) error = %v", err)
+	}
+}
+
+func TestBinary(t *testing.T) {
+	content := make([]byte, 600)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	tests := []struct {
+		name    string
+		maxRead int
+		offset  int
+		n       int
+		cmds    int
+		err     error
+	}{
+		{"chunked", 0, 0, 600, 3, nil},
+		{"small chunks", 100, 10, 300, 3, nil},
+		{"offset", 0, 590, 10, 1, nil},
+		{"past end", 0, 500, 200, 1, io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		c := &fsCard{binary: content}
+		got, err := Binary{Transceiver: c, MaxRead: tt.maxRead}.Read(tt.offset, tt.n)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: Read() error = %v, want %v", tt.name, err, tt.err)
+		}
+		if want := content[tt.offset:min(tt.offset+tt.n, len(content))]; !bytes.Equal(got, want) {
+			t.Errorf("%s: Read() = %d bytes, want %d", tt.name, len(got), len(want))
+		}
+		if len(c.cmds) != tt.cmds {
+			t.Errorf("%s: sent %d commands, want %d", tt.name, len(c.cmds), tt.cmds)
+		}
+	}
+
+	for _, size := range []int{0, 256, 600} {
+		c := &fsCard{binary: content[:size]}
+		if got, err := (Binary{Transceiver: c}).ReadAll(); err != nil || !bytes.Equal(got, content[:size]) {
+			t.Errorf("ReadAll() of %d bytes = %d bytes, %v", size, len(got), err)
+		}
+	}
+
+	if _, err := ReadBinary(&fsCard{}, 0x7F00, 0x200); err == nil {
+		t.Error("ReadBinary() past offset 7FFF succeeded")
+	}
+
+	c := &fsCard{binary: make([]byte, 600)}
+	if err := (Binary{Transceiver: c, MaxWrite: 200}).Write(50, content[:500]); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if len(c.cmds) != 3 || !bytes.Equal(c.binary[50:550], content[:500]) {
+		t.Errorf("Write() sent %d commands, file %X", len(c.cmds), c.binary[:60])
+	}
+	if err := UpdateBinary(c, 590, content[:20]); err == nil {
+		t.Error("UpdateBinary() past the end succeeded")
+	}
+}
+
+func TestReadRecords(t *testing.T) {
+	c := &fsCard{records: [][]byte{{0x70, 0x01, 0x01}, {0x70, 0x01, 0x02}, {0x70, 0x01, 0x03}}}
+	var got [][]byte
+	err := ReadRecords(c, 1, func(rec int, data []byte) error {
+		if rec != len(got)+1 {
+			t.Errorf("record %d after %d records", rec, len(got))
+		}
+		got = append(got, data)
+		return nil
+	})
+	if err != nil || len(got) != 3 || !bytes.Equal(got[2], c.records[2]) {
+		t.Errorf("ReadRecords() = %X, %v", got, err)
+	}
+	if !bytes.Equal(c.cmds[0], unhex("00B2010C00")) {
+		t.Errorf("READ RECORD = %X", c.cmds[0])
+	}
+
+	stop := errors.New("stop")
+	if err := ReadRecords(c, 1, func(int, []byte) error { return stop }); err != stop {
+		t.Errorf("ReadRecords() error = %v, want %v", err, stop)
+	}
+	if err := ReadRecords(c, 2, func(int, []byte) error { return nil }); err == nil {
+		t.Error("ReadRecords() of a missing file succeeded")
+	}
+	if _, err := ReadRecord(c, 31, 1); err == nil {
+		t.Error("ReadRecord() with SFI 31 succeeded")
+	}
+}
+
+func TestParseFileControl(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want FileControl
+		df   bool
+		ef   bool
+	}{
+		{
+			name: "fcp transparent",
+			in:   "62198002020082010183020E10880110" + "8A0105" + "8102040085020102",
+			want: FileControl{Template: 0x62, FID: 0x0E10, Size: 0x200, Allocated: 0x400, SFI: 2, LifeCycle: 5},
+			ef:   true,
+		},
+		{
+			name: "fci of df",
+			in:   "6F186213820138830231008407A00000000310108A0105" + "A5015A",
+			want: FileControl{Template: 0x6F, FID: 0x3100, LifeCycle: 5},
+			df:   true,
+		},
+		{
+			name: "fmd",
+			in:   "64055303010203",
+			want: FileControl{Template: 0x64},
+		},
+	}
+	for _, tt := range tests {
+		fc, err := ParseFileControl(unhex(tt.in))
+		if err != nil {
+			t.Errorf("%s: ParseFileControl() error = %v", tt.name, err)
+			continue
+		}
+		if fc.Template != tt.want.Template || fc.FID != tt.want.FID || fc.Size != tt.want.Size ||
+			fc.Allocated != tt.want.Allocated || fc.SFI != tt.want.SFI || fc.LifeCycle != tt.want.LifeCycle {
+			t.Errorf("%s: ParseFileControl() = %+v, want %+v", tt.name, fc, tt.want)
+		}
+		if fc.IsDF() != tt.df || fc.Transparent() != tt.ef {
+			t.Errorf("%s: IsDF() = %v, Transparent() = %v", tt.name, fc.IsDF(), fc.Transparent())
+		}
+	}
+
+	for _, bad := range []string{"", "5A0112", "620383020E", "6F0462026200"} {
+		if _, err := ParseFileControl(unhex(bad)); err == nil {
+			t.Errorf("ParseFileControl(%s) succeeded", bad)
+		}
+	}
+}
@@ -7,39 +7,142 @@
 // file system operations, security mechanisms, and communication protocols.
 package iso7816
 
+import (
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
 const (
 	// Constants for ISO 7816 specific values, e.g., instruction codes
-	INSReadBinary = 0xB0
-	// ...
+	INSSelect       = 0xA4
+	INSReadBinary   = 0xB0
+	INSReadRecord   = 0xB2
+	INSGetResponse  = 0xC0
+	INSUpdateBinary = 0xD6
 )
 
-// NewCommandAPDU creates a new ISO 7816 Command APDU.
-func NewCommandAPDU(cla, ins, p1, p2, le byte, data []byte) *CommandAPDU { return nil }
+// NewCommandAPDU creates a new ISO 7816 Command APDU. A zero le creates a
+// command without Le field.
+func NewCommandAPDU(cla, ins, p1, p2, le byte, data []byte) *CommandAPDU {
+	return &CommandAPDU{Cla: cla, Ins: ins, P1: p1, P2: p2, Data: data, Ne: int(le)}
+}
 
-// UnmarshalCommandAPDU parses a byte slice into a CommandAPDU.
-func UnmarshalCommandAPDU(data []byte) (*CommandAPDU, error) { return nil, nil }
+// UnmarshalCommandAPDU parses a byte slice into a CommandAPDU. Short and
+// extended length commands of all four cases are accepted.
+func UnmarshalCommandAPDU(data []byte) (*CommandAPDU, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("command apdu of %d bytes", len(data))
+	}
+	cmd := &CommandAPDU{Cla: data[0], Ins: data[1], P1: data[2], P2: data[3]}
+	body := data[4:]
+	switch {
+	case len(body) == 0: // Case 1
+	case len(body) == 1: // Case 2S
+		cmd.Ne = decodeNe(int(body[0]), 256)
+	case body[0] != 0: // Case 3S, 4S
+		lc := int(body[0])
+		switch len(body) {
+		case 1 + lc:
+		case 2 + lc:
+			cmd.Ne = decodeNe(int(body[1+lc]), 256)
+		default:
+			return nil, fmt.Errorf("command apdu body of %d bytes does not match Lc %d", len(body), lc)
+		}
+		cmd.Data = body[1 : 1+lc]
+	case len(body) == 3: // Case 2E
+		cmd.Ne = decodeNe(int(body[1])<<8|int(body[2]), 65536)
+	default: // Case 3E, 4E
+		if len(body) < 3 {
+			return nil, fmt.Errorf("truncated extended command apdu")
+		}
+		lc := int(body[1])<<8 | int(body[2])
+		switch len(body) {
+		case 3 + lc:
+		case 5 + lc:
+			cmd.Ne = decodeNe(int(body[3+lc])<<8|int(body[4+lc]), 65536)
+		default:
+			return nil, fmt.Errorf("command apdu body of %d bytes does not match Lc %d", len(body), lc)
+		}
+		cmd.Data = body[3 : 3+lc]
+	}
+	return cmd, nil
+}
+
+func decodeNe(le, zero int) int {
+	if le == 0 {
+		return zero
+	}
+	return le
+}
 
 // NewResponseAPDU creates a new ISO 7816 Response APDU.
-func NewResponseAPDU(data []byte, sw1, sw2 byte) *ResponseAPDU { return nil }
+func NewResponseAPDU(data []byte, sw1, sw2 byte) *ResponseAPDU {
+	return &ResponseAPDU{Data: data, SW1: sw1, SW2: sw2}
+}
 
 // UnmarshalResponseAPDU parses a byte slice into a ResponseAPDU.
-func UnmarshalResponseAPDU(data []byte) (*ResponseAPDU, error) { return nil, nil }
+func UnmarshalResponseAPDU(data []byte) (*ResponseAPDU, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("response apdu of %d bytes", len(data))
+	}
+	n := len(data) - 2
+	return &ResponseAPDU{Data: data[:n], SW1: data[n], SW2: data[n+1]}, nil
+}
 
 // CheckResponseStatus interprets the SW1 and SW2 status words of a response APDU.
-func CheckResponseStatus(sw1, sw2 byte) error { return nil }
+func CheckResponseStatus(sw1, sw2 byte) error { return apdu.CheckStatus(sw1, sw2) }
 
 // CommandAPDU represents an ISO 7816 command APDU structure.
 type CommandAPDU struct {
-	// Fields like Cla, Ins, P1, P2, Data, Le
+	Cla, Ins, P1, P2 byte
+	Data             []byte
+	// Ne is the maximum number of response bytes expected, zero when the
+	// command has no Le field.
+	Ne int
 }
 
-// Marshal serializes a CommandAPDU into bytes.
-func (cmd *CommandAPDU) Marshal() ([]byte, error) { return nil, nil }
+// Extended reports whether the command requires extended length fields.
+func (cmd *CommandAPDU) Extended() bool { return len(cmd.Data) > 255 || cmd.Ne > 256 }
+
+// Marshal serializes a CommandAPDU into bytes, using extended length fields
+// only when required.
+func (cmd *CommandAPDU) Marshal() ([]byte, error) {
+	if len(cmd.Data) > 65535 || cmd.Ne < 0 || cmd.Ne > 65536 {
+		return nil, fmt.Errorf("command apdu exceeds extended length limits")
+	}
+	out := []byte{cmd.Cla, cmd.Ins, cmd.P1, cmd.P2}
+	if !cmd.Extended() {
+		if len(cmd.Data) > 0 {
+			out = append(append(out, byte(len(cmd.Data))), cmd.Data...)
+		}
+		if cmd.Ne > 0 {
+			out = append(out, byte(cmd.Ne))
+		}
+		return out, nil
+	}
+	if len(cmd.Data) > 0 {
+		out = append(append(out, 0x00, byte(len(cmd.Data)>>8), byte(len(cmd.Data))), cmd.Data...)
+	}
+	if cmd.Ne > 0 {
+		if len(cmd.Data) == 0 {
+			out = append(out, 0x00)
+		}
+		out = append(out, byte(cmd.Ne>>8), byte(cmd.Ne))
+	}
+	return out, nil
+}
 
 // ResponseAPDU represents an ISO 7816 response APDU structure.
 type ResponseAPDU struct {
-	// Fields like Data, SW1, SW2
+	Data     []byte
+	SW1, SW2 byte
 }
 
+// SW returns the status words as a single value, e.g. 0x9000.
+func (resp *ResponseAPDU) SW() uint16 { return uint16(resp.SW1)<<8 | uint16(resp.SW2) }
+
 // Marshal serializes a ResponseAPDU into bytes.
-func (resp *ResponseAPDU) Marshal() ([]byte, error) { return nil, nil }
+func (resp *ResponseAPDU) Marshal() ([]byte, error) {
+	return append(append([]byte(nil), resp.Data...), resp.SW1, resp.SW2), nil
+}
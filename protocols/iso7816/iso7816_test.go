@@ -0,0 +1,45 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package iso7816
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCommandAPDU(t *testing.T) {
+	tests := []struct {
+		hex      string
+		dataLen  int
+		ne       int
+		extended bool
+	}{
+		{"00A40000", 0, 0, false},
+		{"00B0000000", 0, 256, false},
+		{"00A4020C02011E", 2, 0, false},
+		{"00A4040007D276000085010100", 7, 256, false},
+		{"00B00000000200", 0, 512, true},
+		{"00D60000000100" + hex.EncodeToString(make([]byte, 256)), 256, 0, true},
+	}
+	for _, tt := range tests {
+		raw, _ := hex.DecodeString(tt.hex)
+		cmd, err := UnmarshalCommandAPDU(raw)
+		if err != nil {
+			t.Fatalf("UnmarshalCommandAPDU(%.16s) error = %v", tt.hex, err)
+		}
+		if len(cmd.Data) != tt.dataLen || cmd.Ne != tt.ne || cmd.Extended() != tt.extended {
+			t.Errorf("UnmarshalCommandAPDU(%.16s) = data %d, Ne %d, extended %v", tt.hex, len(cmd.Data), cmd.Ne, cmd.Extended())
+		}
+		got, err := cmd.Marshal()
+		if err != nil || !bytes.Equal(got, raw) {
+			t.Errorf("Marshal() = %X, %v; want %.16s", got, err, tt.hex)
+		}
+	}
+
+	if _, err := UnmarshalCommandAPDU([]byte{0x00, 0xA4, 0x00, 0x00, 0x05, 0x01}); err == nil {
+		t.Error("UnmarshalCommandAPDU() accepted a body shorter than Lc")
+	}
+}
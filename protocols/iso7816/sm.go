@@ -0,0 +1,261 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package iso7816
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/crypto"
+)
+
+// ErrSecureMessaging is returned for response APDUs failing secure messaging
+// verification, e.g. with a missing or wrong MAC.
+var ErrSecureMessaging = errors.New("secure messaging verification failed")
+
+// SecureMessaging protects command APDUs and verifies and decrypts response
+// APDUs following ISO/IEC 7816-4 secure messaging as profiled by ICAO 9303
+// and BSI TR-03110: data in DO'87' (DO'85' for odd instructions), Le in
+// DO'97', status words in DO'99' and a cryptographic checksum in DO'8E'
+// computed over the send sequence counter (SSC) and the protected data.
+// Only short length APDUs are supported.
+type SecureMessaging struct {
+	mu        sync.Mutex
+	enc       cipher.Block
+	mac       func(msg []byte) ([]byte, error)
+	ssc       []byte
+	aesIV     bool // IV is E(Kenc, SSC) rather than zero.
+	blockSize int
+}
+
+// NewSecureMessaging3DES returns secure messaging with two-key 3DES session
+// keys, retail MAC and an 8 byte SSC, as established by BAC.
+func NewSecureMessaging3DES(kenc, kmac, ssc []byte) (*SecureMessaging, error) {
+	if len(kenc) != 16 || len(kmac) != 16 || len(ssc) != des.BlockSize {
+		return nil, fmt.Errorf("3des secure messaging requires 16 byte keys and an 8 byte ssc")
+	}
+	enc, err := des.NewTripleDESCipher(append(append([]byte(nil), kenc...), kenc[:8]...))
+	if err != nil {
+		return nil, err
+	}
+	kmac = append([]byte(nil), kmac...)
+	return &SecureMessaging{
+		enc:       enc,
+		mac:       func(msg []byte) ([]byte, error) { return crypto.RetailMAC(kmac, msg) },
+		ssc:       append([]byte(nil), ssc...),
+		blockSize: des.BlockSize,
+	}, nil
+}
+
+// NewSecureMessagingAES returns secure messaging with AES session keys, an
+// AES-CMAC truncated to 8 bytes and a 16 byte SSC, as established by PACE
+// or chip authentication.
+func NewSecureMessagingAES(kenc, kmac, ssc []byte) (*SecureMessaging, error) {
+	if len(ssc) != aes.BlockSize {
+		return nil, fmt.Errorf("aes secure messaging requires a 16 byte ssc")
+	}
+	enc, err := aes.NewCipher(kenc)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := aes.NewCipher(kmac)
+	if err != nil {
+		return nil, err
+	}
+	return &SecureMessaging{
+		enc:       enc,
+		mac:       func(msg []byte) ([]byte, error) { return crypto.CMAC(mac, msg)[:8], nil },
+		ssc:       append([]byte(nil), ssc...),
+		aesIV:     true,
+		blockSize: aes.BlockSize,
+	}, nil
+}
+
+// incrementSSC increments the send sequence counter. sm.mu must be held.
+func (sm *SecureMessaging) incrementSSC() {
+	for i := len(sm.ssc) - 1; i >= 0; i-- {
+		sm.ssc[i]++
+		if sm.ssc[i] != 0 {
+			return
+		}
+	}
+}
+
+func (sm *SecureMessaging) iv() []byte {
+	iv := make([]byte, sm.blockSize)
+	if sm.aesIV {
+		sm.enc.Encrypt(iv, sm.ssc)
+	}
+	return iv
+}
+
+func (sm *SecureMessaging) checksum(data []byte) ([]byte, error) {
+	return sm.mac(crypto.Pad(append(append([]byte(nil), sm.ssc...), data...), sm.blockSize))
+}
+
+// Protect returns cmd protected by secure messaging.
+func (sm *SecureMessaging) Protect(cmd []byte) ([]byte, error) {
+	c, err := UnmarshalCommandAPDU(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if c.Extended() {
+		return nil, fmt.Errorf("secure messaging of extended length apdus is not supported")
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.incrementSSC()
+
+	header := []byte{c.Cla | 0x0C, c.Ins, c.P1, c.P2}
+	var dos []byte
+	if len(c.Data) > 0 {
+		padded := crypto.Pad(c.Data, sm.blockSize)
+		cipher.NewCBCEncrypter(sm.enc, sm.iv()).CryptBlocks(padded, padded)
+		if c.Ins&0x01 == 0 {
+			dos = appendDO(dos, 0x87, append([]byte{0x01}, padded...))
+		} else {
+			dos = appendDO(dos, 0x85, padded)
+		}
+	}
+	if c.Ne > 0 {
+		dos = appendDO(dos, 0x97, []byte{byte(c.Ne)})
+	}
+	mac, err := sm.checksum(append(crypto.Pad(header, sm.blockSize), dos...))
+	if err != nil {
+		return nil, err
+	}
+	dos = appendDO(dos, 0x8E, mac)
+	if len(dos) > 255 {
+		return nil, fmt.Errorf("protected command data of %d bytes exceeds short length", len(dos))
+	}
+	return append(append(append(header, byte(len(dos))), dos...), 0x00), nil
+}
+
+// Unprotect verifies the response to a protected command and returns the
+// plain response APDU. Responses consisting of status words only, which
+// cards return when secure messaging itself fails, are returned unchanged.
+func (sm *SecureMessaging) Unprotect(resp []byte) ([]byte, error) {
+	if len(resp) < 2 {
+		return nil, fmt.Errorf("response apdu of %d bytes", len(resp))
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.incrementSSC()
+	if len(resp) == 2 {
+		return resp, nil
+	}
+
+	var data, sw, mac, macInput []byte
+	body := resp[:len(resp)-2]
+	for len(body) > 0 {
+		tag, value, n, err := parseDO(body)
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case 0x85, 0x87:
+			data = value
+		case 0x99:
+			sw = value
+		case 0x8E:
+			mac = value
+		}
+		if tag != 0x8E {
+			macInput = append(macInput, body[:n]...)
+		}
+		body = body[n:]
+	}
+	if mac == nil || len(sw) != 2 {
+		return nil, fmt.Errorf("%w: missing checksum or status", ErrSecureMessaging)
+	}
+	want, err := sm.checksum(macInput)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(mac, want) != 1 {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrSecureMessaging)
+	}
+
+	var plain []byte
+	if data != nil {
+		if len(data) > 0 && data[0] == 0x01 && len(data)%sm.blockSize == 1 {
+			data = data[1:]
+		}
+		if len(data) == 0 || len(data)%sm.blockSize != 0 {
+			return nil, fmt.Errorf("%w: malformed cryptogram", ErrSecureMessaging)
+		}
+		buf := make([]byte, len(data))
+		cipher.NewCBCDecrypter(sm.enc, sm.iv()).CryptBlocks(buf, data)
+		if plain, err = crypto.Unpad(buf); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSecureMessaging, err)
+		}
+	}
+	return append(plain, sw...), nil
+}
+
+// Middleware returns an apdu.Middleware protecting every command and
+// unprotecting every response, so drivers can run unchanged on top of an
+// established secure messaging session.
+func (sm *SecureMessaging) Middleware() apdu.Middleware {
+	return func(next apdu.Transceiver) apdu.Transceiver {
+		return apdu.TransceiverFunc(func(cmd []byte) ([]byte, error) {
+			protected, err := sm.Protect(cmd)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := next.Transmit(protected)
+			if err != nil {
+				return nil, err
+			}
+			return sm.Unprotect(resp)
+		})
+	}
+}
+
+// appendDO appends a data object with a one byte tag and BER length.
+func appendDO(b []byte, tag byte, value []byte) []byte {
+	b = append(b, tag)
+	switch {
+	case len(value) < 0x80:
+		b = append(b, byte(len(value)))
+	case len(value) <= 0xFF:
+		b = append(b, 0x81, byte(len(value)))
+	default:
+		b = append(b, 0x82, byte(len(value)>>8), byte(len(value)))
+	}
+	return append(b, value...)
+}
+
+// parseDO parses a data object with a one byte tag and BER length and
+// returns its total size.
+func parseDO(b []byte) (tag byte, value []byte, n int, err error) {
+	if len(b) < 2 {
+		return 0, nil, 0, fmt.Errorf("%w: truncated data object", ErrSecureMessaging)
+	}
+	l, hdr := int(b[1]), 2
+	switch b[1] {
+	case 0x81:
+		if len(b) < 3 {
+			return 0, nil, 0, fmt.Errorf("%w: truncated data object", ErrSecureMessaging)
+		}
+		l, hdr = int(b[2]), 3
+	case 0x82:
+		if len(b) < 4 {
+			return 0, nil, 0, fmt.Errorf("%w: truncated data object", ErrSecureMessaging)
+		}
+		l, hdr = int(b[2])<<8|int(b[3]), 4
+	}
+	if len(b) < hdr+l {
+		return 0, nil, 0, fmt.Errorf("%w: truncated data object", ErrSecureMessaging)
+	}
+	return b[0], b[hdr : hdr+l], hdr + l, nil
+}
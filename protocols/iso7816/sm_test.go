@@ -0,0 +1,105 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package iso7816
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/crypto"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// TestSecureMessaging3DES follows the worked example of ICAO Doc 9303
+// part 11 selecting EF.COM after BAC.
+func TestSecureMessaging3DES(t *testing.T) {
+	sm, err := NewSecureMessaging3DES(
+		unhex("979EC13B1CBFE9DCD01AB0FED307EAE5"),
+		unhex("F1CB1F1FB5ADF208806B89DC579DC1F8"),
+		unhex("887022120C06C226"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := sm.Protect(unhex("00A4020C02011E"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := unhex("0CA4020C158709016375432908C044F68E08BF8B92D635FF24F800"); !bytes.Equal(got, want) {
+		t.Errorf("Protect() = %X\nwant %X", got, want)
+	}
+
+	resp, err := sm.Unprotect(unhex("990290008E08FA855A5D4C50A8ED9000"))
+	if err != nil {
+		t.Fatalf("Unprotect() error = %v", err)
+	}
+	if !bytes.Equal(resp, []byte{0x90, 0x00}) {
+		t.Errorf("Unprotect() = %X, want 9000", resp)
+	}
+
+	if _, err := sm.Unprotect(unhex("990290008E08FA855A5D4C50A8ED9000")); !errors.Is(err, ErrSecureMessaging) {
+		t.Errorf("Unprotect() replayed response error = %v, want %v", err, ErrSecureMessaging)
+	}
+}
+
+// TestSecureMessagingAES runs a session through the middleware against a
+// card emulated by a second session sharing keys and SSC.
+func TestSecureMessagingAES(t *testing.T) {
+	kenc, kmac, ssc := bytes.Repeat([]byte{0x11}, 16), bytes.Repeat([]byte{0x22}, 16), make([]byte, 16)
+	terminal, err := NewSecureMessagingAES(kenc, kmac, ssc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	card, _ := NewSecureMessagingAES(kenc, kmac, ssc)
+
+	data := []byte("hello secure world")
+	var tamper bool
+	tr := apdu.Wrap(apdu.TransceiverFunc(func(cmd []byte) ([]byte, error) {
+		if cmd[0]&0x0C != 0x0C {
+			t.Errorf("command %X not marked as protected", cmd)
+		}
+		card.incrementSSC() // command
+		card.incrementSSC() // response
+		resp := sealResponse(card, data)
+		if tamper {
+			resp[3] ^= 0x01
+		}
+		return resp, nil
+	}), terminal.Middleware())
+
+	got, err := tr.Transmit(unhex("00B0000000"))
+	if err != nil {
+		t.Fatalf("Transmit() error = %v", err)
+	}
+	if want := append(append([]byte(nil), data...), 0x90, 0x00); !bytes.Equal(got, want) {
+		t.Errorf("Transmit() = %q, want %q", got, want)
+	}
+
+	tamper = true
+	if _, err := tr.Transmit(unhex("00B0000000")); !errors.Is(err, ErrSecureMessaging) {
+		t.Errorf("Transmit() tampered response error = %v, want %v", err, ErrSecureMessaging)
+	}
+}
+
+// sealResponse builds the protected response a card returns for data.
+func sealResponse(sm *SecureMessaging, data []byte) []byte {
+	padded := crypto.Pad(data, sm.blockSize)
+	cipher.NewCBCEncrypter(sm.enc, sm.iv()).CryptBlocks(padded, padded)
+	dos := appendDO(nil, 0x87, append([]byte{0x01}, padded...))
+	dos = appendDO(dos, 0x99, []byte{0x90, 0x00})
+	mac, _ := sm.checksum(dos)
+	return append(appendDO(dos, 0x8E, mac), 0x90, 0x00)
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package iso7816
+
+import (
+	"fmt"
+)
+
+// Tag is a BER-TLV tag of up to four bytes, e.g. 0x6F or 0x9F38.
+type Tag uint32
+
+// Constructed reports whether the data object contains nested objects.
+func (t Tag) Constructed() bool {
+	b := t
+	for b > 0xFF {
+		b >>= 8
+	}
+	return b&0x20 != 0
+}
+
+// TLV is a BER-TLV data object.
+type TLV struct {
+	Tag   Tag
+	Value []byte
+}
+
+// Children parses the value of a constructed data object.
+func (t TLV) Children() ([]TLV, error) {
+	if !t.Tag.Constructed() {
+		return nil, fmt.Errorf("tag %X is primitive", uint32(t.Tag))
+	}
+	return ParseTLV(t.Value)
+}
+
+// ParseTLV parses the BER-TLV data objects at the top level of b. Padding
+// bytes 00 and FF between objects are skipped.
+func ParseTLV(b []byte) ([]TLV, error) {
+	var out []TLV
+	for len(b) > 0 {
+		if b[0] == 0x00 || b[0] == 0xFF {
+			b = b[1:]
+			continue
+		}
+		t, n, err := parseOne(b)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+		b = b[n:]
+	}
+	return out, nil
+}
+
+// parseOne parses the data object at the start of b and returns its size.
+func parseOne(b []byte) (TLV, int, error) {
+	i := 0
+	tag := Tag(b[i])
+	if b[i]&0x1F == 0x1F {
+		for {
+			i++
+			if i >= len(b) || i > 3 {
+				return TLV{}, 0, fmt.Errorf("tlv tag truncated or too long")
+			}
+			tag = tag<<8 | Tag(b[i])
+			if b[i]&0x80 == 0 {
+				break
+			}
+		}
+	}
+	i++
+	if i >= len(b) {
+		return TLV{}, 0, fmt.Errorf("tlv %X missing length", uint32(tag))
+	}
+	l := int(b[i])
+	i++
+	if l&0x80 != 0 {
+		n := l & 0x7F
+		if n == 0 || n > 3 || i+n > len(b) {
+			return TLV{}, 0, fmt.Errorf("tlv %X has invalid length", uint32(tag))
+		}
+		l = 0
+		for _, c := range b[i : i+n] {
+			l = l<<8 | int(c)
+		}
+		i += n
+	}
+	if l > len(b)-i {
+		return TLV{}, 0, fmt.Errorf("tlv %X value truncated", uint32(tag))
+	}
+	return TLV{Tag: tag, Value: b[i : i+l]}, i + l, nil
+}
+
+// maxTLVDepth bounds the nesting FindTLV descends into, so hostile data
+// cannot make it recurse deeply.
+const maxTLVDepth = 16
+
+// FindTLV searches the data objects in b, descending into constructed
+// objects depth first, and returns the value of the first object with tag.
+// Objects nested deeper than 16 levels are not searched.
+func FindTLV(b []byte, tag Tag) ([]byte, bool) {
+	return findTLV(b, tag, maxTLVDepth)
+}
+
+func findTLV(b []byte, tag Tag, depth int) ([]byte, bool) {
+	tlvs, err := ParseTLV(b)
+	if err != nil {
+		return nil, false
+	}
+	for _, t := range tlvs {
+		if t.Tag == tag {
+			return t.Value, true
+		}
+		if t.Tag.Constructed() && depth > 1 {
+			if v, ok := findTLV(t.Value, tag, depth-1); ok {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// AppendTLV appends the data object tag with value to b.
+func AppendTLV(b []byte, tag Tag, value []byte) []byte {
+	switch {
+	case tag > 0xFFFFFF:
+		b = append(b, byte(tag>>24), byte(tag>>16), byte(tag>>8), byte(tag))
+	case tag > 0xFFFF:
+		b = append(b, byte(tag>>16), byte(tag>>8), byte(tag))
+	case tag > 0xFF:
+		b = append(b, byte(tag>>8), byte(tag))
+	default:
+		b = append(b, byte(tag))
+	}
+	switch l := len(value); {
+	case l < 0x80:
+		b = append(b, byte(l))
+	case l <= 0xFF:
+		b = append(b, 0x81, byte(l))
+	case l <= 0xFFFF:
+		b = append(b, 0x82, byte(l>>8), byte(l))
+	default:
+		b = append(b, 0x83, byte(l>>16), byte(l>>8), byte(l))
+	}
+	return append(b, value...)
+}
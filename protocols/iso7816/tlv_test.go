@@ -0,0 +1,93 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package iso7816
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseTLV(t *testing.T) {
+	// PPSE FCI with one directory entry.
+	fci := unhex("6F2C840E325041592E5359532E4444463031A51ABF0C1761154F07A0000000031010500A564953412044454249549F0A00")
+	tlvs, err := ParseTLV(fci[:len(fci)-3])
+	if err != nil {
+		t.Fatalf("ParseTLV() error = %v", err)
+	}
+	if len(tlvs) != 1 || tlvs[0].Tag != 0x6F || !tlvs[0].Tag.Constructed() {
+		t.Fatalf("ParseTLV() = %+v", tlvs)
+	}
+	if aid, ok := FindTLV(fci[:len(fci)-3], 0x4F); !ok || !bytes.Equal(aid, unhex("A0000000031010")) {
+		t.Errorf("FindTLV(4F) = %X, %v", aid, ok)
+	}
+	if label, ok := FindTLV(fci[:len(fci)-3], 0x50); !ok || string(label) != "VISA DEBIT" {
+		t.Errorf("FindTLV(50) = %q, %v", label, ok)
+	}
+	if _, ok := FindTLV(fci[:len(fci)-3], 0x5A); ok {
+		t.Error("FindTLV(5A) found a missing tag")
+	}
+
+	for _, bad := range []string{"9F", "5A05010203", "5A84000000010000", "5F"} {
+		if _, err := ParseTLV(unhex(bad)); err == nil {
+			t.Errorf("ParseTLV(%s) succeeded", bad)
+		}
+	}
+}
+
+func TestAppendTLV(t *testing.T) {
+	long := bytes.Repeat([]byte{0xAA}, 300)
+	tests := []struct {
+		tag   Tag
+		value []byte
+	}{
+		{0x5A, []byte{0x12, 0x34}},
+		{0x9F38, []byte{0x9F, 0x66, 0x04}},
+		{0x5F2D, bytes.Repeat([]byte{'e'}, 200)},
+		{0xBF0C, long},
+	}
+	for _, tt := range tests {
+		b := AppendTLV(nil, tt.tag, tt.value)
+		tlvs, err := ParseTLV(b)
+		if err != nil || len(tlvs) != 1 || tlvs[0].Tag != tt.tag || !bytes.Equal(tlvs[0].Value, tt.value) {
+			t.Errorf("round trip of %X failed: %+v, %v", uint32(tt.tag), tlvs, err)
+		}
+	}
+}
+
+func TestFindTLVDepth(t *testing.T) {
+	b := []byte{0x5A, 0x01, 0x42}
+	for i := 0; i < maxTLVDepth; i++ {
+		b = AppendTLV(nil, 0x70, b)
+	}
+	if _, ok := FindTLV(b, 0x5A); ok {
+		t.Errorf("FindTLV() found a tag nested %d levels deep", maxTLVDepth+1)
+	}
+	if v, ok := FindTLV(b[2:], 0x5A); !ok || !bytes.Equal(v, []byte{0x42}) {
+		t.Errorf("FindTLV() = %X, %v", v, ok)
+	}
+}
+
+func FuzzParseTLV(f *testing.F) {
+	f.Add(unhex("6F2C840E325041592E5359532E4444463031A51ABF0C1761154F07A0000000031010500A564953412044454249549F0A00"))
+	f.Add(unhex("5A84000000010000"))
+	f.Add(unhex("9F1F8201000000"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		FindTLV(b, 0x4F)
+		tlvs, err := ParseTLV(b)
+		if err != nil {
+			return
+		}
+		n := 0
+		for _, tlv := range tlvs {
+			n += len(tlv.Value)
+			if tlv.Tag.Constructed() {
+				_, _ = tlv.Children()
+			}
+		}
+		if n > len(b) {
+			t.Fatalf("ParseTLV(% X) values total %d bytes", b, n)
+		}
+	})
+}
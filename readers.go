@@ -0,0 +1,56 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"fmt"
+
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+)
+
+// ReaderInfo is a snapshot of the state of a reader.
+type ReaderInfo struct {
+	Name       string
+	State      pcsc.State // State flags reported by the resource manager.
+	ATR        []byte     // ATR of the card in the reader, if any.
+	EventCount int        // Card insertions and removals seen by the reader.
+}
+
+// Present reports whether a card is in the reader.
+func (r ReaderInfo) Present() bool { return r.State&pcsc.StatePresent != 0 }
+
+// Empty reports whether the reader holds no card.
+func (r ReaderInfo) Empty() bool { return r.State&pcsc.StateEmpty != 0 }
+
+// Mute reports whether the card in the reader does not answer to reset.
+func (r ReaderInfo) Mute() bool { return r.State&pcsc.StateMute != 0 }
+
+// Exclusive reports whether the card is connected in exclusive mode.
+func (r ReaderInfo) Exclusive() bool { return r.State&pcsc.StateExclusive != 0 }
+
+// InUse reports whether the card is connected by an application.
+func (r ReaderInfo) InUse() bool { return r.State&pcsc.StateInUse != 0 }
+
+// Readers returns a snapshot of the live state of the selected readers, for
+// dashboards and health checks. Backends which do not report reader states
+// only fill in the reader names.
+func (sdk *SDK) Readers() ([]ReaderInfo, error) {
+	all, err := sdk.backend.ListReaders()
+	if err != nil {
+		return nil, fmt.Errorf("list readers: %w", err)
+	}
+	readers := sdk.SelectReaders(all)
+	if len(readers) == 0 {
+		return nil, nil
+	}
+	if b, ok := sdk.backend.(readerStater); ok {
+		return b.readerStates(readers)
+	}
+	infos := make([]ReaderInfo, len(readers))
+	for i, r := range readers {
+		infos[i] = ReaderInfo{Name: r.Name}
+	}
+	return infos, nil
+}
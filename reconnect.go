@@ -0,0 +1,81 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// WithReconnectPolicy makes the SDK reconnect to cards which are reset or
+// briefly lost while it or a handler exchanges APDUs with them through the
+// CardContext, see pcsc.Card.TransmitReconnect. Cards of other backends
+// are reconnected when they implement pcsc.Reconnector. Reconnects are
+// reported to the metrics.
+func WithReconnectPolicy(p pcsc.ReconnectPolicy) Option {
+	return func(sdk *SDK) {
+		sdk.reconnect = p
+	}
+}
+
+// reconnector is a card which can be reconnected.
+type reconnector interface {
+	transport.Card
+	Reconnect(init pcsc.Disposition) error
+}
+
+// reconnectingCard transmits following the reconnect policy of the SDK.
+type reconnectingCard struct {
+	reconnector
+	policy pcsc.ReconnectPolicy
+}
+
+func (c reconnectingCard) Transmit(cmd []byte) ([]byte, error) {
+	return c.policy.Transmit(c.reconnector, cmd)
+}
+
+func (c reconnectingCard) TransmitContext(ctx context.Context, cmd []byte) ([]byte, error) {
+	return c.policy.Transmit(contextCard{reconnector: c.reconnector, ctx: ctx}, cmd)
+}
+
+// TransmitHook returns the transmit hook of the card, if any, so
+// instrumentedCard leaves reporting exchanges to it.
+func (c reconnectingCard) TransmitHook() pcsc.TransmitHook {
+	if h, ok := c.reconnector.(interface{ TransmitHook() pcsc.TransmitHook }); ok {
+		return h.TransmitHook()
+	}
+	return nil
+}
+
+// contextCard transmits to a card with ctx.
+type contextCard struct {
+	reconnector
+	ctx context.Context
+}
+
+func (c contextCard) Transmit(cmd []byte) ([]byte, error) {
+	return transport.TransmitContext(c.ctx, c.reconnector, cmd)
+}
+
+// reconnecting returns card transmitting with the reconnect policy of the
+// SDK, or card itself when the policy is disabled or card cannot be
+// reconnected.
+func (sdk *SDK) reconnecting(card transport.Card, reader string) transport.Card {
+	rc, ok := card.(reconnector)
+	if !ok || sdk.reconnect.Attempts == 0 {
+		return card
+	}
+	p := sdk.reconnect
+	onReconnect := p.OnReconnect
+	p.OnReconnect = func(cause error) {
+		sdk.metrics.Reconnected(reader)
+		if onReconnect != nil {
+			onReconnect(cause)
+		}
+	}
+	return reconnectingCard{reconnector: rc, policy: p}
+}
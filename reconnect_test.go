@@ -0,0 +1,91 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+	"github.com/happy-sdk/scardkit/x/replay"
+)
+
+// cardBackend presents card once on the reader "gate".
+type cardBackend struct{ card transport.Card }
+
+func (b *cardBackend) ListReaders() ([]cardreader.Reader, error) {
+	return []cardreader.Reader{{Name: "gate"}}, nil
+}
+
+func (b *cardBackend) WaitCard(ctx context.Context, readers []cardreader.Reader, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	card := b.card
+	b.card = nil
+	if card == nil {
+		<-ctx.Done()
+		return nil, cardreader.Reader{}, ctx.Err()
+	}
+	return card, readers[0], nil
+}
+
+func TestRunReconnects(t *testing.T) {
+	readUID := replay.Hex{0xFF, 0xCA, 0x00, 0x00, 0x00}
+	tests := []struct {
+		name    string
+		replay  func(cmd []byte) bool
+		wantErr error
+	}{
+		{"replayed", pcsc.ReplayStateless, nil},
+		{"not replayed", nil, pcsc.ErrCardReset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchanges := []replay.Exchange{
+				{Op: replay.OpUID, Response: replay.Hex{0x04, 0xA1, 0xB2, 0xC3}},
+				{Command: readUID, Code: "card-reset", Error: "card was reset"},
+				{Op: replay.OpReconnect, Disposition: pcsc.ResetCard},
+			}
+			if tt.replay != nil {
+				exchanges = append(exchanges, replay.Exchange{Command: readUID, Response: replay.Hex{0x04, 0xA1, 0xB2, 0xC3, 0x90, 0x00}})
+			}
+			card := replay.NewCard(&replay.Capture{Reader: "gate", ATR: replay.Hex{0x3B, 0x80, 0x80, 0x01, 0x01}, Exchanges: exchanges})
+			reconnects := 0
+			policy := pcsc.ReconnectPolicy{Attempts: 1, Init: pcsc.ResetCard, Replay: tt.replay, OnReconnect: func(error) { reconnects++ }}
+
+			var resp []byte
+			var err error
+			handled := make(chan struct{})
+			sdk := New(WithBackend(&cardBackend{card: card}), WithReconnectPolicy(policy),
+				WithCardHandler(CardFunc(func(c *CardContext) error {
+					defer close(handled)
+					resp, err = c.Transmit(readUID)
+					return nil
+				})))
+			runErr := make(chan error, 1)
+			go func() { runErr <- sdk.Run(context.Background()) }()
+			<-handled
+			if err := sdk.Shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := <-runErr; err != nil {
+				t.Errorf("Run() error = %v", err)
+			}
+			if tt.wantErr == nil && (err != nil || !bytes.Equal(resp, []byte{0x04, 0xA1, 0xB2, 0xC3, 0x90, 0x00})) {
+				t.Errorf("Transmit() = % X, %v; want replayed response", resp, err)
+			}
+			var rerr *pcsc.ReconnectedError
+			if tt.wantErr != nil && (!errors.As(err, &rerr) || !errors.Is(err, tt.wantErr)) {
+				t.Errorf("Transmit() error = %v, want *pcsc.ReconnectedError wrapping %v", err, tt.wantErr)
+			}
+			if reconnects != 1 || card.Remaining() != 0 {
+				t.Errorf("%d reconnects with %d exchanges left, want 1 and 0", reconnects, card.Remaining())
+			}
+		})
+	}
+}
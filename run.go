@@ -0,0 +1,213 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+)
+
+// ErrClosed is returned by Run, WaitForCard, ReadNDEF and WriteNDEF after
+// Shutdown.
+var ErrClosed = errors.New("sdk is shut down")
+
+// errRunning is returned by Run when it is already running.
+var errRunning = errors.New("sdk is already running")
+
+// WithMaxConcurrentSessions lets Run handle cards of up to n readers at a
+// time, each in its own goroutine. A reader is not monitored while its card
+// is handled. Values below one are ignored; by default cards are handled
+// one at a time.
+func WithMaxConcurrentSessions(n int) Option {
+	return func(sdk *SDK) {
+		if n > 0 {
+			sdk.maxSessions = n
+		}
+	}
+}
+
+// Run monitors the selected readers and passes each card to the handler
+// registered for it until ctx is done, returning ctx.Err(), or Shutdown is
+// called, returning nil. Handlers run with ctx, so Shutdown lets them
+// finish. Errors of handlers and cards in readers held by another
+// application, see ReaderBusyError, are logged. Failures of single readers
+// end Run unless WithReaderFailurePolicy says otherwise. Run returns once its
+// handlers returned. Only one Run may be active at a time.
+func (sdk *SDK) Run(ctx context.Context) error {
+	sdk.mu.Lock()
+	if sdk.closed {
+		sdk.mu.Unlock()
+		return ErrClosed
+	}
+	if sdk.stopRun != nil {
+		sdk.mu.Unlock()
+		return errRunning
+	}
+	monitor, cancel := context.WithCancelCause(ctx)
+	sdk.stopRun = cancel
+	sdk.failed = nil
+	sdk.mu.Unlock()
+
+	sessions := make(chan struct{}, sdk.maxSessions)
+	var workers sync.WaitGroup
+	defer func() {
+		cancel(nil)
+		workers.Wait()
+		sdk.mu.Lock()
+		sdk.stopRun = nil
+		sdk.mu.Unlock()
+		sdk.setState(StateStopped)
+	}()
+	if sdk.watchdog > 0 {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			sdk.watch(monitor, sdk.watchdog)
+		}()
+	}
+
+	for {
+		select {
+		case sessions <- struct{}{}:
+		case <-monitor.Done():
+			return sdk.stopped(monitor)
+		}
+		card, reader, err := sdk.waitCard(monitor)
+		var busy *ReaderBusyError
+		if errors.As(err, &busy) {
+			<-sessions
+			sdk.logger.Warn("card not connected", "reader", busy.Reader, "error", err)
+			sdk.setState(StateRecovering)
+			continue
+		}
+		var failed *ReaderError
+		if errors.As(err, &failed) && sdk.failurePolicy == ContinueOthers && monitor.Err() == nil {
+			<-sessions
+			sdk.logger.Warn("reader failed", "reader", failed.Reader, "error", err)
+			sdk.readerFailed(failed)
+			sdk.setState(StateRecovering)
+			continue
+		}
+		if err != nil {
+			<-sessions
+			if monitor.Err() != nil {
+				return sdk.stopped(monitor)
+			}
+			return sdk.withReaderErrors(err)
+		}
+		if sdk.Paused() {
+			<-sessions
+			sdk.logger.Debug("card ignored while paused", "reader", reader.Name)
+			if err := card.Disconnect(); err != nil {
+				sdk.logger.Warn("disconnect ignored card", "reader", reader.Name, "error", err)
+			}
+			continue
+		}
+		sdk.setBusy(reader.Name, true)
+		workers.Add(1)
+		go func() {
+			defer func() {
+				sdk.setBusy(reader.Name, false)
+				<-sessions
+				workers.Done()
+			}()
+			// Handler and disconnect errors are logged by handleCard with
+			// the session.
+			_, _ = sdk.handleCard(ctx, card, reader, true)
+		}()
+	}
+}
+
+// stopped returns the result of Run once monitor is done.
+func (sdk *SDK) stopped(monitor context.Context) error {
+	if context.Cause(monitor) == ErrClosed {
+		return sdk.withReaderErrors(nil)
+	}
+	return sdk.withReaderErrors(monitor.Err())
+}
+
+// setBusy marks the reader name as having a card session in flight.
+func (sdk *SDK) setBusy(name string, busy bool) {
+	sdk.mu.Lock()
+	defer sdk.mu.Unlock()
+	if sdk.busy == nil {
+		sdk.busy = make(map[string]bool)
+	}
+	if busy {
+		sdk.busy[name] = true
+	} else {
+		delete(sdk.busy, name)
+	}
+}
+
+// idleReaders returns readers without a card session in flight, filtering
+// readers in place.
+func (sdk *SDK) idleReaders(readers []cardreader.Reader) []cardreader.Reader {
+	sdk.mu.RLock()
+	defer sdk.mu.RUnlock()
+	if len(sdk.busy) == 0 {
+		return readers
+	}
+	idle := readers[:0]
+	for _, r := range readers {
+		if !sdk.busy[r.Name] {
+			idle = append(idle, r)
+		}
+	}
+	return idle
+}
+
+// Shutdown stops Run and waits for card handlers in flight and for the
+// events buffered for event sinks until ctx is done. It then releases the
+// backend, failing the handlers still running, and returns the errors of
+// the release together with ctx.Err() when handlers or sinks were still
+// running. Later calls of Run and WaitForCard return ErrClosed.
+func (sdk *SDK) Shutdown(ctx context.Context) error {
+	sdk.mu.Lock()
+	closed := sdk.closed
+	sdk.closed = true
+	if sdk.stopRun != nil {
+		sdk.stopRun(ErrClosed)
+	}
+	sdk.mu.Unlock()
+	sdk.refreshState()
+
+	done := make(chan struct{})
+	go func() {
+		sdk.inflight.Wait()
+		close(done)
+	}()
+	var errs []error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("card handlers still running: %w", ctx.Err()))
+	}
+	if !closed {
+		if err := sdk.closeSinks(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c, ok := sdk.backend.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("release backend: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// discardHandler is a slog.Handler discarding all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
@@ -0,0 +1,139 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+func TestShutdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		slow    bool // Handler outlives the Shutdown deadline.
+		wantErr error
+	}{
+		{"handler finishes", false, nil},
+		{"deadline exceeded", true, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &fakeBackend{reader: cardreader.Reader{Name: "virtual"}, card: &memCard{}}
+			entered := make(chan struct{}, 1)
+			release := make(chan struct{})
+			sdk := New(WithBackend(b), WithCardHandler(func(context.Context, cardreader.Event, transport.Card) error {
+				select {
+				case entered <- struct{}{}:
+				default:
+				}
+				<-release
+				return nil
+			}))
+
+			runErr := make(chan error, 1)
+			go func() { runErr <- sdk.Run(context.Background()) }()
+			<-entered
+
+			if !tt.slow {
+				time.AfterFunc(10*time.Millisecond, func() { close(release) })
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			err := sdk.Shutdown(ctx)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Shutdown() error = %v, want %v", err, tt.wantErr)
+			}
+			if !b.closed.Load() {
+				t.Error("Shutdown() did not release the backend")
+			}
+			if tt.slow {
+				close(release)
+			}
+			if err := <-runErr; err != nil {
+				t.Errorf("Run() error = %v", err)
+			}
+			if err := sdk.Run(context.Background()); !errors.Is(err, ErrClosed) {
+				t.Errorf("Run() after Shutdown error = %v, want ErrClosed", err)
+			}
+		})
+	}
+}
+
+func TestRunLogsDisconnectErrors(t *testing.T) {
+	var logs syncBuffer
+	b := &fakeBackend{reader: cardreader.Reader{Name: "virtual"}, card: &memCard{disconnectErr: errors.New("reader gone")}}
+	handled := make(chan struct{}, 1)
+	sdk := New(WithBackend(b), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		WithCardHandler(func(context.Context, cardreader.Event, transport.Card) error {
+			select {
+			case handled <- struct{}{}:
+			default:
+			}
+			return nil
+		}))
+	runErr := make(chan error, 1)
+	go func() { runErr <- sdk.Run(context.Background()) }()
+	<-handled
+	if err := sdk.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-runErr; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+	if out := logs.String(); !strings.Contains(out, "disconnect card") || !strings.Contains(out, "reader gone") {
+		t.Errorf("logs = %s", out)
+	}
+}
+
+// multiBackend has a card on each of its readers.
+type multiBackend struct{ readers []cardreader.Reader }
+
+func (b *multiBackend) ListReaders() ([]cardreader.Reader, error) { return b.readers, nil }
+
+func (b *multiBackend) WaitCard(ctx context.Context, readers []cardreader.Reader, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, cardreader.Reader{}, err
+	}
+	return &memCard{}, readers[0], nil
+}
+
+func TestMaxConcurrentSessions(t *testing.T) {
+	b := &multiBackend{readers: []cardreader.Reader{{Name: "r0"}, {Name: "r1"}, {Name: "r2"}}}
+	var mu sync.Mutex
+	active, peak := map[string]bool{}, 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sdk := New(WithBackend(b), WithMaxConcurrentSessions(2), WithStatusPollTimeout(time.Millisecond),
+		WithCardHandler(func(_ context.Context, ev cardreader.Event, _ transport.Card) error {
+			mu.Lock()
+			if active[ev.Reader] {
+				t.Errorf("concurrent sessions on %s", ev.Reader)
+			}
+			active[ev.Reader] = true
+			peak = max(peak, len(active))
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			delete(active, ev.Reader)
+			mu.Unlock()
+			return nil
+		}))
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if err := sdk.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+	if peak != 2 {
+		t.Errorf("peak concurrent sessions = %d, want 2", peak)
+	}
+}
@@ -11,15 +11,162 @@
 // and simplicity in mind.
 package scardkit
 
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// DefaultStatusPollTimeout is the default timeout of a single reader status
+// poll, bounding how long shutdown may be delayed by a blocked poll.
+const DefaultStatusPollTimeout = time.Second
+
 // New initializes a new instance of the smart card SDK.
-func New() *SDK { return nil }
+func New(opts ...Option) *SDK {
+	sdk := &SDK{
+		statusPollTimeout: DefaultStatusPollTimeout,
+		backend:           &pcscBackend{},
+		maxSessions:       1,
+		history:           newRing(DefaultHistorySize),
+		baseLogger:        slog.New(discardHandler{}),
+		metrics:           nopMetrics{},
+		tracer:            nopTracer{},
+		detector:          tag.Default,
+		sinkStop:          make(chan struct{}),
+	}
+	sdk.sinkCtx, sdk.sinkCancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(sdk)
+	}
+	sdk.logger, sdk.wireLogger = sdk.Logger(LogSDK), sdk.Logger(LogPCSC)
+	if b, ok := sdk.backend.(*pcscBackend); ok {
+		b.onChange = sdk.recordState
+		b.retry = sdk.connectRetry
+	}
+	return sdk
+}
 
-// Run executes a provided Command and returns a Response.
-func (sdk *SDK) Run(cmd Command) (Response, error) { return nil, nil }
+// Option configures the SDK.
+type Option func(sdk *SDK)
+
+// WithStatusPollTimeout sets the timeout of a single reader status poll.
+// Values not greater than zero are ignored.
+func WithStatusPollTimeout(d time.Duration) Option {
+	return func(sdk *SDK) {
+		if d > 0 {
+			sdk.statusPollTimeout = d
+		}
+	}
+}
+
+// WithLogger sets the logger of the SDK. By default nothing is logged.
+// See WithLogLevels for the levels of its subsystems.
+func WithLogger(l *slog.Logger) Option {
+	return func(sdk *SDK) {
+		if l != nil {
+			sdk.baseLogger = l
+		}
+	}
+}
 
 // SDK represents the smart card toolkit with common functionalities.
 type SDK struct {
 	// Fields for SDK configuration and state
+	mu             sync.RWMutex
+	backend        transport.Backend
+	baseLogger     *slog.Logger
+	logLevels      map[string]slog.Level
+	logger         *slog.Logger // Subsystem LogSDK.
+	wireLogger     *slog.Logger // Subsystem LogPCSC.
+	readerSelect   cardreader.ReaderSelectFunc
+	cardHandler    CardHandler
+	typeHandlers   map[tag.Type]CardHandler
+	atrHandlers    []atrHandler
+	detector       *tag.Detector // See WithDetector.
+	filterHandlers []filterHandler
+	metrics        Metrics
+	tracer         Tracer
+	reconnect      pcsc.ReconnectPolicy
+	identities     cardreader.IdentityResolver // See WithIdentityResolver.
+	zones          *cardreader.Zones           // See WithZones.
+	connectRetry   pcsc.BusyRetry
+
+	statusPollTimeout time.Duration
+	sessionTimeout    time.Duration // Deadline of card sessions, see WithSessionTimeout.
+
+	// Run and Shutdown state, guarded by mu.
+	stopRun     context.CancelCauseFunc
+	closed      bool
+	inflight    sync.WaitGroup  // Card handlers running.
+	sessions    int             // Number of card handlers running.
+	maxSessions int             // Card handlers Run may run at a time.
+	busy        map[string]bool // Readers with a card handler running.
+	tapDebounce time.Duration
+	lastTaps    map[string]tap // Last card seen per reader, for tap debouncing.
+	paused      atomic.Bool    // Run ignores cards, see Pause.
+
+	failurePolicy ReaderFailurePolicy
+	failed        map[string]bool // Readers failed under ContinueOthers, until detached.
+	readerErrs    []error         // Errors of the failed readers, returned by Run.
+
+	sinks        []*sinkQueue // Event sinks, see WithEventSink.
+	journal      Journal
+	watchdog     time.Duration           // Interval of reader health checks, see WithWatchdog.
+	health       map[string]ReaderHealth // Guarded by mu.
+	sinkMu       sync.RWMutex            // Guards sending to and closing the sink queues.
+	sinksClosed  bool                    // Sink queues closed by Shutdown, guarded by sinkMu.
+	sinkStop     chan struct{}           // Closed by Shutdown to stop Replay.
+	sinkCtx      context.Context         // Context of Publish, see closeSinks.
+	sinkCancel   context.CancelFunc
+	history      *ring
+	knownReaders map[string]bool // Readers of the last reader list, for the history.
+	tracked      map[string]*cardreader.Reader
+
+	stateMu   sync.Mutex // Guards state and baseState, held while emitting their changes.
+	state     State      // Lifecycle state, see State.
+	baseState State      // State of the reader monitoring, before handlers and Shutdown.
+}
+
+// SetReaderSelect replaces the callback selecting which readers the SDK uses.
+// It may be called at any time, also while the SDK is in use; the new callback
+// applies to every selection made after SetReaderSelect returns. A nil fn
+// restores the default of using all available readers.
+func (sdk *SDK) SetReaderSelect(fn cardreader.ReaderSelectFunc) {
+	sdk.mu.Lock()
+	defer sdk.mu.Unlock()
+	sdk.readerSelect = fn
+}
+
+// SelectReaders applies the current reader-select callback to readers,
+// leaving out deselected readers. The callback is read once per call, so a
+// concurrent SetReaderSelect never affects a selection that is already in
+// progress.
+func (sdk *SDK) SelectReaders(readers []cardreader.Reader) []cardreader.Reader {
+	return sdk.selectReaders(nil, sdk.trackReaders(nil, readers))
+}
+
+// selectReaders appends the selected readers among the tracked readers to
+// dst. Without a reader-select callback no other slice is allocated.
+func (sdk *SDK) selectReaders(dst, tracked []cardreader.Reader) []cardreader.Reader {
+	sdk.mu.RLock()
+	fn := sdk.readerSelect
+	sdk.mu.RUnlock()
+	if fn != nil {
+		tracked = fn(tracked)
+	}
+	for _, r := range tracked {
+		if r.Selected() {
+			dst = append(dst, r)
+		}
+	}
+	return dst
 }
 
 // Command represents a generic command interface that can be implemented by different card protocols.
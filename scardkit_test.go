@@ -0,0 +1,42 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+)
+
+func TestSDKSetReaderSelectConcurrent(t *testing.T) {
+	sdk := New()
+	readers := []cardreader.Reader{{Name: "reader 00"}, {Name: "reader 01"}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			sdk.SetReaderSelect(cardreader.SelectReaderByIndex(i % 2))
+		}(i)
+		go func() {
+			defer wg.Done()
+			if got := sdk.SelectReaders(readers); len(got) == 0 {
+				t.Error("SelectReaders() selected no readers")
+			}
+		}()
+	}
+	wg.Wait()
+
+	sdk.SetReaderSelect(cardreader.SelectReaderByName("reader 01"))
+	if got := sdk.SelectReaders(readers); len(got) != 1 || got[0].Name != "reader 01" {
+		t.Errorf("SelectReaders() = %v, want [reader 01]", got)
+	}
+	sdk.SetReaderSelect(nil)
+	if got := sdk.SelectReaders(readers); len(got) != 2 {
+		t.Errorf("SelectReaders() with default = %v, want all readers", got)
+	}
+}
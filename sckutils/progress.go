@@ -0,0 +1,18 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package sckutils
+
+// ProgressFunc reports the progress of a long running operation, such as a
+// full tag dump or a large NDEF read or write, as bytes done out of total.
+// It is called after each transferred chunk so consumers can render progress
+// bars and detect stalls.
+type ProgressFunc func(done, total int)
+
+// Report calls fn if it is not nil.
+func (fn ProgressFunc) Report(done, total int) {
+	if fn != nil {
+		fn(done, total)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+)
+
+// trackReaders replaces the readers of a reader list with the copies first
+// seen by the SDK, so their selection state and last card outlive the list
+// they were reported in. Newly seen readers report their selection changes
+// to the SDK. The copies are appended to dst.
+func (sdk *SDK) trackReaders(dst, readers []cardreader.Reader) []cardreader.Reader {
+	sdk.mu.Lock()
+	defer sdk.mu.Unlock()
+	if sdk.tracked == nil {
+		sdk.tracked = make(map[string]*cardreader.Reader)
+	}
+	for _, r := range readers {
+		t, ok := sdk.tracked[r.Name]
+		if !ok {
+			t = r.Track()
+			t.OnSelectionChange(sdk.selectionChanged)
+			sdk.tracked[r.Name] = t
+		}
+		dst = append(dst, *t)
+	}
+	return dst
+}
+
+// selectionChanged records and logs a selection change of a reader.
+func (sdk *SDK) selectionChanged(r cardreader.Reader, selected bool) error {
+	kind, msg := HistoryReaderDeselected, "reader deselected"
+	if selected {
+		kind, msg = HistoryReaderSelected, "reader selected"
+	}
+	sdk.history.add(HistoryEntry{Reader: r.Name, Kind: kind})
+	sdk.logger.Info(msg, "reader", r.Name)
+	return nil
+}
+
+// recordCard records info as the last card of the tracked copy of reader,
+// so LookupReader and the reader lists report it whichever copy of the
+// reader the backend returned with the card.
+func (sdk *SDK) recordCard(reader cardreader.Reader, info cardreader.CardInfo) {
+	sdk.mu.RLock()
+	t, ok := sdk.tracked[reader.Name]
+	sdk.mu.RUnlock()
+	if !ok {
+		t = &reader
+	}
+	t.RecordCard(info)
+}
+
+// LookupReader returns the reader named name as tracked by the SDK, whose
+// Select and Deselect methods control whether the SDK uses it. Readers are
+// tracked from the first time the backend lists them.
+func (sdk *SDK) LookupReader(name string) (*cardreader.Reader, error) {
+	sdk.mu.RLock()
+	r, ok := sdk.tracked[name]
+	sdk.mu.RUnlock()
+	if ok {
+		return r, nil
+	}
+	all, err := sdk.backend.ListReaders()
+	if err != nil {
+		return nil, fmt.Errorf("list readers: %w", err)
+	}
+	sdk.trackReaders(nil, all)
+	sdk.mu.RLock()
+	defer sdk.mu.RUnlock()
+	if r, ok := sdk.tracked[name]; ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("reader %q not found", name)
+}
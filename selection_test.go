@@ -0,0 +1,56 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+)
+
+func TestLookupReaderSelection(t *testing.T) {
+	b := &fakeBackend{reader: *cardreader.NewReader("virtual"), card: &memCard{}}
+	sdk := New(WithBackend(b), WithStatusPollTimeout(10*time.Millisecond))
+
+	r, err := sdk.LookupReader("virtual")
+	if err != nil {
+		t.Fatalf("LookupReader() error = %v", err)
+	}
+	if _, err := sdk.LookupReader("missing"); err == nil {
+		t.Error("LookupReader() found a missing reader")
+	}
+	if err := r.Deselect(); err != nil {
+		t.Fatalf("Deselect() error = %v", err)
+	}
+	if infos, err := sdk.Readers(); err != nil || len(infos) != 0 {
+		t.Errorf("Readers() with the reader deselected = %v, %v", infos, err)
+	}
+	if _, err := sdk.WaitForCard(context.Background(), 30*time.Millisecond); !errors.Is(err, pcsc.ErrTimeout) {
+		t.Errorf("WaitForCard() on a deselected reader error = %v", err)
+	}
+
+	// A fresh listing of the same reader keeps its selection state.
+	b.reader = *cardreader.NewReader("virtual")
+	if err := r.Select(); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if _, err := sdk.WaitForCard(context.Background(), time.Second); err != nil {
+		t.Errorf("WaitForCard() after Select() error = %v", err)
+	}
+
+	var kinds []HistoryKind
+	for _, e := range sdk.History() {
+		if e.Kind == HistoryReaderSelected || e.Kind == HistoryReaderDeselected {
+			kinds = append(kinds, e.Kind)
+		}
+	}
+	if len(kinds) != 2 || kinds[0] != HistoryReaderDeselected || kinds[1] != HistoryReaderSelected {
+		t.Errorf("selection history = %v", kinds)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// newSessionID returns a random identifier of a card session.
+func newSessionID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// newCardEvent returns the card inserted event of card starting a new
+// session, tagged with the zone of its reader.
+func (sdk *SDK) newCardEvent(card transport.Card, reader cardreader.Reader) cardreader.Event {
+	ev := cardreader.Event{
+		Type:      cardreader.EventCardInserted,
+		Reader:    reader.Name,
+		Zone:      sdk.zoneOf(reader.Name),
+		ATR:       card.ATR(),
+		Time:      time.Now(),
+		SessionID: newSessionID(),
+	}
+	if uid, err := transport.UID(card); err == nil {
+		ev.UID = uid
+	}
+	return ev
+}
+
+// sessionGroup returns the "session" group logged with all card lifecycle
+// records: session_id, reader, uid and, when the card reports it, protocol.
+// Extra attributes, such as the duration, are added to the group.
+func sessionGroup(ev cardreader.Event, card transport.Card, extra ...slog.Attr) slog.Attr {
+	attrs := []any{
+		slog.String("session_id", ev.SessionID),
+		slog.String("reader", ev.Reader),
+		slog.String("uid", hex.EncodeToString(ev.UID)),
+	}
+	if c, ok := card.(interface{ Protocol() pcsc.Protocol }); ok {
+		attrs = append(attrs, slog.String("protocol", c.Protocol().String()))
+	}
+	for _, a := range extra {
+		attrs = append(attrs, a)
+	}
+	return slog.Group("session", attrs...)
+}
+
+// logSession logs the start of the card session of ev and returns a function
+// logging its end with the duration and the error, if any, of the session.
+func (sdk *SDK) logSession(ev cardreader.Event, card transport.Card) func(err error) {
+	sdk.logger.Debug("card session started", sessionGroup(ev, card))
+	return func(err error) {
+		group := sessionGroup(ev, card, slog.Duration("duration", time.Since(ev.Time)))
+		if err != nil {
+			sdk.logger.Warn("card session failed", group, slog.Any("error", err))
+			return
+		}
+		sdk.logger.Debug("card session ended", group)
+	}
+}
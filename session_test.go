@@ -0,0 +1,74 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+func TestSessionLogs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	b := &fakeBackend{reader: *cardreader.NewReader("virtual"), card: &memCard{}}
+	errHandler := errors.New("handler failed")
+	sdk := New(WithBackend(b), WithLogger(logger), WithCardHandler(func(context.Context, cardreader.Event, transport.Card) error {
+		return errHandler
+	}))
+	ev, err := sdk.WaitForCard(context.Background(), time.Second)
+	if !errors.Is(err, errHandler) {
+		t.Fatalf("WaitForCard() error = %v", err)
+	}
+	if len(ev.SessionID) != 16 {
+		t.Errorf("SessionID = %q", ev.SessionID)
+	}
+
+	type session struct {
+		ID       string `json:"session_id"`
+		Reader   string `json:"reader"`
+		UID      string `json:"uid"`
+		Duration *int64 `json:"duration"`
+	}
+	type record struct {
+		Msg     string  `json:"msg"`
+		Session session `json:"session"`
+		Error   string  `json:"error"`
+	}
+	var records []record
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r record
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	want := []string{"card session started", "card session failed"}
+	if len(records) != len(want) {
+		t.Fatalf("logged %d records, want %d: %+v", len(records), len(want), records)
+	}
+	for i, r := range records {
+		if r.Msg != want[i] || r.Session.ID != ev.SessionID || r.Session.Reader != "virtual" || r.Session.UID != "04a1b2c3" {
+			t.Errorf("record %d = %+v", i, r)
+		}
+	}
+	if end := records[1]; end.Session.Duration == nil || end.Error != errHandler.Error() {
+		t.Errorf("end record = %+v, want duration and error", end)
+	}
+
+	buf.Reset()
+	logger.Info("reader", "reader", b.reader)
+	if !bytes.Contains(buf.Bytes(), []byte(`"reader":{"name":"virtual","last_uid":"04a1b2c3"}`)) {
+		t.Errorf("reader logged as %s", buf.Bytes())
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+)
+
+// DefaultSinkBuffer is the number of events buffered per event sink.
+const DefaultSinkBuffer = 64
+
+// EventSink receives the events of the SDK: a card inserted event for every
+// card handled and reader added and removed events as the reader list
+// changes. State changed events are only passed to the sinks subscribing
+// to them, see WithFilteredEventSink. The webhook integration is an HTTP
+// sink.
+type EventSink interface {
+	// Publish delivers ev. ctx is cancelled when Shutdown stops waiting for
+	// the sinks, so sinks retrying deliveries should give up once it is
+	// done.
+	Publish(ctx context.Context, ev cardreader.Event) error
+}
+
+// SinkFunc adapts a function to the EventSink interface.
+type SinkFunc func(ctx context.Context, ev cardreader.Event) error
+
+// Publish calls f.
+func (f SinkFunc) Publish(ctx context.Context, ev cardreader.Event) error { return f(ctx, ev) }
+
+// WithEventSink registers s to receive the events of the SDK. Each sink
+// has its own goroutine and a buffer of up to buffer events,
+// DefaultSinkBuffer when not greater than zero, so a slow or failing sink
+// delays neither card handling nor the other sinks. Events not fitting the
+// buffer are dropped; errors and panics of Publish are logged.
+func WithEventSink(s EventSink, buffer int) Option {
+	return func(sdk *SDK) {
+		if buffer <= 0 {
+			buffer = DefaultSinkBuffer
+		}
+		sdk.sinks = append(sdk.sinks, &sinkQueue{
+			sink:   s,
+			name:   fmt.Sprintf("%T", s),
+			events: make(chan cardreader.Event, buffer),
+			done:   make(chan struct{}),
+		})
+	}
+}
+
+// LogSink returns a sink logging events with l at level.
+func LogSink(l *slog.Logger, level slog.Level) EventSink {
+	return SinkFunc(func(ctx context.Context, ev cardreader.Event) error {
+		l.Log(ctx, level, "card event", slog.String("type", ev.Type.String()), slog.String("reader", ev.Reader),
+			slog.String("uid", fmt.Sprintf("%X", ev.UID)), slog.String("session_id", ev.SessionID))
+		return nil
+	})
+}
+
+// ChanSink returns a sink sending events to ch.
+func ChanSink(ch chan<- cardreader.Event) EventSink {
+	return SinkFunc(func(ctx context.Context, ev cardreader.Event) error {
+		select {
+		case ch <- ev:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// sinkQueue buffers the events of a sink, delivered by its goroutine
+// started with the first event.
+type sinkQueue struct {
+	sink   EventSink
+	name   string
+	filter *Filter // Events passed to the sink, all when nil.
+	events chan cardreader.Event
+	start  sync.Once
+	done   chan struct{}
+}
+
+// emit journals ev and queues it for every sink. State changed events are
+// not journaled and only queued for the sinks subscribing to them. Events
+// emitted after Shutdown are dropped.
+func (sdk *SDK) emit(ev cardreader.Event) {
+	if len(sdk.sinks) == 0 && sdk.journal == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	sdk.sinkMu.RLock()
+	defer sdk.sinkMu.RUnlock()
+	if sdk.sinksClosed {
+		return
+	}
+	state := ev.Type == cardreader.EventStateChanged
+	if sdk.journal != nil && !state {
+		if err := sdk.journal.Append(ev); err != nil {
+			sdk.logger.Warn("journal event", "type", ev.Type.String(), "reader", ev.Reader, "error", err)
+		}
+	}
+	for _, q := range sdk.sinks {
+		if q.filter != nil && !q.filter.Match(ev) || state && (q.filter == nil || !contains(q.filter.Events, ev.Type)) {
+			continue
+		}
+		q.start.Do(func() { go sdk.drain(q) })
+		select {
+		case q.events <- ev:
+		default:
+			sdk.logger.Warn("event sink full, event dropped", "sink", q.name, "type", ev.Type.String(), "reader", ev.Reader)
+		}
+	}
+}
+
+// drain delivers the events of q until its queue is closed by Shutdown.
+func (sdk *SDK) drain(q *sinkQueue) {
+	defer close(q.done)
+	for ev := range q.events {
+		sdk.publish(q, ev)
+	}
+}
+
+func (sdk *SDK) publish(q *sinkQueue, ev cardreader.Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			sdk.logger.Error("event sink panicked", "sink", q.name, "panic", r)
+		}
+	}()
+	if err := q.sink.Publish(sdk.sinkCtx, ev); err != nil {
+		sdk.logger.Warn("event sink failed", "sink", q.name, "type", ev.Type.String(), "reader", ev.Reader, "error", err)
+	}
+}
+
+// closeSinks closes the queues of the sinks, called once by Shutdown, and
+// waits until ctx is done for the events buffered to be delivered, then
+// cancels the deliveries still running. Events emitted later are dropped.
+func (sdk *SDK) closeSinks(ctx context.Context) error {
+	close(sdk.sinkStop)
+	sdk.sinkMu.Lock()
+	sdk.sinksClosed = true
+	sdk.sinkMu.Unlock()
+	for _, q := range sdk.sinks {
+		q := q
+		q.start.Do(func() { go sdk.drain(q) })
+		close(q.events)
+	}
+	for _, q := range sdk.sinks {
+		select {
+		case <-q.done:
+		case <-ctx.Done():
+			sdk.sinkCancel()
+			return fmt.Errorf("event sinks still publishing: %w", ctx.Err())
+		}
+	}
+	return nil
+}
+
+// Emit passes ev, such as an event of the application, to the journal and
+// the event sinks like the events of the SDK.
+func (sdk *SDK) Emit(ev cardreader.Event) { sdk.emit(ev) }
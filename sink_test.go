@@ -0,0 +1,120 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+)
+
+// syncBuffer is a log output safe for the sink goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestEventSinks(t *testing.T) {
+	var logs syncBuffer
+	events := make(chan cardreader.Event, 8)
+	block, blocked := make(chan struct{}), make(chan struct{}, 1)
+	sdk := New(
+		WithBackend(&fakeBackend{reader: *cardreader.NewReader("virtual"), card: &memCard{}}),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		WithEventSink(SinkFunc(func(context.Context, cardreader.Event) error { return errors.New("endpoint down") }), 0),
+		WithEventSink(SinkFunc(func(context.Context, cardreader.Event) error { panic("bug") }), 0),
+		WithEventSink(SinkFunc(func(context.Context, cardreader.Event) error {
+			select {
+			case blocked <- struct{}{}:
+			default:
+			}
+			<-block
+			return nil
+		}), 1),
+		WithEventSink(ChanSink(events), 0),
+	)
+	if _, err := sdk.WaitForCard(context.Background(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case ev := <-events:
+			got = append(got, ev.Type.String()+" "+ev.Reader)
+		case <-time.After(time.Second):
+			t.Fatalf("events %v, want 2", got)
+		}
+	}
+	if want := []string{"reader-added virtual", "card-inserted virtual"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events %v, want %v", got, want)
+	}
+
+	// The slow sink holds an event and buffers one, the next is dropped.
+	<-blocked
+	sdk.emit(cardreader.Event{Type: cardreader.EventReaderRemoved, Reader: "virtual"})
+	sdk.emit(cardreader.Event{Type: cardreader.EventReaderAdded, Reader: "virtual"})
+	close(block)
+	if err := sdk.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+	for _, want := range []string{"event sink failed", "endpoint down", "event sink panicked", "event sink full"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log lacks %q:\n%s", want, logs.String())
+		}
+	}
+	for len(events) > 0 {
+		<-events
+	}
+	sdk.emit(cardreader.Event{Type: cardreader.EventReaderRemoved, Reader: "virtual"})
+	if len(events) != 0 {
+		t.Error("event emitted after Shutdown")
+	}
+}
+
+func TestShutdownCancelsSinks(t *testing.T) {
+	publishing := make(chan struct{})
+	cancelled := make(chan error, 1)
+	sdk := New(WithEventSink(SinkFunc(func(ctx context.Context, _ cardreader.Event) error {
+		close(publishing)
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return ctx.Err()
+	}), 0))
+	sdk.emit(cardreader.Event{Type: cardreader.EventReaderAdded, Reader: "r0"})
+	<-publishing
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := sdk.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+	select {
+	case err := <-cancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Publish() context error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Shutdown() did not cancel the running Publish")
+	}
+}
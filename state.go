@@ -0,0 +1,101 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import "github.com/happy-sdk/scardkit/cardreader"
+
+// State is the lifecycle state of the SDK, see SDK.State.
+type State uint8
+
+const (
+	StateInitializing     State = iota // Created, not monitoring readers yet.
+	StateWaitingForReader              // Monitoring, but no selected reader is idle or attached.
+	StateMonitoring                    // Waiting for cards on the selected readers.
+	StateHandlingCard                  // A card handler is running.
+	StateRecovering                    // Retrying after a reader failed or was held by another application.
+	StateStopped                       // Not monitoring readers since Run or WaitForCard returned, or shut down.
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateInitializing:
+		return "initializing"
+	case StateWaitingForReader:
+		return "waiting-for-reader"
+	case StateMonitoring:
+		return "monitoring"
+	case StateHandlingCard:
+		return "handling-card"
+	case StateRecovering:
+		return "recovering"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// State returns the lifecycle state of the SDK. Every change is emitted as
+// a state changed event with the name of the new state as its reason, so
+// UIs can show it through an event sink instead of inferring it from logs.
+// The events are not journaled and reach only the sinks subscribing to
+// them:
+//
+//	scardkit.WithFilteredEventSink(ui, 0, scardkit.Filter{Events: []cardreader.EventType{cardreader.EventStateChanged}})
+func (sdk *SDK) State() State {
+	sdk.stateMu.Lock()
+	defer sdk.stateMu.Unlock()
+	return sdk.state
+}
+
+// setState records s as the state of the reader monitoring and updates the
+// state of the SDK.
+func (sdk *SDK) setState(s State) {
+	sdk.stateMu.Lock()
+	defer sdk.stateMu.Unlock()
+	sdk.baseState = s
+	sdk.updateState()
+}
+
+// stopMonitoring records the reader monitoring as stopped once a one-shot
+// wait for a card, such as WaitForCard, returns, unless Run monitors the
+// readers.
+func (sdk *SDK) stopMonitoring() {
+	sdk.mu.RLock()
+	running := sdk.stopRun != nil
+	sdk.mu.RUnlock()
+	if !running {
+		sdk.setState(StateStopped)
+	}
+}
+
+// refreshState updates the state of the SDK after card handlers started or
+// returned, or after Shutdown.
+func (sdk *SDK) refreshState() {
+	sdk.stateMu.Lock()
+	defer sdk.stateMu.Unlock()
+	sdk.updateState()
+}
+
+// updateState derives the state of the SDK from the state of the reader
+// monitoring, which running card handlers and Shutdown override, and emits
+// its changes. sdk.stateMu is held, so changes are emitted in order.
+func (sdk *SDK) updateState() {
+	s := sdk.baseState
+	sdk.mu.RLock()
+	switch {
+	case sdk.closed:
+		s = StateStopped
+	case sdk.sessions > 0:
+		s = StateHandlingCard
+	}
+	sdk.mu.RUnlock()
+	if s == sdk.state {
+		return
+	}
+	sdk.state = s
+	sdk.emit(cardreader.Event{Type: cardreader.EventStateChanged, Reason: s.String()})
+}
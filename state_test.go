@@ -0,0 +1,157 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// failingBackend fails listing or waiting with the given errors.
+type failingBackend struct {
+	fakeBackend
+	listErr, waitErr error
+}
+
+func (b *failingBackend) ListReaders() ([]cardreader.Reader, error) {
+	if b.listErr != nil {
+		return nil, b.listErr
+	}
+	return b.fakeBackend.ListReaders()
+}
+
+func (b *failingBackend) WaitCard(ctx context.Context, readers []cardreader.Reader, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	if b.waitErr != nil {
+		return nil, cardreader.Reader{}, b.waitErr
+	}
+	return b.fakeBackend.WaitCard(ctx, readers, timeout)
+}
+
+// states returns an option registering a sink for the state changed
+// events, and the channel it sends the new states to.
+func states() (Option, chan string) {
+	ch := make(chan string, 16)
+	return WithFilteredEventSink(SinkFunc(func(_ context.Context, ev cardreader.Event) error {
+		ch <- ev.Reason
+		return nil
+	}), 16, Filter{Events: []cardreader.EventType{cardreader.EventStateChanged}}), ch
+}
+
+func nextState(t *testing.T, ch chan string, want State) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		if got != want.String() {
+			t.Fatalf("state changed to %s, want %s", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no state change to %s", want)
+	}
+}
+
+func TestStateLifecycle(t *testing.T) {
+	withStates, ch := states()
+	handling, release := make(chan struct{}), make(chan struct{})
+	sdk := New(
+		WithBackend(&fakeBackend{reader: *cardreader.NewReader("virtual"), card: &memCard{}}),
+		withStates,
+		WithTapDebounce(time.Hour), // The card stays on the reader.
+		WithCardHandler(func(context.Context, cardreader.Event, transport.Card) error {
+			close(handling)
+			<-release
+			return nil
+		}),
+	)
+	if s := sdk.State(); s != StateInitializing {
+		t.Errorf("State() = %s, want %s", s, StateInitializing)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- sdk.Run(ctx) }()
+	nextState(t, ch, StateMonitoring)
+	<-handling
+	nextState(t, ch, StateHandlingCard)
+	if s := sdk.State(); s != StateHandlingCard {
+		t.Errorf("State() in handler = %s", s)
+	}
+	close(release)
+	nextState(t, ch, StateMonitoring)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v", err)
+	}
+	nextState(t, ch, StateStopped)
+	if s := sdk.State(); s != StateStopped {
+		t.Errorf("State() after Run = %s", s)
+	}
+}
+
+func TestStateRecovery(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend *failingBackend
+		states  []State
+		err     error
+	}{
+		{"no readers", &failingBackend{listErr: fmt.Errorf("list: %w", pcsc.ErrNoReaders)}, nil, pcsc.ErrNoReaders},
+		{"reader unavailable", &failingBackend{waitErr: fmt.Errorf("wait: %w", pcsc.ErrReaderUnavailable)}, []State{StateMonitoring}, pcsc.ErrReaderUnavailable},
+		{"reader failed", &failingBackend{waitErr: &ReaderError{Reader: "virtual", Err: errors.New("no card")}}, []State{StateMonitoring, StateRecovering}, ErrAllReadersFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.backend.reader = *cardreader.NewReader("virtual")
+			withStates, ch := states()
+			sdk := New(WithBackend(tt.backend), withStates, WithStatusPollTimeout(time.Millisecond), WithReaderFailurePolicy(ContinueOthers))
+			if err := sdk.Run(context.Background()); !errors.Is(err, tt.err) {
+				t.Errorf("Run() error = %v, want %v", err, tt.err)
+			}
+			for _, s := range append(tt.states, StateStopped) {
+				nextState(t, ch, s)
+			}
+		})
+	}
+}
+
+func TestStateOneShot(t *testing.T) {
+	tests := []struct {
+		name   string
+		wait   func(sdk *SDK) error
+		states []State
+	}{
+		{"WaitForCard", func(sdk *SDK) error {
+			_, err := sdk.WaitForCard(context.Background(), time.Second)
+			return err
+		}, []State{StateMonitoring, StateStopped}},
+		{"ReadNDEF", func(sdk *SDK) error {
+			_, err := sdk.ReadNDEF(context.Background(), nil)
+			return err
+		}, []State{StateMonitoring, StateHandlingCard, StateMonitoring, StateStopped}},
+	}
+	for _, tt := range tests {
+		withStates, ch := states()
+		sdk := New(WithBackend(&fakeBackend{reader: *cardreader.NewReader("virtual"), card: &memCard{}}), withStates)
+		_ = tt.wait(sdk)
+		for _, s := range tt.states {
+			nextState(t, ch, s)
+		}
+		if s := sdk.State(); s != StateStopped {
+			t.Errorf("State() after %s = %s, want %s", tt.name, s, StateStopped)
+		}
+		if err := sdk.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := tt.wait(sdk); !errors.Is(err, ErrClosed) {
+			t.Errorf("%s after Shutdown error = %v, want ErrClosed", tt.name, err)
+		}
+	}
+}
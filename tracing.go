@@ -0,0 +1,160 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// TracerProvider provides the tracer the SDK records spans with. Its shape
+// follows the OpenTelemetry trace API, so an adapter to an OpenTelemetry
+// TracerProvider takes a few lines without scardkit depending on it.
+type TracerProvider interface {
+	Tracer(name string) Tracer
+}
+
+// Tracer starts spans.
+type Tracer interface {
+	// Start starts a span as child of the span in ctx, if any, and returns
+	// a context holding the new span.
+	Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+}
+
+// Span is a traced operation.
+type Span interface {
+	SetAttributes(attrs ...slog.Attr)
+	RecordError(err error)
+	End()
+}
+
+// TracerName is the instrumentation name the SDK requests its tracer with.
+const TracerName = "github.com/happy-sdk/scardkit"
+
+// WithTracerProvider enables tracing of card sessions and APDU exchanges.
+// Card sessions are recorded as "scardkit.card" spans with the reader name,
+// ATR and negotiated protocol, APDU exchanges as "scardkit.transmit" spans
+// with the protocol, the command and response lengths and the status word.
+// Exchanges of PC/SC cards are traced at the transport, so handlers using
+// the *pcsc.Card directly are traced as well.
+func WithTracerProvider(tp TracerProvider) Option {
+	return func(sdk *SDK) {
+		if tp != nil {
+			sdk.tracer = tp.Tracer(TracerName)
+		}
+	}
+}
+
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, _ string, _ ...slog.Attr) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...slog.Attr) {}
+func (nopSpan) RecordError(error)          {}
+func (nopSpan) End()                       {}
+
+// startCardSpan starts the span of a card session.
+func (sdk *SDK) startCardSpan(ctx context.Context, ev cardreader.Event, card transport.Card) (context.Context, Span) {
+	attrs := []slog.Attr{
+		slog.String("session_id", ev.SessionID),
+		slog.String("reader", ev.Reader),
+		slog.String("atr", hex.EncodeToString(ev.ATR)),
+	}
+	return sdk.tracer.Start(ctx, "scardkit.card", append(attrs, protocolAttrs(card)...)...)
+}
+
+// protocolAttrs returns the protocol attribute of card, none when it does
+// not report its protocol.
+func protocolAttrs(card tag.Card) []slog.Attr {
+	if c, ok := card.(interface{ Protocol() pcsc.Protocol }); ok {
+		return []slog.Attr{slog.String("protocol", c.Protocol().String())}
+	}
+	return nil
+}
+
+// endSpan records err, if any, and ends span.
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// transmitHook returns the hook reporting the exchanges of card on reader
+// to the SDK metrics and tracer, and logging them to the LogPCSC logger at
+// debug level.
+func (sdk *SDK) transmitHook(ctx context.Context, reader string, card tag.Card) pcsc.TransmitHook {
+	protocol := protocolAttrs(card)
+	return func(cmd []byte) func(resp []byte, err error) {
+		attrs := append([]slog.Attr{slog.String("reader", reader), slog.Int("apdu.length", len(cmd))}, protocol...)
+		_, span := sdk.tracer.Start(ctx, "scardkit.transmit", attrs...)
+		start := time.Now()
+		return func(resp []byte, err error) {
+			if sdk.wireLogger.Enabled(ctx, slog.LevelDebug) {
+				logged := apdu.TransceiverFunc(func([]byte) ([]byte, error) { return resp, err })
+				_, _ = apdu.RedactedLogging(sdk.wireLogger, slog.LevelDebug, nil)(logged).Transmit(cmd)
+			}
+			if err != nil {
+				sdk.metrics.TransmitError(reader, err)
+				endSpan(span, err)
+				return
+			}
+			sdk.metrics.ObserveAPDU(reader, time.Since(start))
+			span.SetAttributes(slog.Int("response.length", len(resp)))
+			if len(resp) >= 2 {
+				span.SetAttributes(slog.String("sw", fmt.Sprintf("%02X%02X", resp[len(resp)-2], resp[len(resp)-1])))
+			}
+			span.End()
+		}
+	}
+}
+
+// traceTransport makes card report its own exchanges with transmitHook
+// when it is a *pcsc.Card, so handlers transmitting to it directly are
+// traced, and returns the function restoring its previous hook.
+func (sdk *SDK) traceTransport(ctx context.Context, reader string, card transport.Card) func() {
+	pc, ok := card.(*pcsc.Card)
+	if !ok {
+		return func() {}
+	}
+	prev := pc.SetTransmitHook(sdk.transmitHook(ctx, reader, card))
+	return func() { pc.SetTransmitHook(prev) }
+}
+
+// instrumentedCard reports the APDU exchanges of a card to the SDK metrics
+// and tracer, and logs them to the LogPCSC logger at debug level, unless
+// the card reports them itself through a transmit hook.
+type instrumentedCard struct {
+	tag.Card
+	ctx    context.Context
+	reader string
+	sdk    *SDK
+}
+
+func (c instrumentedCard) Transmit(cmd []byte) ([]byte, error) {
+	if h, ok := c.Card.(interface{ TransmitHook() pcsc.TransmitHook }); ok && h.TransmitHook() != nil {
+		return transport.TransmitContext(c.ctx, c.Card, cmd)
+	}
+	done := c.sdk.transmitHook(c.ctx, c.reader, c.Card)(cmd)
+	resp, err := transport.TransmitContext(c.ctx, c.Card, cmd)
+	done(resp, err)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
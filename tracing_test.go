@@ -0,0 +1,92 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+)
+
+type recordedSpan struct {
+	name  string
+	attrs map[string]string
+	ended bool
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...slog.Attr) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value.String()
+	}
+}
+func (s *recordedSpan) RecordError(err error) { s.attrs["error"] = err.Error() }
+func (s *recordedSpan) End()                  { s.ended = true }
+
+type recordingTracer struct{ spans []*recordedSpan }
+
+func (r *recordingTracer) Tracer(string) Tracer { return r }
+
+func (r *recordingTracer) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	s := &recordedSpan{name: name, attrs: make(map[string]string)}
+	s.SetAttributes(attrs...)
+	r.spans = append(r.spans, s)
+	return ctx, s
+}
+
+func TestTransmitSpan(t *testing.T) {
+	tr := &recordingTracer{}
+	card := instrumentedCard{
+		Card: fakeCard{func(cmd []byte) ([]byte, error) {
+			return []byte{0x01, 0x02, 0x6A, 0x82}, nil
+		}},
+		ctx:    context.Background(),
+		reader: "reader 0",
+		sdk:    New(WithTracerProvider(tr)),
+	}
+	if _, err := card.Transmit([]byte{0x00, 0xA4, 0x04, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.spans) != 1 {
+		t.Fatalf("recorded %d spans, want 1", len(tr.spans))
+	}
+	s := tr.spans[0]
+	want := map[string]string{"reader": "reader 0", "apdu.length": "4", "response.length": "4", "sw": "6A82"}
+	for k, v := range want {
+		if s.attrs[k] != v {
+			t.Errorf("attribute %s = %q, want %q", k, s.attrs[k], v)
+		}
+	}
+	if s.name != "scardkit.transmit" || !s.ended {
+		t.Errorf("span %q ended = %v", s.name, s.ended)
+	}
+}
+
+func TestTraceTransport(t *testing.T) {
+	tr := &recordingTracer{}
+	sdk := New(WithTracerProvider(tr))
+	pc := &pcsc.Card{}
+	restore := sdk.traceTransport(context.Background(), "reader 0", pc)
+	if _, err := pc.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	card := instrumentedCard{Card: pc, ctx: context.Background(), reader: "reader 0", sdk: sdk}
+	if _, err := card.Transmit([]byte{0x00, 0xB0, 0x00, 0x00, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(tr.spans))
+	}
+	for _, s := range tr.spans {
+		if s.name != "scardkit.transmit" || s.attrs["protocol"] != pc.Protocol().String() || s.attrs["apdu.length"] != "5" {
+			t.Errorf("span %q attributes = %v", s.name, s.attrs)
+		}
+	}
+	restore()
+	if _, err := pc.Transmit([]byte{0x00}); err != nil || len(tr.spans) != 2 {
+		t.Errorf("transmit traced after restoring the hook")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package transport defines how the SDK reaches readers and cards. The SDK
+// uses the PC/SC resource manager by default; other backends, such as a
+// PN532 driven directly on devices without pcscd, implement Backend to
+// provide the same high-level API.
+package transport
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/cardreader"
+)
+
+// Card is a card connected through a Backend. Transmit accepts command
+// APDUs including the PC/SC Part 3 pseudo-APDUs GET DATA (FF CA) and, for
+// storage cards, READ BINARY (FF B0) and UPDATE BINARY (FF D6).
+type Card interface {
+	apdu.Transceiver
+	// ATR returns the answer to reset of the card. Contactless cards
+	// report the ATR constructed as defined in PC/SC Part 3.
+	ATR() []byte
+	// Disconnect releases the card.
+	Disconnect() error
+}
+
+// Backend gives access to readers and the cards presented to them.
+type Backend interface {
+	// ListReaders returns the readers available through the backend.
+	ListReaders() ([]cardreader.Reader, error)
+	// WaitCard waits up to timeout for a card in one of readers and
+	// connects to it. A card already present is returned right away. When
+	// timeout elapses or the set of readers changes it returns a nil card
+	// and no error, after which the caller lists the readers again.
+	WaitCard(ctx context.Context, readers []cardreader.Reader, timeout time.Duration) (Card, cardreader.Reader, error)
+}
+
+// ErrUIDUnavailable is returned by UID when the card does not report its
+// UID.
+var ErrUIDUnavailable = errors.New("card uid is not available")
+
+// UID returns the UID of card, using its UID method when it has one and the
+// GET DATA pseudo-APDU otherwise.
+func UID(card Card) ([]byte, error) {
+	if c, ok := card.(interface{ UID() ([]byte, error) }); ok {
+		return c.UID()
+	}
+	resp, err := card.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) <= 2 || apdu.CheckStatusFromData(resp) != nil {
+		return nil, ErrUIDUnavailable
+	}
+	return resp[:len(resp)-2], nil
+}
+
+// TransmitContext transmits cmd to tr, using its TransmitContext method when
+// it has one so a pending exchange ends once ctx is done. Otherwise ctx is
+// only checked before the exchange.
+func TransmitContext(ctx context.Context, tr apdu.Transceiver, cmd []byte) ([]byte, error) {
+	if c, ok := tr.(interface {
+		TransmitContext(context.Context, []byte) ([]byte, error)
+	}); ok {
+		return c.TransmitContext(ctx, cmd)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return tr.Transmit(cmd)
+}
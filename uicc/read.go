@@ -0,0 +1,370 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package uicc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+// File identifiers of the files read.
+const (
+	FIDMF    = 0x3F00
+	FIDDIR   = 0x2F00 // EF.DIR, the application directory.
+	FIDICCID = 0x2FE2
+	FIDDFGSM = 0x7F20
+	FIDIMSI  = 0x6F07
+	FIDSPN   = 0x6F46
+)
+
+// Command classes: UICCs use the ISO class (ETSI TS 102 221), GSM SIMs the
+// proprietary one (GSM 11.11).
+const (
+	claUICC = 0x00
+	claSIM  = 0xA0
+)
+
+// USIM is the registered part of the AID of USIM applications.
+var USIM = []byte{0xA0, 0x00, 0x00, 0x00, 0x87, 0x10, 0x02}
+
+// ErrNoUSIM is returned by SelectUSIM when EF.DIR lists no USIM
+// application.
+var ErrNoUSIM = errors.New("uicc: no usim application")
+
+// Card is a UICC or GSM SIM on a contact reader.
+type Card struct {
+	tr  apdu.Transceiver
+	cla byte
+}
+
+// Open selects the master file of the card behind tr, falling back to the
+// GSM class for SIMs which reject the ISO one.
+func Open(tr apdu.Transceiver) (*Card, error) {
+	c := &Card{tr: apdu.Wrap(tr, apdu.GetResponse()), cla: claUICC}
+	_, err := c.Select(FIDMF)
+	var se *apdu.StatusError
+	if errors.As(err, &se) && (se.SW1 == 0x6E || se.SW1 == 0x6D) {
+		c.cla = claSIM
+		_, err = c.Select(FIDMF)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("uicc: select mf: %w", err)
+	}
+	return c, nil
+}
+
+// SIM reports whether the card is addressed as a GSM SIM.
+func (c *Card) SIM() bool { return c.cla == claSIM }
+
+// Select selects the file fid and returns its FCP template, or the GSM
+// response to SELECT for SIMs.
+func (c *Card) Select(fid uint16) ([]byte, error) {
+	p2 := byte(iso7816.ReturnFCP)
+	if c.SIM() {
+		p2 = 0x00
+	}
+	return c.transmit(&iso7816.CommandAPDU{Ins: iso7816.INSSelect, P2: p2, Data: []byte{byte(fid >> 8), byte(fid)}})
+}
+
+// SelectPath selects the file at path from the master file, which may be
+// omitted as first element, and returns the response of its SELECT.
+func (c *Card) SelectPath(path ...uint16) ([]byte, error) {
+	if len(path) == 0 || path[0] != FIDMF {
+		path = append([]uint16{FIDMF}, path...)
+	}
+	var resp []byte
+	for _, fid := range path {
+		var err error
+		if resp, err = c.Select(fid); err != nil {
+			return nil, fmt.Errorf("uicc: select %04X: %w", fid, err)
+		}
+	}
+	return resp, nil
+}
+
+// SelectUSIM selects the first USIM application listed in EF.DIR.
+func (c *Card) SelectUSIM() error {
+	if c.SIM() {
+		return ErrNoUSIM
+	}
+	recs, err := c.ReadRecords(FIDMF, FIDDIR)
+	if NotFound(err) {
+		return ErrNoUSIM
+	}
+	if err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		aid, ok := iso7816.FindTLV(rec, 0x4F)
+		if !ok || !bytes.HasPrefix(aid, USIM) {
+			continue
+		}
+		cmd := &iso7816.CommandAPDU{Ins: iso7816.INSSelect, P1: iso7816.SelectByName, P2: iso7816.ReturnFCP, Data: aid}
+		if _, err := c.transmit(cmd); err != nil {
+			return fmt.Errorf("uicc: select usim %X: %w", aid, err)
+		}
+		return nil
+	}
+	return ErrNoUSIM
+}
+
+// ReadFile selects the transparent EF at path and reads it whole.
+func (c *Card) ReadFile(path ...uint16) ([]byte, error) {
+	resp, err := c.SelectPath(path...)
+	if err != nil {
+		return nil, err
+	}
+	return c.readCurrent(resp)
+}
+
+func (c *Card) readCurrent(resp []byte) ([]byte, error) {
+	size, _, err := c.fileInfo(resp)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, size)
+	for len(out) < size {
+		n := min(size-len(out), 256)
+		chunk, err := c.transmit(&iso7816.CommandAPDU{Ins: iso7816.INSReadBinary, P1: byte(len(out) >> 8), P2: byte(len(out)), Ne: n})
+		if err != nil {
+			return nil, fmt.Errorf("uicc: read binary at %d: %w", len(out), err)
+		}
+		if len(chunk) == 0 {
+			return nil, fmt.Errorf("uicc: read binary at %d: no data", len(out))
+		}
+		out = append(out, chunk...)
+	}
+	return out, nil
+}
+
+// ReadRecords selects the linear fixed EF at path and reads all its
+// records.
+func (c *Card) ReadRecords(path ...uint16) ([][]byte, error) {
+	resp, err := c.SelectPath(path...)
+	if err != nil {
+		return nil, err
+	}
+	size, recLen, err := c.fileInfo(resp)
+	if err != nil {
+		return nil, err
+	}
+	if recLen == 0 {
+		return nil, fmt.Errorf("uicc: not a record file")
+	}
+	var recs [][]byte
+	for rec := 1; rec <= size/recLen && rec <= 254; rec++ {
+		data, err := c.transmit(&iso7816.CommandAPDU{Ins: iso7816.INSReadRecord, P1: byte(rec), P2: 0x04, Ne: recLen})
+		if err != nil {
+			return nil, fmt.Errorf("uicc: read record %d: %w", rec, err)
+		}
+		recs = append(recs, data)
+	}
+	return recs, nil
+}
+
+// fileInfo returns the size of the EF described by the SELECT response
+// resp and its record length, zero for transparent files.
+func (c *Card) fileInfo(resp []byte) (size, recLen int, err error) {
+	if c.SIM() {
+		// File size in bytes 3-4, record length in byte 15.
+		if len(resp) < 15 {
+			return 0, 0, fmt.Errorf("uicc: select response of %d bytes", len(resp))
+		}
+		size = int(resp[2])<<8 | int(resp[3])
+		if resp[13] != 0x00 {
+			recLen = int(resp[14])
+		}
+		return size, recLen, nil
+	}
+	fc, err := iso7816.ParseFileControl(resp)
+	if err != nil {
+		return 0, 0, fmt.Errorf("uicc: %w", err)
+	}
+	if fc.Transparent() {
+		return fc.Size, 0, nil
+	}
+	if len(fc.Descriptor) < 5 {
+		return 0, 0, fmt.Errorf("uicc: file descriptor of %d bytes", len(fc.Descriptor))
+	}
+	recLen = int(fc.Descriptor[2])<<8 | int(fc.Descriptor[3])
+	return recLen * int(fc.Descriptor[4]), recLen, nil
+}
+
+// ICCID reads EF.ICCID and returns the card identifier.
+func (c *Card) ICCID() (string, error) {
+	b, err := c.ReadFile(FIDMF, FIDICCID)
+	if err != nil {
+		return "", err
+	}
+	return DecodeBCD(b), nil
+}
+
+// IMSI reads EF.IMSI of the USIM application, or of DF GSM on SIMs and
+// cards without one, and returns the subscriber identity. The file is
+// usually protected by PIN 1.
+func (c *Card) IMSI() (string, error) {
+	b, err := c.readSubscriberFile(FIDIMSI)
+	if err != nil {
+		return "", err
+	}
+	return ParseIMSI(b)
+}
+
+// SPN reads EF.SPN like IMSI and returns the service provider name.
+func (c *Card) SPN() (string, error) {
+	b, err := c.readSubscriberFile(FIDSPN)
+	if err != nil {
+		return "", err
+	}
+	return ParseSPN(b)
+}
+
+func (c *Card) readSubscriberFile(fid uint16) ([]byte, error) {
+	err := c.SelectUSIM()
+	if err == nil {
+		var resp []byte
+		if resp, err = c.Select(fid); err == nil {
+			return c.readCurrent(resp)
+		}
+		err = fmt.Errorf("uicc: select %04X: %w", fid, err)
+	}
+	if err != ErrNoUSIM && !NotFound(err) {
+		return nil, err
+	}
+	return c.ReadFile(FIDMF, FIDDFGSM, fid)
+}
+
+// Read reads the identifiers of the card behind tr. The service provider
+// name is left empty when the card has none.
+func Read(tr apdu.Transceiver) (*UICCCard, error) {
+	c, err := Open(tr)
+	if err != nil {
+		return nil, err
+	}
+	card := &UICCCard{}
+	if card.ICCID, err = c.ICCID(); err != nil {
+		return nil, err
+	}
+	if card.IMSI, err = c.IMSI(); err != nil {
+		return nil, err
+	}
+	if card.SPN, err = c.SPN(); err != nil && !NotFound(err) {
+		return nil, err
+	}
+	return card, nil
+}
+
+// DecodeBCD decodes digits stored as BCD with swapped nibbles, as in
+// EF.ICCID, up to the first filler nibble F.
+func DecodeBCD(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		for _, d := range []byte{c & 0x0F, c >> 4} {
+			if d > 9 {
+				return sb.String()
+			}
+			sb.WriteByte('0' + d)
+		}
+	}
+	return sb.String()
+}
+
+// ParseIMSI decodes the content of EF.IMSI: a length byte followed by the
+// digits as swapped BCD, the first nibble holding the parity.
+func ParseIMSI(b []byte) (string, error) {
+	if len(b) < 2 || int(b[0]) < 1 || int(b[0]) > len(b)-1 {
+		return "", fmt.Errorf("uicc: malformed imsi")
+	}
+	digits := DecodeBCD(b[1 : 1+b[0]])
+	if len(digits) < 7 {
+		return "", fmt.Errorf("uicc: malformed imsi")
+	}
+	return digits[1:], nil
+}
+
+// ParseSPN decodes the content of EF.SPN: a display condition byte followed
+// by the name in the GSM default alphabet or UCS2, padded with FF.
+func ParseSPN(b []byte) (string, error) {
+	if len(b) < 1 {
+		return "", fmt.Errorf("uicc: malformed spn")
+	}
+	if len(b) > 1 && b[1] == 0x80 {
+		var u []uint16
+		for i := 2; i+1 < len(b) && (b[i] != 0xFF || b[i+1] != 0xFF); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(u)), nil
+	}
+	name := bytes.TrimRight(b[1:], "\xFF")
+	var sb strings.Builder
+	for _, c := range name {
+		sb.WriteRune(gsmAlphabet(c))
+	}
+	return sb.String(), nil
+}
+
+// gsm0 are the characters 00 to 1F of the GSM default alphabet, the escape
+// to the extension table shown as a space.
+var gsm0 = []rune("@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ ÆæßÉ")
+
+// gsmAlphabet maps a character of the GSM 03.38 default alphabet.
+func gsmAlphabet(c byte) rune {
+	switch c &= 0x7F; {
+	case c < 0x20:
+		return gsm0[c]
+	case c == 0x24:
+		return '¤'
+	case c == 0x40:
+		return '¡'
+	case c >= 0x5B && c <= 0x60:
+		return []rune("ÄÖÑÜ§¿")[c-0x5B]
+	case c >= 0x7B:
+		return []rune("äöñüà")[c-0x7B]
+	}
+	return rune(c)
+}
+
+// transmit sends cmd in the class of the card and returns the response
+// data. Over T=0, commands are sent without Le: the responses announced
+// with 61xx or 9Fxx, and the lengths corrected with 6Cxx, are handled by
+// apdu.GetResponse. Status 91xx of a UICC with a pending proactive command
+// ends normally.
+func (c *Card) transmit(cmd *iso7816.CommandAPDU) ([]byte, error) {
+	cmd.Cla = c.cla
+	if len(cmd.Data) > 0 {
+		cmd.Ne = 0
+	}
+	raw, err := cmd.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.tr.Transmit(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 2 {
+		return nil, fmt.Errorf("response of %d bytes", len(resp))
+	}
+	sw1, sw2 := resp[len(resp)-2], resp[len(resp)-1]
+	if sw1 != 0x91 {
+		if err := apdu.CheckStatus(sw1, sw2); err != nil {
+			return nil, err
+		}
+	}
+	return resp[:len(resp)-2], nil
+}
+
+// NotFound reports whether err is the status of a file the card does not
+// have, 6A82 or 9404 on SIMs.
+func NotFound(err error) bool {
+	var se *apdu.StatusError
+	return errors.As(err, &se) && (se.SW1 == 0x6A && se.SW2 == 0x82 || se.SW1 == 0x94 && se.SW2 == 0x04)
+}
@@ -0,0 +1,200 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package uicc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/protocols/iso7816"
+)
+
+func unhex(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	return b
+}
+
+var (
+	usimAID = unhex("A0000000871002FF49FF0589")
+	iccid   = unhex("98440100032143658709")
+	imsi    = unhex("082943519099999999")
+	spn     = append([]byte{0x01, 'S', 'P', 0x11, 'N'}, bytes.Repeat([]byte{0xFF}, 12)...)
+)
+
+// fakeCard emulates a T=0 card: a UICC with a USIM application when sim is
+// false, a GSM SIM otherwise. Commands must not carry Le with data, and
+// responses are announced with 61xx, or 9Fxx on the SIM.
+type fakeCard struct {
+	t       *testing.T
+	sim     bool
+	current uint16
+	adf     bool
+	pending []byte
+	wrongLe bool // answer the first READ BINARY with 6Cxx
+	files   map[uint16][]byte
+}
+
+func newFakeCard(t *testing.T, sim bool) *fakeCard {
+	f := &fakeCard{t: t, sim: sim, wrongLe: true, files: map[uint16][]byte{FIDICCID: iccid, FIDIMSI: imsi}}
+	if sim {
+		f.files[FIDSPN] = spn
+	} else {
+		rec := iso7816.AppendTLV(nil, 0x61, append(iso7816.AppendTLV(nil, 0x4F, usimAID), iso7816.AppendTLV(nil, 0x50, []byte("USIM"))...))
+		f.files[FIDDIR] = append(rec, bytes.Repeat([]byte{0xFF}, 32-len(rec))...)
+	}
+	return f
+}
+
+func (f *fakeCard) Transmit(cmd []byte) ([]byte, error) {
+	cla, ok := byte(0x00), []byte{0x90, 0x00}
+	if f.sim {
+		cla = 0xA0
+	}
+	if cmd[0] != cla {
+		return []byte{0x6E, 0x00}, nil
+	}
+	if len(cmd) > 5 && len(cmd) != 5+int(cmd[4]) {
+		f.t.Errorf("command %X carries Le with data", cmd)
+	}
+	switch cmd[1] {
+	case 0xA4:
+		data := cmd[5:]
+		var resp []byte
+		switch {
+		case cmd[2] == 0x04 && bytes.Equal(data, usimAID):
+			f.adf = true
+			resp = iso7816.AppendTLV(nil, 0x62, unhex("820178"))
+		case bytes.Equal(data, []byte{0x3F, 0x00}) || bytes.Equal(data, []byte{0x7F, 0x20}):
+			f.current, f.adf = uint16(data[0])<<8|uint16(data[1]), false
+			resp = f.fileResponse(f.current, nil)
+		default:
+			fid := uint16(data[0])<<8 | uint16(data[1])
+			content, found := f.files[fid]
+			if !found || (fid == FIDIMSI && !f.sim && !f.adf) {
+				if f.sim {
+					return []byte{0x94, 0x04}, nil
+				}
+				return []byte{0x6A, 0x82}, nil
+			}
+			f.current = fid
+			resp = f.fileResponse(fid, content)
+		}
+		f.pending = resp
+		if f.sim {
+			return []byte{0x9F, byte(len(resp))}, nil
+		}
+		return []byte{0x61, byte(len(resp))}, nil
+	case 0xC0:
+		resp := f.pending
+		f.pending = nil
+		return append(resp, ok...), nil
+	case 0xB0:
+		content := f.files[f.current]
+		if f.wrongLe {
+			f.wrongLe = false
+			return []byte{0x6C, byte(len(content))}, nil
+		}
+		return append(append([]byte(nil), content...), ok...), nil
+	case 0xB2:
+		if f.current != FIDDIR || cmd[2] != 1 {
+			return []byte{0x6A, 0x83}, nil
+		}
+		return append(append([]byte(nil), f.files[FIDDIR]...), ok...), nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+// fileResponse returns the FCP template, or the GSM SELECT response, of the
+// file fid holding content.
+func (f *fakeCard) fileResponse(fid uint16, content []byte) []byte {
+	if f.sim {
+		resp := make([]byte, 15)
+		resp[2], resp[3] = byte(len(content)>>8), byte(len(content))
+		resp[4], resp[5], resp[6] = byte(fid>>8), byte(fid), 0x04
+		if content == nil {
+			resp[6] = 0x02
+		}
+		return resp
+	}
+	fcp := iso7816.AppendTLV(nil, 0x83, []byte{byte(fid >> 8), byte(fid)})
+	switch {
+	case content == nil:
+		fcp = iso7816.AppendTLV(fcp, 0x82, unhex("78"))
+	case fid == FIDDIR:
+		fcp = iso7816.AppendTLV(fcp, 0x82, []byte{0x42, 0x21, 0x00, byte(len(content)), 0x01})
+	default:
+		fcp = iso7816.AppendTLV(fcp, 0x82, unhex("41"))
+		fcp = iso7816.AppendTLV(fcp, 0x80, []byte{0x00, byte(len(content))})
+	}
+	return iso7816.AppendTLV(nil, 0x62, fcp)
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name string
+		sim  bool
+		want UICCCard
+	}{
+		{"uicc", false, UICCCard{ICCID: "89441000301234567890", IMSI: "234150999999999"}},
+		{"sim", true, UICCCard{ICCID: "89441000301234567890", IMSI: "234150999999999", SPN: "SP_N"}},
+	}
+	for _, tt := range tests {
+		card, err := Read(newFakeCard(t, tt.sim))
+		if err != nil {
+			t.Errorf("%s: Read() error = %v", tt.name, err)
+			continue
+		}
+		if *card != tt.want {
+			t.Errorf("%s: Read() = %+v, want %+v", tt.name, *card, tt.want)
+		}
+		if got, err := card.DecodeIMSI(); got != tt.want.IMSI || err != nil {
+			t.Errorf("%s: DecodeIMSI() = %s, %v", tt.name, got, err)
+		}
+	}
+
+	c, err := Open(newFakeCard(t, false))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, err := c.ReadFile(FIDMF, 0x2F05); !NotFound(err) {
+		t.Errorf("ReadFile(missing) error = %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	if got := DecodeBCD(unhex("98440100032143658709F0")); got != "894410003012345678900" {
+		t.Errorf("DecodeBCD() = %s", got)
+	}
+	imsis := []struct {
+		b    []byte
+		want string
+	}{
+		{imsi, "234150999999999"},
+		{unhex("0821436587092143F5FFFF"), "23456789012345"},
+		{unhex("08294351909999999999"), "234150999999999"},
+		{unhex("0A2943519099999999"), ""},
+		{unhex("00"), ""},
+	}
+	for _, tt := range imsis {
+		got, err := ParseIMSI(tt.b)
+		if got != tt.want || (err != nil) != (tt.want == "") {
+			t.Errorf("ParseIMSI(%X) = %s, %v", tt.b, got, err)
+		}
+	}
+	spns := []struct {
+		b    []byte
+		want string
+	}{
+		{spn, "SP_N"},
+		{unhex("00800054006500E9FFFF"), "Teé"},
+		{unhex("0041405B7FFF"), "A¡Äà"},
+	}
+	for _, tt := range spns {
+		if got, err := ParseSPN(tt.b); got != tt.want || err != nil {
+			t.Errorf("ParseSPN(%X) = %q, %v", tt.b, got, err)
+		}
+	}
+}
@@ -11,6 +11,8 @@
 // relevant standards and facilitating secure mobile communications.
 package uicc
 
+import "errors"
+
 // NewUICCCard initializes a new UICC card representation.
 func NewUICCCard() *UICCCard { return nil }
 
@@ -22,7 +24,9 @@ func CheckCardCompatibility(cardInfo []byte) bool { return false }
 
 // UICCCard represents a UICC card with its attributes.
 type UICCCard struct {
-	// Fields like IMSI, ICCID, etc.
+	ICCID string // Integrated circuit card identifier.
+	IMSI  string // International mobile subscriber identity.
+	SPN   string // Service provider name, empty when not set.
 }
 
 // Marshal serializes a UICCCard into bytes.
@@ -37,8 +41,14 @@ func (card *UICCCard) Unmarshal(data []byte) error {
 // ReadApplication retrieves application data from the UICC card.
 func (card *UICCCard) ReadApplication(appID string) (*Application, error) { return nil, nil }
 
-// DecodeIMSI decodes the IMSI (International Mobile Subscriber Identity) from the card.
-func (card *UICCCard) DecodeIMSI() (string, error) { return "", nil }
+// DecodeIMSI returns the IMSI (International Mobile Subscriber Identity) read
+// from the card.
+func (card *UICCCard) DecodeIMSI() (string, error) {
+	if card == nil || card.IMSI == "" {
+		return "", errors.New("uicc: imsi not read")
+	}
+	return card.IMSI, nil
+}
 
 // Application represents a UICC application such as a SIM or USIM application.
 type Application struct {
@@ -0,0 +1,96 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// poller holds the buffers waitCard reuses between status polls, so polling
+// idle readers does not churn the garbage collector.
+type poller struct {
+	tracked  []cardreader.Reader
+	selected []cardreader.Reader
+	timer    *time.Timer
+}
+
+// readers returns the selected idle readers of the reader list all which
+// did not fail, in a buffer valid until the next call. It reports false when
+// every selected reader failed.
+func (p *poller) readers(sdk *SDK, all []cardreader.Reader) ([]cardreader.Reader, bool) {
+	sdk.recordReaders(all)
+	p.tracked = sdk.trackReaders(p.tracked[:0], all)
+	selected, ok := sdk.workingReaders(sdk.selectReaders(p.selected[:0], p.tracked))
+	p.selected = sdk.idleReaders(selected)
+	return p.selected, ok
+}
+
+// sleep waits for d or until ctx is done, reusing the timer of p.
+func (p *poller) sleep(ctx context.Context, d time.Duration) error {
+	if p.timer == nil {
+		p.timer = time.NewTimer(d)
+	} else {
+		p.timer.Reset(d)
+	}
+	select {
+	case <-ctx.Done():
+		if !p.timer.Stop() {
+			<-p.timer.C
+		}
+		return ctx.Err()
+	case <-p.timer.C:
+		return nil
+	}
+}
+
+// waitCard blocks until a card is present in one of the selected readers and
+// connects to it. A card already present when the backend first sees a reader
+// is used right away; with the PC/SC backend a card left in a reader is used
+// only once. Readers with a card session in flight are skipped. The reader
+// list is refreshed every status poll timeout. The state of the SDK follows
+// the wait.
+func (sdk *SDK) waitCard(ctx context.Context) (transport.Card, cardreader.Reader, error) {
+	var p poller
+	defer func() {
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+	}()
+	for {
+		all, err := sdk.backend.ListReaders()
+		if err != nil {
+			return nil, cardreader.Reader{}, fmt.Errorf("list readers: %w", err)
+		}
+		readers, ok := p.readers(sdk, all)
+		if !ok {
+			return nil, cardreader.Reader{}, ErrAllReadersFailed
+		}
+		if len(readers) == 0 {
+			sdk.setState(StateWaitingForReader)
+			if err := p.sleep(ctx, sdk.statusPollTimeout); err != nil {
+				return nil, cardreader.Reader{}, err
+			}
+			continue
+		}
+		sdk.setState(StateMonitoring)
+		card, reader, err := sdk.backend.WaitCard(ctx, readers, sdk.statusPollTimeout)
+		if err != nil {
+			return nil, cardreader.Reader{}, err
+		}
+		if card != nil {
+			sdk.metrics.CardTapped(reader.Name)
+			sdk.history.add(HistoryEntry{Reader: reader.Name, Kind: HistoryCardConnected})
+			return card, reader, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, cardreader.Reader{}, err
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// idleBackend reports the same readers on every poll and presents its card
+// after polls empty waits.
+type idleBackend struct {
+	readers []cardreader.Reader
+	card    *memCard
+	polls   int
+}
+
+func (b *idleBackend) Close() error { return nil }
+
+func (b *idleBackend) ListReaders() ([]cardreader.Reader, error) { return b.readers, nil }
+
+func (b *idleBackend) WaitCard(_ context.Context, readers []cardreader.Reader, _ time.Duration) (transport.Card, cardreader.Reader, error) {
+	if b.polls > 0 {
+		b.polls--
+		return nil, cardreader.Reader{}, nil
+	}
+	return b.card, readers[0], nil
+}
+
+func newIdleBackend() *idleBackend {
+	return &idleBackend{
+		readers: []cardreader.Reader{*cardreader.NewReader("reader 0"), *cardreader.NewReader("reader 1")},
+		card:    &memCard{},
+	}
+}
+
+func TestPollAllocs(t *testing.T) {
+	b := newIdleBackend()
+	sdk := New(WithBackend(b))
+	var p poller
+	if got, _ := p.readers(sdk, b.readers); len(got) != 2 {
+		t.Fatalf("readers() = %v", got)
+	}
+	if n := testing.AllocsPerRun(100, func() { p.readers(sdk, b.readers) }); n != 0 {
+		t.Errorf("polling unchanged readers allocates %v times", n)
+	}
+
+	sdk.setBusy("reader 0", true)
+	if got, _ := p.readers(sdk, b.readers); len(got) != 1 || got[0].Name != "reader 1" {
+		t.Errorf("readers() with a busy reader = %v", got)
+	}
+	if n := testing.AllocsPerRun(100, func() { p.readers(sdk, b.readers) }); n != 0 {
+		t.Errorf("polling with a busy reader allocates %v times", n)
+	}
+}
+
+func BenchmarkPollReaders(b *testing.B) {
+	backend := newIdleBackend()
+	sdk := New(WithBackend(backend))
+	var p poller
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.readers(sdk, backend.readers)
+	}
+}
+
+func BenchmarkWaitCardIdle(b *testing.B) {
+	backend := newIdleBackend()
+	backend.polls = b.N
+	sdk := New(WithBackend(backend))
+	b.ReportAllocs()
+	b.ResetTimer()
+	if _, _, err := sdk.waitCard(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+}
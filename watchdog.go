@@ -0,0 +1,121 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+)
+
+// ReaderHealth is the health of a reader as seen by the watchdog.
+type ReaderHealth uint8
+
+const (
+	HealthUnknown     ReaderHealth = iota // Not checked yet.
+	HealthOK                              // Reader responds.
+	HealthDegraded                        // Reader holds a mute card or its state could not be read.
+	HealthUnavailable                     // Reader is gone or reported unavailable.
+)
+
+// String returns the name of the health.
+func (h ReaderHealth) String() string {
+	switch h {
+	case HealthOK:
+		return "ok"
+	case HealthDegraded:
+		return "degraded"
+	case HealthUnavailable:
+		return "unavailable"
+	default:
+		return "unknown"
+	}
+}
+
+// WithWatchdog makes Run check every interval that the selected readers
+// still respond, querying their state without waiting, instead of finding
+// out when a tap fails. Health changes are logged and emitted as reader
+// health events, see Health.
+func WithWatchdog(interval time.Duration) Option {
+	return func(sdk *SDK) {
+		sdk.watchdog = interval
+	}
+}
+
+// Health returns the health of the readers checked by the watchdog.
+func (sdk *SDK) Health() map[string]ReaderHealth {
+	sdk.mu.RLock()
+	defer sdk.mu.RUnlock()
+	health := make(map[string]ReaderHealth, len(sdk.health))
+	for name, h := range sdk.health {
+		health[name] = h
+	}
+	return health
+}
+
+// watch checks the readers every interval until ctx is done.
+func (sdk *SDK) watch(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		sdk.checkReaders()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// checkReaders updates the health of the selected readers and of the
+// readers checked before.
+func (sdk *SDK) checkReaders() {
+	infos, err := sdk.Readers()
+	health := make(map[string]ReaderHealth, len(infos))
+	for _, info := range infos {
+		switch {
+		case info.State&(pcsc.StateUnavailable|pcsc.StateUnknown) != 0:
+			health[info.Name] = HealthUnavailable
+		case info.Mute():
+			health[info.Name] = HealthDegraded
+		default:
+			health[info.Name] = HealthOK
+		}
+	}
+
+	var events []cardreader.Event
+	sdk.mu.Lock()
+	for name := range sdk.health {
+		if _, ok := health[name]; !ok {
+			if err != nil {
+				// The resource manager failed, not the reader.
+				health[name] = HealthDegraded
+			} else {
+				health[name] = HealthUnavailable
+			}
+		}
+	}
+	for name, h := range health {
+		if sdk.health[name] != h {
+			events = append(events, cardreader.Event{Type: cardreader.EventReaderHealth, Reader: name, Reason: h.String()})
+		}
+	}
+	sdk.health = health
+	sdk.mu.Unlock()
+
+	if err != nil {
+		sdk.logger.Warn("watchdog could not check readers", "error", err)
+	}
+	for _, ev := range events {
+		if ev.Reason != HealthOK.String() {
+			sdk.logger.Warn("reader health changed", "reader", ev.Reader, "health", ev.Reason)
+		} else {
+			sdk.logger.Info("reader health changed", "reader", ev.Reader, "health", ev.Reason)
+		}
+		sdk.emit(ev)
+	}
+}
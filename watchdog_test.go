@@ -0,0 +1,90 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// stateBackend reports the reader states it holds.
+type stateBackend struct {
+	states map[string]pcsc.State
+	err    error
+}
+
+func (b *stateBackend) ListReaders() ([]cardreader.Reader, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	var readers []cardreader.Reader
+	for name := range b.states {
+		readers = append(readers, *cardreader.NewReader(name))
+	}
+	return readers, nil
+}
+
+func (b *stateBackend) WaitCard(ctx context.Context, _ []cardreader.Reader, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	<-ctx.Done()
+	return nil, cardreader.Reader{}, ctx.Err()
+}
+
+func (b *stateBackend) readerStates(readers []cardreader.Reader) ([]ReaderInfo, error) {
+	infos := make([]ReaderInfo, len(readers))
+	for i, r := range readers {
+		infos[i] = ReaderInfo{Name: r.Name, State: b.states[r.Name]}
+	}
+	return infos, nil
+}
+
+func TestWatchdog(t *testing.T) {
+	b := &stateBackend{states: map[string]pcsc.State{"r0": pcsc.StateEmpty, "r1": pcsc.StatePresent}}
+	events := make(chan cardreader.Event, 16)
+	sdk := New(WithBackend(b), WithEventSink(ChanSink(events), 0))
+
+	steps := []struct {
+		name   string
+		change func()
+		want   map[string]ReaderHealth
+		events int
+	}{
+		{"first check", func() {}, map[string]ReaderHealth{"r0": HealthOK, "r1": HealthOK}, 2},
+		{"unchanged", func() {}, map[string]ReaderHealth{"r0": HealthOK, "r1": HealthOK}, 0},
+		{"mute card", func() { b.states["r1"] = pcsc.StatePresent | pcsc.StateMute }, map[string]ReaderHealth{"r0": HealthOK, "r1": HealthDegraded}, 1},
+		{"unplugged", func() { delete(b.states, "r0") }, map[string]ReaderHealth{"r0": HealthUnavailable, "r1": HealthDegraded}, 1},
+		{"resource manager down", func() { b.err = errors.New("no service") }, map[string]ReaderHealth{"r0": HealthDegraded, "r1": HealthDegraded}, 1},
+	}
+	for _, s := range steps {
+		s.change()
+		sdk.checkReaders()
+		got := sdk.Health()
+		for name, h := range s.want {
+			if got[name] != h {
+				t.Errorf("%s: Health()[%s] = %v, want %v", s.name, name, got[name], h)
+			}
+		}
+		for i := 0; i < s.events; i++ {
+			select {
+			case ev := <-events:
+				if ev.Type != cardreader.EventReaderHealth || ev.Reason != s.want[ev.Reader].String() {
+					t.Errorf("%s: event %v %s %s", s.name, ev.Type, ev.Reader, ev.Reason)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s: missing health event", s.name)
+			}
+		}
+		select {
+		case ev := <-events:
+			t.Errorf("%s: unexpected event %v %s %s", s.name, ev.Type, ev.Reader, ev.Reason)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package emulation turns a PN532 based reader into an ISO 14443-4 card.
+// The chip, reached through a pn532.Link, is configured as target with
+// TgInitAsTarget and the commands of the remote reader, e.g. a phone, are
+// served by an apdu.Transceiver such as the virtual NDEF application of
+// Type4Tag.
+package emulation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/x/pn532"
+)
+
+// PN532 command codes used for target mode.
+const (
+	CmdTgInitAsTarget = 0x8C
+	CmdTgGetData      = 0x86
+	CmdTgSetData      = 0x8E
+)
+
+// Target configures the card emulated by the chip.
+type Target struct {
+	// UID holds the last three bytes of the 4 byte NFCID1; the PN532 fixes
+	// the first byte to 08h, marking a random UID.
+	UID [3]byte
+	// Historical holds the historical bytes of the ATS.
+	Historical []byte
+}
+
+// InitAsTarget configures chip as ISO 14443-4 PICC and waits until a reader
+// activates it. It returns the first command of the reader.
+func InitAsTarget(chip pn532.Link, t Target) ([]byte, error) {
+	params := []byte{0x05}                       // PICC only, passive only.
+	params = append(params, 0x04, 0x00)          // SENS_RES
+	params = append(params, t.UID[:]...)         // NFCID1t
+	params = append(params, 0x20)                // SEL_RES: ISO 14443-4 compliant.
+	params = append(params, make([]byte, 18)...) // FeliCa parameters, unused.
+	params = append(params, make([]byte, 10)...) // NFCID3t, unused.
+	params = append(params, 0x00)                // No general bytes.
+	params = append(append(params, byte(len(t.Historical))), t.Historical...)
+	resp, err := chip.Command(CmdTgInitAsTarget, params)
+	if err != nil {
+		return nil, fmt.Errorf("pn532: init as target: %w", err)
+	}
+	if len(resp) < 1 {
+		return nil, fmt.Errorf("pn532: init as target: empty response")
+	}
+	return resp[1:], nil
+}
+
+// GetData returns the next command sent by the reader.
+func GetData(chip pn532.Link) ([]byte, error) {
+	resp, err := chip.Command(CmdTgGetData, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := pn532.CheckStatus(resp); err != nil {
+		return nil, err
+	}
+	return resp[1:], nil
+}
+
+// SetData sends the response to the last command of the reader.
+func SetData(chip pn532.Link, data []byte) error {
+	resp, err := chip.Command(CmdTgSetData, data)
+	if err != nil {
+		return err
+	}
+	return pn532.CheckStatus(resp)
+}
+
+// Serve emulates the card t, passing the commands of each reader that
+// activates it to handler and returning its responses, until ctx is done.
+// A session ends when the chip reports an error, typically because the
+// reader released the target or left the field, and the chip is then
+// configured as target again. Other errors stop Serve. A handler error
+// answers the command with 6F 00. ctx is checked between exchanges; a
+// pending TgInitAsTarget is not interrupted.
+func Serve(ctx context.Context, chip pn532.Link, t Target, handler apdu.Transceiver) error {
+	var chipErr pn532.Error
+	for ctx.Err() == nil {
+		cmd, err := InitAsTarget(chip, t)
+		if err == nil {
+			err = serveSession(ctx, chip, handler, cmd)
+		}
+		if err != nil && !errors.As(err, &chipErr) && ctx.Err() == nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func serveSession(ctx context.Context, chip pn532.Link, handler apdu.Transceiver, cmd []byte) error {
+	for {
+		// The first command may be missing when the reader only activated
+		// the target.
+		if len(cmd) > 0 {
+			resp, err := handler.Transmit(cmd)
+			if err != nil {
+				resp = []byte{0x6F, 0x00}
+			}
+			if err := SetData(chip, resp); err != nil {
+				return err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		if cmd, err = GetData(chip); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package emulation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/x/pn532"
+)
+
+// type4Card presents a Type4Tag as an ISO 14443-4 card to the tag package.
+type type4Card struct{ *Type4Tag }
+
+func (type4Card) ATR() []byte { return []byte{0x3B, 0x81, 0x80, 0x01, 0x80, 0x80} }
+
+func TestType4Tag(t *testing.T) {
+	msg := []byte{0xD1, 0x01, 0x04, 0x54, 0x02, 'e', 'n', 'A'}
+	vt := NewType4Tag(msg, false)
+	var written []byte
+	vt.OnWrite = func(m []byte) { written = m }
+
+	got, err := tag.ReadNDEF(type4Card{vt})
+	if err != nil {
+		t.Fatalf("ReadNDEF() error = %v", err)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("ReadNDEF() = % X, want % X", got, msg)
+	}
+
+	long := append([]byte{0xD1, 0x01, 0x00, 0x54}, bytes.Repeat([]byte{'x'}, 600)...)
+	if err := tag.WriteNDEF(type4Card{vt}, long); err != nil {
+		t.Fatalf("WriteNDEF() error = %v", err)
+	}
+	if !bytes.Equal(written, long) || !bytes.Equal(vt.Message(), long) {
+		t.Errorf("OnWrite got %d bytes, Message() %d bytes; want %d", len(written), len(vt.Message()), len(long))
+	}
+
+	ro := NewType4Tag(msg, true)
+	if err := tag.WriteNDEF(type4Card{ro}, long); err == nil {
+		t.Errorf("WriteNDEF() to read-only tag succeeded")
+	}
+}
+
+func TestServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	selectApp := []byte{0x00, 0xA4, 0x04, 0x00, 0x07, 0xD2, 0x76, 0x00, 0x00, 0x85, 0x01, 0x01, 0x00}
+	readBinary := []byte{0x00, 0xB0, 0x00, 0x00, 0x0F}
+	commands := [][]byte{readBinary}
+	var inits int
+	var responses [][]byte
+
+	chip := pn532.LinkFunc(func(code byte, params []byte) ([]byte, error) {
+		switch code {
+		case CmdTgInitAsTarget:
+			if inits++; inits > 1 {
+				cancel()
+				return []byte{0x04}, nil
+			}
+			return append([]byte{0x04}, selectApp...), nil
+		case CmdTgGetData:
+			if len(commands) == 0 {
+				return []byte{0x29}, nil
+			}
+			cmd := commands[0]
+			commands = commands[1:]
+			return append([]byte{0x00}, cmd...), nil
+		case CmdTgSetData:
+			responses = append(responses, params)
+			return []byte{0x00}, nil
+		}
+		return nil, errors.New("unexpected command")
+	})
+
+	err := Serve(ctx, chip, Target{UID: [3]byte{1, 2, 3}}, NewType4Tag(nil, true))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Serve() error = %v, want context.Canceled", err)
+	}
+	if inits != 2 {
+		t.Errorf("TgInitAsTarget called %d times, want 2", inits)
+	}
+	// SELECT succeeds; READ BINARY without a selected file is refused.
+	want := [][]byte{{0x90, 0x00}, {0x69, 0x86}}
+	if len(responses) != len(want) || !bytes.Equal(responses[0], want[0]) || !bytes.Equal(responses[1], want[1]) {
+		t.Errorf("responses = % X, want % X", responses, want)
+	}
+}
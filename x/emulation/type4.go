@@ -0,0 +1,172 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package emulation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+)
+
+// DefaultNDEFFileSize is the size of the NDEF file of a Type4Tag, including
+// the 2 byte length field.
+const DefaultNDEFFileSize = 0x0400
+
+// Maximum data sizes of READ BINARY responses and UPDATE BINARY commands
+// announced in the capability container.
+const (
+	type4MLe = 0xF6
+	type4MLc = 0xF4
+)
+
+var (
+	ndefAID    = []byte{0xD2, 0x76, 0x00, 0x00, 0x85, 0x01, 0x01}
+	ccFileID   = []byte{0xE1, 0x03}
+	ndefFileID = []byte{0xE1, 0x04}
+)
+
+// Type4Tag is a virtual NFC Forum Type 4 Tag NDEF application (version
+// 2.0). It implements apdu.Transceiver and is typically passed to Serve.
+type Type4Tag struct {
+	// OnWrite, when set, is called with the NDEF message written by a
+	// reader once it completes the write by setting the message length.
+	OnWrite func(msg []byte)
+
+	mu       sync.Mutex
+	cc       []byte
+	ndef     []byte // NLEN followed by the message.
+	readOnly bool
+	appSel   bool // NDEF application selected.
+	selected byte // Selected file, fileNone, fileCC or fileNDEF.
+}
+
+// NewType4Tag returns a tag holding msg, the raw encoding of an NDEF
+// message, in an NDEF file of DefaultNDEFFileSize bytes or larger when msg
+// requires it. Readers may replace the message unless readOnly is set.
+func NewType4Tag(msg []byte, readOnly bool) *Type4Tag {
+	size := max(DefaultNDEFFileSize, len(msg)+2)
+	t := &Type4Tag{ndef: make([]byte, size), readOnly: readOnly}
+	binary.BigEndian.PutUint16(t.ndef, uint16(len(msg)))
+	copy(t.ndef[2:], msg)
+
+	write := byte(0x00)
+	if readOnly {
+		write = 0xFF
+	}
+	// CCLEN, mapping version 2.0, MLe, MLc and the NDEF file control TLV.
+	t.cc = []byte{
+		0x00, 0x0F, 0x20, 0x00, type4MLe, 0x00, type4MLc,
+		0x04, 0x06, ndefFileID[0], ndefFileID[1], byte(size >> 8), byte(size), 0x00, write,
+	}
+	return t
+}
+
+// Message returns the NDEF message currently stored.
+func (t *Type4Tag) Message() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := int(binary.BigEndian.Uint16(t.ndef))
+	return append([]byte(nil), t.ndef[2:2+min(n, len(t.ndef)-2)]...)
+}
+
+const (
+	fileNone = iota
+	fileCC
+	fileNDEF
+)
+
+var (
+	swOK              = []byte{0x90, 0x00}
+	swWrongLength     = []byte{0x67, 0x00}
+	swNotAllowed      = []byte{0x69, 0x86}
+	swFileNotFound    = []byte{0x6A, 0x82}
+	swWrongParameters = []byte{0x6B, 0x00}
+	swInsNotSupported = []byte{0x6D, 0x00}
+)
+
+// Transmit processes a command APDU of the reader and returns the response.
+func (t *Type4Tag) Transmit(cmd []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(cmd) < 4 {
+		return swWrongLength, nil
+	}
+	switch cmd[1] {
+	case 0xA4:
+		return t.selectFile(cmd), nil
+	case 0xB0:
+		return t.readBinary(cmd), nil
+	case 0xD6:
+		resp, written := t.updateBinary(cmd)
+		if written != nil && t.OnWrite != nil {
+			t.mu.Unlock()
+			t.OnWrite(written)
+			t.mu.Lock()
+		}
+		return resp, nil
+	default:
+		return swInsNotSupported, nil
+	}
+}
+
+func (t *Type4Tag) selectFile(cmd []byte) []byte {
+	if len(cmd) < 5 || len(cmd) < 5+int(cmd[4]) {
+		return swWrongLength
+	}
+	id := cmd[5 : 5+int(cmd[4])]
+	switch {
+	case cmd[2] == 0x04 && bytes.Equal(id, ndefAID):
+		t.appSel, t.selected = true, fileNone
+	case cmd[2] == 0x00 && t.appSel && bytes.Equal(id, ccFileID):
+		t.selected = fileCC
+	case cmd[2] == 0x00 && t.appSel && bytes.Equal(id, ndefFileID):
+		t.selected = fileNDEF
+	default:
+		return swFileNotFound
+	}
+	return swOK
+}
+
+func (t *Type4Tag) readBinary(cmd []byte) []byte {
+	file := t.ndef
+	switch t.selected {
+	case fileNone:
+		return swNotAllowed
+	case fileCC:
+		file = t.cc
+	}
+	off := int(cmd[2])<<8 | int(cmd[3])
+	n := 256
+	if len(cmd) > 4 && cmd[4] != 0 {
+		n = int(cmd[4])
+	}
+	n = min(n, type4MLe)
+	if off > len(file) {
+		return swWrongParameters
+	}
+	data := file[off:min(off+n, len(file))]
+	return append(append([]byte(nil), data...), swOK...)
+}
+
+// updateBinary writes to the NDEF file and returns the message when the
+// write set a non-zero message length.
+func (t *Type4Tag) updateBinary(cmd []byte) ([]byte, []byte) {
+	if t.selected != fileNDEF || t.readOnly {
+		return swNotAllowed, nil
+	}
+	if len(cmd) < 5 || len(cmd) != 5+int(cmd[4]) {
+		return swWrongLength, nil
+	}
+	off, data := int(cmd[2])<<8|int(cmd[3]), cmd[5:]
+	if off+len(data) > len(t.ndef) {
+		return swWrongParameters, nil
+	}
+	copy(t.ndef[off:], data)
+	n := int(binary.BigEndian.Uint16(t.ndef))
+	if off >= 2 || n == 0 || n > len(t.ndef)-2 {
+		return swOK, nil
+	}
+	return swOK, append([]byte(nil), t.ndef[2:2+n]...)
+}
@@ -0,0 +1,238 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package loadgen drives applications built on scardkit with simulated card
+// taps for soak testing. It generates taps at a configurable rate across a
+// number of simulated readers, injects reader faults following an error
+// profile and reports handler latency drift, goroutine growth and heap growth
+// over runs that may last hours.
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+)
+
+// Profile configures a load generation run.
+type Profile struct {
+	Readers  int           // Number of simulated readers, at least 1.
+	TapRate  float64       // Taps per second on each reader.
+	Duration time.Duration // Duration of the run, zero runs until ctx is done.
+	Window   time.Duration // Length of a latency reporting window, default one minute.
+	Faults   []Fault       // Faults injected into taps.
+	Seed     int64         // Seed of the random source, zero uses the current time.
+}
+
+// Fault injects Err into a fraction of the taps.
+type Fault struct {
+	Err  error
+	Rate float64 // Fraction of taps receiving the fault, between 0 and 1.
+}
+
+// DefaultFaults is an error profile of common transient reader faults.
+var DefaultFaults = []Fault{
+	{Err: pcsc.ErrCardRemoved, Rate: 0.01},
+	{Err: pcsc.ErrTimeout, Rate: 0.005},
+}
+
+// Tap is a simulated card tap handed to the handler under test.
+type Tap struct {
+	cardreader.Event
+	// Fault is the error the simulated reader reports for the tap, nil for
+	// healthy taps. Handlers should treat it like an error from the reader.
+	Fault error
+}
+
+// Handler handles a simulated tap.
+type Handler func(ctx context.Context, tap Tap) error
+
+// Window holds handler latency statistics of a reporting window.
+type Window struct {
+	Start time.Time
+	Taps  int
+	Mean  time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Report summarizes a load generation run.
+type Report struct {
+	Taps           int
+	HandlerErrors  int
+	InjectedFaults int
+	Windows        []Window
+
+	GoroutinesStart int
+	GoroutinesEnd   int
+	HeapStart       uint64 // Heap bytes in use at the start of the run.
+	HeapEnd         uint64 // Heap bytes in use at the end of the run.
+}
+
+// LatencyDrift returns the change of the mean handler latency between the
+// first and the last reporting window.
+func (r *Report) LatencyDrift() time.Duration {
+	if len(r.Windows) < 2 {
+		return 0
+	}
+	return r.Windows[len(r.Windows)-1].Mean - r.Windows[0].Mean
+}
+
+// GoroutineGrowth returns the number of goroutines gained during the run.
+func (r *Report) GoroutineGrowth() int { return r.GoroutinesEnd - r.GoroutinesStart }
+
+// Run generates taps following p and hands them to h until the configured
+// duration elapses or ctx is done. Taps of one reader are handled
+// sequentially, taps of different readers concurrently.
+func Run(ctx context.Context, p Profile, h Handler) (*Report, error) {
+	if p.Readers < 1 {
+		return nil, fmt.Errorf("loadgen: at least one reader required")
+	}
+	if p.TapRate <= 0 {
+		return nil, fmt.Errorf("loadgen: tap rate must be positive")
+	}
+	if p.Window <= 0 {
+		p.Window = time.Minute
+	}
+	if p.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Duration)
+		defer cancel()
+	}
+	seed := p.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	g := &generator{profile: p, handler: h, start: time.Now()}
+	g.report.GoroutinesStart, g.report.HeapStart = runtimeStats()
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.Readers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			g.reader(ctx, i, rand.New(rand.NewSource(seed+int64(i))))
+		}(i)
+	}
+	wg.Wait()
+
+	g.flush()
+	g.report.GoroutinesEnd, g.report.HeapEnd = runtimeStats()
+	if err := ctx.Err(); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return &g.report, err
+	}
+	return &g.report, nil
+}
+
+type generator struct {
+	profile Profile
+	handler Handler
+	start   time.Time
+
+	mu        sync.Mutex
+	report    Report
+	window    time.Time
+	latencies []time.Duration
+}
+
+func (g *generator) reader(ctx context.Context, idx int, rnd *rand.Rand) {
+	name := fmt.Sprintf("loadgen reader %02d", idx)
+	interval := time.Duration(float64(time.Second) / g.profile.TapRate)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			uid := make([]byte, 7)
+			rnd.Read(uid)
+			tap := Tap{
+				Event: cardreader.Event{
+					Type:   cardreader.EventCardInserted,
+					Reader: name,
+					UID:    uid,
+					Time:   now,
+				},
+				Fault: g.fault(rnd),
+			}
+			began := time.Now()
+			err := g.handler(ctx, tap)
+			g.record(began, time.Since(began), tap.Fault != nil, err != nil)
+		}
+	}
+}
+
+func (g *generator) fault(rnd *rand.Rand) error {
+	x := rnd.Float64()
+	for _, f := range g.profile.Faults {
+		if x < f.Rate {
+			return f.Err
+		}
+		x -= f.Rate
+	}
+	return nil
+}
+
+func (g *generator) record(at time.Time, latency time.Duration, injected, failed bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.window.IsZero() {
+		g.window = g.start
+	}
+	if at.Sub(g.window) >= g.profile.Window {
+		g.flushLocked()
+		g.window = at
+	}
+	g.latencies = append(g.latencies, latency)
+	g.report.Taps++
+	if injected {
+		g.report.InjectedFaults++
+	}
+	if failed {
+		g.report.HandlerErrors++
+	}
+}
+
+func (g *generator) flush() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.flushLocked()
+}
+
+func (g *generator) flushLocked() {
+	if len(g.latencies) == 0 {
+		return
+	}
+	sort.Slice(g.latencies, func(i, j int) bool { return g.latencies[i] < g.latencies[j] })
+	var sum time.Duration
+	for _, l := range g.latencies {
+		sum += l
+	}
+	n := len(g.latencies)
+	g.report.Windows = append(g.report.Windows, Window{
+		Start: g.window,
+		Taps:  n,
+		Mean:  sum / time.Duration(n),
+		P99:   g.latencies[(n*99)/100],
+		Max:   g.latencies[n-1],
+	})
+	g.latencies = g.latencies[:0]
+}
+
+func runtimeStats() (goroutines int, heap uint64) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return runtime.NumGoroutine(), ms.HeapInuse
+}
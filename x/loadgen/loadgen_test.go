@@ -0,0 +1,49 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	errFault := errors.New("injected")
+	p := Profile{
+		Readers:  3,
+		TapRate:  200,
+		Duration: 200 * time.Millisecond,
+		Window:   50 * time.Millisecond,
+		Faults:   []Fault{{Err: errFault, Rate: 0.5}},
+		Seed:     1,
+	}
+
+	report, err := Run(context.Background(), p, func(ctx context.Context, tap Tap) error {
+		if len(tap.UID) == 0 || tap.Reader == "" {
+			t.Errorf("tap without reader or uid: %+v", tap)
+		}
+		return tap.Fault
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if report.Taps == 0 {
+		t.Fatal("Run() generated no taps")
+	}
+	if report.InjectedFaults == 0 || report.InjectedFaults != report.HandlerErrors {
+		t.Errorf("injected %d faults, handler errors %d", report.InjectedFaults, report.HandlerErrors)
+	}
+	if len(report.Windows) < 2 {
+		t.Errorf("got %d reporting windows, want at least 2", len(report.Windows))
+	}
+}
+
+func TestRunInvalidProfile(t *testing.T) {
+	if _, err := Run(context.Background(), Profile{TapRate: 1}, nil); err == nil {
+		t.Error("Run() accepted a profile without readers")
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pn532
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/protocols/iso14443"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// DefaultRetries is the number of activation attempts of a single
+// InListPassiveTarget issued by Backend, roughly 150 ms.
+const DefaultRetries = 0x10
+
+// Backend presents a Device to the SDK as a single reader.
+type Backend struct {
+	dev    *Device
+	reader cardreader.Reader
+
+	once    sync.Once
+	initErr error
+}
+
+// NewBackend returns a backend reading cards with dev and presenting it as
+// the reader name.
+func NewBackend(dev *Device, name string) *Backend {
+	return &Backend{dev: dev, reader: *cardreader.NewReader(name)}
+}
+
+// ListReaders returns the reader of the device.
+func (b *Backend) ListReaders() ([]cardreader.Reader, error) {
+	return []cardreader.Reader{b.reader}, nil
+}
+
+// WaitCard polls for a card until one is activated or timeout elapses.
+func (b *Backend) WaitCard(ctx context.Context, readers []cardreader.Reader, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	b.once.Do(func() { b.initErr = b.dev.Init(DefaultRetries) })
+	if b.initErr != nil {
+		return nil, cardreader.Reader{}, b.initErr
+	}
+	selected := false
+	for _, r := range readers {
+		selected = selected || r.Name == b.reader.Name
+	}
+	deadline := time.Now().Add(timeout)
+	for selected && time.Now().Before(deadline) {
+		if err := ctx.Err(); err != nil {
+			return nil, cardreader.Reader{}, err
+		}
+		t, err := b.dev.InListPassiveTarget()
+		if err != nil {
+			return nil, cardreader.Reader{}, err
+		}
+		if t != nil {
+			return newCard(b.dev, t), b.reader, nil
+		}
+	}
+	if !selected {
+		select {
+		case <-ctx.Done():
+			return nil, cardreader.Reader{}, ctx.Err()
+		case <-time.After(timeout):
+		}
+	}
+	return nil, cardreader.Reader{}, nil
+}
+
+// Card is a card activated by a Device. Besides the commands of ISO
+// 14443-4 cards it handles the PC/SC Part 3 pseudo-APDUs GET DATA for the
+// UID and READ BINARY and UPDATE BINARY for MIFARE Ultralight, NTAG and
+// authenticated MIFARE Classic blocks.
+type Card struct {
+	dev    *Device
+	target *Target
+	atr    []byte
+}
+
+func newCard(dev *Device, t *Target) *Card {
+	return &Card{dev: dev, target: t, atr: contactlessATR(t)}
+}
+
+// Target returns the activation data of the card.
+func (c *Card) Target() *Target { return c.target }
+
+// ATQA returns the ATQA (SENS_RES) of the card.
+func (c *Card) ATQA() uint16 { return c.target.SensRes }
+
+// SAK returns the SAK (SEL_RES) of the card.
+func (c *Card) SAK() byte { return c.target.SelRes }
+
+// ATR returns the ATR constructed for the card as defined in PC/SC Part 3.
+func (c *Card) ATR() []byte { return c.atr }
+
+// UID returns the UID of the card.
+func (c *Card) UID() ([]byte, error) { return c.target.UID, nil }
+
+// Disconnect releases the card.
+func (c *Card) Disconnect() error { return c.dev.InRelease(c.target.Tg) }
+
+var (
+	swOK             = []byte{0x90, 0x00}
+	swWrongLength    = []byte{0x67, 0x00}
+	swNotSupported   = []byte{0x6A, 0x81}
+	swOperationError = []byte{0x63, 0x00}
+)
+
+// Transmit sends cmd to the card.
+func (c *Card) Transmit(cmd []byte) ([]byte, error) {
+	if len(cmd) >= 4 && cmd[0] == 0xFF {
+		return c.pseudoAPDU(cmd)
+	}
+	resp, err := c.dev.InDataExchange(c.target.Tg, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("pn532: in data exchange: %w", err)
+	}
+	return resp, nil
+}
+
+func (c *Card) pseudoAPDU(cmd []byte) ([]byte, error) {
+	switch cmd[1] {
+	case 0xCA:
+		if cmd[2] != 0x00 {
+			return swNotSupported, nil
+		}
+		return append(append([]byte(nil), c.target.UID...), swOK...), nil
+	case 0xB0:
+		n := 16
+		if len(cmd) > 4 && cmd[4] != 0 {
+			n = int(cmd[4])
+		}
+		if n > 16 {
+			return swWrongLength, nil
+		}
+		data, err := c.dev.InDataExchange(c.target.Tg, []byte{0x30, cmd[3]})
+		if err != nil {
+			return failed(err)
+		}
+		if len(data) < n {
+			return swOperationError, nil
+		}
+		return append(data[:n:n], swOK...), nil
+	case 0xD6:
+		if len(cmd) < 5 || len(cmd) != 5+int(cmd[4]) {
+			return swWrongLength, nil
+		}
+		var native []byte
+		switch cmd[4] {
+		case 4:
+			native = append([]byte{0xA2, cmd[3]}, cmd[5:]...) // Ultralight WRITE
+		case 16:
+			native = append([]byte{0xA0, cmd[3]}, cmd[5:]...) // MIFARE WRITE
+		default:
+			return swWrongLength, nil
+		}
+		if _, err := c.dev.InDataExchange(c.target.Tg, native); err != nil {
+			return failed(err)
+		}
+		return swOK, nil
+	default:
+		return swNotSupported, nil
+	}
+}
+
+// failed maps an error reported by the chip for a native command to the
+// status words 63 00, as PC/SC readers do, and returns other errors.
+func failed(err error) ([]byte, error) {
+	var perr Error
+	if errors.As(err, &perr) {
+		return swOperationError, nil
+	}
+	return nil, fmt.Errorf("pn532: in data exchange: %w", err)
+}
+
+// contactlessATR constructs the ATR a PC/SC reader reports for t: the ATS
+// historical bytes for ISO 14443-4 cards, the storage card RID and card
+// name for others.
+func contactlessATR(t *Target) []byte {
+	var hist []byte
+	if t.ISO14443_4() {
+		if ats, err := iso14443.ParseATS(t.ATS); err == nil {
+			hist = ats.Historical
+		}
+	} else {
+		var name byte
+		switch t.SelRes {
+		case 0x00:
+			name = 0x03 // MIFARE Ultralight and NTAG
+		case 0x08:
+			name = 0x01 // MIFARE Classic 1K
+		case 0x18:
+			name = 0x02 // MIFARE Classic 4K
+		case 0x09:
+			name = 0x26 // MIFARE Mini
+		}
+		hist = []byte{0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06, 0x03, 0x00, name, 0x00, 0x00, 0x00, 0x00}
+	}
+	if len(hist) > 15 {
+		hist = hist[:15]
+	}
+	atr := append([]byte{0x3B, 0x80 | byte(len(hist)), 0x80, 0x01}, hist...)
+	var tck byte
+	for _, b := range atr[1:] {
+		tck ^= b
+	}
+	return append(atr, tck)
+}
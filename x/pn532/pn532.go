@@ -0,0 +1,212 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package pn532 drives NXP PN532 NFC controllers directly, without a PC/SC
+// resource manager, and provides them to the SDK as transport.Backend. It
+// suits embedded Linux devices with PN532 boards attached over UART, I2C or
+// SPI. A board on a USB serial adapter is used with:
+//
+//	link, err := pn532.OpenSerial("/dev/ttyUSB0", 115200)
+//	...
+//	sdk := scardkit.New(scardkit.WithBackend(pn532.NewBackend(pn532.New(link), "PN532")))
+package pn532
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/happy-sdk/scardkit/apdu"
+)
+
+// Command codes of the PN532.
+const (
+	CmdGetFirmwareVersion  = 0x02
+	CmdSAMConfiguration    = 0x14
+	CmdPowerDown           = 0x16
+	CmdRFConfiguration     = 0x32
+	CmdInDataExchange      = 0x40
+	CmdInListPassiveTarget = 0x4A
+	CmdInRelease           = 0x52
+)
+
+// Link carries commands to a PN532, e.g. over the host frame protocol of a
+// serial port or through a reader, see ACR122U. Command sends the command
+// code with its parameters and returns the response data following the
+// response code.
+type Link interface {
+	Command(code byte, params []byte) ([]byte, error)
+}
+
+// LinkFunc adapts a function to the Link interface.
+type LinkFunc func(code byte, params []byte) ([]byte, error)
+
+// Command calls f(code, params).
+func (f LinkFunc) Command(code byte, params []byte) ([]byte, error) { return f(code, params) }
+
+// Error is a PN532 status byte reporting a failure.
+type Error byte
+
+func (e Error) Error() string {
+	switch e {
+	case 0x01:
+		return "pn532: timeout"
+	case 0x02:
+		return "pn532: crc error"
+	case 0x03:
+		return "pn532: parity error"
+	case 0x13:
+		return "pn532: rf buffer overflow"
+	case 0x14:
+		return "pn532: mifare authentication error"
+	case 0x25:
+		return "pn532: invalid state"
+	case 0x27:
+		return "pn532: command not acceptable in context"
+	case 0x29:
+		return "pn532: target released"
+	default:
+		return fmt.Sprintf("pn532: error 0x%02X", byte(e))
+	}
+}
+
+// ErrReleased is returned when the target was released, in target mode by
+// the remote reader.
+var ErrReleased = Error(0x29)
+
+// Device is a PN532 reached through a link. Its methods may be called
+// concurrently.
+type Device struct {
+	mu   sync.Mutex
+	link Link
+}
+
+// New returns the PN532 reached through link.
+func New(link Link) *Device { return &Device{link: link} }
+
+func (d *Device) command(code byte, params []byte) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.link.Command(code, params)
+}
+
+// FirmwareVersion returns the IC, version, revision and supported
+// protocols reported by the chip.
+func (d *Device) FirmwareVersion() (ic, ver, rev, support byte, err error) {
+	resp, err := d.command(CmdGetFirmwareVersion, nil)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	if len(resp) < 4 {
+		return 0, 0, 0, 0, fmt.Errorf("pn532: firmware version of %d bytes", len(resp))
+	}
+	return resp[0], resp[1], resp[2], resp[3], nil
+}
+
+// Init configures the chip for reading cards: the SAM is disabled and
+// InListPassiveTarget gives up after retries activation attempts, 0xFF
+// meaning forever.
+func (d *Device) Init(retries byte) error {
+	if _, err := d.command(CmdSAMConfiguration, []byte{0x01, 0x14, 0x01}); err != nil {
+		return fmt.Errorf("pn532: sam configuration: %w", err)
+	}
+	if _, err := d.command(CmdRFConfiguration, []byte{0x05, 0xFF, 0x01, retries}); err != nil {
+		return fmt.Errorf("pn532: rf configuration: %w", err)
+	}
+	return nil
+}
+
+// Target is an ISO 14443 Type A card activated by the chip.
+type Target struct {
+	Tg      byte   // Logical number assigned by the chip.
+	SensRes uint16 // ATQA
+	SelRes  byte   // SAK
+	UID     []byte
+	ATS     []byte // Answer to select of ISO 14443-4 cards, starting with TL.
+}
+
+// ISO14443_4 reports whether the target supports ISO 14443-4.
+func (t *Target) ISO14443_4() bool { return t.SelRes&0x20 != 0 }
+
+// InListPassiveTarget activates one ISO 14443 Type A card at 106 kbps. It
+// returns nil when no card answered within the activation attempts set by
+// Init.
+func (d *Device) InListPassiveTarget() (*Target, error) {
+	resp, err := d.command(CmdInListPassiveTarget, []byte{0x01, 0x00})
+	if err != nil {
+		return nil, fmt.Errorf("pn532: in list passive target: %w", err)
+	}
+	if len(resp) == 0 || resp[0] == 0 {
+		return nil, nil
+	}
+	if len(resp) < 6 || len(resp) < 6+int(resp[5]) {
+		return nil, fmt.Errorf("pn532: in list passive target: short response")
+	}
+	t := &Target{
+		Tg:      resp[1],
+		SensRes: uint16(resp[2])<<8 | uint16(resp[3]),
+		SelRes:  resp[4],
+		UID:     resp[6 : 6+int(resp[5])],
+	}
+	if rest := resp[6+int(resp[5]):]; t.ISO14443_4() && len(rest) > 0 && len(rest) >= int(rest[0]) {
+		t.ATS = rest[:rest[0]]
+	}
+	return t, nil
+}
+
+// InDataExchange sends data to the target tg and returns its response.
+func (d *Device) InDataExchange(tg byte, data []byte) ([]byte, error) {
+	resp, err := d.command(CmdInDataExchange, append([]byte{tg}, data...))
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckStatus(resp); err != nil {
+		return nil, err
+	}
+	return resp[1:], nil
+}
+
+// InRelease releases the target tg.
+func (d *Device) InRelease(tg byte) error {
+	resp, err := d.command(CmdInRelease, []byte{tg})
+	if err != nil {
+		return err
+	}
+	return CheckStatus(resp)
+}
+
+// CheckStatus returns the Error of the status byte leading resp, the
+// response data of commands such as InDataExchange and TgGetData.
+func CheckStatus(resp []byte) error {
+	if len(resp) == 0 {
+		return fmt.Errorf("pn532: missing status")
+	}
+	if s := resp[0] & 0x3F; s != 0 {
+		return Error(s)
+	}
+	return nil
+}
+
+// ACR122U returns a Link reaching the PN532 of an ACR122U reader through
+// its direct transmit pseudo-APDU FF 00 00 00. tr is typically a card
+// connected in direct mode.
+func ACR122U(tr apdu.Transceiver) Link {
+	return LinkFunc(func(code byte, params []byte) ([]byte, error) {
+		frame := append([]byte{0xD4, code}, params...)
+		if len(frame) > 0xFF {
+			return nil, fmt.Errorf("pn532: command of %d bytes", len(frame))
+		}
+		resp, err := tr.Transmit(append([]byte{0xFF, 0x00, 0x00, 0x00, byte(len(frame))}, frame...))
+		if err != nil {
+			return nil, err
+		}
+		if err := apdu.CheckStatusFromData(resp); err != nil {
+			return nil, err
+		}
+		resp = resp[:len(resp)-2]
+		if len(resp) < 2 || resp[0] != 0xD5 || resp[1] != code+1 {
+			return nil, fmt.Errorf("pn532: unexpected response % X", resp)
+		}
+		return resp[2:], nil
+	})
+}
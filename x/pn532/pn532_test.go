@@ -0,0 +1,143 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pn532
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+)
+
+// fakeNTAG emulates a PN532 with an NTAG215 in its field after absent
+// polls.
+type fakeNTAG struct {
+	mem      []byte
+	absent   int
+	released bool
+}
+
+func newFakeNTAG(msg []byte) *fakeNTAG {
+	f := &fakeNTAG{mem: make([]byte, 135*4)}
+	copy(f.mem[12:], []byte{0xE1, 0x10, 0x3E, 0x00})
+	copy(f.mem[16:], append(append([]byte{0x03, byte(len(msg))}, msg...), 0xFE))
+	return f
+}
+
+func (f *fakeNTAG) Command(code byte, params []byte) ([]byte, error) {
+	switch code {
+	case CmdSAMConfiguration, CmdRFConfiguration:
+		return nil, nil
+	case CmdInListPassiveTarget:
+		if f.absent > 0 {
+			f.absent--
+			return []byte{0x00}, nil
+		}
+		return []byte{0x01, 0x01, 0x00, 0x44, 0x00, 0x07, 0x04, 0x51, 0x6E, 0x12, 0x34, 0x56, 0x80}, nil
+	case CmdInRelease:
+		f.released = true
+		return []byte{0x00}, nil
+	case CmdInDataExchange:
+		cmd := params[1:]
+		page := int(cmd[1]) * 4
+		switch {
+		case cmd[0] == 0x30 && page < len(f.mem):
+			out := make([]byte, 16)
+			copy(out, f.mem[page:])
+			return append([]byte{0x00}, out...), nil
+		case cmd[0] == 0xA2 && page+4 <= len(f.mem):
+			copy(f.mem[page:], cmd[2:6])
+			return []byte{0x00}, nil
+		}
+		return []byte{0x01}, nil
+	}
+	return nil, Error(0x27)
+}
+
+func TestBackend(t *testing.T) {
+	msg := []byte{0xD1, 0x01, 0x04, 0x54, 0x02, 'e', 'n', 'A'}
+	f := newFakeNTAG(msg)
+	f.absent = 2
+	b := NewBackend(New(f), "PN532")
+
+	readers, err := b.ListReaders()
+	if err != nil || len(readers) != 1 || readers[0].Name != "PN532" {
+		t.Fatalf("ListReaders() = %v, %v", readers, err)
+	}
+	card, reader, err := b.WaitCard(context.Background(), readers, time.Second)
+	if err != nil || card == nil {
+		t.Fatalf("WaitCard() = %v, %v", card, err)
+	}
+	if reader.Name != "PN532" {
+		t.Errorf("reader = %q, want PN532", reader.Name)
+	}
+	if typ := tag.Detect(tag.Signature{ATR: card.ATR()}); typ != tag.TypeUltralight {
+		t.Errorf("Detect() = %s, want %s", typ, tag.TypeUltralight)
+	}
+	uid, err := card.(*Card).UID()
+	if want := []byte{0x04, 0x51, 0x6E, 0x12, 0x34, 0x56, 0x80}; err != nil || !bytes.Equal(uid, want) {
+		t.Errorf("UID() = % X, %v; want % X", uid, err, want)
+	}
+
+	got, err := tag.ReadNDEF(card.(*Card))
+	if err != nil || !bytes.Equal(got, msg) {
+		t.Fatalf("ReadNDEF() = % X, %v; want % X", got, err, msg)
+	}
+	long := append([]byte{0xD1, 0x01, 0x00, 0x54}, bytes.Repeat([]byte{'x'}, 40)...)
+	if err := tag.WriteNDEF(card.(*Card), long); err != nil {
+		t.Fatalf("WriteNDEF() error = %v", err)
+	}
+	if got, _ := tag.ReadNDEF(card.(*Card)); !bytes.Equal(got, long) {
+		t.Errorf("ReadNDEF() after write = % X, want % X", got, long)
+	}
+
+	if err := card.Disconnect(); err != nil || !f.released {
+		t.Errorf("Disconnect() = %v, released %v", err, f.released)
+	}
+}
+
+func TestContactlessATR(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Target
+		want   tag.Type
+	}{
+		{"classic 1k", Target{SelRes: 0x08}, tag.TypeMifareClassic1K},
+		{"classic 4k", Target{SelRes: 0x18}, tag.TypeMifareClassic4K},
+		{"desfire", Target{SelRes: 0x20, ATS: []byte{0x06, 0x75, 0x77, 0x81, 0x02, 0x80}}, tag.TypeDESFire},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tag.Detect(tag.Signature{ATR: contactlessATR(&tt.target)}); got != tt.want {
+				t.Errorf("Detect(% X) = %s, want %s", contactlessATR(&tt.target), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACR122U(t *testing.T) {
+	var sent []byte
+	link := ACR122U(apdu.TransceiverFunc(func(cmd []byte) ([]byte, error) {
+		sent = cmd
+		return []byte{0xD5, 0x53, 0x00, 0x90, 0x00}, nil
+	}))
+	resp, err := link.Command(CmdInDataExchange, []byte{0x01})
+	if err == nil {
+		t.Fatalf("Command() accepted mismatched response code % X", resp)
+	}
+	resp, err = link.Command(CmdInRelease, []byte{0x01})
+	if err != nil {
+		t.Fatalf("Command() error = %v", err)
+	}
+	if want := []byte{0xFF, 0x00, 0x00, 0x00, 0x03, 0xD4, 0x52, 0x01}; !bytes.Equal(sent, want) {
+		t.Errorf("sent % X, want % X", sent, want)
+	}
+	if !bytes.Equal(resp, []byte{0x00}) || CheckStatus(resp) != nil {
+		t.Errorf("Command() = % X, want 00", resp)
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pn532
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds the wait for the acknowledgement and the response
+// of a command sent over a serial link.
+const DefaultTimeout = 2 * time.Second
+
+// Frame identifiers of host to chip and chip to host frames.
+const (
+	tfiHost = 0xD4
+	tfiChip = 0xD5
+)
+
+var (
+	ackFrame  = []byte{0x00, 0x00, 0xFF, 0x00, 0xFF, 0x00}
+	nackFrame = []byte{0x00, 0x00, 0xFF, 0xFF, 0x00, 0x00}
+)
+
+// Errors of the host frame protocol.
+var (
+	ErrNoACK      = errors.New("pn532: command not acknowledged")
+	ErrChecksum   = errors.New("pn532: frame checksum mismatch")
+	ErrErrorFrame = errors.New("pn532: syntax error frame")
+)
+
+// Serial is a Link speaking the PN532 host frame protocol over a UART
+// (HSU), e.g. a USB serial adapter wired to a PN532 breakout board.
+type Serial struct {
+	// Timeout bounds the wait for the acknowledgement and the response of a
+	// command when the port supports read deadlines, DefaultTimeout when
+	// zero.
+	Timeout time.Duration
+
+	mu    sync.Mutex
+	port  io.ReadWriter
+	r     *bufio.Reader
+	awake bool
+}
+
+// NewSerial returns a link over port, typically a serial port opened with
+// OpenSerial and configured for 115200 baud, 8N1.
+func NewSerial(port io.ReadWriter) *Serial {
+	return &Serial{port: port, r: bufio.NewReader(port)}
+}
+
+// Command sends a command frame, waits for its acknowledgement and returns
+// the data of the response frame. The chip is woken up from power down
+// before the first command, after PowerDown and after a failed exchange.
+func (s *Serial) Command(code byte, params []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp, err := s.exchange(code, params)
+	if err != nil {
+		// The chip may have fallen back into power down, and bytes of a
+		// frame arriving late must not be parsed as the next response.
+		s.awake = false
+		s.r.Reset(s.port)
+	} else if code == CmdPowerDown {
+		s.awake = false
+	}
+	return resp, err
+}
+
+func (s *Serial) exchange(code byte, params []byte) ([]byte, error) {
+	if !s.awake {
+		// A long preamble wakes the chip from power down on HSU.
+		if _, err := s.port.Write([]byte{0x55, 0x55, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}); err != nil {
+			return nil, err
+		}
+		s.awake = true
+	}
+
+	frame, err := encodeFrame(append([]byte{tfiHost, code}, params...))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.port.Write(frame); err != nil {
+		return nil, err
+	}
+	s.deadline()
+	ack, err := s.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	if ack != nil {
+		return nil, fmt.Errorf("%w: got data frame % X", ErrNoACK, ack)
+	}
+
+	s.deadline()
+	resp, err := s.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("pn532: unexpected ack frame")
+	}
+	if len(resp) < 2 || resp[0] != tfiChip || resp[1] != code+1 {
+		return nil, fmt.Errorf("pn532: unexpected response % X", resp)
+	}
+	return resp[2:], nil
+}
+
+// Close closes the port when it implements io.Closer.
+func (s *Serial) Close() error {
+	if c, ok := s.port.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// deadline sets the read deadline of ports supporting one.
+func (s *Serial) deadline() {
+	d, ok := s.port.(interface{ SetReadDeadline(time.Time) error })
+	if !ok {
+		return
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	_ = d.SetReadDeadline(time.Now().Add(timeout))
+}
+
+// encodeFrame returns the normal, or for data over 254 bytes the extended,
+// information frame carrying data.
+func encodeFrame(data []byte) ([]byte, error) {
+	if len(data) > 265 {
+		return nil, fmt.Errorf("pn532: frame data of %d bytes", len(data))
+	}
+	out := []byte{0x00, 0x00, 0xFF}
+	if n := len(data); n > 254 {
+		out = append(out, 0xFF, 0xFF, byte(n>>8), byte(n), -byte(n>>8)-byte(n))
+	} else {
+		out = append(out, byte(n), -byte(n))
+	}
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	out = append(out, data...)
+	return append(out, -sum, 0x00), nil
+}
+
+// readFrame reads the next frame, returning nil data for an ACK frame.
+func (s *Serial) readFrame() ([]byte, error) {
+	// Skip the preamble up to the start code 00 FF.
+	var prev byte = 0xFF
+	for {
+		b, err := s.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if prev == 0x00 && b == 0xFF {
+			break
+		}
+		prev = b
+	}
+	var hdr [2]byte
+	if _, err := io.ReadFull(s.r, hdr[:]); err != nil {
+		return nil, err
+	}
+	var n int
+	switch {
+	case hdr == [2]byte{0x00, 0xFF}:
+		s.r.ReadByte() // Postamble
+		return nil, nil
+	case hdr == [2]byte{0xFF, 0x00}:
+		s.r.ReadByte()
+		return nil, fmt.Errorf("%w: nack", ErrNoACK)
+	case hdr == [2]byte{0xFF, 0xFF}:
+		var ext [3]byte
+		if _, err := io.ReadFull(s.r, ext[:]); err != nil {
+			return nil, err
+		}
+		if ext[0]+ext[1]+ext[2] != 0 {
+			return nil, ErrChecksum
+		}
+		n = int(ext[0])<<8 | int(ext[1])
+	default:
+		if hdr[0]+hdr[1] != 0 {
+			return nil, ErrChecksum
+		}
+		n = int(hdr[0])
+	}
+
+	body := make([]byte, n+2) // Data, DCS and postamble.
+	if _, err := io.ReadFull(s.r, body); err != nil {
+		return nil, err
+	}
+	data := body[:n]
+	var sum byte
+	for _, b := range body[:n+1] {
+		sum += b
+	}
+	if sum != 0 {
+		return nil, ErrChecksum
+	}
+	if bytes.Equal(data, []byte{0x7F}) {
+		return nil, ErrErrorFrame
+	}
+	return data, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pn532
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var baudRates = map[int]uint32{
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	57600:  syscall.B57600,
+	115200: syscall.B115200,
+	230400: syscall.B230400,
+	460800: syscall.B460800,
+	921600: syscall.B921600,
+}
+
+// OpenSerial opens the serial port at path, e.g. /dev/ttyUSB0, in raw 8N1
+// mode at baud and returns a link over it. The PN532 HSU defaults to
+// 115200 baud.
+func OpenSerial(path string, baud int) (*Serial, error) {
+	speed, ok := baudRates[baud]
+	if !ok {
+		return nil, fmt.Errorf("pn532: unsupported baud rate %d", baud)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		// TCSETS takes the speed from the CBAUD bits of Cflag; the
+		// Ispeed and Ospeed fields are missing on some architectures.
+		t := syscall.Termios{Cflag: speed | syscall.CS8 | syscall.CREAD | syscall.CLOCAL}
+		t.Cc[syscall.VMIN] = 1
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCSETS, uintptr(unsafe.Pointer(&t)))
+	})
+	if err == nil && errno != 0 {
+		err = errno
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("pn532: configure %s: %w", path, err)
+	}
+	return NewSerial(f), nil
+}
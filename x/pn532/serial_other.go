@@ -0,0 +1,16 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build !linux
+
+package pn532
+
+import "fmt"
+
+// OpenSerial opens the serial port at path in raw 8N1 mode at baud. It is
+// only supported on Linux; elsewhere configure the port with other means and
+// pass it to NewSerial.
+func OpenSerial(path string, baud int) (*Serial, error) {
+	return nil, fmt.Errorf("pn532: opening serial ports is not supported on this platform")
+}
@@ -0,0 +1,134 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package pn532
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// fakePort replies to every write of a command frame with the queued
+// frames.
+type fakePort struct {
+	written bytes.Buffer
+	replies bytes.Buffer
+}
+
+func (p *fakePort) Write(b []byte) (int, error) { return p.written.Write(b) }
+func (p *fakePort) Read(b []byte) (int, error)  { return p.replies.Read(b) }
+
+func TestSerialCommand(t *testing.T) {
+	long := bytes.Repeat([]byte{0xAB}, 255)
+	longFrame, _ := encodeFrame(append([]byte{0xD5, 0x41, 0x00}, long...))
+	tests := []struct {
+		name    string
+		replies []byte
+		want    []byte
+		err     error
+	}{
+		{
+			name:    "firmware version",
+			replies: append(append([]byte(nil), ackFrame...), 0x00, 0x00, 0xFF, 0x06, 0xFA, 0xD5, 0x03, 0x32, 0x01, 0x06, 0x07, 0xE8, 0x00),
+			want:    []byte{0x32, 0x01, 0x06, 0x07},
+		},
+		{
+			name:    "extended frame",
+			replies: append(append([]byte(nil), ackFrame...), longFrame...),
+			want:    append([]byte{0x00}, long...),
+		},
+		{
+			name:    "bad checksum",
+			replies: append(append([]byte(nil), ackFrame...), 0x00, 0x00, 0xFF, 0x06, 0xFA, 0xD5, 0x03, 0x32, 0x01, 0x06, 0x07, 0xE9, 0x00),
+			err:     ErrChecksum,
+		},
+		{
+			name:    "nack",
+			replies: nackFrame,
+			err:     ErrNoACK,
+		},
+		{
+			name:    "error frame",
+			replies: append(append([]byte(nil), ackFrame...), 0x00, 0x00, 0xFF, 0x01, 0xFF, 0x7F, 0x81, 0x00),
+			err:     ErrErrorFrame,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := &fakePort{}
+			port.replies.Write(tt.replies)
+			s := NewSerial(port)
+			code := byte(CmdGetFirmwareVersion)
+			if tt.name == "extended frame" {
+				code = CmdInDataExchange
+			}
+			got, err := s.Command(code, nil)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Command() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Command() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Command() = % X, want % X", got, tt.want)
+			}
+			// Wakeup preamble followed by the command frame.
+			frame, _ := encodeFrame([]byte{0xD4, code})
+			if w := port.written.Bytes(); !bytes.HasPrefix(w, []byte{0x55, 0x55}) || !bytes.HasSuffix(w, frame) {
+				t.Errorf("written % X", w)
+			}
+		})
+	}
+}
+
+func TestSerialRecover(t *testing.T) {
+	version := []byte{0x00, 0x00, 0xFF, 0x06, 0xFA, 0xD5, 0x03, 0x32, 0x01, 0x06, 0x07, 0xE8, 0x00}
+	port := &fakePort{}
+	// A corrupt response followed by a late frame, both buffered at once.
+	port.replies.Write(ackFrame)
+	port.replies.Write([]byte{0x00, 0x00, 0xFF, 0x06, 0xFA, 0xD5, 0x03, 0x32, 0x01, 0x06, 0x07, 0xE9, 0x00})
+	port.replies.Write(version)
+	s := NewSerial(port)
+	if _, err := s.Command(CmdGetFirmwareVersion, nil); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("Command() error = %v, want %v", err, ErrChecksum)
+	}
+
+	port.written.Reset()
+	port.replies.Write(ackFrame)
+	port.replies.Write(version)
+	got, err := s.Command(CmdGetFirmwareVersion, nil)
+	if err != nil || !bytes.Equal(got, []byte{0x32, 0x01, 0x06, 0x07}) {
+		t.Fatalf("Command() after failure = % X, %v", got, err)
+	}
+	if !bytes.HasPrefix(port.written.Bytes(), []byte{0x55, 0x55}) {
+		t.Errorf("no wakeup after failure, written % X", port.written.Bytes())
+	}
+
+	port.written.Reset()
+	port.replies.Write(ackFrame)
+	port.replies.Write([]byte{0x00, 0x00, 0xFF, 0x03, 0xFD, 0xD5, 0x17, 0x00, 0x14, 0x00})
+	if _, err := s.Command(CmdPowerDown, []byte{0x10}); err != nil {
+		t.Fatalf("Command(PowerDown) error = %v", err)
+	}
+	port.written.Reset()
+	port.replies.Write(ackFrame)
+	port.replies.Write(version)
+	if _, err := s.Command(CmdGetFirmwareVersion, nil); err != nil {
+		t.Fatalf("Command() after power down error = %v", err)
+	}
+	if !bytes.HasPrefix(port.written.Bytes(), []byte{0x55, 0x55}) {
+		t.Errorf("no wakeup after power down, written % X", port.written.Bytes())
+	}
+}
+
+func TestEncodeFrame(t *testing.T) {
+	got, _ := encodeFrame([]byte{0xD4, 0x02})
+	if want := []byte{0x00, 0x00, 0xFF, 0x02, 0xFE, 0xD4, 0x02, 0x2A, 0x00}; !bytes.Equal(got, want) {
+		t.Errorf("encodeFrame() = % X, want % X", got, want)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package remote
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// Client is a transport.Backend driving the readers of a Server.
+type Client struct {
+	rpc    *rpc.Client
+	nextID atomic.Uint64
+
+	mu      sync.Mutex
+	readers map[string]cardreader.Reader
+}
+
+// Dial connects to the server at addr on the named network and
+// authenticates with token. The connection uses TLS configured by config
+// unless it is nil.
+func Dial(network, addr, token string, config *tls.Config) (*Client, error) {
+	var conn net.Conn
+	var err error
+	if config != nil {
+		conn, err = tls.Dial(network, addr, config)
+	} else {
+		conn, err = net.Dial(network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, token)
+}
+
+// NewClient returns a client of the server on conn, authenticated with
+// token. conn is closed when authentication fails.
+func NewClient(conn io.ReadWriteCloser, token string) (*Client, error) {
+	c := &Client{rpc: rpc.NewClient(conn), readers: make(map[string]cardreader.Reader)}
+	if err := c.call(context.Background(), "Authenticate", 0, AuthenticateRequest{Token: token}, &AuthenticateResponse{}, nil); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close closes the connection, disconnecting the cards left connected.
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+// ListReaders returns the readers of the server.
+func (c *Client) ListReaders() ([]cardreader.Reader, error) {
+	var resp ListReadersResponse
+	if err := c.call(context.Background(), "ListReaders", 0, ListReadersRequest{}, &resp, nil); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	readers := make([]cardreader.Reader, len(resp.Readers))
+	for i, name := range resp.Readers {
+		readers[i] = c.reader(name)
+	}
+	return readers, nil
+}
+
+// reader returns the reader name, the same value for every call. c.mu must
+// be held.
+func (c *Client) reader(name string) cardreader.Reader {
+	r, ok := c.readers[name]
+	if !ok {
+		r = *cardreader.NewReader(name)
+		c.readers[name] = r
+	}
+	return r
+}
+
+// WaitCard waits up to timeout for a card in one of readers on the server
+// and connects to it. When ctx is done first the wait is cancelled on the
+// server, and a card it connected meanwhile is disconnected.
+func (c *Client) WaitCard(ctx context.Context, readers []cardreader.Reader, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	req := WaitCardRequest{ID: c.nextID.Add(1), Timeout: int64(timeout)}
+	for _, r := range readers {
+		req.Readers = append(req.Readers, r.Name)
+	}
+	var resp WaitCardResponse
+	err := c.call(ctx, "WaitCard", req.ID, req, &resp, func() {
+		if resp.Handle != 0 {
+			(&Card{client: c, handle: resp.Handle}).Disconnect()
+		}
+	})
+	if err != nil || resp.Handle == 0 {
+		return nil, cardreader.Reader{}, err
+	}
+	card := &Card{client: c, handle: resp.Handle, atr: resp.ATR}
+	for _, r := range readers {
+		if r.Name == resp.Reader {
+			return card, r, nil
+		}
+	}
+	return card, *cardreader.NewReader(resp.Reader), nil
+}
+
+// Event is a card presented on a reader of the server, or the error of
+// monitoring the readers when Err is set. The receiver disconnects Card.
+type Event struct {
+	Reader cardreader.Reader
+	Card   *Card
+	Time   time.Time
+	Err    error
+}
+
+// Events subscribes to the cards presented on the readers of the server
+// named and delivers them, connected, on the returned channel, which is
+// closed once ctx is done or the connection failed. The server keeps
+// waiting for cards between the deliveries, so taps are not lost while the
+// receiver is busy. A client has one subscription at a time.
+func (c *Client) Events(ctx context.Context, readers []string) (<-chan Event, error) {
+	if err := c.call(ctx, "Subscribe", 0, SubscribeRequest{Readers: readers}, &SubscribeResponse{}, nil); err != nil {
+		return nil, err
+	}
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer c.call(context.Background(), "Unsubscribe", 0, UnsubscribeRequest{}, &UnsubscribeResponse{}, nil)
+		for {
+			req := NextEventsRequest{ID: c.nextID.Add(1), Timeout: int64(pollInterval)}
+			var resp NextEventsResponse
+			err := c.call(ctx, "NextEvents", req.ID, req, &resp, func() { c.release(resp.Events) })
+			if err != nil {
+				return
+			}
+			for i, ev := range resp.Events {
+				select {
+				case events <- c.event(ev):
+				case <-ctx.Done():
+					c.release(resp.Events[i:])
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// event returns the Event of ev.
+func (c *Client) event(ev CardEvent) Event {
+	out := Event{Time: time.Unix(0, ev.Time)}
+	if ev.Err != "" {
+		out.Err = decodeError(ev.Err)
+		return out
+	}
+	c.mu.Lock()
+	out.Reader = c.reader(ev.Reader)
+	c.mu.Unlock()
+	out.Card = &Card{client: c, handle: ev.Handle, atr: ev.ATR}
+	return out
+}
+
+// release disconnects the cards of events.
+func (c *Client) release(events []CardEvent) {
+	for _, ev := range events {
+		if ev.Handle != 0 {
+			(&Card{client: c, handle: ev.Handle}).Disconnect()
+		}
+	}
+}
+
+// call calls the method of the service. When ctx is done first it returns
+// ctx.Err(), cancels the call with the request ID on the server unless id
+// is zero, and runs late, if not nil, once the abandoned call completed, so
+// it can release what the call acquired.
+func (c *Client) call(ctx context.Context, method string, id uint64, req, resp any, late func()) error {
+	call := c.rpc.Go(ServiceName+"."+method, req, resp, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return callError(call.Error)
+	case <-ctx.Done():
+	}
+	go func() {
+		if id != 0 {
+			c.rpc.Call(ServiceName+".Cancel", CancelRequest{ID: id}, &CancelResponse{})
+		}
+		<-call.Done
+		if call.Error == nil && late != nil {
+			late()
+		}
+	}()
+	return ctx.Err()
+}
+
+// callError returns the error of a completed call, decoding the errors of
+// the server.
+func callError(err error) error {
+	if se, ok := err.(rpc.ServerError); ok {
+		return decodeError(string(se))
+	}
+	return err
+}
+
+// Card is a card connected on a server.
+type Card struct {
+	client *Client
+	handle uint64
+	atr    []byte
+}
+
+// ATR returns the ATR of the card.
+func (c *Card) ATR() []byte { return c.atr }
+
+// Transmit sends cmd to the card and returns its response.
+func (c *Card) Transmit(cmd []byte) ([]byte, error) {
+	var resp TransmitResponse
+	if err := c.client.call(context.Background(), "Transmit", 0, TransmitRequest{Handle: c.handle, Command: cmd}, &resp, nil); err != nil {
+		return nil, err
+	}
+	return resp.Response, nil
+}
+
+// Disconnect releases the card on the server.
+func (c *Card) Disconnect() error {
+	return c.client.call(context.Background(), "Disconnect", 0, DisconnectRequest{Handle: c.handle}, &DisconnectResponse{}, nil)
+}
@@ -0,0 +1,200 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package remote exposes the readers of a machine over the network, so a
+// central application drives readers attached to remote kiosks through the
+// same SDK API. A Server serves the readers of a transport.Backend; a Client
+// is a transport.Backend using them:
+//
+//	client, err := remote.Dial("tcp", "kiosk:7455", token, tlsConfig)
+//	...
+//	sdk := scardkit.New(scardkit.WithBackend(client))
+//
+// Server and Client speak net/rpc, keeping the module free of
+// dependencies. Clients authenticate with a token shared with the server
+// before any other call. Commands, PINs included, cross the network, so
+// connections leaving a trusted host should use TLS.
+package remote
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// ServiceName is the name the service is registered with.
+const ServiceName = "Remote"
+
+var (
+	// ErrUnknownHandle is returned for cards not connected on the
+	// connection, or disconnected.
+	ErrUnknownHandle = errors.New("remote: unknown card handle")
+	// ErrUnauthenticated is returned for calls on connections which did
+	// not authenticate with the token of the server.
+	ErrUnauthenticated = errors.New("remote: unauthenticated")
+)
+
+// AuthenticateRequest is the request of Service.Authenticate.
+type AuthenticateRequest struct {
+	Token string
+}
+
+// AuthenticateResponse is the response of Service.Authenticate.
+type AuthenticateResponse struct{}
+
+// ListReadersRequest is the request of Service.ListReaders.
+type ListReadersRequest struct{}
+
+// ListReadersResponse is the response of Service.ListReaders.
+type ListReadersResponse struct {
+	Readers []string
+}
+
+// WaitCardRequest is the request of Service.WaitCard. ID identifies the
+// call for Service.Cancel.
+type WaitCardRequest struct {
+	ID      uint64
+	Readers []string
+	Timeout int64 // Nanoseconds.
+}
+
+// WaitCardResponse is the response of Service.WaitCard. Handle is zero when
+// the timeout elapsed.
+type WaitCardResponse struct {
+	Handle uint64
+	Reader string
+	ATR    []byte
+}
+
+// SubscribeRequest is the request of Service.Subscribe.
+type SubscribeRequest struct {
+	Readers []string
+}
+
+// SubscribeResponse is the response of Service.Subscribe.
+type SubscribeResponse struct{}
+
+// NextEventsRequest is the request of Service.NextEvents. ID identifies
+// the call for Service.Cancel.
+type NextEventsRequest struct {
+	ID      uint64
+	Timeout int64 // Nanoseconds.
+}
+
+// NextEventsResponse is the response of Service.NextEvents, empty when the
+// timeout elapsed.
+type NextEventsResponse struct {
+	Events []CardEvent
+}
+
+// CardEvent is a card presented on a reader of the server, connected under
+// Handle, or the error of monitoring the readers when Err is set.
+type CardEvent struct {
+	Handle uint64
+	Reader string
+	ATR    []byte
+	Time   int64  // Unix nanoseconds.
+	Err    string // Encoded like the errors of the rpcs.
+}
+
+// UnsubscribeRequest is the request of Service.Unsubscribe.
+type UnsubscribeRequest struct{}
+
+// UnsubscribeResponse is the response of Service.Unsubscribe.
+type UnsubscribeResponse struct{}
+
+// CancelRequest is the request of Service.Cancel.
+type CancelRequest struct {
+	ID uint64
+}
+
+// CancelResponse is the response of Service.Cancel.
+type CancelResponse struct{}
+
+// TransmitRequest is the request of Service.Transmit.
+type TransmitRequest struct {
+	Handle  uint64
+	Command []byte
+}
+
+// TransmitResponse is the response of Service.Transmit.
+type TransmitResponse struct {
+	Response []byte
+}
+
+// DisconnectRequest is the request of Service.Disconnect.
+type DisconnectRequest struct {
+	Handle uint64
+}
+
+// DisconnectResponse is the response of Service.Disconnect.
+type DisconnectResponse struct{}
+
+// Error is an error returned by the server. It wraps the error of its
+// code, so errors.Is(err, pcsc.ErrCardRemoved) holds for a card removed on
+// the server.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Unwrap returns the error of the code, nil for unclassified errors.
+func (e *Error) Unwrap() error {
+	for _, c := range errorCodes {
+		if c.code == e.Code {
+			return c.err
+		}
+	}
+	return nil
+}
+
+// errorCodes are the codes of the errors classified across the network.
+var errorCodes = []struct {
+	code string
+	err  error
+}{
+	{"unknown-handle", ErrUnknownHandle},
+	{"unauthenticated", ErrUnauthenticated},
+	{"no-readers", pcsc.ErrNoReaders},
+	{"reader-unavailable", pcsc.ErrReaderUnavailable},
+	{"card-removed", pcsc.ErrCardRemoved},
+	{"card-reset", pcsc.ErrCardReset},
+	{"protocol-mismatch", pcsc.ErrProtocolMismatch},
+	{"timeout", pcsc.ErrTimeout},
+	{"cancelled", pcsc.ErrCancelled},
+	{"sharing-violation", pcsc.ErrSharingViolation},
+	{"uid-unavailable", transport.ErrUIDUnavailable},
+	{"canceled", context.Canceled},
+	{"deadline-exceeded", context.DeadlineExceeded},
+}
+
+// codeUnclassified is the code of errors not in errorCodes.
+const codeUnclassified = "error"
+
+// encodeError returns err as sent by the server, its message prefixed with
+// its code.
+func encodeError(err error) string {
+	code := codeUnclassified
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			code = c.code
+			break
+		}
+	}
+	return code + ": " + err.Error()
+}
+
+// decodeError returns the *Error of s, encoded by encodeError.
+func decodeError(s string) error {
+	code, msg, ok := strings.Cut(s, ": ")
+	if !ok {
+		return &Error{Code: codeUnclassified, Message: s}
+	}
+	return &Error{Code: code, Message: msg}
+}
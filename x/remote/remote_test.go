@@ -0,0 +1,276 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"net"
+	"net/rpc"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit"
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+	"github.com/happy-sdk/scardkit/x/virtualreader"
+)
+
+const token = "s3cret"
+
+// tapped is a virtual tag connected by tagBackend.
+type tapped struct {
+	tag.Card
+	disconnected atomic.Bool
+}
+
+func (t *tapped) Disconnect() error {
+	t.disconnected.Store(true)
+	return nil
+}
+
+// tagBackend presents its card once on its reader, after gate is closed
+// when set, regardless of the context of the wait.
+type tagBackend struct {
+	card *tapped
+	gate chan struct{}
+	used atomic.Bool
+}
+
+func (b *tagBackend) ListReaders() ([]cardreader.Reader, error) {
+	return []cardreader.Reader{*cardreader.NewReader("kiosk")}, nil
+}
+
+func (b *tagBackend) WaitCard(ctx context.Context, readers []cardreader.Reader, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	if b.gate != nil {
+		<-b.gate
+	}
+	if b.used.Swap(true) {
+		time.Sleep(timeout)
+		return nil, cardreader.Reader{}, nil
+	}
+	return b.card, readers[0], nil
+}
+
+// serve serves b on a local port, over TLS when config is set, and returns
+// its address.
+func serve(t *testing.T, b transport.Backend, config *tls.Config) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	srv := NewServer(b, token)
+	if config != nil {
+		go srv.ServeTLS(l, config)
+	} else {
+		go srv.Serve(l)
+	}
+	return l.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *Client {
+	t.Helper()
+	client, err := Dial("tcp", addr, token, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// eventually fails t unless cond holds within a second.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: timed out", what)
+		}
+	}
+}
+
+func TestRemote(t *testing.T) {
+	ntag := virtualreader.NewNTAG215([]byte{0x04, 1, 2, 3, 4, 5, 6})
+	data, _ := ndef.NewMessage(ndef.NewURIRecord("https://example.com/kiosk")).Marshal()
+	if err := tag.WriteNDEF(ntag, data); err != nil {
+		t.Fatal(err)
+	}
+	b := &tagBackend{card: &tapped{Card: ntag}}
+	client := dial(t, serve(t, b, nil))
+
+	readers, err := client.ListReaders()
+	if err != nil || len(readers) != 1 || readers[0].Name != "kiosk" {
+		t.Fatalf("ListReaders() = %v, %v", readers, err)
+	}
+	sdk := scardkit.New(scardkit.WithBackend(client), scardkit.WithStatusPollTimeout(50*time.Millisecond))
+	msg, err := sdk.ReadNDEF(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("ReadNDEF() error = %v", err)
+	}
+	if uri, _ := msg.Records[0].URI(); uri != "https://example.com/kiosk" {
+		t.Errorf("ReadNDEF() uri = %q", uri)
+	}
+	if !b.card.disconnected.Load() {
+		t.Error("card not disconnected on the server")
+	}
+
+	card, _, err := client.WaitCard(context.Background(), readers, 10*time.Millisecond)
+	if card != nil || err != nil {
+		t.Errorf("WaitCard() without card = %v, %v", card, err)
+	}
+	stale := &Card{client: client, handle: 1}
+	if _, err := stale.Transmit([]byte{0xFF, 0xCA, 0, 0, 0}); !errors.Is(err, ErrUnknownHandle) {
+		t.Errorf("Transmit() on a disconnected card error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := client.WaitCard(ctx, readers, time.Second); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitCard() with a done context error = %v", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	addr := serve(t, virtualreader.New("kiosk"), nil)
+	if _, err := Dial("tcp", addr, "wrong", nil); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Dial(wrong token) error = %v", err)
+	}
+
+	// Calls are refused before Authenticate.
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{readers: make(map[string]cardreader.Reader)}
+	c.rpc = rpc.NewClient(conn)
+	defer c.Close()
+	if _, err := c.ListReaders(); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("ListReaders() unauthenticated error = %v", err)
+	}
+
+	if _, err := Dial("tcp", serve(t, virtualreader.New("kiosk"), nil), "", nil); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Dial(empty token) error = %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	vr := virtualreader.New("kiosk")
+	client := dial(t, serve(t, vr, nil))
+	vr.Insert("kiosk", virtualreader.NewNTAG215([]byte{0x04, 1, 2, 3, 4, 5, 6}))
+	readers, _ := client.ListReaders()
+	card, _, err := client.WaitCard(context.Background(), readers, time.Second)
+	if err != nil || card == nil {
+		t.Fatalf("WaitCard() = %v, %v", card, err)
+	}
+	vr.Remove("kiosk")
+	_, err = card.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+	var re *Error
+	if !errors.Is(err, pcsc.ErrCardRemoved) || !errors.As(err, &re) || re.Code != "card-removed" {
+		t.Errorf("Transmit() on a removed card error = %#v", err)
+	}
+	if err := decodeError(encodeError(errors.New("other"))); errors.Unwrap(err) != nil || err.Error() != "other" {
+		t.Errorf("unclassified error = %#v", err)
+	}
+}
+
+func TestWaitCardCancel(t *testing.T) {
+	b := &tagBackend{card: &tapped{Card: virtualreader.NewNTAG215(nil)}, gate: make(chan struct{})}
+	client := dial(t, serve(t, b, nil))
+	readers, _ := client.ListReaders()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, _, err := client.WaitCard(ctx, readers, time.Second)
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitCard() error = %v", err)
+	}
+	// The card connected after the cancellation is released.
+	close(b.gate)
+	eventually(t, "disconnect", b.card.disconnected.Load)
+}
+
+func TestEvents(t *testing.T) {
+	vr := virtualreader.New("kiosk", "other")
+	client := dial(t, serve(t, vr, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := client.Events(ctx, []string{"kiosk"})
+	if err != nil {
+		t.Fatalf("Events() error = %v", err)
+	}
+	uid := []byte{0x04, 1, 2, 3, 4, 5, 6}
+	vr.Insert("other", virtualreader.NewNTAG215([]byte{0x04, 9, 9, 9, 9, 9, 9}))
+	vr.Insert("kiosk", virtualreader.NewNTAG215(uid))
+	select {
+	case ev := <-events:
+		if ev.Err != nil || ev.Reader.Name != "kiosk" {
+			t.Fatalf("event = %+v", ev)
+		}
+		resp, err := ev.Card.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+		if err != nil || !bytes.Equal(resp, append(uid, 0x90, 0x00)) {
+			t.Errorf("Transmit() = %X, %v", resp, err)
+		}
+		if err := ev.Card.Disconnect(); err != nil {
+			t.Errorf("Disconnect() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no card event")
+	}
+	cancel()
+	eventually(t, "close", func() bool {
+		select {
+		case _, ok := <-events:
+			return !ok
+		default:
+			return false
+		}
+	})
+}
+
+func TestTLS(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	addr := serve(t, virtualreader.New("kiosk"), &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}})
+	client, err := Dial("tcp", addr, token, &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer client.Close()
+	if readers, err := client.ListReaders(); err != nil || len(readers) != 1 {
+		t.Errorf("ListReaders() = %v, %v", readers, err)
+	}
+}
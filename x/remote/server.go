@@ -0,0 +1,427 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package remote
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// pollInterval bounds the waits of subscriptions, so they notice readers
+// attached meanwhile.
+const pollInterval = time.Second
+
+// eventQueue is the number of card events a subscription holds for its
+// client before it stops waiting for cards.
+const eventQueue = 16
+
+// Server serves the readers of a backend to clients presenting its token.
+type Server struct {
+	backend transport.Backend
+	token   string
+}
+
+// NewServer returns a server for the readers of b, admitting clients which
+// authenticate with token. An empty token admits no client.
+func NewServer(b transport.Backend, token string) *Server {
+	return &Server{backend: b, token: token}
+}
+
+// Serve accepts connections on l and serves each until it closes. It
+// returns the error of Accept, net.ErrClosed once l is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeTLS is Serve over TLS connections configured by config.
+func (s *Server) ServeTLS(l net.Listener, config *tls.Config) error {
+	return s.Serve(tls.NewListener(l, config))
+}
+
+// ServeConn serves a single connection until it closes, then disconnects
+// the cards left connected on it.
+func (s *Server) ServeConn(conn io.ReadWriteCloser) {
+	svc := s.Service()
+	defer svc.Close()
+	srv := rpc.NewServer()
+	srv.RegisterName(ServiceName, svc)
+	srv.ServeConn(conn)
+}
+
+// Service returns the service of a single connection. Cards connected
+// through it are only known to it.
+func (s *Server) Service() *Service {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Service{
+		backend: s.backend,
+		token:   s.token,
+		ctx:     ctx,
+		cancel:  cancel,
+		cards:   make(map[uint64]transport.Card),
+		pending: make(map[uint64]context.CancelFunc),
+	}
+}
+
+// Service is the service of a connection. Its methods fail with
+// ErrUnauthenticated until Authenticate succeeded, and return their errors
+// encoded with a code the Client maps back to the errors of pcsc, transport
+// and this package.
+type Service struct {
+	backend transport.Backend
+	token   string
+	ctx     context.Context // Done once the connection closed.
+	cancel  context.CancelFunc
+
+	mu      sync.Mutex
+	authed  bool
+	cards   map[uint64]transport.Card
+	next    uint64
+	pending map[uint64]context.CancelFunc // Waits in flight by request ID.
+	sub     *subscription
+}
+
+// subscription monitors readers for a client, see Service.Subscribe.
+type subscription struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	events chan CardEvent
+}
+
+// Authenticate admits the connection when the token is the one of the
+// server.
+func (s *Service) Authenticate(req AuthenticateRequest, _ *AuthenticateResponse) error {
+	if s.token == "" || subtle.ConstantTimeCompare([]byte(req.Token), []byte(s.token)) != 1 {
+		return errors.New(encodeError(ErrUnauthenticated))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authed = true
+	return nil
+}
+
+// ListReaders returns the names of the readers of the backend.
+func (s *Service) ListReaders(_ ListReadersRequest, resp *ListReadersResponse) error {
+	if err := s.authorize(); err != nil {
+		return err
+	}
+	readers, err := s.backend.ListReaders()
+	if err != nil {
+		return errors.New(encodeError(err))
+	}
+	for _, r := range readers {
+		resp.Readers = append(resp.Readers, r.Name)
+	}
+	return nil
+}
+
+// WaitCard waits for a card on the requested readers known to the backend
+// and connects to it. A card connected after the wait was cancelled, see
+// Cancel, is disconnected right away.
+func (s *Service) WaitCard(req WaitCardRequest, resp *WaitCardResponse) error {
+	if err := s.authorize(); err != nil {
+		return err
+	}
+	ctx, done := s.begin(req.ID)
+	defer done()
+	card, reader, err := s.waitCard(ctx, req.Readers, time.Duration(req.Timeout))
+	if err != nil {
+		return errors.New(encodeError(err))
+	}
+	if card == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		card.Disconnect()
+		return errors.New(encodeError(ctx.Err()))
+	}
+	resp.Handle, resp.Reader, resp.ATR = s.register(card), reader.Name, card.ATR()
+	return nil
+}
+
+// waitCard waits up to timeout for a card on the readers named.
+func (s *Service) waitCard(ctx context.Context, names []string, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	all, err := s.backend.ListReaders()
+	if err != nil {
+		return nil, cardreader.Reader{}, err
+	}
+	var readers []cardreader.Reader
+	for _, r := range all {
+		for _, name := range names {
+			if r.Name == name {
+				readers = append(readers, r)
+			}
+		}
+	}
+	if len(readers) == 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, cardreader.Reader{}, ctx.Err()
+		case <-t.C:
+			return nil, cardreader.Reader{}, nil
+		}
+	}
+	return s.backend.WaitCard(ctx, readers, timeout)
+}
+
+// Subscribe starts monitoring the requested readers, replacing the readers
+// of an earlier subscription. Every card presented is connected and queued
+// as a CardEvent for NextEvents until Unsubscribe is called or the
+// connection closes, which disconnect the cards still queued.
+func (s *Service) Subscribe(req SubscribeRequest, _ *SubscribeResponse) error {
+	if err := s.authorize(); err != nil {
+		return err
+	}
+	s.unsubscribe()
+	ctx, cancel := context.WithCancel(s.ctx)
+	sub := &subscription{cancel: cancel, done: make(chan struct{}), events: make(chan CardEvent, eventQueue)}
+	s.mu.Lock()
+	s.sub = sub
+	s.mu.Unlock()
+	go s.monitor(ctx, sub, req.Readers)
+	return nil
+}
+
+// monitor queues the cards presented on the readers named until ctx is
+// done.
+func (s *Service) monitor(ctx context.Context, sub *subscription, names []string) {
+	defer close(sub.done)
+	for ctx.Err() == nil {
+		card, reader, err := s.waitCard(ctx, names, pollInterval)
+		if ctx.Err() != nil {
+			if card != nil {
+				card.Disconnect()
+			}
+			return
+		}
+		var ev CardEvent
+		switch {
+		case err != nil:
+			ev = CardEvent{Err: encodeError(err), Time: time.Now().UnixNano()}
+		case card != nil:
+			ev = CardEvent{Handle: s.register(card), Reader: reader.Name, ATR: card.ATR(), Time: time.Now().UnixNano()}
+		default:
+			continue
+		}
+		select {
+		case sub.events <- ev:
+		case <-ctx.Done():
+			s.release(ev.Handle)
+			return
+		}
+		if err != nil {
+			// Back off from a failing backend.
+			t := time.NewTimer(pollInterval)
+			select {
+			case <-ctx.Done():
+			case <-t.C:
+			}
+			t.Stop()
+		}
+	}
+}
+
+// NextEvents waits up to the timeout for the card events of the
+// subscription and returns those queued.
+func (s *Service) NextEvents(req NextEventsRequest, resp *NextEventsResponse) error {
+	if err := s.authorize(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	sub := s.sub
+	s.mu.Unlock()
+	if sub == nil {
+		return errors.New(encodeError(errors.New("remote: not subscribed")))
+	}
+	ctx, done := s.begin(req.ID)
+	defer done()
+	t := time.NewTimer(time.Duration(req.Timeout))
+	defer t.Stop()
+	select {
+	case ev := <-sub.events:
+		resp.Events = append(resp.Events, ev)
+	case <-sub.done:
+		return nil
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return errors.New(encodeError(ctx.Err()))
+	}
+	for {
+		select {
+		case ev := <-sub.events:
+			resp.Events = append(resp.Events, ev)
+		default:
+			return nil
+		}
+	}
+}
+
+// Unsubscribe stops the subscription, disconnecting the cards of the
+// events not returned by NextEvents.
+func (s *Service) Unsubscribe(_ UnsubscribeRequest, _ *UnsubscribeResponse) error {
+	if err := s.authorize(); err != nil {
+		return err
+	}
+	s.unsubscribe()
+	return nil
+}
+
+func (s *Service) unsubscribe() {
+	s.mu.Lock()
+	sub := s.sub
+	s.sub = nil
+	s.mu.Unlock()
+	if sub == nil {
+		return
+	}
+	sub.cancel()
+	<-sub.done
+	for {
+		select {
+		case ev := <-sub.events:
+			s.release(ev.Handle)
+		default:
+			return
+		}
+	}
+}
+
+// Cancel cancels the WaitCard or NextEvents call with the request ID, if
+// still in flight.
+func (s *Service) Cancel(req CancelRequest, _ *CancelResponse) error {
+	if err := s.authorize(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	cancel := s.pending[req.ID]
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	return nil
+}
+
+// Transmit sends the command to the card.
+func (s *Service) Transmit(req TransmitRequest, resp *TransmitResponse) error {
+	if err := s.authorize(); err != nil {
+		return err
+	}
+	card, err := s.card(req.Handle)
+	if err == nil {
+		resp.Response, err = card.Transmit(req.Command)
+	}
+	if err != nil {
+		return errors.New(encodeError(err))
+	}
+	return nil
+}
+
+// Disconnect disconnects the card.
+func (s *Service) Disconnect(req DisconnectRequest, _ *DisconnectResponse) error {
+	if err := s.authorize(); err != nil {
+		return err
+	}
+	card, err := s.card(req.Handle)
+	if err == nil {
+		s.mu.Lock()
+		delete(s.cards, req.Handle)
+		s.mu.Unlock()
+		err = card.Disconnect()
+	}
+	if err != nil {
+		return errors.New(encodeError(err))
+	}
+	return nil
+}
+
+// Close ends the subscription and the waits in flight and disconnects the
+// cards left connected.
+func (s *Service) Close() error {
+	s.cancel()
+	s.unsubscribe()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for h, card := range s.cards {
+		errs = append(errs, card.Disconnect())
+		delete(s.cards, h)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) authorize() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.authed {
+		return errors.New(encodeError(ErrUnauthenticated))
+	}
+	return nil
+}
+
+// begin returns the context of the call with the request ID, cancelled by
+// Cancel and Close, and the function ending the call.
+func (s *Service) begin(id uint64) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	if id == 0 {
+		return ctx, cancel
+	}
+	s.mu.Lock()
+	s.pending[id] = cancel
+	s.mu.Unlock()
+	return ctx, func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+		cancel()
+	}
+}
+
+// register stores the card under a new handle.
+func (s *Service) register(card transport.Card) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	s.cards[s.next] = card
+	return s.next
+}
+
+// release disconnects the card h, if connected.
+func (s *Service) release(h uint64) {
+	s.mu.Lock()
+	card, ok := s.cards[h]
+	delete(s.cards, h)
+	s.mu.Unlock()
+	if ok {
+		card.Disconnect()
+	}
+}
+
+func (s *Service) card(h uint64) (transport.Card, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	card, ok := s.cards[h]
+	if !ok {
+		return nil, ErrUnknownHandle
+	}
+	return card, nil
+}
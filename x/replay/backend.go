@@ -0,0 +1,212 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package replay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// Card is a card replaying a capture. Commands must be sent in the order
+// of the capture; each is answered with the captured response or error.
+// UID reads and reconnects replay the captured ones too, while captured
+// transactions are replayed when the session starts and ends them again and
+// skipped otherwise.
+type Card struct {
+	capture *Capture
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewCard returns a card replaying c.
+func NewCard(c *Capture) *Card { return &Card{capture: c} }
+
+// ATR returns the captured ATR.
+func (c *Card) ATR() []byte { return c.capture.ATR }
+
+// Protocol returns the captured protocol.
+func (c *Card) Protocol() pcsc.Protocol { return c.capture.Protocol }
+
+// Transmit returns the captured response to cmd. It fails with ErrMismatch
+// when cmd is not the next captured command and with ErrExhausted past the
+// end of the capture.
+func (c *Card) Transmit(cmd []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ex, err := c.take("", cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err := ex.err(); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), ex.Response...), nil
+}
+
+// TransmitContext is Transmit failing once ctx is done.
+func (c *Card) TransmitContext(ctx context.Context, cmd []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Transmit(cmd)
+}
+
+// UID returns the captured UID. Captures recorded without UID reads replay
+// the GET DATA command instead.
+func (c *Card) UID() ([]byte, error) {
+	c.mu.Lock()
+	if !c.at(OpUID) {
+		c.mu.Unlock()
+		return transport.UID(struct{ transport.Card }{c})
+	}
+	defer c.mu.Unlock()
+	ex, _ := c.take(OpUID, nil)
+	if err := ex.err(); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), ex.Response...), nil
+}
+
+// BeginTransaction replays the start of a captured transaction.
+func (c *Card) BeginTransaction() error { return c.transaction(OpBegin, pcsc.LeaveCard) }
+
+// EndTransaction replays the end of a captured transaction.
+func (c *Card) EndTransaction(d pcsc.Disposition) error { return c.transaction(OpEnd, d) }
+
+// Reconnect replays a captured reconnect.
+func (c *Card) Reconnect(init pcsc.Disposition) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ex, err := c.take(OpReconnect, nil)
+	if err != nil {
+		return err
+	}
+	if ex.Disposition != init {
+		c.next--
+		return fmt.Errorf("%w at exchange %d: reconnect with disposition %d, want %d", ErrMismatch, c.next, init, ex.Disposition)
+	}
+	return ex.err()
+}
+
+func (c *Card) transaction(op string, d pcsc.Disposition) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.at(op) {
+		return nil
+	}
+	ex, _ := c.take(op, nil)
+	if op == OpEnd && ex.Disposition != d {
+		c.next--
+		return fmt.Errorf("%w at exchange %d: end transaction with disposition %d, want %d", ErrMismatch, c.next, d, ex.Disposition)
+	}
+	return ex.err()
+}
+
+// at reports whether the next captured exchange is of op.
+func (c *Card) at(op string) bool {
+	return c.next < len(c.capture.Exchanges) && c.capture.Exchanges[c.next].Op == op
+}
+
+// take returns the next captured exchange, which must be of op and, for
+// transmits, of cmd.
+func (c *Card) take(op string, cmd []byte) (*Exchange, error) {
+	name := op
+	if op == "" {
+		name = fmt.Sprintf("%X", cmd)
+	}
+	if c.next >= len(c.capture.Exchanges) {
+		return nil, fmt.Errorf("%w after %d exchanges, got %s", ErrExhausted, c.next, name)
+	}
+	ex := &c.capture.Exchanges[c.next]
+	want := ex.Op
+	if ex.Op == "" {
+		want = fmt.Sprintf("%X", []byte(ex.Command))
+	}
+	match := ex.Op == op
+	if match && op == "" {
+		if ex.Redacted {
+			match = bytes.HasPrefix(cmd, ex.Command)
+		} else {
+			match = bytes.Equal(cmd, ex.Command)
+		}
+	}
+	if !match {
+		return nil, fmt.Errorf("%w at exchange %d: got %s, want %s", ErrMismatch, c.next, name, want)
+	}
+	c.next++
+	return ex, nil
+}
+
+// Remaining returns the number of captured exchanges not replayed yet, so
+// tests can check a session issued all of the captured commands.
+func (c *Card) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.capture.Exchanges) - c.next
+}
+
+// Disconnect does nothing.
+func (c *Card) Disconnect() error { return nil }
+
+// Backend is a transport.Backend presenting captures as cards, one per
+// WaitCard call in the given order, each on the reader it was captured on.
+type Backend struct {
+	mu      sync.Mutex
+	pending []*Card
+}
+
+// NewBackend returns a backend replaying captures.
+func NewBackend(captures ...*Capture) *Backend {
+	b := &Backend{}
+	for _, c := range captures {
+		b.pending = append(b.pending, NewCard(c))
+	}
+	return b
+}
+
+// ListReaders returns the readers of the pending captures.
+func (b *Backend) ListReaders() ([]cardreader.Reader, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var readers []cardreader.Reader
+	seen := make(map[string]bool)
+	for _, c := range b.pending {
+		if name := c.capture.Reader; !seen[name] {
+			seen[name] = true
+			readers = append(readers, cardreader.Reader{Name: name})
+		}
+	}
+	return readers, nil
+}
+
+// WaitCard returns the next capture whose reader is among readers. Once
+// none is left it waits for timeout and returns no card.
+func (b *Backend) WaitCard(ctx context.Context, readers []cardreader.Reader, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	b.mu.Lock()
+	for i, c := range b.pending {
+		for _, r := range readers {
+			if r.Name == c.capture.Reader {
+				b.pending = append(b.pending[:i], b.pending[i+1:]...)
+				b.mu.Unlock()
+				return c, r, nil
+			}
+		}
+	}
+	b.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		return nil, cardreader.Reader{}, ctx.Err()
+	case <-time.After(timeout):
+		return nil, cardreader.Reader{}, nil
+	}
+}
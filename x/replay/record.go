@@ -0,0 +1,179 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// Recorder is a transport.Backend recording the sessions of the cards of
+// another backend. Each session is saved to its own file in a directory
+// once the card is disconnected. Besides transmits, the UID reads, PC/SC
+// transactions and reconnects of cards supporting them are recorded, and
+// sensitive commands are redacted, see Exchange.
+type Recorder struct {
+	backend transport.Backend
+	dir     string
+
+	// OnError, when set, is called with errors saving captures, which are
+	// otherwise dropped so recording never disturbs a session.
+	OnError func(err error)
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewRecorder returns a backend recording the sessions of backend to files
+// in dir, which must exist.
+func NewRecorder(backend transport.Backend, dir string) *Recorder {
+	return &Recorder{backend: backend, dir: dir}
+}
+
+// ListReaders returns the readers of the recorded backend.
+func (r *Recorder) ListReaders() ([]cardreader.Reader, error) { return r.backend.ListReaders() }
+
+// WaitCard waits for a card of the recorded backend and starts recording
+// its session.
+func (r *Recorder) WaitCard(ctx context.Context, readers []cardreader.Reader, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	card, reader, err := r.backend.WaitCard(ctx, readers, timeout)
+	if err != nil || card == nil {
+		return card, reader, err
+	}
+	c := &recordingCard{Card: card, recorder: r}
+	c.capture = c.newCapture(reader.Name)
+	return c, reader, nil
+}
+
+// Close closes the recorded backend when it implements io.Closer.
+func (r *Recorder) Close() error {
+	if c, ok := r.backend.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// save writes c to a file named after its time, a sequence number and its
+// reader.
+func (r *Recorder) save(c *Capture) {
+	r.mu.Lock()
+	r.seq++
+	name := fmt.Sprintf("%s-%04d-%s.json", c.Time.UTC().Format("20060102T150405"), r.seq, unsafeChars.ReplaceAllString(c.Reader, "_"))
+	r.mu.Unlock()
+	if err := c.Save(filepath.Join(r.dir, name)); err != nil && r.OnError != nil {
+		r.OnError(fmt.Errorf("replay: save capture: %w", err))
+	}
+}
+
+// recordingCard records the exchanges of a card. It has the optional
+// methods of *pcsc.Card used by the SDK, recording them when card has them.
+type recordingCard struct {
+	transport.Card
+	recorder *Recorder
+
+	mu      sync.Mutex
+	capture *Capture
+}
+
+func (c *recordingCard) newCapture(reader string) *Capture {
+	return &Capture{Reader: reader, ATR: c.Card.ATR(), Protocol: c.Protocol(), Time: time.Now()}
+}
+
+func (c *recordingCard) record(ex Exchange, err error) {
+	ex.setErr(err)
+	if ex.Op == "" && apdu.Sensitive(ex.Command) {
+		ex.redact()
+	}
+	c.mu.Lock()
+	c.capture.Exchanges = append(c.capture.Exchanges, ex)
+	c.mu.Unlock()
+}
+
+func (c *recordingCard) Transmit(cmd []byte) ([]byte, error) {
+	resp, err := c.Card.Transmit(cmd)
+	c.record(Exchange{Command: append(Hex(nil), cmd...), Response: append(Hex(nil), resp...)}, err)
+	return resp, err
+}
+
+func (c *recordingCard) TransmitContext(ctx context.Context, cmd []byte) ([]byte, error) {
+	resp, err := transport.TransmitContext(ctx, c.Card, cmd)
+	c.record(Exchange{Command: append(Hex(nil), cmd...), Response: append(Hex(nil), resp...)}, err)
+	return resp, err
+}
+
+// UID records the UID of the card, read with transport.UID.
+func (c *recordingCard) UID() ([]byte, error) {
+	uid, err := transport.UID(c.Card)
+	c.record(Exchange{Op: OpUID, Response: append(Hex(nil), uid...)}, err)
+	return uid, err
+}
+
+// Protocol returns the protocol of the card, ProtocolUndefined when it
+// does not report one.
+func (c *recordingCard) Protocol() pcsc.Protocol {
+	if p, ok := c.Card.(interface{ Protocol() pcsc.Protocol }); ok {
+		return p.Protocol()
+	}
+	return pcsc.ProtocolUndefined
+}
+
+// BeginTransaction starts a transaction on cards supporting them, and does
+// nothing on other cards.
+func (c *recordingCard) BeginTransaction() error {
+	t, ok := c.Card.(interface{ BeginTransaction() error })
+	if !ok {
+		return nil
+	}
+	err := t.BeginTransaction()
+	c.record(Exchange{Op: OpBegin}, err)
+	return err
+}
+
+// EndTransaction ends a transaction on cards supporting them, and does
+// nothing on other cards.
+func (c *recordingCard) EndTransaction(d pcsc.Disposition) error {
+	t, ok := c.Card.(interface{ EndTransaction(pcsc.Disposition) error })
+	if !ok {
+		return nil
+	}
+	err := t.EndTransaction(d)
+	c.record(Exchange{Op: OpEnd, Disposition: d}, err)
+	return err
+}
+
+// Reconnect reconnects to cards supporting it. Other cards fail with
+// errors.ErrUnsupported.
+func (c *recordingCard) Reconnect(init pcsc.Disposition) error {
+	r, ok := c.Card.(interface{ Reconnect(pcsc.Disposition) error })
+	if !ok {
+		return fmt.Errorf("replay: reconnect: %w", errors.ErrUnsupported)
+	}
+	err := r.Reconnect(init)
+	c.record(Exchange{Op: OpReconnect, Disposition: init}, err)
+	return err
+}
+
+func (c *recordingCard) Disconnect() error {
+	err := c.Card.Disconnect()
+	c.mu.Lock()
+	capture := c.capture
+	c.capture = c.newCapture(capture.Reader)
+	c.mu.Unlock()
+	c.recorder.save(capture)
+	return err
+}
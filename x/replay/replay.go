@@ -0,0 +1,216 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package replay records the APDU exchanges of card sessions to JSON
+// captures and replays them, so regression tests run against traces of real
+// cards. A Recorder wraps the backend of a deployment or a test bench and
+// writes one capture per session; a Backend serves the captured responses
+// back to the SDK.
+package replay
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// Hex is a byte string encoded as a hex string in JSON.
+type Hex []byte
+
+// MarshalJSON encodes h as a hex string.
+func (h Hex) MarshalJSON() ([]byte, error) { return json.Marshal(hex.EncodeToString(h)) }
+
+// UnmarshalJSON decodes a hex string. Spaces are ignored, so captures may
+// be edited by hand with bytes separated.
+func (h *Hex) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	b := make([]byte, 0, len(s)/2)
+	for i := 0; i < len(s); {
+		if s[i] == ' ' {
+			i++
+			continue
+		}
+		if i+2 > len(s) {
+			return fmt.Errorf("replay: odd length hex %q", s)
+		}
+		v, err := hex.DecodeString(s[i : i+2])
+		if err != nil {
+			return fmt.Errorf("replay: invalid hex %q: %w", s, err)
+		}
+		b = append(b, v[0])
+		i += 2
+	}
+	*h = b
+	return nil
+}
+
+// Operations of exchanges other than transmits, which have no Op.
+const (
+	OpUID       = "uid"       // the UID read from the card, in Response
+	OpBegin     = "begin"     // a PC/SC transaction started
+	OpEnd       = "end"       // a PC/SC transaction ended with Disposition
+	OpReconnect = "reconnect" // a reconnect applying Disposition
+)
+
+// Exchange is a command sent to a card with its response, or the error
+// of the exchange, or another operation on the card.
+//
+// Commands which may carry secrets, as reported by apdu.Sensitive, are
+// recorded Redacted: only their header and the status words of their
+// response are kept, and replaying matches the header alone.
+type Exchange struct {
+	Op          string           `json:"op,omitempty"`
+	Command     Hex              `json:"cmd,omitempty"`
+	Response    Hex              `json:"resp,omitempty"`
+	Redacted    bool             `json:"redacted,omitempty"`
+	Disposition pcsc.Disposition `json:"disposition,omitempty"`
+	Code        string           `json:"code,omitempty"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// err returns the recorded error of ex, nil when it succeeded.
+func (ex *Exchange) err() error {
+	if ex.Error == "" && ex.Code == "" {
+		return nil
+	}
+	code := ex.Code
+	if code == "" {
+		code = codeUnclassified
+	}
+	return &Error{Code: code, Message: ex.Error}
+}
+
+// setErr records err, classified by its code.
+func (ex *Exchange) setErr(err error) {
+	if err == nil {
+		return
+	}
+	ex.Code = codeUnclassified
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			ex.Code = c.code
+			break
+		}
+	}
+	ex.Error = err.Error()
+}
+
+// redact strips the data of a sensitive command and its response.
+func (ex *Exchange) redact() {
+	ex.Redacted = true
+	ex.Command = ex.Command[:min(len(ex.Command), 4)]
+	if n := len(ex.Response); n > 2 {
+		ex.Response = ex.Response[n-2:]
+	}
+}
+
+// Capture is the record of a card session.
+type Capture struct {
+	Reader    string        `json:"reader"`
+	ATR       Hex           `json:"atr"`
+	Protocol  pcsc.Protocol `json:"protocol,omitempty"`
+	Time      time.Time     `json:"time"`
+	Exchanges []Exchange    `json:"exchanges"`
+}
+
+// Error is an error replayed from a capture. It wraps the error of its
+// code, so errors.Is(err, pcsc.ErrCardRemoved) holds for an exchange which
+// failed because the card was removed.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Unwrap returns the error of the code, nil for unclassified errors.
+func (e *Error) Unwrap() error {
+	for _, c := range errorCodes {
+		if c.code == e.Code {
+			return c.err
+		}
+	}
+	return nil
+}
+
+// errorCodes are the codes of the errors classified in captures.
+var errorCodes = []struct {
+	code string
+	err  error
+}{
+	{"no-readers", pcsc.ErrNoReaders},
+	{"reader-unavailable", pcsc.ErrReaderUnavailable},
+	{"card-removed", pcsc.ErrCardRemoved},
+	{"card-reset", pcsc.ErrCardReset},
+	{"protocol-mismatch", pcsc.ErrProtocolMismatch},
+	{"timeout", pcsc.ErrTimeout},
+	{"cancelled", pcsc.ErrCancelled},
+	{"sharing-violation", pcsc.ErrSharingViolation},
+	{"uid-unavailable", transport.ErrUIDUnavailable},
+	{"pcsc-uid-unavailable", pcsc.ErrUIDUnavailable},
+	{"unsupported", errors.ErrUnsupported},
+	{"canceled", context.Canceled},
+	{"deadline-exceeded", context.DeadlineExceeded},
+}
+
+// codeUnclassified is the code of errors not in errorCodes.
+const codeUnclassified = "error"
+
+// ErrMismatch is returned by replayed cards for commands differing from
+// the next captured command.
+var ErrMismatch = errors.New("replay: command does not match the capture")
+
+// ErrExhausted is returned by replayed cards for commands past the end of
+// the capture.
+var ErrExhausted = errors.New("replay: capture exhausted")
+
+// Read decodes a capture.
+func Read(r io.Reader) (*Capture, error) {
+	c := new(Capture)
+	if err := json.NewDecoder(r).Decode(c); err != nil {
+		return nil, fmt.Errorf("replay: decode capture: %w", err)
+	}
+	return c, nil
+}
+
+// Load reads the capture stored in the named file.
+func Load(name string) (*Capture, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Write encodes c as indented JSON.
+func (c *Capture) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// Save writes c to the named file, replacing it.
+func (c *Capture) Save(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := c.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
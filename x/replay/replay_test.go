@@ -0,0 +1,220 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit"
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+	"github.com/happy-sdk/scardkit/x/virtualreader"
+)
+
+func TestRecordReplay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	dir := t.TempDir()
+	vr := virtualreader.New("Virtual Reader 00")
+	ntag := virtualreader.NewNTAG215([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	if err := ntag.WriteNDEF(mustMarshal(t, ndef.NewMessage(ndef.NewURIRecord("https://example.com/")))); err != nil {
+		t.Fatal(err)
+	}
+	if err := vr.Insert("Virtual Reader 00", ntag); err != nil {
+		t.Fatal(err)
+	}
+	rec := NewRecorder(vr, dir)
+	rec.OnError = func(err error) { t.Error(err) }
+	want, err := scardkit.New(scardkit.WithBackend(rec)).ReadNDEF(ctx, nil)
+	if err != nil {
+		t.Fatalf("ReadNDEF() recording error = %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*-0001-Virtual_Reader_00.json"))
+	if len(files) != 1 {
+		t.Fatalf("captures = %v", files)
+	}
+	c, err := Load(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Reader != "Virtual Reader 00" || !bytes.Equal(c.ATR, ntag.ATR()) || len(c.Exchanges) == 0 {
+		t.Fatalf("capture = %+v", c)
+	}
+
+	b := NewBackend(c)
+	got, err := scardkit.New(scardkit.WithBackend(b)).ReadNDEF(ctx, nil)
+	if err != nil {
+		t.Fatalf("ReadNDEF() replaying error = %v", err)
+	}
+	if !bytes.Equal(mustMarshal(t, got), mustMarshal(t, want)) {
+		t.Errorf("replayed message differs from the recorded one")
+	}
+}
+
+func TestCard(t *testing.T) {
+	var c Capture
+	data := `{"reader": "r0", "atr": "3B 81 80 01 80 80", "exchanges": [
+		{"cmd": "FF CA 00 00 00", "resp": "01020304 9000"},
+		{"cmd": "00 B0 00 00 00", "code": "card-removed", "error": "card removed"}
+	]}`
+	if err := json.Unmarshal([]byte(data), &c); err != nil {
+		t.Fatal(err)
+	}
+	card := NewCard(&c)
+	if resp, err := card.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00}); err != nil || !bytes.Equal(resp, []byte{1, 2, 3, 4, 0x90, 0x00}) {
+		t.Errorf("Transmit() = %X, %v", resp, err)
+	}
+	if _, err := card.Transmit([]byte{0x00, 0xA4, 0x04, 0x00}); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Transmit() of another command error = %v", err)
+	}
+	if _, err := card.Transmit([]byte{0x00, 0xB0, 0x00, 0x00, 0x00}); !errors.Is(err, pcsc.ErrCardRemoved) || err.Error() != "card removed" {
+		t.Errorf("Transmit() of a failed exchange error = %v", err)
+	}
+	if _, err := card.Transmit([]byte{0x00}); !errors.Is(err, ErrExhausted) || card.Remaining() != 0 {
+		t.Errorf("Transmit() past the end error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"atr": "3b8180018080"`)) || !bytes.Contains(buf.Bytes(), []byte(`"resp": "010203049000"`)) {
+		t.Errorf("Write() = %s", buf.Bytes())
+	}
+	if err := (&Hex{}).UnmarshalJSON([]byte(`"ABC"`)); err == nil {
+		t.Error("UnmarshalJSON() accepted odd length hex")
+	}
+}
+
+// pcscCard is a card with the optional methods of *pcsc.Card.
+type pcscCard struct {
+	ops []string
+}
+
+func (c *pcscCard) ATR() []byte             { return []byte{0x3B, 0x00} }
+func (c *pcscCard) Disconnect() error       { return nil }
+func (c *pcscCard) Protocol() pcsc.Protocol { return pcsc.ProtocolT1 }
+func (c *pcscCard) UID() ([]byte, error)    { return []byte{1, 2, 3, 4}, nil }
+func (c *pcscCard) BeginTransaction() error { c.ops = append(c.ops, OpBegin); return nil }
+func (c *pcscCard) Reconnect(pcsc.Disposition) error {
+	c.ops = append(c.ops, OpReconnect)
+	return nil
+}
+
+func (c *pcscCard) EndTransaction(pcsc.Disposition) error {
+	c.ops = append(c.ops, OpEnd)
+	return nil
+}
+
+func (c *pcscCard) Transmit(cmd []byte) ([]byte, error) {
+	if cmd[1] == 0xB0 {
+		return nil, fmt.Errorf("transmit: %w", pcsc.ErrCardReset)
+	}
+	return []byte{0x01, 0x02, 0x90, 0x00}, nil
+}
+
+type cardBackend struct{ card transport.Card }
+
+func (b cardBackend) ListReaders() ([]cardreader.Reader, error) {
+	return []cardreader.Reader{{Name: "r0"}}, nil
+}
+
+func (b cardBackend) WaitCard(context.Context, []cardreader.Reader, time.Duration) (transport.Card, cardreader.Reader, error) {
+	return b.card, cardreader.Reader{Name: "r0"}, nil
+}
+
+func TestRecordOperations(t *testing.T) {
+	dir := t.TempDir()
+	pc := &pcscCard{}
+	rec := NewRecorder(cardBackend{pc}, dir)
+	rec.OnError = func(err error) { t.Error(err) }
+	card, _, err := rec.WaitCard(context.Background(), nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := []byte{0x00, 0x20, 0x00, 0x81, 0x04, 0x31, 0x32, 0x33, 0x34}
+	session := func(card transport.Card) error {
+		c := card.(interface {
+			transport.Card
+			BeginTransaction() error
+			EndTransaction(pcsc.Disposition) error
+			Reconnect(pcsc.Disposition) error
+		})
+		if err := c.BeginTransaction(); err != nil {
+			return err
+		}
+		if uid, err := transport.UID(c); err != nil || !bytes.Equal(uid, []byte{1, 2, 3, 4}) {
+			return fmt.Errorf("UID() = %X, %v", uid, err)
+		}
+		if _, err := c.Transmit(verify); err != nil {
+			return err
+		}
+		if _, err := c.Transmit([]byte{0x00, 0xB0, 0x00, 0x00, 0x00}); !errors.Is(err, pcsc.ErrCardReset) {
+			return fmt.Errorf("Transmit() error = %v", err)
+		}
+		if err := c.Reconnect(pcsc.ResetCard); err != nil {
+			return err
+		}
+		return c.EndTransaction(pcsc.LeaveCard)
+	}
+	if err := session(card); err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+	if err := card.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{OpBegin, OpReconnect, OpEnd}; fmt.Sprint(pc.ops) != fmt.Sprint(want) {
+		t.Errorf("card operations = %v, want %v", pc.ops, want)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("captures = %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("31323334")) || !bytes.Contains(data, []byte(`"code": "card-reset"`)) {
+		t.Errorf("capture = %s", data)
+	}
+	c, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Protocol != pcsc.ProtocolT1 {
+		t.Errorf("Protocol = %v", c.Protocol)
+	}
+	replayed := NewCard(c)
+	if err := session(replayed); err != nil {
+		t.Fatalf("replaying: %v", err)
+	}
+	if n := replayed.Remaining(); n != 0 {
+		t.Errorf("Remaining() = %d", n)
+	}
+	if err := NewCard(c).Reconnect(pcsc.LeaveCard); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Reconnect() out of order error = %v", err)
+	}
+}
+
+func mustMarshal(t *testing.T, m *ndef.Message) []byte {
+	t.Helper()
+	b, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
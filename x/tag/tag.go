@@ -0,0 +1,106 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package tag forwards to nfc/tag, which it graduated to.
+//
+// Deprecated: Use github.com/happy-sdk/scardkit/nfc/tag. This package is
+// removed after the next minor release.
+package tag
+
+import nfctag "github.com/happy-sdk/scardkit/nfc/tag"
+
+// Deprecated: Use nfc/tag.Type.
+type Type = nfctag.Type
+
+// Deprecated: Use the constants of nfc/tag.
+const (
+	TypeUnknown         = nfctag.TypeUnknown
+	TypeMifareClassic1K = nfctag.TypeMifareClassic1K
+	TypeMifareClassic4K = nfctag.TypeMifareClassic4K
+	TypeMifareMini      = nfctag.TypeMifareMini
+	TypeUltralight      = nfctag.TypeUltralight
+	TypeUltralightC     = nfctag.TypeUltralightC
+	TypeNTAG            = nfctag.TypeNTAG
+	TypeDESFire         = nfctag.TypeDESFire
+	TypeISODEP          = nfctag.TypeISODEP
+	TypeFeliCa          = nfctag.TypeFeliCa
+	TypeTopaz           = nfctag.TypeTopaz
+	TypeISO15693        = nfctag.TypeISO15693
+)
+
+// Deprecated: Use nfc/tag.ForumType.
+type ForumType = nfctag.ForumType
+
+// Deprecated: Use the constants of nfc/tag.
+const (
+	ForumTypeNone = nfctag.ForumTypeNone
+	ForumType2    = nfctag.ForumType2
+	ForumType3    = nfctag.ForumType3
+	ForumType4    = nfctag.ForumType4
+	ForumType5    = nfctag.ForumType5
+)
+
+type (
+	// Deprecated: Use nfc/tag.Card.
+	Card = nfctag.Card
+	// Deprecated: Use nfc/tag.Signature.
+	Signature = nfctag.Signature
+	// Deprecated: Use nfc/tag.Pattern.
+	Pattern = nfctag.Pattern
+	// Deprecated: Use nfc/tag.Rule.
+	Rule = nfctag.Rule
+	// Deprecated: Use nfc/tag.Detector.
+	Detector = nfctag.Detector
+	// Deprecated: Use nfc/tag.Matcher.
+	Matcher = nfctag.Matcher
+	// Deprecated: Use nfc/tag.MatchFunc.
+	MatchFunc = nfctag.MatchFunc
+	// Deprecated: Use nfc/tag.Info.
+	Info = nfctag.Info
+)
+
+var (
+	// Deprecated: Use nfc/tag.Default.
+	Default = nfctag.Default
+	// Deprecated: Use nfc/tag.ErrNotFormatted.
+	ErrNotFormatted = nfctag.ErrNotFormatted
+	// Deprecated: Use nfc/tag.ErrUnsupported.
+	ErrUnsupported = nfctag.ErrUnsupported
+)
+
+// Deprecated: Use nfc/tag.ParsePattern.
+func ParsePattern(s string) (Pattern, error) { return nfctag.ParsePattern(s) }
+
+// Deprecated: Use nfc/tag.MustParsePattern.
+func MustParsePattern(s string) Pattern { return nfctag.MustParsePattern(s) }
+
+// Deprecated: Use nfc/tag.NewDetector.
+func NewDetector() *Detector { return nfctag.NewDetector() }
+
+// Deprecated: Use nfc/tag.Override.
+func Override(r Rule) { nfctag.Override(r) }
+
+// Deprecated: Use nfc/tag.Detect.
+func Detect(sig Signature) Type { return nfctag.Detect(sig) }
+
+// Deprecated: Use nfc/tag.DetectCard.
+func DetectCard(card Card) Type { return nfctag.DetectCard(card) }
+
+// Deprecated: Use nfc/tag.RegisterType.
+func RegisterType(name string) Type { return nfctag.RegisterType(name) }
+
+// Deprecated: Use nfc/tag.Register.
+func Register(t Type, m Matcher) { nfctag.Register(t, m) }
+
+// Deprecated: Use nfc/tag.ReadNDEF.
+func ReadNDEF(card Card) ([]byte, error) { return nfctag.ReadNDEF(card) }
+
+// Deprecated: Use nfc/tag.WriteNDEF.
+func WriteNDEF(card Card, msg []byte) error { return nfctag.WriteNDEF(card, msg) }
+
+// Deprecated: Use nfc/tag.ReadInfo.
+func ReadInfo(card Card) (*Info, error) { return nfctag.ReadInfo(card) }
+
+// Deprecated: Use nfc/tag.Format.
+func Format(card Card) error { return nfctag.Format(card) }
@@ -0,0 +1,175 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package virtualreader
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit"
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+var benchUID = []byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+
+// benchNDEF returns the raw NDEF message of a URI record of about size
+// bytes.
+func benchNDEF(b *testing.B, size int) []byte {
+	data, err := ndef.NewMessage(ndef.NewURIRecord("https://example.com/" + strings.Repeat("x", size))).Marshal()
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+// BenchmarkConnectToFirstAPDU measures the time from placing a tag on a
+// reader until the card handler sends its first command.
+func BenchmarkConnectToFirstAPDU(b *testing.B) {
+	vr := New("r0")
+	var inserted atomic.Int64
+	var latency time.Duration
+	sdk := scardkit.New(scardkit.WithBackend(vr), scardkit.WithCardHandler(func(_ context.Context, _ cardreader.Event, card transport.Card) error {
+		latency += time.Duration(time.Now().UnixNano() - inserted.Load())
+		_, err := card.Transmit([]byte{0x00, 0xA4, 0x04, 0x00, 0x07, 0xD2, 0x76, 0x00, 0x00, 0x85, 0x01, 0x01, 0x00})
+		return err
+	}))
+	t := NewDESFire(benchUID, nil)
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		inserted.Store(time.Now().UnixNano())
+		if err := vr.Insert("r0", t); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := sdk.WaitForCard(ctx, time.Second); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(latency.Nanoseconds())/float64(b.N), "ns/first-apdu")
+}
+
+// BenchmarkReadNDEF measures the NDEF read throughput of each tag type on a
+// connected card.
+func BenchmarkReadNDEF(b *testing.B) {
+	tests := []struct {
+		name string
+		tag  func(msg []byte) Tag
+	}{
+		{"ntag215", func(msg []byte) Tag {
+			t := NewNTAG215(benchUID)
+			if err := t.WriteNDEF(msg); err != nil {
+				b.Fatal(err)
+			}
+			return t
+		}},
+		{"desfire", func(msg []byte) Tag { return NewDESFire(benchUID, msg) }},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			msg := benchNDEF(b, 400)
+			vr := New("r0")
+			if err := vr.Insert("r0", tt.tag(msg)); err != nil {
+				b.Fatal(err)
+			}
+			readers, _ := vr.ListReaders()
+			card, _, err := vr.WaitCard(context.Background(), readers, time.Second)
+			if err != nil || card == nil {
+				b.Fatalf("WaitCard() = %v, %v", card, err)
+			}
+			b.SetBytes(int64(len(msg)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				data, err := tag.ReadNDEF(card)
+				if err != nil || len(data) != len(msg) {
+					b.Fatalf("ReadNDEF() = %d bytes, %v", len(data), err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkStatusChangeWakeup measures the time from placing a tag on a
+// reader until a blocked WaitForCard returns its event.
+func BenchmarkStatusChangeWakeup(b *testing.B) {
+	vr := New("r0")
+	sdk := scardkit.New(scardkit.WithBackend(vr))
+	t := NewNTAG215(benchUID)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	waiting := make(chan struct{})
+	woken := make(chan time.Time)
+	go func() {
+		for {
+			waiting <- struct{}{}
+			if _, err := sdk.WaitForCard(ctx, 0); err != nil {
+				return
+			}
+			woken <- time.Now()
+		}
+	}()
+	var latency time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		<-waiting
+		// Let the waiter block on the empty reader.
+		time.Sleep(50 * time.Microsecond)
+		start := time.Now()
+		if err := vr.Insert("r0", t); err != nil {
+			b.Fatal(err)
+		}
+		latency += (<-woken).Sub(start)
+		if err := vr.Remove("r0"); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(latency.Nanoseconds())/float64(b.N), "ns/wakeup")
+}
+
+// BenchmarkTransmit measures the latency of a command through the usual
+// middleware stack, with logging disabled, and reports its percentiles.
+func BenchmarkTransmit(b *testing.B) {
+	vr := New("r0")
+	if err := vr.Insert("r0", NewDESFire(benchUID, nil)); err != nil {
+		b.Fatal(err)
+	}
+	readers, _ := vr.ListReaders()
+	card, _, err := vr.WaitCard(context.Background(), readers, time.Second)
+	if err != nil || card == nil {
+		b.Fatalf("WaitCard() = %v, %v", card, err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	durations := make([]time.Duration, 0, b.N)
+	tr := apdu.Wrap(card,
+		apdu.Timing(func(_ []byte, d time.Duration, _ error) { durations = append(durations, d) }),
+		apdu.RedactedLogging(logger, slog.LevelDebug, nil),
+		apdu.Dump(logger, apdu.LevelTrace),
+		apdu.StatusErrors(),
+		apdu.GetResponse(),
+	)
+	cmd := []byte{0x00, 0xA4, 0x04, 0x00, 0x07, 0xD2, 0x76, 0x00, 0x00, 0x85, 0x01, 0x01, 0x00}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := tr.Transmit(cmd); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	slices.Sort(durations)
+	b.ReportMetric(float64(durations[len(durations)/2].Nanoseconds()), "ns-p50")
+	b.ReportMetric(float64(durations[len(durations)*99/100].Nanoseconds()), "ns-p99")
+}
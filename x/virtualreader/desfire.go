@@ -0,0 +1,66 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package virtualreader
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/happy-sdk/scardkit/x/emulation"
+)
+
+// desfireATR is the ATR PC/SC readers report for DESFire, whose ATS carries
+// the single historical byte 0x80.
+var desfireATR = withTCK([]byte{0x3B, 0x81, 0x80, 0x01, 0x80})
+
+// DESFire is a virtual MIFARE DESFire EV2 holding an NFC Forum Type 4 Tag
+// NDEF application. Besides the ISO 7816-4 commands of the NDEF
+// application it answers the wrapped native GetVersion command.
+type DESFire struct {
+	*emulation.Type4Tag
+
+	uid []byte
+
+	mu      sync.Mutex
+	version int // Next GetVersion frame, zero when none is pending.
+}
+
+// NewDESFire returns a DESFire with the 7 byte uid holding msg, the raw
+// encoding of an NDEF message. Shorter UIDs are padded with zeros.
+func NewDESFire(uid, msg []byte) *DESFire {
+	t := &DESFire{Type4Tag: emulation.NewType4Tag(msg, false), uid: make([]byte, 7)}
+	copy(t.uid, uid)
+	return t
+}
+
+// ATR returns the ATR a PC/SC reader reports for DESFire.
+func (t *DESFire) ATR() []byte { return desfireATR }
+
+// UID returns the UID of the tag.
+func (t *DESFire) UID() []byte { return t.uid }
+
+// Transmit handles the NDEF application and GetVersion.
+func (t *DESFire) Transmit(cmd []byte) ([]byte, error) {
+	t.mu.Lock()
+	switch {
+	case bytes.Equal(cmd, []byte{0x90, 0x60, 0x00, 0x00, 0x00}):
+		t.version = 1
+		t.mu.Unlock()
+		return []byte{0x04, 0x01, 0x01, 0x12, 0x00, 0x1A, 0x05, 0x91, 0xAF}, nil
+	case bytes.Equal(cmd, []byte{0x90, 0xAF, 0x00, 0x00, 0x00}) && t.version == 1:
+		t.version = 2
+		t.mu.Unlock()
+		return []byte{0x04, 0x01, 0x01, 0x02, 0x01, 0x1A, 0x05, 0x91, 0xAF}, nil
+	case bytes.Equal(cmd, []byte{0x90, 0xAF, 0x00, 0x00, 0x00}) && t.version == 2:
+		t.version = 0
+		t.mu.Unlock()
+		// UID, batch number and production week and year.
+		resp := append(append([]byte(nil), t.uid...), 0xBA, 0x34, 0x49, 0x95, 0x70, 0x15, 0x23)
+		return append(resp, 0x91, 0x00), nil
+	}
+	t.version = 0
+	t.mu.Unlock()
+	return t.Type4Tag.Transmit(cmd)
+}
@@ -0,0 +1,206 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package virtualreader
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// NTAG215 memory layout.
+const (
+	ntag215Pages     = 135
+	ntagPageSize     = 4
+	ntagUserStart    = 4
+	ntag215UserPages = 126 // Pages 4 to 129.
+	ntagCFG0Page     = 131
+	ntagCFG1Page     = 132
+	ntagPWDPage      = 133
+	ntagPACKPage     = 134
+)
+
+// NTAG commands passed through by the reader.
+const (
+	ntagGetVersion = 0x60
+	ntagPwdAuth    = 0x1B
+)
+
+// ntag215Version is the GET VERSION response of NTAG215.
+var ntag215Version = []byte{0x00, 0x04, 0x04, 0x02, 0x01, 0x00, 0x11, 0x03}
+
+var (
+	swOK              = []byte{0x90, 0x00}
+	swWrongLength     = []byte{0x67, 0x00}
+	swNotSupported    = []byte{0x6A, 0x81}
+	swOperationError  = []byte{0x63, 0x00}
+	swCLANotSupported = []byte{0x6E, 0x00}
+)
+
+// NTAG is a virtual NTAG215 formatted for NDEF. Like a PC/SC reader it
+// answers READ BINARY and UPDATE BINARY pseudo-APDUs addressing pages and
+// GET VERSION wrapped in the FF 00 00 00 pass-through pseudo-APDU.
+// Pages 0 and 1 are read-only, the lock bytes of page 2 and the capability
+// container of page 3 are one-time programmable. Pages from AUTH0 on are
+// written, and with PROT set read, only after PWD_AUTH, which holds until
+// the tag is placed on a reader again.
+type NTAG struct {
+	mu            sync.Mutex
+	uid           []byte
+	mem           []byte
+	authenticated bool
+}
+
+// NewNTAG215 returns an NTAG215 with the 7 byte uid holding an empty NDEF
+// message. Shorter UIDs are padded with zeros.
+func NewNTAG215(uid []byte) *NTAG {
+	t := &NTAG{uid: make([]byte, 7), mem: make([]byte, ntag215Pages*ntagPageSize)}
+	copy(t.uid, uid)
+	u := t.uid
+	copy(t.mem, []byte{
+		u[0], u[1], u[2], 0x88 ^ u[0] ^ u[1] ^ u[2],
+		u[3], u[4], u[5], u[6],
+		u[3] ^ u[4] ^ u[5] ^ u[6], 0x48, 0x00, 0x00,
+		0xE1, 0x10, 0x3E, 0x00, // Capability container: 496 bytes.
+		0x03, 0x00, 0xFE, 0x00, // Empty NDEF message.
+	})
+	copy(t.mem[130*ntagPageSize:], []byte{
+		0x00, 0x00, 0x00, 0xBD, // Dynamic lock bytes.
+		0x04, 0x00, 0x00, 0xFF, // CFG0, AUTH0 disabling password protection.
+		0x00, 0x05, 0x00, 0x00, // CFG1.
+		0xFF, 0xFF, 0xFF, 0xFF, // PWD.
+	})
+	return t
+}
+
+// WriteNDEF stores msg, the raw encoding of an NDEF message, as if written
+// by a reader.
+func (t *NTAG) WriteNDEF(msg []byte) error {
+	tlv := []byte{0x03, byte(len(msg))}
+	if len(msg) >= 0xFF {
+		tlv = []byte{0x03, 0xFF, byte(len(msg) >> 8), byte(len(msg))}
+	}
+	tlv = append(append(tlv, msg...), 0xFE)
+	if len(tlv) > ntag215UserPages*ntagPageSize {
+		return fmt.Errorf("virtualreader: ndef message of %d bytes exceeds the ntag215 user memory", len(msg))
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	copy(t.mem[ntagUserStart*ntagPageSize:], tlv)
+	return nil
+}
+
+// Memory returns a copy of the tag memory.
+func (t *NTAG) Memory() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]byte(nil), t.mem...)
+}
+
+// ATR returns the ATR a PC/SC reader reports for NTAG.
+func (t *NTAG) ATR() []byte { return storageATR(0x0003) }
+
+// UID returns the UID of the tag.
+func (t *NTAG) UID() []byte { return t.uid }
+
+// Transmit handles READ BINARY, UPDATE BINARY and pass-through pseudo-APDUs.
+func (t *NTAG) Transmit(cmd []byte) ([]byte, error) {
+	if len(cmd) < 4 {
+		return swWrongLength, nil
+	}
+	if cmd[0] != 0xFF {
+		return swCLANotSupported, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	page := int(cmd[3])
+	switch cmd[1] {
+	case 0x00:
+		if len(cmd) < 6 || len(cmd) != 5+int(cmd[4]) {
+			return swWrongLength, nil
+		}
+		return t.passThrough(cmd[5:]), nil
+	case 0xB0:
+		n := 16
+		if len(cmd) > 4 && cmd[4] != 0 {
+			n = int(cmd[4])
+		}
+		if n > 16 {
+			return swWrongLength, nil
+		}
+		if page >= ntag215Pages || (t.readProtected() && !t.allowed(page)) {
+			return swOperationError, nil
+		}
+		// READ returns four pages, rolling over past the last page. The
+		// password and its acknowledge read as zeros.
+		resp := make([]byte, n, n+2)
+		for i := range resp {
+			off := (page*ntagPageSize + i) % len(t.mem)
+			if off/ntagPageSize < ntagPWDPage {
+				resp[i] = t.mem[off]
+			}
+		}
+		return append(resp, swOK...), nil
+	case 0xD6:
+		if len(cmd) != 5+ntagPageSize || cmd[4] != ntagPageSize {
+			return swWrongLength, nil
+		}
+		data := cmd[5:]
+		p := t.mem[page*ntagPageSize:]
+		switch {
+		case page < 2 || page >= ntag215Pages || !t.allowed(page):
+			return swOperationError, nil
+		case page == 2:
+			p[2] |= data[2]
+			p[3] |= data[3]
+		case page == 3:
+			for i := range data {
+				p[i] |= data[i]
+			}
+		default:
+			copy(p, data)
+		}
+		return swOK, nil
+	default:
+		return swNotSupported, nil
+	}
+}
+
+// passThrough handles the native command frame. t.mu must be held.
+func (t *NTAG) passThrough(frame []byte) []byte {
+	switch {
+	case len(frame) == 1 && frame[0] == ntagGetVersion:
+		return append(append([]byte(nil), ntag215Version...), swOK...)
+	case len(frame) == 5 && frame[0] == ntagPwdAuth:
+		pwd := t.mem[ntagPWDPage*ntagPageSize : (ntagPWDPage+1)*ntagPageSize]
+		if !bytes.Equal(frame[1:], pwd) {
+			t.authenticated = false
+			return swOperationError
+		}
+		t.authenticated = true
+		pack := t.mem[ntagPACKPage*ntagPageSize : ntagPACKPage*ntagPageSize+2]
+		return append(append([]byte(nil), pack...), swOK...)
+	}
+	return swOperationError
+}
+
+// allowed reports whether page may be accessed under the password
+// protection. t.mu must be held.
+func (t *NTAG) allowed(page int) bool {
+	return t.authenticated || page < int(t.mem[ntagCFG0Page*ntagPageSize+3])
+}
+
+// readProtected reports whether the password protection covers reads,
+// PROT of ACCESS. t.mu must be held.
+func (t *NTAG) readProtected() bool {
+	return t.mem[ntagCFG1Page*ntagPageSize]&0x80 != 0
+}
+
+// reset ends the authentication, as taking the tag out of the field does.
+func (t *NTAG) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.authenticated = false
+}
@@ -0,0 +1,258 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package virtualreader provides a simulated card reader backend. Tags are
+// placed on and removed from its readers programmatically, so examples and
+// integration tests exercise the SDK without hardware:
+//
+//	vr := virtualreader.New("Virtual Reader 00")
+//	vr.Insert("Virtual Reader 00", virtualreader.NewNTAG215(uid, msg))
+//	sdk := scardkit.New(scardkit.WithBackend(vr))
+package virtualreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/happy-sdk/scardkit/apdu"
+	"github.com/happy-sdk/scardkit/cardreader"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+	"github.com/happy-sdk/scardkit/transport"
+)
+
+// ErrUnknownReader is returned for operations on readers the backend does
+// not have.
+var ErrUnknownReader = errors.New("virtualreader: unknown reader")
+
+// Tag is a virtual tag which can be placed on a reader. Besides its own
+// commands it receives every command but GET DATA for the UID, which the
+// reader answers.
+type Tag interface {
+	apdu.Transceiver
+	ATR() []byte
+	UID() []byte
+}
+
+// slot is the state of a reader.
+type slot struct {
+	reader   cardreader.Reader
+	tag      Tag
+	reported bool // The tag was returned by WaitCard.
+}
+
+// Backend is a transport.Backend with virtual readers. It is safe for
+// concurrent use.
+type Backend struct {
+	mu      sync.Mutex
+	slots   []*slot
+	changed chan struct{} // Closed and replaced on every change.
+	gen     int           // Incremented when a reader is added or removed.
+}
+
+// New returns a backend with the named readers, all empty.
+func New(readers ...string) *Backend {
+	b := &Backend{changed: make(chan struct{})}
+	for _, name := range readers {
+		b.slots = append(b.slots, &slot{reader: *cardreader.NewReader(name)})
+	}
+	return b
+}
+
+// notify wakes up waiters. b.mu must be held.
+func (b *Backend) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+func (b *Backend) slot(name string) (*slot, error) {
+	for _, s := range b.slots {
+		if s.reader.Name == name {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownReader, name)
+}
+
+// AddReader attaches a reader. Adding a reader which exists does nothing.
+func (b *Backend) AddReader(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, err := b.slot(name); err == nil {
+		return
+	}
+	b.slots = append(b.slots, &slot{reader: *cardreader.NewReader(name)})
+	b.gen++
+	b.notify()
+}
+
+// RemoveReader detaches a reader together with the tag placed on it.
+func (b *Backend) RemoveReader(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, s := range b.slots {
+		if s.reader.Name == name {
+			b.slots = append(b.slots[:i], b.slots[i+1:]...)
+			b.gen++
+			b.notify()
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %q", ErrUnknownReader, name)
+}
+
+// Insert places t on the reader, replacing the tag already there.
+func (b *Backend) Insert(reader string, t Tag) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, err := b.slot(reader)
+	if err != nil {
+		return err
+	}
+	s.tag, s.reported = t, false
+	b.notify()
+	return nil
+}
+
+// Remove takes the tag off the reader. Cards connected to it fail with
+// pcsc.ErrCardRemoved afterwards.
+func (b *Backend) Remove(reader string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, err := b.slot(reader)
+	if err != nil {
+		return err
+	}
+	s.tag = nil
+	b.notify()
+	return nil
+}
+
+// Tap places t on the reader and removes it after d, in the background.
+func (b *Backend) Tap(reader string, t Tag, d time.Duration) error {
+	if err := b.Insert(reader, t); err != nil {
+		return err
+	}
+	time.AfterFunc(d, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if s, err := b.slot(reader); err == nil && s.tag == t {
+			s.tag = nil
+			b.notify()
+		}
+	})
+	return nil
+}
+
+// ListReaders returns the readers of the backend.
+func (b *Backend) ListReaders() ([]cardreader.Reader, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	readers := make([]cardreader.Reader, len(b.slots))
+	for i, s := range b.slots {
+		readers[i] = s.reader
+	}
+	return readers, nil
+}
+
+// WaitCard waits until a tag is placed on one of readers. A tag is
+// returned once until it is removed or inserted again, as a PC/SC reader
+// reports a card left on it once. It returns without a card when timeout
+// elapses or a reader is added or removed.
+func (b *Backend) WaitCard(ctx context.Context, readers []cardreader.Reader, timeout time.Duration) (transport.Card, cardreader.Reader, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	b.mu.Lock()
+	gen := b.gen
+	b.mu.Unlock()
+	for {
+		b.mu.Lock()
+		if b.gen != gen {
+			b.mu.Unlock()
+			return nil, cardreader.Reader{}, nil
+		}
+		for _, s := range b.slots {
+			if s.tag == nil || s.reported || !selected(readers, s.reader.Name) {
+				continue
+			}
+			s.reported = true
+			if r, ok := s.tag.(interface{ reset() }); ok {
+				r.reset()
+			}
+			b.mu.Unlock()
+			return &card{backend: b, slot: s, tag: s.tag}, s.reader, nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, cardreader.Reader{}, ctx.Err()
+		case <-timer.C:
+			return nil, cardreader.Reader{}, nil
+		case <-changed:
+		}
+	}
+}
+
+func selected(readers []cardreader.Reader, name string) bool {
+	for _, r := range readers {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// card is a connection to a tag on a virtual reader.
+type card struct {
+	backend *Backend
+	slot    *slot
+	tag     Tag
+}
+
+func (c *card) ATR() []byte { return c.tag.ATR() }
+
+// UID returns the UID of the tag.
+func (c *card) UID() ([]byte, error) { return c.tag.UID(), nil }
+
+// present reports whether the tag is still on its reader.
+func (c *card) present() bool {
+	c.backend.mu.Lock()
+	defer c.backend.mu.Unlock()
+	return c.slot.tag == c.tag
+}
+
+func (c *card) Transmit(cmd []byte) ([]byte, error) {
+	if !c.present() {
+		return nil, &pcsc.Error{Op: "SCardTransmit", Code: pcsc.SCardWRemovedCard}
+	}
+	if len(cmd) >= 4 && cmd[0] == 0xFF && cmd[1] == 0xCA {
+		if cmd[2] != 0x00 {
+			return []byte{0x6A, 0x81}, nil
+		}
+		return append(append([]byte(nil), c.tag.UID()...), 0x90, 0x00), nil
+	}
+	return c.tag.Transmit(cmd)
+}
+
+func (c *card) Disconnect() error { return nil }
+
+// storageATR returns the ATR of a PC/SC Part 3 storage card with the given
+// card name, e.g. 0x0003 for MIFARE Ultralight and NTAG.
+func storageATR(name uint16) []byte {
+	hist := []byte{0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06, 0x03, byte(name >> 8), byte(name), 0x00, 0x00, 0x00, 0x00}
+	return withTCK(append([]byte{0x3B, 0x80 | byte(len(hist)), 0x80, 0x01}, hist...))
+}
+
+// withTCK appends the check byte to atr.
+func withTCK(atr []byte) []byte {
+	var tck byte
+	for _, b := range atr[1:] {
+		tck ^= b
+	}
+	return append(atr, tck)
+}
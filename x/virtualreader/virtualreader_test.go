@@ -0,0 +1,166 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package virtualreader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit"
+	"github.com/happy-sdk/scardkit/nfc/ndef"
+	"github.com/happy-sdk/scardkit/nfc/tag"
+	pcsc "github.com/happy-sdk/scardkit/pcsc"
+)
+
+func TestSDKNDEF(t *testing.T) {
+	uid := []byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	tests := []struct {
+		name string
+		tag  Tag
+		typ  tag.Type
+	}{
+		{"ntag215", NewNTAG215(uid), tag.TypeUltralight},
+		{"desfire", NewDESFire(uid, nil), tag.TypeDESFire},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			vr := New("Virtual Reader 00")
+			sdk := scardkit.New(scardkit.WithBackend(vr))
+			uri := "https://example.com/" + strings.Repeat("x", 300)
+
+			if err := vr.Insert("Virtual Reader 00", tt.tag); err != nil {
+				t.Fatal(err)
+			}
+			if err := sdk.WriteNDEF(ctx, ndef.NewMessage(ndef.NewURIRecord(uri)), nil); err != nil {
+				t.Fatalf("WriteNDEF() error = %v", err)
+			}
+			if err := vr.Insert("Virtual Reader 00", tt.tag); err != nil {
+				t.Fatal(err)
+			}
+			msg, err := sdk.ReadNDEF(ctx, nil)
+			if err != nil {
+				t.Fatalf("ReadNDEF() error = %v", err)
+			}
+			if got, err := msg.Records[0].URI(); err != nil || got != uri {
+				t.Errorf("URI() = %q, %v", got, err)
+			}
+			if typ := tag.Detect(tag.Signature{ATR: tt.tag.ATR()}); typ != tt.typ {
+				t.Errorf("Detect() = %s, want %s", typ, tt.typ)
+			}
+		})
+	}
+}
+
+func TestNTAGMemory(t *testing.T) {
+	n := NewNTAG215([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	tests := []struct {
+		cmd, want []byte
+	}{
+		{[]byte{0xFF, 0xB0, 0x00, 0x00, 0x04}, []byte{0x04, 0x11, 0x22, 0xBF, 0x90, 0x00}},
+		{[]byte{0xFF, 0xD6, 0x00, 0x01, 0x04, 1, 2, 3, 4}, swOperationError},
+		{[]byte{0xFF, 0xD6, 0x00, 0x03, 0x04, 0x00, 0x00, 0x00, 0x0F}, swOK},
+		{[]byte{0xFF, 0xB0, 0x00, 0x03, 0x04}, []byte{0xE1, 0x10, 0x3E, 0x0F, 0x90, 0x00}},
+		{[]byte{0xFF, 0xB0, 0x00, 0x85, 0x04}, []byte{0x00, 0x00, 0x00, 0x00, 0x90, 0x00}},
+		{[]byte{0xFF, 0xB0, 0x00, 0x87, 0x04}, swOperationError},
+		{[]byte{0x00, 0xA4, 0x04, 0x00}, swCLANotSupported},
+	}
+	for _, tt := range tests {
+		if got, err := n.Transmit(tt.cmd); err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("Transmit(%X) = %X, %v, want %X", tt.cmd, got, err, tt.want)
+		}
+	}
+	if err := n.WriteNDEF(make([]byte, 600)); err == nil {
+		t.Error("WriteNDEF() accepted 600 bytes")
+	}
+}
+
+func TestNTAGPassword(t *testing.T) {
+	n := NewNTAG215([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	write := func(page byte, data ...byte) []byte {
+		return append([]byte{0xFF, 0xD6, 0x00, page, 0x04}, data...)
+	}
+	tests := []struct {
+		cmd, want []byte
+	}{
+		{write(0x86, 0xAB, 0xCD, 0x00, 0x00), swOK}, // PACK
+		{write(0x84, 0x80, 0x05, 0x00, 0x00), swOK}, // CFG1: PROT
+		{write(0x83, 0x04, 0x00, 0x00, 0x10), swOK}, // CFG0: AUTH0 10h
+		{write(0x0F, 1, 2, 3, 4), swOK},             // Below AUTH0.
+		{write(0x10, 1, 2, 3, 4), swOperationError}, // Protected.
+		{[]byte{0xFF, 0xB0, 0x00, 0x10, 0x04}, swOperationError},
+		{[]byte{0xFF, 0x00, 0x00, 0x00, 0x05, 0x1B, 0, 0, 0, 0}, swOperationError},
+		{[]byte{0xFF, 0x00, 0x00, 0x00, 0x05, 0x1B, 0xFF, 0xFF, 0xFF, 0xFF}, []byte{0xAB, 0xCD, 0x90, 0x00}},
+		{write(0x10, 1, 2, 3, 4), swOK},
+		{[]byte{0xFF, 0xB0, 0x00, 0x10, 0x04}, []byte{1, 2, 3, 4, 0x90, 0x00}},
+	}
+	for _, tt := range tests {
+		if got, err := n.Transmit(tt.cmd); err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("Transmit(%X) = %X, %v, want %X", tt.cmd, got, err, tt.want)
+		}
+	}
+	n.reset()
+	if got, _ := n.Transmit(write(0x10, 5, 6, 7, 8)); !bytes.Equal(got, swOperationError) {
+		t.Errorf("write after reset = %X", got)
+	}
+}
+
+func TestDESFireVersion(t *testing.T) {
+	d := NewDESFire([]byte{1, 2, 3, 4, 5, 6, 7}, nil)
+	var version []byte
+	cmd := []byte{0x90, 0x60, 0x00, 0x00, 0x00}
+	for i := 0; i < 3; i++ {
+		resp, err := d.Transmit(cmd)
+		if err != nil || len(resp) < 2 || resp[len(resp)-2] != 0x91 {
+			t.Fatalf("frame %d = %X, %v", i, resp, err)
+		}
+		version = append(version, resp[:len(resp)-2]...)
+		cmd = []byte{0x90, 0xAF, 0x00, 0x00, 0x00}
+	}
+	if len(version) != 28 || !bytes.Equal(version[14:21], d.UID()) {
+		t.Errorf("version = %X", version)
+	}
+}
+
+func TestWaitCard(t *testing.T) {
+	ctx := context.Background()
+	vr := New("r0")
+	readers, _ := vr.ListReaders()
+	if card, _, err := vr.WaitCard(ctx, readers, 10*time.Millisecond); card != nil || err != nil {
+		t.Fatalf("WaitCard() on an empty reader = %v, %v", card, err)
+	}
+
+	time.AfterFunc(10*time.Millisecond, func() { vr.AddReader("r1") })
+	if card, _, err := vr.WaitCard(ctx, readers, time.Second); card != nil || err != nil {
+		t.Fatalf("WaitCard() on reader change = %v, %v", card, err)
+	}
+
+	n := NewNTAG215([]byte{1, 2, 3, 4, 5, 6, 7})
+	time.AfterFunc(10*time.Millisecond, func() { _ = vr.Insert("r0", n) })
+	card, r, err := vr.WaitCard(ctx, readers, time.Second)
+	if err != nil || card == nil || r.Name != "r0" {
+		t.Fatalf("WaitCard() = %v, %s, %v", card, r.Name, err)
+	}
+	if resp, _ := card.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00}); !bytes.Equal(resp, []byte{1, 2, 3, 4, 5, 6, 7, 0x90, 0x00}) {
+		t.Errorf("GET DATA = %X", resp)
+	}
+	if again, _, _ := vr.WaitCard(ctx, readers, 10*time.Millisecond); again != nil {
+		t.Error("WaitCard() reported a tag left on the reader twice")
+	}
+	if err := vr.Remove("r0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := card.Transmit([]byte{0xFF, 0xB0, 0x00, 0x00, 0x04}); !errors.Is(err, pcsc.ErrCardRemoved) {
+		t.Errorf("Transmit() after removal error = %v", err)
+	}
+	if err := vr.Insert("r9", n); !errors.Is(err, ErrUnknownReader) {
+		t.Errorf("Insert() on an unknown reader error = %v", err)
+	}
+}
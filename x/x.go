@@ -0,0 +1,15 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package x is the experimental namespace of scardkit. Packages below x
+// provide new surfaces, such as alternative backends, card emulation and
+// high-level tag operations, whose API may still change between releases
+// without notice. The rest of the module keeps its API backward compatible.
+//
+// Once an experimental package is considered stable it graduates to the
+// root namespace, e.g. x/tag becomes nfc/tag. The x package is then kept for
+// at least one minor release as a thin forwarding layer whose identifiers are
+// marked deprecated and point to their stable counterparts, so users can
+// migrate by changing the import path.
+package x
@@ -0,0 +1,46 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"github.com/happy-sdk/scardkit/cardreader"
+)
+
+// WithZones makes the SDK tag the events of card sessions with the zone of
+// their reader in z, so filters, handlers, sinks and access policies see
+// cardreader.Event.Zone, and pass card inserted events to the zone handlers
+// of z once emitted. Zones assigned to z later apply to the next cards.
+func WithZones(z *cardreader.Zones) Option {
+	return func(sdk *SDK) {
+		sdk.zones = z
+	}
+}
+
+// HandleZone registers h for cards tapped on the readers of the named zone
+// of the registry set with WithZones. It is a filter handler, see
+// HandleFilter.
+func (sdk *SDK) HandleZone(zone string, h CardHandler) {
+	sdk.HandleFilter(Filter{Zones: []string{zone}}, h)
+}
+
+// zoneOf returns the zone reader is assigned to, empty without zones.
+func (sdk *SDK) zoneOf(reader string) string {
+	if sdk.zones == nil {
+		return ""
+	}
+	zone, _ := sdk.zones.Zone(reader)
+	return zone
+}
+
+// dispatchZone passes ev to the zone handlers of its zone, logging the
+// errors they return.
+func (sdk *SDK) dispatchZone(ev cardreader.Event) {
+	if sdk.zones == nil || ev.Zone == "" {
+		return
+	}
+	if _, err := sdk.zones.Dispatch(ev); err != nil {
+		sdk.logger.Warn("zone handler", "reader", ev.Reader, "zone", ev.Zone, "error", err)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 The Happy Authors
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package scardkit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/happy-sdk/scardkit/cardreader"
+	"github.com/happy-sdk/scardkit/transport"
+	"github.com/happy-sdk/scardkit/x/virtualreader"
+)
+
+func TestWithZones(t *testing.T) {
+	tests := []struct {
+		reader  string
+		zone    string
+		handler string
+	}{
+		{"entrance", "lobby", "zone"},
+		{"desk", "", "card"},
+	}
+	for _, tt := range tests {
+		vr := virtualreader.New(tt.reader)
+		if err := vr.Insert(tt.reader, virtualreader.NewNTAG215([]byte{0x04, 1, 2, 3, 4, 5, 6})); err != nil {
+			t.Fatal(err)
+		}
+		var zones cardreader.Zones
+		zones.Assign("lobby", "entrance")
+		var dispatched string
+		zones.Handle("lobby", func(ev cardreader.Event) error {
+			dispatched = ev.Zone
+			return nil
+		})
+		var handler, zone string
+		record := func(name string) CardHandler {
+			return func(_ context.Context, ev cardreader.Event, _ transport.Card) error {
+				handler, zone = name, ev.Zone
+				return nil
+			}
+		}
+		sdk := New(WithBackend(vr), WithZones(&zones), WithCardHandler(record("card")))
+		sdk.HandleZone("lobby", record("zone"))
+		ev, err := sdk.WaitForCard(context.Background(), time.Second)
+		if err != nil {
+			t.Fatalf("%s: WaitForCard() error = %v", tt.reader, err)
+		}
+		if err := sdk.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Zone != tt.zone || zone != tt.zone || dispatched != tt.zone {
+			t.Errorf("%s: zone of event %q, handler %q, zone handler %q; want %q", tt.reader, ev.Zone, zone, dispatched, tt.zone)
+		}
+		if handler != tt.handler {
+			t.Errorf("%s: handled by %s handler, want %s", tt.reader, handler, tt.handler)
+		}
+	}
+}